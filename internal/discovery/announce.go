@@ -1,6 +1,7 @@
 package discovery
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -12,14 +13,17 @@ import (
 
 // Announcer announces a jumpboot-mcp service via mDNS
 type Announcer struct {
-	server *mdns.Server
-	info   ServiceInfo
+	servers []*mdns.Server
+	info    ServiceInfo
+	ifaces  InterfaceFilter
 }
 
-// NewAnnouncer creates a new mDNS announcer for the given service info
-func NewAnnouncer(info ServiceInfo) *Announcer {
+// NewAnnouncer creates a new mDNS announcer for the given service info, announcing
+// the addresses of and listening on the interfaces ifaces selects
+func NewAnnouncer(info ServiceInfo, ifaces InterfaceFilter) *Announcer {
 	return &Announcer{
-		info: info,
+		info:   info,
+		ifaces: ifaces,
 	}
 }
 
@@ -33,9 +37,18 @@ func (a *Announcer) Start() error {
 	if a.info.Note != "" {
 		txtRecords = append(txtRecords, fmt.Sprintf("note=%s", a.info.Note))
 	}
+	if a.info.GPU != "" {
+		txtRecords = append(txtRecords, fmt.Sprintf("gpu=%s", a.info.GPU))
+	}
+	if a.info.WebSocket {
+		txtRecords = append(txtRecords, "websocket=true")
+	}
+	if a.info.SSE {
+		txtRecords = append(txtRecords, "sse=true")
+	}
 
 	// Get local IPs
-	ips, err := getLocalIPs()
+	ips, err := getLocalIPs(a.ifaces)
 	if err != nil {
 		return fmt.Errorf("failed to get local IPs: %w", err)
 	}
@@ -55,25 +68,48 @@ func (a *Announcer) Start() error {
 	}
 
 	// Create and start the server
-	server, err := mdns.NewServer(&mdns.Config{Zone: service})
-	if err != nil {
-		return fmt.Errorf("failed to start mDNS server: %w", err)
+	if a.ifaces.IsZero() {
+		server, err := mdns.NewServer(&mdns.Config{Zone: service})
+		if err != nil {
+			return fmt.Errorf("failed to start mDNS server: %w", err)
+		}
+		a.servers = []*mdns.Server{server}
+		return nil
 	}
 
-	a.server = server
+	// With an explicit selection, listen on each selected interface
+	ifaces, err := a.ifaces.multicastInterfaces()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, iface := range ifaces {
+		server, err := mdns.NewServer(&mdns.Config{Zone: service, Iface: &iface})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", iface.Name, err))
+			continue
+		}
+		a.servers = append(a.servers, server)
+	}
+	if len(a.servers) == 0 {
+		return fmt.Errorf("failed to start mDNS server: %w", errors.Join(errs...))
+	}
 	return nil
 }
 
 // Stop stops announcing the service
 func (a *Announcer) Stop() error {
-	if a.server != nil {
-		return a.server.Shutdown()
+	var errs []error
+	for _, server := range a.servers {
+		errs = append(errs, server.Shutdown())
 	}
-	return nil
+	a.servers = nil
+	return errors.Join(errs...)
 }
 
-// getLocalIPs returns the local IP addresses for mDNS announcement
-func getLocalIPs() ([]net.IP, error) {
+// getLocalIPs returns the local IP addresses for mDNS announcement from the interfaces
+// the filter allows
+func getLocalIPs(filter InterfaceFilter) ([]net.IP, error) {
 	var ips []net.IP
 
 	interfaces, err := net.Interfaces()
@@ -87,8 +123,9 @@ func getLocalIPs() ([]net.IP, error) {
 			continue
 		}
 
-		// Only include physical network interfaces (whitelist approach)
-		if !isPhysicalInterface(iface.Name) {
+		// Only include physical network interfaces (whitelist approach) unless the
+		// selection says otherwise
+		if !filter.Allows(iface.Name) {
 			continue
 		}
 
@@ -106,8 +143,8 @@ func getLocalIPs() ([]net.IP, error) {
 				ip = v.IP
 			}
 
-			// Only include routable IPv4 addresses
-			if ip != nil && ip.To4() != nil && isRoutableIP(ip) {
+			// Only include routable addresses; IPv6 ones are announced as AAAA records
+			if ip != nil && isRoutableIP(ip) {
 				ips = append(ips, ip)
 			}
 		}
@@ -138,6 +175,9 @@ func isPhysicalInterface(name string) bool {
 		"en", // macOS ethernet/wifi (en0, en1, etc.)
 		// BSD
 		"bge", "em", "igb", "ix", "re", // Common BSD ethernet drivers
+		// Windows names adapters by connection: "Ethernet 2" and "WLAN" match the
+		// prefixes above, Hyper-V switches ("vEthernet (...)") stay excluded
+		"wi-fi", "wifi", "local area connection", "wireless network connection",
 	}
 
 	nameLower := strings.ToLower(name)
@@ -151,10 +191,9 @@ func isPhysicalInterface(name string) bool {
 
 // isRoutableIP returns true if the IP is a normal routable address (not virtual/VPN)
 func isRoutableIP(ip net.IP) bool {
-	// Only check IPv4 addresses
 	ip4 := ip.To4()
 	if ip4 == nil {
-		return false
+		return isRoutableIPv6(ip)
 	}
 
 	// Reject loopback
@@ -182,6 +221,19 @@ func isRoutableIP(ip net.IP) bool {
 	return true
 }
 
+// tailscaleULA is the IPv6 range Tailscale assigns to its interfaces
+var tailscaleULA = &net.IPNet{IP: net.ParseIP("fd7a:115c:a1e0::"), Mask: net.CIDRMask(48, 128)}
+
+// isRoutableIPv6 returns true for global unicast and unique local IPv6 addresses.
+// Link-local addresses are left out: they are only usable together with the interface
+// zone, which browsers learn from the packet source instead.
+func isRoutableIPv6(ip net.IP) bool {
+	if len(ip) != net.IPv6len || !ip.IsGlobalUnicast() {
+		return false
+	}
+	return !tailscaleULA.Contains(ip)
+}
+
 // GetDefaultInstanceName returns the default instance name (hostname)
 func GetDefaultInstanceName() string {
 	hostname, err := os.Hostname()
@@ -2,37 +2,128 @@ package discovery
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
-const mdnsAddr = "224.0.0.251:5353"
+// mDNS multicast groups
+const (
+	mdnsAddr  = "224.0.0.251:5353"
+	mdnsAddr6 = "[ff02::fb]:5353"
+)
 
 // Discover searches for jumpboot-mcp services on the local network
 // It uses the packet source address as the service host, which is more reliable
 // than relying on announced A records that might include virtual interfaces.
-func Discover(ctx context.Context, timeout time.Duration) ([]ServiceInfo, error) {
+// Queries go out over IPv4 and IPv6 at the same time, on the system's default
+// multicast interface or on each interface ifaces selects; discovery fails only if
+// none of them works. The announced A and AAAA addresses become further candidates.
+func Discover(ctx context.Context, timeout time.Duration, ifaces InterfaceFilter) ([]ServiceInfo, error) {
 	// Map to collect services by instance name (to deduplicate)
 	services := make(map[string]*ServiceInfo)
 	var mu sync.Mutex
 
+	handle := func(resp *dns.Msg, src *net.UDPAddr) {
+		// Extract service info from the response, using the source address as the host
+		info := parseResponse(resp, src)
+		if info == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// Use instance name as key to deduplicate
+		if existing, ok := services[info.InstanceName]; ok {
+			// Merge: keep existing but update if we got more info
+			if existing.Note == "" && info.Note != "" {
+				existing.Note = info.Note
+			}
+			if existing.GPU == "" && info.GPU != "" {
+				existing.GPU = info.GPU
+			}
+			if existing.Port == 0 {
+				existing.Port = info.Port
+			}
+			existing.WebSocket = existing.WebSocket || info.WebSocket
+			existing.SSE = existing.SSE || info.SSE
+			existing.Addresses = appendUnique(existing.Addresses, info.Addresses...)
+		} else {
+			services[info.InstanceName] = info
+		}
+	}
+
+	// nil stands for the default multicast interface
+	var selected []*net.Interface
+	if ifaces.IsZero() {
+		selected = []*net.Interface{nil}
+	} else {
+		list, err := ifaces.multicastInterfaces()
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			selected = append(selected, &list[i])
+		}
+	}
+
+	var wg sync.WaitGroup
+	var errs []error
+	for _, iface := range selected {
+		for _, group := range []struct{ network, addr string }{{"udp4", mdnsAddr}, {"udp6", mdnsAddr6}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := query(ctx, group.network, group.addr, iface, timeout, handle); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if len(errs) == 2*len(selected) {
+		return nil, errors.Join(errs...)
+	}
+	return mapToSlice(services), nil
+}
+
+// query sends a PTR query for the service type to an mDNS group, out of iface if not
+// nil, and passes every response received within timeout to handle
+func query(ctx context.Context, network, group string, iface *net.Interface, timeout time.Duration, handle func(*dns.Msg, *net.UDPAddr)) error {
 	// Create UDP connection for multicast
-	addr, err := net.ResolveUDPAddr("udp4", mdnsAddr)
+	addr, err := net.ResolveUDPAddr(network, group)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Listen on all interfaces
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	conn, err := net.ListenUDP(network, &net.UDPAddr{Port: 0})
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer conn.Close()
 
+	if iface != nil {
+		if network == "udp6" {
+			err = ipv6.NewPacketConn(conn).SetMulticastInterface(iface)
+		} else {
+			err = ipv4.NewPacketConn(conn).SetMulticastInterface(iface)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", iface.Name, err)
+		}
+	}
+
 	// Build the mDNS query
 	msg := new(dns.Msg)
 	msg.SetQuestion(ServiceType+".local.", dns.TypePTR)
@@ -41,13 +132,13 @@ func Discover(ctx context.Context, timeout time.Duration) ([]ServiceInfo, error)
 	// Pack the message
 	buf, err := msg.Pack()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Send the query
 	_, err = conn.WriteToUDP(buf, addr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Set read deadline
@@ -59,7 +150,7 @@ func Discover(ctx context.Context, timeout time.Duration) ([]ServiceInfo, error)
 	for {
 		select {
 		case <-ctx.Done():
-			return mapToSlice(services), nil
+			return nil
 		default:
 		}
 
@@ -67,7 +158,7 @@ func Discover(ctx context.Context, timeout time.Duration) ([]ServiceInfo, error)
 		if err != nil {
 			// Timeout is expected
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				break
+				return nil
 			}
 			continue
 		}
@@ -77,34 +168,18 @@ func Discover(ctx context.Context, timeout time.Duration) ([]ServiceInfo, error)
 		if err := resp.Unpack(recvBuf[:n]); err != nil {
 			continue
 		}
-
-		// Extract service info from the response, using src.IP as the host
-		info := parseResponse(resp, src.IP)
-		if info != nil {
-			mu.Lock()
-			// Use instance name as key to deduplicate
-			if existing, ok := services[info.InstanceName]; ok {
-				// Merge: keep existing but update if we got more info
-				if existing.Note == "" && info.Note != "" {
-					existing.Note = info.Note
-				}
-			} else {
-				services[info.InstanceName] = info
-			}
-			mu.Unlock()
-		}
+		handle(resp, src)
 	}
-
-	return mapToSlice(services), nil
 }
 
 // parseResponse extracts service info from an mDNS response
-// The sourceIP is the actual IP address the packet came from
-func parseResponse(msg *dns.Msg, sourceIP net.IP) *ServiceInfo {
+// The source is the actual address the packet came from
+func parseResponse(msg *dns.Msg, source *net.UDPAddr) *ServiceInfo {
 	var info *ServiceInfo
 	var instanceName string
 	var port int
 	var txtRecords []string
+	var announced []string
 
 	// Look through all answers
 	allRecords := append(msg.Answer, msg.Extra...)
@@ -125,6 +200,10 @@ func parseResponse(msg *dns.Msg, sourceIP net.IP) *ServiceInfo {
 		case *dns.TXT:
 			// TXT records give us metadata
 			txtRecords = append(txtRecords, r.Txt...)
+		case *dns.A:
+			announced = append(announced, r.A.String())
+		case *dns.AAAA:
+			announced = append(announced, r.AAAA.String())
 		}
 	}
 
@@ -133,9 +212,15 @@ func parseResponse(msg *dns.Msg, sourceIP net.IP) *ServiceInfo {
 		return nil
 	}
 
+	// Use the packet source IP first! A link-local IPv6 source keeps its zone.
+	host := source.IP.String()
+	if source.Zone != "" && source.IP.IsLinkLocalUnicast() {
+		host += "%" + source.Zone
+	}
 	info = &ServiceInfo{
 		InstanceName: instanceName,
-		Host:         sourceIP.String(), // Use the packet source IP!
+		Host:         host,
+		Addresses:    appendUnique([]string{host}, announced...),
 		Port:         port,
 		Endpoint:     "/mcp", // Default
 	}
@@ -144,10 +229,16 @@ func parseResponse(msg *dns.Msg, sourceIP net.IP) *ServiceInfo {
 	for _, txt := range txtRecords {
 		if val, ok := strings.CutPrefix(txt, "note="); ok {
 			info.Note = val
+		} else if val, ok := strings.CutPrefix(txt, "gpu="); ok {
+			info.GPU = val
 		} else if val, ok := strings.CutPrefix(txt, "endpoint="); ok {
 			info.Endpoint = val
 		} else if val, ok := strings.CutPrefix(txt, "tls="); ok {
 			info.TLS = val == "true"
+		} else if val, ok := strings.CutPrefix(txt, "websocket="); ok {
+			info.WebSocket = val == "true"
+		} else if val, ok := strings.CutPrefix(txt, "sse="); ok {
+			info.SSE = val == "true"
 		}
 	}
 
@@ -168,6 +259,16 @@ func extractInstanceName(fullName string) string {
 	return ""
 }
 
+// appendUnique appends the addresses not already in list
+func appendUnique(list []string, addrs ...string) []string {
+	for _, addr := range addrs {
+		if !slices.Contains(list, addr) {
+			list = append(list, addr)
+		}
+	}
+	return list
+}
+
 // mapToSlice converts the services map to a slice
 func mapToSlice(m map[string]*ServiceInfo) []ServiceInfo {
 	result := make([]ServiceInfo, 0, len(m))
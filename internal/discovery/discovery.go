@@ -1,27 +1,77 @@
 package discovery
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
 
 // ServiceType is the mDNS service type for jumpboot-mcp servers
 const ServiceType = "_jumpboot-mcp._tcp"
 
+// Identification of federation proxies to the servers they connect to
+const (
+	FederationClientName = "jumpboot-mcp-proxy"    // MCP client name of the proxy
+	FederationHeader     = "X-Jumpboot-Federation" // HTTP header sent on every proxy request
+	OriginHeader         = "X-Jumpboot-Origin"     // comma-separated InstanceIDs of the proxies a request passed through
+)
+
+// Federation hop limits
+const (
+	DefaultMaxHops = 1 // a client reaches tools through at most one proxy
+
+	MetaHops   = "jumpboot/hops"   // tool _meta key: number of proxies in front of the tool's server
+	MetaOrigin = "jumpboot/origin" // tool _meta key: instance names the tool was proxied through
+)
+
+// InstanceID identifies this process in origin chains, so a server can recognize a
+// request that it forwarded itself
+var InstanceID = uuid.New().String()
+
 // ServiceInfo contains information about a discovered jumpboot-mcp service
 type ServiceInfo struct {
-	InstanceName string // Unique instance name (used as tool prefix)
-	Host         string // Hostname or IP address
-	Port         int    // Port number
-	Note         string // Human-readable description
-	Endpoint     string // HTTP endpoint path (e.g., "/mcp")
-	TLS          bool   // Whether TLS is enabled
+	InstanceName string   // Unique instance name (used as tool prefix)
+	Host         string   // Hostname or IP address
+	Addresses    []string // Candidate addresses in the order to try them; Host is the first
+	Port         int      // Port number
+	Note         string   // Human-readable description
+	GPU          string   // GPU summary (e.g., "2x NVIDIA A100 80GB, CUDA 12.2"), empty without GPUs
+	Endpoint     string   // HTTP endpoint path (e.g., "/mcp")
+	TLS          bool     // Whether TLS is enabled
+	WebSocket    bool     // Whether the endpoint also accepts WebSocket connections
+	SSE          bool     // Whether Endpoint is a legacy HTTP+SSE stream instead of streamable HTTP
+	Token        string   // Bearer token sent to the server (static remotes only)
+	Static       bool     // Configured with -remote or -remotes-file rather than discovered
 }
 
 // URL returns the full URL for the service
 func (s ServiceInfo) URL() string {
+	return s.URLFor(s.Host)
+}
+
+// URLFor returns the URL of the service at one of its addresses. IPv6 addresses are
+// bracketed, and the zone of a link-local address is escaped as URLs require.
+func (s ServiceInfo) URLFor(host string) string {
 	scheme := "http"
 	if s.TLS {
 		scheme = "https"
 	}
-	return fmt.Sprintf("%s://%s:%d%s", scheme, s.Host, s.Port, s.Endpoint)
+	host = strings.ReplaceAll(host, "%", "%25")
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, strconv.Itoa(s.Port)), s.Endpoint)
+}
+
+// Candidates returns the addresses to try when connecting, Host first
+func (s ServiceInfo) Candidates() []string {
+	candidates := []string{s.Host}
+	for _, addr := range s.Addresses {
+		if addr != s.Host {
+			candidates = append(candidates, addr)
+		}
+	}
+	return candidates
 }
 
 // ToolPrefix returns the prefix to use for tools from this service
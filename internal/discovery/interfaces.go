@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"path"
+)
+
+// InterfaceFilter selects the network interfaces used for announcing and browsing.
+// Entries are interface names or glob patterns such as "br*". The zero filter uses
+// the built-in whitelist of physical interfaces and the system's default multicast
+// interface.
+type InterfaceFilter struct {
+	Include []string // if set, only these interfaces are used (replaces the whitelist)
+	Exclude []string // never used, even if included
+}
+
+// IsZero reports whether the filter has no entries
+func (f InterfaceFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Validate checks that all patterns are well-formed
+func (f InterfaceFilter) Validate() error {
+	for _, pattern := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid interface pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the interface with the given name may be used
+func (f InterfaceFilter) Allows(name string) bool {
+	if matchesAny(f.Exclude, name) {
+		return false
+	}
+	if len(f.Include) > 0 {
+		return matchesAny(f.Include, name)
+	}
+	return isPhysicalInterface(name)
+}
+
+// matchesAny reports whether name matches one of the patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// multicastInterfaces returns the up, multicast-capable interfaces the filter allows
+func (f InterfaceFilter) multicastInterfaces() ([]net.Interface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []net.Interface
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if f.Allows(iface.Name) {
+			result = append(result, iface)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no multicast-capable interface matches the mDNS interface selection")
+	}
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package discovery
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+type originKey struct{}
+
+// ParseOrigin splits an OriginHeader value into its instance IDs
+func ParseOrigin(header string) []string {
+	var chain []string
+	for _, id := range strings.Split(header, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			chain = append(chain, id)
+		}
+	}
+	return chain
+}
+
+// WithOrigin stores the origin chain of an incoming request in ctx
+func WithOrigin(ctx context.Context, chain []string) context.Context {
+	return context.WithValue(ctx, originKey{}, chain)
+}
+
+// OriginFromContext returns the origin chain stored by WithOrigin
+func OriginFromContext(ctx context.Context) []string {
+	chain, _ := ctx.Value(originKey{}).([]string)
+	return chain
+}
+
+// OutgoingOrigin returns the OriginHeader value for a request this process forwards
+// on behalf of the request in ctx: the incoming chain followed by InstanceID
+func OutgoingOrigin(ctx context.Context) string {
+	return strings.Join(append(slices.Clone(OriginFromContext(ctx)), InstanceID), ",")
+}
@@ -0,0 +1,118 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RemoteSpec is an explicitly configured remote server, as listed in a remotes file
+type RemoteSpec struct {
+	URL      string `json:"url"`
+	Name     string `json:"name,omitempty"`      // instance name (default: derived from the host)
+	Note     string `json:"note,omitempty"`      // human-readable description
+	Token    string `json:"token,omitempty"`     // bearer token sent to the server
+	TokenEnv string `json:"token_env,omitempty"` // environment variable holding the token
+}
+
+// ParseRemote parses a -remote flag value of the form
+// URL[;name=NAME][;note=TEXT][;token=TOKEN][;token_env=VAR]
+func ParseRemote(value string) (ServiceInfo, error) {
+	parts := strings.Split(value, ";")
+	spec := RemoteSpec{URL: strings.TrimSpace(parts[0])}
+	for _, part := range parts[1:] {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return ServiceInfo{}, fmt.Errorf("invalid remote option %q (expected key=value)", part)
+		}
+		switch strings.TrimSpace(key) {
+		case "name":
+			spec.Name = val
+		case "note":
+			spec.Note = val
+		case "token":
+			spec.Token = val
+		case "token_env":
+			spec.TokenEnv = val
+		default:
+			return ServiceInfo{}, fmt.Errorf("unknown remote option %q (use name, note, token or token_env)", key)
+		}
+	}
+	return spec.ServiceInfo()
+}
+
+// LoadRemotes reads a JSON array of remote specs
+func LoadRemotes(path string) ([]ServiceInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remotes file: %w", err)
+	}
+	var specs []RemoteSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse remotes file %s: %w", path, err)
+	}
+
+	infos := make([]ServiceInfo, 0, len(specs))
+	for _, spec := range specs {
+		info, err := spec.ServiceInfo()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// ServiceInfo converts the spec to the service info the proxy connects to. A URL
+// path ending in /sse selects the legacy HTTP+SSE protocol.
+func (r RemoteSpec) ServiceInfo() (ServiceInfo, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ServiceInfo{}, fmt.Errorf("invalid remote URL %q (expected http(s)://host[:port]/path)", r.URL)
+	}
+
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return ServiceInfo{}, fmt.Errorf("invalid port in remote URL %q", r.URL)
+		}
+	}
+	endpoint := u.EscapedPath()
+	if endpoint == "" {
+		endpoint = "/mcp"
+	}
+
+	name := r.Name
+	if name == "" {
+		name = u.Hostname()
+	}
+	name = sanitizeInstanceName(name)
+	if name == "" {
+		return ServiceInfo{}, fmt.Errorf("remote %q needs a name", r.URL)
+	}
+
+	token := r.Token
+	if r.TokenEnv != "" {
+		if token = os.Getenv(r.TokenEnv); token == "" {
+			return ServiceInfo{}, fmt.Errorf("remote %s: environment variable %s is not set", name, r.TokenEnv)
+		}
+	}
+
+	return ServiceInfo{
+		InstanceName: name,
+		Host:         u.Hostname(),
+		Port:         port,
+		Note:         r.Note,
+		Endpoint:     endpoint,
+		TLS:          u.Scheme == "https",
+		SSE:          strings.HasSuffix(endpoint, "/sse"),
+		Token:        token,
+		Static:       true,
+	}, nil
+}
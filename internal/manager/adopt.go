@@ -0,0 +1,205 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/richinsley/jumpboot"
+)
+
+// adoptMarker prefixes the JSON printed by adoptProbeScript
+const adoptMarker = "__JUMPBOOT_ADOPT__"
+
+// adoptProbeScript prints what an adopted interpreter needs to be described as a
+// jumpboot environment
+const adoptProbeScript = `import json, site, sys, sysconfig
+try:
+    sp = site.getsitepackages()[0]
+except Exception:
+    sp = sysconfig.get_path("purelib")
+print("` + adoptMarker + `" + json.dumps({
+    "version": "Python " + sys.version.split()[0],
+    "prefix": sys.prefix,
+    "site_packages": sp,
+    "scripts": sysconfig.get_path("scripts"),
+    "libdir": sysconfig.get_config_var("LIBDIR") or "",
+    "include": sysconfig.get_path("include") or "",
+}))
+`
+
+// adoptProbe is the output of adoptProbeScript
+type adoptProbe struct {
+	Version      string `json:"version"`
+	Prefix       string `json:"prefix"`
+	SitePackages string `json:"site_packages"`
+	Scripts      string `json:"scripts"`
+	LibDir       string `json:"libdir"`
+	Include      string `json:"include"`
+}
+
+// AdoptEnvironment registers an existing Python installation or venv as an
+// environment without copying it. path is the interpreter or the directory of a venv
+// or installation. The environment gets its own directory under the base directory
+// for its workspace and bookkeeping; destroying it removes only that directory and
+// leaves the adopted installation in place. Post-create hooks do not run. With session
+// isolation or roles, adopting host installations requires the admin role.
+func (m *Manager) AdoptEnvironment(ctx context.Context, name, path string) (*EnvironmentInfo, error) {
+	if caller := CallerFromContext(ctx); caller.Role != "" || m.SessionIsolation() {
+		if err := m.checkAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	pythonPath, err := findAdoptedPython(path)
+	if err != nil {
+		return nil, err
+	}
+	if isSubPath(m.baseDir, pythonPath) {
+		return nil, fmt.Errorf("%s is inside the server's base directory; only installations outside it can be adopted", path)
+	}
+	m.mu.RLock()
+	for _, env := range m.environments {
+		if env.AdoptedPath == pythonPath {
+			m.mu.RUnlock()
+			return nil, fmt.Errorf("%s is already adopted as environment %s", pythonPath, env.ID)
+		}
+	}
+	m.mu.RUnlock()
+
+	output, err := runPython(ctx, &ManagedEnvironment{Env: &jumpboot.PythonEnvironment{PythonPath: pythonPath}}, "-c", adoptProbeScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w\n%s", pythonPath, err, output)
+	}
+	var probe adoptProbe
+	if err := decodeMarkedJSON(output, adoptMarker, &probe); err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", pythonPath, err)
+	}
+	env, err := adoptedPythonEnvironment(ctx, pythonPath, &probe)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		// Project venvs are usually called .venv or venv; their project is a better name
+		name = filepath.Base(probe.Prefix)
+		if name == ".venv" || name == "venv" {
+			name = filepath.Base(filepath.Dir(probe.Prefix))
+		}
+	}
+
+	release, err := m.reserveEnvironment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	id := uuid.New().String()
+	rootDir := filepath.Join(m.baseDir, id)
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create environment directory: %w", err)
+	}
+
+	managed := &ManagedEnvironment{
+		ID:          id,
+		Name:        name,
+		Env:         env,
+		PythonVer:   env.PythonVersion.String(),
+		RootDir:     rootDir,
+		AdoptedPath: pythonPath,
+		Owner:       ownerFor(ctx),
+		CreatedBy:   CallerFromContext(ctx).Principal,
+		secrets:     m.secrets,
+		network:     envNetwork{policy: NetworkPolicy{Mode: NetworkAllow}},
+	}
+	// An adopted conda environment may already have node
+	managed.refreshRuntimes(ctx)
+
+	m.mu.Lock()
+	m.environments[id] = managed
+	m.mu.Unlock()
+
+	return &EnvironmentInfo{
+		ID:            id,
+		Name:          name,
+		PythonVersion: env.PythonVersion.String(),
+		EnvPath:       env.EnvPath,
+		Owner:         managed.Owner,
+		Network:       NetworkAllow,
+		AdoptedPath:   pythonPath,
+		Runtimes:      managed.runtimesOf(),
+	}, nil
+}
+
+// findAdoptedPython resolves path, an interpreter or the directory of a venv or
+// installation, to an absolute interpreter path
+func findAdoptedPython(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("cannot adopt %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return abs, nil
+	}
+
+	candidates := []string{"bin/python3", "bin/python"}
+	if runtime.GOOS == "windows" {
+		candidates = []string{"Scripts/python.exe", "python.exe"}
+	}
+	for _, c := range candidates {
+		p := filepath.Join(abs, filepath.FromSlash(c))
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no Python interpreter found in %s (expected %s)", path, strings.Join(candidates, " or "))
+}
+
+// adoptedPythonEnvironment describes an adopted interpreter as a jumpboot environment.
+// Like jumpboot's venvs it has no micromamba, so packages are installed with pip.
+func adoptedPythonEnvironment(ctx context.Context, pythonPath string, probe *adoptProbe) (*jumpboot.PythonEnvironment, error) {
+	version, err := jumpboot.ParsePythonVersion(probe.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the version of %s: %w", pythonPath, err)
+	}
+	env := &jumpboot.PythonEnvironment{
+		BaseEnvironment: jumpboot.BaseEnvironment{
+			EnvironmentName: filepath.Base(probe.Prefix),
+			RootDir:         probe.Prefix,
+			EnvPath:         probe.Prefix,
+			EnvBinPath:      filepath.Dir(pythonPath),
+		},
+		PythonVersion:     version,
+		PythonPath:        pythonPath,
+		PythonHeadersPath: probe.Include,
+		SitePackagesPath:  probe.SitePackages,
+	}
+	if probe.LibDir != "" {
+		env.EnvLibPath = probe.LibDir
+		if runtime.GOOS != "windows" {
+			env.PythonLibPath = filepath.Join(probe.LibDir, fmt.Sprintf("libpython%s.so", version.MinorString()))
+		}
+	}
+
+	// pip is optional; without it nothing can be installed
+	pip := filepath.Join(probe.Scripts, "pip")
+	if runtime.GOOS == "windows" {
+		pip += ".exe"
+	}
+	if _, err := os.Stat(pip); err == nil {
+		env.PipPath = pip
+	}
+	if output, err := runPython(ctx, &ManagedEnvironment{Env: env}, "-m", "pip", "--version"); err == nil {
+		env.PipVersion, _ = jumpboot.ParsePipVersion(strings.TrimSpace(output))
+	}
+	return env, nil
+}
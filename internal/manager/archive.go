@@ -0,0 +1,216 @@
+package manager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// MaxWorkspaceArchiveBytes limits the compressed size of a workspace archive, which
+// travels base64-encoded inside a single MCP message
+const MaxWorkspaceArchiveBytes = 100 << 20
+
+// WorkspaceArchiveInfo describes a workspace archive
+type WorkspaceArchiveInfo struct {
+	EnvID string `json:"env_id"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"` // uncompressed size of the files
+}
+
+// ArchiveWorkspace packs an environment's workspace into a gzip-compressed tar archive.
+// Symbolic links are stored as links; other special files are skipped. Mounts are
+// left out, since their host paths belong to this server.
+func (m *Manager) ArchiveWorkspace(ctx context.Context, envID string) ([]byte, *WorkspaceArchiveInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ws, err := m.GetWorkspace(envID)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	m.mu.RLock()
+	mountPoints := make(map[string]bool, len(env.mounts))
+	for _, mt := range env.mounts {
+		mountPoints[mt.target] = true
+	}
+	m.mu.RUnlock()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	info := &WorkspaceArchiveInfo{EnvID: envID}
+
+	err = filepath.WalkDir(ws.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return checkCancelled(ctx)
+		}
+		rel, err := filepath.Rel(ws.Path, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if mountPoints[path] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		switch {
+		case fi.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !fi.Mode().IsRegular() && !fi.IsDir():
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(tw, f); err != nil {
+			return err
+		}
+		info.Files++
+		info.Bytes += fi.Size()
+		if buf.Len() > MaxWorkspaceArchiveBytes {
+			return fmt.Errorf("workspace archive exceeds %d MB", MaxWorkspaceArchiveBytes>>20)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to archive workspace: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to archive workspace: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to archive workspace: %w", err)
+	}
+	return buf.Bytes(), info, nil
+}
+
+// ExtractWorkspace unpacks an archive made by ArchiveWorkspace into an environment's
+// workspace, creating the workspace if needed. Existing files are overwritten, except
+// in read-only mounts and mount points, which fail the extraction.
+func (m *Manager) ExtractWorkspace(ctx context.Context, envID string, data []byte) (*WorkspaceArchiveInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := m.CreateWorkspace(envID)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lockEnvironment(ctx, env, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	info := &WorkspaceArchiveInfo{EnvID: envID}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace archive: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, checkCancelled(ctx)
+		}
+		target, err := safeJoinPath(ws.Path, filepath.FromSlash(hdr.Name))
+		if err != nil {
+			return nil, err
+		}
+		if err := m.checkMountWritable(env, target); err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := m.checkNoMounts(env, target); err != nil {
+				return nil, err
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory: %w", err)
+			}
+		case tar.TypeSymlink:
+			// Links may only point inside the workspace
+			resolved := hdr.Linkname
+			if !filepath.IsAbs(resolved) {
+				resolved = filepath.Join(filepath.Dir(target), resolved)
+			}
+			if !isSubPath(ws.Path, resolved) {
+				return nil, fmt.Errorf("link %s points outside the workspace", hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory: %w", err)
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return nil, fmt.Errorf("failed to create link: %w", err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory: %w", err)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm()|0600)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write file: %w", err)
+			}
+			n, err := io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to write file: %w", err)
+			}
+			info.Files++
+			info.Bytes += n
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// artifactsEnv names the directory where run_code and run_script executions save
+// files for the client, such as plots
+const artifactsEnv = "JUMPBOOT_ARTIFACTS"
+
+// artifactsDir is the workspace directory holding one subdirectory per execution
+const artifactsDir = "artifacts"
+
+// WorkspaceURIPrefix starts the URI of every workspace file resource
+const WorkspaceURIPrefix = "jumpboot://workspace/"
+
+// Artifact is a file an execution saved in its artifacts directory
+type Artifact struct {
+	Path     string `json:"path"` // relative to the workspace
+	Size     int64  `json:"size"`
+	MIMEType string `json:"mime_type"`
+	URI      string `json:"uri"` // workspace resource of the file
+}
+
+// newArtifactsDir creates the artifacts directory of one execution in the
+// environment's workspace, creating the workspace if needed. Locked environments get
+// none, since their workspace is read-only; dir is then empty.
+func (m *Manager) newArtifactsDir(env *ManagedEnvironment) (dir string, err error) {
+	if env.checkWritable() != nil {
+		return "", nil
+	}
+	workspace, err := m.CreateWorkspace(env.ID)
+	if err != nil {
+		return "", err
+	}
+	// Names sort by start time
+	name := time.Now().UTC().Format("20060102-150405") + "-" + uuid.New().String()[:8]
+	dir = filepath.Join(workspace.Path, artifactsDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// collectArtifacts lists the files in an execution's artifacts directory, sorted by
+// path, and removes the directory if the execution saved nothing
+func collectArtifacts(env *ManagedEnvironment, dir string) []Artifact {
+	if dir == "" {
+		return nil
+	}
+	var artifacts []Artifact
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel := workspaceRelative(env.WorkspaceDir, path)
+		artifacts = append(artifacts, Artifact{
+			Path:     rel,
+			Size:     info.Size(),
+			MIMEType: detectMIMEType(path),
+			URI:      WorkspaceURIPrefix + env.ID + "/" + (&url.URL{Path: rel}).EscapedPath(),
+		})
+		return nil
+	})
+	if len(artifacts) == 0 {
+		os.RemoveAll(dir)
+		return nil
+	}
+	slices.SortFunc(artifacts, func(a, b Artifact) int { return strings.Compare(a.Path, b.Path) })
+	return artifacts
+}
+
+// detectMIMEType returns the MIME type of a file from its extension, or else from its
+// first bytes
+func detectMIMEType(path string) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
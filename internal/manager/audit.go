@@ -0,0 +1,234 @@
+package manager
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit query limits
+const (
+	DefaultAuditQueryLimit = 100
+	MaxAuditQueryLimit     = 1000
+)
+
+// auditArgMaxLen is the longest argument value recorded; longer values such as code
+// are truncated
+const auditArgMaxLen = 256
+
+// auditSensitiveArgs are arguments whose values are never recorded
+var auditSensitiveArgs = map[string]bool{"value": true, "token": true, "webhook_secret": true}
+
+// Audit outcomes
+const (
+	AuditOK    = "ok"
+	AuditError = "error"
+)
+
+// AuditEntry is a line of the audit log
+type AuditEntry struct {
+	Time       time.Time      `json:"time"`
+	Session    string         `json:"session,omitempty"`
+	Admin      bool           `json:"admin,omitempty"`
+	Principal  string         `json:"principal,omitempty"` // role token name, with -roles
+	Role       string         `json:"role,omitempty"`
+	Tool       string         `json:"tool"`
+	Args       map[string]any `json:"args,omitempty"`
+	Outcome    string         `json:"outcome"` // AuditOK or AuditError
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+// AuditQuery selects audit entries. Empty fields match everything.
+type AuditQuery struct {
+	Tool     string
+	Session  string
+	EnvID    string // the call's env_id argument
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+	Contains string // substring of the recorded JSON line
+	Limit    int    // most recent matches returned (0 = DefaultAuditQueryLimit)
+}
+
+// AuditQueryResult holds the matching entries, oldest first
+type AuditQueryResult struct {
+	Entries   []AuditEntry `json:"entries"`
+	Truncated bool         `json:"truncated,omitempty"` // older matches were left out
+}
+
+// AuditLog is an append-only JSONL file of mutating tool calls
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenAuditLog opens path for appending, creating it and its directory
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLog{path: path, file: f}, nil
+}
+
+// Close closes the audit log file
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// write appends an entry as one line
+func (l *AuditLog) write(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(data, '\n'))
+	return err
+}
+
+// query scans the log for entries matching q
+func (l *AuditLog) query(q AuditQuery) (*AuditQueryResult, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultAuditQueryLimit
+	}
+	limit = min(limit, MaxAuditQueryLimit)
+
+	result := &AuditQueryResult{Entries: []AuditEntry{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if q.Contains != "" && !strings.Contains(line, q.Contains) {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		if !q.matches(entry) {
+			continue
+		}
+		if len(result.Entries) == limit {
+			result.Entries = result.Entries[1:]
+			result.Truncated = true
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return result, nil
+}
+
+// matches reports whether an entry passes the query's field filters
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	switch {
+	case q.Tool != "" && entry.Tool != q.Tool,
+		q.Session != "" && entry.Session != q.Session,
+		q.Outcome != "" && entry.Outcome != q.Outcome,
+		!q.Since.IsZero() && entry.Time.Before(q.Since),
+		!q.Until.IsZero() && entry.Time.After(q.Until):
+		return false
+	}
+	if q.EnvID != "" {
+		if id, _ := entry.Args["env_id"].(string); id != q.EnvID {
+			return false
+		}
+	}
+	return true
+}
+
+// SetAuditLog makes the Manager record tool calls in l (nil disables auditing)
+func (m *Manager) SetAuditLog(l *AuditLog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.auditLog = l
+}
+
+// RecordAudit appends a tool call to the audit log, if one is configured. Arguments
+// are summarized: sensitive ones are masked, long values truncated and secret values
+// redacted.
+func (m *Manager) RecordAudit(entry AuditEntry) {
+	m.mu.RLock()
+	l := m.auditLog
+	m.mu.RUnlock()
+	if l == nil {
+		return
+	}
+
+	args := make(map[string]any, len(entry.Args))
+	for key, value := range entry.Args {
+		args[key] = m.auditArg(key, value)
+	}
+	entry.Args = args
+	entry.Error = m.RedactSecrets(entry.Error)
+	if err := l.write(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write audit log: %v\n", err)
+	}
+}
+
+// auditArg returns the recorded form of an argument value
+func (m *Manager) auditArg(key string, value any) any {
+	if auditSensitiveArgs[key] {
+		return "[REDACTED]"
+	}
+	switch v := value.(type) {
+	case string:
+		return truncateAuditValue(m.RedactSecrets(v))
+	case bool, float64, nil:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	redacted := m.RedactSecrets(string(data))
+	if len(redacted) > auditArgMaxLen || redacted != string(data) {
+		return truncateAuditValue(redacted)
+	}
+	return value
+}
+
+// truncateAuditValue shortens s to auditArgMaxLen bytes, noting the original length
+func truncateAuditValue(s string) string {
+	if len(s) <= auditArgMaxLen {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d bytes)", strings.ToValidUTF8(s[:auditArgMaxLen], ""), len(s))
+}
+
+// QueryAudit searches the audit log. Requires the admin token.
+func (m *Manager) QueryAudit(ctx context.Context, q AuditQuery) (*AuditQueryResult, error) {
+	if err := m.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	l := m.auditLog
+	m.mu.RUnlock()
+	if l == nil {
+		return nil, fmt.Errorf("audit logging is not enabled (start the server with -audit-log)")
+	}
+	return l.query(q)
+}
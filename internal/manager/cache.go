@@ -0,0 +1,278 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bounds of the execution cache; the least recently used results are dropped first
+const (
+	maxExecCacheEntries = 256
+	maxExecCacheBytes   = 64 << 20
+	maxCacheLabelLen    = 80
+)
+
+// Kinds of cached executions
+const (
+	CacheKindCode   = "code"
+	CacheKindScript = "script"
+)
+
+// execCacheEntry is a memoized run_code or run_script result
+type execCacheEntry struct {
+	key     string
+	envID   string
+	kind    string
+	label   string // first line of the code, or the script path
+	code    *CodeResult
+	script  *ScriptResult
+	size    int
+	created time.Time
+	lastHit time.Time
+	hits    int
+}
+
+// ExecCacheEntry describes a cached execution result
+type ExecCacheEntry struct {
+	Key       string     `json:"key"`
+	EnvID     string     `json:"env_id"`
+	Kind      string     `json:"kind"`
+	Label     string     `json:"label"`
+	Size      int        `json:"size"` // bytes of output and result JSON
+	Hits      int        `json:"hits"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastHitAt *time.Time `json:"last_hit_at,omitempty"`
+}
+
+// ExecCacheInfo is the state of the execution cache, as seen by one caller
+type ExecCacheInfo struct {
+	Entries []ExecCacheEntry `json:"entries"`
+	Bytes   int              `json:"bytes"`
+	Hits    int64            `json:"hits"`   // server-wide since start
+	Misses  int64            `json:"misses"` // server-wide since start
+	Cleared int              `json:"cleared,omitempty"`
+}
+
+// execCacheKey hashes what determines the outcome of a run: the environment's
+// interpreter, installed distributions, variables and shared workspace, and the parts
+// of the run. It is computed while the environment is locked, so nothing is installed
+// meanwhile.
+func (m *Manager) execCacheKey(ctx context.Context, env *ManagedEnvironment, kind string, parts ...string) (string, error) {
+	state, err := envStateHash(ctx, env)
+	if err != nil {
+		return "", err
+	}
+	// The workspace is created on first use, so only attaching a shared one counts
+	m.mu.RLock()
+	shared := env.sharedWorkspace
+	m.mu.RUnlock()
+
+	h := sha256.New()
+	for _, part := range append([]string{kind, env.ID, env.Env.PythonPath, shared, state}, env.appendVars(nil)...) {
+		fmt.Fprintf(h, "%d:%s\n", len(part), part)
+	}
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:%s\n", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
+
+// envStateHash returns a hash of the distributions installed in an environment, like
+// a hash of pip freeze. The names of the site-packages metadata directories, which
+// carry the versions, are read instead of running pip, which takes seconds.
+func envStateHash(ctx context.Context, env *ManagedEnvironment) (string, error) {
+	var dirs []string
+	if env.Env.SitePackagesPath != "" {
+		dirs = append(dirs, env.Env.SitePackagesPath)
+	} else {
+		for _, pattern := range []string{"lib/python*/site-packages", "Lib/site-packages"} {
+			matches, _ := filepath.Glob(filepath.Join(env.RootDir, pattern))
+			dirs = append(dirs, matches...)
+		}
+	}
+
+	h := sha256.New()
+	if len(dirs) == 0 {
+		// An unusual layout: ask pip
+		output, err := commandContext(ctx, env.Env.PythonPath, "-m", "pip", "freeze").Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to list installed packages: %w", err)
+		}
+		h.Write(output)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to list installed packages: %w", err)
+		}
+		fmt.Fprintln(h, dir)
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasSuffix(name, ".dist-info") || strings.HasSuffix(name, ".egg-info") || strings.HasSuffix(name, ".pth") || strings.HasSuffix(name, ".egg-link") {
+				fmt.Fprintln(h, name)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedCode returns a memoized run_code result, marked as cached
+func (m *Manager) cachedCode(key string) *CodeResult {
+	entry := m.execCacheHit(key)
+	if entry == nil || entry.code == nil {
+		return nil
+	}
+	result := *entry.code
+	result.Cached = true
+	return &result
+}
+
+// cachedScript returns a memoized run_script result, marked as cached
+func (m *Manager) cachedScript(key string) *ScriptResult {
+	entry := m.execCacheHit(key)
+	if entry == nil || entry.script == nil {
+		return nil
+	}
+	result := *entry.script
+	result.Cached = true
+	return &result
+}
+
+// execCacheHit looks up a cache entry and counts the hit or miss
+func (m *Manager) execCacheHit(key string) *execCacheEntry {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	entry, ok := m.execCache[key]
+	if !ok {
+		m.cacheMisses++
+		return nil
+	}
+	m.cacheHits++
+	entry.hits++
+	entry.lastHit = time.Now().UTC()
+	return entry
+}
+
+// storeExecCache memoizes a result, dropping the least recently used entries beyond
+// the cache's bounds. Results larger than the whole cache are not kept.
+func (m *Manager) storeExecCache(entry *execCacheEntry) {
+	if entry.size > maxExecCacheBytes {
+		return
+	}
+	entry.created = time.Now().UTC()
+	if i := strings.IndexByte(entry.label, '\n'); i >= 0 {
+		entry.label = entry.label[:i]
+	}
+	if len(entry.label) > maxCacheLabelLen {
+		entry.label = strings.ToValidUTF8(entry.label[:maxCacheLabelLen], "") + "..."
+	}
+
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	if old, ok := m.execCache[entry.key]; ok {
+		m.cacheBytes -= old.size
+	}
+	m.execCache[entry.key] = entry
+	m.cacheBytes += entry.size
+
+	for len(m.execCache) > maxExecCacheEntries || m.cacheBytes > maxExecCacheBytes {
+		var oldest *execCacheEntry
+		for _, e := range m.execCache {
+			if oldest == nil || e.lastUsed().Before(oldest.lastUsed()) {
+				oldest = e
+			}
+		}
+		delete(m.execCache, oldest.key)
+		m.cacheBytes -= oldest.size
+	}
+}
+
+// lastUsed is when the entry was stored or last returned
+func (e *execCacheEntry) lastUsed() time.Time {
+	if e.lastHit.After(e.created) {
+		return e.lastHit
+	}
+	return e.created
+}
+
+// dropExecCache removes the cached results of an environment. Callers must hold m.mu.
+func (m *Manager) dropExecCache(envID string) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	for key, entry := range m.execCache {
+		if entry.envID == envID {
+			delete(m.execCache, key)
+			m.cacheBytes -= entry.size
+		}
+	}
+}
+
+// ExecCache lists the cached execution results of the environments visible to the
+// caller in ctx, or of envID only, most recently used first. With clear, the listed
+// entries are removed, or only the one with key if set.
+func (m *Manager) ExecCache(ctx context.Context, envID, key string, clear bool) (*ExecCacheInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if envID != "" {
+		if _, ok := m.environments[envID]; !ok {
+			return nil, notFound("environment", envID)
+		}
+	}
+
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	info := &ExecCacheInfo{Entries: []ExecCacheEntry{}, Hits: m.cacheHits, Misses: m.cacheMisses}
+	for _, entry := range m.execCache {
+		env, ok := m.environments[entry.envID]
+		if !ok || !m.canAccess(ctx, env.Owner) || (envID != "" && entry.envID != envID) {
+			continue
+		}
+		if key != "" && entry.key != key {
+			continue
+		}
+		if clear {
+			delete(m.execCache, entry.key)
+			m.cacheBytes -= entry.size
+			info.Cleared++
+			continue
+		}
+		listed := ExecCacheEntry{
+			Key:       entry.key,
+			EnvID:     entry.envID,
+			Kind:      entry.kind,
+			Label:     entry.label,
+			Size:      entry.size,
+			Hits:      entry.hits,
+			CreatedAt: entry.created,
+		}
+		if entry.hits > 0 {
+			lastHit := entry.lastHit
+			listed.LastHitAt = &lastHit
+		}
+		info.Entries = append(info.Entries, listed)
+		info.Bytes += entry.size
+	}
+	if key != "" && info.Cleared == 0 && len(info.Entries) == 0 {
+		return nil, notFound("cache entry", key)
+	}
+	sort.Slice(info.Entries, func(i, j int) bool {
+		return lastListed(info.Entries[i]).After(lastListed(info.Entries[j]))
+	})
+	return info, nil
+}
+
+// lastListed is when a listed entry was stored or last returned
+func lastListed(e ExecCacheEntry) time.Time {
+	if e.LastHitAt != nil {
+		return *e.LastHitAt
+	}
+	return e.CreatedAt
+}
@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrCancelled is returned when an operation is aborted because its context was cancelled
+var ErrCancelled = errors.New("operation cancelled")
+
+// cancelWaitDelay bounds how long a cancelled command may keep its output pipes open
+const cancelWaitDelay = 5 * time.Second
+
+// replInterruptGrace is how long an interrupted REPL execution may take to unwind
+// before the interpreter is terminated
+const replInterruptGrace = 5 * time.Second
+
+// runCommand runs cmd and returns its combined output. The command is killed if ctx
+// is cancelled, in which case the returned error wraps ErrCancelled.
+func runCommand(ctx context.Context, cmd *exec.Cmd) (string, error) {
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return string(output), fmt.Errorf("%w: %w", ErrCancelled, ctx.Err())
+	}
+	return string(output), err
+}
+
+// commandContext is exec.CommandContext with a bounded wait after cancellation
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = cancelWaitDelay
+	return cmd
+}
+
+// runPython runs the environment's interpreter with args and returns its combined output
+func runPython(ctx context.Context, env *ManagedEnvironment, args ...string) (string, error) {
+	return runCommand(ctx, commandContext(ctx, env.Env.PythonPath, args...))
+}
+
+// decodeMarkedJSON decodes the JSON that a helper script printed on a line after
+// marker, ignoring any other output. Without the marker the output is the error.
+func decodeMarkedJSON(output, marker string, out any) error {
+	idx := strings.LastIndex(output, marker)
+	if idx < 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(output))
+	}
+	data := output[idx+len(marker):]
+	if nl := strings.IndexByte(data, '\n'); nl >= 0 {
+		data = data[:nl]
+	}
+	return json.Unmarshal([]byte(data), out)
+}
+
+// checkCancelled returns an error wrapping ErrCancelled if ctx is done
+func checkCancelled(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCancelled, err)
+	}
+	return nil
+}
+
+// executeREPL runs code in a REPL session. If ctx is cancelled the running code is
+// interrupted (KeyboardInterrupt), keeping the session's state; if the interpreter does
+// not respond within replInterruptGrace it is terminated and the session is removed.
+func (m *Manager) executeREPL(ctx context.Context, repl *ManagedREPL, code string) (string, error) {
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := repl.REPL.Execute(code, true)
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+	}
+
+	proc := repl.REPL.PythonProcess
+	if proc.Cmd != nil && proc.Cmd.Process != nil && proc.Cmd.Process.Signal(os.Interrupt) == nil {
+		select {
+		case <-done:
+			return "", fmt.Errorf("%w: execution interrupted, session state kept", ErrCancelled)
+		case <-time.After(replInterruptGrace):
+		}
+	}
+
+	// The interpreter did not unwind; terminate it and drop the session
+	proc.Terminate()
+	<-done
+	m.DestroyREPL(repl.ID)
+	return "", fmt.Errorf("%w: REPL session %s terminated", ErrCancelled, repl.ID)
+}
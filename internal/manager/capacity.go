@@ -0,0 +1,223 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// Reasons a new environment is refused
+const (
+	CapacityEnvironmentLimit = "environment_limit"
+	CapacityDiskSpace        = "disk_space"
+)
+
+// maxCleanupCandidates bounds the environments suggested for cleanup in a CapacityError
+const maxCleanupCandidates = 5
+
+// CapacityInfo describes how much room the server has for new environments
+type CapacityInfo struct {
+	Available        bool   `json:"available"`
+	Environments     int    `json:"environments"`
+	MaxEnvironments  int    `json:"max_environments,omitempty"` // 0 = unlimited
+	FreeDiskBytes    uint64 `json:"free_disk_bytes,omitempty"`
+	MinFreeDiskBytes uint64 `json:"min_free_disk_bytes,omitempty"`
+}
+
+// CleanupCandidate is an existing environment that could be destroyed to make room
+type CleanupCandidate struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	SizeBytes     int64  `json:"size_bytes"`
+	Processes     int    `json:"processes,omitempty"` // running spawned processes
+	REPLs         int    `json:"repls,omitempty"`
+	Terminals     int    `json:"terminals,omitempty"`
+	DebugSessions int    `json:"debug_sessions,omitempty"`
+	Idle          bool   `json:"idle"` // nothing is running in the environment
+
+	root string // environment directory, measured for SizeBytes
+}
+
+// ServerCapacity is the capacity reported by a federated server
+type ServerCapacity struct {
+	Server string `json:"server"`
+	CapacityInfo
+}
+
+// CapacityError is returned when a new environment would exceed a server limit.
+// It carries hints that let a client free room or go elsewhere instead of retrying.
+type CapacityError struct {
+	Reason     string             `json:"reason"`
+	Capacity   *CapacityInfo      `json:"capacity"`
+	Candidates []CleanupCandidate `json:"cleanup_candidates,omitempty"`
+	Servers    []ServerCapacity   `json:"servers_with_capacity,omitempty"`
+}
+
+func (e *CapacityError) Error() string {
+	switch e.Reason {
+	case CapacityEnvironmentLimit:
+		return fmt.Sprintf("environment limit reached (%d of %d); destroy an environment or use another server",
+			e.Capacity.Environments, e.Capacity.MaxEnvironments)
+	case CapacityDiskSpace:
+		return fmt.Sprintf("insufficient disk space (%d MB free, %d MB required); destroy an environment or use another server",
+			e.Capacity.FreeDiskBytes>>20, e.Capacity.MinFreeDiskBytes>>20)
+	default:
+		return "server capacity exceeded"
+	}
+}
+
+// Details returns the structured hints included in error responses
+func (e *CapacityError) Details() any {
+	return e
+}
+
+// ErrorCode classifies capacity refusals as CodeQuotaExceeded
+func (e *CapacityError) ErrorCode() string {
+	return CodeQuotaExceeded
+}
+
+// SetCapacityLimits sets the maximum number of environments (0 = unlimited) and the
+// free disk space that must remain for a new environment to be created (0 = no check)
+func (m *Manager) SetCapacityLimits(maxEnvironments int, minFreeDisk uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxEnvironments = maxEnvironments
+	m.minFreeDisk = minFreeDisk
+}
+
+// Capacity reports how much room the server has for new environments
+func (m *Manager) Capacity() *CapacityInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info, _ := m.capacity()
+	return info
+}
+
+// capacity computes the current capacity and, when no environment can be created,
+// the reason. Callers must hold m.mu.
+func (m *Manager) capacity() (*CapacityInfo, string) {
+	info := &CapacityInfo{
+		Available:        true,
+		Environments:     len(m.environments) + m.pendingEnvs,
+		MaxEnvironments:  m.maxEnvironments,
+		MinFreeDiskBytes: m.minFreeDisk,
+	}
+	if free, err := diskFree(m.baseDir); err == nil {
+		info.FreeDiskBytes = free
+	}
+
+	reason := ""
+	switch {
+	case m.maxEnvironments > 0 && info.Environments >= m.maxEnvironments:
+		reason = CapacityEnvironmentLimit
+	case m.minFreeDisk > 0 && info.FreeDiskBytes < m.minFreeDisk:
+		reason = CapacityDiskSpace
+	}
+	info.Available = reason == ""
+	return info, reason
+}
+
+// reserveEnvironment claims room for a new environment, returning a *ThrottleError
+// when the caller's session limit or a *CapacityError when a server limit would be
+// exceeded. release must be called once the environment has
+// been stored or its creation has failed.
+func (m *Manager) reserveEnvironment(ctx context.Context) (release func(), err error) {
+	m.mu.Lock()
+	if err := m.checkSessionEnvironments(ctx); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	info, reason := m.capacity()
+	if reason != "" {
+		candidates := m.cleanupCandidates(ctx)
+		m.mu.Unlock()
+		return nil, &CapacityError{Reason: reason, Capacity: info, Candidates: rankCandidates(candidates)}
+	}
+	owner := ownerFor(ctx)
+	m.pendingEnvs++
+	m.pendingOwners[owner]++
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		m.pendingEnvs--
+		if m.pendingOwners[owner]--; m.pendingOwners[owner] <= 0 {
+			delete(m.pendingOwners, owner)
+		}
+		m.mu.Unlock()
+	}, nil
+}
+
+// cleanupCandidates lists the caller's environments with what is running in them.
+// Callers must hold m.mu.
+func (m *Manager) cleanupCandidates(ctx context.Context) []CleanupCandidate {
+	var candidates []CleanupCandidate
+	for _, env := range m.environments {
+		if !m.canAccess(ctx, env.Owner) {
+			continue
+		}
+		c := CleanupCandidate{ID: env.ID, Name: env.Name, root: env.RootDir}
+		for _, proc := range m.spawnedProcesses {
+			if proc.EnvID == env.ID && proc.info().Running {
+				c.Processes++
+			}
+		}
+		for _, repl := range m.replSessions {
+			if repl.EnvID == env.ID {
+				c.REPLs++
+			}
+		}
+		for _, term := range m.terminals {
+			if term.EnvID == env.ID {
+				c.Terminals++
+			}
+		}
+		for _, session := range m.debugSessions {
+			if session.EnvID == env.ID {
+				c.DebugSessions++
+			}
+		}
+		c.Idle = c.Processes == 0 && c.REPLs == 0 && c.Terminals == 0 && c.DebugSessions == 0
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// rankCandidates measures the candidates and orders them by how good they are to
+// destroy: idle environments first, then the largest. It walks the environment
+// directories, so it must not be called with m.mu held.
+func rankCandidates(candidates []CleanupCandidate) []CleanupCandidate {
+	for i := range candidates {
+		candidates[i].SizeBytes = dirSize(candidates[i].root)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Idle != candidates[j].Idle {
+			return candidates[i].Idle
+		}
+		return candidates[i].SizeBytes > candidates[j].SizeBytes
+	})
+	if len(candidates) > maxCleanupCandidates {
+		candidates = candidates[:maxCleanupCandidates]
+	}
+	return candidates
+}
+
+// dirSize returns the total size of the regular files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
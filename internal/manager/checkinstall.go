@@ -0,0 +1,203 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// installCheckMarker prefixes the JSON printed by installCheckScript
+const installCheckMarker = "__JUMPBOOT_INSTALL_CHECK__"
+
+// installCheckScript reads the report of "pip install --dry-run --report" and
+// compares the installed distributions with the ones they would be after the
+// install, listing the changes and every dependency requirement that is broken
+// before and after. Argument: the report file.
+const installCheckScript = `
+import json, sys
+from importlib import metadata
+try:
+    from packaging.requirements import Requirement
+    from packaging.utils import canonicalize_name
+except ImportError:
+    from pip._vendor.packaging.requirements import Requirement
+    from pip._vendor.packaging.utils import canonicalize_name
+
+with open(sys.argv[1]) as f:
+    report = json.load(f)
+
+before = {}
+for dist in metadata.distributions():
+    name = dist.metadata['Name']
+    if name:
+        before[canonicalize_name(name)] = (name, dist.version, dist.requires or [])
+after = dict(before)
+changes = []
+for item in report.get('install', []):
+    md = item['metadata']
+    key = canonicalize_name(md['name'])
+    changes.append({'name': md['name'], 'version': md['version'],
+                    'installed_version': before[key][1] if key in before else '',
+                    'requested': bool(item.get('requested'))})
+    after[key] = (md['name'], md['version'], md.get('requires_dist') or [])
+
+def broken(state):
+    out = []
+    for name, version, requires in state.values():
+        for line in requires:
+            try:
+                req = Requirement(line)
+                if req.marker is not None and not req.marker.evaluate({'extra': ''}):
+                    continue
+            except Exception:
+                continue
+            dep = state.get(canonicalize_name(req.name))
+            if dep is None or not req.specifier.contains(dep[1], prereleases=True):
+                out.append({'package': name, 'version': version, 'requires': str(req),
+                            'installed_version': dep[1] if dep else ''})
+    return out
+
+existing = broken(before)
+seen = {(c['package'], c['requires'], c['installed_version']) for c in existing}
+conflicts = [c for c in broken(after) if (c['package'], c['requires'], c['installed_version']) not in seen]
+print(%q + json.dumps({'changes': changes, 'conflicts': conflicts, 'existing_conflicts': existing}))
+`
+
+// Actions of an InstallChange
+const (
+	ChangeInstall   = "install"
+	ChangeUpgrade   = "upgrade"
+	ChangeDowngrade = "downgrade"
+	ChangeReinstall = "reinstall"
+)
+
+// InstallChange is a distribution an install would add or replace
+type InstallChange struct {
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	InstalledVersion string `json:"installed_version,omitempty"`
+	Action           string `json:"action"`
+	Requested        bool   `json:"requested"` // named in the install, not pulled in as a dependency
+}
+
+// InstallConflict is a dependency requirement an installed distribution would no
+// longer meet. InstalledVersion is the version it would find, "" if missing.
+type InstallConflict struct {
+	Package          string `json:"package"`
+	Version          string `json:"version"`
+	Requires         string `json:"requires"`
+	InstalledVersion string `json:"installed_version"`
+}
+
+// InstallCheckResult is what CheckInstall found an install would do
+type InstallCheckResult struct {
+	Resolvable        bool              `json:"resolvable"`
+	Safe              bool              `json:"safe"` // resolvable without new conflicts
+	Changes           []InstallChange   `json:"changes"`
+	Conflicts         []InstallConflict `json:"conflicts"`          // broken by the install
+	ExistingConflicts []InstallConflict `json:"existing_conflicts"` // broken already
+	Error             string            `json:"error,omitempty"`    // why pip could not resolve
+}
+
+// CheckInstall runs the pip resolver for packages without installing anything and
+// reports the distributions that would be installed, upgraded or downgraded, and the
+// requirements of installed packages the result would break. pip itself only checks
+// the packages it resolves, so an install can silently break others; those are the
+// Conflicts. A request pip cannot resolve is reported with Resolvable false and pip's
+// explanation rather than as an error. indexName selects a private index as for
+// InstallPackagesFromIndex.
+func (m *Manager) CheckInstall(ctx context.Context, envID string, packages []string, indexName string) (*InstallCheckResult, error) {
+	if len(packages) == 0 {
+		return nil, WithErrorCode(CodeInvalidArgument, errors.New("at least one package is required"))
+	}
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	packages, err = resolvePackageArgs(env, packages)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	tmpDir, err := os.MkdirTemp("", "check-install-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	reportPath := filepath.Join(tmpDir, "report.json")
+
+	args := []string{"-m", "pip", "install", "--dry-run", "--quiet", "--disable-pip-version-check", "--report", reportPath}
+	var environ []string
+	if indexName != "" {
+		index, err := m.packageIndex(indexName, IndexPip)
+		if err != nil {
+			return nil, err
+		}
+		indexArgs, indexEnv, cleanup, err := pipIndexConfig(env, index)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		args, environ = append(args, indexArgs...), indexEnv
+	}
+	cmd := commandContext(ctx, env.Env.PythonPath, append(args, packages...)...)
+	if len(environ) > 0 {
+		cmd.Env = append(os.Environ(), environ...)
+	}
+	output, err := runCommand(ctx, cmd)
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	if err != nil {
+		if strings.Contains(output, "no such option: --report") || strings.Contains(output, "no such option: --dry-run") {
+			return nil, WithErrorCode(CodeExecFailed, errors.New("checking an install needs pip 22.2 or newer; install \"pip>=22.2\" first"))
+		}
+		// Retries and other warnings bury pip's explanation
+		var kept []string
+		for _, line := range splitLines(output) {
+			if !strings.HasPrefix(line, "WARNING:") {
+				kept = append(kept, line)
+			}
+		}
+		errText, _ := tailLines(strings.TrimSpace(strings.Join(kept, "\n")), 20)
+		return &InstallCheckResult{
+			Changes:           []InstallChange{},
+			Conflicts:         []InstallConflict{},
+			ExistingConflicts: []InstallConflict{},
+			Error:             errText,
+		}, nil
+	}
+
+	output, err = runPython(ctx, env, "-c", fmt.Sprintf(installCheckScript, installCheckMarker), reportPath)
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	result := &InstallCheckResult{Resolvable: true}
+	if err := decodeMarkedJSON(output, installCheckMarker, result); err != nil {
+		return nil, fmt.Errorf("failed to check installed packages: %w", err)
+	}
+	for i := range result.Changes {
+		c := &result.Changes[i]
+		switch cmp := CompareVersions(c.Version, c.InstalledVersion); {
+		case c.InstalledVersion == "":
+			c.Action = ChangeInstall
+		case cmp > 0:
+			c.Action = ChangeUpgrade
+		case cmp < 0:
+			c.Action = ChangeDowngrade
+		default:
+			c.Action = ChangeReinstall
+		}
+	}
+	result.Safe = len(result.Conflicts) == 0
+	return result, nil
+}
@@ -0,0 +1,215 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/richinsley/jumpboot"
+)
+
+// cloneSkip are the entries of an environment's directory that are not part of its
+// venv and are left out of a clone
+var cloneSkip = []string{"workspace", processOutputDir, trashDirName}
+
+// cloneRewriteLimit is the size above which files are never checked for paths to
+// rewrite
+const cloneRewriteLimit = 1 << 20
+
+// CloneInfo describes how an environment created from a template was copied
+type CloneInfo struct {
+	Template   string `json:"template"`             // environment the packages were cloned from
+	Reflinked  int    `json:"reflinked,omitempty"`  // files shared copy-on-write (reflink or clonefile)
+	Hardlinked int    `json:"hardlinked,omitempty"` // files shared through hard links
+	Copied     int    `json:"copied,omitempty"`     // files copied, including those whose paths were rewritten
+}
+
+// SetCloneHardlinks allows or forbids hard links when cloning a template on a
+// filesystem without copy-on-write clones. Hard-linked files are shared with the
+// template, so only pip's replace-on-write keeps them apart.
+func (m *Manager) SetCloneHardlinks(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cloneHardlinks = enabled
+}
+
+// cloneTemplate copies the venv of the template environment to dir and returns it as a
+// jumpboot environment. The template is locked shared while it is copied, so no
+// install changes it halfway.
+func (m *Manager) cloneTemplate(ctx context.Context, templateID, dir string) (*jumpboot.PythonEnvironment, *CloneInfo, error) {
+	if err := m.CheckEnvironmentAccess(ctx, templateID); err != nil {
+		return nil, nil, err
+	}
+	tmpl, err := m.GetEnvironment(templateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tmpl.AdoptedPath != "" {
+		return nil, nil, fmt.Errorf("environment %s is adopted; only environments created by the server can be templates", templateID)
+	}
+	unlock, err := m.lockEnvironment(ctx, tmpl, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	m.mu.RLock()
+	hardlinks := m.cloneHardlinks
+	m.mu.RUnlock()
+	info, err := cloneTree(ctx, tmpl.RootDir, dir, hardlinks)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("failed to clone environment %s: %w", templateID, err)
+	}
+	info.Template = templateID
+	return rebaseEnvironment(tmpl.Env, tmpl.RootDir, dir), info, nil
+}
+
+// rebaseEnvironment returns a copy of env whose paths under from point under to
+func rebaseEnvironment(env *jumpboot.PythonEnvironment, from, to string) *jumpboot.PythonEnvironment {
+	clone := *env
+	v := reflect.ValueOf(&clone).Elem()
+	var rebase func(v reflect.Value)
+	rebase = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			switch f.Kind() {
+			case reflect.Struct:
+				rebase(f)
+			case reflect.String:
+				if rel, ok := relativeTo(from, f.String()); ok {
+					f.SetString(filepath.Join(to, rel))
+				}
+			}
+		}
+	}
+	rebase(v)
+	clone.EnvironmentName = filepath.Base(to)
+	clone.IsNew = true
+	return &clone
+}
+
+// relativeTo returns path relative to dir if it lies inside dir or is dir
+func relativeTo(dir, path string) (string, bool) {
+	if path == dir {
+		return ".", true
+	}
+	if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		return "", false
+	}
+	return path[len(dir)+1:], true
+}
+
+// cloneTree copies the venv in src to dst file by file: as a copy-on-write clone where
+// the filesystem supports it, otherwise as a hard link if hardlinks is set, otherwise
+// as a plain copy. Scripts in the venv's bin directory and pyvenv.cfg that mention src
+// are rewritten to mention dst, as are symbolic links into src.
+func cloneTree(ctx context.Context, src, dst string, hardlinks bool) (*CloneInfo, error) {
+	info := &CloneInfo{}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return checkCancelled(ctx)
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		top, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+		if path != src && (slices.Contains(cloneSkip, top) || strings.HasPrefix(top, ".netrc-") || strings.HasPrefix(top, ".condarc-")) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, fi.Mode().Perm())
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if r, ok := relativeTo(src, link); ok {
+				link = filepath.Join(dst, r)
+			}
+			return os.Symlink(link, target)
+		case !fi.Mode().IsRegular():
+			return nil
+		}
+
+		if top == "bin" || top == "Scripts" || rel == "pyvenv.cfg" {
+			rewritten, err := rewriteClonedFile(path, target, src, dst, fi)
+			if err != nil {
+				return err
+			}
+			if rewritten {
+				info.Copied++
+				return nil
+			}
+		}
+		switch {
+		case reflinkFile(path, target, fi.Mode().Perm()) == nil:
+			info.Reflinked++
+		case hardlinks && os.Link(path, target) == nil:
+			info.Hardlinked++
+		default:
+			if err := copyFile(path, target, fi.Mode().Perm()); err != nil {
+				return err
+			}
+			info.Copied++
+		}
+		return nil
+	})
+	return info, err
+}
+
+// rewriteClonedFile writes the text file at path to target with src replaced by dst,
+// if it mentions src. Binary and large files are left alone.
+func rewriteClonedFile(path, target, src, dst string, fi fs.FileInfo) (bool, error) {
+	if fi.Size() > cloneRewriteLimit {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !bytes.Contains(data, []byte(src)) {
+		return false, nil
+	}
+	data = bytes.ReplaceAll(data, []byte(src), []byte(dst))
+	return true, os.WriteFile(target, data, fi.Mode().Perm())
+}
+
+// copyFile copies the regular file src to dst with the given permissions
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
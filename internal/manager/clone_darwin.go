@@ -0,0 +1,15 @@
+//go:build darwin
+
+package manager
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkFile creates dst as a copy-on-write clone of src with clonefile, which APFS
+// supports. The clone keeps src's permissions.
+func reflinkFile(src, dst string, perm fs.FileMode) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
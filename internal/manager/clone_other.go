@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package manager
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// reflinkFile is not supported here, so clones fall back to hard links or copies
+func reflinkFile(src, dst string, perm fs.FileMode) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,222 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// ErrCommandNotAllowed is returned when a command is rejected by the command policy
+var ErrCommandNotAllowed = errors.New("command not allowed")
+
+// CommandResult is the outcome of a synchronous command
+type CommandResult struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
+
+// SetCommandPolicy configures which executables run_command, spawn_command and terminal
+// shells may start. Entries are executable base names or glob patterns (e.g. "pytest",
+// "python*"). Deny entries take precedence; an empty allow list allows everything not denied.
+func (m *Manager) SetCommandPolicy(allow, deny []string) error {
+	for _, pattern := range append(append([]string{}, allow...), deny...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid command pattern %q: %w", pattern, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commandAllow = allow
+	m.commandDeny = deny
+	return nil
+}
+
+// checkCommand returns an error if the command policy forbids the executable
+func (m *Manager) checkCommand(command string) error {
+	m.mu.RLock()
+	allow, deny := m.commandAllow, m.commandDeny
+	m.mu.RUnlock()
+
+	name := commandName(command)
+	if matchAny(deny, name) {
+		return fmt.Errorf("%w: %s is denied by the server", ErrCommandNotAllowed, name)
+	}
+	if len(allow) > 0 && !matchAny(allow, name) {
+		return fmt.Errorf("%w: %s is not in the server's allowlist", ErrCommandNotAllowed, name)
+	}
+	return nil
+}
+
+// commandName returns the name the command policy matches: the executable's base name
+// without ".exe", and on Windows also without any other PATHEXT extension and
+// lower-cased, as Windows file names are case-insensitive
+func commandName(command string) string {
+	name := filepath.Base(command)
+	if runtime.GOOS != "windows" {
+		return strings.TrimSuffix(name, ".exe")
+	}
+	name = strings.ToLower(name)
+	if ext := filepath.Ext(name); ext != "" && slices.Contains(executableExts(), ext) {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// executableExts returns the lower-cased extensions Windows runs without being given
+// them, from PATHEXT
+func executableExts() []string {
+	pathext := os.Getenv("PATHEXT")
+	if pathext == "" {
+		return []string{".com", ".exe", ".bat", ".cmd"}
+	}
+	return strings.Split(strings.ToLower(pathext), string(filepath.ListSeparator))
+}
+
+// matchAny reports whether name matches any of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if runtime.GOOS == "windows" {
+			pattern = strings.ToLower(pattern)
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// commandDir returns the working directory for commands: the workspace if one exists,
+// otherwise the environment's root directory
+func commandDir(env *ManagedEnvironment) string {
+	if env.WorkspaceDir != "" {
+		return env.WorkspaceDir
+	}
+	return env.RootDir
+}
+
+// envBinDirs returns the directories holding an environment's executables, in lookup
+// order. On Windows a conda environment keeps them in its root, Library\bin and
+// Scripts rather than in one bin directory.
+func envBinDirs(env *ManagedEnvironment) []string {
+	bin := env.Env.EnvBinPath
+	if bin == "" {
+		return nil
+	}
+	dirs := []string{bin}
+	if runtime.GOOS == "windows" {
+		for _, sub := range []string{`Library\mingw-w64\bin`, `Library\usr\bin`, `Library\bin`, "Scripts"} {
+			if dir := filepath.Join(bin, sub); isDir(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// pathVar returns the PATH assignment that activates an environment: its executable
+// directories ahead of the server's PATH
+func pathVar(env *ManagedEnvironment) string {
+	return "PATH=" + strings.Join(append(envBinDirs(env), os.Getenv("PATH")), string(filepath.ListSeparator))
+}
+
+// resolveCommand finds the executable for a command. Bare names are looked up in the
+// environment's bin directories first, then on the server's PATH; paths are relative
+// to the working directory. On Windows, LookPath tries the PATHEXT extensions.
+func resolveCommand(env *ManagedEnvironment, command string) (string, error) {
+	if strings.ContainsRune(command, '/') || strings.ContainsRune(command, filepath.Separator) {
+		if filepath.IsAbs(command) {
+			return command, nil
+		}
+		return safeJoinPath(commandDir(env), command)
+	}
+
+	for _, dir := range envBinDirs(env) {
+		if path, err := exec.LookPath(filepath.Join(dir, command)); err == nil {
+			return path, nil
+		}
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", WithErrorCode(CodeNotFound, fmt.Errorf("command not found: %s", command))
+	}
+	return path, nil
+}
+
+// environmentCommand builds an exec.Cmd for an arbitrary executable with the
+// environment activated, after checking the command policy
+func (m *Manager) environmentCommand(ctx context.Context, env *ManagedEnvironment, command string, args []string) (*exec.Cmd, error) {
+	if err := m.checkCommand(command); err != nil {
+		return nil, err
+	}
+	path, err := resolveCommand(env, command)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := commandContext(ctx, path, args...)
+	cmd.Dir = commandDir(env)
+	cmd.Env = append(env.appendVars(os.Environ()),
+		pathVar(env),
+		"VIRTUAL_ENV="+env.Env.EnvPath,
+	)
+	return cmd, nil
+}
+
+// RunCommand runs an executable inside the environment and waits for it to finish.
+// A non-zero exit status is reported in the result rather than as an error.
+func (m *Manager) RunCommand(ctx context.Context, envID, command string, args []string) (*CommandResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := m.environmentCommand(ctx, env, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	output, err := m.runIsolated(ctx, env, cmd)
+	result := &CommandResult{Command: command, Output: output}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && !errors.Is(err, ErrCancelled):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, fmt.Errorf("failed to run %s: %w\nOutput: %s", command, err, output)
+	}
+	return result, nil
+}
+
+// SpawnCommand starts an executable inside the environment that runs in the background
+func (m *Manager) SpawnCommand(ctx context.Context, envID, command string, opts SpawnOptions) (*ProcessInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The process outlives the request, so it must not be tied to its context
+	cmd, err := m.environmentCommand(context.WithoutCancel(ctx), env, command, opts.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Name == "" {
+		opts.Name = filepath.Base(command)
+	}
+	return m.startProcess(ctx, envID, cmd, opts)
+}
@@ -0,0 +1,141 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvironmentYMLFile is the workspace file export_environment_yml writes by default
+const DefaultEnvironmentYMLFile = "environment.yml"
+
+// condaPackage is an entry of `micromamba list --json`
+type condaPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Channel string `json:"channel"`
+}
+
+// condaExport is the environment.yml written by ExportEnvironmentYML
+type condaExport struct {
+	Name         string   `yaml:"name,omitempty"`
+	Channels     []string `yaml:"channels"`
+	Dependencies []any    `yaml:"dependencies"`
+}
+
+// CreateEnvironmentFromYML creates an environment from a conda environment.yml. name
+// defaults to the file's name field.
+func (m *Manager) CreateEnvironmentFromYML(ctx context.Context, name, spec, pythonVersion string) (*EnvironmentInfo, error) {
+	if name == "" {
+		var condaEnv condaEnvironment
+		if err := yaml.Unmarshal([]byte(spec), &condaEnv); err != nil {
+			return nil, fmt.Errorf("invalid environment.yml: %w", err)
+		}
+		name = condaEnv.Name
+	}
+	if name == "" {
+		return nil, fmt.Errorf("name is required when the environment.yml does not set one")
+	}
+	return m.RestoreEnvironmentFromSpec(ctx, name, spec, RestoreOptions{
+		Format:        SpecEnvironmentYML,
+		PythonVersion: pythonVersion,
+	})
+}
+
+// ExportEnvironmentYML exports an environment as a conda environment.yml: the Python
+// version, the conda packages installed into the environment and a pip section with
+// the remaining packages pinned to their versions
+func (m *Manager) ExportEnvironmentYML(ctx context.Context, envID string) (string, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return "", err
+	}
+	condaPackages, err := listCondaPackages(ctx, env)
+	if err != nil {
+		return "", err
+	}
+	pipPackages, err := m.ListPackages(envID)
+	if err != nil {
+		return "", err
+	}
+
+	export := condaExport{
+		Name:         env.Name,
+		Dependencies: []any{"python=" + env.PythonVer, "pip"},
+	}
+	fromConda := make(map[string]bool)
+	for _, pkg := range condaPackages {
+		if pkg.Name == "python" || pkg.Name == "pip" {
+			continue
+		}
+		fromConda[NormalizePackageName(pkg.Name)] = true
+		export.Dependencies = append(export.Dependencies, pkg.Name+"="+pkg.Version)
+		if channel := condaChannelName(pkg.Channel); channel != "" && !slices.Contains(export.Channels, channel) {
+			export.Channels = append(export.Channels, channel)
+		}
+	}
+	if len(export.Channels) == 0 {
+		export.Channels = []string{"conda-forge"}
+	}
+
+	var pip []string
+	for _, pkg := range pipPackages {
+		name, ref, direct := strings.Cut(pkg.Name, " @ ")
+		switch {
+		case strings.HasPrefix(pkg.Name, "-"):
+			// Editable installs cannot be restored from a requirement line
+		case fromConda[NormalizePackageName(name)]:
+			// Already listed as a conda package
+		case direct && strings.HasPrefix(ref, "file://"):
+			// Installed from a local file, usually by conda
+		case pkg.Version != "":
+			pip = append(pip, pkg.Name+"=="+pkg.Version)
+		default:
+			pip = append(pip, pkg.Name)
+		}
+	}
+	if len(pip) > 0 {
+		export.Dependencies = append(export.Dependencies, map[string][]string{"pip": pip})
+	}
+
+	var buf strings.Builder
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(export); err != nil {
+		return "", fmt.Errorf("failed to encode environment.yml: %w", err)
+	}
+	enc.Close()
+	return buf.String(), nil
+}
+
+// listCondaPackages returns the conda packages installed into an environment's prefix.
+// An environment without any has no conda metadata, which is not an error.
+func listCondaPackages(ctx context.Context, env *ManagedEnvironment) ([]condaPackage, error) {
+	cmd := commandContext(ctx, env.Env.MicromambaPath, "list", "--no-rc", "--prefix", env.Env.EnvPath, "--json")
+	output, err := cmd.Output()
+	if err != nil {
+		if err := checkCancelled(ctx); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	var packages []condaPackage
+	if err := json.Unmarshal(output, &packages); err != nil {
+		return nil, fmt.Errorf("failed to parse conda package list: %w", err)
+	}
+	return packages, nil
+}
+
+// condaChannelName shortens a channel URL such as
+// "https://conda.anaconda.org/conda-forge" to its name
+func condaChannelName(channel string) string {
+	channel = strings.TrimSuffix(channel, "/")
+	if i := strings.LastIndex(channel, "/"); i >= 0 && strings.Contains(channel, "conda.anaconda.org") {
+		return channel[i+1:]
+	}
+	return channel
+}
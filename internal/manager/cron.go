@@ -0,0 +1,214 @@
+package manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit bounds the search for the next matching time, so that expressions
+// that can never match (e.g. "0 0 30 2 *") fail instead of looping forever
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// cronMacros are the supported shorthand expressions
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cronDayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// cronField is the set of values a field matches, indexed by value
+type cronField []bool
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week, evaluated in the server's local time
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+
+	// Like cron, when both day fields are restricted a day matches if either does
+	domStar, dowStar bool
+
+	// hourStar schedules follow the wall clock across daylight saving changes; others
+	// run once at a time repeated when clocks go back, and just after a skipped time
+	// when they go forward
+	hourStar bool
+}
+
+// parseCron parses a standard five-field cron expression or an @-macro such as
+// @hourly. Fields accept *, values, ranges (a-b), steps (*/n, a-b/n), comma-separated
+// lists and three-letter month and weekday names. Weekday 7 is Sunday, like 0, so a
+// weekday range may end with it (fri-sun).
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields (minute hour day month weekday)", expr)
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron minute: %w", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron hour: %w", err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron day of month: %w", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("invalid cron month: %w", err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7, 6, cronDayNames); err != nil {
+		return nil, fmt.Errorf("invalid cron weekday: %w", err)
+	}
+	if s.dow[7] {
+		s.dow[0] = true
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	s.hourStar = strings.HasPrefix(fields[1], "*")
+	return &s, nil
+}
+
+// parseCronField parses one comma-separated field with values in [min, max]. * and
+// a/n run to last, which is below max when max is an alias of min (weekday 7), and a
+// range ending with min's name ends at that alias. names, if set, are accepted in
+// place of numbers; the first name stands for min.
+func parseCronField(field string, min, max, last int, names []string) (cronField, error) {
+	matches := make(cronField, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, last
+		if rangePart != "*" {
+			loStr, hiStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = cronValue(loStr, min, max, names); err != nil {
+				return nil, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(hiStr, min, max, names); err != nil {
+					return nil, err
+				}
+				if hi == min && hi < lo && last < max {
+					hi = max
+				}
+			} else if hasStep {
+				// "a/n" means from a to the end of the range
+				hi = last
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			matches[v] = true
+		}
+	}
+	return matches, nil
+}
+
+// cronValue parses a number or name within [min, max]
+func cronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", s, min, max)
+	}
+	return n, nil
+}
+
+// dayMatches reports whether t's day satisfies the day-of-month and weekday fields
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// matches reports whether the wall clock time t is on the schedule
+func (s *cronSchedule) matches(t time.Time) bool {
+	return s.month[int(t.Month())] && s.dayMatches(t) && s.hour[t.Hour()] && s.minute[t.Minute()]
+}
+
+// wallClock returns t's local date and time as the same reading in UTC, so that wall
+// clock times compare and step without daylight saving changes
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// skippedMatch reports whether the clocks went forward just before t, past a wall
+// clock time on the schedule
+func (s *cronSchedule) skippedMatch(t time.Time) bool {
+	wall := wallClock(t)
+	for w := wallClock(t.Add(-time.Minute)).Add(time.Minute); w.Before(wall); w = w.Add(time.Minute) {
+		if s.matches(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// cronAdvance returns next, or the minute after t if next is not later. time.Date
+// moves a wall clock time that the clocks skip back by the skipped time, which can
+// land before t.
+func cronAdvance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+// next returns the first matching minute strictly after t, or the zero time if there
+// is none within cronSearchLimit. Unless the hour is *, a wall clock time that the
+// clocks skip runs at the first minute after the change, and one they repeat runs
+// only the first time.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	after := wallClock(t)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		if !s.hourStar && s.skippedMatch(t) {
+			return t
+		}
+		switch {
+		case !s.month[int(t.Month())]:
+			t = cronAdvance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+		case !s.dayMatches(t):
+			t = cronAdvance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+		case !s.hour[t.Hour()]:
+			t = cronAdvance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+		case !s.minute[t.Minute()], !s.hourStar && !wallClock(t).After(after):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
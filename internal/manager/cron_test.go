@@ -0,0 +1,212 @@
+package manager
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// fieldValues lists the values a field matches
+func fieldValues(f cronField) []int {
+	var values []int
+	for v, ok := range f {
+		if ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func TestParseCronFields(t *testing.T) {
+	tests := []struct {
+		expr                          string
+		minute, hour, dom, month, dow []int
+	}{
+		{"0 0 1 1 *", []int{0}, []int{0}, []int{1}, []int{1}, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"*/15 9-17/4 1,15 */6 1-5", []int{0, 15, 30, 45}, []int{9, 13, 17}, []int{1, 15}, []int{1, 7}, []int{1, 2, 3, 4, 5}},
+		{"5/20 22/1 28/2 11/1 1/2", []int{5, 25, 45}, []int{22, 23}, []int{28, 30}, []int{11, 12}, []int{1, 3, 5}},
+		{"0 0 * jan-mar,DEC sat,sun", []int{0}, []int{0}, nil, []int{1, 2, 3, 12}, []int{0, 6}},
+		{"0 0 * * 7", []int{0}, []int{0}, nil, nil, []int{0}},
+		{"0 0 * * 5-7", []int{0}, []int{0}, nil, nil, []int{0, 5, 6}},
+		{"0 0 * * fri-sun", []int{0}, []int{0}, nil, nil, []int{0, 5, 6}},
+		{"0 0 * * Sat-Sun", []int{0}, []int{0}, nil, nil, []int{0, 6}},
+		{"0 0 * * sun-tue", []int{0}, []int{0}, nil, nil, []int{0, 1, 2}},
+		{"0 0 * * */3", []int{0}, []int{0}, nil, nil, []int{0, 3, 6}},
+		{"@hourly", []int{0}, nil, nil, nil, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"@weekly", []int{0}, []int{0}, nil, nil, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check := func(name string, f cronField, want []int) {
+				if want == nil {
+					return
+				}
+				if got := fieldValues(f); !slices.Equal(got, want) {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+			check("minute", s.minute, tt.minute)
+			check("hour", s.hour, tt.hour)
+			check("day of month", s.dom, tt.dom)
+			check("month", s.month, tt.month)
+			// Weekday 7 is only ever an alias that parseCron folds into 0
+			check("weekday", s.dow[:7], tt.dow)
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"* * * * 3-1",
+		"* * * * tue-mon",
+		"* * * foo *",
+		"1,,2 * * * *",
+		"@sometimes",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestCronDayMatching(t *testing.T) {
+	// 2024-06-01 is a Saturday, 2024-06-03 a Monday and 2024-06-15 a Saturday
+	tests := []struct {
+		expr string
+		day  int
+		want bool
+	}{
+		// Both restricted: either field matching is enough
+		{"0 0 15 * mon", 3, true},
+		{"0 0 15 * mon", 15, true},
+		{"0 0 15 * mon", 1, false},
+		// With one field *, only the other counts
+		{"0 0 15 * *", 3, false},
+		{"0 0 15 * *", 15, true},
+		{"0 0 * * mon", 3, true},
+		{"0 0 * * mon", 15, false},
+		// A stepped * still counts as *, as in cron
+		{"0 0 */2 * mon", 3, true},
+		{"0 0 */2 * mon", 2, false},
+		{"0 0 1-7 * */7", 2, true},
+		{"0 0 1-7 * */7", 1, false},
+		{"0 0 1-7 * */7", 9, false},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		day := time.Date(2024, 6, tt.day, 0, 0, 0, 0, time.UTC)
+		if got := s.dayMatches(day); got != tt.want {
+			t.Errorf("%q on June %d: matches %v, want %v", tt.expr, tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr     string
+		from     time.Time
+		want     time.Time
+		describe string
+	}{
+		{"* * * * *", utc(2024, 6, 1, 10, 0).Add(30 * time.Second), utc(2024, 6, 1, 10, 1), "next minute, dropping seconds"},
+		{"0 * * * *", utc(2024, 6, 1, 10, 0), utc(2024, 6, 1, 11, 0), "strictly after"},
+		{"30 9 * * 1-5", utc(2024, 6, 1, 8, 0), utc(2024, 6, 3, 9, 30), "Saturday to Monday"},
+		{"0 0 1 * *", utc(2024, 12, 15, 0, 0), utc(2025, 1, 1, 0, 0), "over the year end"},
+		{"0 0 29 2 *", utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0), "leap day"},
+		{"0 0 31 * *", utc(2024, 4, 1, 0, 0), utc(2024, 5, 31, 0, 0), "skips short months"},
+		{"0 0 13 * fri", utc(2024, 6, 1, 0, 0), utc(2024, 6, 7, 0, 0), "first Friday before the 13th"},
+		{"0 0 * * 7", utc(2024, 6, 3, 0, 0), utc(2024, 6, 9, 0, 0), "weekday 7 is Sunday"},
+		{"0 12 * * fri-sun", utc(2024, 6, 3, 0, 0), utc(2024, 6, 7, 12, 0), "name range ending Sunday"},
+		{"0 12 * * fri-sun", utc(2024, 6, 8, 12, 0), utc(2024, 6, 9, 12, 0), "name range reaches Sunday"},
+		{"0 0 30 2 mon", utc(2024, 1, 1, 0, 0), utc(2024, 2, 5, 0, 0), "impossible date saved by the weekday"},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: %q after %v = %v, want %v", tt.describe, tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	for _, expr := range []string{"0 0 30 2 *", "0 0 31 4,6,9,11 *", "0 0 31 apr *"} {
+		s, err := parseCron(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+			t.Errorf("%q matched %v", expr, got)
+		}
+	}
+}
+
+func TestCronNextDaylightSaving(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	// Clocks went from 02:00 EST to 03:00 EDT on 2024-03-10 and from 02:00 EDT
+	// back to 01:00 EST on 2024-11-03
+	local := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, ny)
+	}
+	springGap := local(3, 10, 1, 59).Add(time.Minute)
+	fallFirst := local(11, 3, 1, 30)
+	fallSecond := fallFirst.Add(time.Hour)
+	if springGap.Hour() != 3 || fallSecond.Hour() != 1 {
+		t.Fatalf("unexpected time zone rules: %v, %v", springGap, fallSecond)
+	}
+
+	tests := []struct {
+		expr     string
+		from     time.Time
+		want     time.Time
+		describe string
+	}{
+		{"30 2 * * *", local(3, 10, 0, 0), springGap, "skipped time runs after the change"},
+		{"30 2 * * *", springGap, local(3, 11, 2, 30), "and only once"},
+		{"0 3 * * *", local(3, 10, 0, 0), springGap, "time after the gap runs on time"},
+		{"0 3 * * *", springGap, local(3, 11, 3, 0), "and only once"},
+		{"30 4 * * *", local(3, 10, 0, 0), local(3, 10, 4, 30), "later times are not moved"},
+		{"30 * * * *", local(3, 10, 1, 30), local(3, 10, 3, 30), "wildcard hours follow the clock"},
+		{"30 1 * * *", local(11, 3, 0, 0), fallFirst, "repeated time runs the first time"},
+		{"30 1 * * *", fallFirst, local(11, 4, 1, 30), "but not the second"},
+		{"0 2 * * *", fallFirst, local(11, 3, 2, 0), "time after the repeat runs once"},
+		{"30 * * * *", fallFirst, fallSecond, "wildcard hours run in both"},
+		{"*/20 * * * *", fallSecond.Add(-time.Minute), fallSecond.Add(10 * time.Minute), "in the repeated hour"},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: %q after %v = %v, want %v", tt.describe, tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
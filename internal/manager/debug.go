@@ -0,0 +1,823 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Debug session defaults
+const (
+	DefaultDebugTimeout = 30 * time.Second // how long a command waits for the script to stop
+	debugOutputSize     = 1 << 20          // program output kept between commands
+	debugValueChars     = 1000             // longest repr returned for a value
+	debugMarker         = "__JUMPBOOT_DEBUG__"
+)
+
+// States of a debug session
+const (
+	DebugPaused  = "paused"
+	DebugRunning = "running"
+	DebugExited  = "exited"
+)
+
+// Debug commands. Resuming commands run the script until it stops again; the others
+// inspect it or change breakpoints while it is paused.
+const (
+	DebugContinue    = "continue"
+	DebugStep        = "step"
+	DebugNext        = "next"
+	DebugReturn      = "return"
+	DebugQuit        = "quit"
+	DebugBreak       = "break"
+	DebugClear       = "clear"
+	DebugBreakpoints = "breakpoints"
+	DebugStack       = "stack"
+	DebugVariables   = "variables"
+	DebugEval        = "eval"
+	DebugSource      = "source"
+	DebugWait        = "wait"
+	debugStart       = "start" // sent once by StartDebug
+)
+
+var debugResumeCommands = []string{DebugContinue, DebugStep, DebugNext, DebugReturn, DebugQuit}
+
+var debugInspectCommands = []string{DebugBreak, DebugClear, DebugBreakpoints, DebugStack, DebugVariables, DebugEval, DebugSource}
+
+// debugDriverScript runs a script under a bdb debugger controlled by JSON commands on
+// stdin. Replies are written to stdout after a marker, behind the program's own
+// output, so the output that precedes a stop arrives first. The program gets an empty
+// stdin. Arguments: 1 to pause on the first line, the script, its arguments.
+const debugDriverScript = `
+import bdb, json, linecache, os, sys, traceback, types
+
+MARKER = %q
+VALUE_CHARS = %d
+DRIVER = globals()
+RESUME = ('continue', 'step', 'next', 'return', 'quit')
+
+commands = os.fdopen(os.dup(0), 'r')
+_null = os.open(os.devnull, os.O_RDONLY)
+os.dup2(_null, 0)
+os.close(_null)
+
+
+def send(reply):
+    for stream in (sys.stdout, sys.stderr, sys.__stdout__, sys.__stderr__):
+        try:
+            stream.flush()
+        except Exception:
+            pass
+    data = (MARKER + json.dumps(reply, default=str) + '\n').encode()
+    while data:
+        data = data[os.write(1, data):]
+
+
+def receive():
+    line = commands.readline()
+    if not line:
+        os._exit(1)
+    return json.loads(line)
+
+
+def rel(path):
+    try:
+        r = os.path.relpath(path)
+        if not r.startswith('..'):
+            return r
+    except ValueError:
+        pass
+    return path
+
+
+def describe(value):
+    try:
+        text = repr(value)
+    except Exception as e:
+        text = '<repr failed: %%s>' %% e
+    out = {'type': type(value).__name__, 'value': text[:VALUE_CHARS]}
+    if len(text) > VALUE_CHARS:
+        out['truncated'] = True
+    return out
+
+
+def internal(frame):
+    return frame.f_globals is DRIVER or frame.f_code.co_filename == bdb.__file__
+
+
+def user_traceback(tb):
+    while tb is not None and internal(tb.tb_frame):
+        tb = tb.tb_next
+    return tb
+
+
+def exception_info(exc_type, value, tb):
+    text = ''.join(traceback.format_exception(exc_type, value, user_traceback(tb)))
+    return {'type': exc_type.__name__, 'message': str(value), 'traceback': text[-8000:]}
+
+
+class Debugger(bdb.Bdb):
+    def __init__(self, script, stop_on_entry):
+        bdb.Bdb.__init__(self)
+        self.script = self.canonic(script)
+        self.stop_on_entry = stop_on_entry
+        self.waiting = True
+        self.post_mortem = False
+        self.quit = False
+        self.stack = []
+        self.locals = {}
+
+    def user_call(self, frame, args):
+        pass
+
+    def user_line(self, frame):
+        if self.waiting:
+            if self.canonic(frame.f_code.co_filename) != self.script or frame.f_lineno <= 0:
+                return
+            self.waiting = False
+            if self.stop_on_entry:
+                return self.interaction(frame, 'entry')
+            if self.break_here(frame):
+                return self.interaction(frame, 'breakpoint')
+            return self.set_continue()
+        hit = self.get_breaks(self.canonic(frame.f_code.co_filename), frame.f_lineno)
+        self.interaction(frame, 'breakpoint' if hit else 'step')
+
+    def user_return(self, frame, value):
+        if not self.waiting:
+            self.interaction(frame, 'return', return_value=describe(value))
+
+    def user_exception(self, frame, exc_info):
+        if not self.waiting:
+            self.interaction(frame, 'exception', exception=exception_info(*exc_info))
+
+    def interaction(self, frame, reason, tb=None, **extra):
+        stack, _ = self.get_stack(frame, tb)
+        self.stack = [(f, n) for f, n in reversed(stack) if not internal(f)]
+        self.locals = {}
+        state = {'state': 'paused', 'reason': reason, 'location': self.frame_info(0)}
+        state.update(extra)
+        self.serve(state)
+        self.stack = []
+
+    def serve(self, reply):
+        send(reply)
+        while True:
+            cmd = receive()
+            name = cmd.get('command')
+            if name in RESUME:
+                if self.post_mortem and name not in ('continue', 'quit'):
+                    send({'error': 'the script has ended with an exception; inspect it, then continue or quit'})
+                    continue
+                return self.resume(name)
+            try:
+                reply = self.handle(name, cmd)
+            except Exception as e:
+                reply = {'error': '%%s: %%s' %% (type(e).__name__, e)}
+            send(reply)
+
+    def resume(self, name):
+        frame = self.stack[0][0]
+        if self.post_mortem:
+            self.quit = name == 'quit'
+        elif name == 'continue':
+            self.set_continue()
+        elif name == 'step':
+            self.set_step()
+        elif name == 'next':
+            self.set_next(frame)
+        elif name == 'return':
+            self.set_return(frame)
+        else:
+            self.quit = True
+            self.set_quit()
+
+    def frame(self, cmd):
+        if not self.stack:
+            raise ValueError('the script is not paused')
+        index = int(cmd.get('frame') or 0)
+        if not 0 <= index < len(self.stack):
+            raise ValueError('frame must be between 0 and %%d' %% (len(self.stack) - 1))
+        return index, self.stack[index][0]
+
+    def frame_info(self, index):
+        frame, lineno = self.stack[index]
+        filename = frame.f_code.co_filename
+        return {'index': index, 'file': rel(filename), 'line': lineno, 'function': frame.f_code.co_name,
+                'code': linecache.getline(filename, lineno, frame.f_globals).strip()}
+
+    def frame_locals(self, index, frame):
+        # Edits to f_locals only reach the frame if the same dict is kept until it resumes
+        if index not in self.locals:
+            self.locals[index] = frame.f_locals
+        return self.locals[index]
+
+    def breakpoint_list(self):
+        return [{'number': bp.number, 'file': rel(bp.file), 'line': bp.line, 'condition': bp.cond,
+                 'temporary': bool(bp.temporary), 'hits': bp.hits}
+                for bp in bdb.Breakpoint.bpbynumber if bp]
+
+    def handle(self, name, cmd):
+        if name == 'break':
+            filename = self.canonic(cmd.get('file') or self.script)
+            line = int(cmd.get('line') or 0)
+            if not os.path.isfile(filename):
+                raise ValueError('no such file: %%s' %% rel(filename))
+            code = linecache.getline(filename, line).strip()
+            if code == '' or code.startswith('#'):
+                raise ValueError('line %%d of %%s is blank, a comment or past the end' %% (line, rel(filename)))
+            err = self.set_break(filename, line, bool(cmd.get('temporary')), cmd.get('condition') or None)
+            if err:
+                raise ValueError(err)
+            bp = self.get_breaks(filename, line)[-1]
+            return {'breakpoint': [b for b in self.breakpoint_list() if b['number'] == bp.number][0],
+                    'breakpoints': self.breakpoint_list()}
+        if name == 'clear':
+            if cmd.get('line'):
+                err = self.clear_break(self.canonic(cmd.get('file') or self.script), int(cmd['line']))
+                if err:
+                    raise ValueError(err)
+            else:
+                self.clear_all_breaks()
+            return {'breakpoints': self.breakpoint_list()}
+        if name == 'breakpoints':
+            return {'breakpoints': self.breakpoint_list()}
+        if name == 'stack':
+            self.frame(cmd)
+            return {'frames': [self.frame_info(i) for i in range(len(self.stack))]}
+        if name == 'variables':
+            index, frame = self.frame(cmd)
+            scope = cmd.get('scope') or 'locals'
+            if scope not in ('locals', 'globals'):
+                raise ValueError('scope must be locals or globals')
+            names = self.frame_locals(index, frame) if scope == 'locals' else frame.f_globals
+            values = {k: describe(v) for k, v in names.items() if not (k.startswith('__') and k.endswith('__'))}
+            return {'variables': values}
+        if name == 'eval':
+            index, frame = self.frame(cmd)
+            expression = cmd.get('expression') or ''
+            scope = self.frame_locals(index, frame)
+            try:
+                code = compile(expression, '<debug>', 'eval')
+            except SyntaxError:
+                exec(compile(expression, '<debug>', 'exec'), frame.f_globals, scope)
+                return {'value': describe(None)}
+            return {'value': describe(eval(code, frame.f_globals, scope))}
+        if name == 'source':
+            index, frame = self.frame(cmd)
+            filename, current = frame.f_code.co_filename, self.stack[index][1]
+            context = int(cmd.get('context') or 5)
+            lines = []
+            for n in range(max(1, current - context), current + context + 1):
+                code = linecache.getline(filename, n, frame.f_globals)
+                if not code:
+                    break
+                lines.append({'line': n, 'code': code.rstrip('\n'), 'current': n == current})
+            return {'source': lines}
+        raise ValueError('unknown command: %%s' %% name)
+
+
+def main():
+    stop_on_entry = sys.argv[1] == '1'
+    script = os.path.abspath(sys.argv[2])
+    sys.argv = sys.argv[2:]
+    sys.path[0] = os.path.dirname(script)
+    debugger = Debugger(script, stop_on_entry)
+
+    while True:
+        cmd = receive()
+        if cmd.get('command') == 'start':
+            break
+        try:
+            reply = debugger.handle(cmd.get('command'), cmd)
+        except Exception as e:
+            reply = {'error': '%%s: %%s' %% (type(e).__name__, e)}
+        send(reply)
+
+    module = types.ModuleType('__main__')
+    module.__file__ = script
+    module.__builtins__ = __builtins__
+    sys.modules['__main__'] = module
+
+    final = {'state': 'exited', 'reason': 'finished', 'exit_code': 0}
+    try:
+        with open(script, 'rb') as f:
+            code = compile(f.read(), script, 'exec')
+        debugger.run(code, module.__dict__)
+    except SystemExit as e:
+        if e.code is None or isinstance(e.code, int):
+            final['exit_code'] = e.code or 0
+        else:
+            print(e.code, file=sys.stderr)
+            final['exit_code'] = 1
+    except BaseException:
+        exc_type, value, tb = sys.exc_info()
+        info = exception_info(exc_type, value, tb)
+        final.update(reason='exception', exit_code=1, exception=info)
+        if not debugger.quit and user_traceback(tb) is not None:
+            debugger.post_mortem = True
+            debugger.interaction(None, 'uncaught_exception', user_traceback(tb), exception=info)
+    if debugger.quit:
+        final['reason'] = 'quit'
+    send(final)
+    sys.exit(final['exit_code'])
+
+
+main()
+`
+
+// DebugOptions configure a debug session
+type DebugOptions struct {
+	Name        string
+	Args        []string
+	Breakpoints []DebugBreakpoint // set before the script starts
+	StopOnEntry bool              // pause on the script's first line
+}
+
+// DebugBreakpoint is a line breakpoint. File is relative to the workspace, or
+// absolute for library code; empty means the debugged script.
+type DebugBreakpoint struct {
+	Number    int    `json:"number,omitempty"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"` // Python expression; the breakpoint only stops when it is true
+	Temporary bool   `json:"temporary,omitempty"` // removed when first hit
+	Hits      int    `json:"hits,omitempty"`
+}
+
+// DebugFrame is a stack frame of a paused script. Index 0 is the innermost frame.
+type DebugFrame struct {
+	Index    int    `json:"index"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function"`
+	Code     string `json:"code"`
+}
+
+// DebugValue is a Python value, described by its type and repr
+type DebugValue struct {
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// DebugException is an exception raised in a debugged script
+type DebugException struct {
+	Type      string `json:"type"`
+	Message   string `json:"message"`
+	Traceback string `json:"traceback"`
+}
+
+// DebugSourceLine is a line of source around a frame's current line
+type DebugSourceLine struct {
+	Line    int    `json:"line"`
+	Code    string `json:"code"`
+	Current bool   `json:"current,omitempty"`
+}
+
+// DebugState is where a debugged script is. Reason says why it paused (entry,
+// breakpoint, step, return, exception or uncaught_exception, which is inspected after
+// the script has failed) or why it exited (finished, exception, quit or killed).
+type DebugState struct {
+	State       string          `json:"state"`
+	Reason      string          `json:"reason,omitempty"`
+	Location    *DebugFrame     `json:"location,omitempty"`
+	ReturnValue *DebugValue     `json:"return_value,omitempty"` // value returned, when paused on return
+	Exception   *DebugException `json:"exception,omitempty"`
+	ExitCode    *int            `json:"exit_code,omitempty"`
+}
+
+// DebugRequest is a command for a debug session. Frame selects a stack frame by index
+// for stack inspection; the other fields apply to the commands named with them.
+type DebugRequest struct {
+	Command    string `json:"command"`
+	File       string `json:"file,omitempty"`       // break, clear
+	Line       int    `json:"line,omitempty"`       // break, clear
+	Condition  string `json:"condition,omitempty"`  // break
+	Temporary  bool   `json:"temporary,omitempty"`  // break
+	Frame      int    `json:"frame,omitempty"`      // variables, eval, source
+	Scope      string `json:"scope,omitempty"`      // variables: locals or globals
+	Expression string `json:"expression,omitempty"` // eval
+	Context    int    `json:"context,omitempty"`    // source: lines before and after
+}
+
+// DebugResult is the state of a debug session after a command, the command's own
+// result and the program output since the previous command
+type DebugResult struct {
+	DebugID string `json:"debug_id"`
+	DebugState
+
+	Frames      []DebugFrame          `json:"frames,omitempty"`
+	Variables   map[string]DebugValue `json:"variables,omitempty"`
+	Value       *DebugValue           `json:"value,omitempty"`
+	Breakpoint  *DebugBreakpoint      `json:"breakpoint,omitempty"`
+	Breakpoints []DebugBreakpoint     `json:"breakpoints,omitempty"`
+	Source      []DebugSourceLine     `json:"source,omitempty"`
+
+	Output          string `json:"output"`
+	OutputTruncated bool   `json:"output_truncated,omitempty"` // older output was dropped
+}
+
+// DebugSessionInfo is the serializable info about a debug session
+type DebugSessionInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	EnvID     string    `json:"env_id"`
+	Script    string    `json:"script"`
+	Args      []string  `json:"args,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	Owner     string    `json:"owner,omitempty"`
+	DebugState
+}
+
+// ManagedDebugSession is a workspace script running under the debugger
+type ManagedDebugSession struct {
+	ID        string
+	Name      string
+	EnvID     string
+	Script    string // relative to the workspace
+	Args      []string
+	Owner     string
+	StartTime time.Time
+
+	cmd     *exec.Cmd
+	tree    *processTree
+	stdin   io.WriteCloser
+	replies chan []byte   // driver replies; closed when its output ends
+	done    chan struct{} // closed when the process has exited
+
+	cmdMu sync.Mutex // one command at a time
+
+	mu              sync.Mutex // protects the fields below
+	state           DebugState
+	pending         bool // a command was sent and its reply not yet received
+	output          []byte
+	outputTruncated bool
+	exitCode        int
+}
+
+// info returns the serializable info about the session
+func (s *ManagedDebugSession) info() *DebugSessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &DebugSessionInfo{
+		ID:         s.ID,
+		Name:       s.Name,
+		EnvID:      s.EnvID,
+		Script:     s.Script,
+		Args:       s.Args,
+		StartTime:  s.StartTime,
+		Owner:      s.Owner,
+		DebugState: s.state,
+	}
+}
+
+// appendOutput adds program output, keeping the newest debugOutputSize bytes
+func (s *ManagedDebugSession) appendOutput(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.output = append(s.output, b...)
+	if over := len(s.output) - debugOutputSize; over > 0 {
+		s.output = s.output[over:]
+		s.outputTruncated = true
+	}
+}
+
+// readOutput splits the process output into program output and driver replies
+func (s *ManagedDebugSession) readOutput(r io.ReadCloser) {
+	defer close(s.replies)
+	defer r.Close()
+
+	marker := []byte(debugMarker)
+	var pending []byte
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		pending = append(pending, chunk[:n]...)
+		for {
+			i := bytes.Index(pending, marker)
+			if i < 0 {
+				break
+			}
+			end := bytes.IndexByte(pending[i:], '\n')
+			if end < 0 {
+				break
+			}
+			s.appendOutput(pending[:i])
+			s.replies <- bytes.Clone(pending[i+len(marker) : i+end])
+			pending = pending[i+end+1:]
+		}
+		if err != nil {
+			s.appendOutput(pending)
+			return
+		}
+		// Hold back a reply that is still arriving
+		keep := bytes.Index(pending, marker)
+		if keep < 0 {
+			keep = len(pending) - partialSuffix(pending, marker)
+		}
+		s.appendOutput(pending[:keep])
+		pending = slices.Clone(pending[keep:])
+	}
+}
+
+// partialSuffix returns the length of the longest end of b that starts marker
+func partialSuffix(b, marker []byte) int {
+	for k := min(len(marker)-1, len(b)); k > 0; k-- {
+		if bytes.HasSuffix(b, marker[:k]) {
+			return k
+		}
+	}
+	return 0
+}
+
+// command sends req to the driver and waits up to timeout for the reply. A resuming
+// command that gets no reply in time leaves the script running; DebugWait collects
+// the reply later.
+func (s *ManagedDebugSession) command(ctx context.Context, req DebugRequest, timeout time.Duration) (*DebugResult, error) {
+	s.mu.Lock()
+	pending, before := s.pending, s.state
+	s.mu.Unlock()
+
+	switch {
+	case req.Command == DebugWait:
+		if !pending {
+			return s.result(nil), nil
+		}
+	case pending:
+		return nil, WithErrorCode(CodeInvalidArgument, errors.New("the script is running; use wait to wait for it to stop, or debug_stop"))
+	case before.State == DebugExited:
+		return nil, fmt.Errorf("debug session has exited: %s", s.ID)
+	default:
+		line, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.pending = true
+		if req.Command == debugStart || slices.Contains(debugResumeCommands, req.Command) {
+			s.state = DebugState{State: DebugRunning}
+		}
+		s.mu.Unlock()
+		if _, err := s.stdin.Write(append(line, '\n')); err != nil {
+			return nil, fmt.Errorf("failed to send debug command: %w", err)
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply, ok := <-s.replies:
+		if !ok {
+			// The driver died without reporting, e.g. it was killed
+			<-s.done
+			s.mu.Lock()
+			code := s.exitCode
+			s.pending = false
+			s.state = DebugState{State: DebugExited, Reason: "killed", ExitCode: &code}
+			s.mu.Unlock()
+			return s.result(nil), nil
+		}
+		var decoded struct {
+			DebugResult
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(reply, &decoded); err != nil {
+			return nil, fmt.Errorf("invalid reply from debugger: %w", err)
+		}
+		s.mu.Lock()
+		s.pending = false
+		if decoded.State != "" {
+			s.state = decoded.DebugState
+		} else {
+			// A refused resume leaves the script where it was
+			s.state = before
+		}
+		s.mu.Unlock()
+		if decoded.Error != "" {
+			return nil, WithErrorCode(CodeInvalidArgument, errors.New(decoded.Error))
+		}
+		return s.result(&decoded.DebugResult), nil
+	case <-timer.C:
+		return s.result(nil), nil
+	case <-ctx.Done():
+		return nil, checkCancelled(ctx)
+	}
+}
+
+// result completes a command's result with the session's state and the output
+// received since the previous command
+func (s *ManagedDebugSession) result(r *DebugResult) *DebugResult {
+	if r == nil {
+		r = &DebugResult{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.DebugID = s.ID
+	r.DebugState = s.state
+	r.Output, r.OutputTruncated = string(s.output), s.outputTruncated
+	s.output, s.outputTruncated = nil, false
+	return r
+}
+
+// StartDebug runs a workspace script under the debugger. Breakpoints are set first;
+// the script then runs until it pauses at one, on its first line with StopOnEntry, or
+// exits. It waits up to timeout for that; the script keeps running if it takes longer.
+// The script runs in the environment's sandbox and network policy like run_script.
+func (m *Manager) StartDebug(ctx context.Context, envID, filename string, opts DebugOptions, timeout time.Duration) (*DebugResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	scriptPath, err := safeJoinPath(env.WorkspaceDir, filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("script not found: %s", filename))
+	}
+
+	stopOnEntry := "0"
+	if opts.StopOnEntry {
+		stopOnEntry = "1"
+	}
+	args := append([]string{"-u", "-c", fmt.Sprintf(debugDriverScript, debugMarker, debugValueChars), stopOnEntry, scriptPath}, opts.Args...)
+	cmd := exec.Command(env.Env.PythonPath, args...)
+	cmd.Dir = env.WorkspaceDir
+	cmd.Env = append(env.appendVars(os.Environ()), pathVar(env))
+	cleanup, err := m.isolate(env, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to create input pipe: %w", err)
+	}
+	// Program output and replies share one pipe, which keeps them in order
+	r, w, err := os.Pipe()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to create output pipe: %w", err)
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	setProcessGroup(cmd)
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		r.Close()
+		cleanup()
+		return nil, fmt.Errorf("failed to start debugger: %w", err)
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = filepath.Base(filename)
+	}
+	session := &ManagedDebugSession{
+		ID:        uuid.New().String(),
+		Name:      name,
+		EnvID:     envID,
+		Script:    workspaceRelative(env.WorkspaceDir, scriptPath),
+		Args:      opts.Args,
+		Owner:     ownerFor(ctx),
+		StartTime: time.Now(),
+		cmd:       cmd,
+		tree:      newProcessTree(cmd.Process),
+		stdin:     stdin,
+		replies:   make(chan []byte, 1),
+		done:      make(chan struct{}),
+		state:     DebugState{State: DebugRunning},
+	}
+	go session.readOutput(r)
+	go func() {
+		err := cmd.Wait()
+		cleanup()
+		session.tree.release()
+		session.mu.Lock()
+		session.exitCode = 0
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				session.exitCode = exitErr.ExitCode()
+			} else {
+				session.exitCode = -1
+			}
+		}
+		session.mu.Unlock()
+		close(session.done)
+	}()
+
+	m.mu.Lock()
+	m.debugSessions[session.ID] = session
+	m.mu.Unlock()
+
+	session.cmdMu.Lock()
+	defer session.cmdMu.Unlock()
+	for _, bp := range opts.Breakpoints {
+		req := DebugRequest{Command: DebugBreak, File: bp.File, Line: bp.Line, Condition: bp.Condition, Temporary: bp.Temporary}
+		if _, err := session.command(ctx, req, DefaultDebugTimeout); err != nil {
+			m.StopDebug(session.ID)
+			return nil, err
+		}
+	}
+	return session.command(ctx, DebugRequest{Command: debugStart}, timeout)
+}
+
+// DebugCommand runs a command in a debug session. Resuming commands (continue, step,
+// next, return, quit) wait up to timeout for the script to pause or exit; if it is
+// still running, the result says so and wait collects the stop later. The other
+// commands inspect the paused script or change its breakpoints.
+func (m *Manager) DebugCommand(ctx context.Context, id string, req DebugRequest, timeout time.Duration) (*DebugResult, error) {
+	if req.Command != DebugWait && !slices.Contains(debugResumeCommands, req.Command) && !slices.Contains(debugInspectCommands, req.Command) {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("unknown debug command %q", req.Command))
+	}
+	session, err := m.getDebugSession(id)
+	if err != nil {
+		return nil, err
+	}
+	session.cmdMu.Lock()
+	defer session.cmdMu.Unlock()
+	return session.command(ctx, req, timeout)
+}
+
+// getDebugSession returns a debug session by ID
+func (m *Manager) getDebugSession(id string) (*ManagedDebugSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.debugSessions[id]
+	if !ok {
+		return nil, notFound("debug session", id)
+	}
+	return session, nil
+}
+
+// CheckDebugAccess returns an error if the caller in ctx may not use the debug session
+func (m *Manager) CheckDebugAccess(ctx context.Context, id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.debugSessions[id]
+	if !ok || !m.canAccess(ctx, session.Owner) {
+		return notFound("debug session", id)
+	}
+	return nil
+}
+
+// ListDebugSessions returns all debug sessions visible to the caller in ctx
+func (m *Manager) ListDebugSessions(ctx context.Context) []DebugSessionInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]DebugSessionInfo, 0, len(m.debugSessions))
+	for _, session := range m.debugSessions {
+		if !m.canAccess(ctx, session.Owner) {
+			continue
+		}
+		result = append(result, *session.info())
+	}
+	return result
+}
+
+// StopDebug kills the debugged script and removes the session
+func (m *Manager) StopDebug(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.debugSessions[id]
+	if !ok {
+		return notFound("debug session", id)
+	}
+	session.close()
+	delete(m.debugSessions, id)
+	return nil
+}
+
+// close kills the script and the processes it started
+func (s *ManagedDebugSession) close() {
+	select {
+	case <-s.done:
+	default:
+		s.tree.kill()
+		<-s.done
+	}
+	s.stdin.Close()
+}
@@ -0,0 +1,579 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultDiffContext is the number of unchanged lines around each change in a diff
+const DefaultDiffContext = 3
+
+// maxDiffTrace bounds the memory of the diff algorithm; inputs that differ more are
+// shown as one replaced block
+const maxDiffTrace = 1 << 24
+
+// devNull names the missing side of a created or deleted file in a diff
+const devNull = "/dev/null"
+
+// noNewline marks a diff line that has no newline at the end of the file
+const noNewline = `\ No newline at end of file`
+
+// DiffResult is a unified diff between a workspace file and new content or another file
+type DiffResult struct {
+	Path      string `json:"path"`
+	Against   string `json:"against"` // the other file, or "content"
+	Identical bool   `json:"identical"`
+	Added     int    `json:"added"`   // lines only in the new side
+	Removed   int    `json:"removed"` // lines only in path
+	Diff      string `json:"diff,omitempty"`
+}
+
+// PatchedFile is a file changed by ApplyPatch
+type PatchedFile struct {
+	Path    string `json:"path"`
+	Hunks   int    `json:"hunks"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Created bool   `json:"created,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"` // moved to the trash
+}
+
+// PatchResult is the outcome of ApplyPatch
+type PatchResult struct {
+	DryRun bool          `json:"dry_run"`
+	Files  []PatchedFile `json:"files"`
+}
+
+// DiffWorkspaceFile returns a unified diff from a workspace file to content, or to the
+// workspace file other when other is set. A missing path diffs as an empty file, so
+// the result creates it. Headers use git's a/ and b/ prefixes, which ApplyPatch strips.
+func (m *Manager) DiffWorkspaceFile(envID, path, other, content string, contextLines int) (*DiffResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if contextLines < 0 {
+		contextLines = DefaultDiffContext
+	}
+
+	oldName, oldText, err := readDiffSide(env, path)
+	if err != nil {
+		return nil, err
+	}
+	result := &DiffResult{Path: path, Against: "content"}
+	newName, newText := "b/"+filepath.ToSlash(path), content
+	if other != "" {
+		result.Against = other
+		name, text, err := readDiffSide(env, other)
+		if err != nil {
+			return nil, err
+		}
+		if name == devNull {
+			return nil, WithErrorCode(CodeNotFound, fmt.Errorf("file not found: %s", other))
+		}
+		newName, newText = "b/"+filepath.ToSlash(other), text
+	}
+	if oldText == newText && oldName != devNull {
+		result.Identical = true
+		return result, nil
+	}
+
+	result.Diff, result.Added, result.Removed = unifiedDiff(oldName, newName, splitKeepNewlines(oldText), splitKeepNewlines(newText), contextLines)
+	return result, nil
+}
+
+// readDiffSide reads a workspace file for a diff, returning its header name, or
+// devNull and no content if it does not exist
+func readDiffSide(env *ManagedEnvironment, path string) (name, text string, err error) {
+	file, err := safeJoinPath(env.WorkspaceDir, path)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return devNull, "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return "a/" + filepath.ToSlash(path), string(data), nil
+}
+
+// ApplyPatch applies a unified diff, as made by workspace_diff, git diff or diff -u,
+// to the workspace. Each hunk must match the file's lines exactly, but may have moved
+// from the line its header names. Nothing is written unless every hunk of every file
+// applies, and nothing at all with dryRun. path overrides the file named by a
+// single-file patch. Files the patch deletes are moved to the trash.
+func (m *Manager) ApplyPatch(envID, patch, path string, dryRun bool) (*PatchResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return nil, WithErrorCode(CodeInvalidArgument, err)
+	}
+	if path != "" {
+		if len(patches) > 1 {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("path can only be given for a single-file patch; this one changes %d files", len(patches)))
+		}
+		patches[0].path = path
+	}
+
+	// Apply everything in memory first
+	type change struct {
+		file    string
+		content string
+		info    PatchedFile
+	}
+	var changes []change
+	for _, p := range patches {
+		file, err := safeJoinPath(env.WorkspaceDir, p.path)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.checkMountWritable(env, file); err != nil {
+			return nil, err
+		}
+		info := PatchedFile{Path: p.path, Hunks: len(p.hunks), Created: p.created, Deleted: p.deleted}
+		var lines []string
+		data, err := os.ReadFile(file)
+		switch {
+		case p.created && err == nil:
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("the patch creates %s, which already exists", p.path))
+		case !p.created && errors.Is(err, fs.ErrNotExist):
+			return nil, WithErrorCode(CodeNotFound, fmt.Errorf("file not found: %s", p.path))
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		case err == nil:
+			lines = splitKeepNewlines(string(data))
+		}
+		lines, err = applyHunks(lines, p.hunks)
+		if err != nil {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("%s: %w", p.path, err))
+		}
+		if p.deleted && len(lines) > 0 {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("the patch deletes %s, but does not remove all of its lines", p.path))
+		}
+		for _, h := range p.hunks {
+			for _, line := range h.lines {
+				switch line[0] {
+				case '+':
+					info.Added++
+				case '-':
+					info.Removed++
+				}
+			}
+		}
+		changes = append(changes, change{file: file, content: strings.Join(lines, ""), info: info})
+	}
+
+	result := &PatchResult{DryRun: dryRun}
+	for _, c := range changes {
+		result.Files = append(result.Files, c.info)
+		if dryRun {
+			continue
+		}
+		if c.info.Deleted {
+			if _, err := m.moveToTrash(env, c.file, filepath.Clean(c.info.Path)); err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", c.info.Path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(c.file), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(c.file, []byte(c.content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", c.info.Path, err)
+		}
+	}
+	return result, nil
+}
+
+// splitKeepNewlines splits text into lines that keep their newline; only the last may
+// lack one
+func splitKeepNewlines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is one line of an edit script: kind is ' ' (kept), '-' (removed from a) or
+// '+' (added from b); ai and bi are the positions in a and b where it applies
+type diffOp struct {
+	kind   byte
+	ai, bi int
+}
+
+// diffLines returns the edit script turning a into b, found with Myers' algorithm
+// after removing the common head and tail
+func diffLines(a, b []string) []diffOp {
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+
+	var ops []diffOp
+	for i := range head {
+		ops = append(ops, diffOp{' ', i, i})
+	}
+	for _, op := range myersDiff(a[head:len(a)-tail], b[head:len(b)-tail]) {
+		ops = append(ops, diffOp{op.kind, op.ai + head, op.bi + head})
+	}
+	for i := range tail {
+		ops = append(ops, diffOp{' ', len(a) - tail + i, len(b) - tail + i})
+	}
+	return ops
+}
+
+// myersDiff returns a shortest edit script from a to b, or a plain replacement of a
+// by b when finding one would need more than maxDiffTrace memory
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && !found; d++ {
+		if (d+1)*len(v) > maxDiffTrace {
+			return replaceAll(n, m)
+		}
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk back from the end through the saved rounds
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', x, y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', x, y})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', x, y})
+		}
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// replaceAll is the edit script removing n lines and adding m
+func replaceAll(n, m int) []diffOp {
+	var ops []diffOp
+	for i := range n {
+		ops = append(ops, diffOp{'-', i, 0})
+	}
+	for i := range m {
+		ops = append(ops, diffOp{'+', n, i})
+	}
+	return ops
+}
+
+// unifiedDiff formats the changes from a to b with context unchanged lines around
+// each, returning the diff and the numbers of added and removed lines
+func unifiedDiff(aName, bName string, a, b []string, context int) (diff string, added, removed int) {
+	ops := diffLines(a, b)
+	var sb strings.Builder
+	sb.WriteString("--- " + aName + "\n+++ " + bName + "\n")
+	for start := 0; start < len(ops); {
+		// Find the next change and extend its hunk while changes are near
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				if i-last > 2*context {
+					break
+				}
+				last = i
+			}
+		}
+		from := max(first-context, start)
+		to := min(last+context+1, len(ops))
+
+		aLen, bLen := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(ops[from].ai, aLen), hunkRange(ops[from].bi, bLen))
+		for _, op := range ops[from:to] {
+			var line string
+			switch op.kind {
+			case ' ':
+				line = b[op.bi]
+			case '-':
+				line = a[op.ai]
+				removed++
+			case '+':
+				line = b[op.bi]
+				added++
+			}
+			sb.WriteByte(op.kind)
+			sb.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n" + noNewline + "\n")
+			}
+		}
+		start = to
+	}
+	return sb.String(), added, removed
+}
+
+// hunkRange formats the start and length of one side of a hunk header; an empty
+// side names the line before it
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return strconv.Itoa(start) + ",0"
+	}
+	if length == 1 {
+		return strconv.Itoa(start + 1)
+	}
+	return strconv.Itoa(start+1) + "," + strconv.Itoa(length)
+}
+
+// filePatch is the part of a unified diff that changes one file
+type filePatch struct {
+	path             string
+	created, deleted bool
+	hunks            []hunk
+}
+
+// hunk is one @@ block of a unified diff; lines keep their ' ', '-' or '+' prefix and
+// their newline, which is missing where the patch says there is none
+type hunk struct {
+	oldStart, oldLen, newStart, newLen int
+	lines                              []string
+}
+
+// parsePatch splits a unified diff into its files. Text outside the files, such as
+// git's "diff --git" and "index" lines, is ignored.
+func parsePatch(patch string) ([]filePatch, error) {
+	lines := splitKeepNewlines(patch)
+	var patches []filePatch
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		oldName, newName := patchFileName(lines[i][4:]), patchFileName(lines[i+1][4:])
+		p := filePatch{created: oldName == devNull, deleted: newName == devNull}
+		switch {
+		case p.created && p.deleted:
+			return nil, fmt.Errorf("line %d: both sides of the file header are %s", i+1, devNull)
+		case p.deleted:
+			p.path = strings.TrimPrefix(oldName, "a/")
+		default:
+			p.path = strings.TrimPrefix(newName, "b/")
+		}
+		i += 2
+
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@ ") {
+			h, err := parseHunkHeader(lines[i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			i++
+			oldLeft, newLeft := h.oldLen, h.newLen
+			for i < len(lines) && (oldLeft > 0 || newLeft > 0 || strings.HasPrefix(lines[i], `\`)) {
+				line := lines[i]
+				switch line[0] {
+				case ' ', '\n', '\r':
+					if line[0] != ' ' {
+						// Editors often strip the space of empty context lines
+						line = " " + line
+					}
+					oldLeft--
+					newLeft--
+				case '-':
+					oldLeft--
+				case '+':
+					newLeft--
+				case '\\':
+					if len(h.lines) > 0 {
+						last := &h.lines[len(h.lines)-1]
+						*last = strings.TrimSuffix(*last, "\n")
+					}
+					i++
+					continue
+				default:
+					return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", i+1, strings.TrimSpace(line))
+				}
+				if oldLeft < 0 || newLeft < 0 {
+					return nil, fmt.Errorf("line %d: hunk is longer than its header says", i+1)
+				}
+				h.lines = append(h.lines, line)
+				i++
+			}
+			if oldLeft > 0 || newLeft > 0 {
+				return nil, fmt.Errorf("hunk at line %d is shorter than its header says", i)
+			}
+			p.hunks = append(p.hunks, h)
+		}
+		i--
+		if len(p.hunks) == 0 {
+			return nil, fmt.Errorf("no hunks for %s", p.path)
+		}
+		patches = append(patches, p)
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no unified diff found: expected --- and +++ file headers followed by @@ hunks")
+	}
+	return patches, nil
+}
+
+// patchFileName returns the file name of a --- or +++ header without its timestamp
+func patchFileName(header string) string {
+	name := strings.TrimRight(header, "\r\n")
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSpace(name)
+}
+
+// parseHunkHeader parses "@@ -start,len +start,len @@"
+func parseHunkHeader(line string) (hunk, error) {
+	var h hunk
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return h, fmt.Errorf("malformed hunk header: %q", strings.TrimSpace(line))
+	}
+	var err1, err2 error
+	h.oldStart, h.oldLen, err1 = parseHunkRange(fields[1][1:])
+	h.newStart, h.newLen, err2 = parseHunkRange(fields[2][1:])
+	if err1 != nil || err2 != nil {
+		return h, fmt.Errorf("malformed hunk header: %q", strings.TrimSpace(line))
+	}
+	return h, nil
+}
+
+// parseHunkRange parses "start,len" or "start", whose length is 1
+func parseHunkRange(s string) (start, length int, err error) {
+	startText, lengthText, ok := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startText); err != nil {
+		return 0, 0, err
+	}
+	length = 1
+	if ok {
+		length, err = strconv.Atoi(lengthText)
+	}
+	return start, length, err
+}
+
+// applyHunks applies the hunks of one file in order. A hunk whose lines are not at the
+// line its header names is looked for nearby, like patch does, but never before the
+// previous hunk.
+func applyHunks(lines []string, hunks []hunk) ([]string, error) {
+	var out []string
+	pos, shift := 0, 0 // next unread line; how far the hunks moved so far
+	for n, h := range hunks {
+		var old, replacement []string
+		for _, line := range h.lines {
+			if line[0] != '+' {
+				old = append(old, line[1:])
+			}
+			if line[0] != '-' {
+				replacement = append(replacement, line[1:])
+			}
+		}
+		want := h.oldStart - 1 + shift
+		if h.oldLen == 0 {
+			want = h.oldStart + shift
+		}
+		at := findHunk(lines, old, pos, want)
+		if at < 0 {
+			return nil, fmt.Errorf("hunk %d (@@ -%d,%d @@) does not match the file; read the file and make a new diff", n+1, h.oldStart, h.oldLen)
+		}
+		shift += at - want
+		out = append(out, lines[pos:at]...)
+		out = append(out, replacement...)
+		pos = at + len(old)
+	}
+	return append(out, lines[pos:]...), nil
+}
+
+// findHunk returns the position at or after from closest to want where lines holds
+// old, or -1
+func findHunk(lines, old []string, from, want int) int {
+	want = max(from, min(want, len(lines)-len(old)))
+	for d := 0; ; d++ {
+		before, after := want-d, want+d
+		if before < from && after > len(lines)-len(old) {
+			return -1
+		}
+		if after <= len(lines)-len(old) && slices.Equal(lines[after:after+len(old)], old) {
+			return after
+		}
+		if d > 0 && before >= from && slices.Equal(lines[before:before+len(old)], old) {
+			return before
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// applyPatchText parses patch and applies its single file to text
+func applyPatchText(t *testing.T, patch, text string) (string, filePatch) {
+	t.Helper()
+	patches, err := parsePatch(patch)
+	if err != nil {
+		t.Fatalf("parsePatch: %v\npatch:\n%s", err, patch)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("parsePatch returned %d files, want 1", len(patches))
+	}
+	lines, err := applyHunks(splitKeepNewlines(text), patches[0].hunks)
+	if err != nil {
+		t.Fatalf("applyHunks: %v\npatch:\n%s", err, patch)
+	}
+	return strings.Join(lines, ""), patches[0]
+}
+
+// numbered returns n lines "prefix1\n" to "prefixn\n"
+func numbered(prefix string, n int) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "%s%d\n", prefix, i)
+	}
+	return sb.String()
+}
+
+func TestDiffPatchRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		added   int
+		removed int
+	}{
+		{"unchanged", "a\nb\nc\n", "a\nb\nc\n", 0, 0},
+		{"insert", "a\nb\nc\n", "a\nb\nx\nc\n", 1, 0},
+		{"delete", "a\nb\nc\n", "a\nc\n", 0, 1},
+		{"replace", "a\nb\nc\n", "a\nB\nc\n", 1, 1},
+		{"prepend and append", "a\nb\n", "0\na\nb\nz\n", 2, 0},
+		{"from empty", "", "a\nb\n", 2, 0},
+		{"to empty", "a\nb\n", "", 0, 2},
+		{"separate hunks", numbered("l", 30), strings.Replace(strings.Replace(numbered("l", 30), "l3\n", "x3\n", 1), "l27\n", "x27\n", 1), 2, 2},
+		{"close changes share a hunk", numbered("l", 12), strings.Replace(strings.Replace(numbered("l", 12), "l3\n", "x3\n", 1), "l8\n", "x8\n", 1), 2, 2},
+		{"no newline before", "a\nb", "a\nb\n", 1, 1},
+		{"no newline after", "a\nb\n", "a\nb", 1, 1},
+		{"no newline on both sides", "a\nb", "a\nc", 1, 1},
+		{"no newline kept with other change", "a\nb\nc", "x\nb\nc", 1, 1},
+		{"crlf", "a\r\nb\r\nc\r\n", "a\r\nB\r\nc\r\n", 1, 1},
+		{"crlf to lf", "a\r\nb\r\n", "a\nb\n", 2, 2},
+		{"blank lines", "a\n\nb\n", "a\n\n\nb\n", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := splitKeepNewlines(tt.a), splitKeepNewlines(tt.b)
+			diff, added, removed := unifiedDiff("a/f.txt", "b/f.txt", a, b, DefaultDiffContext)
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("unifiedDiff counted +%d -%d, want +%d -%d\n%s", added, removed, tt.added, tt.removed, diff)
+			}
+			if tt.a == tt.b {
+				if strings.Contains(diff, "@@") {
+					t.Errorf("diff of equal texts has hunks:\n%s", diff)
+				}
+				return
+			}
+			got, p := applyPatchText(t, diff, tt.a)
+			if got != tt.b {
+				t.Errorf("round trip gave %q, want %q\npatch:\n%s", got, tt.b, diff)
+			}
+			if p.path != "f.txt" {
+				t.Errorf("patch path %q, want f.txt", p.path)
+			}
+		})
+	}
+}
+
+func TestDiffNoNewlineMarker(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		markers int
+	}{
+		{"old side", "a\nb", "a\nc\n", 1},
+		{"new side", "a\nb\n", "a\nc", 1},
+		{"both sides", "a\nb", "a\nc", 2},
+		{"neither side", "a\nb\n", "a\nc\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, _, _ := unifiedDiff("a/f", "b/f", splitKeepNewlines(tt.a), splitKeepNewlines(tt.b), DefaultDiffContext)
+			if n := strings.Count(diff, noNewline+"\n"); n != tt.markers {
+				t.Errorf("diff has %d %q markers, want %d:\n%s", n, noNewline, tt.markers, diff)
+			}
+			if got, _ := applyPatchText(t, diff, tt.a); got != tt.b {
+				t.Errorf("applied %q, want %q", got, tt.b)
+			}
+		})
+	}
+}
+
+func TestParsePatchDevNull(t *testing.T) {
+	tests := []struct {
+		name             string
+		patch            string
+		input, want      string
+		path             string
+		created, deleted bool
+	}{
+		{
+			name:    "create",
+			patch:   "--- /dev/null\n+++ b/dir/new.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:    "a\nb\n",
+			path:    "dir/new.txt",
+			created: true,
+		},
+		{
+			name:    "create without newline",
+			patch:   "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+a\n\\ No newline at end of file\n",
+			want:    "a",
+			path:    "new.txt",
+			created: true,
+		},
+		{
+			name:    "delete",
+			patch:   "--- a/old.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+			input:   "a\nb\n",
+			path:    "old.txt",
+			deleted: true,
+		},
+		{
+			name:    "git headers and timestamps",
+			patch:   "diff --git a/x.py b/x.py\nindex 1234567..89abcde 100644\n--- a/x.py\t2024-01-01 00:00:00\n+++ b/x.py\t2024-01-02 00:00:00\n@@ -1 +1 @@\n-old\n+new\n",
+			input:   "old\n",
+			want:    "new\n",
+			path:    "x.py",
+			created: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, p := applyPatchText(t, tt.patch, tt.input)
+			if got != tt.want {
+				t.Errorf("applied %q, want %q", got, tt.want)
+			}
+			if p.path != tt.path || p.created != tt.created || p.deleted != tt.deleted {
+				t.Errorf("got path %q created %v deleted %v, want %q %v %v", p.path, p.created, p.deleted, tt.path, tt.created, tt.deleted)
+			}
+		})
+	}
+
+	if _, err := parsePatch("--- /dev/null\n+++ /dev/null\n@@ -0,0 +0,0 @@\n"); err == nil {
+		t.Error("parsePatch accepted /dev/null on both sides")
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"no headers", "just text\n"},
+		{"no hunks", "--- a/f\n+++ b/f\n"},
+		{"malformed header", "--- a/f\n+++ b/f\n@@ -x +1 @@\n-a\n+b\n"},
+		{"hunk too short", "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n"},
+		{"hunk too long", "--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n-b\n+c\n"},
+		{"unexpected line", "--- a/f\n+++ b/f\n@@ -1 +1 @@\n*a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePatch(tt.patch); err == nil {
+				t.Errorf("parsePatch accepted %q", tt.patch)
+			}
+		})
+	}
+}
+
+func TestApplyHunksOffset(t *testing.T) {
+	base := numbered("l", 20)
+	patch := "--- a/f\n+++ b/f\n" +
+		"@@ -4,3 +4,3 @@\n l4\n-l5\n+x5\n l6\n" +
+		"@@ -14,3 +14,3 @@\n l14\n-l15\n+x15\n l16\n"
+	want := strings.Replace(strings.Replace(base, "l5\n", "x5\n", 1), "l15\n", "x15\n", 1)
+
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		errors bool
+	}{
+		{"in place", base, want, false},
+		{"moved down", "new1\nnew2\nnew3\n" + base, "new1\nnew2\nnew3\n" + want, false},
+		{"moved up", strings.Replace(base, "l1\nl2\n", "", 1), strings.Replace(want, "l1\nl2\n", "", 1), false},
+		{"second hunk moved further", strings.Replace(base, "l10\n", "l10\nextra1\nextra2\n", 1), strings.Replace(want, "l10\n", "l10\nextra1\nextra2\n", 1), false},
+		{"context changed", strings.Replace(base, "l6\n", "changed\n", 1), "", true},
+		{"target missing", numbered("other", 20), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patches, err := parsePatch(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines, err := applyHunks(splitKeepNewlines(tt.input), patches[0].hunks)
+			if tt.errors {
+				if err == nil {
+					t.Errorf("applyHunks applied a hunk that does not match")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(lines, ""); got != tt.want {
+				t.Errorf("applied:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyHunksNeverBeforePreviousHunk(t *testing.T) {
+	// The second hunk's lines only occur before the first hunk
+	patch := "--- a/f\n+++ b/f\n@@ -3 +3 @@\n-c\n+C\n@@ -5 +5 @@\n-a\n+A\n"
+	patches, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applyHunks(splitKeepNewlines("a\nb\nc\nd\ne\n"), patches[0].hunks); err == nil {
+		t.Error("applyHunks applied a hunk before the previous one")
+	}
+}
+
+func TestParsePatchCRLF(t *testing.T) {
+	// A whole patch with CRLF line ends, as pasted from Windows, for a CRLF file
+	patch := strings.ReplaceAll("--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n", "\n", "\r\n")
+	got, p := applyPatchText(t, patch, "a\r\nb\r\nc\r\n")
+	if want := "a\r\nB\r\nc\r\n"; got != want {
+		t.Errorf("applied %q, want %q", got, want)
+	}
+	if p.path != "f.txt" {
+		t.Errorf("path %q, want f.txt", p.path)
+	}
+
+	// Empty context lines whose space an editor stripped
+	patch = "--- a/f\r\n+++ b/f\r\n@@ -1,3 +1,3 @@\r\n a\r\n\r\n-b\r\n+B\r\n"
+	if got, _ := applyPatchText(t, patch, "a\r\n\r\nb\r\n"); got != "a\r\n\r\nB\r\n" {
+		t.Errorf("applied %q with a stripped empty context line", got)
+	}
+}
+
+func TestMyersDiffMinimal(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"abcabba", "cbabac", 5},
+		{"abc", "abc", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abc", "xyz", 6},
+		{"axbxc", "abc", 2},
+	}
+	for _, tt := range tests {
+		a, b := strings.Split(tt.a, ""), strings.Split(tt.b, "")
+		if tt.a == "" {
+			a = nil
+		}
+		if tt.b == "" {
+			b = nil
+		}
+		ops := myersDiff(a, b)
+		edits := 0
+		var rebuilt []string
+		for _, op := range ops {
+			switch op.kind {
+			case ' ':
+				if a[op.ai] != b[op.bi] {
+					t.Errorf("%q -> %q: kept line %d differs", tt.a, tt.b, op.ai)
+				}
+				rebuilt = append(rebuilt, b[op.bi])
+			case '+':
+				edits++
+				rebuilt = append(rebuilt, b[op.bi])
+			case '-':
+				edits++
+			}
+		}
+		if edits != tt.edits {
+			t.Errorf("%q -> %q: %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+		}
+		if got := strings.Join(rebuilt, ""); got != tt.b {
+			t.Errorf("%q -> %q: script builds %q", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestMyersDiffReplaceAllFallback(t *testing.T) {
+	if got := replaceAll(2, 1); len(got) != 3 ||
+		got[0] != (diffOp{'-', 0, 0}) || got[1] != (diffOp{'-', 1, 0}) || got[2] != (diffOp{'+', 2, 0}) {
+		t.Errorf("replaceAll(2, 1) = %v", got)
+	}
+
+	// Completely different inputs this large need more than maxDiffTrace to trace
+	const n = 3000
+	aText, bText := numbered("a", n), numbered("b", n)
+	a, b := splitKeepNewlines(aText), splitKeepNewlines(bText)
+	ops := myersDiff(a, b)
+	want := replaceAll(n, n)
+	if len(ops) != len(want) {
+		t.Fatalf("got %d ops, want the %d of replaceAll", len(ops), len(want))
+	}
+	for i := range ops {
+		if ops[i] != want[i] {
+			t.Fatalf("op %d is %v, want %v", i, ops[i], want[i])
+		}
+	}
+
+	diff, added, removed := unifiedDiff("a/f", "b/f", a, b, DefaultDiffContext)
+	if added != n || removed != n {
+		t.Errorf("counted +%d -%d, want +%d -%d", added, removed, n, n)
+	}
+	if got, _ := applyPatchText(t, diff, aText); got != bText {
+		t.Error("the replacement patch does not round-trip")
+	}
+}
@@ -0,0 +1,190 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// docsMarker prefixes the JSON printed by docsScript
+const docsMarker = "__JUMPBOOT_DOCS__"
+
+// Defaults for DocsOptions
+const (
+	DefaultDocsMaxMembers = 100
+	DefaultDocsMaxChars   = 20000
+)
+
+// docsScript imports the longest importable module prefix of the target, resolves the
+// rest as attributes and prints its documentation with inspect.
+// Arguments: target, max members, include private ("1"/"0"), max doc characters.
+const docsScript = `
+import importlib, inspect, json, sys
+target, max_members, include_private, max_chars = sys.argv[1], int(sys.argv[2]), sys.argv[3] == '1', int(sys.argv[4])
+
+def kind_of(o):
+    if inspect.ismodule(o): return 'module'
+    if inspect.isclass(o): return 'class'
+    if inspect.isbuiltin(o): return 'builtin'
+    if inspect.isfunction(o) or inspect.ismethod(o): return 'function'
+    if inspect.ismethoddescriptor(o) or inspect.isdatadescriptor(o): return 'descriptor'
+    if callable(o): return 'callable'
+    return type(o).__name__
+
+def signature(o):
+    if not callable(o):
+        return None
+    try:
+        return str(inspect.signature(o))
+    except (TypeError, ValueError):
+        return None
+
+parts = target.split('.')
+obj = module = None
+for i in range(len(parts), 0, -1):
+    name = '.'.join(parts[:i])
+    try:
+        module = importlib.import_module(name)
+    except ModuleNotFoundError as e:
+        # Only a missing target module means "try a shorter prefix"
+        if i > 1 and e.name and (name == e.name or name.startswith(e.name + '.')):
+            continue
+        raise
+    obj = module
+    for attr in parts[i:]:
+        obj = getattr(obj, attr)
+    break
+
+version = None
+try:
+    import importlib.metadata as md
+    dists = md.packages_distributions().get(parts[0]) or [parts[0]]
+    version = md.version(dists[0])
+except Exception:
+    pass
+
+source = None
+try:
+    source = inspect.getsourcefile(obj) or inspect.getfile(obj)
+except TypeError:
+    pass
+
+doc = inspect.getdoc(obj) or ''
+result = {
+    'target': target,
+    'kind': kind_of(obj),
+    'module': getattr(obj, '__module__', None) or module.__name__,
+    'version': version,
+    'file': source,
+    'signature': signature(obj),
+    'doc': doc[:max_chars],
+    'doc_truncated': len(doc) > max_chars,
+    'members': [],
+}
+
+if inspect.ismodule(obj) or inspect.isclass(obj):
+    public = getattr(obj, '__all__', None) if inspect.ismodule(obj) else None
+    try:
+        members = inspect.getmembers(obj)
+    except Exception:
+        members = [(n, getattr(obj, n, None)) for n in dir(obj)]
+    for name, value in members:
+        if public is not None and not include_private:
+            if name not in public:
+                continue
+        elif name.startswith('_') and not include_private:
+            continue
+        # Skip modules a package merely imports
+        if inspect.ismodule(obj) and inspect.ismodule(value) and not value.__name__.startswith(obj.__name__ + '.'):
+            continue
+        if len(result['members']) >= max_members:
+            result['members_truncated'] = True
+            break
+        member_doc = inspect.getdoc(value) if (callable(value) or inspect.ismodule(value)) else None
+        result['members'].append({
+            'name': name,
+            'kind': kind_of(value),
+            'signature': signature(value),
+            'summary': (member_doc or '').strip().split('\n')[0],
+        })
+
+print(%q + json.dumps(result, default=str))
+`
+
+// DocsOptions configures PackageDocs
+type DocsOptions struct {
+	MaxMembers     int  // members listed for modules and classes (default DefaultDocsMaxMembers)
+	MaxChars       int  // docstring characters returned (default DefaultDocsMaxChars)
+	IncludePrivate bool // also list members starting with "_" or missing from __all__
+}
+
+// MemberDoc summarizes a member of a module or class
+type MemberDoc struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Signature string `json:"signature,omitempty"`
+	Summary   string `json:"summary,omitempty"` // first line of the docstring
+}
+
+// PackageDocs is the documentation of an installed module or object
+type PackageDocs struct {
+	Target           string      `json:"target"`
+	Kind             string      `json:"kind"`
+	Module           string      `json:"module"`
+	Version          string      `json:"version,omitempty"` // installed distribution version
+	File             string      `json:"file,omitempty"`
+	Signature        string      `json:"signature,omitempty"`
+	Doc              string      `json:"doc"`
+	DocTruncated     bool        `json:"doc_truncated,omitempty"`
+	Members          []MemberDoc `json:"members"`
+	MembersTruncated bool        `json:"members_truncated,omitempty"`
+}
+
+// PackageDocs returns the docstring, signature and member listing of a module or
+// object (e.g. "pandas.DataFrame.merge") as installed in an environment. It runs in a
+// separate interpreter so importing the target cannot affect REPL sessions.
+func (m *Manager) PackageDocs(ctx context.Context, envID, target string, opts DocsOptions) (*PackageDocs, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxMembers <= 0 {
+		opts.MaxMembers = DefaultDocsMaxMembers
+	}
+	if opts.MaxChars <= 0 {
+		opts.MaxChars = DefaultDocsMaxChars
+	}
+	includePrivate := "0"
+	if opts.IncludePrivate {
+		includePrivate = "1"
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	output, err := runPython(ctx, env, "-c", fmt.Sprintf(docsScript, docsMarker), target,
+		strconv.Itoa(opts.MaxMembers), includePrivate, strconv.Itoa(opts.MaxChars))
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documentation for %s: %s", target, lastLine(output))
+	}
+
+	var docs PackageDocs
+	if err := decodeMarkedJSON(output, docsMarker, &docs); err != nil {
+		return nil, fmt.Errorf("failed to load documentation for %s: %w", target, err)
+	}
+	return &docs, nil
+}
+
+// lastLine returns the last non-empty line of output, e.g. the exception of a traceback
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,320 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDownloadMaxBytes caps workspace downloads unless the server sets another limit
+const DefaultDownloadMaxBytes int64 = 10 << 30
+
+// downloadProgressInterval throttles download progress reports
+const downloadProgressInterval = time.Second
+
+// downloadPartSuffix marks an unfinished download kept for resuming
+const downloadPartSuffix = ".part"
+
+// DownloadOptions configures DownloadToWorkspace
+type DownloadOptions struct {
+	Path      string // destination relative to the workspace (default: the URL's file name)
+	SHA256    string // expected hex digest of the whole file; a mismatch discards the download
+	MaxBytes  int64  // per-call size limit, capped by the server limit (0 = server limit)
+	Overwrite bool   // replace an existing file at Path
+
+	// Progress, if set, is called about once per downloadProgressInterval with the
+	// bytes received so far and the total size (-1 if the server did not report it)
+	Progress func(downloaded, total int64)
+}
+
+// DownloadInfo describes a file downloaded into a workspace
+type DownloadInfo struct {
+	URL             string  `json:"url"`
+	Path            string  `json:"path"`
+	FullPath        string  `json:"full_path"`
+	SizeBytes       int64   `json:"size_bytes"`
+	SHA256          string  `json:"sha256"`
+	Verified        bool    `json:"verified"`               // the expected sha256 was given and matched
+	ResumedFrom     int64   `json:"resumed_from,omitempty"` // bytes kept from an earlier interrupted download
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// SetDownloadPolicy sets the URL prefixes workspace downloads may fetch from (empty =
+// any http(s) URL) and the largest file they may fetch (0 = DefaultDownloadMaxBytes)
+func (m *Manager) SetDownloadPolicy(allow []string, maxBytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.downloadAllow = allow
+	m.downloadMaxBytes = maxBytes
+}
+
+// DownloadToWorkspace fetches a URL into an environment's workspace. The data is
+// written to "<path>.part" and renamed when complete, so an interrupted or cancelled
+// download can be resumed by calling again with the same URL and path: the server is
+// asked for the remaining bytes with a Range request. With opts.SHA256 the whole file
+// is verified before it is moved into place.
+func (m *Manager) DownloadToWorkspace(ctx context.Context, envID, rawURL string, opts DownloadOptions) (*DownloadInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid download URL: %s", rawURL)
+	}
+
+	m.mu.RLock()
+	env, ok := m.environments[envID]
+	allow, limit := m.downloadAllow, m.downloadMaxBytes
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, notFound("environment", envID)
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if len(allow) > 0 {
+		allowed := false
+		for _, prefix := range allow {
+			if strings.HasPrefix(rawURL, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("download URL not allowed by server policy: %s", rawURL)
+		}
+	}
+	if limit <= 0 {
+		limit = DefaultDownloadMaxBytes
+	}
+	if opts.MaxBytes > 0 && opts.MaxBytes < limit {
+		limit = opts.MaxBytes
+	}
+
+	expected := strings.ToLower(strings.TrimSpace(opts.SHA256))
+	if expected != "" {
+		if b, err := hex.DecodeString(expected); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid sha256: must be 64 hex characters")
+		}
+	}
+
+	relPath := opts.Path
+	if relPath == "" {
+		relPath = path.Base(u.Path)
+		if relPath == "" || relPath == "/" || relPath == "." {
+			return nil, fmt.Errorf("cannot derive a file name from the URL; pass path")
+		}
+	}
+	destPath, err := safeJoinPath(env.WorkspaceDir, relPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkMountWritable(env, destPath); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(destPath); err == nil && !opts.Overwrite {
+		return nil, fmt.Errorf("file already exists: %s (set overwrite to replace it)", relPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	start := time.Now()
+	partPath := destPath + downloadPartSuffix
+	info := &DownloadInfo{URL: rawURL, Path: filepath.ToSlash(relPath), FullPath: destPath}
+
+	size, resumedFrom, err := fetchToPart(ctx, rawURL, partPath, limit, opts.Progress)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Keep the partial file so the download can be resumed
+			return nil, fmt.Errorf("%w: %w (call again to resume)", ErrCancelled, ctx.Err())
+		}
+		return nil, err
+	}
+	info.SizeBytes = size
+	info.ResumedFrom = resumedFrom
+
+	digest, err := fileSHA256(partPath)
+	if err != nil {
+		return nil, err
+	}
+	info.SHA256 = digest
+	if expected != "" {
+		if digest != expected {
+			os.Remove(partPath)
+			return nil, fmt.Errorf("sha256 mismatch: expected %s, got %s (download discarded)", expected, digest)
+		}
+		info.Verified = true
+	}
+
+	if err := os.Rename(partPath, destPath); err != nil {
+		return nil, fmt.Errorf("failed to move download into place: %w", err)
+	}
+	info.DurationSeconds = time.Since(start).Seconds()
+	return info, nil
+}
+
+// fetchToPart downloads rawURL into partPath, resuming from the bytes already there.
+// It returns the final size and how many bytes were kept from an earlier attempt.
+func fetchToPart(ctx context.Context, rawURL, partPath string, limit int64, progress func(int64, int64)) (int64, int64, error) {
+	var offset int64
+	if fi, err := os.Stat(partPath); err == nil {
+		offset = fi.Size()
+	}
+
+	// At most two attempts: a resume the server rejects falls back to a full download
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid download request: %w", err)
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, 0, fmt.Errorf("download failed: %w", err)
+		}
+
+		var total int64 = -1
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp) == offset:
+			if resp.ContentLength >= 0 {
+				total = offset + resp.ContentLength
+			}
+		case (resp.StatusCode == http.StatusRequestedRangeNotSatisfiable || resp.StatusCode == http.StatusPartialContent) && offset > 0:
+			resp.Body.Close()
+			// The part file may already hold the whole file
+			if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && contentRangeTotal(resp) == offset {
+				return offset, offset, nil
+			}
+			if attempt > 0 {
+				return 0, 0, fmt.Errorf("download failed: %s", resp.Status)
+			}
+			offset = 0
+			continue
+		case resp.StatusCode == http.StatusOK:
+			// Full content: the server ignored the range or there was nothing to resume
+			offset = 0
+			total = resp.ContentLength
+		default:
+			resp.Body.Close()
+			return 0, 0, fmt.Errorf("download failed: %s", resp.Status)
+		}
+
+		size, err := writePart(resp.Body, partPath, offset, total, limit, progress)
+		resp.Body.Close()
+		return size, offset, err
+	}
+}
+
+// writePart appends body to partPath (truncating it unless offset > 0) while
+// enforcing the size limit and reporting progress
+func writePart(body io.Reader, partPath string, offset, total, limit int64, progress func(int64, int64)) (int64, error) {
+	if total > limit {
+		return 0, fmt.Errorf("file is %d bytes, larger than the %d byte download limit", total, limit)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open download file: %w", err)
+	}
+	defer f.Close()
+
+	written := offset
+	lastReport := time.Now()
+	buf := make([]byte, 256<<10)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if written+int64(n) > limit {
+				f.Close()
+				os.Remove(partPath)
+				return 0, fmt.Errorf("download exceeded the %d byte limit", limit)
+			}
+			if _, err := f.Write(buf[:n]); err != nil {
+				return 0, fmt.Errorf("failed to write download file: %w", err)
+			}
+			written += int64(n)
+			if progress != nil && time.Since(lastReport) >= downloadProgressInterval {
+				progress(written, total)
+				lastReport = time.Now()
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return 0, fmt.Errorf("download interrupted after %d bytes: %w", written, readErr)
+		}
+	}
+	if total >= 0 && written != total {
+		return 0, fmt.Errorf("download incomplete: got %d of %d bytes", written, total)
+	}
+	if progress != nil {
+		progress(written, total)
+	}
+	return written, nil
+}
+
+// contentRangeStart returns the first byte position of a "bytes a-b/n" Content-Range
+// header, or -1
+func contentRangeStart(resp *http.Response) int64 {
+	spec, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// contentRangeTotal returns the complete length of a "bytes */n" Content-Range
+// header, or -1
+func contentRangeTotal(resp *http.Response) int64 {
+	_, totalStr, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(totalStr, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// fileSHA256 returns the hex sha256 digest of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read download: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read download: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
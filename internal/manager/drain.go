@@ -0,0 +1,208 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultDrainGrace is how long a drain waits for in-flight tool calls and jobs
+const DefaultDrainGrace = 30 * time.Second
+
+// drainPollInterval is how often a drain checks whether in-flight work has finished
+const drainPollInterval = 100 * time.Millisecond
+
+// drainCheckpointTimeout bounds checkpointing one REPL session while draining
+const drainCheckpointTimeout = 30 * time.Second
+
+// DrainReportFile is written to the base directory once a drain completes
+const DrainReportFile = "drain.json"
+
+// ErrDraining is returned for tool calls that arrive while the server drains
+var ErrDraining = errors.New("the server is draining for shutdown and accepts no new tool calls; use another server or retry after it restarts")
+
+// DrainInfo describes a drain of the server before shutdown
+type DrainInfo struct {
+	StartedAt     time.Time         `json:"started_at"`
+	GraceSeconds  float64           `json:"grace_seconds"`
+	InFlightCalls int64             `json:"in_flight_calls"` // still running, or left running when the grace period expired
+	RunningJobs   int               `json:"running_jobs"`
+	Done          bool              `json:"done"`                // the server is shutting down
+	TimedOut      bool              `json:"timed_out,omitempty"` // the grace period expired with work still running
+	Checkpoints   []DrainCheckpoint `json:"checkpoints,omitempty"`
+	Report        string            `json:"report,omitempty"` // file the final DrainInfo was written to
+}
+
+// DrainCheckpoint is a REPL session checkpointed while draining, or why it was not
+type DrainCheckpoint struct {
+	SessionID string `json:"session_id"`
+	EnvID     string `json:"env_id"`
+	Path      string `json:"path,omitempty"` // relative to the workspace, for restore_repl
+	Error     string `json:"error,omitempty"`
+}
+
+// SetDrainPolicy sets how long a drain waits for in-flight work by default and whether
+// it checkpoints REPL sessions
+func (m *Manager) SetDrainPolicy(grace time.Duration, checkpointREPLs bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.drainGrace = grace
+	m.drainCheckpoints = checkpointREPLs
+}
+
+// BeginToolCall records a tool call in flight, refusing it with ErrDraining while the
+// server drains. done must be called once the call has been handled.
+func (m *Manager) BeginToolCall() (done func(), err error) {
+	// Counted before the check, so a drain that starts in between waits for the call
+	m.inFlight.Add(1)
+	if m.draining.Load() {
+		m.inFlight.Add(-1)
+		return nil, ErrDraining
+	}
+	return func() { m.inFlight.Add(-1) }, nil
+}
+
+// RequestDrain starts a drain for the server_drain tool. With session isolation or
+// roles it requires the admin role.
+func (m *Manager) RequestDrain(ctx context.Context, grace time.Duration) (*DrainInfo, error) {
+	if caller := CallerFromContext(ctx); caller.Role != "" || m.SessionIsolation() {
+		if err := m.checkAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return m.Drain(grace), nil
+}
+
+// Drain starts draining the server: new tool calls are refused, in-flight calls and
+// background jobs get up to grace (negative = the policy's grace) to finish, idle REPL
+// sessions are checkpointed if the policy says so, and the final DrainInfo is written to
+// DrainReportFile. Drained is closed once that is done; the caller then shuts down.
+// Draining again returns the drain already under way.
+func (m *Manager) Drain(grace time.Duration) *DrainInfo {
+	m.drainMu.Lock()
+	if m.drain == nil {
+		m.mu.RLock()
+		if grace < 0 {
+			grace = m.drainGrace
+		}
+		checkpoint := m.drainCheckpoints
+		m.mu.RUnlock()
+
+		m.drain = &DrainInfo{StartedAt: time.Now(), GraceSeconds: grace.Seconds()}
+		m.draining.Store(true)
+		go m.runDrain(grace, checkpoint)
+	}
+	m.drainMu.Unlock()
+	return m.DrainStatus()
+}
+
+// DrainStatus returns the drain under way, or nil when the server is not draining
+func (m *Manager) DrainStatus() *DrainInfo {
+	m.drainMu.Lock()
+	defer m.drainMu.Unlock()
+	if m.drain == nil {
+		return nil
+	}
+	info := *m.drain
+	if !info.Done {
+		info.InFlightCalls = m.inFlight.Load()
+		m.mu.RLock()
+		info.RunningJobs = m.runningJobs()
+		m.mu.RUnlock()
+	}
+	return &info
+}
+
+// Drained returns a channel that is closed once a drain has completed
+func (m *Manager) Drained() <-chan struct{} {
+	return m.drained
+}
+
+// runDrain waits for in-flight work, checkpoints REPLs and writes the drain report
+func (m *Manager) runDrain(grace time.Duration, checkpoint bool) {
+	deadline := time.Now().Add(grace)
+	var calls int64
+	var jobs int
+	for {
+		m.mu.RLock()
+		calls, jobs = m.inFlight.Load(), m.runningJobs()
+		m.mu.RUnlock()
+		if (calls == 0 && jobs == 0) || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(drainPollInterval)
+	}
+
+	var checkpoints []DrainCheckpoint
+	if checkpoint {
+		checkpoints = m.checkpointREPLs()
+	}
+
+	m.drainMu.Lock()
+	m.drain.InFlightCalls, m.drain.RunningJobs = calls, jobs
+	m.drain.TimedOut = calls > 0 || jobs > 0
+	m.drain.Checkpoints = checkpoints
+	m.drain.Done = true
+	report := filepath.Join(m.baseDir, DrainReportFile)
+	if data, err := json.MarshalIndent(m.drain, "", "  "); err == nil {
+		if err := os.WriteFile(report, data, 0644); err == nil {
+			m.drain.Report = report
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write drain report: %v\n", err)
+		}
+	}
+	m.drainMu.Unlock()
+	close(m.drained)
+}
+
+// checkpointREPLs saves every idle REPL session to its default checkpoint path.
+// Sessions still executing when the grace period expired are skipped.
+func (m *Manager) checkpointREPLs() []DrainCheckpoint {
+	m.mu.RLock()
+	repls := make([]*ManagedREPL, 0, len(m.replSessions))
+	for _, repl := range m.replSessions {
+		repls = append(repls, repl)
+	}
+	m.mu.RUnlock()
+
+	checkpoints := make([]DrainCheckpoint, 0, len(repls))
+	for _, repl := range repls {
+		cp := DrainCheckpoint{SessionID: repl.ID, EnvID: repl.EnvID}
+		repl.activityMu.Lock()
+		busy := repl.executing > 0
+		repl.activityMu.Unlock()
+		if busy {
+			cp.Error = "still executing"
+			checkpoints = append(checkpoints, cp)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), drainCheckpointTimeout)
+		info, err := m.CheckpointREPL(ctx, repl.ID, "")
+		cancel()
+		if err != nil {
+			cp.Error = err.Error()
+		} else {
+			cp.Path = info.Path
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints
+}
+
+// runningJobs counts the background jobs still running or queued. Callers must hold m.mu.
+func (m *Manager) runningJobs() int {
+	running := 0
+	for _, job := range m.jobs {
+		job.mu.Lock()
+		if job.status == JobRunning || job.status == JobCancelling || job.status == JobQueued {
+			running++
+		}
+		job.mu.Unlock()
+	}
+	return running
+}
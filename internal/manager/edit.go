@@ -0,0 +1,174 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FileLines is a range of lines of a workspace file
+type FileLines struct {
+	Filename   string `json:"filename"`
+	Content    string `json:"content"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"` // last line returned; start_line - 1 if none
+	TotalLines int    `json:"total_lines"`
+}
+
+// FileEdit is a change made by EditWorkspaceFile: either lines StartLine to EndLine
+// replaced by NewContent, or OldString replaced by NewString
+type FileEdit struct {
+	StartLine  int    // first line to replace, from 1
+	EndLine    int    // last line to replace; StartLine - 1 inserts before StartLine
+	NewContent string // lines replacing the range
+	OldString  string // text to find
+	NewString  string // its replacement
+	ReplaceAll bool   // replace every occurrence of OldString instead of requiring one
+}
+
+// EditResult describes a change made by EditWorkspaceFile
+type EditResult struct {
+	Filename    string `json:"filename"`
+	Occurrences int    `json:"occurrences,omitempty"` // replaced matches of old_string
+	StartLine   int    `json:"start_line"`            // first changed line of the new file
+	EndLine     int    `json:"end_line"`              // last changed line of the new file
+	TotalLines  int    `json:"total_lines"`           // lines of the new file
+}
+
+// ReadWorkspaceLines returns lines start to end (from 1, inclusive) of a workspace
+// file. end 0 reads to the end of the file; ranges past the end are cut.
+func (m *Manager) ReadWorkspaceLines(envID, filename string, start, end int) (*FileLines, error) {
+	if start < 1 {
+		start = 1
+	}
+	if end != 0 && end < start {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("end_line %d is before start_line %d", end, start))
+	}
+	content, err := m.ReadWorkspaceFile(envID, filename)
+	if err != nil {
+		return nil, err
+	}
+	lines := splitKeepNewlines(content)
+	if end == 0 || end > len(lines) {
+		end = len(lines)
+	}
+	result := &FileLines{Filename: filename, StartLine: start, EndLine: start - 1, TotalLines: len(lines)}
+	if start <= end {
+		result.Content = strings.Join(lines[start-1:end], "")
+		result.EndLine = end
+	}
+	return result, nil
+}
+
+// EditWorkspaceFile changes part of a workspace file: a range of lines, or the text
+// OldString, which must occur exactly once unless ReplaceAll is set
+func (m *Manager) EditWorkspaceFile(envID, filename string, edit FileEdit) (*EditResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	filePath, err := safeJoinPath(env.WorkspaceDir, filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkMountWritable(env, filePath); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	content := string(data)
+	result := &EditResult{Filename: filename}
+	if edit.OldString != "" {
+		content, err = replaceString(content, edit, result)
+	} else {
+		content, err = replaceLines(content, edit, result)
+	}
+	if err != nil {
+		return nil, WithErrorCode(CodeInvalidArgument, err)
+	}
+	result.TotalLines = len(splitKeepNewlines(content))
+
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	return result, nil
+}
+
+// replaceLines replaces the lines of edit's range in content
+func replaceLines(content string, edit FileEdit, result *EditResult) (string, error) {
+	lines := splitKeepNewlines(content)
+	if edit.StartLine < 1 || edit.StartLine > len(lines)+1 {
+		return "", fmt.Errorf("start_line must be between 1 and %d (the file has %d lines)", len(lines)+1, len(lines))
+	}
+	if edit.EndLine < edit.StartLine-1 || edit.EndLine > len(lines) {
+		return "", fmt.Errorf("end_line must be between %d and %d", edit.StartLine-1, len(lines))
+	}
+
+	replacement := edit.NewContent
+	// Keep the line after the range on its own line; the file's last line may stay
+	// without a newline
+	atEnd := edit.EndLine == len(lines) && !strings.HasSuffix(content, "\n")
+	if replacement != "" && !strings.HasSuffix(replacement, "\n") && !atEnd {
+		replacement += "\n"
+	}
+	if edit.StartLine > len(lines) && len(lines) > 0 && !strings.HasSuffix(content, "\n") {
+		// Appending after a last line without a newline
+		lines[len(lines)-1] += "\n"
+	}
+	added := splitKeepNewlines(replacement)
+
+	result.StartLine = edit.StartLine
+	result.EndLine = edit.StartLine + len(added) - 1
+	var sb strings.Builder
+	for _, line := range lines[:edit.StartLine-1] {
+		sb.WriteString(line)
+	}
+	sb.WriteString(replacement)
+	for _, line := range lines[edit.EndLine:] {
+		sb.WriteString(line)
+	}
+	return sb.String(), nil
+}
+
+// replaceString replaces edit.OldString in content, recording where
+func replaceString(content string, edit FileEdit, result *EditResult) (string, error) {
+	// Offsets of the matches, as strings.ReplaceAll finds them
+	var offsets []int
+	for i := 0; ; i += len(edit.OldString) {
+		j := strings.Index(content[i:], edit.OldString)
+		if j < 0 {
+			break
+		}
+		i += j
+		offsets = append(offsets, i)
+	}
+	result.Occurrences = len(offsets)
+	switch {
+	case len(offsets) == 0:
+		return "", fmt.Errorf("old_string not found in %s", result.Filename)
+	case len(offsets) > 1 && !edit.ReplaceAll:
+		lines := make([]string, len(offsets))
+		for n, offset := range offsets {
+			lines[n] = strconv.Itoa(strings.Count(content[:offset], "\n") + 1)
+		}
+		return "", fmt.Errorf("old_string occurs %d times (at lines %s); add surrounding text to make it unique or set replace_all", len(offsets), strings.Join(lines, ", "))
+	}
+
+	updated := strings.ReplaceAll(content, edit.OldString, edit.NewString)
+	// Where the last replacement ends in the new content
+	last := offsets[len(offsets)-1]
+	lastEnd := last + (len(offsets)-1)*(len(edit.NewString)-len(edit.OldString)) + len(edit.NewString)
+	result.StartLine = strings.Count(updated[:offsets[0]], "\n") + 1
+	result.EndLine = max(result.StartLine, strings.Count(strings.TrimSuffix(updated[:lastEnd], "\n"), "\n")+1)
+	return updated, nil
+}
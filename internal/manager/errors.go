@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+)
+
+// Error codes of failed responses, so agents can tell "environment not found" from
+// "pip failed" without parsing messages
+const (
+	CodeNotFound         = "NOT_FOUND"         // the environment, session, process or file does not exist
+	CodeInvalidArgument  = "INVALID_ARGUMENT"  // a parameter is missing or malformed
+	CodeBusy             = "BUSY"              // the environment is in use by another operation
+	CodeLocked           = "LOCKED"            // the environment is read-only
+	CodeTimeout          = "TIMEOUT"           // the operation ran out of time
+	CodeCancelled        = "CANCELLED"         // the client cancelled the call
+	CodeQuotaExceeded    = "QUOTA_EXCEEDED"    // the server is out of environment slots or disk
+	CodeRateLimited      = "RATE_LIMITED"      // the caller made too many calls
+	CodePermissionDenied = "PERMISSION_DENIED" // the caller's role or the command policy forbids it
+	CodeUnavailable      = "UNAVAILABLE"       // the server is draining or shutting down
+	CodeUnsupported      = "UNSUPPORTED"       // not possible in this environment or on this platform
+	CodeExecFailed       = "EXEC_FAILED"       // a command such as pip or the user's code exited with an error
+	CodeUnknown          = "UNKNOWN"           // anything else
+)
+
+// retriableCodes are the codes whose calls may succeed unchanged when repeated later
+var retriableCodes = map[string]bool{
+	CodeBusy:        true,
+	CodeTimeout:     true,
+	CodeRateLimited: true,
+	CodeUnavailable: true,
+}
+
+// ErrNotFound matches every NotFoundError
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError reports a missing environment, session, process or other object
+type NotFoundError struct {
+	Kind string // e.g. "environment" or "REPL session"
+	ID   string
+}
+
+func (e *NotFoundError) Error() string { return e.Kind + " not found: " + e.ID }
+
+// Is makes errors.Is(err, ErrNotFound) hold
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// notFound returns a NotFoundError
+func notFound(kind, id string) error {
+	return &NotFoundError{Kind: kind, ID: id}
+}
+
+// codedError gives an error an explicit code
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string     { return e.err.Error() }
+func (e *codedError) Unwrap() error     { return e.err }
+func (e *codedError) ErrorCode() string { return e.code }
+
+// WithErrorCode returns err with the given code, which ErrorCode reports instead of
+// the code it would derive
+func WithErrorCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// ErrorCode classifies an error for Response.ErrorCode and reports whether repeating
+// the same call later may succeed. Explicit codes (WithErrorCode or an ErrorCode
+// method) win over the sentinel errors of this package.
+func ErrorCode(err error) (code string, retriable bool) {
+	code = errorCode(err)
+	return code, retriableCodes[code]
+}
+
+// errorCode returns the code of err
+func errorCode(err error) string {
+	var coded interface{ ErrorCode() string }
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &coded):
+		return coded.ErrorCode()
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, ErrCancelled), errors.Is(err, context.Canceled):
+		return CodeCancelled
+	case errors.Is(err, ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return CodeNotFound
+	case errors.Is(err, ErrEnvironmentBusy):
+		return CodeBusy
+	case errors.Is(err, ErrEnvironmentLocked):
+		return CodeLocked
+	case errors.Is(err, ErrAdminRequired), errors.Is(err, ErrCommandNotAllowed), errors.Is(err, fs.ErrPermission):
+		return CodePermissionDenied
+	case errors.Is(err, ErrDraining):
+		return CodeUnavailable
+	case errors.Is(err, ErrNotIsolated), errors.Is(err, ErrNetworkRestricted), errors.Is(err, errors.ErrUnsupported):
+		return CodeUnsupported
+	case errors.As(err, &exitErr):
+		return CodeExecFailed
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,552 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// experimentsFile is the SQLite database under the base directory holding experiment runs
+const experimentsFile = "experiments.db"
+
+// Limits of experiment queries
+const (
+	DefaultExperimentLimit = 20
+	MaxExperimentLimit     = 1000
+	maxExperimentNameLen   = 128
+)
+
+// Statuses of experiment runs
+const (
+	ExperimentRunning   = "running"
+	ExperimentCompleted = "completed"
+	ExperimentFailed    = "failed"
+)
+
+// experimentSchema creates the tables of the experiment store. Params hold JSON
+// values, compared as canonical JSON text.
+const experimentSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id         TEXT PRIMARY KEY,
+	experiment TEXT NOT NULL,
+	name       TEXT NOT NULL DEFAULT '',
+	env_id     TEXT NOT NULL DEFAULT '',
+	status     TEXT NOT NULL,
+	artifacts  TEXT NOT NULL DEFAULT '[]',
+	manifest   TEXT NOT NULL DEFAULT '',
+	notes      TEXT NOT NULL DEFAULT '',
+	owner      TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_by_experiment ON runs (experiment, created_at);
+CREATE TABLE IF NOT EXISTS params (
+	run_id TEXT NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	key    TEXT NOT NULL,
+	value  TEXT NOT NULL,
+	PRIMARY KEY (run_id, key)
+);
+CREATE TABLE IF NOT EXISTS metrics (
+	run_id TEXT NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	key    TEXT NOT NULL,
+	value  REAL NOT NULL,
+	PRIMARY KEY (run_id, key)
+);
+`
+
+// ExperimentRun is a recorded run of an experiment
+type ExperimentRun struct {
+	ID         string             `json:"id"`
+	Experiment string             `json:"experiment"`
+	Name       string             `json:"name,omitempty"`
+	EnvID      string             `json:"env_id,omitempty"`
+	Status     string             `json:"status"`
+	Params     map[string]any     `json:"params"`
+	Metrics    map[string]float64 `json:"metrics"`
+	Artifacts  []string           `json:"artifacts,omitempty"` // workspace paths or URIs
+	Manifest   string             `json:"manifest,omitempty"`  // run manifest of a reproducible run
+	Notes      string             `json:"notes,omitempty"`
+	Owner      string             `json:"owner,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
+
+// ExperimentLog describes a run to record, or the changes to a recorded one
+type ExperimentLog struct {
+	RunID      string             // update this run instead of recording a new one
+	Experiment string             // required for new runs
+	Name       string             // replaces the name if set
+	EnvID      string             // environment whose workspace holds the artifacts
+	Status     string             // replaces the status if set (new runs: completed)
+	Params     map[string]any     // merged into the run's params
+	Metrics    map[string]float64 // merged into the run's metrics
+	Artifacts  []string           // added to the run's artifacts
+	Manifest   string             // replaces the manifest if set
+	Notes      string             // replaces the notes if set
+}
+
+// ExperimentQuery selects experiment runs. Without Experiment and RunIDs, experiments
+// are listed instead.
+type ExperimentQuery struct {
+	Experiment string
+	RunIDs     []string
+	Params     map[string]any // runs whose params have these values
+	Status     string
+	SortBy     string // metric to sort by; runs without it come last (default: newest first)
+	Ascending  bool
+	Limit      int // 0 = DefaultExperimentLimit
+}
+
+// ExperimentSummary describes an experiment in a listing
+type ExperimentSummary struct {
+	Name      string    `json:"name"`
+	Runs      int       `json:"runs"`
+	LastRunAt time.Time `json:"last_run_at"`
+	Metrics   []string  `json:"metrics,omitempty"` // metric names logged by its runs
+}
+
+// MetricSummary compares one metric across the returned runs
+type MetricSummary struct {
+	Count    int     `json:"count"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Mean     float64 `json:"mean"`
+	MinRunID string  `json:"min_run_id"`
+	MaxRunID string  `json:"max_run_id"`
+}
+
+// ExperimentQueryResult is the result of QueryExperiments
+type ExperimentQueryResult struct {
+	Experiments   []ExperimentSummary      `json:"experiments,omitempty"`
+	Runs          []*ExperimentRun         `json:"runs,omitempty"`
+	Total         int                      `json:"total"`                    // matching runs or experiments, before the limit
+	VaryingParams []string                 `json:"varying_params,omitempty"` // params that differ between the returned runs
+	Metrics       map[string]MetricSummary `json:"metrics,omitempty"`
+}
+
+// experimentDB opens the experiment store on first use
+func (m *Manager) experimentDB() (*sql.DB, error) {
+	m.experimentsMu.Lock()
+	defer m.experimentsMu.Unlock()
+	if m.experiments != nil {
+		return m.experiments, nil
+	}
+	path := filepath.Join(m.baseDir, experimentsFile)
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_foreign_keys=on&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open experiment store: %w", err)
+	}
+	// One connection serializes writers, which SQLite would otherwise make wait
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(experimentSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open experiment store %s: %w", path, err)
+	}
+	m.experiments = db
+	return db, nil
+}
+
+// closeExperiments closes the experiment store if it is open
+func (m *Manager) closeExperiments() {
+	m.experimentsMu.Lock()
+	defer m.experimentsMu.Unlock()
+	if m.experiments != nil {
+		m.experiments.Close()
+		m.experiments = nil
+	}
+}
+
+// ownerClause restricts a query to runs visible to the caller in ctx, like
+// canAccess. It returns "" when every run is visible.
+func (m *Manager) ownerClause(ctx context.Context) (string, []any) {
+	m.mu.RLock()
+	isolation := m.sessionIsolation
+	m.mu.RUnlock()
+	caller := CallerFromContext(ctx)
+	if !isolation || caller.Admin {
+		return "", nil
+	}
+	return " AND (r.owner = '' OR r.owner = ?)", []any{caller.SessionID}
+}
+
+// LogExperiment records a run of an experiment with its parameters, metrics and
+// artifacts, or adds to a recorded run. Runs are kept in a SQLite database in the base
+// directory, so they outlive environments and server restarts.
+func (m *Manager) LogExperiment(ctx context.Context, entry ExperimentLog) (*ExperimentRun, error) {
+	if entry.Status != "" && entry.Status != ExperimentRunning && entry.Status != ExperimentCompleted && entry.Status != ExperimentFailed {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("unknown status %q (use %s, %s or %s)", entry.Status, ExperimentRunning, ExperimentCompleted, ExperimentFailed))
+	}
+	for key, value := range entry.Metrics {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("metric %s is not a finite number", key))
+		}
+	}
+	if err := m.checkExperimentFiles(entry); err != nil {
+		return nil, err
+	}
+	db, err := m.experimentDB()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	runID := entry.RunID
+	if runID == "" {
+		if entry.Experiment == "" || len(entry.Experiment) > maxExperimentNameLen || !utf8.ValidString(entry.Experiment) {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("experiment must be a name of 1 to %d bytes", maxExperimentNameLen))
+		}
+		if entry.Status == "" {
+			entry.Status = ExperimentCompleted
+		}
+		runID = uuid.New().String()
+		_, err = tx.ExecContext(ctx, `INSERT INTO runs (id, experiment, name, env_id, status, manifest, notes, owner, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			runID, entry.Experiment, entry.Name, entry.EnvID, entry.Status, entry.Manifest, entry.Notes, ownerFor(ctx), now.UnixNano(), now.UnixNano())
+		if err != nil {
+			return nil, fmt.Errorf("failed to record run: %w", err)
+		}
+	} else {
+		run, err := m.loadExperimentRun(ctx, tx, runID)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Experiment != "" && entry.Experiment != run.Experiment {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("run %s belongs to experiment %s", runID, run.Experiment))
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE runs SET
+			name = COALESCE(NULLIF(?, ''), name),
+			env_id = COALESCE(NULLIF(?, ''), env_id),
+			status = COALESCE(NULLIF(?, ''), status),
+			manifest = COALESCE(NULLIF(?, ''), manifest),
+			notes = COALESCE(NULLIF(?, ''), notes),
+			updated_at = ?
+			WHERE id = ?`,
+			entry.Name, entry.EnvID, entry.Status, entry.Manifest, entry.Notes, now.UnixNano(), runID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update run: %w", err)
+		}
+		entry.Artifacts = append(run.Artifacts, entry.Artifacts...)
+	}
+
+	for key, value := range entry.Params {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("param %s: %w", key, err))
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO params (run_id, key, value) VALUES (?, ?, ?)`, runID, key, string(data)); err != nil {
+			return nil, fmt.Errorf("failed to record params: %w", err)
+		}
+	}
+	for key, value := range entry.Metrics {
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO metrics (run_id, key, value) VALUES (?, ?, ?)`, runID, key, value); err != nil {
+			return nil, fmt.Errorf("failed to record metrics: %w", err)
+		}
+	}
+	if len(entry.Artifacts) > 0 {
+		slices.Sort(entry.Artifacts)
+		data, _ := json.Marshal(slices.Compact(entry.Artifacts))
+		if _, err := tx.ExecContext(ctx, `UPDATE runs SET artifacts = ? WHERE id = ?`, string(data), runID); err != nil {
+			return nil, fmt.Errorf("failed to record artifacts: %w", err)
+		}
+	}
+
+	run, err := m.loadExperimentRun(ctx, tx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to record run: %w", err)
+	}
+	return run, nil
+}
+
+// checkExperimentFiles checks that the workspace artifacts and manifest of a log entry
+// exist. URIs are not checked.
+func (m *Manager) checkExperimentFiles(entry ExperimentLog) error {
+	var paths []string
+	for _, artifact := range entry.Artifacts {
+		if !strings.Contains(artifact, "://") {
+			paths = append(paths, artifact)
+		}
+	}
+	if entry.Manifest != "" {
+		paths = append(paths, entry.Manifest)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	if entry.EnvID == "" {
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("env_id is required for workspace artifacts and manifests"))
+	}
+	env, err := m.GetEnvironment(entry.EnvID)
+	if err != nil {
+		return err
+	}
+	if env.WorkspaceDir == "" {
+		return fmt.Errorf("no workspace created for environment: %s", entry.EnvID)
+	}
+	for _, path := range paths {
+		full, err := safeJoinPath(env.WorkspaceDir, path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(full); err != nil {
+			return WithErrorCode(CodeNotFound, fmt.Errorf("file not found in workspace: %s", path))
+		}
+	}
+	return nil
+}
+
+// loadExperimentRun reads a run visible to the caller in ctx
+func (m *Manager) loadExperimentRun(ctx context.Context, tx *sql.Tx, runID string) (*ExperimentRun, error) {
+	clause, args := m.ownerClause(ctx)
+	runs, _, err := m.scanExperimentRuns(ctx, tx, `SELECT r.id, r.experiment, r.name, r.env_id, r.status, r.artifacts, r.manifest, r.notes, r.owner, r.created_at, r.updated_at, 1
+		FROM runs r WHERE r.id = ?`+clause, append([]any{runID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, notFound("experiment run", runID)
+	}
+	return runs[0], nil
+}
+
+// querier is a *sql.DB or *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// scanExperimentRuns runs a query selecting run columns followed by the number of
+// matching runs, and loads the params and metrics of the runs
+func (m *Manager) scanExperimentRuns(ctx context.Context, q querier, query string, args ...any) (runs []*ExperimentRun, total int, err error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query experiment runs: %w", err)
+	}
+	byID := make(map[string]*ExperimentRun)
+	for rows.Next() {
+		run := &ExperimentRun{Params: map[string]any{}, Metrics: map[string]float64{}}
+		var artifacts string
+		var created, updated int64
+		if err := rows.Scan(&run.ID, &run.Experiment, &run.Name, &run.EnvID, &run.Status, &artifacts, &run.Manifest, &run.Notes, &run.Owner, &created, &updated, &total); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("failed to read experiment runs: %w", err)
+		}
+		json.Unmarshal([]byte(artifacts), &run.Artifacts)
+		run.CreatedAt, run.UpdatedAt = time.Unix(0, created).UTC(), time.Unix(0, updated).UTC()
+		runs = append(runs, run)
+		byID[run.ID] = run
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read experiment runs: %w", err)
+	}
+	if len(runs) == 0 {
+		return runs, 0, nil
+	}
+
+	ids := make([]any, 0, len(runs))
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	in := "(" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+	for _, table := range []string{"params", "metrics"} {
+		rows, err := q.QueryContext(ctx, "SELECT run_id, key, value FROM "+table+" WHERE run_id IN "+in, ids...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read experiment %s: %w", table, err)
+		}
+		for rows.Next() {
+			var runID, key string
+			var value any
+			if err := rows.Scan(&runID, &key, &value); err != nil {
+				rows.Close()
+				return nil, 0, fmt.Errorf("failed to read experiment %s: %w", table, err)
+			}
+			run := byID[runID]
+			switch v := value.(type) {
+			case float64:
+				run.Metrics[key] = v
+			case int64:
+				run.Metrics[key] = float64(v)
+			case string:
+				var param any
+				json.Unmarshal([]byte(v), &param)
+				run.Params[key] = param
+			case []byte:
+				var param any
+				json.Unmarshal(v, &param)
+				run.Params[key] = param
+			}
+		}
+		rows.Close()
+	}
+	return runs, total, nil
+}
+
+// QueryExperiments lists experiments, or selects runs and compares them: params whose
+// values differ between the returned runs, and the range of each metric.
+func (m *Manager) QueryExperiments(ctx context.Context, q ExperimentQuery) (*ExperimentQueryResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultExperimentLimit
+	}
+	q.Limit = min(q.Limit, MaxExperimentLimit)
+	db, err := m.experimentDB()
+	if err != nil {
+		return nil, err
+	}
+	clause, ownerArgs := m.ownerClause(ctx)
+	if q.Experiment == "" && len(q.RunIDs) == 0 {
+		return m.listExperiments(ctx, db, clause, ownerArgs, q.Limit)
+	}
+
+	var where []string
+	var args []any
+	if q.Experiment != "" {
+		where = append(where, "r.experiment = ?")
+		args = append(args, q.Experiment)
+	}
+	if len(q.RunIDs) > 0 {
+		where = append(where, "r.id IN ("+strings.TrimSuffix(strings.Repeat("?,", len(q.RunIDs)), ",")+")")
+		for _, id := range q.RunIDs {
+			args = append(args, id)
+		}
+	}
+	if q.Status != "" {
+		where = append(where, "r.status = ?")
+		args = append(args, q.Status)
+	}
+	for key, value := range q.Params {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("param %s: %w", key, err))
+		}
+		where = append(where, "EXISTS (SELECT 1 FROM params p WHERE p.run_id = r.id AND p.key = ? AND p.value = ?)")
+		args = append(args, key, string(data))
+	}
+
+	order := "r.created_at DESC"
+	join := ""
+	if q.SortBy != "" {
+		join = " LEFT JOIN metrics s ON s.run_id = r.id AND s.key = ?"
+		args = append([]any{q.SortBy}, args...)
+		direction := "DESC"
+		if q.Ascending {
+			direction = "ASC"
+		}
+		order = "s.value IS NULL, s.value " + direction + ", r.created_at DESC"
+	}
+	query := `SELECT r.id, r.experiment, r.name, r.env_id, r.status, r.artifacts, r.manifest, r.notes, r.owner, r.created_at, r.updated_at, COUNT(*) OVER ()
+		FROM runs r` + join + ` WHERE ` + strings.Join(where, " AND ") + clause + ` ORDER BY ` + order + ` LIMIT ?`
+	args = append(append(args, ownerArgs...), q.Limit)
+
+	runs, total, err := m.scanExperimentRuns(ctx, db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result := &ExperimentQueryResult{Runs: runs, Total: total}
+	result.VaryingParams, result.Metrics = compareRuns(runs)
+	return result, nil
+}
+
+// listExperiments summarizes the experiments with runs visible to the caller, most
+// recently active first
+func (m *Manager) listExperiments(ctx context.Context, db *sql.DB, clause string, args []any, limit int) (*ExperimentQueryResult, error) {
+	rows, err := db.QueryContext(ctx, `SELECT r.experiment, COUNT(*), MAX(r.created_at),
+			(SELECT GROUP_CONCAT(DISTINCT s.key) FROM metrics s JOIN runs x ON x.id = s.run_id WHERE x.experiment = r.experiment)
+		FROM runs r WHERE 1 = 1`+clause+` GROUP BY r.experiment ORDER BY MAX(r.created_at) DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+	defer rows.Close()
+	result := &ExperimentQueryResult{Experiments: []ExperimentSummary{}}
+	for rows.Next() {
+		var summary ExperimentSummary
+		var last int64
+		var metrics sql.NullString
+		if err := rows.Scan(&summary.Name, &summary.Runs, &last, &metrics); err != nil {
+			return nil, fmt.Errorf("failed to list experiments: %w", err)
+		}
+		summary.LastRunAt = time.Unix(0, last).UTC()
+		if metrics.Valid {
+			summary.Metrics = strings.Split(metrics.String, ",")
+			sort.Strings(summary.Metrics)
+		}
+		result.Total++
+		if len(result.Experiments) < limit {
+			result.Experiments = append(result.Experiments, summary)
+		}
+	}
+	return result, rows.Err()
+}
+
+// compareRuns returns the params whose values differ between runs, and a summary of
+// each metric
+func compareRuns(runs []*ExperimentRun) ([]string, map[string]MetricSummary) {
+	if len(runs) < 2 {
+		return nil, nil
+	}
+	values := make(map[string]map[string]bool)
+	for _, run := range runs {
+		for key, value := range run.Params {
+			data, _ := json.Marshal(value)
+			if values[key] == nil {
+				values[key] = make(map[string]bool)
+			}
+			values[key][string(data)] = true
+		}
+	}
+	var varying []string
+	for key, seen := range values {
+		// A param missing from some runs also varies
+		missing := slices.ContainsFunc(runs, func(run *ExperimentRun) bool {
+			_, ok := run.Params[key]
+			return !ok
+		})
+		if len(seen) > 1 || missing {
+			varying = append(varying, key)
+		}
+	}
+	sort.Strings(varying)
+
+	metrics := make(map[string]MetricSummary)
+	for _, run := range runs {
+		for key, value := range run.Metrics {
+			summary, ok := metrics[key]
+			if !ok {
+				summary = MetricSummary{Min: value, Max: value, MinRunID: run.ID, MaxRunID: run.ID}
+			}
+			if value < summary.Min {
+				summary.Min, summary.MinRunID = value, run.ID
+			}
+			if value > summary.Max {
+				summary.Max, summary.MaxRunID = value, run.ID
+			}
+			summary.Mean += value
+			summary.Count++
+			metrics[key] = summary
+		}
+	}
+	for key, summary := range metrics {
+		summary.Mean /= float64(summary.Count)
+		metrics[key] = summary
+	}
+	return varying, metrics
+}
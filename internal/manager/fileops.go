@@ -0,0 +1,196 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileOpResult describes a file or directory moved, copied or created in the workspace
+type FileOpResult struct {
+	Path     string      `json:"path"`
+	Source   string      `json:"source,omitempty"`
+	IsDir    bool        `json:"is_dir"`
+	Created  bool        `json:"created,omitempty"`  // mkdir made the directory; unset if it existed
+	Files    int         `json:"files,omitempty"`    // files copied
+	Bytes    int64       `json:"bytes,omitempty"`    // bytes copied
+	Symlinks int         `json:"symlinks,omitempty"` // links copied as links
+	Replaced *TrashEntry `json:"replaced,omitempty"` // the overwritten destination, now in the trash
+}
+
+// workspacePaths resolves the source and destination of a move or copy. The source
+// must exist; an existing destination is moved to the trash with overwrite, unless it
+// is a directory. Neither may be in a read-only mount, and a moved source may not
+// hold a mount point.
+func (m *Manager) workspacePaths(env *ManagedEnvironment, src, dst string, overwrite, move bool) (from, to string, replaced *TrashEntry, err error) {
+	if env.WorkspaceDir == "" {
+		return "", "", nil, fmt.Errorf("no workspace created for environment: %s", env.ID)
+	}
+	if from, err = safeJoinPath(env.WorkspaceDir, src); err != nil {
+		return "", "", nil, err
+	}
+	if to, err = safeJoinPath(env.WorkspaceDir, dst); err != nil {
+		return "", "", nil, err
+	}
+	if _, err := os.Lstat(from); err != nil {
+		return "", "", nil, WithErrorCode(CodeNotFound, fmt.Errorf("file not found: %s", src))
+	}
+	if from == to || isSubPath(from, to) {
+		return "", "", nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("cannot move or copy %s into itself", src))
+	}
+	if move {
+		if err := m.checkNoMounts(env, from); err != nil {
+			return "", "", nil, err
+		}
+		if err := m.checkMountWritable(env, from); err != nil {
+			return "", "", nil, err
+		}
+	}
+	if err := m.checkMountWritable(env, to); err != nil {
+		return "", "", nil, err
+	}
+
+	if info, err := os.Lstat(to); err == nil {
+		switch {
+		case info.IsDir():
+			return "", "", nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("destination is an existing directory: %s; name the new path inside it", dst))
+		case !overwrite:
+			return "", "", nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("destination exists: %s; set overwrite to replace it", dst))
+		}
+		if replaced, err = m.moveToTrash(env, to, filepath.Clean(dst)); err != nil {
+			return "", "", nil, fmt.Errorf("failed to replace %s: %w", dst, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return "", "", nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return from, to, replaced, nil
+}
+
+// MoveWorkspaceFile moves or renames a file or directory within the workspace. An
+// existing destination file is replaced only with overwrite, and is moved to the trash.
+func (m *Manager) MoveWorkspaceFile(envID, src, dst string, overwrite bool) (*FileOpResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	from, to, replaced, err := m.workspacePaths(env, src, dst, overwrite, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return nil, fmt.Errorf("failed to move file: %w", err)
+	}
+	info, _ := os.Lstat(to)
+	return &FileOpResult{
+		Path:     workspaceRelative(env.WorkspaceDir, to),
+		Source:   workspaceRelative(env.WorkspaceDir, from),
+		IsDir:    info != nil && info.IsDir(),
+		Replaced: replaced,
+	}, nil
+}
+
+// CopyWorkspaceFile copies a file or, recursively, a directory within the workspace,
+// keeping file modes. Symbolic links are copied as links, never followed, so a copy
+// cannot pull in files from outside the workspace.
+func (m *Manager) CopyWorkspaceFile(envID, src, dst string, overwrite bool) (*FileOpResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	from, to, replaced, err := m.workspacePaths(env, src, dst, overwrite, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FileOpResult{
+		Path:     workspaceRelative(env.WorkspaceDir, to),
+		Source:   workspaceRelative(env.WorkspaceDir, from),
+		Replaced: replaced,
+	}
+	err = filepath.WalkDir(from, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			if p == from {
+				result.IsDir = true
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			result.Symlinks++
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			if err := copyFile(p, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			result.Files++
+			result.Bytes += info.Size()
+		}
+		return nil // sockets, devices and pipes are skipped
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return result, nil
+}
+
+// MakeWorkspaceDir creates a directory in the workspace with any missing parents. An
+// existing directory is not an error.
+func (m *Manager) MakeWorkspaceDir(envID, path string) (*FileOpResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	dir, err := safeJoinPath(env.WorkspaceDir, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkMountWritable(env, dir); err != nil {
+		return nil, err
+	}
+
+	result := &FileOpResult{Path: workspaceRelative(env.WorkspaceDir, dir), IsDir: true}
+	info, err := os.Stat(dir)
+	switch {
+	case err == nil && info.IsDir():
+		return result, nil
+	case err == nil:
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("a file exists at %s", path))
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	result.Created = true
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flamegraph layout, in pixels
+const (
+	flameWidth     = 1200
+	flameRowHeight = 16
+	flameMargin    = 10
+	flameCharWidth = 7 // of the 12px monospace labels
+	flameMinWidth  = 0.1
+)
+
+// foldedStack is one line of the "folded" stack format used by flamegraph tools and
+// py-spy: frames from the outermost, separated by ';', then a weight
+type foldedStack struct {
+	frames []string
+	weight int64
+}
+
+// parseFolded reads folded stacks, skipping lines that are not in the format
+func parseFolded(data string) []foldedStack {
+	var stacks []foldedStack
+	for _, line := range splitLines(data) {
+		sep := strings.LastIndexByte(line, ' ')
+		if sep <= 0 {
+			continue
+		}
+		weight, err := strconv.ParseInt(line[sep+1:], 10, 64)
+		if err != nil || weight <= 0 {
+			continue
+		}
+		stacks = append(stacks, foldedStack{frames: strings.Split(line[:sep], ";"), weight: weight})
+	}
+	return stacks
+}
+
+// frameLabel matches a "function (file:line)" frame; the line is optional
+var frameLabel = regexp.MustCompile(`^(.*) \((.+?)(?::(\d+))?\)$`)
+
+// parseFrame splits a frame label into function, file and line. ok is false for
+// frames that name no function, such as py-spy's process frames.
+func parseFrame(label string) (function, file string, line int, ok bool) {
+	match := frameLabel.FindStringSubmatch(label)
+	if match == nil {
+		return "", "", 0, false
+	}
+	line, _ = strconv.Atoi(match[3])
+	return match[1], match[2], line, true
+}
+
+// flameNode is a frame of the merged call tree a flamegraph draws
+type flameNode struct {
+	label    string
+	weight   int64
+	children map[string]*flameNode
+}
+
+// renderFlamegraph draws folded stacks as an SVG flamegraph, with the outermost frames
+// at the bottom and each frame as wide as its share of the total weight. Hovering a
+// frame shows its full label and share. unit names the weights, e.g. "samples".
+func renderFlamegraph(title string, stacks []foldedStack, unit string) []byte {
+	root := &flameNode{children: make(map[string]*flameNode)}
+	depth := 0
+	for _, s := range stacks {
+		node := root
+		node.weight += s.weight
+		for _, frame := range s.frames {
+			child := node.children[frame]
+			if child == nil {
+				child = &flameNode{label: frame, children: make(map[string]*flameNode)}
+				node.children[frame] = child
+			}
+			child.weight += s.weight
+			node = child
+		}
+		depth = max(depth, len(s.frames))
+	}
+
+	height := 2*flameMargin + 2*flameRowHeight + depth*flameRowHeight
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" standalone="no"?>
+<svg version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">
+<style>text { font-family: monospace; font-size: 12px; fill: #000; } rect { stroke: #fff; stroke-width: 0.5; }</style>
+<rect x="0" y="0" width="%d" height="%d" fill="#f8f8f8"/>
+<text x="%d" y="%d">%s</text>
+`, flameWidth, height, flameWidth, height, flameWidth, height, flameMargin, flameMargin+12, html.EscapeString(title))
+	if root.weight > 0 {
+		scale := float64(flameWidth-2*flameMargin) / float64(root.weight)
+		drawFlameChildren(&b, root, flameMargin, height-flameMargin-flameRowHeight, scale, root.weight, unit)
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// drawFlameChildren draws node's children side by side, heaviest first, in the row at
+// y, and their children in the rows above
+func drawFlameChildren(b *strings.Builder, node *flameNode, x float64, y int, scale float64, total int64, unit string) {
+	children := make([]*flameNode, 0, len(node.children))
+	for _, child := range node.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].weight != children[j].weight {
+			return children[i].weight > children[j].weight
+		}
+		return children[i].label < children[j].label
+	})
+	for _, child := range children {
+		width := float64(child.weight) * scale
+		if width < flameMinWidth {
+			break
+		}
+		label := html.EscapeString(child.label)
+		fmt.Fprintf(b, `<g><title>%s (%d %s, %.2f%%)</title><rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`,
+			label, child.weight, unit, 100*float64(child.weight)/float64(total), x, y, width, flameRowHeight-1, flameColor(child.label))
+		if fit := int(width-6) / flameCharWidth; fit >= 3 {
+			text := child.label
+			if runes := []rune(text); len(runes) > fit {
+				text = string(runes[:fit-2]) + ".."
+			}
+			fmt.Fprintf(b, `<text x="%.1f" y="%d">%s</text>`, x+3, y+flameRowHeight-4, html.EscapeString(text))
+		}
+		b.WriteString("</g>\n")
+		drawFlameChildren(b, child, x, y-flameRowHeight, scale, total, unit)
+		x += width
+	}
+}
+
+// flameColor picks a warm color from the frame's function name, so a function has
+// the same color wherever it appears
+func flameColor(label string) string {
+	function, _, _, ok := parseFrame(label)
+	if !ok {
+		function = label
+	}
+	h := fnv.New32a()
+	h.Write([]byte(function))
+	v := h.Sum32()
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+v%50, 80+(v>>8)%130, 40+(v>>16)%50)
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultRequirementsFile is the workspace file FreezeRequirements writes by default
+const DefaultRequirementsFile = "requirements.txt"
+
+// toolingPackages are development and tooling packages left out of a frozen
+// requirements file by default: packaging tools, linters, test runners, notebook
+// kernels and the tools the server installs on demand
+var toolingPackages = []string{
+	"pip", "setuptools", "wheel", "build", "twine", "pip-tools",
+	"poetry", "uv", "huggingface-hub",
+	"ruff", "mypy", "mypy-extensions", "black", "flake8", "pylint", "isort", "pre-commit",
+	"pytest", "pytest-cov", "coverage", "tox", "nox",
+	"ipython", "ipykernel", "jupyter", "jupyterlab", "notebook",
+}
+
+// FreezeRequirementsOptions configures FreezeRequirements
+type FreezeRequirementsOptions struct {
+	Path           string   // workspace file to write ("" = DefaultRequirementsFile)
+	Exclude        []string // additional package names to leave out
+	IncludeTooling bool     // keep toolingPackages
+}
+
+// FreezeRequirementsResult describes a written requirements file
+type FreezeRequirementsResult struct {
+	Path     string   `json:"path"`
+	Packages int      `json:"packages"`
+	Excluded []string `json:"excluded"`
+	Content  string   `json:"content"`
+}
+
+// FreezeRequirements writes the environment's installed packages, pinned to their
+// versions, to a requirements file in the workspace. Editable installs are left out
+// because they refer to local paths.
+func (m *Manager) FreezeRequirements(ctx context.Context, envID string, opts FreezeRequirementsOptions) (*FreezeRequirementsResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Path == "" {
+		opts.Path = DefaultRequirementsFile
+	}
+
+	excluded := make(map[string]bool)
+	for _, name := range opts.Exclude {
+		excluded[NormalizePackageName(name)] = true
+	}
+	if !opts.IncludeTooling {
+		for _, name := range toolingPackages {
+			excluded[NormalizePackageName(name)] = true
+		}
+	}
+
+	output, err := commandContext(ctx, env.Env.PythonPath, "-m", "pip", "freeze", "--exclude-editable").Output()
+	if err != nil {
+		if err := checkCancelled(ctx); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	result := &FreezeRequirementsResult{Path: opts.Path, Excluded: []string{}}
+	var b strings.Builder
+	for _, line := range splitLines(string(output)) {
+		line = trimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Direct references ("name @ url") carry no version
+		name, _, _ := strings.Cut(line, " @ ")
+		if req, err := ParseRequirement(name); err == nil {
+			name = req.Name
+		}
+		if excluded[NormalizePackageName(name)] {
+			result.Excluded = append(result.Excluded, line)
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+		result.Packages++
+	}
+	slices.Sort(result.Excluded)
+	result.Content = b.String()
+
+	if _, err := m.WriteWorkspaceFile(envID, opts.Path, result.Content); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
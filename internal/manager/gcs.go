@@ -0,0 +1,250 @@
+package manager
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcsScope is the OAuth scope requested for service accounts
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// serviceAccount is the part of a Google service account key file used to get tokens
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// loadServiceAccount reads a service account key file
+func loadServiceAccount(path string) (*serviceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var account serviceAccount
+	if err := json.Unmarshal(data, &account); err != nil || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("credentials file %s is not a service account key", path)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("credentials file %s has no PEM private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("credentials file %s: invalid private key: %w", path, err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("credentials file %s: the private key is not an RSA key", path)
+	}
+	account.key = key
+	return &account, nil
+}
+
+// gcsClient is a minimal Cloud Storage JSON API client. Requests are anonymous
+// without a token or service account.
+type gcsClient struct {
+	endpoint string
+	token    string          // static access token
+	account  *serviceAccount // exchanged for access tokens
+
+	mu      sync.Mutex // protects the cached token
+	cached  string
+	expires time.Time
+}
+
+// accessToken returns a token for the Authorization header, or "" for anonymous access
+func (c *gcsClient) accessToken(ctx context.Context) (string, error) {
+	if c.account == nil {
+		return c.token, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached != "" && time.Until(c.expires) > time.Minute {
+		return c.cached, nil
+	}
+
+	// A signed JWT is exchanged for an access token (RFC 7523)
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   c.account.ClientEmail,
+		"scope": gcsScope,
+		"aud":   c.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.account.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GCS token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error_description"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&token)
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", WithErrorCode(CodePermissionDenied, fmt.Errorf("GCS token request failed: %s %s", resp.Status, token.Error))
+	}
+	c.cached, c.expires = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	return c.cached, nil
+}
+
+// do sends an authorized request and returns the response if its status is 2xx
+func (c *gcsClient) do(ctx context.Context, method, rawURL string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	req.Header.Set("User-Agent", objectStoreAgent)
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GCS request failed: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var gcsErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := resp.Status
+	if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&gcsErr) == nil && gcsErr.Error.Message != "" {
+		message = fmt.Sprintf("%s: %s", resp.Status, gcsErr.Error.Message)
+	}
+	err = fmt.Errorf("GCS %s failed: %s", method, message)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, WithErrorCode(CodeNotFound, err)
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return nil, WithErrorCode(CodePermissionDenied, err)
+	}
+	return nil, err
+}
+
+// list returns the objects below prefix, paging through objects.list
+func (c *gcsClient) list(ctx context.Context, bucket, prefix string, limit int) ([]objectInfo, error) {
+	var objects []objectInfo
+	token := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name,size,md5Hash),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		resp, err := c.do(ctx, http.MethodGet, c.endpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"/o?"+query.Encode(), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items []struct {
+				Name    string `json:"name"`
+				Size    string `json:"size"` // a decimal string
+				MD5Hash string `json:"md5Hash"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid GCS listing: %w", err)
+		}
+		for _, item := range page.Items {
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			obj := objectInfo{Key: item.Name, Size: size}
+			// Composite objects have no MD5
+			if sum, err := base64.StdEncoding.DecodeString(item.MD5Hash); err == nil && len(sum) == 16 {
+				obj.MD5 = hex.EncodeToString(sum)
+			}
+			objects = append(objects, obj)
+		}
+		if len(objects) > limit {
+			return nil, WithErrorCode(CodeQuotaExceeded, fmt.Errorf("more than %d objects below %s; narrow the prefix", limit, prefix))
+		}
+		if page.NextPageToken == "" {
+			return objects, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// get writes an object's content to w
+func (c *gcsClient) get(ctx context.Context, bucket, key string, w io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"/o/"+url.PathEscape(key)+"?alt=media", nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// put uploads a file as an object in one request
+func (c *gcsClient) put(ctx context.Context, bucket, key, file string, size int64) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	query := url.Values{"uploadType": {"media"}, "name": {key}}
+	resp, err := c.do(ctx, http.MethodPost, c.endpoint+"/upload/storage/v1/b/"+url.PathEscape(bucket)+"/o?"+query.Encode(), f, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
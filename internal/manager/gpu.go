@@ -0,0 +1,339 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GPU vendors reported by GPUInfo
+const (
+	GPUVendorNVIDIA = "nvidia"
+	GPUVendorAMD    = "amd"
+	GPUVendorApple  = "apple"
+)
+
+// gpuProbeTimeout bounds each vendor tool run by the GPU probe
+const gpuProbeTimeout = 10 * time.Second
+
+// maxGPUSummaryLen keeps the GPU summary within a single mDNS TXT string
+const maxGPUSummaryLen = 200
+
+// GPUDevice describes one GPU. Memory and utilization are omitted when the vendor
+// tool does not report them (e.g. Apple GPUs share system memory).
+type GPUDevice struct {
+	Index              int      `json:"index"` // among the vendor's devices, as used by gpu_devices
+	Vendor             string   `json:"vendor"`
+	Name               string   `json:"name"`
+	UUID               string   `json:"uuid,omitempty"`
+	MemoryTotalMB      uint64   `json:"memory_total_mb,omitempty"`
+	MemoryUsedMB       uint64   `json:"memory_used_mb,omitempty"`
+	UtilizationPercent *int     `json:"utilization_percent,omitempty"`
+	Cores              int      `json:"cores,omitempty"` // Apple GPUs only
+	MetalFamily        string   `json:"metal_family,omitempty"`
+	AllocatedTo        []string `json:"allocated_to,omitempty"` // holders restricted to this device
+}
+
+// GPUInfo describes the GPUs available to processes started by the server
+type GPUInfo struct {
+	Available     bool        `json:"available"`
+	DriverVersion string      `json:"driver_version,omitempty"`
+	CUDAVersion   string      `json:"cuda_version,omitempty"`
+	ROCmVersion   string      `json:"rocm_version,omitempty"`
+	Devices       []GPUDevice `json:"devices"`
+	Summary       string      `json:"summary,omitempty"`
+	// Allocations lists the processes, REPL sessions and scripts started with gpu_devices
+	Allocations []GPUAllocation `json:"allocations,omitempty"`
+	// ProbeErrors lists vendor tools that are installed but failed to report
+	ProbeErrors []string `json:"probe_errors,omitempty"`
+}
+
+// gpuProbe adds the devices reported by a vendor tool to a GPUInfo
+type gpuProbe struct {
+	tool  string
+	probe func(context.Context, *GPUInfo) error
+}
+
+// GPUInfo probes the GPUs of the host with nvidia-smi, rocm-smi and, on macOS,
+// system_profiler, and reports the gpu_devices allocations visible to the caller. Vendors whose tool is not installed are skipped, so a host without
+// GPUs reports Available false rather than an error.
+func (m *Manager) GPUInfo(ctx context.Context) *GPUInfo {
+	info := &GPUInfo{Devices: []GPUDevice{}}
+	probes := []gpuProbe{
+		{"nvidia-smi", probeNVIDIA},
+		{"rocm-smi", probeROCm},
+	}
+	if runtime.GOOS == "darwin" {
+		probes = append(probes, gpuProbe{"system_profiler", probeMetal})
+	}
+
+	for _, p := range probes {
+		if _, err := exec.LookPath(p.tool); err != nil {
+			continue
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, gpuProbeTimeout)
+		err := p.probe(probeCtx, info)
+		cancel()
+		if err != nil {
+			info.ProbeErrors = append(info.ProbeErrors, fmt.Sprintf("%s: %v", p.tool, err))
+		}
+	}
+	perVendor := make(map[string]int)
+	for i := range info.Devices {
+		d := &info.Devices[i]
+		d.Index = perVendor[d.Vendor]
+		perVendor[d.Vendor]++
+	}
+	info.Available = len(info.Devices) > 0
+	info.Summary = info.summary()
+	info.Allocations = m.GPUAllocations(ctx)
+	markAllocatedDevices(info.Devices, info.Allocations)
+	return info
+}
+
+// summary condenses the devices into one line such as
+// "2x NVIDIA A100-SXM4-80GB 80GB, CUDA 12.2", used for mDNS announcements
+func (g *GPUInfo) summary() string {
+	if len(g.Devices) == 0 {
+		return ""
+	}
+
+	type model struct {
+		name     string
+		memoryMB uint64
+		count    int
+	}
+	var models []*model
+	byName := make(map[string]*model)
+	for _, d := range g.Devices {
+		key := d.Name + "/" + strconv.FormatUint(d.MemoryTotalMB, 10)
+		if m, ok := byName[key]; ok {
+			m.count++
+			continue
+		}
+		m := &model{name: d.Name, memoryMB: d.MemoryTotalMB, count: 1}
+		byName[key] = m
+		models = append(models, m)
+	}
+
+	parts := make([]string, 0, len(models))
+	for _, m := range models {
+		part := fmt.Sprintf("%dx %s", m.count, m.name)
+		if m.memoryMB > 0 {
+			part += fmt.Sprintf(" %dGB", (m.memoryMB+512)/1024)
+		}
+		parts = append(parts, part)
+	}
+	summary := strings.Join(parts, " + ")
+	if g.CUDAVersion != "" {
+		summary += ", CUDA " + g.CUDAVersion
+	}
+	if g.ROCmVersion != "" {
+		summary += ", ROCm " + g.ROCmVersion
+	}
+	if len(summary) > maxGPUSummaryLen {
+		summary = summary[:maxGPUSummaryLen]
+	}
+	return summary
+}
+
+// cudaVersionPattern finds the CUDA version in the nvidia-smi banner
+var cudaVersionPattern = regexp.MustCompile(`CUDA Version:\s*([0-9.]+)`)
+
+// probeNVIDIA adds the devices reported by nvidia-smi
+func probeNVIDIA(ctx context.Context, info *GPUInfo) error {
+	output, err := runGPUTool(ctx, "nvidia-smi",
+		"--query-gpu=name,uuid,memory.total,memory.used,utilization.gpu,driver_version",
+		"--format=csv,noheader,nounits")
+	if err != nil {
+		return err
+	}
+	devices, driver := parseNVIDIASMI(output)
+	info.Devices = append(info.Devices, devices...)
+	if info.DriverVersion == "" {
+		info.DriverVersion = driver
+	}
+
+	// The CUDA version the driver supports is only printed in the banner
+	if banner, err := runGPUTool(ctx, "nvidia-smi"); err == nil {
+		if match := cudaVersionPattern.FindStringSubmatch(banner); match != nil {
+			info.CUDAVersion = match[1]
+		}
+	}
+	return nil
+}
+
+// parseNVIDIASMI parses nvidia-smi --query-gpu CSV output (name, uuid, memory.total,
+// memory.used, utilization.gpu, driver_version; no header, no units)
+func parseNVIDIASMI(output string) ([]GPUDevice, string) {
+	var devices []GPUDevice
+	driver := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 6 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		device := GPUDevice{Vendor: GPUVendorNVIDIA, Name: fields[0], UUID: fields[1]}
+		device.MemoryTotalMB, _ = strconv.ParseUint(fields[2], 10, 64)
+		device.MemoryUsedMB, _ = strconv.ParseUint(fields[3], 10, 64)
+		if util, err := strconv.Atoi(fields[4]); err == nil {
+			device.UtilizationPercent = &util
+		}
+		driver = fields[5]
+		devices = append(devices, device)
+	}
+	return devices, driver
+}
+
+// probeROCm adds the devices reported by rocm-smi
+func probeROCm(ctx context.Context, info *GPUInfo) error {
+	output, err := runGPUTool(ctx, "rocm-smi",
+		"--showproductname", "--showmeminfo", "vram", "--showuse", "--showdriverversion", "--json")
+	if err != nil {
+		return err
+	}
+	devices, driver, err := parseROCmSMI(output)
+	if err != nil {
+		return err
+	}
+	info.Devices = append(info.Devices, devices...)
+	if info.DriverVersion == "" {
+		info.DriverVersion = driver
+	}
+	if data, err := os.ReadFile("/opt/rocm/.info/version"); err == nil {
+		info.ROCmVersion = strings.TrimSpace(string(data))
+	}
+	return nil
+}
+
+// parseROCmSMI parses rocm-smi --json output: one object per "cardN" keyed by
+// human-readable field names, plus a "system" object with the driver version
+func parseROCmSMI(output string) ([]GPUDevice, string, error) {
+	// rocm-smi may print warnings before the JSON document
+	if start := strings.Index(output, "{"); start > 0 {
+		output = output[start:]
+	}
+	var cards map[string]map[string]string
+	if err := json.Unmarshal([]byte(output), &cards); err != nil {
+		return nil, "", fmt.Errorf("invalid JSON output: %w", err)
+	}
+
+	driver := cards["system"]["Driver version"]
+	var names []string
+	for name := range cards {
+		if strings.HasPrefix(name, "card") {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(names[i], "card"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(names[j], "card"))
+		return a < b
+	})
+
+	devices := make([]GPUDevice, 0, len(names))
+	for _, name := range names {
+		card := cards[name]
+		device := GPUDevice{Vendor: GPUVendorAMD, Name: card["Card Series"], UUID: card["Unique ID"]}
+		if device.Name == "" {
+			device.Name = card["Card series"]
+		}
+		if device.Name == "" {
+			device.Name = card["Card model"]
+		}
+		if total, err := strconv.ParseUint(card["VRAM Total Memory (B)"], 10, 64); err == nil {
+			device.MemoryTotalMB = total >> 20
+		}
+		if used, err := strconv.ParseUint(card["VRAM Total Used Memory (B)"], 10, 64); err == nil {
+			device.MemoryUsedMB = used >> 20
+		}
+		if util, err := strconv.Atoi(card["GPU use (%)"]); err == nil {
+			device.UtilizationPercent = &util
+		}
+		if driver == "" {
+			driver = card["Driver version"]
+		}
+		devices = append(devices, device)
+	}
+	return devices, driver, nil
+}
+
+// probeMetal adds the GPUs reported by system_profiler on macOS
+func probeMetal(ctx context.Context, info *GPUInfo) error {
+	output, err := runGPUTool(ctx, "system_profiler", "SPDisplaysDataType", "-json")
+	if err != nil {
+		return err
+	}
+	devices, err := parseSystemProfiler(output)
+	if err != nil {
+		return err
+	}
+	info.Devices = append(info.Devices, devices...)
+	return nil
+}
+
+// parseSystemProfiler parses system_profiler SPDisplaysDataType -json output
+func parseSystemProfiler(output string) ([]GPUDevice, error) {
+	var report struct {
+		Displays []struct {
+			Model  string `json:"sppci_model"`
+			Cores  string `json:"sppci_cores"`
+			Metal  string `json:"spdisplays_mtlgpufamilysupport"`
+			VRAM   string `json:"spdisplays_vram"`
+			Vendor string `json:"spdisplays_vendor"`
+		} `json:"SPDisplaysDataType"`
+	}
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		return nil, fmt.Errorf("invalid JSON output: %w", err)
+	}
+
+	var devices []GPUDevice
+	for _, d := range report.Displays {
+		// GPUs without Metal support can't be used for compute
+		if d.Metal == "" {
+			continue
+		}
+		device := GPUDevice{
+			Vendor:      strings.ToLower(strings.TrimPrefix(d.Vendor, "sppci_vendor_")),
+			Name:        d.Model,
+			MetalFamily: strings.TrimPrefix(d.Metal, "spdisplays_"),
+		}
+		device.Cores, _ = strconv.Atoi(d.Cores)
+		if gb, ok := strings.CutSuffix(d.VRAM, " GB"); ok {
+			if n, err := strconv.ParseUint(gb, 10, 64); err == nil {
+				device.MemoryTotalMB = n << 10
+			}
+		}
+		if device.Vendor == "" || strings.HasPrefix(d.Model, "Apple") {
+			device.Vendor = GPUVendorApple
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// runGPUTool runs a vendor tool and returns its standard output
+func runGPUTool(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := commandContext(ctx, name, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(output), nil
+}
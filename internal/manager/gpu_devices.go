@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of GPU holders
+const (
+	GPUHolderProcess = "process"
+	GPUHolderREPL    = "repl"
+	GPUHolderScript  = "script"
+)
+
+// GPUAllocation records the GPUs a process, REPL session or running script was
+// restricted to with gpu_devices
+type GPUAllocation struct {
+	Devices    []string  `json:"devices"`
+	HolderKind string    `json:"holder_kind"`
+	HolderID   string    `json:"holder_id"`
+	Name       string    `json:"name,omitempty"`
+	EnvID      string    `json:"env_id"`
+	Owner      string    `json:"owner,omitempty"`
+	Since      time.Time `json:"since"`
+}
+
+// validateGPUDevices checks device selectors: GPU indices as listed by gpu_info, or
+// GPU UUIDs
+func validateGPUDevices(devices []string) error {
+	for _, d := range devices {
+		if d == "" || strings.ContainsAny(d, ", \t\n=") {
+			return fmt.Errorf("invalid GPU device %q: use an index or UUID from gpu_info", d)
+		}
+	}
+	return nil
+}
+
+// gpuEnv returns the environment variables that restrict a child process to devices.
+// Device ordering follows the PCI bus so that indices match nvidia-smi and gpu_info.
+func gpuEnv(devices []string) map[string]string {
+	if len(devices) == 0 {
+		return nil
+	}
+	visible := strings.Join(devices, ",")
+	return map[string]string{
+		"CUDA_DEVICE_ORDER":    "PCI_BUS_ID",
+		"CUDA_VISIBLE_DEVICES": visible,
+		"ROCR_VISIBLE_DEVICES": visible,
+	}
+}
+
+// appendGPUEnv adds the gpuEnv variables to a command environment
+func appendGPUEnv(environ []string, devices []string) []string {
+	for key, value := range gpuEnv(devices) {
+		environ = append(environ, key+"="+value)
+	}
+	return environ
+}
+
+// allocateGPUs records that a holder uses devices. It does nothing without devices.
+func (m *Manager) allocateGPUs(alloc GPUAllocation) {
+	if len(alloc.Devices) == 0 {
+		return
+	}
+	alloc.Since = time.Now()
+	m.gpuMu.Lock()
+	defer m.gpuMu.Unlock()
+	m.gpuAllocations[alloc.HolderID] = &alloc
+}
+
+// releaseGPUs forgets the allocation of a holder, if any
+func (m *Manager) releaseGPUs(holderID string) {
+	m.gpuMu.Lock()
+	defer m.gpuMu.Unlock()
+	delete(m.gpuAllocations, holderID)
+}
+
+// GPUAllocations lists the GPU allocations visible to the caller in ctx, oldest first
+func (m *Manager) GPUAllocations(ctx context.Context) []GPUAllocation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.gpuMu.Lock()
+	defer m.gpuMu.Unlock()
+
+	result := make([]GPUAllocation, 0, len(m.gpuAllocations))
+	for _, alloc := range m.gpuAllocations {
+		if !m.canAccess(ctx, alloc.Owner) {
+			continue
+		}
+		result = append(result, *alloc)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Since.Before(result[j].Since) })
+	return result
+}
+
+// markAllocatedDevices fills in AllocatedTo for the devices named by allocations,
+// matching selectors against the device index or UUID
+func markAllocatedDevices(devices []GPUDevice, allocations []GPUAllocation) {
+	for i := range devices {
+		d := &devices[i]
+		index := strconv.Itoa(d.Index)
+		for _, alloc := range allocations {
+			for _, selector := range alloc.Devices {
+				if selector == index || (d.UUID != "" && selector == d.UUID) {
+					d.AllocatedTo = append(d.AllocatedTo, alloc.HolderID)
+					break
+				}
+			}
+		}
+	}
+}
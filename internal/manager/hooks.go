@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CreateOptions configures optional behavior of CreateEnvironment
+type CreateOptions struct {
+	// PostCreate is Python code run inside the new environment after creation
+	PostCreate string
+
+	// Isolation runs the environment's code, scripts and processes in a sandbox
+	// (IsolationBubblewrap, IsolationPodman or IsolationDocker; empty for none)
+	Isolation string
+
+	// Network restricts what the environment's processes may reach (the zero value
+	// denies isolated environments the network and allows others everything)
+	Network NetworkPolicy
+
+	// Template is the ID of an environment whose packages are cloned into the new one,
+	// sharing unchanged files where the filesystem allows
+	Template string
+
+	// Labels are key=value metadata set on the new environment
+	Labels map[string]string
+
+	// Runtimes are provisioned from conda-forge next to Python, before the hooks run,
+	// e.g. "nodejs" or "nodejs=20"; their executables are on the PATH of commands,
+	// spawned processes and terminals
+	Runtimes []string
+
+	// WithREPL also creates the environment's workspace and a REPL session named
+	// DefaultREPLName, so the environment is ready for code in one call
+	WithREPL bool
+
+	// setup installs the environment's packages after the post-create hooks have run
+	setup func(ctx context.Context, env *ManagedEnvironment) error
+}
+
+// SetPostCreateHook configures a Python script that runs inside every newly created or
+// restored environment before it is handed out (empty disables)
+func (m *Manager) SetPostCreateHook(path string) error {
+	if path != "" {
+		// The hook runs from the environment's directory, so resolve it now
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("post-create hook: %w", err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return fmt.Errorf("post-create hook: %w", err)
+		}
+		path = abs
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.postCreateHook = path
+	return nil
+}
+
+// runPostCreate runs the server's post-create hook and then the caller's post-create
+// code inside a new environment, under its network policy. The hooks see
+// JUMPBOOT_ENV_ID, JUMPBOOT_ENV_NAME and JUMPBOOT_ENV_PATH and run from the
+// environment's root directory. Returns the combined output of both.
+func (m *Manager) runPostCreate(ctx context.Context, env *ManagedEnvironment, code string) (string, error) {
+	m.mu.RLock()
+	hookPath := m.postCreateHook
+	m.mu.RUnlock()
+
+	var outputs []string
+
+	if hookPath != "" {
+		output, err := m.runHookScript(ctx, env, hookPath)
+		if err != nil {
+			return output, fmt.Errorf("post-create hook failed: %w\nOutput: %s", err, output)
+		}
+		outputs = append(outputs, output)
+	}
+
+	if code != "" {
+		tmpFile, err := os.CreateTemp("", "post-create-*.py")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp script: %w", err)
+		}
+		tmpPath := tmpFile.Name()
+		defer os.Remove(tmpPath)
+
+		if _, err := tmpFile.WriteString(code); err != nil {
+			tmpFile.Close()
+			return "", fmt.Errorf("failed to write script: %w", err)
+		}
+		tmpFile.Close()
+
+		output, err := m.runHookScript(ctx, env, tmpPath)
+		if err != nil {
+			return output, fmt.Errorf("post_create script failed: %w\nOutput: %s", err, output)
+		}
+		outputs = append(outputs, output)
+	}
+
+	return strings.Join(outputs, ""), nil
+}
+
+// runHookScript runs a Python script inside the environment with the hook variables
+// set, restricted by its network policy and inside its sandbox if it is isolated
+func (m *Manager) runHookScript(ctx context.Context, env *ManagedEnvironment, scriptPath string) (string, error) {
+	cmd := commandContext(ctx, env.Env.PythonPath, scriptPath)
+	cmd.Dir = env.RootDir
+	cmd.Env = append(os.Environ(),
+		"JUMPBOOT_ENV_ID="+env.ID,
+		"JUMPBOOT_ENV_NAME="+env.Name,
+		"JUMPBOOT_ENV_PATH="+env.Env.EnvPath,
+	)
+	return m.runIsolated(ctx, env, cmd, sandboxMount{path: scriptPath})
+}
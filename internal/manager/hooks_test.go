@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richinsley/jumpboot"
+	"github.com/richinsley/jumpboot-mcp/internal/netpolicy"
+)
+
+// fakeSandbox puts a bwrap on the PATH that records its arguments instead of
+// starting a sandbox, and returns the file they are written to
+func fakeSandbox(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	record := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + record + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "bwrap"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return record
+}
+
+func TestPostCreateHookRunsInSandbox(t *testing.T) {
+	record := fakeSandbox(t)
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := filepath.Join(t.TempDir(), "hook.py")
+	if err := os.WriteFile(hook, []byte("print('hi')\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetPostCreateHook(hook); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	env := &ManagedEnvironment{
+		ID:        "env",
+		RootDir:   root,
+		Env:       &jumpboot.PythonEnvironment{PythonPath: filepath.Join(root, "bin", "python")},
+		Isolation: IsolationBubblewrap,
+		network:   envNetwork{policy: NetworkPolicy{Mode: NetworkDeny}},
+	}
+	if _, err := m.runPostCreate(context.Background(), env, "print('post')"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("the hooks did not run through bwrap: %v", err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	// The record holds the last hook, the caller's code, run by the environment's
+	// interpreter inside the sandbox
+	sep := -1
+	for i, arg := range args {
+		if arg == "--" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || sep+2 >= len(args) || args[sep+1] != env.Env.PythonPath {
+		t.Fatalf("bwrap did not run the interpreter: %q", args)
+	}
+	script := args[sep+2]
+	if !strings.Contains(strings.Join(args[:sep], " "), "--ro-bind "+script+" "+script) {
+		t.Errorf("the hook script %s is not mounted: %q", script, args)
+	}
+	if !strings.Contains(strings.Join(args[:sep], " "), "--unshare-net") {
+		t.Errorf("the hook has the network despite the deny policy: %q", args)
+	}
+}
+
+func TestPostCreateHookFollowsNetworkPolicy(t *testing.T) {
+	record := fakeSandbox(t)
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	env := &ManagedEnvironment{
+		ID:        "env",
+		RootDir:   root,
+		Env:       &jumpboot.PythonEnvironment{PythonPath: filepath.Join(root, "bin", "python")},
+		Isolation: IsolationBubblewrap,
+		network:   envNetwork{policy: NetworkPolicy{Mode: NetworkAllowlist, Hosts: []string{"pypi.org"}}},
+	}
+	defer env.stopNetworkProxy()
+	if _, err := m.runPostCreate(context.Background(), env, "print('post')"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("the hook did not run through bwrap: %v", err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	helper, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The network helper runs inside the sandbox and starts the interpreter with the
+	// proxy's socket
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	if len(args) < 4 || args[0] != helper || args[1] != netpolicy.ExecCommand || args[2] != "-proxy-socket" {
+		t.Fatalf("the hook does not run under the network helper: %q", args)
+	}
+	if env.network.proxy == nil {
+		t.Error("the allowlist proxy was not started for the hook")
+	}
+}
@@ -0,0 +1,328 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultImageBase is the base image of exported environments. Its micromamba
+// installs the environment.yml, and its entrypoint activates the environment.
+const DefaultImageBase = "mambaorg/micromamba:1.5.10-bookworm-slim"
+
+// imageHeader starts the first line of every generated Dockerfile
+const imageHeader = "# Generated by jumpboot-mcp"
+
+// imageWorkdir is where the workspace is copied in an exported image
+const imageWorkdir = "/workspace"
+
+// imageBuilders are the tools export_image can build with, in order of preference
+var imageBuilders = []string{"buildah", "docker", "podman"}
+
+// imageSkipDirs are workspace directories left out of an exported image
+var imageSkipDirs = []string{".git", "__pycache__", ".ipynb_checkpoints"}
+
+// buildOutputLines is the number of lines of builder output an export reports
+const buildOutputLines = 40
+
+// ImageExportOptions controls ExportImage
+type ImageExportOptions struct {
+	BaseImage        string   // default DefaultImageBase
+	IncludeWorkspace bool     // copy the workspace into the image
+	Entrypoint       string   // manifest entrypoint to use as the image's command
+	Cmd              []string // image command, instead of an entrypoint
+	Output           string   // workspace directory to write the build context to
+	Tag              string   // build the image with this tag
+	Builder          string   // buildah, docker or podman (default: the first installed)
+}
+
+// ImageExport describes an exported environment image
+type ImageExport struct {
+	EnvID          string   `json:"env_id"`
+	Dockerfile     string   `json:"dockerfile"`
+	EnvironmentYML string   `json:"environment_yml"`
+	WorkspaceFiles int      `json:"workspace_files"`
+	RuntimeSecrets []string `json:"runtime_secrets,omitempty"` // variables to pass when running the image
+	Output         string   `json:"output,omitempty"`          // build context directory in the workspace
+	Image          string   `json:"image,omitempty"`           // tag of the built image
+	Builder        string   `json:"builder,omitempty"`
+	BuildOutput    string   `json:"build_output,omitempty"` // last lines of the builder's output
+}
+
+// ExportImage generates a Dockerfile reproducing an environment: its Python version and
+// packages (through the environment.yml of ExportEnvironmentYML), its plain variables
+// and optionally its workspace and a command. The build context is written to a
+// workspace directory when opts.Output is set, and built into an image with buildah,
+// docker or podman when opts.Tag is set. Secret variables are never baked in; they
+// are reported as runtime_secrets instead.
+func (m *Manager) ExportImage(ctx context.Context, envID string, opts ImageExportOptions) (*ImageExport, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Entrypoint != "" && len(opts.Cmd) > 0 {
+		return nil, fmt.Errorf("entrypoint and cmd cannot both be set")
+	}
+	var builder string
+	if opts.Tag != "" {
+		if builder, err = findImageBuilder(opts.Builder); err != nil {
+			return nil, err
+		}
+	}
+	var outputDir string
+	if opts.Output != "" {
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+		ws, err := m.CreateWorkspace(envID)
+		if err != nil {
+			return nil, err
+		}
+		if outputDir, err = safeJoinPath(ws.Path, opts.Output); err != nil {
+			return nil, err
+		}
+		if outputDir == ws.Path {
+			return nil, fmt.Errorf("output must be a directory inside the workspace")
+		}
+	}
+
+	spec, err := m.ExportEnvironmentYML(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	cmd := opts.Cmd
+	if opts.Entrypoint != "" {
+		env.configMu.RLock()
+		ep, ok := env.entrypoints[opts.Entrypoint]
+		env.configMu.RUnlock()
+		if !ok {
+			return nil, notFound("entrypoint", opts.Entrypoint)
+		}
+		if !opts.IncludeWorkspace {
+			return nil, fmt.Errorf("entrypoint %s runs a workspace script, so the workspace must be included", opts.Entrypoint)
+		}
+		cmd = append([]string{"python", filepath.ToSlash(ep.Script)}, ep.Args...)
+	}
+	env.configMu.RLock()
+	vars, secrets := maps.Clone(env.vars), slices.Sorted(maps.Keys(env.secretRefs))
+	env.configMu.RUnlock()
+
+	result := &ImageExport{
+		EnvID:          envID,
+		EnvironmentYML: spec,
+		RuntimeSecrets: secrets,
+	}
+	includeWorkspace := opts.IncludeWorkspace && env.WorkspaceDir != ""
+	if result.Dockerfile, err = imageDockerfile(env, opts.BaseImage, vars, includeWorkspace, cmd); err != nil {
+		return nil, err
+	}
+	if outputDir == "" && opts.Tag == "" {
+		return result, nil
+	}
+
+	// Write the build context: Dockerfile, environment.yml and workspace/
+	contextDir := outputDir
+	if contextDir == "" {
+		if contextDir, err = os.MkdirTemp("", "jumpboot-image-*"); err != nil {
+			return nil, fmt.Errorf("failed to create build context: %w", err)
+		}
+		defer os.RemoveAll(contextDir)
+	} else {
+		if err := clearImageContext(contextDir, opts.Output); err != nil {
+			return nil, err
+		}
+		result.Output = filepath.ToSlash(opts.Output)
+	}
+	if err := os.MkdirAll(contextDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create build context: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte(result.Dockerfile), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write Dockerfile: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, DefaultEnvironmentYMLFile), []byte(spec), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write environment.yml: %w", err)
+	}
+	if includeWorkspace {
+		unlock, err := m.lockEnvironment(ctx, env, false)
+		if err != nil {
+			return nil, err
+		}
+		result.WorkspaceFiles, err = copyWorkspaceTree(ctx, env.WorkspaceDir, filepath.Join(contextDir, "workspace"), outputDir)
+		unlock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy workspace: %w", err)
+		}
+	}
+
+	if opts.Tag != "" {
+		args := []string{"build", "-t", opts.Tag, contextDir}
+		if filepath.Base(builder) == "buildah" {
+			// "bud" is accepted by every buildah version
+			args[0] = "bud"
+		}
+		output, err := runCommand(ctx, commandContext(ctx, builder, args...))
+		result.BuildOutput, _ = tailLines(output, buildOutputLines)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s build failed: %w\n%s", filepath.Base(builder), err, result.BuildOutput)
+		}
+		result.Image, result.Builder = opts.Tag, filepath.Base(builder)
+	}
+	return result, nil
+}
+
+// clearImageContext removes an earlier build context at dir so that it can be written
+// again. Directories that hold anything else are refused rather than deleted.
+func clearImageContext(dir, name string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		data, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+		if err != nil || !strings.HasPrefix(string(data), imageHeader) {
+			return fmt.Errorf("output %s already exists and is not an exported image; choose another directory", name)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", name, err)
+	}
+	return nil
+}
+
+// findImageBuilder returns the path of the named image builder, or of the first
+// installed one
+func findImageBuilder(name string) (string, error) {
+	if name != "" {
+		if !slices.Contains(imageBuilders, name) {
+			return "", fmt.Errorf("unknown builder %q (use %s)", name, strings.Join(imageBuilders, ", "))
+		}
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("builder %s was not found: %w", name, err)
+		}
+		return path, nil
+	}
+	for _, name := range imageBuilders {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("building an image requires %s; none was found (export without tag to get the Dockerfile)", strings.Join(imageBuilders, ", "))
+}
+
+// imageDockerfile returns the Dockerfile of an exported environment. Its build context
+// holds the Dockerfile, the environment.yml and, with workspace set, a workspace/
+// directory.
+func imageDockerfile(env *ManagedEnvironment, base string, vars map[string]string, workspace bool, cmd []string) (string, error) {
+	if base == "" {
+		base = DefaultImageBase
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s from environment %s (%s), Python %s\n", imageHeader, env.Name, env.ID, env.PythonVer)
+	fmt.Fprintf(&b, "FROM %s\n\n", base)
+	b.WriteString("COPY --chown=$MAMBA_USER:$MAMBA_USER environment.yml /tmp/environment.yml\n")
+	b.WriteString("RUN micromamba install -y -n base -f /tmp/environment.yml && \\\n    micromamba clean --all --yes\n")
+	b.WriteString("ARG MAMBA_DOCKERFILE_ACTIVATE=1\n")
+
+	if len(vars) > 0 {
+		b.WriteString("\n")
+		for _, key := range slices.Sorted(maps.Keys(vars)) {
+			if strings.ContainsAny(vars[key], "\r\n") {
+				return "", fmt.Errorf("variable %s contains a line break, which a Dockerfile cannot hold", key)
+			}
+			fmt.Fprintf(&b, "ENV %s=%s\n", key, dockerfileQuote(vars[key]))
+		}
+	}
+
+	fmt.Fprintf(&b, "\nWORKDIR %s\n", imageWorkdir)
+	if workspace {
+		fmt.Fprintf(&b, "COPY --chown=$MAMBA_USER:$MAMBA_USER workspace/ %s/\n", imageWorkdir)
+	}
+	if len(cmd) > 0 {
+		data, err := json.Marshal(cmd)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "CMD %s\n", data)
+	}
+	return b.String(), nil
+}
+
+// dockerfileQuote quotes an ENV value so that the builder neither splits nor expands it
+func dockerfileQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// copyWorkspaceTree copies the files of a workspace to dst, leaving out imageSkipDirs
+// and skip (the build context itself when it lies inside the workspace). Symbolic
+// links are recreated; other special files are skipped. It returns the number of
+// files copied.
+func copyWorkspaceTree(ctx context.Context, src, dst, skip string) (int, error) {
+	files := 0
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return checkCancelled(ctx)
+		}
+		if d.IsDir() && path != src && (path == skip || slices.Contains(imageSkipDirs, d.Name())) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, 0755)
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !fi.Mode().IsRegular():
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		files++
+		return out.Close()
+	})
+	return files, err
+}
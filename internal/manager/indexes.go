@@ -0,0 +1,179 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Package index types
+const (
+	IndexPip   = "pip"
+	IndexConda = "conda"
+)
+
+// PackageIndexSpec is an entry of the -package-indexes file. Passwords are best read
+// from the server's environment with password_env.
+type PackageIndexSpec struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // IndexPip (default) or IndexConda
+	URL         string `json:"url"`
+	Extra       bool   `json:"extra,omitempty"` // pip: add to PyPI instead of replacing it
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	PasswordEnv string `json:"password_env,omitempty"`
+}
+
+// PackageIndex is a configured private index, as listed to clients (no credentials)
+type PackageIndex struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Extra bool   `json:"extra,omitempty"`
+	Auth  bool   `json:"auth"`
+
+	username string
+	password string
+}
+
+// LoadPackageIndexes reads a JSON array of PackageIndexSpec
+func LoadPackageIndexes(path string) ([]PackageIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package index file: %w", err)
+	}
+	var specs []PackageIndexSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse package index file %s: %w", path, err)
+	}
+
+	indexes := make([]PackageIndex, 0, len(specs))
+	for _, spec := range specs {
+		index, err := spec.index()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if slices.ContainsFunc(indexes, func(i PackageIndex) bool { return i.Name == index.Name }) {
+			return nil, fmt.Errorf("%s: duplicate package index %s", path, index.Name)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// index validates the spec and resolves its password
+func (s PackageIndexSpec) index() (PackageIndex, error) {
+	if s.Name == "" {
+		return PackageIndex{}, fmt.Errorf("package index %q needs a name", s.URL)
+	}
+	if strings.ContainsFunc(s.Name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) {
+		return PackageIndex{}, fmt.Errorf("invalid package index name %q (use letters, digits, '-', '_' and '.')", s.Name)
+	}
+	if s.Type == "" {
+		s.Type = IndexPip
+	}
+	if s.Type != IndexPip && s.Type != IndexConda {
+		return PackageIndex{}, fmt.Errorf("package index %s: unknown type %q (use %s or %s)", s.Name, s.Type, IndexPip, IndexConda)
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return PackageIndex{}, fmt.Errorf("package index %s: invalid URL %q", s.Name, s.URL)
+	}
+	if u.User != nil {
+		return PackageIndex{}, fmt.Errorf("package index %s: put credentials in username and password_env, not in the URL", s.Name)
+	}
+
+	password := s.Password
+	if s.PasswordEnv != "" {
+		if password = os.Getenv(s.PasswordEnv); password == "" {
+			return PackageIndex{}, fmt.Errorf("package index %s: environment variable %s is not set", s.Name, s.PasswordEnv)
+		}
+	}
+	// pip reads the credentials from a netrc file, which cannot hold whitespace
+	if strings.ContainsAny(s.Username+password, " \t\r\n") {
+		return PackageIndex{}, fmt.Errorf("package index %s: username and password must not contain whitespace", s.Name)
+	}
+	return PackageIndex{
+		Name:     s.Name,
+		Type:     s.Type,
+		URL:      strings.TrimSuffix(s.URL, "/"),
+		Extra:    s.Extra,
+		Auth:     s.Username != "" || password != "",
+		username: s.Username,
+		password: password,
+	}, nil
+}
+
+// SetPackageIndexes configures the private indexes install_packages may use by name
+func (m *Manager) SetPackageIndexes(indexes []PackageIndex) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.packageIndexes = indexes
+}
+
+// PackageIndexes returns the configured private indexes without their credentials
+func (m *Manager) PackageIndexes() []PackageIndex {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.packageIndexes)
+}
+
+// packageIndex looks up a configured index by name and type
+func (m *Manager) packageIndex(name, indexType string) (PackageIndex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, index := range m.packageIndexes {
+		if index.Name != name {
+			continue
+		}
+		if index.Type != indexType {
+			return PackageIndex{}, fmt.Errorf("package index %s is a %s index", name, index.Type)
+		}
+		return index, nil
+	}
+	return PackageIndex{}, WithErrorCode(CodeNotFound, fmt.Errorf("package index not found: %s", name))
+}
+
+// pipIndexConfig returns the pip arguments selecting an index and the environment
+// variables carrying its credentials. Credentials go to a netrc file in the
+// environment's root directory (removed by cleanup), never to the command line.
+func pipIndexConfig(env *ManagedEnvironment, index PackageIndex) (args, environ []string, cleanup func(), err error) {
+	args = []string{"--index-url", index.URL}
+	if index.Extra {
+		args = []string{"--extra-index-url", index.URL}
+	}
+	cleanup = func() {}
+	if !index.Auth {
+		return args, nil, cleanup, nil
+	}
+
+	u, _ := url.Parse(index.URL)
+	netrc := fmt.Sprintf("machine %s\nlogin %s\npassword %s\n", u.Hostname(), index.username, index.password)
+	path := filepath.Join(env.RootDir, ".netrc-"+index.Name)
+	if err := os.WriteFile(path, []byte(netrc), 0600); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to write credentials: %w", err)
+	}
+	return args, []string{"NETRC=" + path}, func() { os.Remove(path) }, nil
+}
+
+// condaIndexConfig writes a condarc listing the channel, with its credentials, ahead of
+// conda-forge, and returns the micromamba arguments that use it instead of -c options
+func condaIndexConfig(env *ManagedEnvironment, index PackageIndex) (args []string, cleanup func(), err error) {
+	u, _ := url.Parse(index.URL)
+	if index.Auth {
+		u.User = url.UserPassword(index.username, index.password)
+	}
+	data, _ := json.Marshal(map[string][]string{"channels": {u.String(), "conda-forge"}})
+	path := filepath.Join(env.RootDir, ".condarc-"+index.Name)
+	// JSON is valid YAML
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to write channel configuration: %w", err)
+	}
+	return []string{"--rc-file", path}, func() { os.Remove(path) }, nil
+}
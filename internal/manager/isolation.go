@@ -0,0 +1,268 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Isolation backends an environment's processes can run under
+const (
+	IsolationNone       = "none"       // processes run directly on the host
+	IsolationBubblewrap = "bubblewrap" // bwrap sandbox sharing the host's /usr
+	IsolationPodman     = "podman"     // container from the sandbox image
+	IsolationDocker     = "docker"     // container from the sandbox image
+)
+
+// DefaultSandboxImage is the container image podman and docker isolation use. It only
+// needs the C library the micromamba Python was built against.
+const DefaultSandboxImage = "debian:bookworm-slim"
+
+// isolationBinaries are the executables that start each backend
+var isolationBinaries = map[string]string{
+	IsolationBubblewrap: "bwrap",
+	IsolationPodman:     "podman",
+	IsolationDocker:     "docker",
+}
+
+// sandboxHostVars are the host variables passed into a sandbox; everything else the
+// server inherited stays outside
+var sandboxHostVars = []string{"PATH", "LANG", "LANGUAGE", "TERM", "TZ", "HF_HOME", "SSL_CERT_FILE", "SSL_CERT_DIR"}
+
+// ErrNotIsolated is returned for interactive sessions in an isolated environment,
+// which cannot run inside its sandbox
+var ErrNotIsolated = errors.New("REPLs and terminals are not available in isolated environments; use run_code, run_script or spawn_process")
+
+// SetSandboxImage sets the container image of podman and docker isolation ("" restores
+// DefaultSandboxImage)
+func (m *Manager) SetSandboxImage(image string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sandboxImage = image
+}
+
+// checkIsolation validates an isolation backend and makes sure it is installed
+func checkIsolation(backend string) error {
+	switch backend {
+	case "", IsolationNone:
+		return nil
+	case IsolationBubblewrap, IsolationPodman, IsolationDocker:
+	default:
+		return fmt.Errorf("unknown isolation %q (use %s, %s, %s or %s)", backend, IsolationNone, IsolationBubblewrap, IsolationPodman, IsolationDocker)
+	}
+	if backend == IsolationBubblewrap && runtime.GOOS != "linux" {
+		return fmt.Errorf("%s isolation is only available on Linux", backend)
+	}
+	if _, err := exec.LookPath(isolationBinaries[backend]); err != nil {
+		return fmt.Errorf("%s isolation requires %s, which was not found: %w", backend, isolationBinaries[backend], err)
+	}
+	return nil
+}
+
+// sandboxMount is a host path made visible inside a sandbox at the same path
+type sandboxMount struct {
+	path     string
+	writable bool
+}
+
+// isolated reports whether the environment's processes run in a sandbox
+func (env *ManagedEnvironment) isolated() bool {
+	return env.Isolation != "" && env.Isolation != IsolationNone
+}
+
+// isolate rewrites cmd, which has not been started, to run under the environment's
+// network policy and inside its sandbox. The environment's directory (unless it is
+// locked) and the model cache are mounted read-write,
+// the base interpreters read-only and extra as given, all at their host paths, so the
+// command line is unchanged. Only the variables the server added and a few host ones reach
+// the sandbox. The returned cleanup must be called once the command has exited.
+func (m *Manager) isolate(env *ManagedEnvironment, cmd *exec.Cmd, extra ...sandboxMount) (cleanup func(), err error) {
+	helperMounts, err := m.applyNetworkPolicy(env, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if !env.isolated() {
+		return func() {}, nil
+	}
+	binary, err := exec.LookPath(isolationBinaries[env.Isolation])
+	if err != nil {
+		return nil, fmt.Errorf("%s isolation is unavailable: %w", env.Isolation, err)
+	}
+
+	m.mu.RLock()
+	image, modelCache := m.sandboxImage, m.modelCache
+	sharedDir := ""
+	if env.sharedWorkspace != "" {
+		sharedDir = env.WorkspaceDir
+	}
+	// The targets of symlink mounts are outside the mounted directories
+	var linked []sandboxMount
+	for _, mt := range env.mounts {
+		if mt.Mode == MountSymlink {
+			linked = append(linked, sandboxMount{path: mt.HostPath, writable: !mt.ReadOnly && env.checkWritable() == nil})
+		}
+	}
+	m.mu.RUnlock()
+	if image == "" {
+		image = DefaultSandboxImage
+	}
+
+	environ := cmd.Env
+	if environ == nil {
+		environ = os.Environ()
+	}
+	vars := sandboxVars(environ)
+	// A locked environment's packages and workspace are read-only inside its sandbox too
+	mounts := []sandboxMount{{path: env.RootDir, writable: env.checkWritable() == nil}}
+	if sharedDir != "" {
+		mounts = append(mounts, sandboxMount{path: sharedDir, writable: env.checkWritable() == nil})
+	}
+	if modelCache != "" {
+		mounts = append(mounts, sandboxMount{path: modelCache, writable: true})
+	}
+	if bases := filepath.Join(m.baseDir, "bases"); isDir(bases) {
+		mounts = append(mounts, sandboxMount{path: bases})
+	}
+	mounts = append(append(append(mounts, linked...), helperMounts...), extra...)
+	network := env.networkPolicy().Mode == NetworkAllow
+	dir := cmd.Dir
+	if dir == "" {
+		dir = commandDir(env)
+	}
+	command := append([]string{cmd.Path}, cmd.Args[1:]...)
+
+	var args []string
+	cleanup = func() {}
+	switch env.Isolation {
+	case IsolationBubblewrap:
+		args = bubblewrapArgs(mounts, dir, network)
+		cmd.Env = vars
+	default:
+		name := "jumpboot-" + uuid.New().String()
+		args = containerArgs(env.Isolation, name, image, mounts, dir, vars, network)
+		// Values stay in the CLI's environment, so they never appear on a command line
+		cmd.Env = environ
+		// Killing the CLI does not stop the container
+		cleanup = func() {
+			exec.Command(binary, "rm", "-f", name).Run()
+		}
+	}
+
+	cmd.Path = binary
+	cmd.Args = append(append([]string{binary}, args...), command...)
+	return cleanup, nil
+}
+
+// runIsolated runs cmd inside the environment's sandbox and returns its combined
+// output, like runCommand
+func (m *Manager) runIsolated(ctx context.Context, env *ManagedEnvironment, cmd *exec.Cmd, extra ...sandboxMount) (string, error) {
+	cleanup, err := m.isolate(env, cmd, extra...)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	return runCommand(ctx, cmd)
+}
+
+// sandboxVars returns the variables of environ that the server added, plus the
+// allowed host ones, with HOME pointing into the sandbox. Later entries win.
+func sandboxVars(environ []string) []string {
+	host := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		host[kv] = true
+	}
+	values := make(map[string]string)
+	var keys []string
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			continue
+		}
+		allowed := slices.Contains(sandboxHostVars, key) || strings.HasPrefix(key, "LC_")
+		if host[kv] && !allowed {
+			continue
+		}
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	values["HOME"] = "/tmp"
+	if !slices.Contains(keys, "HOME") {
+		keys = append(keys, "HOME")
+	}
+
+	vars := make([]string, 0, len(keys))
+	for _, key := range keys {
+		vars = append(vars, key+"="+values[key])
+	}
+	return vars
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// bubblewrapArgs returns the bwrap options of a sandbox that sees the host's system
+// directories read-only and a private /tmp
+func bubblewrapArgs(mounts []sandboxMount, dir string, network bool) []string {
+	args := []string{"--die-with-parent", "--new-session", "--unshare-pid", "--unshare-ipc", "--unshare-uts"}
+	if !network {
+		args = append(args, "--unshare-net")
+	}
+	for _, path := range []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc"} {
+		args = append(args, "--ro-bind-try", path, path)
+	}
+	args = append(args, "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp")
+	// Mounted after /tmp, which temporary scripts usually live under
+	for _, mount := range mounts {
+		if mount.writable {
+			args = append(args, "--bind", mount.path, mount.path)
+		} else {
+			args = append(args, "--ro-bind", mount.path, mount.path)
+		}
+	}
+	return append(args, "--chdir", dir, "--")
+}
+
+// containerArgs returns the podman or docker run options of a throwaway container
+// named name. Variables are passed by name only.
+func containerArgs(backend, name, image string, mounts []sandboxMount, dir string, vars []string, network bool) []string {
+	args := []string{"run", "--rm", "-i", "--init", "--name", name}
+	if backend == IsolationPodman {
+		// Rootless podman maps the user to itself, keeping workspace files owned by it
+		args = append(args, "--userns=keep-id")
+	} else if uid := os.Getuid(); uid >= 0 {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, os.Getgid()))
+	}
+	if !network {
+		args = append(args, "--network", "none")
+	}
+	for _, mount := range mounts {
+		spec := fmt.Sprintf("type=bind,src=%s,dst=%s", mount.path, mount.path)
+		if !mount.writable {
+			spec += ",readonly"
+		}
+		args = append(args, "--mount", spec)
+	}
+	args = append(args, "-w", dir)
+	for _, kv := range vars {
+		key, _, _ := strings.Cut(kv, "=")
+		if key == "HOME" {
+			args = append(args, "-e", kv)
+			continue
+		}
+		args = append(args, "-e", key)
+	}
+	return append(args, image)
+}
@@ -0,0 +1,247 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bounds of job priorities; higher runs first
+const (
+	MinJobPriority = -100
+	MaxJobPriority = 100
+)
+
+// JobLimits caps how many queued jobs run at once. Zero fields are unlimited.
+type JobLimits struct {
+	Running        int `json:"running"`         // jobs running on the server
+	PerEnvironment int `json:"per_environment"` // jobs running against one environment
+	PerSession     int `json:"per_session"`     // jobs running for one MCP session
+}
+
+// JobOptions describe a job submitted to the queue
+type JobOptions struct {
+	EnvID    string // environment the job runs against, for the per-environment limit
+	Priority int    // MinJobPriority..MaxJobPriority, higher first
+}
+
+// jobQueue holds submitted jobs until the limits let them run. It is protected by
+// Manager.queueMu.
+type jobQueue struct {
+	limits    JobLimits
+	queued    []*Job
+	seq       uint64
+	running   int
+	byEnv     map[string]int
+	bySession map[string]int
+}
+
+// JobQueueInfo is the state of the job queue reported with a job's status
+type JobQueueInfo struct {
+	Queued    int       `json:"queued"`
+	Running   int       `json:"running"`
+	Limits    JobLimits `json:"limits"`
+	BlockedBy string    `json:"blocked_by,omitempty"` // limit keeping a queued job waiting
+}
+
+// SetJobLimits configures how many queued jobs may run at once
+func (m *Manager) SetJobLimits(limits JobLimits) {
+	m.queueMu.Lock()
+	m.queue.limits = limits
+	m.queueMu.Unlock()
+	m.dispatchJobs()
+}
+
+// SubmitJob queues fn as a background job and returns it immediately. Jobs start in
+// order of priority and, within a priority, sessions with fewer running jobs go first,
+// then the earliest submitted. A job waits while starting it would exceed the
+// JobLimits. fn and hook work as for StartJob.
+func (m *Manager) SubmitJob(ctx context.Context, kind string, opts JobOptions, hook *Webhook, fn func(ctx context.Context) (any, error)) (*JobInfo, error) {
+	if opts.Priority < MinJobPriority || opts.Priority > MaxJobPriority {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("priority must be between %d and %d", MinJobPriority, MaxJobPriority))
+	}
+	hook, err := m.checkWebhook(hook)
+	if err != nil {
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	job := &Job{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Owner:     ownerFor(ctx),
+		EnvID:     opts.EnvID,
+		Priority:  opts.Priority,
+		session:   CallerFromContext(ctx).SessionID,
+		status:    JobQueued,
+		createdAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		hook:      hook,
+	}
+	job.run = func() {
+		defer cancel()
+		result, err := fn(jobCtx)
+		job.finish(result, err, m.createdEnvironment(result))
+		m.jobStopped(job)
+		m.fireWebhook(hook, WebhookEvent{Event: EventJobFinished, Job: job.info()})
+	}
+
+	m.mu.Lock()
+	m.pruneJobs()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	m.queueMu.Lock()
+	m.queue.seq++
+	job.seq = m.queue.seq
+	m.queue.queued = append(m.queue.queued, job)
+	m.queueMu.Unlock()
+
+	m.dispatchJobs()
+	return m.jobInfo(job), nil
+}
+
+// orderedQueue sorts the queued jobs in the order they start. Callers must hold
+// m.queueMu.
+func (m *Manager) orderedQueue() []*Job {
+	q := &m.queue
+	slices.SortStableFunc(q.queued, func(a, b *Job) int {
+		if a.Priority != b.Priority {
+			return b.Priority - a.Priority
+		}
+		if ra, rb := q.bySession[a.session], q.bySession[b.session]; ra != rb {
+			return ra - rb
+		}
+		return int(a.seq) - int(b.seq)
+	})
+	return q.queued
+}
+
+// blockedBy returns the limit that keeps job from starting, or "". Callers must hold
+// m.queueMu.
+func (m *Manager) blockedBy(job *Job) string {
+	q := &m.queue
+	switch {
+	case q.limits.Running > 0 && q.running >= q.limits.Running:
+		return "running"
+	case q.limits.PerEnvironment > 0 && job.EnvID != "" && q.byEnv[job.EnvID] >= q.limits.PerEnvironment:
+		return "per_environment"
+	case q.limits.PerSession > 0 && job.session != "" && q.bySession[job.session] >= q.limits.PerSession:
+		return "per_session"
+	}
+	return ""
+}
+
+// dispatchJobs starts the queued jobs the limits allow. A job blocked by its
+// environment or session does not hold up the jobs behind it.
+func (m *Manager) dispatchJobs() {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	q := &m.queue
+	for {
+		started := false
+		for i, job := range m.orderedQueue() {
+			if m.blockedBy(job) != "" {
+				continue
+			}
+			q.queued = slices.Delete(q.queued, i, i+1)
+			job.mu.Lock()
+			if job.status != JobQueued {
+				// Cancelled while queued; CancelJob finished it
+				job.mu.Unlock()
+				started = true
+				break
+			}
+			job.status = JobRunning
+			job.startedAt = time.Now()
+			job.mu.Unlock()
+
+			q.running++
+			q.byEnv[job.EnvID]++
+			q.bySession[job.session]++
+			go job.run()
+			// Counts changed, so the order may have too
+			started = true
+			break
+		}
+		if !started {
+			return
+		}
+	}
+}
+
+// jobStopped frees the slot of a job that ran and starts the next ones
+func (m *Manager) jobStopped(job *Job) {
+	m.queueMu.Lock()
+	q := &m.queue
+	q.running--
+	if q.byEnv[job.EnvID]--; q.byEnv[job.EnvID] <= 0 {
+		delete(q.byEnv, job.EnvID)
+	}
+	if q.bySession[job.session]--; q.bySession[job.session] <= 0 {
+		delete(q.bySession, job.session)
+	}
+	m.queueMu.Unlock()
+	m.dispatchJobs()
+}
+
+// unqueueJob removes a job cancelled before it started from the queue and
+// finishes it
+func (m *Manager) unqueueJob(job *Job) {
+	m.queueMu.Lock()
+	if i := slices.Index(m.queue.queued, job); i >= 0 {
+		m.queue.queued = slices.Delete(m.queue.queued, i, i+1)
+	}
+	m.queueMu.Unlock()
+	job.cancel()
+	close(job.done)
+	m.fireWebhook(job.hook, WebhookEvent{Event: EventJobFinished, Job: job.info()})
+}
+
+// jobInfo returns the status of a job with its place in the queue
+func (m *Manager) jobInfo(job *Job) *JobInfo {
+	info := job.info()
+	if job.run == nil {
+		return info // started with StartJob, outside the queue
+	}
+
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	q := &m.queue
+	info.Queue = &JobQueueInfo{Queued: len(q.queued), Running: q.running, Limits: q.limits}
+	if info.Status == JobQueued {
+		if i := slices.Index(m.orderedQueue(), job); i >= 0 {
+			info.QueuePosition = i + 1
+			info.Queue.BlockedBy = m.blockedBy(job)
+		}
+	}
+	return info
+}
+
+// clearJobQueue cancels the jobs that have not started. Called by Shutdown.
+func (m *Manager) clearJobQueue() {
+	m.queueMu.Lock()
+	queued := m.queue.queued
+	m.queue.queued = nil
+	m.queueMu.Unlock()
+
+	for _, job := range queued {
+		job.mu.Lock()
+		wasQueued := job.status == JobQueued
+		if wasQueued {
+			job.status = JobCancelled
+			job.finishedAt = time.Now()
+		}
+		job.mu.Unlock()
+		// A job cancelled meanwhile is finished by CancelJob
+		if wasQueued {
+			job.cancel()
+			close(job.done)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobRetention is how long finished jobs are kept before they are pruned
+const JobRetention = time.Hour
+
+// Job states
+const (
+	JobQueued     = "queued"
+	JobRunning    = "running"
+	JobCancelling = "cancelling" // cancelled while running, until its work stops
+	JobSucceeded  = "succeeded"
+	JobFailed     = "failed"
+	JobCancelled  = "cancelled"
+)
+
+// Job is a long operation running in the background
+type Job struct {
+	ID       string
+	Kind     string // operation name, e.g. "create_environment"
+	Owner    string
+	EnvID    string // set for queued jobs that run against an environment
+	Priority int
+
+	mu         sync.Mutex // protects the fields below
+	status     string
+	err        error
+	result     any
+	createdAt  time.Time
+	startedAt  time.Time // when a queued job started
+	finishedAt time.Time
+	createdEnv string // environment the job created that still existed when it finished
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Set for jobs submitted with SubmitJob
+	run     func() // runs the job once the queue starts it
+	hook    *Webhook
+	session string // MCP session, for the per-session limit
+	seq     uint64 // submission order
+}
+
+// JobInfo is the serializable status of a job
+type JobInfo struct {
+	ID            string        `json:"id"`
+	Kind          string        `json:"kind"`
+	Status        string        `json:"status"`
+	Error         string        `json:"error,omitempty"`
+	Owner         string        `json:"owner,omitempty"`
+	EnvID         string        `json:"env_id,omitempty"`
+	Priority      int           `json:"priority,omitempty"`
+	QueuePosition int           `json:"queue_position,omitempty"` // 1 = starts next, while queued
+	Queue         *JobQueueInfo `json:"queue,omitempty"`          // jobs submitted to the queue
+	CreatedEnvID  string        `json:"created_env_id,omitempty"` // environment the job created, even if cancelled
+	CreatedAt     time.Time     `json:"created_at"`
+	StartedAt     *time.Time    `json:"started_at,omitempty"`
+	FinishedAt    *time.Time    `json:"finished_at,omitempty"`
+}
+
+// info returns the serializable status of the job
+func (j *Job) info() *JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := &JobInfo{
+		ID:           j.ID,
+		Kind:         j.Kind,
+		Status:       j.status,
+		Owner:        j.Owner,
+		EnvID:        j.EnvID,
+		Priority:     j.Priority,
+		CreatedEnvID: j.createdEnv,
+		CreatedAt:    j.createdAt,
+	}
+	if j.err != nil {
+		info.Error = j.err.Error()
+	}
+	if !j.startedAt.IsZero() {
+		started := j.startedAt
+		info.StartedAt = &started
+	}
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		info.FinishedAt = &finished
+	}
+	return info
+}
+
+// finish records the outcome of the job and the environment it created, if any. A
+// cancelled job that still completes succeeds, since its work was done.
+func (j *Job) finish(result any, err error, createdEnv string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case err == nil:
+		j.status = JobSucceeded
+		j.result = result
+	case j.status == JobCancelling:
+		j.status = JobCancelled
+		j.err = err
+	default:
+		j.status = JobFailed
+		j.err = err
+	}
+	j.createdEnv = createdEnv
+	j.finishedAt = time.Now()
+	close(j.done)
+}
+
+// createdEnvironment returns the environment a job result reports creating, if it
+// still exists
+func (m *Manager) createdEnvironment(result any) string {
+	var id string
+	switch r := result.(type) {
+	case *EnvironmentInfo:
+		if r != nil {
+			id = r.ID
+		}
+	case *ManifestResult:
+		if r != nil && r.Created {
+			id = r.EnvID
+		}
+	}
+	if id == "" {
+		return ""
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.environments[id]; !ok {
+		return ""
+	}
+	return id
+}
+
+// StartJob runs fn in the background and returns the new job immediately.
+// fn receives a context that keeps the caller of ctx but is cancelled by CancelJob
+// instead of by the end of the originating request. hook, if not nil, is notified
+// when the job finishes.
+func (m *Manager) StartJob(ctx context.Context, kind string, hook *Webhook, fn func(ctx context.Context) (any, error)) (*JobInfo, error) {
+	hook, err := m.checkWebhook(hook)
+	if err != nil {
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	job := &Job{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Owner:     ownerFor(ctx),
+		status:    JobRunning,
+		createdAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.pruneJobs()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := fn(jobCtx)
+		job.finish(result, err, m.createdEnvironment(result))
+		m.fireWebhook(hook, WebhookEvent{Event: EventJobFinished, Job: job.info()})
+	}()
+
+	return job.info(), nil
+}
+
+// pruneJobs removes jobs that finished more than JobRetention ago. Callers must hold m.mu.
+func (m *Manager) pruneJobs() {
+	cutoff := time.Now().Add(-JobRetention)
+	for id, job := range m.jobs {
+		job.mu.Lock()
+		expired := !job.finishedAt.IsZero() && job.finishedAt.Before(cutoff)
+		job.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// getJob returns a job visible to the caller in ctx
+func (m *Manager) getJob(ctx context.Context, id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok || !m.canAccess(ctx, job.Owner) {
+		return nil, notFound("job", id)
+	}
+	return job, nil
+}
+
+// CheckJobAccess returns an error if the caller in ctx may not use the job
+func (m *Manager) CheckJobAccess(ctx context.Context, id string) error {
+	_, err := m.getJob(ctx, id)
+	return err
+}
+
+// GetJobStatus returns the status of a job
+func (m *Manager) GetJobStatus(ctx context.Context, id string) (*JobInfo, error) {
+	job, err := m.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return m.jobInfo(job), nil
+}
+
+// GetJobResult returns the result of a finished job, waiting up to wait for it to finish.
+// A failed job returns its error; a job still running after wait returns an error.
+func (m *Manager) GetJobResult(ctx context.Context, id string, wait time.Duration) (*JobInfo, any, error) {
+	job, err := m.getJob(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-job.done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	info := job.info()
+	switch info.Status {
+	case JobSucceeded:
+		job.mu.Lock()
+		result := job.result
+		job.mu.Unlock()
+		return info, result, nil
+	case JobFailed:
+		job.mu.Lock()
+		jobErr := job.err
+		job.mu.Unlock()
+		// Wrap the original error so structured details survive
+		return info, nil, fmt.Errorf("job %s failed: %w", id, jobErr)
+	case JobCancelled:
+		if info.CreatedEnvID != "" {
+			return info, nil, fmt.Errorf("job %s was cancelled, but environment %s it created remains", id, info.CreatedEnvID)
+		}
+		return info, nil, fmt.Errorf("job %s was cancelled", id)
+	case JobCancelling:
+		return info, nil, fmt.Errorf("job %s is being cancelled", id)
+	case JobQueued:
+		return m.jobInfo(job), nil, fmt.Errorf("job %s is queued", id)
+	default:
+		return info, nil, fmt.Errorf("job %s is still running", id)
+	}
+}
+
+// CancelJob cancels a queued or running job. A queued job is removed from the queue
+// and is cancelled at once. A running job's context is cancelled and the job stays
+// cancelling until its work stops; it then reports any environment it created.
+func (m *Manager) CancelJob(ctx context.Context, id string) (*JobInfo, error) {
+	job, err := m.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	job.mu.Lock()
+	status := job.status
+	if status != JobRunning && status != JobQueued {
+		job.mu.Unlock()
+		return nil, fmt.Errorf("job %s already %s", id, status)
+	}
+	if status == JobQueued {
+		job.status = JobCancelled
+		job.finishedAt = time.Now()
+	} else {
+		job.status = JobCancelling
+	}
+	job.mu.Unlock()
+
+	if status == JobQueued {
+		m.unqueueJob(job)
+	} else {
+		job.cancel()
+	}
+	return m.jobInfo(job), nil
+}
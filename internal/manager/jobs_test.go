@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelJobWaitsForWorkToStop(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// The job creates an environment and ignores cancellation for a moment
+	m.mu.Lock()
+	m.environments["env-1"] = &ManagedEnvironment{ID: "env-1"}
+	m.mu.Unlock()
+	stop := make(chan struct{})
+	job, err := m.StartJob(ctx, "create_environment", nil, func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		<-stop
+		return &EnvironmentInfo{ID: "env-1"}, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := m.CancelJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != JobCancelling || info.FinishedAt != nil {
+		t.Fatalf("status %s, finished %v; want cancelling and not finished", info.Status, info.FinishedAt)
+	}
+	if _, err := m.CancelJob(ctx, job.ID); err == nil {
+		t.Error("a cancelling job was cancelled again")
+	}
+	if _, _, err := m.GetJobResult(ctx, job.ID, 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "being cancelled") {
+		t.Errorf("job_result while cancelling: %v", err)
+	}
+	m.mu.RLock()
+	running := m.runningJobs()
+	m.mu.RUnlock()
+	if running != 1 {
+		t.Errorf("drain counts %d running jobs, want the cancelling one", running)
+	}
+
+	close(stop)
+	info, _, err = m.GetJobResult(ctx, job.ID, 5*time.Second)
+	if info.Status != JobCancelled {
+		t.Fatalf("status %s, want cancelled", info.Status)
+	}
+	if info.CreatedEnvID != "env-1" || err == nil || !strings.Contains(err.Error(), "env-1") {
+		t.Errorf("created_env_id %q, error %v; want env-1 reported", info.CreatedEnvID, err)
+	}
+	if info.Error != context.Canceled.Error() {
+		t.Errorf("error %q, want the job's own error", info.Error)
+	}
+}
+
+func TestCancelJobCompletedAnyway(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	stop := make(chan struct{})
+	job, err := m.StartJob(ctx, "test", nil, func(ctx context.Context) (any, error) {
+		<-stop
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CancelJob(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+
+	info, result, err := m.GetJobResult(ctx, job.ID, 5*time.Second)
+	if err != nil || info.Status != JobSucceeded || result != "done" {
+		t.Errorf("got %s %v %v, want the job's result", info.Status, result, err)
+	}
+}
+
+func TestCancelJobDiscardedEnvironmentNotReported(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// The environment the result names no longer exists
+	job, err := m.StartJob(ctx, "create_environment", nil, func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return &EnvironmentInfo{ID: "gone"}, errors.New("cancelled and destroyed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CancelJob(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	info, _, err := m.GetJobResult(ctx, job.ID, 5*time.Second)
+	if info.Status != JobCancelled || info.CreatedEnvID != "" || err == nil {
+		t.Errorf("got %s, created_env_id %q, %v", info.Status, info.CreatedEnvID, err)
+	}
+}
@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Limits of environment labels
+const (
+	maxLabels           = 64
+	maxLabelKeyLength   = 63
+	maxLabelValueLength = 256
+)
+
+// labelKeyPattern matches label keys: letters, digits and . _ / - , starting with a
+// letter or digit
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// labelValuePattern matches label values, which may be empty
+var labelValuePattern = regexp.MustCompile(`^[A-Za-z0-9._/:@+-]*$`)
+
+// validateLabel rejects keys and values a label selector could not express
+func validateLabel(key, value string) error {
+	if len(key) > maxLabelKeyLength || !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: use up to %d letters, digits and . _ / -, starting with a letter or digit", key, maxLabelKeyLength)
+	}
+	if len(value) > maxLabelValueLength || !labelValuePattern.MatchString(value) {
+		return fmt.Errorf("invalid value for label %s: use up to %d letters, digits and . _ / : @ + -", key, maxLabelValueLength)
+	}
+	return nil
+}
+
+// validateLabels validates every label of a map
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("too many labels (%d, at most %d)", len(labels), maxLabels)
+	}
+	for key, value := range labels {
+		if err := validateLabel(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// labelsOf returns a copy of the environment's labels
+func (env *ManagedEnvironment) labelsOf() map[string]string {
+	env.configMu.RLock()
+	defer env.configMu.RUnlock()
+	return maps.Clone(env.labels)
+}
+
+// LabelsUpdate describes a change of an environment's labels
+type LabelsUpdate struct {
+	Labels  map[string]string // labels to set
+	Unset   []string          // labels to remove
+	Replace bool              // remove all existing labels first
+}
+
+// EnvironmentLabelsInfo lists an environment's labels
+type EnvironmentLabelsInfo struct {
+	EnvID  string            `json:"env_id"`
+	Labels map[string]string `json:"labels"`
+}
+
+// SetEnvironmentLabels updates an environment's labels. Labels are bookkeeping for the
+// agents managing the environment, so they can be changed while it is locked.
+func (m *Manager) SetEnvironmentLabels(envID string, update LabelsUpdate) (*EnvironmentLabelsInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range update.Labels {
+		if err := validateLabel(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	env.configMu.Lock()
+	labels := maps.Clone(env.labels)
+	if update.Replace {
+		labels = nil
+	}
+	for key, value := range update.Labels {
+		labels = setVar(labels, key, value)
+	}
+	for _, key := range update.Unset {
+		delete(labels, key)
+	}
+	if len(labels) > maxLabels {
+		env.configMu.Unlock()
+		return nil, fmt.Errorf("too many labels (%d, at most %d)", len(labels), maxLabels)
+	}
+	env.labels = labels
+	env.configMu.Unlock()
+
+	return &EnvironmentLabelsInfo{EnvID: envID, Labels: env.labelsOf()}, nil
+}
+
+// labelRequirement is one comma-separated term of a label selector
+type labelRequirement struct {
+	key    string
+	op     string // "=", "!=", "exists" or "!exists"
+	values []string
+}
+
+// LabelSelector selects environments by their labels. Every requirement must hold.
+type LabelSelector []labelRequirement
+
+// ParseLabelSelector parses a comma-separated selector of key=value (or key==value),
+// key!=value, key in (a,b), key (the label is set) and !key (it is not) requirements.
+// The empty selector matches everything.
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, term := range splitSelector(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var req labelRequirement
+		switch {
+		case strings.HasPrefix(term, "!"):
+			req = labelRequirement{key: strings.TrimSpace(term[1:]), op: "!exists"}
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			req = labelRequirement{key: strings.TrimSpace(key), op: "!=", values: []string{strings.TrimSpace(value)}}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			value = strings.TrimPrefix(value, "=")
+			req = labelRequirement{key: strings.TrimSpace(key), op: "=", values: []string{strings.TrimSpace(value)}}
+		case strings.Contains(term, " in "):
+			key, set, _ := strings.Cut(term, " in ")
+			set = strings.TrimSpace(set)
+			if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+				return nil, fmt.Errorf("invalid label selector term %q: write key in (a,b)", term)
+			}
+			req = labelRequirement{key: strings.TrimSpace(key), op: "="}
+			for _, value := range strings.Split(set[1:len(set)-1], ",") {
+				req.values = append(req.values, strings.TrimSpace(value))
+			}
+		default:
+			req = labelRequirement{key: term, op: "exists"}
+		}
+		for _, value := range append([]string{""}, req.values...) {
+			if err := validateLabel(req.key, value); err != nil {
+				return nil, fmt.Errorf("invalid label selector term %q: %w", term, err)
+			}
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// splitSelector splits a selector at the commas outside parentheses
+func splitSelector(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+// Matches reports whether labels satisfy every requirement of the selector
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.key]
+		switch req.op {
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		case "=":
+			if !ok || !slices.Contains(req.values, value) {
+				return false
+			}
+		case "!=":
+			if ok && value == req.values[0] {
+				return false
+			}
+		}
+	}
+	return true
+}
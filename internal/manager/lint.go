@@ -0,0 +1,247 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityNote    = "note"
+)
+
+// Diagnostic is a single finding reported by a linter or type checker
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+	Fixable  bool   `json:"fixable,omitempty"`
+}
+
+// CheckResult is the outcome of running a linter or type checker over workspace files
+type CheckResult struct {
+	Tool        string       `json:"tool"`
+	Installed   bool         `json:"installed,omitempty"` // the tool was installed on demand
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Output      string       `json:"output,omitempty"` // tool output that is not a diagnostic
+}
+
+// count tallies the diagnostics by severity
+func (r *CheckResult) count() {
+	for _, d := range r.Diagnostics {
+		switch d.Severity {
+		case SeverityError:
+			r.Errors++
+		case SeverityWarning:
+			r.Warnings++
+		}
+	}
+}
+
+// mypyLine matches "file:line:col: severity: message  [code]"
+var mypyLine = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)? (error|warning|note): (.*?)(?:  \[([a-z0-9-]+)\])?$`)
+
+// LintWorkspace runs ruff over paths in the workspace (the whole workspace when
+// paths is empty), installing ruff into the environment if needed. With fix set,
+// ruff applies safe fixes and reports only what remains.
+func (m *Manager) LintWorkspace(ctx context.Context, envID string, paths []string, fix bool) (*CheckResult, error) {
+	args := []string{"-m", "ruff", "check", "--output-format", "json", "--exit-zero", "--no-cache"}
+	if fix {
+		env, err := m.GetEnvironment(envID)
+		if err != nil {
+			return nil, err
+		}
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+		args = append(args, "--fix")
+	}
+
+	result := &CheckResult{Tool: "ruff"}
+	stdout, stderr, err := m.runCheckTool(ctx, envID, "ruff", paths, args, result)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []struct {
+		Code     *string `json:"code"`
+		Message  string  `json:"message"`
+		Filename string  `json:"filename"`
+		Location struct {
+			Row    int `json:"row"`
+			Column int `json:"column"`
+		} `json:"location"`
+		Fix *json.RawMessage `json:"fix"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &findings); err != nil {
+		return nil, fmt.Errorf("failed to parse ruff output: %w\nOutput: %s%s", err, stdout, stderr)
+	}
+
+	env, _ := m.GetEnvironment(envID)
+	result.Diagnostics = make([]Diagnostic, 0, len(findings))
+	for _, f := range findings {
+		d := Diagnostic{
+			File:     workspaceRelative(env.WorkspaceDir, f.Filename),
+			Line:     f.Location.Row,
+			Column:   f.Location.Column,
+			Severity: SeverityWarning,
+			Message:  f.Message,
+			Fixable:  f.Fix != nil,
+		}
+		// Syntax errors have no rule code
+		if f.Code == nil || *f.Code == "E999" {
+			d.Severity = SeverityError
+		} else {
+			d.Code = *f.Code
+		}
+		result.Diagnostics = append(result.Diagnostics, d)
+	}
+	result.Output = strings.TrimSpace(stderr)
+	result.count()
+	return result, nil
+}
+
+// TypeCheckWorkspace runs mypy over paths in the workspace (the whole workspace when
+// paths is empty), installing mypy into the environment if needed
+func (m *Manager) TypeCheckWorkspace(ctx context.Context, envID string, paths []string, strict bool) (*CheckResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the cache out of the workspace
+	args := []string{"-m", "mypy", "--show-column-numbers", "--show-error-codes", "--no-error-summary",
+		"--no-color-output", "--no-pretty", "--cache-dir", filepath.Join(env.RootDir, ".mypy_cache")}
+	if strict {
+		args = append(args, "--strict")
+	}
+
+	result := &CheckResult{Tool: "mypy"}
+	// mypy exits non-zero when it reports errors, so only output-less failures are errors
+	stdout, stderr, err := m.runCheckTool(ctx, envID, "mypy", paths, args, result)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Diagnostics = []Diagnostic{}
+	var other []string
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	for scanner.Scan() {
+		line := scanner.Text()
+		match := mypyLine.FindStringSubmatch(line)
+		if match == nil {
+			if strings.TrimSpace(line) != "" {
+				other = append(other, line)
+			}
+			continue
+		}
+		lineNo, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		result.Diagnostics = append(result.Diagnostics, Diagnostic{
+			File:     filepath.ToSlash(match[1]),
+			Line:     lineNo,
+			Column:   column,
+			Severity: match[4],
+			Code:     match[6],
+			Message:  match[5],
+		})
+	}
+	if s := strings.TrimSpace(stderr); s != "" {
+		other = append(other, s)
+	}
+	result.Output = strings.Join(other, "\n")
+	result.count()
+	return result, nil
+}
+
+// runCheckTool installs module into the environment if it is missing, then runs the
+// interpreter with args followed by paths from the workspace, under the environment's
+// network policy and inside its sandbox if it is isolated. It returns stdout and
+// stderr separately so machine-readable output is not mixed with warnings. A non-zero
+// exit is only an error when the tool printed nothing on stdout.
+func (m *Manager) runCheckTool(ctx context.Context, envID, module string, paths, args []string, result *CheckResult) (string, string, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return "", "", err
+	}
+	if env.WorkspaceDir == "" {
+		return "", "", fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+
+	// Validate the paths before installing anything
+	for _, p := range paths {
+		if _, err := safeJoinPath(env.WorkspaceDir, p); err != nil {
+			return "", "", err
+		}
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	if _, err := m.runIsolated(ctx, env, commandContext(ctx, env.Env.PythonPath, "-m", module, "--version")); err != nil {
+		if errors.Is(err, ErrCancelled) {
+			return "", "", err
+		}
+		if err := m.InstallPackages(ctx, envID, []string{module}, false); err != nil {
+			return "", "", fmt.Errorf("failed to install %s: %w", module, err)
+		}
+		result.Installed = true
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return "", "", err
+	}
+	defer unlock()
+
+	// Plugins and hooks in the workspace's configuration run as part of the tool
+	cmd := commandContext(ctx, env.Env.PythonPath, append(args, paths...)...)
+	cmd.Dir = env.WorkspaceDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cleanup, err := m.isolate(env, cmd)
+	if err != nil {
+		return "", "", err
+	}
+	err = cmd.Run()
+	cleanup()
+	if err := checkCancelled(ctx); err != nil {
+		return "", "", err
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", "", fmt.Errorf("failed to run %s: %w", module, err)
+		}
+		if strings.TrimSpace(stdout.String()) == "" {
+			return "", "", fmt.Errorf("%s failed: %w\nOutput: %s", module, err, stderr.String())
+		}
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// workspaceRelative returns path relative to the workspace when it is inside it
+func workspaceRelative(workspace, path string) string {
+	if rel, err := filepath.Rel(workspace, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
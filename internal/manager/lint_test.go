@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richinsley/jumpboot"
+)
+
+func TestCheckToolRunsInSandbox(t *testing.T) {
+	record := fakeSandbox(t)
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	env := &ManagedEnvironment{
+		ID:           "env",
+		RootDir:      root,
+		WorkspaceDir: filepath.Join(root, "workspace"),
+		Env:          &jumpboot.PythonEnvironment{PythonPath: filepath.Join(root, "bin", "python")},
+		Isolation:    IsolationBubblewrap,
+		network:      envNetwork{policy: NetworkPolicy{Mode: NetworkDeny}},
+	}
+	if err := os.Mkdir(env.WorkspaceDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.environments[env.ID] = env
+	m.mu.Unlock()
+
+	var result CheckResult
+	if _, _, err := m.runCheckTool(context.Background(), env.ID, "mypy", nil, []string{"-m", "mypy"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Installed {
+		t.Error("mypy was installed although the sandboxed probe succeeded")
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("mypy did not run through bwrap: %v", err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{"--", env.Env.PythonPath, "-m", "mypy", "."}
+	if len(args) < len(want) || strings.Join(args[len(args)-len(want):], " ") != strings.Join(want, " ") {
+		t.Errorf("bwrap did not run mypy on the workspace: %q", args)
+	}
+	if !strings.Contains(strings.Join(args, " "), "--unshare-net") {
+		t.Errorf("mypy has the network despite the deny policy: %q", args)
+	}
+}
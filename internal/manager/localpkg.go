@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localArchiveSuffixes are the file names pip installs as wheels or sdists
+var localArchiveSuffixes = []string{".whl", ".tar.gz", ".tgz", ".tar.bz2", ".zip"}
+
+// isLocalPackage reports whether a package entry names a file or directory rather
+// than a requirement: a relative or absolute path, or a wheel/sdist file name
+func isLocalPackage(entry string) bool {
+	if strings.Contains(entry, "://") {
+		return false
+	}
+	if entry == "." || entry == ".." || strings.HasPrefix(entry, "./") || strings.HasPrefix(entry, "../") ||
+		strings.HasPrefix(entry, `.\`) || strings.HasPrefix(entry, `..\`) || filepath.IsAbs(entry) {
+		return true
+	}
+	path, _ := splitExtras(entry)
+	for _, suffix := range localArchiveSuffixes {
+		if strings.HasSuffix(strings.ToLower(path), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitExtras splits "path[extra1,extra2]" into the path and "[extra1,extra2]"
+func splitExtras(entry string) (string, string) {
+	if strings.HasSuffix(entry, "]") {
+		if i := strings.LastIndex(entry, "["); i > 0 {
+			return entry[:i], entry[i:]
+		}
+	}
+	return entry, ""
+}
+
+// editablePath returns the path of an editable entry ("-e ./repo" or
+// "--editable=./repo")
+func editablePath(entry string) (string, bool) {
+	for _, prefix := range []string{"--editable=", "--editable ", "-e=", "-e "} {
+		if rest, ok := strings.CutPrefix(entry, prefix); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
+
+// resolvePackageArgs turns install_packages entries that refer to workspace files,
+// such as "./dist/pkg-1.0-py3-none-any.whl" or "-e ./repo", into pip arguments with
+// absolute paths. Paths must stay inside the workspace and exist; other entries are
+// returned unchanged.
+func resolvePackageArgs(env *ManagedEnvironment, packages []string) ([]string, error) {
+	args := make([]string, 0, len(packages))
+	for _, entry := range packages {
+		entry = strings.TrimSpace(entry)
+		path, editable := editablePath(entry)
+		switch {
+		case editable && strings.Contains(path, "://"):
+			// Editable VCS checkout such as "-e git+https://..."
+			args = append(args, "-e", path)
+			continue
+		case !editable:
+			if !isLocalPackage(entry) {
+				args = append(args, entry)
+				continue
+			}
+			path = entry
+		}
+
+		resolved, err := resolveWorkspacePackage(env, path)
+		if err != nil {
+			return nil, err
+		}
+		if editable {
+			args = append(args, "-e")
+		}
+		args = append(args, resolved)
+	}
+	return args, nil
+}
+
+// resolveWorkspacePackage validates a package path relative to the workspace (extras
+// allowed) and returns it as an absolute path
+func resolveWorkspacePackage(env *ManagedEnvironment, entry string) (string, error) {
+	if env.WorkspaceDir == "" {
+		return "", fmt.Errorf("no workspace created for environment: %s (needed to install %s)", env.ID, entry)
+	}
+	path, extras := splitExtras(entry)
+
+	var full string
+	switch {
+	case filepath.IsAbs(path):
+		full = filepath.Clean(path)
+		if full != filepath.Clean(env.WorkspaceDir) && !isSubPath(env.WorkspaceDir, full) {
+			return "", fmt.Errorf("package path outside the workspace: %s", path)
+		}
+	case filepath.Clean(path) == ".":
+		full = env.WorkspaceDir
+	default:
+		var err error
+		if full, err = safeJoinPath(env.WorkspaceDir, path); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := os.Stat(full); err != nil {
+		return "", WithErrorCode(CodeNotFound, fmt.Errorf("package path not found in the workspace: %s", path))
+	}
+	return full + extras, nil
+}
@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrEnvironmentBusy is returned when an environment is locked by a conflicting operation
+var ErrEnvironmentBusy = errors.New("environment is busy")
+
+// opLock is a readers-writer lock whose waiters are served in arrival order, so that a
+// stream of shared holders cannot starve an exclusive waiter: once one queues, later
+// shared requests queue behind it. A waiter can give up when its context is cancelled
+// or its wait runs out. The zero value is unlocked.
+type opLock struct {
+	mu      sync.Mutex // protects the fields below
+	readers int
+	writer  bool
+	queue   []*opWaiter
+}
+
+// opWaiter is a queued lock request
+type opWaiter struct {
+	exclusive bool
+	granted   chan struct{} // closed once the lock is held for the waiter
+}
+
+// free reports whether the lock can be taken now. Callers must hold l.mu.
+func (l *opLock) free(exclusive bool) bool {
+	if exclusive {
+		return !l.writer && l.readers == 0
+	}
+	return !l.writer
+}
+
+// take marks the lock held. Callers must hold l.mu.
+func (l *opLock) take(exclusive bool) {
+	if exclusive {
+		l.writer = true
+	} else {
+		l.readers++
+	}
+}
+
+// grantWaiting hands the lock to waiters at the head of the queue while it is free
+// for them. Callers must hold l.mu.
+func (l *opLock) grantWaiting() {
+	for len(l.queue) > 0 && l.free(l.queue[0].exclusive) {
+		w := l.queue[0]
+		l.queue = l.queue[1:]
+		l.take(w.exclusive)
+		close(w.granted)
+	}
+}
+
+// lock takes the lock, waiting up to wait behind earlier requests. It returns
+// ErrEnvironmentBusy if the wait runs out, or the context's error.
+func (l *opLock) lock(ctx context.Context, exclusive bool, wait time.Duration) error {
+	l.mu.Lock()
+	if len(l.queue) == 0 && l.free(exclusive) {
+		l.take(exclusive)
+		l.mu.Unlock()
+		return nil
+	}
+	if wait <= 0 {
+		l.mu.Unlock()
+		return ErrEnvironmentBusy
+	}
+	w := &opWaiter{exclusive: exclusive, granted: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.granted:
+		return nil
+	case <-timer.C:
+		err = ErrEnvironmentBusy
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	select {
+	case <-w.granted:
+		// Granted while giving up: pass the lock on
+		l.mu.Unlock()
+		l.unlock(exclusive)
+		return err
+	default:
+	}
+	for i, queued := range l.queue {
+		if queued == w {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			break
+		}
+	}
+	// Requests queued behind an exclusive waiter may now be able to go
+	l.grantWaiting()
+	l.mu.Unlock()
+	return err
+}
+
+// unlock releases a lock taken with the same exclusive flag
+func (l *opLock) unlock(exclusive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if exclusive {
+		l.writer = false
+	} else {
+		l.readers--
+	}
+	l.grantWaiting()
+}
+
+// SetLockWait configures how long an operation waits for a conflicting operation on
+// the same environment to finish. Zero fails immediately with ErrEnvironmentBusy.
+func (m *Manager) SetLockWait(wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lockWait = wait
+}
+
+// lockEnvironment acquires the per-environment operation lock. Executions take it
+// shared so they can run concurrently; mutating operations (installs, destroy) take
+// it exclusively, which read-only environments refuse. Requests are served in arrival
+// order, so a waiting mutation holds back executions that arrive after it, and waiting
+// stops when ctx is cancelled. Every operation holding the lock counts against the
+// concurrency caps. The returned function releases the lock.
+func (m *Manager) lockEnvironment(ctx context.Context, env *ManagedEnvironment, exclusive bool) (func(), error) {
+	if exclusive {
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+	release, err := m.acquireExecution(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	wait := m.lockWait
+	m.mu.RUnlock()
+
+	if err := env.opMu.lock(ctx, exclusive, wait); err != nil {
+		release()
+		if ctx.Err() != nil {
+			return nil, checkCancelled(ctx)
+		}
+		return nil, fmt.Errorf("%w: %s (another operation is in progress)", ErrEnvironmentBusy, env.ID)
+	}
+	return func() {
+		env.opMu.unlock(exclusive)
+		release()
+	}, nil
+}
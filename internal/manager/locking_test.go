@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// lockAsync takes l in a goroutine and returns a channel with the result
+func lockAsync(l *opLock, ctx context.Context, exclusive bool, wait time.Duration) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- l.lock(ctx, exclusive, wait) }()
+	return done
+}
+
+// waitQueued waits until n requests are queued on l
+func waitQueued(t *testing.T, l *opLock, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		l.mu.Lock()
+		queued := len(l.queue)
+		l.mu.Unlock()
+		if queued == n {
+			return
+		}
+	}
+	t.Fatalf("%d requests never queued", n)
+}
+
+func expectPending(t *testing.T, done <-chan error, what string) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("%s was not kept waiting: %v", what, err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func expectResult(t *testing.T, done <-chan error, want error, what string) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if !errors.Is(err, want) {
+			t.Fatalf("%s: got %v, want %v", what, err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s is still waiting", what)
+	}
+}
+
+func TestOpLockSharedAndExclusive(t *testing.T) {
+	var l opLock
+	ctx := context.Background()
+	if err := l.lock(ctx, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.lock(ctx, false, 0); err != nil {
+		t.Fatalf("second shared lock: %v", err)
+	}
+	if err := l.lock(ctx, true, 0); !errors.Is(err, ErrEnvironmentBusy) {
+		t.Fatalf("exclusive lock while shared: got %v, want busy", err)
+	}
+	l.unlock(false)
+	l.unlock(false)
+	if err := l.lock(ctx, true, 0); err != nil {
+		t.Fatalf("exclusive lock when free: %v", err)
+	}
+	if err := l.lock(ctx, false, 0); !errors.Is(err, ErrEnvironmentBusy) {
+		t.Fatalf("shared lock while exclusive: got %v, want busy", err)
+	}
+	l.unlock(true)
+}
+
+func TestOpLockWriterNotStarved(t *testing.T) {
+	var l opLock
+	ctx := context.Background()
+	if err := l.lock(ctx, false, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := lockAsync(&l, ctx, true, time.Minute)
+	waitQueued(t, &l, 1)
+
+	// A shared request arriving after the writer waits behind it, even though the
+	// lock is only held shared
+	if err := l.lock(ctx, false, 0); !errors.Is(err, ErrEnvironmentBusy) {
+		t.Fatalf("shared lock overtook a queued writer: %v", err)
+	}
+	reader := lockAsync(&l, ctx, false, time.Minute)
+	waitQueued(t, &l, 2)
+	expectPending(t, writer, "writer")
+
+	l.unlock(false)
+	expectResult(t, writer, nil, "writer")
+	expectPending(t, reader, "reader behind the writer")
+
+	l.unlock(true)
+	expectResult(t, reader, nil, "reader")
+	l.unlock(false)
+}
+
+func TestOpLockGivingUp(t *testing.T) {
+	var l opLock
+	if err := l.lock(context.Background(), false, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A writer whose wait runs out leaves the queue
+	expectResult(t, lockAsync(&l, context.Background(), true, 10*time.Millisecond), ErrEnvironmentBusy, "timed out writer")
+
+	// A cancelled writer leaves the queue and lets the readers behind it in
+	ctx, cancel := context.WithCancel(context.Background())
+	writer := lockAsync(&l, ctx, true, time.Minute)
+	waitQueued(t, &l, 1)
+	reader := lockAsync(&l, context.Background(), false, time.Minute)
+	waitQueued(t, &l, 2)
+	cancel()
+	expectResult(t, writer, context.Canceled, "cancelled writer")
+	expectResult(t, reader, nil, "reader behind the cancelled writer")
+
+	l.unlock(false)
+	l.unlock(false)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.readers != 0 || l.writer || len(l.queue) != 0 {
+		t.Errorf("lock left with %d readers, writer %v, %d queued", l.readers, l.writer, len(l.queue))
+	}
+}
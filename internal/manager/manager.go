@@ -2,13 +2,20 @@ package manager
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,15 +25,94 @@ import (
 // DefaultPythonVersion is used when no version is specified
 const DefaultPythonVersion = "3.11"
 
+// DefaultREPLName names the REPL session created with an environment by WithREPL
+const DefaultREPLName = "default"
+
 // Manager tracks active environments and REPL sessions
 type Manager struct {
 	mu               sync.RWMutex
 	environments     map[string]*ManagedEnvironment
 	replSessions     map[string]*ManagedREPL
 	spawnedProcesses map[string]*ManagedProcess
+	terminals        map[string]*ManagedTerminal
+	debugSessions    map[string]*ManagedDebugSession
 	baseEnvironments map[string]*jumpboot.PythonEnvironment // base envs by version (e.g., "3.11" -> env)
-	baseMu           sync.Mutex                       // separate lock for base environment creation
+	baseMu           sync.Mutex                             // separate lock for base environment creation
 	baseDir          string
+	sessionIsolation bool                        // scope resources to the MCP session that created them
+	lockWait         time.Duration               // how long to wait for a busy environment
+	trashRetention   time.Duration               // how long deleted workspace content is kept (0 = delete immediately)
+	maxREPLsPerEnv   int                         // per-environment REPL limit (0 = unlimited)
+	maxREPLs         int                         // global REPL limit (0 = unlimited)
+	jobs             map[string]*Job             // background jobs by ID
+	schedules        map[string]*Schedule        // recurring script runs by ID
+	watches          map[string]*Watch           // workspace watches by ID
+	sharedWorkspaces map[string]*SharedWorkspace // shared workspaces by name
+	mountAllow       []mountRoot                 // host directories workspaces may mount
+	postCreateHook   string                      // Python script run in every new environment
+	commandAllow     []string                    // executables run_command/spawn_command may start (empty = any)
+	commandDeny      []string                    // executables that may never be started
+	webhookAllow     []string                    // URL prefixes webhooks may target (empty = any)
+	webhookSecret    string                      // default secret for signing webhook deliveries
+	downloadAllow    []string                    // URL prefixes workspace downloads may fetch (empty = any)
+	downloadMaxBytes int64                       // largest workspace download (0 = DefaultDownloadMaxBytes)
+	modelCache       string                      // shared Hugging Face cache (HF_HOME) of all environments
+	sandboxImage     string                      // container image of podman and docker isolation ("" = DefaultSandboxImage)
+	outputMaxLines   int                         // default captured lines kept per process
+	outputMaxBytes   int                         // default captured bytes kept per process
+	maxEnvironments  int                         // environment limit (0 = unlimited)
+	minFreeDisk      uint64                      // free disk space required to create an environment (0 = no check)
+	pendingEnvs      int                         // environments being created, counted against maxEnvironments
+	pendingOwners    map[string]int              // environments being created by session, counted against the session limit
+	limiter          *rateLimiter                // per-session and global call rates and concurrency caps
+	packageIndexes   []PackageIndex              // private indexes and channels install_packages may use by name
+	objectStores     []ObjectStore               // S3 and GCS accounts workspace_sync_remote may use
+	secrets          *secretStore                // named secrets injected into environments and redacted from output
+	auditLog         *AuditLog                   // record of mutating tool calls (nil = disabled)
+
+	sessions           map[string]*sessionActivity // MCP sessions seen with isolation on
+	sessionIdleTimeout time.Duration               // a session without tool calls this long is dead (0 = only disconnects)
+	sessionReapGrace   time.Duration               // how long resources of dead sessions are kept (0 = until claimed)
+	reaperStop         chan struct{}               // stops the session reaper (nil when not running)
+
+	gpuMu          sync.Mutex                // protects gpuAllocations; taken after mu
+	gpuAllocations map[string]*GPUAllocation // gpu_devices allocations by holder ID
+
+	version   string    // server version reported by Status
+	startedAt time.Time // when the manager was created
+	shutdown  bool      // set by Shutdown
+
+	drainGrace       time.Duration // how long a drain waits for in-flight work by default
+	drainCheckpoints bool          // checkpoint REPL sessions when draining
+	drainMu          sync.Mutex    // protects drain
+	drain            *DrainInfo    // set once a drain starts
+	drained          chan struct{} // closed once the drain has completed
+	draining         atomic.Bool   // new tool calls are refused
+	inFlight         atomic.Int64  // tool calls being handled
+
+	warmSize     int                   // pre-warmed venvs kept per Python version (0 = no pool)
+	warmVersions []string              // Python versions the pool is kept for
+	warmPool     map[string][]*warmEnv // ready venvs by Python version
+	warmFilling  map[string]bool       // Python versions whose pool is being filled
+
+	cloneHardlinks bool // clones may hard-link files when copy-on-write is unavailable
+
+	spoolMu        sync.Mutex                // protects the result spool
+	spoolThreshold int                       // results larger than this are spooled (0 = never)
+	spoolTTL       time.Duration             // how long spooled results are kept
+	spooled        map[string]*spooledResult // spooled results by ID
+
+	cacheMu     sync.Mutex                 // protects the execution cache; taken after mu
+	execCache   map[string]*execCacheEntry // memoized run_code and run_script results by key
+	cacheBytes  int                        // output and result JSON bytes held by the cache
+	cacheHits   int64
+	cacheMisses int64
+
+	queueMu sync.Mutex // protects queue; taken after mu and before job locks
+	queue   jobQueue   // background jobs waiting for a slot, and the running counts
+
+	experimentsMu sync.Mutex // protects experiments
+	experiments   *sql.DB    // experiment store, opened on first use
 }
 
 // ManagedEnvironment wraps a jumpboot PythonEnvironment with metadata
@@ -36,31 +122,87 @@ type ManagedEnvironment struct {
 	Env          *jumpboot.PythonEnvironment `json:"-"`
 	PythonVer    string                      `json:"python_version"`
 	WorkspaceDir string                      `json:"workspace_dir,omitempty"`
-	RootDir      string                      `json:"root_dir"` // The venv directory
+	RootDir      string                      `json:"root_dir"`               // The venv directory
+	Owner        string                      `json:"owner,omitempty"`        // MCP session that created it
+	CreatedBy    string                      `json:"created_by,omitempty"`   // principal of the role token that created it
+	Isolation    string                      `json:"isolation,omitempty"`    // sandbox backend of its processes (IsolationNone if empty)
+	AdoptedPath  string                      `json:"adopted_path,omitempty"` // interpreter of an adopted installation, which is never deleted
+	opMu         opLock                      // shared for executions, exclusive for mutations
+
+	configMu    sync.RWMutex          // protects vars, secretRefs, entrypoints, labels and runtimes
+	vars        map[string]string     // variables added to every process started in the environment
+	secretRefs  map[string]string     // variables whose value is a server secret, by secret name
+	entrypoints map[string]Entrypoint // named workspace scripts, set by a manifest
+	labels      map[string]string     // key=value metadata for organizing environments
+	runtimes    map[string]string     // versions of runtime executables such as node, by command
+	secrets     *secretStore          // the Manager's secrets, resolved when a process starts
+
+	netMu   sync.Mutex // protects network
+	network envNetwork // network policy of the processes it starts
+
+	readOnly atomic.Pointer[EnvironmentLock] // set while the environment is locked
+
+	sharedWorkspace string            // name of the attached shared workspace, protected by the Manager's mu
+	mounts          []*WorkspaceMount // host paths mounted into its workspaces, protected by the Manager's mu
 }
 
 // ManagedREPL wraps a jumpboot REPL session with metadata
 type ManagedREPL struct {
-	ID        string                      `json:"id"`
-	Name      string                      `json:"name"`
-	EnvID     string                      `json:"env_id"`
-	REPL      *jumpboot.REPLPythonProcess `json:"-"`
+	ID    string                      `json:"id"`
+	Name  string                      `json:"name"`
+	EnvID string                      `json:"env_id"`
+	REPL  *jumpboot.REPLPythonProcess `json:"-"`
+	Owner string                      `json:"owner,omitempty"`
+
+	GPUDevices []string `json:"gpu_devices,omitempty"` // CUDA/ROCR_VISIBLE_DEVICES of the interpreter
+
+	activityMu   sync.Mutex // protects the fields below
+	lastActivity time.Time
+	executing    int  // number of in-flight executions
+	closed       bool // set when the session is destroyed or evicted
 }
 
 // EnvironmentInfo is the serializable info about an environment
 type EnvironmentInfo struct {
-	ID            string `json:"id"`
-	Name          string `json:"name"`
-	PythonVersion string `json:"python_version"`
-	EnvPath       string `json:"env_path"`
-	WorkspaceDir  string `json:"workspace_dir,omitempty"`
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	PythonVersion   string           `json:"python_version"`
+	EnvPath         string           `json:"env_path"`
+	WorkspaceDir    string           `json:"workspace_dir,omitempty"`
+	SharedWorkspace string           `json:"shared_workspace,omitempty"` // name of the attached shared workspace
+	Owner           string           `json:"owner,omitempty"`
+	CreatedBy       string           `json:"created_by,omitempty"`
+	Isolation       string           `json:"isolation,omitempty"`
+	Network         string           `json:"network,omitempty"`      // network policy mode
+	Locked          *EnvironmentLock `json:"locked,omitempty"`       // set while the environment is read-only
+	AdoptedPath     string           `json:"adopted_path,omitempty"` // interpreter of an adopted installation
+	Prewarmed       bool             `json:"prewarmed,omitempty"`    // handed out from the warm pool (only set on creation)
+	Clone           *CloneInfo       `json:"clone,omitempty"`        // how a template was cloned (only set on creation)
+	REPL            *REPLInfo        `json:"repl,omitempty"`         // default REPL session (only set on creation with a REPL)
+
+	// Runtimes are the versions of runtimes provisioned next to Python, by executable
+	// (e.g. {"node": "20.11.1", "npm": "10.2.4"})
+	Runtimes map[string]string `json:"runtimes,omitempty"`
+
+	// PostCreateOutput is the output of post-create hooks (only set on creation)
+	PostCreateOutput string `json:"post_create_output,omitempty"`
+
+	// Labels are key=value metadata set at creation or with environment_set_labels
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// SpecFormat is the format of the specification an environment was restored from
+	// (only set on restore)
+	SpecFormat string `json:"spec_format,omitempty"`
 }
 
 // REPLInfo is the serializable info about a REPL session
 type REPLInfo struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	EnvID string `json:"env_id"`
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	EnvID        string    `json:"env_id"`
+	Owner        string    `json:"owner,omitempty"`
+	LastActivity time.Time `json:"last_activity"`
+	GPUDevices   []string  `json:"gpu_devices,omitempty"`
 }
 
 // ManagedProcess wraps a spawned Python process with metadata
@@ -69,11 +211,22 @@ type ManagedProcess struct {
 	Name          string       `json:"name"`
 	EnvID         string       `json:"env_id"`
 	Cmd           *exec.Cmd    `json:"-"`
+	tree          *processTree // the process and the processes it starts
 	StartTime     time.Time    `json:"start_time"`
 	CaptureOutput bool         `json:"capture_output"`
-	outputMu      sync.RWMutex // protects outputLines
+	Owner         string       `json:"owner,omitempty"`
+	MetricsURL    string       `json:"metrics_url,omitempty"`
+	GPUDevices    []string     `json:"gpu_devices,omitempty"`
+	LogFile       string       `json:"log_file,omitempty"` // relative to the workspace
+	log           *rotatingLog // output log file, if enabled
+	SpillFile     string       `json:"spill_file,omitempty"` // file receiving all captured output, if enabled
+	outputMu      sync.RWMutex // protects outputLines and the fields below
 	outputLines   []string     // circular buffer of output lines
 	maxLines      int          // max lines to keep
+	maxBytes      int          // max bytes of lines to keep
+	outputBytes   int          // bytes held in outputLines
+	firstLine     int          // number of outputLines[0], counting from the first line of the process
+	spill         *os.File     // open SpillFile
 	done          chan struct{}
 	exitCode      int
 	exited        bool
@@ -81,13 +234,18 @@ type ManagedProcess struct {
 
 // ProcessInfo is the serializable info about a spawned process
 type ProcessInfo struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	EnvID     string    `json:"env_id"`
-	PID       int       `json:"pid"`
-	StartTime time.Time `json:"start_time"`
-	Running   bool      `json:"running"`
-	ExitCode  int       `json:"exit_code,omitempty"`
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	EnvID      string    `json:"env_id"`
+	PID        int       `json:"pid"`
+	StartTime  time.Time `json:"start_time"`
+	Running    bool      `json:"running"`
+	ExitCode   int       `json:"exit_code,omitempty"`
+	Owner      string    `json:"owner,omitempty"`
+	MetricsURL string    `json:"metrics_url,omitempty"`
+	GPUDevices []string  `json:"gpu_devices,omitempty"`
+	LogFile    string    `json:"log_file,omitempty"`
+	SpillFile  string    `json:"spill_file,omitempty"`
 }
 
 // NewManager creates a new environment manager
@@ -108,8 +266,32 @@ func NewManager(baseDir string) (*Manager, error) {
 		environments:     make(map[string]*ManagedEnvironment),
 		replSessions:     make(map[string]*ManagedREPL),
 		spawnedProcesses: make(map[string]*ManagedProcess),
+		terminals:        make(map[string]*ManagedTerminal),
+		debugSessions:    make(map[string]*ManagedDebugSession),
 		baseEnvironments: make(map[string]*jumpboot.PythonEnvironment),
+		jobs:             make(map[string]*Job),
+		schedules:        make(map[string]*Schedule),
+		watches:          make(map[string]*Watch),
+		sharedWorkspaces: make(map[string]*SharedWorkspace),
+		sessions:         make(map[string]*sessionActivity),
+		gpuAllocations:   make(map[string]*GPUAllocation),
+		secrets:          newSecretStore(),
+		pendingOwners:    make(map[string]int),
+		limiter:          newRateLimiter(),
 		baseDir:          baseDir,
+		trashRetention:   DefaultTrashRetention,
+		outputMaxLines:   DefaultOutputMaxLines,
+		outputMaxBytes:   DefaultOutputMaxBytes,
+		startedAt:        time.Now(),
+		drainGrace:       DefaultDrainGrace,
+		drained:          make(chan struct{}),
+		warmPool:         make(map[string][]*warmEnv),
+		warmFilling:      make(map[string]bool),
+		cloneHardlinks:   true,
+		spoolTTL:         DefaultSpoolTTL,
+		spooled:          make(map[string]*spooledResult),
+		execCache:        make(map[string]*execCacheEntry),
+		queue:            jobQueue{byEnv: make(map[string]int), bySession: make(map[string]int)},
 	}, nil
 }
 
@@ -155,26 +337,77 @@ func (m *Manager) getOrCreateBase(pythonVersion string) (*jumpboot.PythonEnviron
 
 // CreateEnvironment creates a new Python environment.
 // Creates a venv from a cached micromamba base environment (independent of system Python).
-func (m *Manager) CreateEnvironment(name, pythonVersion string) (*EnvironmentInfo, error) {
+func (m *Manager) CreateEnvironment(ctx context.Context, name, pythonVersion string, opts CreateOptions) (*EnvironmentInfo, error) {
+	if opts.Template != "" {
+		tmpl, err := m.GetEnvironment(opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		if pythonVersion != "" && pythonVersion != tmpl.PythonVer && !strings.HasPrefix(tmpl.Env.PythonVersion.String(), pythonVersion+".") {
+			return nil, fmt.Errorf("template %s has Python %s, not %s", opts.Template, tmpl.PythonVer, pythonVersion)
+		}
+		pythonVersion = tmpl.PythonVer
+	}
 	// Use default version if not specified
 	if pythonVersion == "" {
 		pythonVersion = DefaultPythonVersion
 	}
+	if err := checkIsolation(opts.Isolation); err != nil {
+		return nil, err
+	}
+	if err := validateLabels(opts.Labels); err != nil {
+		return nil, err
+	}
+	network, err := checkNetworkPolicy(opts.Network, opts.Isolation != "" && opts.Isolation != IsolationNone)
+	if err != nil {
+		return nil, err
+	}
+	if opts.WithREPL && opts.Isolation != "" && opts.Isolation != IsolationNone {
+		return nil, ErrNotIsolated
+	}
+	if opts.WithREPL && network.Mode != NetworkAllow {
+		return nil, ErrNetworkRestricted
+	}
+	runtimes, err := parseRuntimes(opts.Runtimes)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := m.reserveEnvironment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	// Generate ID and path for the venv
 	id := uuid.New().String()
 	envPath := filepath.Join(m.baseDir, id)
+	var env *jumpboot.PythonEnvironment
+	var clone *CloneInfo
 
-	// Get or create base environment (handles its own locking)
-	baseEnv, err := m.getOrCreateBase(pythonVersion)
-	if err != nil {
-		return nil, err
+	var warm *warmEnv
+	if opts.Template == "" {
+		warm = m.takeWarm(pythonVersion)
 	}
+	if opts.Template != "" {
+		if env, clone, err = m.cloneTemplate(ctx, opts.Template, envPath); err != nil {
+			return nil, err
+		}
+	} else if warm != nil {
+		// A pre-warmed venv keeps its ID and directory
+		id, envPath, env = warm.id, warm.dir, warm.env
+	} else {
+		// Get or create base environment (handles its own locking)
+		baseEnv, err := m.getOrCreateBase(pythonVersion)
+		if err != nil {
+			return nil, err
+		}
 
-	// Create venv from base (runs without holding the main lock)
-	env, err := jumpboot.CreateVenvEnvironment(baseEnv, envPath, jumpboot.VenvOptions{}, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create venv: %w", err)
+		// Create venv from base (runs without holding the main lock)
+		env, err = jumpboot.CreateVenvEnvironment(baseEnv, envPath, jumpboot.VenvOptions{}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create venv: %w", err)
+		}
 	}
 
 	managed := &ManagedEnvironment{
@@ -183,6 +416,40 @@ func (m *Manager) CreateEnvironment(name, pythonVersion string) (*EnvironmentInf
 		Env:       env,
 		PythonVer: pythonVersion,
 		RootDir:   envPath,
+		Owner:     ownerFor(ctx),
+		CreatedBy: CallerFromContext(ctx).Principal,
+		Isolation: opts.Isolation,
+		labels:    maps.Clone(opts.Labels),
+		secrets:   m.secrets,
+		network:   envNetwork{policy: network},
+	}
+
+	// Runtimes come first so the hooks can use them
+	if len(runtimes) > 0 {
+		if err := m.installRuntimes(ctx, managed, runtimes); err != nil {
+			os.RemoveAll(envPath)
+			return nil, err
+		}
+	}
+	if len(runtimes) > 0 || opts.Template != "" {
+		managed.refreshRuntimes(ctx)
+	}
+
+	// Run post-create hooks before the environment becomes visible
+	hookOutput, err := m.runPostCreate(ctx, managed, opts.PostCreate)
+	if err != nil {
+		managed.stopNetworkProxy()
+		os.RemoveAll(envPath)
+		return nil, err
+	}
+
+	// Install packages after the hooks, which may configure pip
+	if opts.setup != nil {
+		if err := opts.setup(ctx, managed); err != nil {
+			managed.stopNetworkProxy()
+			os.RemoveAll(envPath)
+			return nil, err
+		}
 	}
 
 	// Only hold lock briefly to store the result
@@ -190,12 +457,42 @@ func (m *Manager) CreateEnvironment(name, pythonVersion string) (*EnvironmentInf
 	m.environments[id] = managed
 	m.mu.Unlock()
 
-	return &EnvironmentInfo{
-		ID:            id,
-		Name:          name,
-		PythonVersion: env.PythonVersion.String(),
-		EnvPath:       env.EnvPath,
-	}, nil
+	info := &EnvironmentInfo{
+		ID:               id,
+		Name:             name,
+		PythonVersion:    env.PythonVersion.String(),
+		EnvPath:          env.EnvPath,
+		Owner:            managed.Owner,
+		Isolation:        managed.Isolation,
+		Network:          network.Mode,
+		Prewarmed:        warm != nil,
+		Clone:            clone,
+		Labels:           managed.labelsOf(),
+		Runtimes:         managed.runtimesOf(),
+		PostCreateOutput: hookOutput,
+	}
+	if opts.WithREPL {
+		if err := m.startDefaultREPL(ctx, info); err != nil {
+			m.DestroyEnvironment(id)
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// startDefaultREPL creates the workspace and default REPL session of a new
+// environment and records both in its info
+func (m *Manager) startDefaultREPL(ctx context.Context, info *EnvironmentInfo) error {
+	workspace, err := m.CreateWorkspace(info.ID)
+	if err != nil {
+		return err
+	}
+	repl, err := m.CreateREPL(ctx, info.ID, DefaultREPLName, nil)
+	if err != nil {
+		return err
+	}
+	info.WorkspaceDir, info.REPL = workspace.Path, repl
+	return nil
 }
 
 // GetEnvironment retrieves an environment by ID
@@ -205,24 +502,36 @@ func (m *Manager) GetEnvironment(id string) (*ManagedEnvironment, error) {
 
 	env, ok := m.environments[id]
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", id)
+		return nil, notFound("environment", id)
 	}
 	return env, nil
 }
 
-// ListEnvironments returns info about all managed environments
-func (m *Manager) ListEnvironments() []EnvironmentInfo {
+// ListEnvironments returns info about all managed environments visible to the caller in ctx
+func (m *Manager) ListEnvironments(ctx context.Context) []EnvironmentInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	result := make([]EnvironmentInfo, 0, len(m.environments))
 	for _, env := range m.environments {
+		if !m.canAccess(ctx, env.Owner) {
+			continue
+		}
 		result = append(result, EnvironmentInfo{
-			ID:            env.ID,
-			Name:          env.Name,
-			PythonVersion: env.Env.PythonVersion.String(),
-			EnvPath:       env.Env.EnvPath,
-			WorkspaceDir:  env.WorkspaceDir,
+			ID:              env.ID,
+			Name:            env.Name,
+			PythonVersion:   env.Env.PythonVersion.String(),
+			EnvPath:         env.Env.EnvPath,
+			WorkspaceDir:    env.WorkspaceDir,
+			SharedWorkspace: env.sharedWorkspace,
+			Owner:           env.Owner,
+			CreatedBy:       env.CreatedBy,
+			Isolation:       env.Isolation,
+			Network:         env.networkPolicy().Mode,
+			Locked:          env.readOnly.Load(),
+			AdoptedPath:     env.AdoptedPath,
+			Labels:          env.labelsOf(),
+			Runtimes:        env.runtimesOf(),
 		})
 	}
 	return result
@@ -230,12 +539,35 @@ func (m *Manager) ListEnvironments() []EnvironmentInfo {
 
 // DestroyEnvironment removes an environment and cleans up its resources
 func (m *Manager) DestroyEnvironment(id string) error {
+	m.mu.RLock()
+	env, ok := m.environments[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return notFound("environment", id)
+	}
+	if err := env.checkWritable(); err != nil {
+		return err
+	}
+
+	// Stop schedules first so that a scheduled run does not hold up the lock
+	m.mu.Lock()
+	m.deleteEnvironmentSchedules(id)
+	m.deleteEnvironmentWatches(id)
+	m.mu.Unlock()
+
+	// Wait for in-flight operations on this environment to finish
+	unlock, err := m.lockEnvironment(context.Background(), env, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	env, ok := m.environments[id]
-	if !ok {
-		return fmt.Errorf("environment not found: %s", id)
+	if _, ok := m.environments[id]; !ok {
+		return notFound("environment", id)
 	}
 
 	// Kill any spawned processes using this environment
@@ -246,7 +578,7 @@ func (m *Manager) DestroyEnvironment(id string) error {
 			proc.outputMu.RUnlock()
 
 			if !exited && proc.Cmd.Process != nil {
-				proc.Cmd.Process.Kill()
+				proc.tree.kill()
 				<-proc.done
 			}
 			delete(m.spawnedProcesses, procID)
@@ -260,15 +592,42 @@ func (m *Manager) DestroyEnvironment(id string) error {
 				repl.REPL.Close()
 			}
 			delete(m.replSessions, replID)
+			m.releaseGPUs(replID)
 		}
 	}
 
-	// Remove the workspace directory if it exists
-	if env.WorkspaceDir != "" {
+	// Close any terminals using this environment
+	for termID, term := range m.terminals {
+		if term.EnvID == id {
+			term.close()
+			delete(m.terminals, termID)
+		}
+	}
+
+	// Stop any scripts being debugged in this environment
+	for debugID, session := range m.debugSessions {
+		if session.EnvID == id {
+			session.close()
+			delete(m.debugSessions, debugID)
+		}
+	}
+	env.stopNetworkProxy()
+	m.dropExecCache(id)
+
+	// Remove the workspace directory if it exists. Mounts go first, so that host files
+	// are not deleted with it. A shared workspace is only removed with its last
+	// environment.
+	if err := m.removeMounts(env, ""); err != nil {
+		return err
+	}
+	if env.sharedWorkspace != "" {
+		m.releaseSharedWorkspace(env)
+	} else if env.WorkspaceDir != "" {
 		os.RemoveAll(env.WorkspaceDir)
 	}
 
-	// Remove the root environment directory (contains bin, envs, pkgs)
+	// Remove the root environment directory (contains bin, envs, pkgs). An adopted
+	// installation lives elsewhere and is left alone.
 	if env.RootDir != "" {
 		if err := os.RemoveAll(env.RootDir); err != nil {
 			return fmt.Errorf("failed to remove environment directory: %w", err)
@@ -286,7 +645,7 @@ func (m *Manager) FreezeEnvironment(id string) (string, error) {
 
 	env, ok := m.environments[id]
 	if !ok {
-		return "", fmt.Errorf("environment not found: %s", id)
+		return "", notFound("environment", id)
 	}
 
 	// Create a temp file for the freeze
@@ -311,9 +670,12 @@ func (m *Manager) FreezeEnvironment(id string) (string, error) {
 }
 
 // RestoreEnvironment recreates an environment from frozen JSON
-func (m *Manager) RestoreEnvironment(name, frozenJSON string) (*EnvironmentInfo, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Manager) RestoreEnvironment(ctx context.Context, name, frozenJSON string) (*EnvironmentInfo, error) {
+	release, err := m.reserveEnvironment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	id := uuid.New().String()
 	envPath := filepath.Join(m.baseDir, id)
@@ -343,48 +705,87 @@ func (m *Manager) RestoreEnvironment(name, frozenJSON string) (*EnvironmentInfo,
 		Env:       env,
 		PythonVer: env.PythonVersion.String(),
 		RootDir:   envPath,
+		Owner:     ownerFor(ctx),
+		CreatedBy: CallerFromContext(ctx).Principal,
+		secrets:   m.secrets,
+		network:   envNetwork{policy: NetworkPolicy{Mode: NetworkAllow}},
+	}
+	managed.refreshRuntimes(ctx)
+
+	// Run the server's post-create hook before the environment becomes visible
+	hookOutput, err := m.runPostCreate(ctx, managed, "")
+	if err != nil {
+		os.RemoveAll(envPath)
+		return nil, err
 	}
 
+	m.mu.Lock()
 	m.environments[id] = managed
+	m.mu.Unlock()
 
 	return &EnvironmentInfo{
-		ID:            id,
-		Name:          name,
-		PythonVersion: env.PythonVersion.String(),
-		EnvPath:       env.EnvPath,
+		ID:               id,
+		Name:             name,
+		PythonVersion:    env.PythonVersion.String(),
+		EnvPath:          env.EnvPath,
+		Owner:            managed.Owner,
+		Runtimes:         managed.runtimesOf(),
+		PostCreateOutput: hookOutput,
 	}, nil
 }
 
 // CreateREPL creates a new REPL session for an environment
-func (m *Manager) CreateREPL(envID, sessionName string) (*REPLInfo, error) {
+func (m *Manager) CreateREPL(ctx context.Context, envID, sessionName string, gpuDevices []string) (*REPLInfo, error) {
+	if err := validateGPUDevices(gpuDevices); err != nil {
+		return nil, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	env, ok := m.environments[envID]
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
+	}
+	if env.isolated() {
+		return nil, ErrNotIsolated
+	}
+	if env.networkPolicy().Mode != NetworkAllow {
+		return nil, ErrNetworkRestricted
+	}
+
+	// Make room by evicting idle sessions if a limit is reached
+	if err := m.enforceREPLLimits(ctx, envID); err != nil {
+		return nil, err
 	}
 
-	repl, err := env.Env.NewREPLPythonProcess(nil, nil, nil, nil)
+	repl, err := env.Env.NewREPLPythonProcess(nil, env.varsWith(gpuEnv(gpuDevices)), nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create REPL: %w", err)
 	}
 
 	id := uuid.New().String()
 	managed := &ManagedREPL{
-		ID:    id,
-		Name:  sessionName,
-		EnvID: envID,
-		REPL:  repl,
+		ID:           id,
+		Name:         sessionName,
+		EnvID:        envID,
+		REPL:         repl,
+		Owner:        ownerFor(ctx),
+		GPUDevices:   gpuDevices,
+		lastActivity: time.Now(),
 	}
 
 	m.replSessions[id] = managed
-
-	return &REPLInfo{
-		ID:    id,
-		Name:  sessionName,
-		EnvID: envID,
-	}, nil
+	m.allocateGPUs(GPUAllocation{
+		Devices:    gpuDevices,
+		HolderKind: GPUHolderREPL,
+		HolderID:   id,
+		Name:       sessionName,
+		EnvID:      envID,
+		Owner:      managed.Owner,
+	})
+
+	return managed.info(), nil
 }
 
 // GetREPL retrieves a REPL session by ID
@@ -394,23 +795,22 @@ func (m *Manager) GetREPL(id string) (*ManagedREPL, error) {
 
 	repl, ok := m.replSessions[id]
 	if !ok {
-		return nil, fmt.Errorf("REPL session not found: %s", id)
+		return nil, notFound("REPL session", id)
 	}
 	return repl, nil
 }
 
-// ListREPLs returns info about all active REPL sessions
-func (m *Manager) ListREPLs() []REPLInfo {
+// ListREPLs returns info about all active REPL sessions visible to the caller in ctx
+func (m *Manager) ListREPLs(ctx context.Context) []REPLInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	result := make([]REPLInfo, 0, len(m.replSessions))
 	for _, repl := range m.replSessions {
-		result = append(result, REPLInfo{
-			ID:    repl.ID,
-			Name:  repl.Name,
-			EnvID: repl.EnvID,
-		})
+		if !m.canAccess(ctx, repl.Owner) {
+			continue
+		}
+		result = append(result, *repl.info())
 	}
 	return result
 }
@@ -422,9 +822,10 @@ func (m *Manager) DestroyREPL(id string) error {
 
 	repl, ok := m.replSessions[id]
 	if !ok {
-		return fmt.Errorf("REPL session not found: %s", id)
+		return notFound("REPL session", id)
 	}
 
+	repl.markClosed()
 	if repl.REPL != nil {
 		if err := repl.REPL.Close(); err != nil {
 			return fmt.Errorf("failed to close REPL: %w", err)
@@ -432,20 +833,40 @@ func (m *Manager) DestroyREPL(id string) error {
 	}
 
 	delete(m.replSessions, id)
+	m.releaseGPUs(id)
 	return nil
 }
 
 // ExecuteREPL runs code in a REPL session
-func (m *Manager) ExecuteREPL(id, code string) (string, error) {
+func (m *Manager) ExecuteREPL(ctx context.Context, id, code string) (string, error) {
 	m.mu.RLock()
 	repl, ok := m.replSessions[id]
 	m.mu.RUnlock()
 
 	if !ok {
-		return "", fmt.Errorf("REPL session not found: %s", id)
+		return "", notFound("REPL session", id)
 	}
 
-	result, err := repl.REPL.Execute(code, true)
+	env, err := m.GetEnvironment(repl.EnvID)
+	if err != nil {
+		return "", err
+	}
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Mark the session busy so it is never evicted mid-execution
+	if err := repl.beginExecution(); err != nil {
+		return "", err
+	}
+	defer repl.endExecution()
+
+	result, err := m.executeREPL(ctx, repl, code)
+	if errors.Is(err, ErrCancelled) {
+		return "", err
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to execute code: %w", err)
 	}
@@ -457,6 +878,7 @@ func (m *Manager) ExecuteREPL(id, code string) (string, error) {
 func (m *Manager) Shutdown() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.shutdown = true
 
 	// Kill all spawned processes
 	for _, proc := range m.spawnedProcesses {
@@ -465,7 +887,7 @@ func (m *Manager) Shutdown() {
 		proc.outputMu.RUnlock()
 
 		if !exited && proc.Cmd.Process != nil {
-			proc.Cmd.Process.Kill()
+			proc.tree.kill()
 			<-proc.done
 		}
 	}
@@ -478,27 +900,137 @@ func (m *Manager) Shutdown() {
 		}
 	}
 	m.replSessions = make(map[string]*ManagedREPL)
+
+	// Close all terminals
+	for _, term := range m.terminals {
+		term.close()
+	}
+	m.terminals = make(map[string]*ManagedTerminal)
+
+	// Stop all debug sessions
+	for _, session := range m.debugSessions {
+		session.close()
+	}
+	m.debugSessions = make(map[string]*ManagedDebugSession)
+
+	// Cancel background jobs
+	m.clearJobQueue()
+	for _, job := range m.jobs {
+		job.cancel()
+	}
+
+	// Stop schedules
+	for _, s := range m.schedules {
+		s.cancel()
+	}
+	m.schedules = make(map[string]*Schedule)
+
+	// Stop workspace watches
+	for _, w := range m.watches {
+		w.cancel()
+	}
+	m.watches = make(map[string]*Watch)
+
+	for _, env := range m.environments {
+		env.stopNetworkProxy()
+		m.removeMounts(env, "")
+	}
+	m.clearWarmPool()
+	m.clearSpool()
+	m.closeExperiments()
+
+	if m.reaperStop != nil {
+		close(m.reaperStop)
+		m.reaperStop = nil
+	}
+
+	if m.auditLog != nil {
+		m.auditLog.Close()
+		m.auditLog = nil
+	}
+}
+
+// InstallPackages installs packages in an environment. With pip, packages may also
+// name wheels, sdists or project directories in the workspace (see resolvePackageArgs).
+func (m *Manager) InstallPackages(ctx context.Context, envID string, packages []string, useConda bool) error {
+	return m.InstallPackagesFromIndex(ctx, envID, packages, useConda, "")
 }
 
-// InstallPackages installs packages in an environment
-func (m *Manager) InstallPackages(envID string, packages []string, useConda bool) error {
+// InstallPackagesFromIndex is InstallPackages with a private index or conda channel
+// configured with SetPackageIndexes, referenced by name ("" = PyPI or conda-forge)
+func (m *Manager) InstallPackagesFromIndex(ctx context.Context, envID string, packages []string, useConda bool, indexName string) error {
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return fmt.Errorf("environment not found: %s", envID)
+		return notFound("environment", envID)
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, true)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	if useConda {
 		for _, pkg := range packages {
-			if err := env.Env.MicromambaInstallPackage(pkg, "conda-forge"); err != nil {
-				return fmt.Errorf("failed to install %s via conda: %w", pkg, err)
+			if _, editable := editablePath(pkg); editable || isLocalPackage(pkg) {
+				return fmt.Errorf("workspace packages can only be installed with pip: %s", pkg)
+			}
+		}
+		channelArgs := []string{"--no-rc", "-c", "conda-forge"}
+		if indexName != "" {
+			index, err := m.packageIndex(indexName, IndexConda)
+			if err != nil {
+				return err
+			}
+			args, cleanup, err := condaIndexConfig(env, index)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			channelArgs = args
+		}
+		micromamba, err := m.micromambaFor(env)
+		if err != nil {
+			return err
+		}
+		for _, pkg := range packages {
+			args := append([]string{"install"}, channelArgs...)
+			args = append(args, "--prefix", env.Env.EnvPath, "-y", pkg)
+			cmd := commandContext(ctx, micromamba, args...)
+			if output, err := runCommand(ctx, cmd); err != nil {
+				return fmt.Errorf("failed to install %s via conda: %w\nOutput: %s", pkg, err, output)
 			}
 		}
+		// A conda package may have brought a runtime such as nodejs
+		env.refreshRuntimes(ctx)
 	} else {
-		if err := env.Env.PipInstallPackages(packages, "", "", false, nil); err != nil {
-			return fmt.Errorf("failed to install packages via pip: %w", err)
+		packages, err := resolvePackageArgs(env, packages)
+		if err != nil {
+			return err
+		}
+		args := []string{"-m", "pip", "install", "--no-warn-script-location"}
+		var environ []string
+		if indexName != "" {
+			index, err := m.packageIndex(indexName, IndexPip)
+			if err != nil {
+				return err
+			}
+			indexArgs, indexEnv, cleanup, err := pipIndexConfig(env, index)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			args, environ = append(args, indexArgs...), indexEnv
+		}
+		cmd := commandContext(ctx, env.Env.PythonPath, append(args, packages...)...)
+		if len(environ) > 0 {
+			cmd.Env = append(os.Environ(), environ...)
+		}
+		if output, err := runCommand(ctx, cmd); err != nil {
+			return fmt.Errorf("failed to install packages via pip: %w\nOutput: %s", err, output)
 		}
 	}
 
@@ -506,13 +1038,13 @@ func (m *Manager) InstallPackages(envID string, packages []string, useConda bool
 }
 
 // InstallRequirements installs packages from a requirements.txt file in the workspace
-func (m *Manager) InstallRequirements(envID, requirementsPath string, upgrade bool) error {
+func (m *Manager) InstallRequirements(ctx context.Context, envID, requirementsPath string, upgrade bool) error {
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return fmt.Errorf("environment not found: %s", envID)
+		return notFound("environment", envID)
 	}
 
 	if env.WorkspaceDir == "" {
@@ -527,8 +1059,14 @@ func (m *Manager) InstallRequirements(envID, requirementsPath string, upgrade bo
 
 	// Check if file exists
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return fmt.Errorf("requirements file not found: %s", requirementsPath)
+		return WithErrorCode(CodeNotFound, fmt.Errorf("requirements file not found: %s", requirementsPath))
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, true)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	// Use the environment's pip to install from requirements file
 	// Run: python -m pip install -r requirements.txt [--upgrade]
@@ -537,7 +1075,7 @@ func (m *Manager) InstallRequirements(envID, requirementsPath string, upgrade bo
 		args = append(args, "--upgrade")
 	}
 
-	output, err := env.Env.RunPythonReadCombined(args[0], args[1:]...)
+	output, err := runPython(ctx, env, args...)
 	if err != nil {
 		return fmt.Errorf("failed to install from requirements: %w\nOutput: %s", err, output)
 	}
@@ -552,7 +1090,7 @@ func (m *Manager) ListPackages(envID string) ([]PackageInfo, error) {
 	m.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
 	}
 
 	// Use pip freeze to list packages
@@ -580,55 +1118,292 @@ func (m *Manager) ListPackages(envID string) ([]PackageInfo, error) {
 	return packages, nil
 }
 
-// RunCode executes Python code in an environment
-func (m *Manager) RunCode(envID, code string, inputJSON string) (string, error) {
+// Environment variables of the run_code I/O contract
+const (
+	codeInputEnv  = "JUMPBOOT_INPUT"  // file holding input_json (also sent on stdin)
+	codeResultEnv = "JUMPBOOT_RESULT" // file the code may write a JSON result to
+)
+
+// CodeResult is the outcome of RunCode
+type CodeResult struct {
+	Output       string          `json:"output"`
+	ResultJSON   json.RawMessage `json:"result_json,omitempty"`   // JSON the code wrote to $JUMPBOOT_RESULT
+	ArtifactsDir string          `json:"artifacts_dir,omitempty"` // $JUMPBOOT_ARTIFACTS, relative to the workspace
+	Artifacts    []Artifact      `json:"artifacts,omitempty"`     // files the code saved there
+	CacheKey     string          `json:"cache_key,omitempty"`     // set when caching was requested
+	Cached       bool            `json:"cached,omitempty"`        // returned from the execution cache
+	Manifest     string          `json:"manifest,omitempty"`      // run manifest of a reproducible run, relative to the workspace
+	Seed         *int64          `json:"seed,omitempty"`          // seed of a reproducible run
+}
+
+// ScriptResult is the outcome of RunScript
+type ScriptResult struct {
+	Output       string     `json:"output"`
+	ArtifactsDir string     `json:"artifacts_dir,omitempty"` // $JUMPBOOT_ARTIFACTS, relative to the workspace
+	Artifacts    []Artifact `json:"artifacts,omitempty"`     // files the script saved there
+	CacheKey     string     `json:"cache_key,omitempty"`     // set when caching was requested
+	Cached       bool       `json:"cached,omitempty"`        // returned from the execution cache
+	Manifest     string     `json:"manifest,omitempty"`      // run manifest of a reproducible run, relative to the workspace
+	Seed         *int64     `json:"seed,omitempty"`          // seed of a reproducible run
+}
+
+// RunCode executes Python code in an environment. A non-empty inputJSON is sent on
+// stdin and written to the file named by $JUMPBOOT_INPUT. JSON the code writes to the
+// file named by $JUMPBOOT_RESULT is returned as ResultJSON, separate from the output.
+// Files the code saves in the directory named by $JUMPBOOT_ARTIFACTS are listed as
+// Artifacts. With opts.Cache, a result memoized for the same code, input and
+// environment state is returned without running the code, and a successful run
+// without artifacts is memoized. With opts.Reproducible, the code runs seeded and a
+// successful run writes a RunManifest to the workspace.
+func (m *Manager) RunCode(ctx context.Context, envID, code string, inputJSON string, opts RunOptions) (*CodeResult, error) {
+	if inputJSON != "" && !json.Valid([]byte(inputJSON)) {
+		return nil, fmt.Errorf("input_json is not valid JSON")
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return "", fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
 	}
 
-	// Create a temporary script file
-	tmpFile, err := os.CreateTemp("", "script-*.py")
+	unlock, err := m.lockEnvironment(ctx, env, false)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp script: %w", err)
+		return nil, err
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
+	defer unlock()
 
-	if _, err := tmpFile.WriteString(code); err != nil {
-		tmpFile.Close()
-		return "", fmt.Errorf("failed to write script: %w", err)
+	var cacheKey string
+	if opts.Cache {
+		if cacheKey, err = m.execCacheKey(ctx, env, CacheKindCode, code, inputJSON); err != nil {
+			return nil, err
+		}
+		if result := m.cachedCode(cacheKey); result != nil {
+			return result, nil
+		}
 	}
-	tmpFile.Close()
 
-	output, err := env.Env.RunPythonReadCombined(tmpPath)
+	// The script, its input and its result live in a temporary directory
+	tmpDir, err := os.MkdirTemp("", "run-code-*")
 	if err != nil {
-		return "", fmt.Errorf("execution failed: %w\nOutput: %s", err, output)
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
+	defer os.RemoveAll(tmpDir)
 
-	return output, nil
+	scriptPath := filepath.Join(tmpDir, "script.py")
+	if err := os.WriteFile(scriptPath, []byte(code), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write script: %w", err)
+	}
+	resultPath := filepath.Join(tmpDir, "result.json")
+	artifacts, err := m.newArtifactsDir(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	cmd := commandContext(ctx, env.Env.PythonPath, scriptPath)
+	cmd.Env = append(env.appendVars(os.Environ()), codeResultEnv+"="+resultPath)
+	var run *reproducibleRun
+	if opts.Reproducible {
+		if run, err = m.prepareReproducible(ctx, env, opts, CacheKindCode, tmpDir, env.WorkspaceDir); err != nil {
+			return nil, err
+		}
+		run.manifest.CodeSHA256 = hexSHA256(code)
+		if inputJSON != "" {
+			run.manifest.InputSHA256 = hexSHA256(inputJSON)
+		}
+		cmd = commandContext(ctx, env.Env.PythonPath, run.bootstrap, scriptPath)
+		cmd.Env = run.environ(append(env.appendVars(os.Environ()), codeResultEnv+"="+resultPath))
+	}
+	if artifacts != "" {
+		cmd.Env = append(cmd.Env, artifactsEnv+"="+artifacts)
+	}
+	if inputJSON != "" {
+		inputPath := filepath.Join(tmpDir, "input.json")
+		if err := os.WriteFile(inputPath, []byte(inputJSON), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write input: %w", err)
+		}
+		cmd.Env = append(cmd.Env, codeInputEnv+"="+inputPath)
+		cmd.Stdin = strings.NewReader(inputJSON)
+	}
+
+	output, err := m.runIsolated(ctx, env, cmd, sandboxMount{path: tmpDir, writable: true})
+	if err != nil {
+		return nil, fmt.Errorf("execution failed: %w\nOutput: %s", err, output)
+	}
+
+	result := &CodeResult{Output: output, Artifacts: collectArtifacts(env, artifacts)}
+	if len(result.Artifacts) > 0 {
+		result.ArtifactsDir = workspaceRelative(env.WorkspaceDir, artifacts)
+	}
+	data, err := os.ReadFile(resultPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read result: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("the code wrote invalid JSON to $%s\nOutput: %s", codeResultEnv, output)
+		}
+		result.ResultJSON = data
+	}
+	if run != nil {
+		if result.Manifest, err = run.finish(env, output, result.ResultJSON, result.Artifacts); err != nil {
+			return nil, err
+		}
+		result.Seed = &run.manifest.Seed
+	}
+	if opts.Cache {
+		result.CacheKey = cacheKey
+		if len(result.Artifacts) == 0 {
+			cached := *result
+			m.storeExecCache(&execCacheEntry{
+				key:   cacheKey,
+				envID: envID,
+				kind:  CacheKindCode,
+				label: code,
+				code:  &cached,
+				size:  len(result.Output) + len(result.ResultJSON),
+			})
+		}
+	}
+	return result, nil
 }
 
-// RunScript executes a Python script file in an environment
-func (m *Manager) RunScript(envID, scriptPath string, args []string) (string, error) {
+// RunScript executes a Python script file in an environment. With gpuDevices the
+// script only sees those GPUs. Files the script saves in the directory named by
+// $JUMPBOOT_ARTIFACTS are listed as Artifacts. opts work like those of RunCode, with
+// the script's content and arguments in place of the code and input.
+func (m *Manager) RunScript(ctx context.Context, envID, scriptPath string, args, gpuDevices []string, opts RunOptions) (*ScriptResult, error) {
+	if err := validateGPUDevices(gpuDevices); err != nil {
+		return nil, err
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return "", fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if env.isolated() {
+		// The sandbox starts in the workspace, not the server's directory, and only
+		// sees the script itself
+		if scriptPath, err = filepath.Abs(scriptPath); err != nil {
+			return nil, fmt.Errorf("invalid script path: %w", err)
+		}
+	}
+
+	var cacheKey string
+	if opts.Cache {
+		content, err := os.ReadFile(scriptPath)
+		if err != nil {
+			return nil, WithErrorCode(CodeNotFound, fmt.Errorf("failed to read script: %w", err))
+		}
+		parts := append([]string{scriptPath, string(content), strings.Join(gpuDevices, ",")}, args...)
+		if cacheKey, err = m.execCacheKey(ctx, env, CacheKindScript, parts...); err != nil {
+			return nil, err
+		}
+		if result := m.cachedScript(cacheKey); result != nil {
+			return result, nil
+		}
+	}
+
+	artifacts, err := m.newArtifactsDir(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifacts directory: %w", err)
 	}
 
 	allArgs := append([]string{scriptPath}, args...)
-	output, err := env.Env.RunPythonReadCombined(allArgs[0], allArgs[1:]...)
+	cmd := commandContext(ctx, env.Env.PythonPath, allArgs...)
+	cmd.Env = env.appendVars(os.Environ())
+	mounts := []sandboxMount{{path: scriptPath}}
+	var run *reproducibleRun
+	if opts.Reproducible {
+		// The seed bootstrap starts the script
+		tmpDir, err := os.MkdirTemp("", "run-script-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		absScript, err := filepath.Abs(scriptPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script path: %w", err)
+		}
+		if run, err = m.prepareReproducible(ctx, env, opts, CacheKindScript, tmpDir, filepath.Dir(absScript)); err != nil {
+			return nil, err
+		}
+		sum, err := fileSHA256(scriptPath)
+		if err != nil {
+			return nil, WithErrorCode(CodeNotFound, fmt.Errorf("failed to read script: %w", err))
+		}
+		run.manifest.Script, run.manifest.ScriptSHA256 = scriptPath, sum
+		run.manifest.Args, run.manifest.GPUDevices = args, gpuDevices
+		if isSubPath(env.WorkspaceDir, absScript) {
+			run.manifest.Script = workspaceRelative(env.WorkspaceDir, absScript)
+		}
+		cmd = commandContext(ctx, env.Env.PythonPath, append([]string{run.bootstrap}, allArgs...)...)
+		cmd.Env = run.environ(env.appendVars(os.Environ()))
+		mounts = append(mounts, sandboxMount{path: tmpDir, writable: true})
+	}
+	if artifacts != "" {
+		cmd.Env = append(cmd.Env, artifactsEnv+"="+artifacts)
+	}
+	if len(gpuDevices) > 0 {
+		cmd.Env = appendGPUEnv(cmd.Env, gpuDevices)
+		runID := uuid.New().String()
+		m.allocateGPUs(GPUAllocation{
+			Devices:    gpuDevices,
+			HolderKind: GPUHolderScript,
+			HolderID:   runID,
+			Name:       filepath.Base(scriptPath),
+			EnvID:      envID,
+			Owner:      ownerFor(ctx),
+		})
+		defer m.releaseGPUs(runID)
+	}
+	output, err := m.runIsolated(ctx, env, cmd, mounts...)
 	if err != nil {
-		return "", fmt.Errorf("execution failed: %w\nOutput: %s", err, output)
+		return nil, fmt.Errorf("execution failed: %w\nOutput: %s", err, output)
 	}
 
-	return output, nil
+	result := &ScriptResult{Output: output, Artifacts: collectArtifacts(env, artifacts)}
+	if len(result.Artifacts) > 0 {
+		result.ArtifactsDir = workspaceRelative(env.WorkspaceDir, artifacts)
+	}
+	if run != nil {
+		if result.Manifest, err = run.finish(env, output, nil, result.Artifacts); err != nil {
+			return nil, err
+		}
+		result.Seed = &run.manifest.Seed
+	}
+	if opts.Cache {
+		result.CacheKey = cacheKey
+		if len(result.Artifacts) == 0 {
+			cached := *result
+			m.storeExecCache(&execCacheEntry{
+				key:    cacheKey,
+				envID:  envID,
+				kind:   CacheKindScript,
+				label:  scriptPath,
+				script: &cached,
+				size:   len(result.Output),
+			})
+		}
+	}
+	return result, nil
 }
 
 // PackageInfo describes an installed package
@@ -658,7 +1433,7 @@ func (m *Manager) CreateWorkspace(envID string) (*WorkspaceInfo, error) {
 
 	env, ok := m.environments[envID]
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
 	}
 
 	// If workspace already exists, return it
@@ -690,7 +1465,7 @@ func (m *Manager) GetWorkspace(envID string) (*WorkspaceInfo, error) {
 
 	env, ok := m.environments[envID]
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
 	}
 
 	if env.WorkspaceDir == "" {
@@ -710,7 +1485,10 @@ func (m *Manager) WriteWorkspaceFile(envID, filename, content string) (*FileInfo
 	m.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
 	}
 
 	if env.WorkspaceDir == "" {
@@ -722,6 +1500,9 @@ func (m *Manager) WriteWorkspaceFile(envID, filename, content string) (*FileInfo
 	if err != nil {
 		return nil, err
 	}
+	if err := m.checkMountWritable(env, filePath); err != nil {
+		return nil, err
+	}
 
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
@@ -752,7 +1533,7 @@ func (m *Manager) ReadWorkspaceFile(envID, filename string) (string, error) {
 	m.mu.RUnlock()
 
 	if !ok {
-		return "", fmt.Errorf("environment not found: %s", envID)
+		return "", notFound("environment", envID)
 	}
 
 	if env.WorkspaceDir == "" {
@@ -780,7 +1561,7 @@ func (m *Manager) ListWorkspaceFiles(envID string, subpath string) ([]FileInfo,
 	m.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
 	}
 
 	if env.WorkspaceDir == "" {
@@ -822,41 +1603,65 @@ func (m *Manager) ListWorkspaceFiles(envID string, subpath string) ([]FileInfo,
 	return files, nil
 }
 
-// DeleteWorkspaceFile deletes a file from the workspace
-func (m *Manager) DeleteWorkspaceFile(envID, filename string) error {
+// DeleteWorkspaceFile deletes a file (or empty directory) from the workspace by
+// moving it to the environment's trash
+func (m *Manager) DeleteWorkspaceFile(envID, filename string) (*TrashEntry, error) {
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
 	}
 
 	if env.WorkspaceDir == "" {
-		return fmt.Errorf("no workspace created for environment: %s", envID)
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
 	}
 
 	// Sanitize and validate path
 	filePath, err := safeJoinPath(env.WorkspaceDir, filename)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := m.checkMountWritable(env, filePath); err != nil {
+		return nil, err
 	}
 
-	if err := os.Remove(filePath); err != nil {
-		return fmt.Errorf("failed to delete file: %w", err)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete file: %w", err)
 	}
 
-	return nil
+	// Only empty directories can be deleted, as before
+	if info.IsDir() {
+		entries, err := os.ReadDir(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete file: %w", err)
+		}
+		if len(entries) > 0 {
+			return nil, fmt.Errorf("failed to delete file: directory not empty: %s", filename)
+		}
+	}
+
+	entry, err := m.moveToTrash(env, filePath, filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return entry, nil
 }
 
 // RunWorkspaceScript runs a script from the workspace
-func (m *Manager) RunWorkspaceScript(envID, filename string, args []string) (string, error) {
+func (m *Manager) RunWorkspaceScript(ctx context.Context, envID, filename string, args []string) (string, error) {
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return "", fmt.Errorf("environment not found: %s", envID)
+		return "", notFound("environment", envID)
 	}
 
 	if env.WorkspaceDir == "" {
@@ -871,11 +1676,19 @@ func (m *Manager) RunWorkspaceScript(envID, filename string, args []string) (str
 
 	// Check if file exists
 	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("script not found: %s", filename)
+		return "", WithErrorCode(CodeNotFound, fmt.Errorf("script not found: %s", filename))
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return "", err
 	}
+	defer unlock()
 
 	allArgs := append([]string{scriptPath}, args...)
-	output, err := env.Env.RunPythonReadCombined(allArgs[0], allArgs[1:]...)
+	cmd := commandContext(ctx, env.Env.PythonPath, allArgs...)
+	cmd.Env = env.appendVars(os.Environ())
+	output, err := m.runIsolated(ctx, env, cmd)
 	if err != nil {
 		return "", fmt.Errorf("execution failed: %w\nOutput: %s", err, output)
 	}
@@ -883,26 +1696,46 @@ func (m *Manager) RunWorkspaceScript(envID, filename string, args []string) (str
 	return output, nil
 }
 
-// DestroyWorkspace removes the workspace directory
-func (m *Manager) DestroyWorkspace(envID string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
+// DestroyWorkspace removes the workspace directory by moving it to the environment's trash
+func (m *Manager) DestroyWorkspace(envID string) (*TrashEntry, error) {
+	m.mu.RLock()
 	env, ok := m.environments[envID]
+	var workspaceDir, shared string
+	if ok {
+		workspaceDir, shared = env.WorkspaceDir, env.sharedWorkspace
+	}
+	m.mu.RUnlock()
+
 	if !ok {
-		return fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if shared != "" {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("environment %s uses shared workspace %s; detach it with workspace_detach instead", envID, shared))
 	}
 
-	if env.WorkspaceDir == "" {
-		return fmt.Errorf("no workspace to destroy for environment: %s", envID)
+	if workspaceDir == "" {
+		return nil, fmt.Errorf("no workspace to destroy for environment: %s", envID)
+	}
+	m.mu.Lock()
+	err := m.removeMounts(env, workspaceDir)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
 	}
 
-	if err := os.RemoveAll(env.WorkspaceDir); err != nil {
-		return fmt.Errorf("failed to remove workspace: %w", err)
+	entry, err := m.moveToTrash(env, workspaceDir, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove workspace: %w", err)
 	}
 
+	m.mu.Lock()
 	env.WorkspaceDir = ""
-	return nil
+	m.mu.Unlock()
+
+	return entry, nil
 }
 
 // GitCloneInfo describes the result of a git clone operation
@@ -913,13 +1746,16 @@ type GitCloneInfo struct {
 }
 
 // GitCloneToWorkspace clones a git repository into the workspace
-func (m *Manager) GitCloneToWorkspace(envID, repoURL, dirName string) (*GitCloneInfo, error) {
+func (m *Manager) GitCloneToWorkspace(ctx context.Context, envID, repoURL, dirName string) (*GitCloneInfo, error) {
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
 	}
 
 	if env.WorkspaceDir == "" {
@@ -939,11 +1775,13 @@ func (m *Manager) GitCloneToWorkspace(envID, repoURL, dirName string) (*GitClone
 	}
 
 	// Run git clone
-	cmd := exec.Command("git", "clone", repoURL, clonePath)
+	cmd := commandContext(ctx, "git", "clone", repoURL, clonePath)
 	cmd.Dir = env.WorkspaceDir
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
-		return nil, fmt.Errorf("git clone failed: %w\nOutput: %s", err, string(output))
+		// Don't leave a partial clone behind
+		os.RemoveAll(clonePath)
+		return nil, fmt.Errorf("git clone failed: %w\nOutput: %s", err, output)
 	}
 
 	return &GitCloneInfo{
@@ -1058,20 +1896,49 @@ func splitOnce(s, sep string) []string {
 	return []string{s}
 }
 
+// SpawnOptions configures a spawned process
+type SpawnOptions struct {
+	Name           string   // display name (defaults to the script filename)
+	Args           []string // command-line arguments for the script
+	CaptureOutput  bool     // capture stdout/stderr for process_output
+	MetricsURL     string   // optional Prometheus scrape target exposed by the process
+	GPUDevices     []string // restrict the process to these GPUs (indices or UUIDs from gpu_info)
+	LogToFile      bool     // also write stdout/stderr to a rotating log file in the workspace
+	OutputMaxLines int      // captured lines kept in memory (0 = server default)
+	OutputMaxBytes int      // captured bytes kept in memory (0 = server default)
+	SpillOutput    bool     // write all captured lines to a file in the environment directory
+	Webhook        *Webhook // optional callback notified when the process exits
+
+	mounts []sandboxMount // paths made visible when the environment is isolated
+}
+
 // SpawnProcess starts a Python script that runs in the background
-func (m *Manager) SpawnProcess(envID, scriptPath, name string, args []string, captureOutput bool) (*ProcessInfo, error) {
+func (m *Manager) SpawnProcess(ctx context.Context, envID, scriptPath string, opts SpawnOptions) (*ProcessInfo, error) {
+	name, args := opts.Name, opts.Args
+
 	m.mu.RLock()
 	env, ok := m.environments[envID]
 	m.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("environment not found: %s", envID)
+		return nil, notFound("environment", envID)
 	}
 
-	id := uuid.New().String()
 	if name == "" {
 		name = filepath.Base(scriptPath)
 	}
+	if env.isolated() {
+		// Resolve the script as the host would and make it visible
+		if !filepath.IsAbs(scriptPath) {
+			scriptPath = filepath.Join(env.WorkspaceDir, scriptPath)
+		}
+		abs, err := filepath.Abs(scriptPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script path: %w", err)
+		}
+		scriptPath = abs
+		opts.mounts = append(opts.mounts, sandboxMount{path: abs})
+	}
 
 	// Build command using environment's Python
 	cmdArgs := append([]string{scriptPath}, args...)
@@ -1079,8 +1946,31 @@ func (m *Manager) SpawnProcess(envID, scriptPath, name string, args []string, ca
 	cmd.Dir = env.WorkspaceDir
 
 	// Set up environment variables with the Python environment's bin path
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, fmt.Sprintf("PATH=%s%c%s", env.Env.EnvBinPath, filepath.ListSeparator, os.Getenv("PATH")))
+	cmd.Env = env.appendVars(os.Environ())
+	cmd.Env = append(cmd.Env, pathVar(env))
+
+	opts.Name = name
+	return m.startProcess(ctx, envID, cmd, opts)
+}
+
+// startProcess starts cmd as a managed background process named opts.Name
+func (m *Manager) startProcess(ctx context.Context, envID string, cmd *exec.Cmd, opts SpawnOptions) (*ProcessInfo, error) {
+	id := uuid.New().String()
+	name, captureOutput := opts.Name, opts.CaptureOutput
+
+	hook, err := m.checkWebhook(opts.Webhook)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGPUDevices(opts.GPUDevices); err != nil {
+		return nil, err
+	}
+	if len(opts.GPUDevices) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = appendGPUEnv(cmd.Env, opts.GPUDevices)
+	}
 
 	managed := &ManagedProcess{
 		ID:            id,
@@ -1089,35 +1979,113 @@ func (m *Manager) SpawnProcess(envID, scriptPath, name string, args []string, ca
 		Cmd:           cmd,
 		StartTime:     time.Now(),
 		CaptureOutput: captureOutput,
+		Owner:         ownerFor(ctx),
+		MetricsURL:    opts.MetricsURL,
+		GPUDevices:    opts.GPUDevices,
 		outputLines:   make([]string, 0),
-		maxLines:      1000, // keep last 1000 lines
+		maxLines:      opts.OutputMaxLines,
+		maxBytes:      opts.OutputMaxBytes,
 		done:          make(chan struct{}),
 	}
+	m.mu.RLock()
+	if managed.maxLines <= 0 {
+		managed.maxLines = m.outputMaxLines
+	}
+	if managed.maxBytes <= 0 {
+		managed.maxBytes = m.outputMaxBytes
+	}
+	env := m.environments[envID]
+	m.mu.RUnlock()
 
-	if captureOutput {
-		// Create pipes for stdout and stderr
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	cleanup := func() {}
+	if env != nil {
+		if cleanup, err = m.isolate(env, cmd, opts.mounts...); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.LogToFile {
+		if env == nil || env.WorkspaceDir == "" {
+			return nil, fmt.Errorf("no workspace created for environment: %s", envID)
 		}
-		stderr, err := cmd.StderrPipe()
+		managed.LogFile = processLogPath(name, id)
+		managed.log, err = openRotatingLog(filepath.Join(env.WorkspaceDir, managed.LogFile), ProcessLogMaxBytes, ProcessLogBackups)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+			return nil, err
+		}
+	}
+	if opts.SpillOutput && captureOutput {
+		if env == nil || env.RootDir == "" {
+			managed.closeLog()
+			return nil, fmt.Errorf("environment has no directory for output spill files: %s", envID)
 		}
+		if err := managed.openSpill(filepath.Join(env.RootDir, processOutputDir, id+".log")); err != nil {
+			managed.closeLog()
+			return nil, err
+		}
+	}
 
-		// Start output capture goroutines
-		go managed.captureOutput(stdout)
-		go managed.captureOutput(stderr)
+	// Output is read from our own pipes rather than cmd.StdoutPipe, so that lines
+	// written just before the process exits are not lost when Wait returns
+	var readers, writers []*os.File
+	if captureOutput || managed.log != nil {
+		for _, target := range []*io.Writer{&cmd.Stdout, &cmd.Stderr} {
+			r, w, err := os.Pipe()
+			if err != nil {
+				closeFiles(readers)
+				closeFiles(writers)
+				managed.closeLog()
+				return nil, fmt.Errorf("failed to create output pipe: %w", err)
+			}
+			*target = w
+			readers = append(readers, r)
+			writers = append(writers, w)
+		}
 	}
 
-	// Start the process
-	if err := cmd.Start(); err != nil {
+	// Start the process; the child holds its own copies of the write ends. It leads its
+	// own group, so killing it also stops the processes it starts.
+	setProcessGroup(cmd)
+	err = cmd.Start()
+	closeFiles(writers)
+	if err != nil {
+		closeFiles(readers)
+		managed.closeLog()
 		return nil, fmt.Errorf("failed to start process: %w", err)
 	}
+	managed.tree = newProcessTree(cmd.Process)
+
+	// Start output capture goroutines; the log is closed once both streams end
+	var readersDone sync.WaitGroup
+	outputDone := make(chan struct{})
+	for _, r := range readers {
+		readersDone.Add(1)
+		go func() {
+			defer readersDone.Done()
+			defer r.Close()
+			managed.captureOutput(r)
+		}()
+	}
+	go func() {
+		readersDone.Wait()
+		managed.closeLog()
+		close(outputDone)
+	}()
+
+	m.allocateGPUs(GPUAllocation{
+		Devices:    opts.GPUDevices,
+		HolderKind: GPUHolderProcess,
+		HolderID:   id,
+		Name:       name,
+		EnvID:      envID,
+		Owner:      managed.Owner,
+	})
 
 	// Monitor process in background
 	go func() {
 		err := cmd.Wait()
+		cleanup()
+		managed.tree.release()
 		managed.outputMu.Lock()
 		managed.exited = true
 		if err != nil {
@@ -1130,7 +2098,21 @@ func (m *Manager) SpawnProcess(envID, scriptPath, name string, args []string, ca
 			managed.exitCode = 0
 		}
 		managed.outputMu.Unlock()
+		m.releaseGPUs(id)
 		close(managed.done)
+
+		if hook != nil {
+			// Let the last lines reach the buffer, unless a child keeps the pipes open
+			select {
+			case <-outputDone:
+			case <-time.After(time.Second):
+			}
+			m.fireWebhook(hook, WebhookEvent{
+				Event:      EventProcessExited,
+				Process:    managed.info(),
+				OutputTail: managed.tail(webhookTailSize),
+			})
+		}
 	}()
 
 	m.mu.Lock()
@@ -1138,49 +2120,63 @@ func (m *Manager) SpawnProcess(envID, scriptPath, name string, args []string, ca
 	m.mu.Unlock()
 
 	return &ProcessInfo{
-		ID:        id,
-		Name:      name,
-		EnvID:     envID,
-		PID:       cmd.Process.Pid,
-		StartTime: managed.StartTime,
-		Running:   true,
+		ID:         id,
+		Name:       name,
+		EnvID:      envID,
+		PID:        cmd.Process.Pid,
+		StartTime:  managed.StartTime,
+		Running:    true,
+		Owner:      managed.Owner,
+		MetricsURL: managed.MetricsURL,
+		GPUDevices: managed.GPUDevices,
+		LogFile:    managed.LogFile,
+		SpillFile:  managed.SpillFile,
 	}, nil
 }
 
-// captureOutput reads from a reader and stores lines in the buffer
+// captureOutput reads lines from r into the buffer (with capture enabled) and the
+// log file (if any)
 func (p *ManagedProcess) captureOutput(r io.Reader) {
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
-		p.outputMu.Lock()
-		p.outputLines = append(p.outputLines, line)
-		// Keep only the last maxLines
-		if len(p.outputLines) > p.maxLines {
-			p.outputLines = p.outputLines[len(p.outputLines)-p.maxLines:]
+		if p.log != nil {
+			p.log.Write([]byte(line + "\n"))
+		}
+		if p.CaptureOutput {
+			p.appendOutput(line)
 		}
-		p.outputMu.Unlock()
+	}
+	// Keep draining after an over-long line so the process never blocks on a full pipe
+	io.Copy(io.Discard, r)
+}
+
+// closeLog closes the log and spill files, if any
+func (p *ManagedProcess) closeLog() {
+	if p.log != nil {
+		p.log.Close()
+	}
+	p.closeSpill()
+}
+
+// closeFiles closes every file in files
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
 	}
 }
 
-// ListProcesses returns info about all spawned processes
-func (m *Manager) ListProcesses() []ProcessInfo {
+// ListProcesses returns info about all spawned processes visible to the caller in ctx
+func (m *Manager) ListProcesses(ctx context.Context) []ProcessInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	result := make([]ProcessInfo, 0, len(m.spawnedProcesses))
 	for _, proc := range m.spawnedProcesses {
-		proc.outputMu.RLock()
-		info := ProcessInfo{
-			ID:        proc.ID,
-			Name:      proc.Name,
-			EnvID:     proc.EnvID,
-			PID:       proc.Cmd.Process.Pid,
-			StartTime: proc.StartTime,
-			Running:   !proc.exited,
-			ExitCode:  proc.exitCode,
+		if !m.canAccess(ctx, proc.Owner) {
+			continue
 		}
-		proc.outputMu.RUnlock()
-		result = append(result, info)
+		result = append(result, *proc.info())
 	}
 	return result
 }
@@ -1192,28 +2188,33 @@ func (m *Manager) GetProcessOutput(processID string, tailLines int) ([]string, e
 	m.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("process not found: %s", processID)
+		return nil, notFound("process", processID)
 	}
 
 	if !proc.CaptureOutput {
 		return nil, fmt.Errorf("output capture not enabled for process: %s", processID)
 	}
 
-	proc.outputMu.RLock()
-	defer proc.outputMu.RUnlock()
+	return proc.tail(tailLines), nil
+}
+
+// tail returns the last n captured output lines (all lines when n <= 0)
+func (p *ManagedProcess) tail(n int) []string {
+	p.outputMu.RLock()
+	defer p.outputMu.RUnlock()
 
-	if tailLines <= 0 || tailLines >= len(proc.outputLines) {
+	if n <= 0 || n >= len(p.outputLines) {
 		// Return all lines
-		result := make([]string, len(proc.outputLines))
-		copy(result, proc.outputLines)
-		return result, nil
+		result := make([]string, len(p.outputLines))
+		copy(result, p.outputLines)
+		return result
 	}
 
 	// Return last N lines
-	start := len(proc.outputLines) - tailLines
-	result := make([]string, tailLines)
-	copy(result, proc.outputLines[start:])
-	return result, nil
+	start := len(p.outputLines) - n
+	result := make([]string, n)
+	copy(result, p.outputLines[start:])
+	return result
 }
 
 // KillProcess terminates a spawned process
@@ -1222,7 +2223,7 @@ func (m *Manager) KillProcess(processID string) error {
 	proc, ok := m.spawnedProcesses[processID]
 	if !ok {
 		m.mu.Unlock()
-		return fmt.Errorf("process not found: %s", processID)
+		return notFound("process", processID)
 	}
 	m.mu.Unlock()
 
@@ -1239,7 +2240,7 @@ func (m *Manager) KillProcess(processID string) error {
 	}
 
 	// Kill the process
-	if err := proc.Cmd.Process.Kill(); err != nil {
+	if err := proc.tree.kill(); err != nil {
 		return fmt.Errorf("failed to kill process: %w", err)
 	}
 
@@ -1260,21 +2261,31 @@ func (m *Manager) GetProcessInfo(processID string) (*ProcessInfo, error) {
 	m.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("process not found: %s", processID)
+		return nil, notFound("process", processID)
 	}
 
-	proc.outputMu.RLock()
-	defer proc.outputMu.RUnlock()
+	return proc.info(), nil
+}
+
+// info returns the serializable status of the process
+func (p *ManagedProcess) info() *ProcessInfo {
+	p.outputMu.RLock()
+	defer p.outputMu.RUnlock()
 
 	return &ProcessInfo{
-		ID:        proc.ID,
-		Name:      proc.Name,
-		EnvID:     proc.EnvID,
-		PID:       proc.Cmd.Process.Pid,
-		StartTime: proc.StartTime,
-		Running:   !proc.exited,
-		ExitCode:  proc.exitCode,
-	}, nil
+		ID:         p.ID,
+		Name:       p.Name,
+		EnvID:      p.EnvID,
+		PID:        p.Cmd.Process.Pid,
+		StartTime:  p.StartTime,
+		Running:    !p.exited,
+		ExitCode:   p.exitCode,
+		Owner:      p.Owner,
+		MetricsURL: p.MetricsURL,
+		GPUDevices: p.GPUDevices,
+		LogFile:    p.LogFile,
+		SpillFile:  p.SpillFile,
+	}
 }
 
 // Response is a standard response format for MCP tools
@@ -1282,6 +2293,10 @@ type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"` // structured error context, e.g. capacity hints
+
+	ErrorCode string `json:"error_code,omitempty"` // machine-readable class of the error, e.g. NOT_FOUND
+	Retriable *bool  `json:"retriable,omitempty"`  // whether repeating the call later may succeed
 }
 
 // SuccessResponse creates a success response
@@ -1293,7 +2308,12 @@ func SuccessResponse(data interface{}) string {
 
 // ErrorResponse creates an error response
 func ErrorResponse(err error) string {
-	resp := Response{Success: false, Error: err.Error()}
+	code, retriable := ErrorCode(err)
+	resp := Response{Success: false, Error: err.Error(), ErrorCode: code, Retriable: &retriable}
+	var detailed interface{ Details() any }
+	if errors.As(err, &detailed) {
+		resp.Details = detailed.Details()
+	}
 	b, _ := json.Marshal(resp)
 	return string(b)
 }
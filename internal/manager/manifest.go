@@ -0,0 +1,297 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultManifestFile is the workspace file that holds an environment's manifest
+const DefaultManifestFile = "jumpboot.yaml"
+
+// Manifest declares the desired state of an environment. Unlike frozen JSON it lists
+// only what the project needs, so it can be kept in the workspace and edited by hand.
+type Manifest struct {
+	Name        string                `yaml:"name,omitempty" json:"name,omitempty"`
+	Python      string                `yaml:"python,omitempty" json:"python,omitempty"`           // version prefix, e.g. "3.11"
+	Packages    []string              `yaml:"packages,omitempty" json:"packages,omitempty"`       // pip requirements
+	Env         map[string]string     `yaml:"env,omitempty" json:"env,omitempty"`                 // variables of every process in the environment
+	Secrets     map[string]string     `yaml:"secrets,omitempty" json:"secrets,omitempty"`         // variables set from server secrets, by secret name
+	Entrypoints map[string]Entrypoint `yaml:"entrypoints,omitempty" json:"entrypoints,omitempty"` // named workspace scripts
+}
+
+// Entrypoint is a workspace script started by name with run_entrypoint
+type Entrypoint struct {
+	Script string   `yaml:"script" json:"script"`
+	Args   []string `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+// ManifestResult reports what applying a manifest changed
+type ManifestResult struct {
+	EnvID       string           `json:"env_id"`
+	Created     bool             `json:"created,omitempty"`
+	Environment *EnvironmentInfo `json:"environment,omitempty"` // only set when Created
+	Installed   []string         `json:"installed"`             // requirements that had to be installed
+	Satisfied   []string         `json:"satisfied"`             // requirements that were already met
+	Env         []string         `json:"env,omitempty"`         // names of the variables set
+	Secrets     []string         `json:"secrets,omitempty"`     // names of the variables set from secrets
+	Entrypoints []string         `json:"entrypoints,omitempty"`
+}
+
+// ParseManifest parses and validates the YAML content of a manifest
+func ParseManifest(data string) (*Manifest, error) {
+	var mf Manifest
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&mf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	for _, pkg := range mf.Packages {
+		if strings.TrimSpace(pkg) == "" {
+			return nil, fmt.Errorf("invalid manifest: empty package")
+		}
+	}
+	for key := range mf.Env {
+		if err := validateVarName(key); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+	}
+	for key := range mf.Secrets {
+		if err := validateVarName(key); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		if _, ok := mf.Env[key]; ok {
+			return nil, fmt.Errorf("invalid manifest: %s is in both env and secrets", key)
+		}
+	}
+	for name, ep := range mf.Entrypoints {
+		if ep.Script == "" {
+			return nil, fmt.Errorf("invalid manifest: entrypoint %s has no script", name)
+		}
+	}
+	return &mf, nil
+}
+
+// Marshal returns the manifest as YAML
+func (mf *Manifest) Marshal() (string, error) {
+	var buf strings.Builder
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(mf); err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	enc.Close()
+	return buf.String(), nil
+}
+
+// ReadManifest reads and parses a manifest file from an environment's workspace
+func (m *Manager) ReadManifest(envID, filename string) (*Manifest, error) {
+	if filename == "" {
+		filename = DefaultManifestFile
+	}
+	content, err := m.ReadWorkspaceFile(envID, filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(content)
+}
+
+// ApplyManifest reconciles an existing environment with a manifest: requirements that
+// are missing or not satisfied are installed, and the environment's variables and
+// entrypoints are replaced by the manifest's. Installed packages the manifest does not
+// list are kept. The Python version of an environment cannot change.
+func (m *Manager) ApplyManifest(ctx context.Context, envID string, mf *Manifest) (*ManifestResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if mf.Python != "" && !pythonVersionMatches(env.PythonVer, mf.Python) {
+		return nil, fmt.Errorf("environment %s runs Python %s but the manifest requires %s; apply the manifest without env_id to create a new environment",
+			envID, env.PythonVer, mf.Python)
+	}
+	if err := m.checkSecretRefs(mf.Secrets); err != nil {
+		return nil, err
+	}
+
+	missing, satisfied, err := m.unsatisfiedRequirements(envID, mf.Packages)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		if err := m.InstallPackages(ctx, envID, missing, false); err != nil {
+			return nil, err
+		}
+	}
+	env.setConfig(mf.Env, mf.Secrets, mf.Entrypoints)
+
+	return &ManifestResult{
+		EnvID:       envID,
+		Installed:   nonNil(missing),
+		Satisfied:   nonNil(satisfied),
+		Env:         slices.Sorted(maps.Keys(mf.Env)),
+		Secrets:     slices.Sorted(maps.Keys(mf.Secrets)),
+		Entrypoints: slices.Sorted(maps.Keys(mf.Entrypoints)),
+	}, nil
+}
+
+// CreateEnvironmentFromManifest creates an environment for the manifest's Python
+// version and installs its packages before the environment becomes visible. name
+// defaults to the manifest's name.
+func (m *Manager) CreateEnvironmentFromManifest(ctx context.Context, name string, mf *Manifest) (*ManifestResult, error) {
+	if name == "" {
+		name = mf.Name
+	}
+	if name == "" {
+		return nil, fmt.Errorf("name is required when the manifest does not set one")
+	}
+	if err := m.checkSecretRefs(mf.Secrets); err != nil {
+		return nil, err
+	}
+
+	info, err := m.CreateEnvironment(ctx, name, mf.Python, CreateOptions{
+		setup: func(ctx context.Context, env *ManagedEnvironment) error {
+			env.setConfig(mf.Env, mf.Secrets, mf.Entrypoints)
+			if len(mf.Packages) == 0 {
+				return nil
+			}
+			return pipInstallRequirements(ctx, env, strings.Join(mf.Packages, "\n"))
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ManifestResult{
+		EnvID:       info.ID,
+		Created:     true,
+		Environment: info,
+		Installed:   nonNil(slices.Clone(mf.Packages)),
+		Satisfied:   []string{},
+		Env:         slices.Sorted(maps.Keys(mf.Env)),
+		Secrets:     slices.Sorted(maps.Keys(mf.Secrets)),
+		Entrypoints: slices.Sorted(maps.Keys(mf.Entrypoints)),
+	}, nil
+}
+
+// ExportManifest generates a manifest from an environment's current state, with every
+// installed package pinned to its version
+func (m *Manager) ExportManifest(envID string) (*Manifest, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	packages, err := m.ListPackages(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	mf := &Manifest{
+		Name:   env.Name,
+		Python: majorMinor(env.PythonVer),
+	}
+	for _, pkg := range packages {
+		// Editable installs ("-e ...") cannot be expressed as a single requirement
+		if strings.HasPrefix(pkg.Name, "-") {
+			continue
+		}
+		if pkg.Version != "" {
+			mf.Packages = append(mf.Packages, pkg.Name+"=="+pkg.Version)
+		} else {
+			mf.Packages = append(mf.Packages, pkg.Name)
+		}
+	}
+
+	env.configMu.RLock()
+	defer env.configMu.RUnlock()
+	if len(env.vars) > 0 {
+		mf.Env = maps.Clone(env.vars)
+	}
+	if len(env.secretRefs) > 0 {
+		mf.Secrets = maps.Clone(env.secretRefs)
+	}
+	if len(env.entrypoints) > 0 {
+		mf.Entrypoints = maps.Clone(env.entrypoints)
+	}
+	return mf, nil
+}
+
+// RunEntrypoint runs a named entrypoint of an environment. args are appended to the
+// entrypoint's own arguments.
+func (m *Manager) RunEntrypoint(ctx context.Context, envID, name string, args []string) (string, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return "", err
+	}
+
+	env.configMu.RLock()
+	ep, ok := env.entrypoints[name]
+	env.configMu.RUnlock()
+	if !ok {
+		return "", notFound("entrypoint", name)
+	}
+
+	return m.RunWorkspaceScript(ctx, envID, ep.Script, append(slices.Clone(ep.Args), args...))
+}
+
+// unsatisfiedRequirements splits requirements into those the environment's installed
+// packages do not satisfy and those they do. Requirements that cannot be parsed, such
+// as URLs, are always passed to pip.
+func (m *Manager) unsatisfiedRequirements(envID string, requirements []string) (missing, satisfied []string, err error) {
+	if len(requirements) == 0 {
+		return nil, nil, nil
+	}
+	packages, err := m.ListPackages(envID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	installed := make(map[string]string, len(packages))
+	for _, pkg := range packages {
+		name, _, _ := strings.Cut(pkg.Name, " @ ")
+		installed[NormalizePackageName(name)] = pkg.Version
+	}
+
+	for _, r := range requirements {
+		req, err := ParseRequirement(r)
+		if err == nil {
+			if version, found := installed[req.Name]; found && req.SatisfiedBy(version) {
+				satisfied = append(satisfied, r)
+				continue
+			}
+		}
+		missing = append(missing, r)
+	}
+	return missing, satisfied, nil
+}
+
+// pythonVersionMatches reports whether version matches a prefix such as "3.11"
+func pythonVersionMatches(version, prefix string) bool {
+	return version == prefix || strings.HasPrefix(version, prefix+".")
+}
+
+// majorMinor shortens a version such as "3.11.9" to "3.11"
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// nonNil returns s, or an empty slice if s is nil, so it encodes as [] in JSON
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,250 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Matrix cell outcomes
+const (
+	MatrixPassed = "passed"
+	MatrixFailed = "failed" // ran and exited non-zero
+	MatrixError  = "error"  // could not be run, timed out or was cancelled
+)
+
+// DefaultMatrixOutputLines is how many trailing output lines each matrix cell keeps
+const DefaultMatrixOutputLines = 50
+
+// MatrixSpec is the work RunMatrix repeats in every environment. Exactly one of Code
+// and Command is set.
+type MatrixSpec struct {
+	Code         string        // Python code run with each environment's interpreter
+	Command      string        // executable run as by RunCommand, e.g. "pytest"
+	Args         []string      // arguments for Command
+	WorkdirEnvID string        // run every cell in this environment's workspace (default: each environment's own)
+	Timeout      time.Duration // per cell, 0 = no limit
+	MaxParallel  int           // cells running at once, 0 = all
+	OutputLines  int           // trailing output lines kept per cell (default DefaultMatrixOutputLines)
+}
+
+// MatrixCell is the outcome of the matrix work in one environment
+type MatrixCell struct {
+	EnvID           string  `json:"env_id"`
+	EnvName         string  `json:"env_name,omitempty"`
+	PythonVersion   string  `json:"python_version,omitempty"`
+	JobID           string  `json:"job_id,omitempty"`
+	Status          string  `json:"status"`
+	ExitCode        int     `json:"exit_code"`
+	Error           string  `json:"error,omitempty"`
+	Output          string  `json:"output"`
+	OutputTruncated bool    `json:"output_truncated,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// MatrixResult is the consolidated outcome of RunMatrix
+type MatrixResult struct {
+	AllPassed bool         `json:"all_passed"`
+	Passed    int          `json:"passed"`
+	Failed    int          `json:"failed"`
+	Errors    int          `json:"errors"`
+	Cells     []MatrixCell `json:"cells"`
+}
+
+// RunMatrix runs the same code or command in several environments in parallel, one
+// background job per environment, and waits for all of them. A cell that exits
+// non-zero is reported as failed rather than failing the matrix. Cancelling ctx
+// cancels the cells still running.
+func (m *Manager) RunMatrix(ctx context.Context, envIDs []string, spec MatrixSpec) (*MatrixResult, error) {
+	if (spec.Code == "") == (spec.Command == "") {
+		return nil, fmt.Errorf("exactly one of code and command is required")
+	}
+	if len(envIDs) == 0 {
+		return nil, fmt.Errorf("at least one environment is required")
+	}
+	if spec.Command != "" {
+		if err := m.checkCommand(spec.Command); err != nil {
+			return nil, err
+		}
+	}
+	if spec.OutputLines <= 0 {
+		spec.OutputLines = DefaultMatrixOutputLines
+	}
+
+	envs := make([]*ManagedEnvironment, len(envIDs))
+	for i, id := range envIDs {
+		// The tool middleware only checks a single env_id argument
+		if err := m.CheckEnvironmentAccess(ctx, id); err != nil {
+			return nil, err
+		}
+		env, err := m.GetEnvironment(id)
+		if err != nil {
+			return nil, err
+		}
+		envs[i] = env
+	}
+	workdir := ""
+	if spec.WorkdirEnvID != "" {
+		if err := m.CheckEnvironmentAccess(ctx, spec.WorkdirEnvID); err != nil {
+			return nil, err
+		}
+		env, err := m.GetEnvironment(spec.WorkdirEnvID)
+		if err != nil {
+			return nil, err
+		}
+		if env.WorkspaceDir == "" {
+			return nil, fmt.Errorf("environment %s has no workspace", spec.WorkdirEnvID)
+		}
+		workdir = env.WorkspaceDir
+	}
+
+	parallel := spec.MaxParallel
+	if parallel <= 0 || parallel > len(envs) {
+		parallel = len(envs)
+	}
+	slots := make(chan struct{}, parallel)
+
+	cells := make([]MatrixCell, len(envs))
+	jobs := make([]string, len(envs))
+	for i, env := range envs {
+		cells[i] = MatrixCell{EnvID: env.ID, EnvName: env.Name, PythonVersion: env.Env.PythonVersion.String()}
+		job, err := m.StartJob(ctx, "run_matrix", nil, func(ctx context.Context) (any, error) {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, checkCancelled(ctx)
+			}
+			defer func() { <-slots }()
+			return m.runMatrixCell(ctx, env, workdir, spec), nil
+		})
+		if err != nil {
+			for _, id := range jobs[:i] {
+				m.CancelJob(ctx, id)
+			}
+			return nil, err
+		}
+		jobs[i] = job.ID
+		cells[i].JobID = job.ID
+	}
+
+	result := &MatrixResult{Cells: cells}
+	for i, id := range jobs {
+		job, err := m.getJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			for _, id := range jobs[i:] {
+				m.CancelJob(ctx, id)
+			}
+			return nil, checkCancelled(ctx)
+		}
+
+		info, value, err := m.GetJobResult(ctx, id, 0)
+		if cell, ok := value.(*MatrixCell); ok {
+			cell.JobID = id
+			cells[i] = *cell
+		} else {
+			cells[i].Status = MatrixError
+			cells[i].Error = info.Status
+			if err != nil {
+				cells[i].Error = err.Error()
+			}
+		}
+
+		switch cells[i].Status {
+		case MatrixPassed:
+			result.Passed++
+		case MatrixFailed:
+			result.Failed++
+		default:
+			result.Errors++
+		}
+	}
+	result.AllPassed = result.Passed == len(cells)
+	return result, nil
+}
+
+// runMatrixCell runs the matrix work in one environment
+func (m *Manager) runMatrixCell(ctx context.Context, env *ManagedEnvironment, workdir string, spec MatrixSpec) *MatrixCell {
+	cell := &MatrixCell{EnvID: env.ID, EnvName: env.Name, PythonVersion: env.Env.PythonVersion.String()}
+	start := time.Now()
+	defer func() { cell.DurationSeconds = time.Since(start).Seconds() }()
+
+	fail := func(err error) *MatrixCell {
+		cell.Status = MatrixError
+		cell.ExitCode = -1
+		cell.Error = err.Error()
+		return cell
+	}
+
+	if spec.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, spec.Timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	var mounts []sandboxMount
+	if spec.Code != "" {
+		tmpFile, err := os.CreateTemp("", "matrix-*.py")
+		if err != nil {
+			return fail(fmt.Errorf("failed to create temp script: %w", err))
+		}
+		defer os.Remove(tmpFile.Name())
+		_, err = tmpFile.WriteString(spec.Code)
+		tmpFile.Close()
+		if err != nil {
+			return fail(fmt.Errorf("failed to write script: %w", err))
+		}
+		cmd = commandContext(ctx, env.Env.PythonPath, tmpFile.Name())
+		mounts = append(mounts, sandboxMount{path: tmpFile.Name()})
+		cmd.Dir = commandDir(env)
+		cmd.Env = env.appendVars(os.Environ())
+	} else {
+		var err error
+		if cmd, err = m.environmentCommand(ctx, env, spec.Command, spec.Args); err != nil {
+			return fail(err)
+		}
+	}
+	if workdir != "" {
+		cmd.Dir = workdir
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return fail(err)
+	}
+	defer unlock()
+
+	output, err := m.runIsolated(ctx, env, cmd, mounts...)
+	cell.Output, cell.OutputTruncated = tailLines(output, spec.OutputLines)
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		cell.Status = MatrixPassed
+	case errors.As(err, &exitErr) && !errors.Is(err, ErrCancelled):
+		cell.Status = MatrixFailed
+		cell.ExitCode = exitErr.ExitCode()
+	case errors.Is(err, ErrCancelled) && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fail(fmt.Errorf("timed out after %s", spec.Timeout))
+	default:
+		return fail(err)
+	}
+	return cell
+}
+
+// tailLines returns the last n lines of s and whether any were dropped
+func tailLines(s string, n int) (string, bool) {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return s, false
+	}
+	return strings.Join(lines[len(lines)-n:], "\n") + "\n", true
+}
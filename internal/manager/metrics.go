@@ -0,0 +1,234 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsScrapeTimeout bounds how long a single process scrape may take
+const metricsScrapeTimeout = 5 * time.Second
+
+// maxScrapeBytes bounds the payload read from a process; larger ones fail the scrape
+const maxScrapeBytes = 4 << 20
+
+// metricFamily groups the HELP/TYPE metadata and samples of one metric name
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	samples []string
+}
+
+// WriteMetrics writes Prometheus text-format metrics describing the server itself,
+// followed by the metrics scraped from every running process that registered a
+// scrape target. Scraped samples are labeled with the process and environment IDs.
+func (m *Manager) WriteMetrics(ctx context.Context, w io.Writer) {
+	type target struct {
+		id, name, envID, url string
+	}
+
+	m.mu.RLock()
+	envCount := len(m.environments)
+	replCount := len(m.replSessions)
+	running := 0
+	var targets []target
+	for _, proc := range m.spawnedProcesses {
+		proc.outputMu.RLock()
+		exited := proc.exited
+		proc.outputMu.RUnlock()
+		if exited {
+			continue
+		}
+		running++
+		if proc.MetricsURL != "" {
+			targets = append(targets, target{proc.ID, proc.Name, proc.EnvID, proc.MetricsURL})
+		}
+	}
+	m.mu.RUnlock()
+
+	fmt.Fprintln(w, "# HELP jumpboot_environments Number of managed environments.")
+	fmt.Fprintln(w, "# TYPE jumpboot_environments gauge")
+	fmt.Fprintf(w, "jumpboot_environments %d\n", envCount)
+	fmt.Fprintln(w, "# HELP jumpboot_repl_sessions Number of active REPL sessions.")
+	fmt.Fprintln(w, "# TYPE jumpboot_repl_sessions gauge")
+	fmt.Fprintf(w, "jumpboot_repl_sessions %d\n", replCount)
+	fmt.Fprintln(w, "# HELP jumpboot_processes_running Number of running spawned processes.")
+	fmt.Fprintln(w, "# TYPE jumpboot_processes_running gauge")
+	fmt.Fprintf(w, "jumpboot_processes_running %d\n", running)
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].id < targets[j].id })
+
+	// Scrape the targets concurrently, so a slow one does not delay the others
+	bodies := make([]string, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies[i], errs[i] = scrapeMetrics(ctx, t.url)
+		}()
+	}
+	wg.Wait()
+
+	// Merge samples into families so each family is contiguous
+	families := map[string]*metricFamily{}
+	var order []string
+	var upLines []string
+	for i, t := range targets {
+		labels := fmt.Sprintf(`jumpboot_process_id="%s",jumpboot_process_name="%s",jumpboot_env_id="%s"`,
+			escapeLabelValue(t.id), escapeLabelValue(t.name), escapeLabelValue(t.envID))
+		body, err := bodies[i], errs[i]
+		up := 1
+		if err != nil {
+			up = 0
+		} else {
+			mergeMetrics(body, labels, families, &order)
+		}
+		upLines = append(upLines, fmt.Sprintf("jumpboot_process_scrape_up{%s} %d", labels, up))
+	}
+
+	if len(upLines) > 0 {
+		fmt.Fprintln(w, "# HELP jumpboot_process_scrape_up Whether the last scrape of a process metrics target succeeded.")
+		fmt.Fprintln(w, "# TYPE jumpboot_process_scrape_up gauge")
+		for _, line := range upLines {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	for _, name := range order {
+		fam := families[name]
+		if fam.help != "" {
+			fmt.Fprintf(w, "# HELP %s %s\n", fam.name, fam.help)
+		}
+		if fam.typ != "" {
+			fmt.Fprintf(w, "# TYPE %s %s\n", fam.name, fam.typ)
+		}
+		for _, sample := range fam.samples {
+			fmt.Fprintln(w, sample)
+		}
+	}
+}
+
+// labelValueEscaper escapes what the Prometheus text format requires in label values
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes a label value for the Prometheus text format, which unlike
+// Go quoting leaves everything but backslash, double quote and newline as is
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+// scrapeMetrics fetches a Prometheus text-format payload of at most maxScrapeBytes
+func scrapeMetrics(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, metricsScrapeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("scrape %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxScrapeBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxScrapeBytes {
+		return "", fmt.Errorf("scrape %s: more than %d bytes", url, maxScrapeBytes)
+	}
+	return string(data), nil
+}
+
+// mergeMetrics parses a Prometheus text payload, adds labels to every sample and
+// appends the samples to their metric families
+func mergeMetrics(body, labels string, families map[string]*metricFamily, order *[]string) {
+	family := func(name string) *metricFamily {
+		fam, ok := families[name]
+		if !ok {
+			fam = &metricFamily{name: name}
+			families[name] = fam
+			*order = append(*order, name)
+		}
+		return fam
+	}
+
+	current := ""
+	for _, line := range splitLines(body) {
+		line = trimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				continue
+			}
+			current = fields[2]
+			fam := family(current)
+			rest := trimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "#"), " "+fields[1]+" "+fields[2]))
+			if fields[1] == "HELP" && fam.help == "" {
+				fam.help = rest
+			} else if fields[1] == "TYPE" && fam.typ == "" {
+				fam.typ = rest
+			}
+			continue
+		}
+
+		// Split "name{labels} value [timestamp]" into name, existing labels and the rest
+		nameEnd := strings.IndexAny(line, "{ ")
+		if nameEnd <= 0 {
+			continue
+		}
+		name := line[:nameEnd]
+		var sample string
+		if line[nameEnd] == '{' {
+			closeIdx := strings.LastIndex(line, "}")
+			if closeIdx < nameEnd {
+				continue
+			}
+			existing := trimSpace(line[nameEnd+1 : closeIdx])
+			if existing != "" {
+				existing = strings.TrimSuffix(existing, ",") + ","
+			}
+			sample = fmt.Sprintf("%s{%s%s}%s", name, existing, labels, line[closeIdx+1:])
+		} else {
+			sample = fmt.Sprintf("%s{%s}%s", name, labels, line[nameEnd:])
+		}
+
+		// Histogram/summary series belong to the family declared before them
+		famName := name
+		if current != "" && (name == current || isFamilySuffix(name, current)) {
+			famName = current
+		}
+		fam := family(famName)
+		fam.samples = append(fam.samples, sample)
+	}
+}
+
+// isFamilySuffix reports whether a sample name is a suffixed series of a family
+func isFamilySuffix(name, family string) bool {
+	for _, suffix := range []string{"_bucket", "_sum", "_count", "_total", "_created", "_info"} {
+		if name == family+suffix {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{`back\slash`, `back\\slash`},
+		{`say "hi"`, `say \"hi\"`},
+		{"two\nlines", `two\nlines`},
+		// Go quoting would escape these, Prometheus reads them as is
+		{"tab\there", "tab\there"},
+		{"café ☕", "café ☕"},
+		{"bell\a", "bell\a"},
+	}
+	for _, tt := range tests {
+		if got := escapeLabelValue(tt.in); got != tt.want {
+			t.Errorf("escapeLabelValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMetricsScrapesConcurrently(t *testing.T) {
+	// Each target answers only once both were asked, which a sequential scrape never does
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	both := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(both)
+	}()
+	target := func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+		select {
+		case <-both:
+			fmt.Fprintln(w, "requests_total 1")
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	a := httptest.NewServer(http.HandlerFunc(target))
+	defer a.Close()
+	b := httptest.NewServer(http.HandlerFunc(target))
+	defer b.Close()
+	huge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		line := strings.Repeat("x", 1023) + "\n"
+		for range maxScrapeBytes/len(line) + 1 {
+			fmt.Fprint(w, line)
+		}
+	}))
+	defer huge.Close()
+
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.spawnedProcesses = map[string]*ManagedProcess{
+		"a":    {ID: "a", EnvID: "env", MetricsURL: a.URL},
+		"b":    {ID: "b", EnvID: "env", MetricsURL: b.URL},
+		"huge": {ID: "huge", EnvID: "env", MetricsURL: huge.URL},
+	}
+	m.mu.Unlock()
+
+	var out strings.Builder
+	m.WriteMetrics(context.Background(), &out)
+	for _, want := range []string{
+		`jumpboot_process_scrape_up{jumpboot_process_id="a",jumpboot_process_name="",jumpboot_env_id="env"} 1`,
+		`jumpboot_process_scrape_up{jumpboot_process_id="b",jumpboot_process_name="",jumpboot_env_id="env"} 1`,
+		`jumpboot_process_scrape_up{jumpboot_process_id="huge",jumpboot_process_name="",jumpboot_env_id="env"} 0`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %s in\n%.2000s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// modelMarker prefixes the JSON printed by modelDownloadScript
+const modelMarker = "__JUMPBOOT_MODEL__"
+
+// Hugging Face repository types accepted by DownloadModel
+var modelRepoTypes = map[string]bool{"model": true, "dataset": true, "space": true}
+
+// modelRepoIDPattern matches "name" or "namespace/name" Hugging Face repository IDs
+var modelRepoIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(/[A-Za-z0-9][A-Za-z0-9._-]*)?$`)
+
+// modelDownloadScript runs huggingface_hub.snapshot_download and prints where the
+// snapshot is with its file count and size. Argument: JSON-encoded options.
+const modelDownloadScript = `
+import json, os, sys
+from huggingface_hub import snapshot_download
+opts = json.loads(sys.argv[1])
+path = snapshot_download(
+    repo_id=opts['repo_id'],
+    repo_type=opts['repo_type'],
+    revision=opts['revision'] or None,
+    allow_patterns=opts['allow_patterns'] or None,
+    ignore_patterns=opts['ignore_patterns'] or None,
+)
+files = size = 0
+for root, _, names in os.walk(path):
+    for name in names:
+        files += 1
+        # Snapshot entries are symlinks into the shared blob store
+        size += os.stat(os.path.join(root, name)).st_size
+print(%q + json.dumps({'local_path': path, 'files': files, 'size_bytes': size}))
+`
+
+// ModelDownloadOptions configures DownloadModel
+type ModelDownloadOptions struct {
+	Revision       string   // branch, tag or commit (default: the repository's default branch)
+	RepoType       string   // model (default), dataset or space
+	AllowPatterns  []string // only download files matching these globs
+	IgnorePatterns []string // skip files matching these globs
+	Token          string   // Hugging Face token for gated or private repositories
+}
+
+// ModelDownloadInfo describes a snapshot downloaded into the model cache
+type ModelDownloadInfo struct {
+	RepoID          string  `json:"repo_id"`
+	RepoType        string  `json:"repo_type"`
+	Revision        string  `json:"revision,omitempty"`
+	LocalPath       string  `json:"local_path"`
+	Files           int     `json:"files"`
+	SizeBytes       int64   `json:"size_bytes"`
+	CacheDir        string  `json:"cache_dir,omitempty"`
+	Installed       bool    `json:"installed,omitempty"` // huggingface_hub was installed first
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// SetModelCache makes dir the Hugging Face cache (HF_HOME) of every interpreter,
+// command and terminal the server starts, so environments share downloaded model
+// weights instead of each keeping a copy. The variable is set on the server process
+// and inherited by its children. An empty dir leaves HF_HOME untouched.
+func (m *Manager) SetModelCache(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid model cache directory: %w", err)
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return fmt.Errorf("failed to create model cache directory: %w", err)
+	}
+	if err := os.Setenv("HF_HOME", abs); err != nil {
+		return fmt.Errorf("failed to set HF_HOME: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.modelCache = abs
+	return nil
+}
+
+// ModelCache returns the shared model cache directory, or "" if none is configured
+func (m *Manager) ModelCache() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.modelCache
+}
+
+// DownloadModel downloads a Hugging Face repository snapshot with huggingface_hub,
+// installing it into the environment on first use. Files land in the shared model
+// cache, so a later download of the same revision from any environment is a no-op.
+func (m *Manager) DownloadModel(ctx context.Context, envID, repoID string, opts ModelDownloadOptions) (*ModelDownloadInfo, error) {
+	if !modelRepoIDPattern.MatchString(repoID) {
+		return nil, fmt.Errorf("invalid repo_id: %s (expected 'name' or 'namespace/name')", repoID)
+	}
+	if opts.RepoType == "" {
+		opts.RepoType = "model"
+	}
+	if !modelRepoTypes[opts.RepoType] {
+		return nil, fmt.Errorf("invalid repo_type: %s (use model, dataset or space)", opts.RepoType)
+	}
+
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	info := &ModelDownloadInfo{
+		RepoID:   repoID,
+		RepoType: opts.RepoType,
+		Revision: opts.Revision,
+		CacheDir: m.ModelCache(),
+	}
+
+	if _, err := runPython(ctx, env, "-c", "import huggingface_hub"); err != nil {
+		if errors.Is(err, ErrCancelled) {
+			return nil, err
+		}
+		if err := m.InstallPackages(ctx, envID, []string{"huggingface_hub"}, false); err != nil {
+			return nil, fmt.Errorf("failed to install huggingface_hub: %w", err)
+		}
+		info.Installed = true
+	}
+
+	args, err := json.Marshal(map[string]any{
+		"repo_id":         repoID,
+		"repo_type":       opts.RepoType,
+		"revision":        opts.Revision,
+		"allow_patterns":  opts.AllowPatterns,
+		"ignore_patterns": opts.IgnorePatterns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cmd := commandContext(ctx, env.Env.PythonPath, "-c", fmt.Sprintf(modelDownloadScript, modelMarker), string(args))
+	if opts.Token != "" {
+		// Passed through the environment so the token never shows up in process listings
+		cmd.Env = append(os.Environ(), "HF_TOKEN="+opts.Token)
+	}
+	output, err := runCommand(ctx, cmd)
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %s", repoID, lastLine(output))
+	}
+
+	if err := decodeMarkedJSON(output, modelMarker, info); err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", repoID, err)
+	}
+	info.DurationSeconds = time.Since(start).Seconds()
+	return info, nil
+}
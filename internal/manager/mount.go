@@ -0,0 +1,263 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Ways of mounting a host directory into a workspace
+const (
+	MountSymlink = "symlink" // a symbolic link to the host path
+	MountBind    = "bind"    // a bind mount (Linux, needs CAP_SYS_ADMIN)
+)
+
+// mountRoot is a host directory the operator allows to be mounted, with everything below it
+type mountRoot struct {
+	path     string
+	readOnly bool // mounts from it are always read-only
+}
+
+// WorkspaceMount is a host directory or file mounted into a workspace
+type WorkspaceMount struct {
+	Path      string    `json:"path"` // relative to the workspace
+	HostPath  string    `json:"host_path"`
+	Mode      string    `json:"mode"`
+	ReadOnly  bool      `json:"read_only"`
+	CreatedAt time.Time `json:"created_at"`
+	target    string    // absolute mount point
+}
+
+// SetMountPolicy sets the host directories workspace_mount may mount, with anything
+// below them. An entry ending in ":ro" may only be mounted read-only. Without entries,
+// nothing can be mounted.
+func (m *Manager) SetMountPolicy(allow []string) error {
+	var roots []mountRoot
+	for _, entry := range allow {
+		root := mountRoot{path: entry}
+		if p, ok := strings.CutSuffix(entry, ":ro"); ok {
+			root = mountRoot{path: p, readOnly: true}
+		}
+		if !filepath.IsAbs(root.path) {
+			return fmt.Errorf("mount directory must be an absolute path: %s", root.path)
+		}
+		resolved, err := filepath.EvalSymlinks(root.path)
+		if err != nil || !isDir(resolved) {
+			return fmt.Errorf("mount directory not found: %s", root.path)
+		}
+		root.path = resolved
+		roots = append(roots, root)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mountAllow = roots
+	return nil
+}
+
+// MountWorkspace makes a host path allowed by SetMountPolicy visible at path in the
+// workspace (default: the host path's base name), so data can be used without
+// copying it. A symlink mount works everywhere; a bind mount hides the host path
+// behind a real mount point and needs privileges. Read-only mounts refuse writes from
+// workspace tools and sandboxed processes, and bind mounts from every process.
+func (m *Manager) MountWorkspace(envID, hostPath, path, mode string, readOnly bool) (*WorkspaceMount, error) {
+	if mode == "" {
+		mode = MountSymlink
+	}
+	if mode != MountSymlink && mode != MountBind {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("unknown mount mode %q (use %s or %s)", mode, MountSymlink, MountBind))
+	}
+	if !filepath.IsAbs(hostPath) {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("host_path must be absolute: %s", hostPath))
+	}
+
+	m.mu.RLock()
+	roots := m.mountAllow
+	m.mu.RUnlock()
+	if len(roots) == 0 {
+		return nil, WithErrorCode(CodePermissionDenied, fmt.Errorf("this server does not allow host directories to be mounted; start it with -mount-allow"))
+	}
+	host, err := filepath.EvalSymlinks(filepath.Clean(hostPath))
+	if err != nil {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("host path not found: %s", hostPath))
+	}
+	allowed := false
+	for _, root := range roots {
+		if host == root.path || isSubPath(root.path, host) {
+			allowed = true
+			readOnly = readOnly || root.readOnly
+			break
+		}
+	}
+	if !allowed {
+		return nil, WithErrorCode(CodePermissionDenied, fmt.Errorf("host path not allowed by server policy: %s", hostPath))
+	}
+
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if path == "" {
+		path = filepath.Base(host)
+	}
+	target, err := safeJoinPath(env.WorkspaceDir, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("path already exists in the workspace: %s", path))
+	}
+	if mt := m.mountAt(env, target); mt != nil {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("cannot mount inside the mount at %s", mt.Path))
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	mount := &WorkspaceMount{
+		Path:      workspaceRelative(env.WorkspaceDir, target),
+		HostPath:  host,
+		Mode:      mode,
+		ReadOnly:  readOnly,
+		CreatedAt: time.Now().UTC(),
+		target:    target,
+	}
+	if mode == MountSymlink {
+		if err := os.Symlink(host, target); err != nil {
+			return nil, fmt.Errorf("failed to mount %s: %w", hostPath, err)
+		}
+	} else {
+		// The mount point has the host path's type
+		if isDir(host) {
+			err = os.Mkdir(target, 0755)
+		} else {
+			err = os.WriteFile(target, nil, 0644)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mount point: %w", err)
+		}
+		if err := bindMount(host, target, readOnly); err != nil {
+			os.Remove(target)
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	env.mounts = append(env.mounts, mount)
+	m.mu.Unlock()
+	return mount, nil
+}
+
+// UnmountWorkspace removes a mount from the workspace. The host path is not touched.
+func (m *Manager) UnmountWorkspace(envID, path string) (*WorkspaceMount, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clean := filepath.ToSlash(filepath.Clean(path))
+	i := slices.IndexFunc(env.mounts, func(mt *WorkspaceMount) bool { return mt.Path == clean })
+	if i < 0 {
+		return nil, notFound("mount", path)
+	}
+	mount := env.mounts[i]
+	if err := mount.remove(); err != nil {
+		return nil, err
+	}
+	env.mounts = slices.Delete(env.mounts, i, i+1)
+	return mount, nil
+}
+
+// ListMounts returns the mounts in an environment's workspace
+func (m *Manager) ListMounts(envID string) ([]*WorkspaceMount, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(env.mounts), nil
+}
+
+// remove unmounts the mount and deletes its mount point
+func (mt *WorkspaceMount) remove() error {
+	if mt.Mode == MountBind {
+		if err := bindUnmount(mt.target); err != nil {
+			return fmt.Errorf("failed to unmount %s: %w", mt.Path, err)
+		}
+	}
+	if err := os.Remove(mt.target); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove mount point %s: %w", mt.Path, err)
+	}
+	return nil
+}
+
+// removeMounts unmounts the environment's mounts below dir ("" = all of them), before
+// dir is deleted. Deleting a directory with a bind mount inside would delete the host
+// files. Callers must hold m.mu.
+func (m *Manager) removeMounts(env *ManagedEnvironment, dir string) error {
+	var errs []error
+	env.mounts = slices.DeleteFunc(env.mounts, func(mt *WorkspaceMount) bool {
+		if dir != "" && !isSubPath(dir, mt.target) {
+			return false
+		}
+		if err := mt.remove(); err != nil {
+			errs = append(errs, err)
+			return false
+		}
+		return true
+	})
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// mountAt returns the mount that contains a workspace path, if any. Callers must hold
+// m.mu.
+func (m *Manager) mountAt(env *ManagedEnvironment, full string) *WorkspaceMount {
+	for _, mt := range env.mounts {
+		if full == mt.target || isSubPath(mt.target, full) {
+			return mt
+		}
+	}
+	return nil
+}
+
+// checkMountWritable returns an error if a workspace path is in a read-only mount
+func (m *Manager) checkMountWritable(env *ManagedEnvironment, full string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if mt := m.mountAt(env, full); mt != nil && mt.ReadOnly {
+		return WithErrorCode(CodePermissionDenied, fmt.Errorf("%s is in the read-only mount %s", workspaceRelative(env.WorkspaceDir, full), mt.Path))
+	}
+	return nil
+}
+
+// checkNoMounts returns an error if a workspace path is or contains a mount point,
+// which must be unmounted before the path is moved or deleted
+func (m *Manager) checkNoMounts(env *ManagedEnvironment, full string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, mt := range env.mounts {
+		if full == mt.target || isSubPath(full, mt.target) {
+			return WithErrorCode(CodeInvalidArgument, fmt.Errorf("%s is or contains the mount %s; remove it with workspace_unmount first", workspaceRelative(env.WorkspaceDir, full), mt.Path))
+		}
+	}
+	return nil
+}
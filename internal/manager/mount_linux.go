@@ -0,0 +1,42 @@
+//go:build linux
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindMount bind-mounts source at target, read-only if asked
+func bindMount(source, target string, readOnly bool) error {
+	if err := unix.Mount(source, target, "", unix.MS_BIND, ""); err != nil {
+		if errors.Is(err, syscall.EPERM) {
+			return WithErrorCode(CodeUnsupported, fmt.Errorf("bind mounts need root or CAP_SYS_ADMIN; use mode symlink"))
+		}
+		return fmt.Errorf("failed to bind mount %s: %w", source, err)
+	}
+	if readOnly {
+		// MS_RDONLY is ignored by the first bind; it takes a remount
+		if err := unix.Mount("", target, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+			unix.Unmount(target, 0)
+			return fmt.Errorf("failed to make the mount of %s read-only: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// bindUnmount removes a bind mount made by bindMount
+func bindUnmount(target string) error {
+	err := unix.Unmount(target, 0)
+	if errors.Is(err, syscall.EBUSY) {
+		// Still in use by a process; detach it now and let the kernel finish later
+		err = unix.Unmount(target, unix.MNT_DETACH)
+	}
+	if errors.Is(err, syscall.EINVAL) {
+		return nil // not mounted any more
+	}
+	return err
+}
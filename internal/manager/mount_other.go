@@ -0,0 +1,15 @@
+//go:build !linux
+
+package manager
+
+import "fmt"
+
+// bindMount is only supported on Linux
+func bindMount(source, target string, readOnly bool) error {
+	return WithErrorCode(CodeUnsupported, fmt.Errorf("bind mounts are only supported on Linux; use mode symlink"))
+}
+
+// bindUnmount is only supported on Linux
+func bindUnmount(target string) error {
+	return nil
+}
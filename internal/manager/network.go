@@ -0,0 +1,247 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/richinsley/jumpboot-mcp/internal/netpolicy"
+)
+
+// Network policy modes
+const (
+	NetworkAllow     = "allow"     // unrestricted
+	NetworkDeny      = "deny"      // loopback only
+	NetworkAllowlist = "allowlist" // HTTP(S) to listed hosts through the server's proxy
+)
+
+// maxBlockedHosts is the number of recently refused hosts an environment remembers
+const maxBlockedHosts = 20
+
+// ErrNetworkRestricted is returned for interactive sessions in an environment whose
+// network is restricted, which cannot be confined to the policy
+var ErrNetworkRestricted = errors.New("REPLs and terminals are not available while the environment's network is restricted; use run_code, run_script or spawn_process")
+
+// NetworkPolicy controls what the code, scripts and processes an environment starts
+// may reach
+type NetworkPolicy struct {
+	Mode  string   `json:"mode"`
+	Hosts []string `json:"hosts,omitempty"` // allowlist: host names, IP addresses or *.domain
+}
+
+// NetworkInfo describes an environment's network policy
+type NetworkInfo struct {
+	EnvID string `json:"env_id"`
+	NetworkPolicy
+	Blocked []string `json:"blocked,omitempty"` // hosts the allowlist recently refused, newest last
+}
+
+// envNetwork is an environment's network policy and allowlist proxy
+type envNetwork struct {
+	policy   NetworkPolicy
+	proxy    *netpolicy.Proxy // started for the first allowlisted process
+	proxyDir string           // private directory of the proxy's socket
+	blocked  []string
+}
+
+// checkNetworkPolicy validates a policy for an environment and fills in the default:
+// deny for isolated environments, allow otherwise
+func checkNetworkPolicy(policy NetworkPolicy, isolated bool) (NetworkPolicy, error) {
+	switch policy.Mode {
+	case "":
+		policy.Mode = NetworkAllow
+		if isolated {
+			policy.Mode = NetworkDeny
+		}
+	case NetworkAllow, NetworkDeny, NetworkAllowlist:
+	default:
+		return policy, fmt.Errorf("unknown network policy %q (use %s, %s or %s)", policy.Mode, NetworkAllow, NetworkDeny, NetworkAllowlist)
+	}
+	if len(policy.Hosts) > 0 && policy.Mode != NetworkAllowlist {
+		return policy, fmt.Errorf("hosts are only used by the %s network policy", NetworkAllowlist)
+	}
+	for _, host := range policy.Hosts {
+		if err := netpolicy.ValidatePattern(host); err != nil {
+			return policy, err
+		}
+	}
+	policy.Hosts = slices.Compact(slices.Sorted(slices.Values(policy.Hosts)))
+
+	// Sandboxes disable the network themselves, but the allowlist proxy's helper is
+	// this binary, which must run inside them
+	if !netpolicy.Supported {
+		switch {
+		case policy.Mode == NetworkAllowlist:
+			return policy, fmt.Errorf("the %s network policy is only available on Linux", NetworkAllowlist)
+		case policy.Mode == NetworkDeny && !isolated:
+			return policy, fmt.Errorf("the %s network policy needs Linux or an isolated environment", NetworkDeny)
+		}
+	}
+	return policy, nil
+}
+
+// networkPolicy returns the environment's current policy
+func (env *ManagedEnvironment) networkPolicy() NetworkPolicy {
+	env.netMu.Lock()
+	defer env.netMu.Unlock()
+	return env.network.policy
+}
+
+// SetNetworkPolicy changes what processes the environment starts from now on may
+// reach. Processes already running keep their access, so a restriction is refused
+// while the environment has REPLs or terminals.
+func (m *Manager) SetNetworkPolicy(envID string, policy NetworkPolicy) (*NetworkInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if policy, err = checkNetworkPolicy(policy, env.isolated()); err != nil {
+		return nil, err
+	}
+	if policy.Mode != NetworkAllow && m.hasInteractiveSessions(envID) {
+		return nil, fmt.Errorf("close the environment's REPLs and terminals before restricting its network; they would keep unrestricted access")
+	}
+
+	env.netMu.Lock()
+	env.network.policy = policy
+	env.network.blocked = nil
+	if policy.Mode != NetworkAllowlist {
+		env.network.closeProxy()
+	}
+	env.netMu.Unlock()
+
+	return m.NetworkPolicy(envID)
+}
+
+// NetworkPolicy returns an environment's network policy and the hosts its allowlist
+// recently refused
+func (m *Manager) NetworkPolicy(envID string) (*NetworkInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	env.netMu.Lock()
+	defer env.netMu.Unlock()
+	return &NetworkInfo{
+		EnvID:         envID,
+		NetworkPolicy: env.network.policy,
+		Blocked:       slices.Clone(env.network.blocked),
+	}, nil
+}
+
+// hasInteractiveSessions reports whether the environment has REPLs or terminals
+func (m *Manager) hasInteractiveSessions(envID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, repl := range m.replSessions {
+		if repl.EnvID == envID {
+			return true
+		}
+	}
+	for _, term := range m.terminals {
+		if term.EnvID == envID {
+			return true
+		}
+	}
+	return false
+}
+
+// applyNetworkPolicy rewrites cmd, which has not been started, to run under the
+// environment's network policy, returning what a sandbox must also mount. Restricted
+// commands run through the net-exec helper: outside a sandbox in new user and network
+// namespaces, inside one in the sandbox's own network namespace.
+func (m *Manager) applyNetworkPolicy(env *ManagedEnvironment, cmd *exec.Cmd) ([]sandboxMount, error) {
+	policy := env.networkPolicy()
+	var socket string
+	switch policy.Mode {
+	case NetworkAllow:
+		return nil, nil
+	case NetworkDeny:
+		if env.isolated() {
+			return nil, nil
+		}
+	case NetworkAllowlist:
+		var err error
+		if socket, err = env.startNetworkProxy(); err != nil {
+			return nil, err
+		}
+	}
+
+	helper, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the network helper: %w", err)
+	}
+	args := []string{helper, netpolicy.ExecCommand}
+	if socket != "" {
+		args = append(args, "-proxy-socket", socket)
+	}
+	cmd.Args = append(append(args, "--", cmd.Path), cmd.Args[1:]...)
+	cmd.Path = helper
+
+	if !env.isolated() {
+		cmd.SysProcAttr = netpolicy.NamespaceAttr()
+		return nil, nil
+	}
+	mounts := []sandboxMount{{path: helper}}
+	if socket != "" {
+		mounts = append(mounts, sandboxMount{path: filepath.Dir(socket), writable: true})
+	}
+	return mounts, nil
+}
+
+// startNetworkProxy starts the environment's allowlist proxy if it is not running and
+// returns its socket
+func (env *ManagedEnvironment) startNetworkProxy() (string, error) {
+	env.netMu.Lock()
+	defer env.netMu.Unlock()
+	n := &env.network
+	if n.proxy != nil {
+		return filepath.Join(n.proxyDir, "proxy.sock"), nil
+	}
+
+	dir, err := os.MkdirTemp("", "jumpboot-net-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create network proxy directory: %w", err)
+	}
+	socket := filepath.Join(dir, "proxy.sock")
+	allow := func(host string) bool {
+		env.netMu.Lock()
+		defer env.netMu.Unlock()
+		return n.policy.Mode == NetworkAllowlist && netpolicy.Allowed(n.policy.Hosts, host)
+	}
+	deny := func(host string) {
+		env.netMu.Lock()
+		defer env.netMu.Unlock()
+		n.blocked = append(slices.DeleteFunc(n.blocked, func(h string) bool { return h == host }), host)
+		if len(n.blocked) > maxBlockedHosts {
+			n.blocked = n.blocked[len(n.blocked)-maxBlockedHosts:]
+		}
+	}
+	proxy, err := netpolicy.Listen(socket, allow, deny)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	n.proxy, n.proxyDir = proxy, dir
+	return socket, nil
+}
+
+// closeProxy stops the allowlist proxy. Callers must hold env.netMu.
+func (n *envNetwork) closeProxy() {
+	if n.proxy == nil {
+		return
+	}
+	n.proxy.Close()
+	os.RemoveAll(n.proxyDir)
+	n.proxy, n.proxyDir = nil, ""
+}
+
+// stopNetworkProxy stops the environment's allowlist proxy, if it is running
+func (env *ManagedEnvironment) stopNetworkProxy() {
+	env.netMu.Lock()
+	defer env.netMu.Unlock()
+	env.network.closeProxy()
+}
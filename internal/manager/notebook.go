@@ -0,0 +1,406 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of a notebook run and its cells
+const (
+	NotebookCompleted = "completed"
+	NotebookFailed    = "failed"
+	NotebookSkipped   = "skipped" // not reached because an earlier cell failed
+)
+
+// notebookOutputChars bounds the output and traceback kept per cell
+const notebookOutputChars = 2000
+
+// notebookLogChars bounds the papermill log returned with a failed run
+const notebookLogChars = 4000
+
+// ansiEscape matches the terminal colour codes in Jupyter tracebacks
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// NotebookOptions configures RunNotebook
+type NotebookOptions struct {
+	Parameters  map[string]any // values for the notebook's "parameters" cell
+	OutputPath  string         // workspace file for the executed notebook ("" = <name>.executed.ipynb)
+	Kernel      string         // Jupyter kernel ("" = python3, the environment's interpreter)
+	CellTimeout time.Duration  // limit per cell (0 = none)
+}
+
+// NotebookError is the exception a notebook cell raised
+type NotebookError struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Traceback string `json:"traceback,omitempty"`
+}
+
+// NotebookCell is the outcome of one code cell
+type NotebookCell struct {
+	Index           int            `json:"index"` // position among all cells of the notebook
+	Status          string         `json:"status"`
+	ExecutionCount  *int           `json:"execution_count,omitempty"`
+	DurationSeconds float64        `json:"duration_seconds,omitempty"`
+	Source          string         `json:"source"`             // first line of the cell
+	Injected        bool           `json:"injected,omitempty"` // the parameters cell added by papermill
+	Output          string         `json:"output,omitempty"`   // tail of the text output
+	Error           *NotebookError `json:"error,omitempty"`
+}
+
+// NotebookResult is the outcome of RunNotebook
+type NotebookResult struct {
+	Path            string         `json:"path"`
+	OutputPath      string         `json:"output_path"` // executed notebook in the workspace
+	Status          string         `json:"status"`
+	Installed       bool           `json:"installed,omitempty"` // papermill was installed on demand
+	Cells           []NotebookCell `json:"cells"`
+	Completed       int            `json:"completed"`
+	Failed          int            `json:"failed"`
+	Skipped         int            `json:"skipped"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Log             string         `json:"log,omitempty"` // tail of papermill's output when a cell failed
+}
+
+// RunNotebook executes a .ipynb file from the workspace with papermill, which injects
+// Parameters after the cell tagged "parameters", and saves the executed notebook with
+// its outputs to the workspace. papermill and ipykernel are installed into the
+// environment if needed. A failing cell stops the run; it is reported with status
+// failed, not as an error, and the remaining cells are skipped.
+func (m *Manager) RunNotebook(ctx context.Context, envID, path string, opts NotebookOptions) (*NotebookResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if !strings.HasSuffix(path, ".ipynb") {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("not a notebook: %s (expected a .ipynb file)", path))
+	}
+	input, err := safeJoinPath(env.WorkspaceDir, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(input); err != nil {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("notebook not found: %s", path))
+	}
+	outputPath := opts.OutputPath
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(path, ".ipynb") + ".executed.ipynb"
+	}
+	output, err := safeJoinPath(env.WorkspaceDir, outputPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkMountWritable(env, output); err != nil {
+		return nil, err
+	}
+	kernel := opts.Kernel
+	if kernel == "" {
+		kernel = "python3"
+	}
+
+	args := []string{"-m", "papermill", input, output, "--kernel", kernel, "--cwd", filepath.Dir(input), "--no-progress-bar", "--log-output"}
+	if len(opts.Parameters) > 0 {
+		// papermill reads parameters as YAML, which JSON is a subset of
+		params, err := json.Marshal(opts.Parameters)
+		if err != nil {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid parameters: %w", err))
+		}
+		args = append(args, "--parameters_yaml", string(params))
+	}
+	if opts.CellTimeout > 0 {
+		args = append(args, "--execution-timeout", strconv.Itoa(int(opts.CellTimeout.Seconds())))
+	}
+
+	result := &NotebookResult{Path: path, OutputPath: workspaceRelative(env.WorkspaceDir, output)}
+	if _, err := runPython(ctx, env, "-c", "import papermill, ipykernel"); err != nil {
+		if errors.Is(err, ErrCancelled) {
+			return nil, err
+		}
+		if err := m.InstallPackages(ctx, envID, []string{"papermill", "ipykernel"}, false); err != nil {
+			return nil, fmt.Errorf("failed to install papermill: %w", err)
+		}
+		result.Installed = true
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// A notebook left from an earlier run must not be mistaken for this run's
+	if output != input {
+		os.Remove(output)
+	}
+	cmd := commandContext(ctx, env.Env.PythonPath, args...)
+	cmd.Dir = filepath.Dir(input)
+	// Keep kernels installed for the server's user from replacing the environment's
+	// own interpreter as python3
+	cmd.Env = append(env.appendVars(os.Environ()), "JUPYTER_DATA_DIR="+filepath.Join(env.RootDir, ".jupyter"))
+	start := time.Now()
+	log, runErr := m.runIsolated(ctx, env, cmd)
+	result.DurationSeconds = time.Since(start).Seconds()
+	if errors.Is(runErr, ErrCancelled) {
+		return nil, runErr
+	}
+
+	if err := readExecutedNotebook(output, result); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("notebook execution failed: %w\nOutput: %s", runErr, tailString(log, notebookLogChars))
+		}
+		return nil, err
+	}
+	if runErr != nil && result.Failed == 0 {
+		// papermill failed outside the cells, e.g. the kernel did not start
+		return nil, fmt.Errorf("notebook execution failed: %w\nOutput: %s", runErr, tailString(log, notebookLogChars))
+	}
+	result.Status = NotebookCompleted
+	if result.Failed > 0 {
+		result.Status = NotebookFailed
+		result.Log = tailString(log, notebookLogChars)
+	}
+	return result, nil
+}
+
+// notebookText is a notebook string field, which may be a string or a list of lines
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = notebookText(s)
+	return nil
+}
+
+// readExecutedNotebook fills result with the status of each code cell of the notebook
+// papermill wrote to path
+func readExecutedNotebook(path string, result *NotebookResult) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the executed notebook: %w", err)
+	}
+	var nb struct {
+		Cells []struct {
+			CellType       string       `json:"cell_type"`
+			Source         notebookText `json:"source"`
+			ExecutionCount *int         `json:"execution_count"`
+			Metadata       struct {
+				Tags      []string `json:"tags"`
+				Papermill struct {
+					Status   string   `json:"status"`
+					Duration *float64 `json:"duration"`
+				} `json:"papermill"`
+			} `json:"metadata"`
+			Outputs []struct {
+				OutputType string                     `json:"output_type"`
+				Text       notebookText               `json:"text"`
+				Data       map[string]json.RawMessage `json:"data"`
+				EName      string                     `json:"ename"`
+				EValue     string                     `json:"evalue"`
+				Traceback  []string                   `json:"traceback"`
+			} `json:"outputs"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return fmt.Errorf("failed to parse the executed notebook: %w", err)
+	}
+
+	result.Cells = []NotebookCell{}
+	for i, c := range nb.Cells {
+		if c.CellType != "code" {
+			continue
+		}
+		source, _, _ := strings.Cut(strings.TrimSpace(string(c.Source)), "\n")
+		cell := NotebookCell{
+			Index:          i,
+			ExecutionCount: c.ExecutionCount,
+			Source:         source,
+			Injected:       slices.Contains(c.Metadata.Tags, "injected-parameters"),
+		}
+		if c.Metadata.Papermill.Duration != nil {
+			cell.DurationSeconds = *c.Metadata.Papermill.Duration
+		}
+
+		var text strings.Builder
+		for _, out := range c.Outputs {
+			switch out.OutputType {
+			case "stream":
+				text.WriteString(string(out.Text))
+			case "execute_result", "display_data":
+				var plain notebookText
+				if json.Unmarshal(out.Data["text/plain"], &plain) == nil {
+					text.WriteString(string(plain) + "\n")
+				}
+			case "error":
+				cell.Error = &NotebookError{
+					Name:      out.EName,
+					Value:     out.EValue,
+					Traceback: tailString(ansiEscape.ReplaceAllString(strings.Join(out.Traceback, "\n"), ""), notebookOutputChars),
+				}
+			}
+		}
+		cell.Output = tailString(text.String(), notebookOutputChars)
+
+		switch {
+		case c.Metadata.Papermill.Status == "failed" || cell.Error != nil:
+			cell.Status = NotebookFailed
+			result.Failed++
+		case c.Metadata.Papermill.Status == "completed" || (c.Metadata.Papermill.Status == "" && c.ExecutionCount != nil):
+			cell.Status = NotebookCompleted
+			result.Completed++
+		default:
+			cell.Status = NotebookSkipped
+			result.Skipped++
+		}
+		result.Cells = append(result.Cells, cell)
+	}
+	return nil
+}
+
+// Text formats of notebooks for ConvertNotebook
+const (
+	NotebookFormatPercent  = "percent"  // Python with # %% cell markers
+	NotebookFormatLight    = "light"    // Python with cells separated by blank lines
+	NotebookFormatMarkdown = "markdown" // Markdown with fenced code cells
+)
+
+// jupytextFormats maps the text formats to jupytext's --to values
+var jupytextFormats = map[string]string{
+	NotebookFormatPercent:  "py:percent",
+	NotebookFormatLight:    "py:light",
+	NotebookFormatMarkdown: "md",
+}
+
+// ConversionResult is the outcome of ConvertNotebook
+type ConversionResult struct {
+	Path       string `json:"path"`
+	OutputPath string `json:"output_path"`
+	Format     string `json:"format"`
+	Size       int64  `json:"size"`
+	Installed  bool   `json:"installed,omitempty"` // jupytext was installed on demand
+	Updated    bool   `json:"updated,omitempty"`   // an existing notebook kept its outputs
+}
+
+// ConvertNotebook converts between a workspace notebook and a text file with
+// jupytext, installing it into the environment if needed. With toNotebook, the .py
+// or .md file at path becomes a .ipynb; an existing notebook at the output keeps the
+// outputs of cells whose code is unchanged. Otherwise the .ipynb at path becomes a
+// text file in format (default NotebookFormatPercent), without outputs. output
+// defaults to path with the new extension.
+func (m *Manager) ConvertNotebook(ctx context.Context, envID, path, output, format string, toNotebook bool) (*ConversionResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+
+	ext := filepath.Ext(path)
+	to := "ipynb"
+	if toNotebook {
+		if ext != ".py" && ext != ".md" {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("not a script: %s (expected a .py or .md file)", path))
+		}
+		format = "ipynb"
+	} else {
+		if ext != ".ipynb" {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("not a notebook: %s (expected a .ipynb file)", path))
+		}
+		if format == "" {
+			format = NotebookFormatPercent
+		}
+		var ok bool
+		if to, ok = jupytextFormats[format]; !ok {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("unknown format %q (use %s, %s or %s)", format, NotebookFormatPercent, NotebookFormatLight, NotebookFormatMarkdown))
+		}
+	}
+	if output == "" {
+		newExt := ".ipynb"
+		if !toNotebook {
+			newExt = "." + strings.SplitN(to, ":", 2)[0]
+		}
+		output = strings.TrimSuffix(path, ext) + newExt
+	}
+	input, err := safeJoinPath(env.WorkspaceDir, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(input); err != nil {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("file not found: %s", path))
+	}
+	outputPath, err := safeJoinPath(env.WorkspaceDir, output)
+	if err != nil {
+		return nil, err
+	}
+	if outputPath == input {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("output_path must differ from path"))
+	}
+	if err := m.checkMountWritable(env, outputPath); err != nil {
+		return nil, err
+	}
+
+	result := &ConversionResult{Path: path, OutputPath: workspaceRelative(env.WorkspaceDir, outputPath), Format: format}
+	if _, err := runPython(ctx, env, "-m", "jupytext", "--version"); err != nil {
+		if errors.Is(err, ErrCancelled) {
+			return nil, err
+		}
+		if err := m.InstallPackages(ctx, envID, []string{"jupytext"}, false); err != nil {
+			return nil, fmt.Errorf("failed to install jupytext: %w", err)
+		}
+		result.Installed = true
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	args := []string{"-m", "jupytext", "--to", to, "--output", outputPath}
+	if _, err := os.Stat(outputPath); err == nil && toNotebook {
+		args = append(args, "--update")
+		result.Updated = true
+	}
+	cmd := commandContext(ctx, env.Env.PythonPath, append(args, input)...)
+	cmd.Dir = env.WorkspaceDir
+	cmd.Env = env.appendVars(os.Environ())
+	if out, err := runCommand(ctx, cmd); err != nil {
+		return nil, fmt.Errorf("conversion failed: %w\nOutput: %s", err, out)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat output: %w", err)
+	}
+	result.Size = info.Size()
+	return result, nil
+}
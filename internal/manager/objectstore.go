@@ -0,0 +1,559 @@
+package manager
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object store types, named by their URI schemes
+const (
+	ObjectStoreS3  = "s3"
+	ObjectStoreGCS = "gs"
+)
+
+// Directions of SyncRemote
+const (
+	SyncPull = "pull" // object storage to workspace
+	SyncPush = "push" // workspace to object storage
+)
+
+// Limits of SyncRemote
+const (
+	maxSyncObjects   = 100000 // objects or files one sync may consider
+	maxSyncListed    = 200    // transferred files listed in the result
+	syncWorkers      = 4      // files transferred at once
+	syncPartSuffix   = ".part"
+	objectStoreAgent = "jumpboot-mcp"
+)
+
+// ObjectStoreSpec is an entry of the -object-stores file. Secrets are best read from
+// the server's environment with the *_env fields.
+type ObjectStoreSpec struct {
+	Name               string   `json:"name"`
+	Type               string   `json:"type"`                            // ObjectStoreS3 or ObjectStoreGCS
+	Buckets            []string `json:"buckets,omitempty"`               // bucket globs it serves (empty = all)
+	Endpoint           string   `json:"endpoint,omitempty"`              // S3-compatible service or GCS emulator
+	Region             string   `json:"region,omitempty"`                // S3 region (default us-east-1)
+	AccessKeyID        string   `json:"access_key_id,omitempty"`         // S3
+	SecretAccessKeyEnv string   `json:"secret_access_key_env,omitempty"` // S3
+	SessionTokenEnv    string   `json:"session_token_env,omitempty"`     // S3 temporary credentials
+	CredentialsFile    string   `json:"credentials_file,omitempty"`      // GCS service account key
+	AccessTokenEnv     string   `json:"access_token_env,omitempty"`      // GCS OAuth access token
+}
+
+// ObjectStore is a configured object storage account, as listed to clients (no
+// credentials)
+type ObjectStore struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Buckets  []string `json:"buckets,omitempty"`
+	Endpoint string   `json:"endpoint,omitempty"`
+	Auth     bool     `json:"auth"`
+
+	client objectClient
+}
+
+// objectInfo is an object found by a listing
+type objectInfo struct {
+	Key  string
+	Size int64
+	MD5  string // hex; empty if the store does not know it (S3 multipart uploads)
+}
+
+// objectClient talks to one object storage service
+type objectClient interface {
+	list(ctx context.Context, bucket, prefix string, limit int) ([]objectInfo, error)
+	get(ctx context.Context, bucket, key string, w io.Writer) error
+	put(ctx context.Context, bucket, key, file string, size int64) error
+}
+
+// LoadObjectStores reads a JSON array of ObjectStoreSpec
+func LoadObjectStores(path string) ([]ObjectStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object store file: %w", err)
+	}
+	var specs []ObjectStoreSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse object store file %s: %w", path, err)
+	}
+
+	stores := make([]ObjectStore, 0, len(specs))
+	for _, spec := range specs {
+		store, err := spec.store()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if slices.ContainsFunc(stores, func(s ObjectStore) bool { return s.Name == store.Name }) {
+			return nil, fmt.Errorf("%s: duplicate object store %s", path, store.Name)
+		}
+		stores = append(stores, store)
+	}
+	return stores, nil
+}
+
+// store validates the spec, reads its credentials and creates its client
+func (s ObjectStoreSpec) store() (ObjectStore, error) {
+	if s.Name == "" {
+		return ObjectStore{}, fmt.Errorf("object store needs a name")
+	}
+	for _, pattern := range s.Buckets {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return ObjectStore{}, fmt.Errorf("object store %s: invalid bucket pattern %q", s.Name, pattern)
+		}
+	}
+	if s.Endpoint != "" {
+		if u, err := url.Parse(s.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ObjectStore{}, fmt.Errorf("object store %s: invalid endpoint %q", s.Name, s.Endpoint)
+		}
+	}
+	env := func(name string) (string, error) {
+		if name == "" {
+			return "", nil
+		}
+		value := os.Getenv(name)
+		if value == "" {
+			return "", fmt.Errorf("object store %s: environment variable %s is not set", s.Name, name)
+		}
+		return value, nil
+	}
+
+	store := ObjectStore{Name: s.Name, Type: s.Type, Buckets: s.Buckets, Endpoint: s.Endpoint}
+	switch s.Type {
+	case ObjectStoreS3:
+		secret, err := env(s.SecretAccessKeyEnv)
+		if err != nil {
+			return ObjectStore{}, err
+		}
+		token, err := env(s.SessionTokenEnv)
+		if err != nil {
+			return ObjectStore{}, err
+		}
+		if (s.AccessKeyID == "") != (secret == "") {
+			return ObjectStore{}, fmt.Errorf("object store %s: set both access_key_id and secret_access_key_env, or neither for public buckets", s.Name)
+		}
+		region := s.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		store.Auth = s.AccessKeyID != ""
+		store.client = &s3Client{endpoint: strings.TrimSuffix(s.Endpoint, "/"), region: region, accessKey: s.AccessKeyID, secretKey: secret, sessionToken: token}
+	case ObjectStoreGCS:
+		token, err := env(s.AccessTokenEnv)
+		if err != nil {
+			return ObjectStore{}, err
+		}
+		client := &gcsClient{endpoint: strings.TrimSuffix(s.Endpoint, "/"), token: token}
+		if s.CredentialsFile != "" {
+			if client.account, err = loadServiceAccount(s.CredentialsFile); err != nil {
+				return ObjectStore{}, fmt.Errorf("object store %s: %w", s.Name, err)
+			}
+		}
+		if client.endpoint == "" {
+			client.endpoint = "https://storage.googleapis.com"
+		}
+		store.Auth = token != "" || client.account != nil
+		store.client = client
+	default:
+		return ObjectStore{}, fmt.Errorf("object store %s: unknown type %q (use %s or %s)", s.Name, s.Type, ObjectStoreS3, ObjectStoreGCS)
+	}
+	return store, nil
+}
+
+// SetObjectStores configures the object storage workspace_sync_remote may use
+func (m *Manager) SetObjectStores(stores []ObjectStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objectStores = stores
+}
+
+// ObjectStores returns the configured object storage without credentials
+func (m *Manager) ObjectStores() []ObjectStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.objectStores)
+}
+
+// objectStore finds the configured store serving a bucket
+func (m *Manager) objectStore(scheme, bucket string) (*ObjectStore, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.objectStores) == 0 {
+		return nil, WithErrorCode(CodePermissionDenied, fmt.Errorf("no object storage is configured on this server; start it with -object-stores"))
+	}
+	for i, store := range m.objectStores {
+		if store.Type != scheme {
+			continue
+		}
+		if len(store.Buckets) == 0 || slices.ContainsFunc(store.Buckets, func(pattern string) bool {
+			ok, _ := path.Match(pattern, bucket)
+			return ok
+		}) {
+			return &m.objectStores[i], nil
+		}
+	}
+	return nil, WithErrorCode(CodePermissionDenied, fmt.Errorf("no object store is configured for %s://%s", scheme, bucket))
+}
+
+// parseObjectURI splits s3://bucket/prefix or gs://bucket/prefix
+func parseObjectURI(uri string) (scheme, bucket, prefix string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != ObjectStoreS3 && u.Scheme != ObjectStoreGCS) || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", "", WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid object storage URI %q (use s3://bucket/prefix or gs://bucket/prefix)", uri))
+	}
+	return u.Scheme, u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// SyncOptions configures SyncRemote
+type SyncOptions struct {
+	Direction string   // SyncPull or SyncPush
+	Path      string   // workspace directory (or file, for a push) to sync ("" = the workspace)
+	Include   []string // globs files must match, relative to the prefix or Path
+	Exclude   []string // globs of files to leave out
+	DryRun    bool     // only report what would be transferred
+	Progress  func(done, total int64)
+}
+
+// SyncedFile is a file transferred by SyncRemote
+type SyncedFile struct {
+	Path string `json:"path"` // relative to the workspace
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// SyncResult describes a SyncRemote run
+type SyncResult struct {
+	URI             string       `json:"uri"`
+	Path            string       `json:"path"`
+	Direction       string       `json:"direction"`
+	DryRun          bool         `json:"dry_run,omitempty"`
+	Transferred     int          `json:"transferred"`
+	Bytes           int64        `json:"bytes"`
+	Unchanged       int          `json:"unchanged"` // files whose size and checksum already matched
+	Files           []SyncedFile `json:"files,omitempty"`
+	Truncated       bool         `json:"truncated,omitempty"` // more files than listed
+	DurationSeconds float64      `json:"duration_seconds"`
+}
+
+// SyncRemote copies files between an s3:// or gs:// prefix and the workspace, with
+// the credentials the operator configured for the bucket, so data never passes
+// through the client. A pull downloads objects below the prefix into Path; a push
+// uploads the files below Path to the prefix. Files whose size and MD5 already match
+// are skipped, and nothing is ever deleted. Include and exclude globs work as in
+// ListWorkspaceTree, on paths relative to the prefix or Path.
+func (m *Manager) SyncRemote(ctx context.Context, envID, uri string, opts SyncOptions) (*SyncResult, error) {
+	if opts.Direction != SyncPull && opts.Direction != SyncPush {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("direction must be %s or %s", SyncPull, SyncPush))
+	}
+	for _, pattern := range slices.Concat(opts.Include, opts.Exclude) {
+		if !validGlob(pattern) {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid glob %q", pattern))
+		}
+	}
+	scheme, bucket, prefix, err := parseObjectURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	store, err := m.objectStore(scheme, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Direction == SyncPull && !opts.DryRun {
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	root := env.WorkspaceDir
+	if opts.Path != "" {
+		if root, err = safeJoinPath(env.WorkspaceDir, opts.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	result := &SyncResult{URI: uri, Path: workspaceRelative(env.WorkspaceDir, root), Direction: opts.Direction, DryRun: opts.DryRun}
+	if opts.Path == "" {
+		result.Path = ""
+	}
+	var plan []syncTransfer
+	if opts.Direction == SyncPull {
+		plan, err = m.planPull(ctx, env, store, bucket, prefix, root, opts, result)
+	} else {
+		plan, err = planPush(ctx, env, store, bucket, prefix, root, opts, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, t := range plan {
+		total += t.file.Size
+	}
+	for i, t := range plan {
+		if i == maxSyncListed {
+			result.Truncated = true
+			break
+		}
+		result.Files = append(result.Files, t.file)
+	}
+	result.Transferred, result.Bytes = len(plan), total
+	if !opts.DryRun {
+		if err := runTransfers(ctx, store, bucket, plan, opts.Direction, total, opts.Progress); err != nil {
+			return nil, err
+		}
+	}
+	result.DurationSeconds = time.Since(start).Seconds()
+	return result, nil
+}
+
+// syncTransfer is one file to copy
+type syncTransfer struct {
+	file  SyncedFile
+	local string // absolute workspace path
+}
+
+// planPull lists the objects below prefix and returns those missing or different in
+// the workspace
+func (m *Manager) planPull(ctx context.Context, env *ManagedEnvironment, store *ObjectStore, bucket, prefix, root string, opts SyncOptions, result *SyncResult) ([]syncTransfer, error) {
+	objects, err := store.client.list(ctx, bucket, prefix, maxSyncObjects)
+	if err != nil {
+		return nil, err
+	}
+	var plan []syncTransfer
+	for _, obj := range objects {
+		var rel string
+		switch {
+		case strings.HasSuffix(obj.Key, "/"):
+			continue // a folder marker
+		case obj.Key == prefix:
+			rel = path.Base(obj.Key) // the URI names one object
+		case prefix == "" || strings.HasSuffix(prefix, "/"):
+			rel = obj.Key[len(prefix):]
+		case strings.HasPrefix(obj.Key, prefix+"/"):
+			rel = obj.Key[len(prefix)+1:]
+		default:
+			continue // data2/x for the prefix data
+		}
+		if matchAnyGlob(opts.Exclude, rel) || (len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel)) {
+			continue
+		}
+		local, err := safeJoinPath(root, rel)
+		if err != nil {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("object key %s is not a safe workspace path; exclude it", obj.Key))
+		}
+		if err := m.checkMountWritable(env, local); err != nil {
+			return nil, err
+		}
+		if sameContent(local, obj) {
+			result.Unchanged++
+			continue
+		}
+		plan = append(plan, syncTransfer{
+			file:  SyncedFile{Path: workspaceRelative(env.WorkspaceDir, local), Key: obj.Key, Size: obj.Size},
+			local: local,
+		})
+	}
+	return plan, nil
+}
+
+// planPush walks the files below root and returns those missing or different below
+// prefix
+func planPush(ctx context.Context, env *ManagedEnvironment, store *ObjectStore, bucket, prefix, root string, opts SyncOptions, result *SyncResult) ([]syncTransfer, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("file not found: %s", opts.Path))
+	}
+
+	type localFile struct {
+		path, rel string
+		size      int64
+	}
+	var files []localFile
+	if !info.IsDir() {
+		files = append(files, localFile{path: root, rel: filepath.Base(root), size: info.Size()})
+	} else {
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel := filepath.ToSlash(strings.TrimPrefix(p, root+string(filepath.Separator)))
+			switch {
+			case p == root:
+				return nil
+			case d.IsDir() && slices.Contains(treeIgnoredDirs, d.Name()):
+				return filepath.SkipDir
+			case d.IsDir() && matchAnyGlob(opts.Exclude, rel):
+				return filepath.SkipDir
+			case !d.Type().IsRegular():
+				return nil // directories, links and special files
+			case matchAnyGlob(opts.Exclude, rel) || (len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel)):
+				return nil
+			}
+			if len(files) == maxSyncObjects {
+				return WithErrorCode(CodeQuotaExceeded, fmt.Errorf("more than %d files to push; narrow path or include", maxSyncObjects))
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, localFile{path: p, rel: rel, size: fi.Size()})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A single file goes to the prefix itself, unless it names a folder
+	base := strings.TrimSuffix(prefix, "/")
+	keyOf := func(rel string) string {
+		if base == "" {
+			return rel
+		}
+		return base + "/" + rel
+	}
+	if !info.IsDir() && prefix != "" && !strings.HasSuffix(prefix, "/") {
+		keyOf = func(string) string { return prefix }
+	}
+
+	existing, err := store.client.list(ctx, bucket, base, maxSyncObjects)
+	if err != nil {
+		return nil, err
+	}
+	remote := make(map[string]objectInfo, len(existing))
+	for _, obj := range existing {
+		remote[obj.Key] = obj
+	}
+	var plan []syncTransfer
+	for _, f := range files {
+		key := keyOf(f.rel)
+		if obj, ok := remote[key]; ok && sameContent(f.path, obj) {
+			result.Unchanged++
+			continue
+		}
+		plan = append(plan, syncTransfer{
+			file:  SyncedFile{Path: workspaceRelative(env.WorkspaceDir, f.path), Key: key, Size: f.size},
+			local: f.path,
+		})
+	}
+	return plan, nil
+}
+
+// sameContent reports whether a local file already has an object's content: the same
+// size and, when the store knows it, the same MD5
+func sameContent(local string, obj objectInfo) bool {
+	info, err := os.Stat(local)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != obj.Size {
+		return false
+	}
+	if obj.MD5 == "" {
+		return true
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == obj.MD5
+}
+
+// runTransfers copies the planned files with a few workers, stopping at the first
+// error
+func runTransfers(ctx context.Context, store *ObjectStore, bucket string, plan []syncTransfer, direction string, total int64, progress func(int64, int64)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		done     int64
+		wg       sync.WaitGroup
+	)
+	work := make(chan syncTransfer)
+	for range min(syncWorkers, len(plan)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range work {
+				var err error
+				if direction == SyncPull {
+					err = pullObject(ctx, store, bucket, t)
+				} else {
+					err = store.client.put(ctx, bucket, t.file.Key, t.local, t.file.Size)
+				}
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", t.file.Key, err)
+					cancel()
+				}
+				done += t.file.Size
+				if progress != nil && err == nil {
+					progress(done, total)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, t := range plan {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- t
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// pullObject downloads an object next to its workspace path and moves it into place
+// when complete, so an interrupted sync leaves no partial files
+func pullObject(ctx context.Context, store *ObjectStore, bucket string, t syncTransfer) error {
+	if err := os.MkdirAll(filepath.Dir(t.local), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	part := t.local + syncPartSuffix
+	f, err := os.Create(part)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	err = store.client.get(ctx, bucket, t.file.Key, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(part, t.local)
+	}
+	if err != nil {
+		os.Remove(part)
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// previewMarker prefixes the JSON printed by previewScript
+const previewMarker = "__JUMPBOOT_PREVIEW__"
+
+// Limits of PreviewData
+const (
+	DefaultPreviewRows = 10
+	MaxPreviewRows     = 100
+	previewCellChars   = 200 // longer values are cut
+)
+
+// Formats of PreviewData
+const (
+	DataFormatAuto    = "auto"
+	DataFormatCSV     = "csv"
+	DataFormatTSV     = "tsv"
+	DataFormatParquet = "parquet"
+	DataFormatJSON    = "json"
+	DataFormatJSONL   = "jsonl"
+)
+
+// previewScript reads the head, schema and row count of a tabular file with pyarrow
+// (Parquet), pandas, or the standard library when pandas is not installed.
+// Arguments: path, format, rows, max cell characters.
+const previewScript = `
+import csv, json, os, sys
+path, fmt, rows, max_chars = sys.argv[1], sys.argv[2], int(sys.argv[3]), int(sys.argv[4])
+SAMPLE = 1000
+
+if fmt == 'auto':
+    name = path.lower()
+    for ext in ('.gz', '.bz2', '.xz', '.zst', '.zip'):
+        if name.endswith(ext):
+            name = name[:-len(ext)]
+    fmt = {'.csv': 'csv', '.tsv': 'tsv', '.tab': 'tsv', '.parquet': 'parquet', '.pq': 'parquet',
+           '.json': 'json', '.jsonl': 'jsonl', '.ndjson': 'jsonl'}.get(os.path.splitext(name)[1])
+    if fmt is None:
+        raise SystemExit('cannot tell the format of ' + path + ' from its extension; pass format')
+
+def cell(v):
+    if hasattr(v, 'item') and type(v).__module__ == 'numpy':
+        v = v.item()
+    if v is None or (isinstance(v, float) and v != v):
+        return None
+    if not isinstance(v, (bool, int, float, str)):
+        v = str(v)
+    if isinstance(v, str) and len(v) > max_chars:
+        v = v[:max_chars] + '...'
+    return v
+
+result = {'format': fmt}
+try:
+    import pandas as pd
+except ImportError:
+    pd = None
+
+if fmt == 'parquet':
+    try:
+        import pyarrow.parquet as pq
+    except ImportError:
+        raise SystemExit('reading Parquet needs pyarrow; install it with install_packages')
+    f = pq.ParquetFile(path)
+    result['engine'] = 'pyarrow'
+    result['row_count'] = f.metadata.num_rows
+    result['columns'] = [{'name': fld.name, 'dtype': str(fld.type)} for fld in f.schema_arrow]
+    batch = next(f.iter_batches(batch_size=rows), None) if rows > 0 else None
+    head = batch.to_pylist() if batch is not None else []
+    result['rows'] = [[cell(r.get(c['name'])) for c in result['columns']] for r in head[:rows]]
+elif pd is not None:
+    result['engine'] = 'pandas'
+    if fmt in ('csv', 'tsv'):
+        sep = ',' if fmt == 'csv' else '\t'
+        df = pd.read_csv(path, sep=sep, nrows=max(rows, SAMPLE))
+        count = sum(len(chunk) for chunk in pd.read_csv(path, sep=sep, usecols=[0], chunksize=200000))
+    elif fmt == 'jsonl':
+        df = pd.read_json(path, lines=True, nrows=max(rows, SAMPLE))
+        count = sum(len(chunk) for chunk in pd.read_json(path, lines=True, chunksize=200000))
+    else:
+        df = pd.read_json(path)
+        count = len(df)
+    result['row_count'] = count
+    result['columns'] = [{'name': str(c), 'dtype': str(t)} for c, t in df.dtypes.items()]
+    result['rows'] = [[cell(v) for v in row] for row in df.head(rows).itertuples(index=False, name=None)]
+else:
+    result['engine'] = 'python'
+    if fmt in ('csv', 'tsv'):
+        with open(path, newline='') as f:
+            reader = csv.reader(f, delimiter=',' if fmt == 'csv' else '\t')
+            header = next(reader, [])
+            head, count = [], 0
+            for row in reader:
+                if count < rows:
+                    head.append(row)
+                count += 1
+        result['columns'] = [{'name': h, 'dtype': 'str'} for h in header]
+        result['rows'] = [[cell(v) for v in row] for row in head]
+    else:
+        with open(path) as f:
+            if fmt == 'jsonl':
+                records = [json.loads(line) for line in f if line.strip()]
+            else:
+                records = json.load(f)
+        if isinstance(records, dict):
+            records = [records]
+        names = []
+        for r in records[:SAMPLE]:
+            for k in (r if isinstance(r, dict) else {'value': r}):
+                if k not in names:
+                    names.append(k)
+        def value(r, k):
+            return r.get(k) if isinstance(r, dict) else (r if k == 'value' else None)
+        def dtype(k):
+            kinds = {type(value(r, k)).__name__ for r in records[:SAMPLE] if value(r, k) is not None}
+            return kinds.pop() if len(kinds) == 1 else 'object'
+        count = len(records)
+        result['columns'] = [{'name': k, 'dtype': dtype(k)} for k in names]
+        result['rows'] = [[cell(value(r, k)) for k in names] for r in records[:rows]]
+    result['row_count'] = count
+
+print(%q + json.dumps(result, default=str))
+`
+
+// DataColumn is a column of a previewed file
+type DataColumn struct {
+	Name  string `json:"name"`
+	DType string `json:"dtype"`
+}
+
+// DataPreview is the head and schema of a tabular workspace file
+type DataPreview struct {
+	Path     string       `json:"path"`
+	Format   string       `json:"format"`
+	Engine   string       `json:"engine"` // pyarrow, pandas, or python without pandas
+	RowCount int64        `json:"row_count"`
+	Columns  []DataColumn `json:"columns"`
+	Rows     [][]any      `json:"rows"` // the first rows, one value per column
+}
+
+// PreviewData returns the column names and types, row count and first rows of a
+// CSV, TSV, Parquet, JSON or JSON Lines file in the workspace, read with the
+// environment's pyarrow and pandas. Without pandas, CSV and JSON are read with the
+// standard library and CSV columns have dtype str. Types are inferred from the first
+// 1000 rows for CSV and JSON Lines. Nothing is installed.
+func (m *Manager) PreviewData(ctx context.Context, envID, path, format string, rows int) (*DataPreview, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	switch format {
+	case "":
+		format = DataFormatAuto
+	case DataFormatAuto, DataFormatCSV, DataFormatTSV, DataFormatParquet, DataFormatJSON, DataFormatJSONL:
+	default:
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("unknown format %q (use auto, csv, tsv, parquet, json or jsonl)", format))
+	}
+	if rows <= 0 {
+		rows = DefaultPreviewRows
+	}
+	rows = min(rows, MaxPreviewRows)
+	file, err := safeJoinPath(env.WorkspaceDir, path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("file not found: %s", path))
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	output, err := runPython(ctx, env, "-c", fmt.Sprintf(previewScript, previewMarker), file, format,
+		strconv.Itoa(rows), strconv.Itoa(previewCellChars))
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to preview %s: %s", path, lastLine(output))
+	}
+
+	var preview DataPreview
+	if err := decodeMarkedJSON(output, previewMarker, &preview); err != nil {
+		return nil, fmt.Errorf("failed to preview %s: %w", path, err)
+	}
+	preview.Path = path
+	return &preview, nil
+}
@@ -0,0 +1,195 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Process log files
+const (
+	ProcessLogDir             = "logs"   // directory under the workspace holding process logs
+	ProcessLogMaxBytes  int64 = 10 << 20 // size at which a log file is rotated
+	ProcessLogBackups         = 3        // rotated files kept as <log>.1 ... <log>.N
+	DefaultLogTailLines       = 100
+)
+
+// logNameUnsafe matches characters replaced in log file names
+var logNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ProcessLogs is the tail of a process log file
+type ProcessLogs struct {
+	ProcessID string `json:"process_id"`
+	LogFile   string `json:"log_file"` // relative to the workspace
+	Output    string `json:"output"`
+	Lines     int    `json:"lines"`
+	Running   bool   `json:"running"`
+}
+
+// rotatingLog is an io.Writer appending to a file that is rotated once it reaches
+// maxBytes, keeping the previous backups files
+type rotatingLog struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxBytes int64
+	backups  int
+}
+
+// openRotatingLog opens path for appending, creating its directory
+func openRotatingLog(path string, maxBytes int64, backups int) (*rotatingLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	l := &rotatingLog{path: path, maxBytes: maxBytes, backups: backups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the current log file and records its size
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	l.file, l.size = f, fi.Size()
+	return nil
+}
+
+// Write appends p, rotating first if it would push the file past maxBytes
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.size > 0 && l.size+int64(len(p)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts <log>.N-1 to <log>.N and so on, moves the current file to <log>.1
+// and starts a new one. Callers must hold l.mu.
+func (l *rotatingLog) rotate() error {
+	l.file.Close()
+	l.file = nil
+	if l.backups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", l.path, l.backups))
+		for i := l.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		}
+		os.Rename(l.path, l.path+".1")
+	} else {
+		os.Remove(l.path)
+	}
+	return l.open()
+}
+
+// Close closes the current file; later writes fail
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// processLogPath returns the workspace-relative log file of a process
+func processLogPath(name, id string) string {
+	base := strings.Trim(logNameUnsafe.ReplaceAllString(name, "_"), "_.")
+	if base == "" {
+		base = "process"
+	}
+	return filepath.Join(ProcessLogDir, fmt.Sprintf("%s-%s.log", base, id[:8]))
+}
+
+// GetProcessLogs returns the last lines of a process log file, reading rotated files
+// as needed. It works whether or not output capture is enabled.
+func (m *Manager) GetProcessLogs(processID string, lines int) (*ProcessLogs, error) {
+	m.mu.RLock()
+	proc, ok := m.spawnedProcesses[processID]
+	var workspace string
+	if ok {
+		if env, found := m.environments[proc.EnvID]; found {
+			workspace = env.WorkspaceDir
+		}
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, notFound("process", processID)
+	}
+	if proc.LogFile == "" {
+		return nil, fmt.Errorf("process was not started with log_file: %s", processID)
+	}
+	if workspace == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", proc.EnvID)
+	}
+	if lines <= 0 {
+		lines = DefaultLogTailLines
+	}
+
+	tail, err := tailLogFiles(filepath.Join(workspace, proc.LogFile), ProcessLogBackups, lines)
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessLogs{
+		ProcessID: processID,
+		LogFile:   filepath.ToSlash(proc.LogFile),
+		Output:    strings.Join(tail, "\n"),
+		Lines:     len(tail),
+		Running:   proc.info().Running,
+	}, nil
+}
+
+// tailLogFiles returns the last n lines of a rotating log, reading the current file
+// and then the backups from newest to oldest until it has enough
+func tailLogFiles(path string, backups, n int) ([]string, error) {
+	var result []string
+	for i := 0; i <= backups && len(result) < n; i++ {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		data, err := os.ReadFile(name)
+		if os.IsNotExist(err) {
+			if i == 0 {
+				continue
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log file: %w", err)
+		}
+		text := strings.TrimSuffix(string(data), "\n")
+		if text == "" {
+			continue
+		}
+		result = append(strings.Split(text, "\n"), result...)
+	}
+	if len(result) > n {
+		result = result[len(result)-n:]
+	}
+	return result, nil
+}
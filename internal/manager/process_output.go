@@ -0,0 +1,189 @@
+package manager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Defaults for the in-memory output buffer of a process
+const (
+	DefaultOutputMaxLines = 1000
+	DefaultOutputMaxBytes = 1 << 20
+)
+
+// processOutputDir is the directory under the environment root holding spill files
+const processOutputDir = "process-output"
+
+// ProcessOutput is a range of captured process output. Lines are numbered from 0,
+// the first line the process wrote.
+type ProcessOutput struct {
+	ProcessID  string   `json:"process_id"`
+	Output     string   `json:"output"`
+	Lines      int      `json:"lines"`
+	Offset     int      `json:"offset"`      // number of the first returned line
+	NextOffset int      `json:"next_offset"` // offset to request the following lines
+	FirstLine  int      `json:"first_line"`  // oldest line still held in memory
+	TotalLines int      `json:"total_lines"` // lines captured so far
+	FromSpill  bool     `json:"from_spill,omitempty"`
+	Truncated  bool     `json:"truncated,omitempty"` // some requested lines were dropped
+	Running    bool     `json:"running"`
+	lines      []string // the returned lines
+}
+
+// SetOutputLimits sets how much captured output each process keeps in memory unless
+// it is spawned with its own limits (0 = built-in default)
+func (m *Manager) SetOutputLimits(maxLines, maxBytes int) {
+	if maxLines <= 0 {
+		maxLines = DefaultOutputMaxLines
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultOutputMaxBytes
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outputMaxLines = maxLines
+	m.outputMaxBytes = maxBytes
+}
+
+// appendOutput adds a captured line, dropping the oldest lines beyond the line and
+// byte limits. The newest line is always kept.
+func (p *ManagedProcess) appendOutput(line string) {
+	p.outputMu.Lock()
+	defer p.outputMu.Unlock()
+
+	if p.spill != nil {
+		p.spill.WriteString(line + "\n")
+	}
+	p.outputLines = append(p.outputLines, line)
+	p.outputBytes += len(line) + 1
+
+	drop := 0
+	for drop < len(p.outputLines)-1 &&
+		(len(p.outputLines)-drop > p.maxLines || p.outputBytes > p.maxBytes) {
+		p.outputBytes -= len(p.outputLines[drop]) + 1
+		drop++
+	}
+	if drop > 0 {
+		// Copy so the dropped lines can be garbage collected
+		p.outputLines = append([]string(nil), p.outputLines[drop:]...)
+		p.firstLine += drop
+	}
+}
+
+// openSpill creates the spill file that receives every captured line
+func (p *ManagedProcess) openSpill(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output spill file: %w", err)
+	}
+	p.spill = f
+	p.SpillFile = path
+	return nil
+}
+
+// closeSpill closes the spill file, if any
+func (p *ManagedProcess) closeSpill() {
+	p.outputMu.Lock()
+	defer p.outputMu.Unlock()
+	if p.spill != nil {
+		p.spill.Close()
+		p.spill = nil
+	}
+}
+
+// GetProcessOutputRange returns up to limit captured lines starting at line offset
+// (all remaining lines when limit <= 0). Lines that were dropped from memory are read
+// from the spill file if the process has one; otherwise the result starts at the
+// oldest line still held and is marked truncated.
+func (m *Manager) GetProcessOutputRange(processID string, offset, limit int) (*ProcessOutput, error) {
+	m.mu.RLock()
+	proc, ok := m.spawnedProcesses[processID]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, notFound("process", processID)
+	}
+	if !proc.CaptureOutput {
+		return nil, fmt.Errorf("output capture not enabled for process: %s", processID)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+
+	proc.outputMu.RLock()
+	result := &ProcessOutput{
+		ProcessID:  processID,
+		FirstLine:  proc.firstLine,
+		TotalLines: proc.firstLine + len(proc.outputLines),
+		Running:    !proc.exited,
+	}
+	spillPath := proc.SpillFile
+	if offset >= proc.firstLine {
+		start := min(offset-proc.firstLine, len(proc.outputLines))
+		end := len(proc.outputLines)
+		if limit > 0 {
+			end = min(end, start+limit)
+		}
+		result.lines = append([]string(nil), proc.outputLines[start:end]...)
+		result.Offset = proc.firstLine + start
+	}
+	proc.outputMu.RUnlock()
+
+	if offset < result.FirstLine {
+		if spillPath != "" {
+			lines, err := readSpillLines(spillPath, offset, limit)
+			if err != nil {
+				return nil, err
+			}
+			result.lines, result.Offset, result.FromSpill = lines, offset, true
+		} else {
+			// Fall back to what is still in memory
+			fallback, err := m.GetProcessOutputRange(processID, result.FirstLine, limit)
+			if err != nil {
+				return nil, err
+			}
+			fallback.Truncated = true
+			return fallback, nil
+		}
+	}
+
+	result.Output = strings.Join(result.lines, "\n")
+	result.Lines = len(result.lines)
+	result.NextOffset = result.Offset + result.Lines
+	return result, nil
+}
+
+// readSpillLines reads up to limit lines (all when limit <= 0) starting at line
+// offset of a spill file
+func readSpillLines(path string, offset, limit int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output spill file: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	r := bufio.NewReader(f)
+	for n := 0; limit <= 0 || len(lines) < limit; n++ {
+		line, err := r.ReadString('\n')
+		// A line without its newline is still being written
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output spill file: %w", err)
+		}
+		if n >= offset {
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package manager
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes cmd, which has not been started, lead a new process group,
+// so that its tree can be killed and signals sent to the server do not reach it
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// processTree is a started process and the processes it starts. On Unix they share
+// the process group it leads (see setProcessGroup, or a session started with Setsid).
+type processTree struct {
+	process *os.Process
+}
+
+// newProcessTree tracks the tree of a started process
+func newProcessTree(p *os.Process) *processTree {
+	return &processTree{process: p}
+}
+
+// kill kills the process and its descendants
+func (t *processTree) kill() error {
+	// The group leader's PID is the group ID
+	if err := syscall.Kill(-t.process.Pid, syscall.SIGKILL); err != nil {
+		return t.process.Kill()
+	}
+	return nil
+}
+
+// release frees what tracking the tree holds once the process has exited
+func (t *processTree) release() {}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// setProcessGroup makes cmd, which has not been started, start a new process group,
+// so that console Ctrl+C events sent to the server do not reach it
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_NEW_PROCESS_GROUP
+}
+
+// processTree is a started process and the processes it starts. On Windows they are
+// tracked with a job object, which children join when they are created.
+type processTree struct {
+	process *os.Process
+
+	mu  sync.Mutex     // protects job
+	job windows.Handle // 0 if the process could not be assigned to a job
+}
+
+// newProcessTree assigns a started process to a new job object. Without one (e.g. the
+// server's own job forbids nesting on old Windows versions), kill falls back to taskkill.
+func newProcessTree(p *os.Process) *processTree {
+	t := &processTree{process: p}
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return t
+	}
+	handle, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return t
+	}
+	defer windows.CloseHandle(handle)
+	if err := windows.AssignProcessToJobObject(job, handle); err != nil {
+		windows.CloseHandle(job)
+		return t
+	}
+	t.job = job
+	return t
+}
+
+// kill kills the process and its descendants
+func (t *processTree) kill() error {
+	t.mu.Lock()
+	job := t.job
+	if job != 0 && windows.TerminateJobObject(job, 1) == nil {
+		t.mu.Unlock()
+		return nil
+	}
+	t.mu.Unlock()
+
+	// taskkill finds the descendants through their parent process IDs instead
+	if exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(t.process.Pid)).Run() == nil {
+		return nil
+	}
+	return t.process.Kill()
+}
+
+// release closes the job object once the process has exited. Descendants still
+// running are left alone, as on Unix.
+func (t *processTree) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.job != 0 {
+		windows.CloseHandle(t.job)
+		t.job = 0
+	}
+}
@@ -0,0 +1,534 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profilers of a ProfileResult
+const (
+	ProfilerCProfile = "cprofile"
+	ProfilerPySpy    = "py-spy"
+)
+
+// Orders of the hotspots of a profile
+const (
+	ProfileSortSelf  = "self"  // time spent in the function itself
+	ProfileSortTotal = "total" // time including the functions it calls
+)
+
+// Profiling limits
+const (
+	DefaultProfileTop      = 20
+	MaxProfileTop          = 500
+	DefaultProfileDuration = 10 * time.Second
+	MaxProfileDuration     = 10 * time.Minute
+	pySpySampleRate        = 100 // samples per second
+)
+
+// flamegraphFile is the name of the flamegraph SVG in a profile's artifacts directory
+const flamegraphFile = "flamegraph.svg"
+
+// profileDriverScript runs a script under cProfile the way "python -m cProfile" does
+// and, even when the script fails, writes the functions with the most time as JSON
+// and, if a path is given, the call tree as folded stacks. cProfile only records call
+// edges, so a function's time is split between its callers in proportion to the time
+// each caller spent in it. Arguments: the JSON file, the folded stacks file or "",
+// the number of functions, the sort order, then the script and its arguments.
+const profileDriverScript = `
+import cProfile, json, os, pstats, sys
+
+stats_path, folded_path, top, sort_key, script = sys.argv[1], sys.argv[2], int(sys.argv[3]), sys.argv[4], sys.argv[5]
+sys.argv = sys.argv[5:]
+sys.path[0] = os.path.dirname(script)
+with open(script, 'rb') as f:
+    code = compile(f.read(), script, 'exec')
+globs = {'__name__': '__main__', '__file__': script, '__builtins__': __builtins__}
+
+# The exec call that runs the script
+EXEC = ('~', 0, '<built-in method builtins.exec>')
+MAIN = (script, code.co_firstlineno, code.co_name)
+
+def label(func):
+    if func[0] == '~':
+        return func[2]
+    return '%s (%s:%d)' % (func[2], func[0], func[1])
+
+def report(prof):
+    stats = dict(pstats.Stats(prof).stats)
+    skip = {f for f in stats if f[0] == '~' and "'_lsprof.Profiler'" in f[2]}
+    # Take the call that runs the script out of exec, which imports call too, and
+    # make the script the root
+    if MAIN in stats and EXEC in stats[MAIN][4]:
+        cc, nc, tt, ct, callers = stats[MAIN]
+        run = callers.pop(EXEC)
+        cc, nc, tt, ct, callers = stats[EXEC]
+        if nc <= run[1]:
+            skip.add(EXEC)
+        else:
+            stats[EXEC] = (cc - run[0], nc - run[1], tt, ct - run[3], callers)
+
+    functions, total = [], 0.0
+    for func, (cc, nc, tt, ct, callers) in stats.items():
+        if func in skip:
+            continue
+        total += tt
+        functions.append({'function': func[2], 'file': '' if func[0] == '~' else func[0], 'line': func[1],
+                          'calls': nc, 'self_seconds': round(tt, 6), 'total_seconds': round(ct, 6)})
+    key = 'self_seconds' if sort_key == 'self' else 'total_seconds'
+    functions.sort(key=lambda f: (-f[key], f['function']))
+    with open(stats_path, 'w') as f:
+        json.dump({'total_seconds': round(total, 6), 'functions': functions[:top]}, f)
+
+    if not folded_path:
+        return
+    children = {}
+    for func, (cc, nc, tt, ct, callers) in stats.items():
+        for caller, edge in callers.items():
+            children.setdefault(caller, {})[func] = edge[3]
+    roots = {f: s[3] for f, s in stats.items() if not s[4] and f not in skip}
+    # Branches under 0.1% of the time would be less than a pixel wide
+    threshold = total / 1000
+    lines = []
+
+    def walk(func, path, labels, seconds):
+        ct = stats[func][3]
+        scale = seconds / ct if ct > 0 else 0
+        rest = seconds
+        if len(path) < 64:
+            for child, edge in children.get(func, {}).items():
+                share = edge * scale
+                if child in path or child in skip or share < threshold:
+                    continue
+                rest -= share
+                walk(child, path | {child}, labels + [label(child)], share)
+        if rest >= 1e-6:
+            lines.append('%s %d' % (';'.join(labels), round(rest * 1e6)))
+
+    for func, seconds in roots.items():
+        if seconds >= threshold:
+            walk(func, {func}, [label(func)], seconds)
+    with open(folded_path, 'w') as f:
+        f.write('\n'.join(lines))
+
+prof = cProfile.Profile()
+try:
+    prof.runctx(code, globs, None)
+finally:
+    report(prof)
+`
+
+// ProfileOptions configures ProfileScript and ProfileProcess
+type ProfileOptions struct {
+	Args       []string      // script arguments (ProfileScript)
+	Duration   time.Duration // how long to sample (ProfileProcess); 0 for DefaultProfileDuration
+	Top        int           // number of hotspots; 0 for DefaultProfileTop
+	Sort       string        // ProfileSortSelf (default) or ProfileSortTotal
+	Flamegraph bool          // save a flamegraph SVG in the workspace
+}
+
+// validate checks the options and fills in the defaults
+func (o *ProfileOptions) validate() error {
+	switch o.Sort {
+	case "":
+		o.Sort = ProfileSortSelf
+	case ProfileSortSelf, ProfileSortTotal:
+	default:
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid sort %q (use %s or %s)", o.Sort, ProfileSortSelf, ProfileSortTotal))
+	}
+	if o.Top <= 0 {
+		o.Top = DefaultProfileTop
+	}
+	if o.Top > MaxProfileTop {
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("top must be at most %d", MaxProfileTop))
+	}
+	if o.Duration <= 0 {
+		o.Duration = DefaultProfileDuration
+	}
+	if o.Duration > MaxProfileDuration {
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("duration must be at most %s", MaxProfileDuration))
+	}
+	return nil
+}
+
+// ProfileHotspot is a function and the time spent in it. Line is the line the
+// function starts on; built-in functions have no file.
+type ProfileHotspot struct {
+	Function     string  `json:"function"`
+	File         string  `json:"file,omitempty"` // relative to the workspace when inside it
+	Line         int     `json:"line,omitempty"`
+	Calls        int     `json:"calls,omitempty"` // cProfile only
+	SelfSeconds  float64 `json:"self_seconds"`
+	TotalSeconds float64 `json:"total_seconds"`
+	SelfPercent  float64 `json:"self_percent"`
+	TotalPercent float64 `json:"total_percent"`
+}
+
+// ProfileResult is the outcome of profiling a script or process
+type ProfileResult struct {
+	Profiler     string           `json:"profiler"`
+	Script       string           `json:"script,omitempty"`     // relative to the workspace
+	ProcessID    string           `json:"process_id,omitempty"` // the spawned process sampled by py-spy
+	TotalSeconds float64          `json:"total_seconds"`        // time the percentages are shares of
+	Samples      int64            `json:"samples,omitempty"`    // py-spy only
+	Hotspots     []ProfileHotspot `json:"hotspots"`
+	Flamegraph   string           `json:"flamegraph,omitempty"` // SVG, relative to the workspace
+	Artifacts    []Artifact       `json:"artifacts,omitempty"`
+	ArtifactsDir string           `json:"artifacts_dir,omitempty"`
+	Output       string           `json:"output,omitempty"`    // of the profiled script
+	ExitCode     int              `json:"exit_code,omitempty"` // of the profiled script
+	Installed    bool             `json:"installed,omitempty"` // py-spy was installed on demand
+}
+
+// percentOf returns part as a percentage of total, rounded to 0.01
+func percentOf(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(10000*part/total) / 100
+}
+
+// shortFrames returns stacks with the files of their frames made relative to the
+// workspace, or reduced to their base names outside it, for a readable flamegraph
+func shortFrames(env *ManagedEnvironment, stacks []foldedStack) []foldedStack {
+	short := make(map[string]string)
+	for i, s := range stacks {
+		frames := make([]string, len(s.frames))
+		for j, frame := range s.frames {
+			label, ok := short[frame]
+			if !ok {
+				label = frame
+				if function, file, line, ok := parseFrame(frame); ok {
+					if rel := workspaceRelative(env.WorkspaceDir, file); rel != file {
+						file = rel
+					} else {
+						file = filepath.Base(file)
+					}
+					label = function + " (" + file + ")"
+					if line > 0 {
+						label = fmt.Sprintf("%s (%s:%d)", function, file, line)
+					}
+				}
+				short[frame] = label
+			}
+			frames[j] = label
+		}
+		stacks[i].frames = frames
+	}
+	return stacks
+}
+
+// saveFlamegraph renders stacks into the artifacts directory and records the SVG,
+// with everything else saved there, in result
+func saveFlamegraph(env *ManagedEnvironment, dir, title string, stacks []foldedStack, unit string, result *ProfileResult) error {
+	svg := renderFlamegraph(title, shortFrames(env, stacks), unit)
+	path := filepath.Join(dir, flamegraphFile)
+	if err := os.WriteFile(path, svg, 0644); err != nil {
+		return fmt.Errorf("failed to write flamegraph: %w", err)
+	}
+	result.Flamegraph = workspaceRelative(env.WorkspaceDir, path)
+	return nil
+}
+
+// ProfileScript runs a workspace script under cProfile and returns the opts.Top
+// functions with the most time, in the order opts.Sort. cProfile measures wall time
+// and only the main thread. The script's output and exit code are part of the result;
+// a script that fails is still profiled up to the failure. With opts.Flamegraph an SVG
+// of the call tree, approximated from the call graph cProfile records, is saved with
+// the execution's artifacts.
+func (m *Manager) ProfileScript(ctx context.Context, envID, filename string, opts ProfileOptions) (*ProfileResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	scriptPath, err := safeJoinPath(env.WorkspaceDir, filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("script not found: %s", filename))
+	}
+	if opts.Flamegraph {
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	tmpDir, err := os.MkdirTemp("", "profile-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	statsPath := filepath.Join(tmpDir, "stats.json")
+	var foldedPath string
+	if opts.Flamegraph {
+		foldedPath = filepath.Join(tmpDir, "stacks.folded")
+	}
+
+	artifacts, err := m.newArtifactsDir(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	args := append([]string{"-c", profileDriverScript, statsPath, foldedPath, strconv.Itoa(opts.Top), opts.Sort, scriptPath}, opts.Args...)
+	cmd := commandContext(ctx, env.Env.PythonPath, args...)
+	cmd.Dir = env.WorkspaceDir
+	cmd.Env = env.appendVars(os.Environ())
+	if artifacts != "" {
+		cmd.Env = append(cmd.Env, artifactsEnv+"="+artifacts)
+	}
+	output, runErr := m.runIsolated(ctx, env, cmd, sandboxMount{path: tmpDir, writable: true})
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		collectArtifacts(env, artifacts)
+		return nil, fmt.Errorf("execution failed: %w\nOutput: %s", runErr, output)
+	}
+
+	var stats struct {
+		TotalSeconds float64          `json:"total_seconds"`
+		Functions    []ProfileHotspot `json:"functions"`
+	}
+	data, err := os.ReadFile(statsPath)
+	if err == nil {
+		err = json.Unmarshal(data, &stats)
+	}
+	if err != nil {
+		// The script did not compile, or the profiler itself failed
+		collectArtifacts(env, artifacts)
+		if runErr != nil {
+			return nil, fmt.Errorf("execution failed: %w\nOutput: %s", runErr, output)
+		}
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+
+	result := &ProfileResult{
+		Profiler:     ProfilerCProfile,
+		Script:       workspaceRelative(env.WorkspaceDir, scriptPath),
+		TotalSeconds: stats.TotalSeconds,
+		Hotspots:     stats.Functions,
+		Output:       output,
+	}
+	if exitErr != nil {
+		result.ExitCode = exitErr.ExitCode()
+	}
+	if result.Hotspots == nil {
+		result.Hotspots = []ProfileHotspot{}
+	}
+	for i := range result.Hotspots {
+		h := &result.Hotspots[i]
+		if h.File != "" {
+			h.File = workspaceRelative(env.WorkspaceDir, h.File)
+		}
+		h.SelfPercent = percentOf(h.SelfSeconds, stats.TotalSeconds)
+		h.TotalPercent = percentOf(h.TotalSeconds, stats.TotalSeconds)
+	}
+	if opts.Flamegraph {
+		folded, err := os.ReadFile(foldedPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read call tree: %w", err)
+		}
+		title := "cProfile: " + result.Script + " (call tree approximated from the call graph)"
+		if err := saveFlamegraph(env, artifacts, title, parseFolded(string(folded)), "µs", result); err != nil {
+			return nil, err
+		}
+	}
+	result.Artifacts = collectArtifacts(env, artifacts)
+	if len(result.Artifacts) > 0 {
+		result.ArtifactsDir = workspaceRelative(env.WorkspaceDir, artifacts)
+	}
+	return result, nil
+}
+
+// pySpyFor returns the py-spy executable of env, installing py-spy if it is missing
+func (m *Manager) pySpyFor(ctx context.Context, env *ManagedEnvironment) (path string, installed bool, err error) {
+	find := func() string {
+		for _, dir := range envBinDirs(env) {
+			if p, err := exec.LookPath(filepath.Join(dir, "py-spy")); err == nil {
+				return p
+			}
+		}
+		return ""
+	}
+	if path = find(); path != "" {
+		return path, false, nil
+	}
+	if err := m.InstallPackages(ctx, env.ID, []string{"py-spy"}, false); err != nil {
+		return "", false, fmt.Errorf("failed to install py-spy: %w", err)
+	}
+	if path = find(); path == "" {
+		return "", false, errors.New("py-spy was installed but its executable was not found")
+	}
+	return path, true, nil
+}
+
+// ProfileProcess samples a running spawned process, and the processes it started,
+// with py-spy for opts.Duration or until it exits, and returns the opts.Top functions
+// that were on the CPU most, in the order opts.Sort. Idle threads are not counted.
+// py-spy is installed into the process's environment if needed; attaching needs
+// ptrace permission (CAP_SYS_PTRACE, or kernel.yama.ptrace_scope=0 on Linux).
+func (m *Manager) ProfileProcess(ctx context.Context, processID string, opts ProfileOptions) (*ProfileResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	proc, ok := m.spawnedProcesses[processID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, notFound("process", processID)
+	}
+	info := proc.info()
+	if !info.Running {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("process %s has exited", processID))
+	}
+	env, err := m.GetEnvironment(proc.EnvID)
+	if err != nil {
+		return nil, err
+	}
+	if env.isolated() {
+		return nil, fmt.Errorf("py-spy cannot attach to processes of isolated environments: %w", errors.ErrUnsupported)
+	}
+	if opts.Flamegraph {
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	pySpy, installed, err := m.pySpyFor(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "profile-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	rawPath := filepath.Join(tmpDir, "stacks.folded")
+
+	// --function reports the line a function starts on, like cProfile
+	seconds := int(math.Ceil(opts.Duration.Seconds()))
+	output, err := runCommand(ctx, commandContext(ctx, pySpy, "record",
+		"--pid", strconv.Itoa(info.PID),
+		"--duration", strconv.Itoa(seconds),
+		"--rate", strconv.Itoa(pySpySampleRate),
+		"--format", "raw", "--output", rawPath,
+		"--function", "--full-filenames", "--subprocesses", "--nonblocking"))
+	if err != nil {
+		if errors.Is(err, ErrCancelled) {
+			return nil, err
+		}
+		return nil, WithErrorCode(CodeExecFailed, fmt.Errorf("py-spy failed: %w\nOutput: %s", err, strings.TrimSpace(output)))
+	}
+	raw, err := os.ReadFile(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read py-spy output: %w", err)
+	}
+	stacks := parseFolded(string(raw))
+
+	result := &ProfileResult{
+		Profiler:  ProfilerPySpy,
+		ProcessID: processID,
+		Hotspots:  foldedHotspots(env, stacks, opts.Sort, opts.Top),
+		Installed: installed,
+	}
+	for _, s := range stacks {
+		result.Samples += s.weight
+	}
+	result.TotalSeconds = float64(result.Samples) / pySpySampleRate
+	if opts.Flamegraph {
+		artifacts, err := m.newArtifactsDir(env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create artifacts directory: %w", err)
+		}
+		title := fmt.Sprintf("py-spy: %s (%d samples at %d Hz)", proc.Name, result.Samples, pySpySampleRate)
+		if err := saveFlamegraph(env, artifacts, title, stacks, "samples", result); err != nil {
+			return nil, err
+		}
+		result.Artifacts = collectArtifacts(env, artifacts)
+		result.ArtifactsDir = workspaceRelative(env.WorkspaceDir, artifacts)
+	}
+	return result, nil
+}
+
+// foldedHotspots adds up the samples of each function in stacks: its self samples are
+// those where it is the innermost frame, its total samples those where it is anywhere
+func foldedHotspots(env *ManagedEnvironment, stacks []foldedStack, sortBy string, top int) []ProfileHotspot {
+	type counts struct {
+		hotspot     ProfileHotspot
+		self, total int64
+	}
+	functions := make(map[string]*counts)
+	var samples int64
+	for _, s := range stacks {
+		samples += s.weight
+		seen := make(map[string]bool, len(s.frames))
+		for i, frame := range s.frames {
+			c := functions[frame]
+			if c == nil {
+				function, file, line, ok := parseFrame(frame)
+				if !ok {
+					continue
+				}
+				c = &counts{hotspot: ProfileHotspot{Function: function, File: workspaceRelative(env.WorkspaceDir, file), Line: line}}
+				functions[frame] = c
+			}
+			if i == len(s.frames)-1 {
+				c.self += s.weight
+			}
+			// Recursive functions count once per sample
+			if !seen[frame] {
+				seen[frame] = true
+				c.total += s.weight
+			}
+		}
+	}
+
+	hotspots := make([]ProfileHotspot, 0, len(functions))
+	for _, c := range functions {
+		h := c.hotspot
+		h.SelfSeconds = float64(c.self) / pySpySampleRate
+		h.TotalSeconds = float64(c.total) / pySpySampleRate
+		h.SelfPercent = percentOf(float64(c.self), float64(samples))
+		h.TotalPercent = percentOf(float64(c.total), float64(samples))
+		hotspots = append(hotspots, h)
+	}
+	sort.Slice(hotspots, func(i, j int) bool {
+		a, b := hotspots[i], hotspots[j]
+		if sortBy == ProfileSortTotal && a.TotalSeconds != b.TotalSeconds {
+			return a.TotalSeconds > b.TotalSeconds
+		}
+		if a.SelfSeconds != b.SelfSeconds {
+			return a.SelfSeconds > b.SelfSeconds
+		}
+		if a.TotalSeconds != b.TotalSeconds {
+			return a.TotalSeconds > b.TotalSeconds
+		}
+		return a.Function < b.Function
+	})
+	if len(hotspots) > top {
+		hotspots = hotspots[:top]
+	}
+	return hotspots
+}
@@ -0,0 +1,316 @@
+package manager
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Project installation backends
+const (
+	ProjectBackendAuto   = "auto"
+	ProjectBackendPip    = "pip"    // pip install -e .
+	ProjectBackendPoetry = "poetry" // poetry install
+	ProjectBackendUV     = "uv"     // uv sync
+)
+
+// ProjectInstallOptions configures ProjectInstall
+type ProjectInstallOptions struct {
+	Path     string   // project directory in the workspace ("" = workspace root)
+	Backend  string   // ProjectBackendAuto (or empty) detects it
+	Extras   []string // optional dependency sets ([project.optional-dependencies] or poetry extras)
+	Groups   []string // dependency groups
+	Editable bool     // pip only: install in development mode
+}
+
+// ProjectInstallResult reports how a project was installed
+type ProjectInstallResult struct {
+	Path          string   `json:"path"`
+	Backend       string   `json:"backend"`
+	Command       []string `json:"command"`
+	Output        string   `json:"output"`
+	ToolInstalled bool     `json:"tool_installed,omitempty"` // poetry or uv was installed first
+}
+
+// ProjectInstall installs a Python project from the workspace into the environment with
+// the backend its files call for: uv sync for uv projects, poetry install for Poetry
+// projects and pip for everything else (pyproject.toml or setup.py)
+func (m *Manager) ProjectInstall(ctx context.Context, envID string, opts ProjectInstallOptions) (*ProjectInstallResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	path, dir := opts.Path, env.WorkspaceDir
+	if path == "" || filepath.Clean(path) == "." {
+		path = "."
+	} else if dir, err = safeJoinPath(env.WorkspaceDir, path); err != nil {
+		return nil, err
+	}
+
+	backend := opts.Backend
+	if backend == "" || backend == ProjectBackendAuto {
+		if backend, err = detectProjectBackend(dir); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var module string
+	var args []string
+	switch backend {
+	case ProjectBackendPip:
+		target := "."
+		if len(opts.Extras) > 0 {
+			target += "[" + strings.Join(opts.Extras, ",") + "]"
+		}
+		args = []string{"install", "--no-warn-script-location"}
+		if opts.Editable {
+			args = append(args, "-e")
+		}
+		args = append(args, target)
+		for _, group := range opts.Groups {
+			args = append(args, "--group", group)
+		}
+		module = "pip"
+	case ProjectBackendPoetry:
+		args = []string{"install", "--no-interaction"}
+		if len(opts.Extras) > 0 {
+			args = append(args, "--extras", strings.Join(opts.Extras, " "))
+		}
+		if len(opts.Groups) > 0 {
+			args = append(args, "--with", strings.Join(opts.Groups, ","))
+		}
+		module = "poetry"
+	case ProjectBackendUV:
+		// --inexact keeps packages installed outside the project
+		args = []string{"sync", "--inexact"}
+		for _, extra := range opts.Extras {
+			args = append(args, "--extra", extra)
+		}
+		for _, group := range opts.Groups {
+			args = append(args, "--group", group)
+		}
+		module = "uv"
+	default:
+		return nil, fmt.Errorf("unknown project backend: %s (use %s, %s, %s or %s)",
+			backend, ProjectBackendAuto, ProjectBackendPip, ProjectBackendPoetry, ProjectBackendUV)
+	}
+
+	result := &ProjectInstallResult{
+		Path:    path,
+		Backend: backend,
+		Command: append([]string{"python", "-m", module}, args...),
+	}
+	if module != "pip" {
+		if _, err := runPython(ctx, env, "-m", module, "--version"); err != nil {
+			if errors.Is(err, ErrCancelled) {
+				return nil, err
+			}
+			if err := m.InstallPackages(ctx, envID, []string{module}, false); err != nil {
+				return nil, fmt.Errorf("failed to install %s: %w", module, err)
+			}
+			result.ToolInstalled = true
+		}
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// poetry and uv install into the environment instead of a project virtualenv
+	cmd := commandContext(ctx, env.Env.PythonPath, append([]string{"-m", module}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(env.appendVars(os.Environ()),
+		pathVar(env),
+		"VIRTUAL_ENV="+env.Env.EnvPath,
+		"POETRY_VIRTUALENVS_CREATE=false",
+		"UV_PROJECT_ENVIRONMENT="+env.Env.EnvPath,
+	)
+	output, err := runCommand(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w\nOutput: %s", strings.Join(result.Command, " "), err, output)
+	}
+	result.Output = output
+	return result, nil
+}
+
+// detectProjectBackend chooses the backend for a project directory: a uv.lock or
+// [tool.uv] section means uv, a poetry.lock or [tool.poetry] section means Poetry
+func detectProjectBackend(dir string) (string, error) {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	if exists("uv.lock") {
+		return ProjectBackendUV, nil
+	}
+	if exists("poetry.lock") {
+		return ProjectBackendPoetry, nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, "pyproject.toml"))
+	if errors.Is(err, os.ErrNotExist) {
+		if exists("setup.py") || exists("setup.cfg") {
+			return ProjectBackendPip, nil
+		}
+		return "", fmt.Errorf("no pyproject.toml or setup.py found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read pyproject.toml: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "[tool.uv]") || strings.HasPrefix(line, "[tool.uv."):
+			return ProjectBackendUV, nil
+		case strings.HasPrefix(line, "[tool.poetry]") || strings.HasPrefix(line, "[tool.poetry."):
+			return ProjectBackendPoetry, nil
+		}
+	}
+	return ProjectBackendPip, nil
+}
+
+// MaxBuildContentBytes limits the total size of artifacts returned inline by
+// BuildPackage
+const MaxBuildContentBytes = 50 << 20
+
+// BuildOptions configures BuildPackage
+type BuildOptions struct {
+	Path           string // project directory in the workspace ("" = workspace root)
+	OutDir         string // output directory in the workspace ("" = <Path>/dist)
+	Sdist          bool
+	Wheel          bool
+	NoIsolation    bool // build with the environment's packages instead of an isolated build environment
+	IncludeContent bool // return the artifacts base64-encoded
+}
+
+// BuildArtifact is a file produced by BuildPackage
+type BuildArtifact struct {
+	Path    string `json:"path"` // relative to the workspace
+	Size    int64  `json:"size"`
+	Content string `json:"content,omitempty"` // base64, with IncludeContent
+}
+
+// BuildResult reports the artifacts of a build
+type BuildResult struct {
+	Path           string          `json:"path"`
+	Artifacts      []BuildArtifact `json:"artifacts"`
+	Output         string          `json:"output"`
+	BuildInstalled bool            `json:"build_installed,omitempty"` // the build package was installed first
+}
+
+// BuildPackage builds wheels and/or sdists of a workspace project with python -m build.
+// Artifacts are the files in the output directory that the build created or replaced.
+func (m *Manager) BuildPackage(ctx context.Context, envID string, opts BuildOptions) (*BuildResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	path, dir := opts.Path, env.WorkspaceDir
+	if path == "" || filepath.Clean(path) == "." {
+		path = "."
+	} else if dir, err = safeJoinPath(env.WorkspaceDir, path); err != nil {
+		return nil, err
+	}
+	outDir := filepath.Join(dir, "dist")
+	if opts.OutDir != "" {
+		if outDir, err = safeJoinPath(env.WorkspaceDir, opts.OutDir); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkMountWritable(env, outDir); err != nil {
+		return nil, err
+	}
+
+	result := &BuildResult{Path: path, Artifacts: []BuildArtifact{}}
+	if _, err := runPython(ctx, env, "-m", "build", "--version"); err != nil {
+		if errors.Is(err, ErrCancelled) {
+			return nil, err
+		}
+		if err := m.InstallPackages(ctx, envID, []string{"build"}, false); err != nil {
+			return nil, fmt.Errorf("failed to install build: %w", err)
+		}
+		result.BuildInstalled = true
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	args := []string{"-m", "build", "--outdir", outDir}
+	if opts.Sdist {
+		args = append(args, "--sdist")
+	}
+	if opts.Wheel {
+		args = append(args, "--wheel")
+	}
+	if opts.NoIsolation {
+		args = append(args, "--no-isolation")
+	}
+	args = append(args, dir)
+
+	before := buildOutputs(outDir)
+	cmd := commandContext(ctx, env.Env.PythonPath, args...)
+	cmd.Dir = dir
+	cmd.Env = env.appendVars(os.Environ())
+	output, err := runCommand(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("build failed: %w\nOutput: %s", err, output)
+	}
+	result.Output = output
+
+	var total int64
+	for name, info := range buildOutputs(outDir) {
+		if old, ok := before[name]; ok && !info.ModTime().After(old.ModTime()) {
+			continue
+		}
+		full := filepath.Join(outDir, name)
+		artifact := BuildArtifact{Path: workspaceRelative(env.WorkspaceDir, full), Size: info.Size()}
+		if opts.IncludeContent {
+			if total += info.Size(); total > MaxBuildContentBytes {
+				return nil, fmt.Errorf("artifacts exceed %d MB; read them from the workspace instead", MaxBuildContentBytes>>20)
+			}
+			data, err := os.ReadFile(full)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read artifact: %w", err)
+			}
+			artifact.Content = base64.StdEncoding.EncodeToString(data)
+		}
+		result.Artifacts = append(result.Artifacts, artifact)
+	}
+	slices.SortFunc(result.Artifacts, func(a, b BuildArtifact) int { return strings.Compare(a.Path, b.Path) })
+	return result, nil
+}
+
+// buildOutputs returns the regular files in a build output directory
+func buildOutputs(dir string) map[string]os.FileInfo {
+	files := make(map[string]os.FileInfo)
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			files[entry.Name()] = info
+		}
+	}
+	return files
+}
@@ -0,0 +1,64 @@
+//go:build linux
+
+package manager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// startWithPTY starts cmd attached to a new pseudo-terminal and returns its master side.
+// The returned bool reports whether a real PTY is used.
+func startWithPTY(cmd *exec.Cmd, cols, rows int) (io.ReadWriteCloser, bool, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open pty: %w", err)
+	}
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, false, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, false, fmt.Errorf("failed to get pty number: %w", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, false, fmt.Errorf("failed to open pty slave: %w", err)
+	}
+	defer slave.Close()
+
+	unix.IoctlSetWinsize(int(slave.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Col: uint16(cols), Row: uint16(rows)})
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	// As a session leader the shell also leads a process group, which processTree kills
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, false, err
+	}
+	return master, true, nil
+}
+
+// resizePTY sets the window size of the PTY whose master side startWithPTY returned.
+// The kernel sends SIGWINCH to the terminal's foreground process group.
+func resizePTY(tty io.ReadWriteCloser, cols, rows int) error {
+	master, ok := tty.(*os.File)
+	if !ok {
+		return fmt.Errorf("not a pty")
+	}
+	return unix.IoctlSetWinsize(int(master.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Col: uint16(cols), Row: uint16(rows)})
+}
@@ -0,0 +1,61 @@
+//go:build !linux
+
+package manager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// pipeTerminal joins the write end of the shell's stdin and the read end of its output
+type pipeTerminal struct {
+	in  *os.File
+	out *os.File
+}
+
+func (p *pipeTerminal) Read(b []byte) (int, error)  { return p.out.Read(b) }
+func (p *pipeTerminal) Write(b []byte) (int, error) { return p.in.Write(b) }
+
+func (p *pipeTerminal) Close() error {
+	p.in.Close()
+	return p.out.Close()
+}
+
+// startWithPTY starts cmd with its input and output connected through pipes.
+// Pseudo-terminals are only implemented on Linux, so programs that require a TTY
+// behave as if run non-interactively. The returned bool is always false.
+func startWithPTY(cmd *exec.Cmd, cols, rows int) (io.ReadWriteCloser, bool, error) {
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		inW.Close()
+		return nil, false, fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	cmd.Stdin = inR
+	cmd.Stdout = outW
+	cmd.Stderr = outW
+	setProcessGroup(cmd)
+	err = cmd.Start()
+
+	// The child holds its own copies of these ends
+	inR.Close()
+	outW.Close()
+	if err != nil {
+		inW.Close()
+		outR.Close()
+		return nil, false, err
+	}
+	return &pipeTerminal{in: inW, out: outR}, false, nil
+}
+
+// resizePTY fails: pipes have no window size
+func resizePTY(tty io.ReadWriteCloser, cols, rows int) error {
+	return fmt.Errorf("not a pty")
+}
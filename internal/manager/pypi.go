@@ -0,0 +1,357 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PyPIURL is the index package_info and package_search query
+const PyPIURL = "https://pypi.org"
+
+// Limits of package lookups
+const (
+	pypiTimeout               = 30 * time.Second
+	MaxPackageVersions        = 100 // newest versions listed by PackageInfo
+	DefaultPackageSearchLimit = 20
+	MaxPackageSearchLimit     = 100
+	pypiSearchPageSize        = 20 // results per page of PyPI's search
+)
+
+// PackageDetails describes a package release and the versions available on its index
+type PackageDetails struct {
+	Name             string            `json:"name"`
+	Version          string            `json:"version"`
+	Latest           string            `json:"latest,omitempty"` // newest stable release
+	Summary          string            `json:"summary,omitempty"`
+	License          string            `json:"license,omitempty"`
+	Author           string            `json:"author,omitempty"`
+	HomePage         string            `json:"home_page,omitempty"`
+	ProjectURLs      map[string]string `json:"project_urls,omitempty"`
+	RequiresPython   string            `json:"requires_python,omitempty"`
+	PythonCompatible *bool             `json:"python_compatible,omitempty"` // with the environment's Python
+	Requires         []string          `json:"requires"`                    // dependencies of Version
+	ReleasedAt       string            `json:"released_at,omitempty"`
+	Yanked           bool              `json:"yanked,omitempty"`
+	YankedReason     string            `json:"yanked_reason,omitempty"`
+	Versions         []string          `json:"versions"` // newest first, at most MaxPackageVersions
+	VersionsTotal    int               `json:"versions_total"`
+	YankedVersions   []string          `json:"yanked_versions,omitempty"`
+	InstalledVersion string            `json:"installed_version,omitempty"`
+	Index            string            `json:"index"` // "pypi" or the private index name
+}
+
+// PackageInfoOptions configures PackageInfo
+type PackageInfoOptions struct {
+	Version string // release to describe (default: the latest)
+	EnvID   string // environment to compare against: installed version, Python compatibility
+	Index   string // private pip index (needs EnvID); only versions are available from it
+}
+
+// PackageSearchHit is a package found by SearchPackages
+type PackageSearchHit struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Summary    string `json:"summary,omitempty"`
+	ReleasedAt string `json:"released_at,omitempty"`
+}
+
+// pypiProject is the subset of PyPI's JSON API response used by PackageInfo
+type pypiProject struct {
+	Info struct {
+		Name           string            `json:"name"`
+		Version        string            `json:"version"`
+		Summary        string            `json:"summary"`
+		License        string            `json:"license"`
+		LicenseExpr    string            `json:"license_expression"`
+		Author         string            `json:"author"`
+		AuthorEmail    string            `json:"author_email"`
+		HomePage       string            `json:"home_page"`
+		ProjectURLs    map[string]string `json:"project_urls"`
+		RequiresPython string            `json:"requires_python"`
+		RequiresDist   []string          `json:"requires_dist"`
+		Yanked         bool              `json:"yanked"`
+		YankedReason   string            `json:"yanked_reason"`
+	} `json:"info"`
+	URLs []struct {
+		UploadTime string `json:"upload_time_iso_8601"`
+	} `json:"urls"`
+	Releases map[string][]pypiFile `json:"releases"`
+}
+
+// pypiFile is an uploaded file of a release
+type pypiFile struct {
+	Yanked bool `json:"yanked"`
+}
+
+// pypiGet fetches a PyPI page. A 404 is reported as CodeNotFound with notFoundMsg.
+func pypiGet(ctx context.Context, rawURL, notFoundMsg string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, pypiTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "jumpboot-mcp")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query PyPI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, WithErrorCode(CodeNotFound, errors.New(notFoundMsg))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query PyPI: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query PyPI: %w", err)
+	}
+	return data, nil
+}
+
+// PackageInfo describes a package from PyPI's JSON API: summary, links, the
+// dependencies and Python requirement of a release, and the available versions.
+// With an environment it also reports the installed version and whether the
+// release supports the environment's Python. With a private index, pip index
+// versions is run in the environment and only the versions are reported.
+func (m *Manager) PackageInfo(ctx context.Context, name string, opts PackageInfoOptions) (*PackageDetails, error) {
+	req, err := ParseRequirement(name)
+	if err != nil || len(req.Specifiers) > 0 || strings.Contains(name, "[") {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid package name: %s (give the bare name and the version separately)", name))
+	}
+	var env *ManagedEnvironment
+	if opts.EnvID != "" {
+		if env, err = m.GetEnvironment(opts.EnvID); err != nil {
+			return nil, err
+		}
+	}
+
+	var details *PackageDetails
+	if opts.Index != "" {
+		if env == nil {
+			return nil, WithErrorCode(CodeInvalidArgument, errors.New("a private index is queried from an environment; pass env_id"))
+		}
+		details, err = m.indexPackageInfo(ctx, env, req.Name, opts)
+	} else {
+		details, err = pypiPackageInfo(ctx, req.Name, opts.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if env != nil {
+		pins, err := pipFreeze(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+		if line, ok := pins[req.Name]; ok {
+			_, details.InstalledVersion, _ = strings.Cut(line, "==")
+		}
+		if details.RequiresPython != "" {
+			if pyReq, err := ParseRequirement("python" + details.RequiresPython); err == nil {
+				compatible := pyReq.SatisfiedBy(env.PythonVer)
+				details.PythonCompatible = &compatible
+			}
+		}
+	}
+	return details, nil
+}
+
+// pypiPackageInfo looks a package up in PyPI's JSON API
+func pypiPackageInfo(ctx context.Context, name, version string) (*PackageDetails, error) {
+	project := PyPIURL + "/pypi/" + url.PathEscape(name) + "/json"
+	data, err := pypiGet(ctx, project, "package not found on PyPI: "+name)
+	if err != nil {
+		return nil, err
+	}
+	var all pypiProject
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid PyPI response: %w", err)
+	}
+
+	release := &all
+	if version != "" && version != all.Info.Version {
+		data, err := pypiGet(ctx, PyPIURL+"/pypi/"+url.PathEscape(name)+"/"+url.PathEscape(version)+"/json",
+			fmt.Sprintf("version %s of %s not found on PyPI", version, name))
+		if err != nil {
+			return nil, err
+		}
+		release = &pypiProject{}
+		if err := json.Unmarshal(data, release); err != nil {
+			return nil, fmt.Errorf("invalid PyPI response: %w", err)
+		}
+	}
+
+	info := release.Info
+	details := &PackageDetails{
+		Name:           info.Name,
+		Version:        info.Version,
+		Latest:         all.Info.Version,
+		Summary:        info.Summary,
+		License:        info.LicenseExpr,
+		Author:         info.Author,
+		HomePage:       info.HomePage,
+		ProjectURLs:    info.ProjectURLs,
+		RequiresPython: info.RequiresPython,
+		Requires:       info.RequiresDist,
+		Yanked:         info.Yanked,
+		YankedReason:   info.YankedReason,
+		Index:          "pypi",
+	}
+	if details.License == "" && len(info.License) <= 200 {
+		// Some projects paste the whole license text here
+		details.License = info.License
+	}
+	if details.Author == "" {
+		details.Author = info.AuthorEmail
+	}
+	if details.Requires == nil {
+		details.Requires = []string{}
+	}
+	if len(release.URLs) > 0 {
+		details.ReleasedAt = release.URLs[0].UploadTime
+	}
+
+	var versions []string
+	for v, files := range all.Releases {
+		if len(files) == 0 {
+			continue // registered without uploads
+		}
+		if !slices.ContainsFunc(files, func(f pypiFile) bool { return !f.Yanked }) {
+			details.YankedVersions = append(details.YankedVersions, v)
+			continue
+		}
+		versions = append(versions, v)
+	}
+	newestFirst := func(a, b string) int { return CompareVersions(b, a) }
+	slices.SortFunc(versions, newestFirst)
+	slices.SortFunc(details.YankedVersions, newestFirst)
+	details.VersionsTotal = len(versions)
+	details.Versions = versions[:min(len(versions), MaxPackageVersions)]
+	return details, nil
+}
+
+// indexPackageInfo lists the versions of a package on a private index with
+// pip index versions, which reports nothing else
+func (m *Manager) indexPackageInfo(ctx context.Context, env *ManagedEnvironment, name string, opts PackageInfoOptions) (*PackageDetails, error) {
+	index, err := m.packageIndex(opts.Index, IndexPip)
+	if err != nil {
+		return nil, err
+	}
+	indexArgs, indexEnv, cleanup, err := pipIndexConfig(env, index)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	args := append([]string{"-m", "pip", "index", "versions", "--pre", "--disable-pip-version-check"}, indexArgs...)
+	cmd := commandContext(ctx, env.Env.PythonPath, append(args, name)...)
+	cmd.Env = append(os.Environ(), indexEnv...)
+	output, err := runCommand(ctx, cmd)
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	if err != nil {
+		if strings.Contains(output, "No matching distribution") {
+			return nil, WithErrorCode(CodeNotFound, fmt.Errorf("package not found on index %s: %s", opts.Index, name))
+		}
+		return nil, fmt.Errorf("failed to query index %s: %s", opts.Index, lastLine(output))
+	}
+
+	// "name (latest)" then "Available versions: a, b, ..."
+	details := &PackageDetails{Name: name, Requires: []string{}, Versions: []string{}, Index: opts.Index}
+	for _, line := range splitLines(output) {
+		line = trimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "Available versions:"); ok {
+			for _, v := range strings.Split(rest, ",") {
+				if v = trimSpace(v); v != "" {
+					details.Versions = append(details.Versions, v)
+				}
+			}
+		} else if n, latest, ok := strings.Cut(line, " ("); ok && details.Latest == "" && !strings.Contains(n, ":") {
+			details.Name, details.Latest = n, strings.TrimSuffix(latest, ")")
+		}
+	}
+	details.VersionsTotal = len(details.Versions)
+	details.Versions = details.Versions[:min(len(details.Versions), MaxPackageVersions)]
+	details.Version = details.Latest
+	if opts.Version != "" {
+		if !slices.Contains(details.Versions, opts.Version) {
+			return nil, WithErrorCode(CodeNotFound, fmt.Errorf("version %s of %s not found on index %s", opts.Version, name, opts.Index))
+		}
+		details.Version = opts.Version
+	}
+	return details, nil
+}
+
+// PyPI search result markup: one package-snippet link per hit
+var (
+	pypiSnippet     = regexp.MustCompile(`(?s)<a class="package-snippet"[^>]*>(.*?)</a>`)
+	pypiSnippetName = regexp.MustCompile(`(?s)class="package-snippet__name">(.*?)</span>`)
+	pypiSnippetVer  = regexp.MustCompile(`(?s)class="package-snippet__version">(.*?)</span>`)
+	pypiSnippetDesc = regexp.MustCompile(`(?s)class="package-snippet__description">(.*?)</p>`)
+	pypiSnippetTime = regexp.MustCompile(`datetime="([^"]+)"`)
+)
+
+// SearchPackages searches PyPI for packages matching query, by relevance. PyPI has
+// no search API, so its search page is read; results follow its ranking.
+func (m *Manager) SearchPackages(ctx context.Context, query string, limit int) ([]PackageSearchHit, error) {
+	query = trimSpace(query)
+	if query == "" {
+		return nil, WithErrorCode(CodeInvalidArgument, errors.New("query is required"))
+	}
+	if limit <= 0 {
+		limit = DefaultPackageSearchLimit
+	}
+	limit = min(limit, MaxPackageSearchLimit)
+
+	hits := []PackageSearchHit{}
+	for page := 1; len(hits) < limit; page++ {
+		q := url.Values{"q": {query}, "page": {fmt.Sprint(page)}}
+		data, err := pypiGet(ctx, PyPIURL+"/search/?"+q.Encode(), "no more results")
+		if err != nil {
+			if code, _ := ErrorCode(err); page > 1 && code == CodeNotFound {
+				break // past the last page
+			}
+			return nil, err
+		}
+		snippets := pypiSnippet.FindAllSubmatch(data, -1)
+		for _, s := range snippets {
+			hit := PackageSearchHit{Name: snippetField(pypiSnippetName, s[1])}
+			if hit.Name == "" {
+				continue
+			}
+			hit.Version = snippetField(pypiSnippetVer, s[1])
+			hit.Summary = snippetField(pypiSnippetDesc, s[1])
+			hit.ReleasedAt = snippetField(pypiSnippetTime, s[1])
+			hits = append(hits, hit)
+		}
+		if len(snippets) < pypiSearchPageSize {
+			break
+		}
+	}
+	return hits[:min(len(hits), limit)], nil
+}
+
+// snippetField returns the unescaped text captured by re in a search result
+func snippetField(re *regexp.Regexp, snippet []byte) string {
+	match := re.FindSubmatch(snippet)
+	if match == nil {
+		return ""
+	}
+	return trimSpace(html.UnescapeString(string(match[1])))
+}
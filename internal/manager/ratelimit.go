@@ -0,0 +1,228 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limits a ThrottleError reports
+const (
+	ThrottleSessionEnvironments = "session_environments"
+	ThrottleSessionExecutions   = "session_executions"
+	ThrottleExecutions          = "executions"
+	ThrottleSessionCalls        = "session_calls_per_minute"
+	ThrottleCalls               = "calls_per_minute"
+)
+
+// maxIdleBuckets is the number of per-session call buckets kept before full (idle)
+// ones are dropped
+const maxIdleBuckets = 1024
+
+// RateLimits caps what sessions may do at once. Zero fields are unlimited.
+type RateLimits struct {
+	SessionEnvironments   int // environments owned by one session
+	SessionExecutions     int // concurrent environment operations of one session
+	Executions            int // concurrent environment operations on the server
+	SessionCallsPerMinute int // tool calls of one session
+	CallsPerMinute        int // tool calls on the server
+}
+
+// ThrottleError is returned when a call would exceed a rate limit or concurrency cap.
+// Clients should wait RetryAfterSeconds (calls per minute) or for their running
+// operations to finish (concurrency) instead of retrying immediately.
+type ThrottleError struct {
+	Limit             string  `json:"limit"`
+	Max               int     `json:"max"`
+	Current           int     `json:"current,omitempty"`
+	RetryAfterSeconds float64 `json:"retry_after_seconds,omitempty"`
+}
+
+func (e *ThrottleError) Error() string {
+	switch e.Limit {
+	case ThrottleSessionEnvironments:
+		return fmt.Sprintf("session environment limit reached (%d of %d); destroy an environment first", e.Current, e.Max)
+	case ThrottleSessionExecutions:
+		return fmt.Sprintf("session has %d of %d operations running; wait for one to finish", e.Current, e.Max)
+	case ThrottleExecutions:
+		return fmt.Sprintf("server has %d of %d operations running; wait for one to finish", e.Current, e.Max)
+	case ThrottleSessionCalls, ThrottleCalls:
+		return fmt.Sprintf("rate limit of %d calls per minute exceeded; retry in %.1fs", e.Max, e.RetryAfterSeconds)
+	default:
+		return "rate limit exceeded"
+	}
+}
+
+// Details returns the structured throttle information included in error responses
+func (e *ThrottleError) Details() any {
+	return e
+}
+
+// ErrorCode classifies the session environment limit as a quota and the other limits,
+// which clear by waiting, as CodeRateLimited
+func (e *ThrottleError) ErrorCode() string {
+	if e.Limit == ThrottleSessionEnvironments {
+		return CodeQuotaExceeded
+	}
+	return CodeRateLimited
+}
+
+// callBucket is a token bucket refilled at perMinute tokens per minute
+type callBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and takes a token, or returns how long until one is available
+func (b *callBucket) take(now time.Time, perMinute int) (time.Duration, bool) {
+	rate := float64(perMinute) / 60
+	b.tokens = math.Min(float64(perMinute), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+}
+
+// rateLimiter enforces RateLimits
+type rateLimiter struct {
+	mu                sync.Mutex
+	limits            RateLimits
+	executions        int
+	sessionExecutions map[string]int
+	calls             *callBucket
+	sessionCalls      map[string]*callBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		sessionExecutions: make(map[string]int),
+		sessionCalls:      make(map[string]*callBucket),
+	}
+}
+
+// SetRateLimits configures per-session and server-wide limits
+func (m *Manager) SetRateLimits(limits RateLimits) {
+	l := m.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	l.calls = nil
+	clear(l.sessionCalls)
+}
+
+// RateLimits returns the configured limits
+func (m *Manager) RateLimits() RateLimits {
+	m.limiter.mu.Lock()
+	defer m.limiter.mu.Unlock()
+	return m.limiter.limits
+}
+
+// AllowCall counts a tool call of the caller in ctx against the calls-per-minute
+// limits, returning a *ThrottleError when one is exceeded
+func (m *Manager) AllowCall(ctx context.Context) error {
+	l := m.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+
+	if max := l.limits.SessionCallsPerMinute; max > 0 {
+		if session := CallerFromContext(ctx).SessionID; session != "" {
+			b, ok := l.sessionCalls[session]
+			if !ok {
+				l.pruneCallBuckets(now)
+				b = &callBucket{tokens: float64(max), last: now}
+				l.sessionCalls[session] = b
+			}
+			if wait, ok := b.take(now, max); !ok {
+				return &ThrottleError{Limit: ThrottleSessionCalls, Max: max, RetryAfterSeconds: roundUpSeconds(wait)}
+			}
+		}
+	}
+	if max := l.limits.CallsPerMinute; max > 0 {
+		if l.calls == nil {
+			l.calls = &callBucket{tokens: float64(max), last: now}
+		}
+		if wait, ok := l.calls.take(now, max); !ok {
+			return &ThrottleError{Limit: ThrottleCalls, Max: max, RetryAfterSeconds: roundUpSeconds(wait)}
+		}
+	}
+	return nil
+}
+
+// pruneCallBuckets drops the buckets of sessions that have been idle long enough to
+// be full again once there are many. Callers must hold l.mu.
+func (l *rateLimiter) pruneCallBuckets(now time.Time) {
+	if len(l.sessionCalls) < maxIdleBuckets {
+		return
+	}
+	for session, b := range l.sessionCalls {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.sessionCalls, session)
+		}
+	}
+}
+
+// acquireExecution claims a slot for an environment operation of the caller in ctx,
+// returning a *ThrottleError when a concurrency cap is reached. release must be
+// called when the operation ends.
+func (m *Manager) acquireExecution(ctx context.Context) (release func(), err error) {
+	l := m.limiter
+	session := CallerFromContext(ctx).SessionID
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if max := l.limits.Executions; max > 0 && l.executions >= max {
+		return nil, &ThrottleError{Limit: ThrottleExecutions, Max: max, Current: l.executions}
+	}
+	if max := l.limits.SessionExecutions; max > 0 && session != "" && l.sessionExecutions[session] >= max {
+		return nil, &ThrottleError{Limit: ThrottleSessionExecutions, Max: max, Current: l.sessionExecutions[session]}
+	}
+	l.executions++
+	if session != "" {
+		l.sessionExecutions[session]++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.executions--
+			if session != "" {
+				if l.sessionExecutions[session]--; l.sessionExecutions[session] <= 0 {
+					delete(l.sessionExecutions, session)
+				}
+			}
+		})
+	}, nil
+}
+
+// checkSessionEnvironments returns a *ThrottleError when the caller in ctx already
+// owns the maximum number of environments, counting those being created. Callers
+// must hold m.mu.
+func (m *Manager) checkSessionEnvironments(ctx context.Context) error {
+	max := m.RateLimits().SessionEnvironments
+	owner := ownerFor(ctx)
+	if max <= 0 || owner == "" {
+		return nil
+	}
+	count := m.pendingOwners[owner]
+	for _, env := range m.environments {
+		if env.Owner == owner {
+			count++
+		}
+	}
+	if count >= max {
+		return &ThrottleError{Limit: ThrottleSessionEnvironments, Max: max, Current: count}
+	}
+	return nil
+}
+
+// roundUpSeconds converts a wait to seconds, rounded up to a tenth
+func roundUpSeconds(d time.Duration) float64 {
+	return math.Ceil(d.Seconds()*10) / 10
+}
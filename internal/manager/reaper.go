@@ -0,0 +1,365 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ErrAdminRequired is returned when an operation needs the admin token
+var ErrAdminRequired = errors.New("this operation requires the admin token or role")
+
+// maxReapInterval bounds how often the reaper looks for resources of dead sessions
+const maxReapInterval = time.Minute
+
+// sessionActivity records when an MCP session was last seen
+type sessionActivity struct {
+	lastSeen       time.Time
+	disconnectedAt time.Time // zero while connected
+}
+
+// OrphanedSession lists the resources still owned by an MCP session that is gone:
+// it disconnected, or made no tool call within the idle timeout
+type OrphanedSession struct {
+	SessionID     string     `json:"session_id"`
+	LastSeen      time.Time  `json:"last_seen"`
+	Disconnected  bool       `json:"disconnected"` // false when the session went idle instead
+	DeadSince     time.Time  `json:"dead_since"`
+	ReapAt        *time.Time `json:"reap_at,omitempty"` // nil when automatic reaping is off
+	Environments  []string   `json:"environments,omitempty"`
+	REPLs         []string   `json:"repls,omitempty"`
+	Processes     []string   `json:"processes,omitempty"`
+	Terminals     []string   `json:"terminals,omitempty"`
+	DebugSessions []string   `json:"debug_sessions,omitempty"`
+	Jobs          []string   `json:"jobs,omitempty"` // running jobs
+}
+
+// SetSessionReaping configures how resources of vanished sessions are cleaned up when
+// session isolation is on. A session is dead once it disconnects or, with a non-zero
+// idleTimeout, once it has made no tool call for that long. With a non-zero grace its
+// environments, REPLs, processes, terminals, debug sessions and running jobs are
+// destroyed once it has been dead that long; with zero grace they are kept until an
+// admin claims them.
+func (m *Manager) SetSessionReaping(idleTimeout, grace time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionIdleTimeout = idleTimeout
+	m.sessionReapGrace = grace
+
+	if m.reaperStop == nil {
+		m.reaperStop = make(chan struct{})
+		go m.runReaper(m.reaperStop)
+	}
+}
+
+// TouchSession records activity of an MCP session, reviving it if it was considered gone
+func (m *Manager) TouchSession(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.sessionIsolation {
+		return
+	}
+	m.sessions[sessionID] = &sessionActivity{lastSeen: time.Now()}
+}
+
+// SessionDisconnected records that an MCP session closed its connection
+func (m *Manager) SessionDisconnected(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if activity, ok := m.sessions[sessionID]; ok {
+		activity.disconnectedAt = time.Now()
+	}
+}
+
+// OrphanedSessions lists dead sessions that still own resources. Admin only.
+func (m *Manager) OrphanedSessions(ctx context.Context) ([]OrphanedSession, error) {
+	if err := m.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orphans := m.orphans(time.Now())
+	result := make([]OrphanedSession, 0, len(orphans))
+	for _, o := range orphans {
+		result = append(result, *o)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].DeadSince.Before(result[j].DeadSince) })
+	return result, nil
+}
+
+// ClaimOrphanedSession transfers the resources of a dead session to target (default:
+// the caller's session), which keeps them from being reaped. Admin only.
+func (m *Manager) ClaimOrphanedSession(ctx context.Context, sessionID, target string) (*OrphanedSession, error) {
+	if err := m.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if target == "" {
+		target = CallerFromContext(ctx).SessionID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orphan, ok := m.orphans(time.Now())[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("no orphaned resources for session: %s", sessionID)
+	}
+	for _, env := range m.environments {
+		if env.Owner == sessionID {
+			env.Owner = target
+		}
+	}
+	// info() methods read the owner under each resource's own lock
+	for _, repl := range m.replSessions {
+		repl.activityMu.Lock()
+		if repl.Owner == sessionID {
+			repl.Owner = target
+		}
+		repl.activityMu.Unlock()
+	}
+	for _, proc := range m.spawnedProcesses {
+		proc.outputMu.Lock()
+		if proc.Owner == sessionID {
+			proc.Owner = target
+		}
+		proc.outputMu.Unlock()
+	}
+	for _, term := range m.terminals {
+		term.mu.Lock()
+		if term.Owner == sessionID {
+			term.Owner = target
+		}
+		term.mu.Unlock()
+	}
+	for _, session := range m.debugSessions {
+		session.mu.Lock()
+		if session.Owner == sessionID {
+			session.Owner = target
+		}
+		session.mu.Unlock()
+	}
+	for _, job := range m.jobs {
+		job.mu.Lock()
+		if job.Owner == sessionID {
+			job.Owner = target
+		}
+		job.mu.Unlock()
+	}
+	for _, s := range m.schedules {
+		s.mu.Lock()
+		if s.Owner == sessionID {
+			s.Owner = target
+		}
+		s.mu.Unlock()
+	}
+	for _, w := range m.watches {
+		if w.Owner == sessionID {
+			w.Owner = target
+		}
+	}
+	for _, shared := range m.sharedWorkspaces {
+		if shared.Owner == sessionID {
+			shared.Owner = target
+		}
+	}
+	delete(m.sessions, sessionID)
+	if target != "" {
+		m.sessions[target] = &sessionActivity{lastSeen: time.Now()}
+	}
+
+	orphan.ReapAt = nil
+	return orphan, nil
+}
+
+// checkAdmin returns ErrAdminRequired unless the caller is an admin, through the admin
+// role or, with session isolation on, the admin token
+func (m *Manager) checkAdmin(ctx context.Context) error {
+	caller := CallerFromContext(ctx)
+	if caller.Role == "" && !m.SessionIsolation() {
+		return fmt.Errorf("session isolation is not enabled")
+	}
+	if !caller.Admin {
+		return ErrAdminRequired
+	}
+	return nil
+}
+
+// deadSince reports whether a session is gone and since when. Sessions never seen
+// (e.g. resources created without isolation) are not considered dead.
+// Callers must hold m.mu.
+func (m *Manager) deadSince(sessionID string, now time.Time) (time.Time, bool) {
+	activity, ok := m.sessions[sessionID]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !activity.disconnectedAt.IsZero() {
+		return activity.disconnectedAt, true
+	}
+	if m.sessionIdleTimeout > 0 && now.Sub(activity.lastSeen) >= m.sessionIdleTimeout {
+		return activity.lastSeen.Add(m.sessionIdleTimeout), true
+	}
+	return time.Time{}, false
+}
+
+// orphans groups the resources of dead sessions by owner. Callers must hold m.mu.
+func (m *Manager) orphans(now time.Time) map[string]*OrphanedSession {
+	orphans := make(map[string]*OrphanedSession)
+	get := func(owner string) *OrphanedSession {
+		if owner == "" {
+			return nil
+		}
+		if o, ok := orphans[owner]; ok {
+			return o
+		}
+		since, dead := m.deadSince(owner, now)
+		if !dead {
+			return nil
+		}
+		activity := m.sessions[owner]
+		o := &OrphanedSession{
+			SessionID:    owner,
+			LastSeen:     activity.lastSeen,
+			Disconnected: !activity.disconnectedAt.IsZero(),
+			DeadSince:    since,
+		}
+		if m.sessionReapGrace > 0 {
+			reapAt := since.Add(m.sessionReapGrace)
+			o.ReapAt = &reapAt
+		}
+		orphans[owner] = o
+		return o
+	}
+
+	for id, env := range m.environments {
+		if o := get(env.Owner); o != nil {
+			o.Environments = append(o.Environments, id)
+		}
+	}
+	for id, repl := range m.replSessions {
+		if o := get(repl.Owner); o != nil {
+			o.REPLs = append(o.REPLs, id)
+		}
+	}
+	for id, proc := range m.spawnedProcesses {
+		if o := get(proc.Owner); o != nil {
+			o.Processes = append(o.Processes, id)
+		}
+	}
+	for id, term := range m.terminals {
+		if o := get(term.Owner); o != nil {
+			o.Terminals = append(o.Terminals, id)
+		}
+	}
+	for id, session := range m.debugSessions {
+		if o := get(session.Owner); o != nil {
+			o.DebugSessions = append(o.DebugSessions, id)
+		}
+	}
+	for id, job := range m.jobs {
+		if status := job.info().Status; status != JobRunning && status != JobCancelling {
+			continue
+		}
+		if o := get(job.Owner); o != nil {
+			o.Jobs = append(o.Jobs, id)
+		}
+	}
+
+	for _, o := range orphans {
+		sort.Strings(o.Environments)
+		sort.Strings(o.REPLs)
+		sort.Strings(o.Processes)
+		sort.Strings(o.Terminals)
+		sort.Strings(o.DebugSessions)
+		sort.Strings(o.Jobs)
+	}
+	return orphans
+}
+
+// runReaper periodically reaps dead sessions until stop is closed
+func (m *Manager) runReaper(stop chan struct{}) {
+	for {
+		m.mu.RLock()
+		interval := maxReapInterval
+		if m.sessionReapGrace > 0 {
+			interval = min(interval, max(m.sessionReapGrace/2, time.Second))
+		}
+		m.mu.RUnlock()
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+		m.reapSessions(time.Now())
+	}
+}
+
+// reapSessions destroys the resources of sessions dead for longer than the grace
+// period and forgets dead sessions that own nothing
+func (m *Manager) reapSessions(now time.Time) {
+	m.mu.Lock()
+	if !m.sessionIsolation {
+		m.mu.Unlock()
+		return
+	}
+	orphans := m.orphans(now)
+	for id := range m.sessions {
+		if _, dead := m.deadSince(id, now); dead && orphans[id] == nil {
+			delete(m.sessions, id)
+		}
+	}
+	var victims []*OrphanedSession
+	if m.sessionReapGrace > 0 {
+		for _, o := range orphans {
+			if !now.Before(*o.ReapAt) {
+				victims = append(victims, o)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	admin := WithCaller(context.Background(), Caller{Admin: true})
+	for _, o := range victims {
+		// The session may have come back since the orphans were collected
+		m.mu.RLock()
+		_, dead := m.deadSince(o.SessionID, time.Now())
+		m.mu.RUnlock()
+		if !dead {
+			continue
+		}
+
+		for _, id := range o.Jobs {
+			m.CancelJob(admin, id)
+		}
+		for _, id := range o.Processes {
+			m.KillProcess(id)
+		}
+		for _, id := range o.Terminals {
+			m.CloseTerminal(id)
+		}
+		for _, id := range o.DebugSessions {
+			m.StopDebug(id)
+		}
+		for _, id := range o.REPLs {
+			m.DestroyREPL(id)
+		}
+		for _, id := range o.Environments {
+			m.DestroyEnvironment(id)
+		}
+		fmt.Fprintf(os.Stderr, "Reaped session %s: %d environments, %d REPLs, %d processes, %d terminals, %d debug sessions, %d jobs\n",
+			o.SessionID, len(o.Environments), len(o.REPLs), len(o.Processes), len(o.Terminals), len(o.DebugSessions), len(o.Jobs))
+
+		m.mu.Lock()
+		if _, dead := m.deadSince(o.SessionID, time.Now()); dead {
+			delete(m.sessions, o.SessionID)
+		}
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,224 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkpointMarker prefixes the JSON summary printed by the checkpoint scripts
+const checkpointMarker = "__JUMPBOOT_CHECKPOINT__"
+
+// checkpointDir is the workspace directory used when no checkpoint path is given
+const checkpointDir = "checkpoints"
+
+// checkpointScript saves the session's global namespace. Each variable is serialized
+// separately (with dill when installed, otherwise pickle) so one unpicklable value
+// only skips that variable; modules are stored by name and re-imported on restore.
+const checkpointScript = `
+def __jb_checkpoint(path):
+    import json, os, pickle, types
+    try:
+        import dill as ser
+    except ImportError:
+        ser = pickle
+    variables, modules, skipped = {}, {}, []
+    for name, value in list(globals().items()):
+        if name.startswith('__'):
+            continue
+        if isinstance(value, types.ModuleType):
+            modules[name] = value.__name__
+            continue
+        try:
+            variables[name] = ser.dumps(value)
+        except Exception:
+            skipped.append(name)
+    os.makedirs(os.path.dirname(path), exist_ok=True)
+    tmp = path + '.tmp'
+    with open(tmp, 'wb') as f:
+        pickle.dump({'format': 1, 'serializer': ser.__name__, 'variables': variables, 'modules': modules}, f)
+    os.replace(tmp, path)
+    print(%[1]q + json.dumps({'variables': sorted(variables), 'modules': modules,
+                              'skipped': sorted(skipped), 'serializer': ser.__name__}))
+try:
+    __jb_checkpoint(%[2]s)
+finally:
+    del __jb_checkpoint
+`
+
+// restoreScript loads a checkpoint written by checkpointScript into the session
+const restoreScript = `
+def __jb_restore(path):
+    import importlib, json, pickle
+    with open(path, 'rb') as f:
+        checkpoint = pickle.load(f)
+    ser = pickle
+    if checkpoint['serializer'] == 'dill':
+        import dill as ser
+    ns = globals()
+    restored, failed = [], {}
+    for name, module in checkpoint['modules'].items():
+        try:
+            ns[name] = importlib.import_module(module)
+        except Exception as e:
+            failed[name] = repr(e)
+    for name, blob in checkpoint['variables'].items():
+        try:
+            ns[name] = ser.loads(blob)
+            restored.append(name)
+        except Exception as e:
+            failed[name] = repr(e)
+    print(%[1]q + json.dumps({'variables': sorted(restored), 'modules': checkpoint['modules'],
+                              'failed': failed, 'serializer': checkpoint['serializer']}))
+try:
+    __jb_restore(%[2]s)
+finally:
+    del __jb_restore
+`
+
+// CheckpointInfo describes a saved REPL checkpoint
+type CheckpointInfo struct {
+	SessionID  string            `json:"session_id"`
+	Path       string            `json:"path"` // relative to the workspace
+	SizeBytes  int64             `json:"size_bytes"`
+	Serializer string            `json:"serializer"` // "dill" or "pickle"
+	Variables  []string          `json:"variables"`
+	Modules    map[string]string `json:"modules"` // alias -> module name
+	Skipped    []string          `json:"skipped,omitempty"`
+}
+
+// RestoreInfo describes a checkpoint loaded into a REPL session
+type RestoreInfo struct {
+	Session    *REPLInfo         `json:"session"`
+	Path       string            `json:"path"`
+	Serializer string            `json:"serializer"`
+	Variables  []string          `json:"variables"`
+	Modules    map[string]string `json:"modules"`
+	Failed     map[string]string `json:"failed,omitempty"` // name -> error
+}
+
+// CheckpointREPL saves the global namespace of a REPL session to a workspace file
+// (default "checkpoints/<session name>.pkl"). Variables that cannot be serialized are
+// reported as skipped.
+func (m *Manager) CheckpointREPL(ctx context.Context, sessionID, path string) (*CheckpointInfo, error) {
+	repl, err := m.GetREPL(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	env, err := m.GetEnvironment(repl.EnvID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = filepath.Join(checkpointDir, checkpointName(repl)+".pkl")
+	}
+	fullPath, err := m.checkpointPath(repl.EnvID, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkMountWritable(env, fullPath); err != nil {
+		return nil, err
+	}
+
+	var info CheckpointInfo
+	if err := m.runCheckpointScript(ctx, sessionID, checkpointScript, fullPath, &info); err != nil {
+		return nil, fmt.Errorf("checkpoint failed: %w", err)
+	}
+	info.SessionID = sessionID
+	info.Path = filepath.ToSlash(path)
+	if st, err := os.Stat(fullPath); err == nil {
+		info.SizeBytes = st.Size()
+	}
+	return &info, nil
+}
+
+// RestoreREPL loads a checkpoint from the environment's workspace into an existing
+// session, or into a new session named sessionName when sessionID is empty. A new
+// session is destroyed again if the checkpoint cannot be loaded.
+func (m *Manager) RestoreREPL(ctx context.Context, envID, path, sessionID, sessionName string) (*RestoreInfo, error) {
+	fullPath, err := m.checkpointPath(envID, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return nil, notFound("checkpoint", path)
+	}
+
+	created := false
+	if sessionID == "" {
+		session, err := m.CreateREPL(ctx, envID, sessionName, nil)
+		if err != nil {
+			return nil, err
+		}
+		sessionID = session.ID
+		created = true
+	} else {
+		repl, err := m.GetREPL(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if repl.EnvID != envID {
+			return nil, fmt.Errorf("REPL session %s belongs to environment %s", sessionID, repl.EnvID)
+		}
+	}
+
+	var info RestoreInfo
+	if err := m.runCheckpointScript(ctx, sessionID, restoreScript, fullPath, &info); err != nil {
+		if created {
+			m.DestroyREPL(sessionID)
+		}
+		return nil, fmt.Errorf("restore failed: %w", err)
+	}
+
+	repl, err := m.GetREPL(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	info.Session = repl.info()
+	info.Path = filepath.ToSlash(path)
+	return &info, nil
+}
+
+// checkpointPath resolves a checkpoint path inside the environment's workspace,
+// creating the workspace if needed
+func (m *Manager) checkpointPath(envID, path string) (string, error) {
+	workspace, err := m.CreateWorkspace(envID)
+	if err != nil {
+		return "", err
+	}
+	return safeJoinPath(workspace.Path, path)
+}
+
+// runCheckpointScript runs a checkpoint script in a session and decodes the summary
+// it prints into out
+func (m *Manager) runCheckpointScript(ctx context.Context, sessionID, script, fullPath string, out any) error {
+	// A JSON string is also a valid Python string literal. The REPL feeds code to an
+	// interactive console line by line, so the script is sent as a single exec() call.
+	pathLiteral, _ := json.Marshal(fullPath)
+	codeLiteral, _ := json.Marshal(fmt.Sprintf(script, checkpointMarker, pathLiteral))
+	output, err := m.ExecuteREPL(ctx, sessionID, fmt.Sprintf("exec(%s)", codeLiteral))
+	if err != nil {
+		return err
+	}
+	return decodeMarkedJSON(output, checkpointMarker, out)
+}
+
+// checkpointName returns a file-name-safe name for a session's default checkpoint
+func checkpointName(repl *ManagedREPL) string {
+	name := repl.Name
+	if name == "" {
+		name = repl.ID
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
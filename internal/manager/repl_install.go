@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// replRefreshMarker prefixes the JSON printed by replRefreshScript
+const replRefreshMarker = "__JUMPBOOT_REFRESH__"
+
+// replRefreshScript makes packages installed while a session is running importable:
+// it clears the import system's directory caches and re-processes site-packages so
+// new .pth files take effect. It reports the installed versions of the requested
+// distributions and which of their modules the session had already imported.
+const replRefreshScript = `
+def __jb_refresh(requirements):
+    import importlib, json, re, site, sys
+    import importlib.metadata as md
+    importlib.invalidate_caches()
+    for d in site.getsitepackages():
+        site.addsitedir(d)
+    norm = lambda n: re.sub(r'[-_.]+', '-', n).lower()
+    try:
+        modules = {}
+        for module, dists in md.packages_distributions().items():
+            for dist in dists:
+                modules.setdefault(norm(dist), set()).add(module)
+    except AttributeError:
+        modules = None
+    versions, stale = {}, set()
+    for req in requirements:
+        match = re.match(r'[A-Za-z0-9][A-Za-z0-9._-]*', req)
+        if not match:
+            continue
+        try:
+            dist = md.distribution(match.group(0))
+        except md.PackageNotFoundError:
+            continue
+        name = dist.metadata['Name']
+        versions[name] = dist.version
+        if modules is not None:
+            top = modules.get(norm(name), ())
+        else:
+            top = (dist.read_text('top_level.txt') or '').split()
+        stale.update(m for m in top if m in sys.modules)
+    print(%[1]q + json.dumps({'versions': versions, 'stale_modules': sorted(stale)}))
+try:
+    __jb_refresh(%[2]s)
+finally:
+    del __jb_refresh
+`
+
+// REPLInstallInfo describes packages installed from a REPL session
+type REPLInstallInfo struct {
+	SessionID string            `json:"session_id"`
+	Packages  []string          `json:"packages"`
+	Versions  map[string]string `json:"versions"` // distribution name -> installed version
+	// StaleModules were imported before the install; the session keeps using the old
+	// code until they are reloaded or the session is restarted
+	StaleModules []string `json:"stale_modules,omitempty"`
+}
+
+// InstallREPLPackages installs packages into a REPL session's environment and makes
+// them importable in the running session without restarting it
+func (m *Manager) InstallREPLPackages(ctx context.Context, sessionID string, packages []string, useConda bool) (*REPLInstallInfo, error) {
+	repl, err := m.GetREPL(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.InstallPackages(ctx, repl.EnvID, packages, useConda); err != nil {
+		return nil, err
+	}
+
+	// Quoted and sent as one exec() line, as in runCheckpointScript
+	packagesLiteral, _ := json.Marshal(packages)
+	scriptLiteral, _ := json.Marshal(fmt.Sprintf(replRefreshScript, replRefreshMarker, packagesLiteral))
+	output, err := m.ExecuteREPL(ctx, sessionID, fmt.Sprintf("exec(%s)", scriptLiteral))
+	if err != nil {
+		return nil, fmt.Errorf("packages installed but the session was not refreshed: %w", err)
+	}
+
+	info := &REPLInstallInfo{SessionID: sessionID, Packages: packages}
+	if err := decodeMarkedJSON(output, replRefreshMarker, info); err != nil {
+		return nil, fmt.Errorf("packages installed but the session was not refreshed: %w", err)
+	}
+	return info, nil
+}
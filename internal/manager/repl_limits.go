@@ -0,0 +1,166 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetREPLLimits configures the maximum number of REPL sessions per environment and
+// across the server (0 = unlimited). When a limit is reached, creating a session
+// evicts the least recently used idle session in the same scope that the caller can
+// access.
+func (m *Manager) SetREPLLimits(perEnv, global int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxREPLsPerEnv = perEnv
+	m.maxREPLs = global
+}
+
+// info returns the serializable info about the session
+func (r *ManagedREPL) info() *REPLInfo {
+	r.activityMu.Lock()
+	defer r.activityMu.Unlock()
+
+	return &REPLInfo{
+		ID:           r.ID,
+		Name:         r.Name,
+		EnvID:        r.EnvID,
+		Owner:        r.Owner,
+		LastActivity: r.lastActivity,
+		GPUDevices:   r.GPUDevices,
+	}
+}
+
+// beginExecution marks the session busy and records activity
+func (r *ManagedREPL) beginExecution() error {
+	r.activityMu.Lock()
+	defer r.activityMu.Unlock()
+
+	if r.closed {
+		return fmt.Errorf("REPL session was closed: %s", r.ID)
+	}
+	r.executing++
+	r.lastActivity = time.Now()
+	return nil
+}
+
+// endExecution marks an execution finished and records activity
+func (r *ManagedREPL) endExecution() {
+	r.activityMu.Lock()
+	defer r.activityMu.Unlock()
+
+	r.executing--
+	r.lastActivity = time.Now()
+}
+
+// markClosed prevents new executions from starting on the session
+func (r *ManagedREPL) markClosed() {
+	r.activityMu.Lock()
+	defer r.activityMu.Unlock()
+	r.closed = true
+}
+
+// enforceREPLLimits evicts idle sessions of the caller until a new session for envID
+// fits within the configured limits. Callers must hold m.mu for writing.
+func (m *Manager) enforceREPLLimits(ctx context.Context, envID string) error {
+	if m.maxREPLsPerEnv > 0 {
+		for m.countREPLs(envID) >= m.maxREPLsPerEnv {
+			if !m.evictIdleREPL(ctx, envID) {
+				return WithErrorCode(CodeQuotaExceeded, fmt.Errorf("REPL limit reached for environment %s (%d sessions, none idle that you can close)", envID, m.maxREPLsPerEnv))
+			}
+		}
+	}
+
+	if m.maxREPLs > 0 {
+		for len(m.replSessions) >= m.maxREPLs {
+			if !m.evictIdleREPL(ctx, "") {
+				return WithErrorCode(CodeQuotaExceeded, fmt.Errorf("server REPL limit reached (%d sessions, none idle that you can close)", m.maxREPLs))
+			}
+		}
+	}
+
+	return nil
+}
+
+// countREPLs returns the number of sessions for an environment. Callers must hold m.mu.
+func (m *Manager) countREPLs(envID string) int {
+	count := 0
+	for _, repl := range m.replSessions {
+		if repl.EnvID == envID {
+			count++
+		}
+	}
+	return count
+}
+
+// evictIdleREPL closes the least recently used idle session that the caller can
+// access, restricted to envID when it is non-empty, so that one session's REPLs are
+// never closed to make room for another's. Returns false if no session could be
+// evicted. Callers must hold m.mu for writing.
+func (m *Manager) evictIdleREPL(ctx context.Context, envID string) bool {
+	var victim *ManagedREPL
+	var victimActivity time.Time
+
+	for _, repl := range m.replSessions {
+		if (envID != "" && repl.EnvID != envID) || !m.canAccess(ctx, repl.Owner) {
+			continue
+		}
+		repl.activityMu.Lock()
+		idle := repl.executing == 0
+		activity := repl.lastActivity
+		repl.activityMu.Unlock()
+
+		if idle && (victim == nil || activity.Before(victimActivity)) {
+			victim = repl
+			victimActivity = activity
+		}
+	}
+
+	if victim == nil {
+		return false
+	}
+
+	victim.activityMu.Lock()
+	if victim.executing > 0 {
+		victim.activityMu.Unlock()
+		return false
+	}
+	victim.closed = true
+	victim.activityMu.Unlock()
+
+	if victim.REPL != nil {
+		victim.REPL.Close()
+	}
+	delete(m.replSessions, victim.ID)
+	m.releaseGPUs(victim.ID)
+	return true
+}
+
+// FindREPL returns the ID of the REPL session with the given name in an environment.
+// If several sessions share the name, the most recently used one is returned.
+func (m *Manager) FindREPL(ctx context.Context, envID, name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *ManagedREPL
+	var foundActivity time.Time
+	for _, repl := range m.replSessions {
+		if repl.EnvID != envID || repl.Name != name || !m.canAccess(ctx, repl.Owner) {
+			continue
+		}
+		repl.activityMu.Lock()
+		activity := repl.lastActivity
+		repl.activityMu.Unlock()
+
+		if found == nil || activity.After(foundActivity) {
+			found = repl
+			foundActivity = activity
+		}
+	}
+
+	if found == nil {
+		return "", WithErrorCode(CodeNotFound, fmt.Errorf("REPL session %q not found in environment %s", name, envID))
+	}
+	return found.ID, nil
+}
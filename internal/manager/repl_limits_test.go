@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEnforceREPLLimitsEvictsOnlyAccessibleREPLs(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetSessionIsolation(true)
+	m.SetREPLLimits(0, 2)
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replSessions = map[string]*ManagedREPL{
+		"a-old": {ID: "a-old", EnvID: "env", Owner: "a", lastActivity: now.Add(-2 * time.Hour)},
+		"b-new": {ID: "b-new", EnvID: "env", Owner: "b", lastActivity: now},
+	}
+
+	// Session b may only evict its own REPL, although a's is older
+	ctxB := WithCaller(context.Background(), Caller{SessionID: "b"})
+	if err := m.enforceREPLLimits(ctxB, "env"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.replSessions["a-old"]; !ok {
+		t.Error("session b evicted session a's REPL")
+	}
+	if _, ok := m.replSessions["b-new"]; ok {
+		t.Error("session b's idle REPL was not evicted")
+	}
+
+	// Session c owns nothing to evict
+	m.replSessions["a-busy"] = &ManagedREPL{ID: "a-busy", EnvID: "env", Owner: "a", executing: 1}
+	ctxC := WithCaller(context.Background(), Caller{SessionID: "c"})
+	err = m.enforceREPLLimits(ctxC, "env")
+	if code := errorCode(err); code != CodeQuotaExceeded {
+		t.Fatalf("got error %v (%s), want %s", err, code, CodeQuotaExceeded)
+	}
+	if len(m.replSessions) != 2 {
+		t.Errorf("%d REPLs left, want 2", len(m.replSessions))
+	}
+
+	// An admin may evict any idle REPL, but never a busy one
+	ctxAdmin := WithCaller(context.Background(), Caller{Admin: true})
+	if err := m.enforceREPLLimits(ctxAdmin, "env"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.replSessions["a-busy"]; !ok || len(m.replSessions) != 1 {
+		t.Errorf("admin eviction left %v", m.replSessions)
+	}
+	m.replSessions["a-busy2"] = &ManagedREPL{ID: "a-busy2", EnvID: "env", Owner: "a", executing: 1}
+	if err := m.enforceREPLLimits(ctxAdmin, "env"); err == nil {
+		t.Error("admin evicted a busy REPL")
+	}
+}
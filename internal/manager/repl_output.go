@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// replResultMarker prefixes the JSON printed by replStructuredScript
+const replResultMarker = "__JUMPBOOT_RESULT__"
+
+// replStructuredScript runs code in the session's namespace with stdout and stderr
+// captured separately. Like a notebook cell, a trailing expression is evaluated and its
+// repr returned (and bound to _). Exceptions are reported instead of raised, with the
+// helper's own frame removed from the traceback.
+const replStructuredScript = `
+def __jb_run(source):
+    import ast, builtins, io, json, traceback
+    from contextlib import redirect_stdout, redirect_stderr
+    out, err = io.StringIO(), io.StringIO()
+    result = {'result': None, 'result_type': None, 'exception': None}
+    ns = globals()
+    try:
+        with redirect_stdout(out), redirect_stderr(err):
+            tree = ast.parse(source, '<input>', 'exec')
+            last = None
+            if tree.body and isinstance(tree.body[-1], ast.Expr):
+                last = ast.Expression(tree.body.pop().value)
+            exec(compile(tree, '<input>', 'exec'), ns)
+            if last is not None:
+                value = eval(compile(last, '<input>', 'eval'), ns)
+                if value is not None:
+                    builtins._ = value
+                    result['result'] = repr(value)
+                    result['result_type'] = type(value).__name__
+    except Exception as e:
+        if isinstance(e, SyntaxError):
+            tb = ''.join(traceback.format_exception_only(type(e), e))
+        else:
+            tb = ''.join(traceback.format_exception(type(e), e, e.__traceback__.tb_next))
+        result['exception'] = {'type': type(e).__name__, 'message': str(e), 'traceback': tb}
+    result['stdout'], result['stderr'] = out.getvalue(), err.getvalue()
+    print(%[1]q + json.dumps(result))
+try:
+    __jb_run(%[2]s)
+finally:
+    del __jb_run
+`
+
+// REPLException describes an exception raised by code run in a REPL session
+type REPLException struct {
+	Type      string `json:"type"`
+	Message   string `json:"message"`
+	Traceback string `json:"traceback"`
+}
+
+// REPLResult is the structured outcome of ExecuteREPLStructured
+type REPLResult struct {
+	Stdout     string         `json:"stdout"`
+	Stderr     string         `json:"stderr"`
+	Result     *string        `json:"result"`                // repr of the trailing expression, null if none or None
+	ResultType string         `json:"result_type,omitempty"` // type name of the trailing expression
+	Failed     bool           `json:"failed"`
+	Exception  *REPLException `json:"exception,omitempty"`
+}
+
+// ExecuteREPLStructured runs code in a REPL session and returns stdout, stderr, the
+// repr of a trailing expression and any exception separately. An exception raised by
+// the code is reported in the result (Failed) rather than as an error.
+func (m *Manager) ExecuteREPLStructured(ctx context.Context, id, code string) (*REPLResult, error) {
+	// Quoted and sent as one exec() line, as in runCheckpointScript
+	codeLiteral, _ := json.Marshal(code)
+	scriptLiteral, _ := json.Marshal(fmt.Sprintf(replStructuredScript, replResultMarker, codeLiteral))
+	output, err := m.ExecuteREPL(ctx, id, fmt.Sprintf("exec(%s)", scriptLiteral))
+	if err != nil {
+		return nil, err
+	}
+
+	var result REPLResult
+	if err := decodeMarkedJSON(output, replResultMarker, &result); err != nil {
+		return nil, fmt.Errorf("failed to read execution result: %w", err)
+	}
+	result.Failed = result.Exception != nil
+	return &result, nil
+}
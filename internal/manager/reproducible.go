@@ -0,0 +1,261 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// runManifestDir is the workspace directory holding the manifests of reproducible runs
+const runManifestDir = "runs"
+
+// Environment variables of reproducible runs
+const (
+	seedEnv    = "JUMPBOOT_SEED"     // seed of the run
+	seedLogEnv = "JUMPBOOT_SEED_LOG" // file the bootstrap lists the seeded libraries in
+)
+
+// seedBootstrap seeds Python's random module, then runs the script in argv[1] with
+// the remaining arguments as if it had been started directly. numpy, torch and
+// tensorflow are seeded when they are first imported, so they do not have to be
+// installed and are not imported needlessly. The seeded libraries are written to
+// $JUMPBOOT_SEED_LOG as a JSON list.
+const seedBootstrap = `
+import importlib.abc, importlib.util, json, os, random, runpy, sys
+
+_seed = int(os.environ['JUMPBOOT_SEED'])
+_seeded = ['random']
+_seeders = {
+    'numpy': lambda m: m.random.seed(_seed),
+    'torch': lambda m: m.manual_seed(_seed),
+    'tensorflow': lambda m: m.random.set_seed(_seed),
+}
+
+def _log():
+    with open(os.environ['JUMPBOOT_SEED_LOG'], 'w') as f:
+        json.dump(_seeded, f)
+
+class _SeedOnImport(importlib.abc.MetaPathFinder):
+    def find_spec(self, name, path, target=None):
+        if name not in _seeders or name in _seeded:
+            return None
+        sys.meta_path.remove(self)
+        try:
+            spec = importlib.util.find_spec(name)
+        finally:
+            sys.meta_path.insert(0, self)
+        if spec is None or spec.loader is None:
+            return spec
+        exec_module = spec.loader.exec_module
+        def exec_and_seed(module):
+            exec_module(module)
+            try:
+                _seeders[name](module)
+                _seeded.append(name)
+                _log()
+            except Exception:
+                pass
+        spec.loader.exec_module = exec_and_seed
+        return spec
+
+random.seed(_seed)
+_log()
+sys.meta_path.insert(0, _SeedOnImport())
+
+_script = sys.argv[1]
+sys.argv = sys.argv[1:]
+sys.path[0] = os.path.dirname(os.path.abspath(_script))
+runpy.run_path(_script, run_name='__main__')
+`
+
+// RunOptions are the options of RunCode and RunScript
+type RunOptions struct {
+	Cache        bool   // return the memoized result of an identical run, and memoize this one
+	Reproducible bool   // seed the random number generators and write a run manifest
+	Seed         *int64 // seed of a reproducible run (nil = random)
+}
+
+// validate checks that the options can be combined
+func (o RunOptions) validate() error {
+	if o.Seed != nil && !o.Reproducible {
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("seed requires reproducible"))
+	}
+	if o.Reproducible && o.Cache {
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("a reproducible run cannot be served from the cache; pass either cache or reproducible"))
+	}
+	if o.Seed != nil && (*o.Seed < 0 || *o.Seed >= 1<<32) {
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("seed must be between 0 and 4294967295"))
+	}
+	return nil
+}
+
+// RunManifest records what a reproducible run depended on and what it produced, so
+// the run can be audited and repeated
+type RunManifest struct {
+	ID              string            `json:"id"`
+	Kind            string            `json:"kind"` // CacheKindCode or CacheKindScript
+	EnvID           string            `json:"env_id"`
+	PythonVersion   string            `json:"python_version"`
+	StartedAt       time.Time         `json:"started_at"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	CodeSHA256      string            `json:"code_sha256,omitempty"`
+	Script          string            `json:"script,omitempty"`
+	ScriptSHA256    string            `json:"script_sha256,omitempty"`
+	Args            []string          `json:"args,omitempty"`
+	GPUDevices      []string          `json:"gpu_devices,omitempty"`
+	InputSHA256     string            `json:"input_sha256,omitempty"`
+	Variables       []string          `json:"variables,omitempty"` // names only; values may be secrets
+	Seed            int64             `json:"seed"`
+	Seeds           map[string]int64  `json:"seeds"` // seeds set, by variable or library
+	FreezeSHA256    string            `json:"freeze_sha256"`
+	Packages        []string          `json:"packages"` // pip freeze
+	Git             *RunGitState      `json:"git,omitempty"`
+	OutputSHA256    string            `json:"output_sha256"`
+	ResultSHA256    string            `json:"result_sha256,omitempty"`
+	Artifacts       map[string]string `json:"artifacts,omitempty"` // SHA-256 by workspace path
+}
+
+// RunGitState is the git checkout a reproducible run was started from
+type RunGitState struct {
+	Commit string `json:"commit"`
+	Branch string `json:"branch,omitempty"`
+	Dirty  bool   `json:"dirty,omitempty"` // uncommitted changes
+}
+
+// reproducibleRun is a reproducible run being prepared and recorded
+type reproducibleRun struct {
+	manifest  *RunManifest
+	bootstrap string // seedBootstrap, run instead of the script
+	seedLog   string
+}
+
+// prepareReproducible records the state a run starts from and writes the seed
+// bootstrap to dir. gitDir is the directory whose checkout is recorded, if any.
+// Callers hold the environment's operation lock, so nothing is installed meanwhile.
+func (m *Manager) prepareReproducible(ctx context.Context, env *ManagedEnvironment, opts RunOptions, kind, dir, gitDir string) (*reproducibleRun, error) {
+	if err := env.checkWritable(); err != nil {
+		return nil, fmt.Errorf("a reproducible run writes its manifest to the workspace: %w", err)
+	}
+	seed := int64(0)
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	} else {
+		var b [4]byte
+		rand.Read(b[:])
+		seed = int64(binary.BigEndian.Uint32(b[:]))
+	}
+
+	output, err := commandContext(ctx, env.Env.PythonPath, "-m", "pip", "freeze", "--all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	var packages []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			packages = append(packages, line)
+		}
+	}
+	slices.Sort(packages)
+
+	var variables []string
+	for _, kv := range env.appendVars(nil) {
+		name, _, _ := strings.Cut(kv, "=")
+		variables = append(variables, name)
+	}
+
+	run := &reproducibleRun{
+		manifest: &RunManifest{
+			ID:            uuid.New().String(),
+			Kind:          kind,
+			EnvID:         env.ID,
+			PythonVersion: env.Env.PythonVersion.String(),
+			StartedAt:     time.Now().UTC(),
+			Variables:     variables,
+			Seed:          seed,
+			Seeds:         map[string]int64{"PYTHONHASHSEED": seed},
+			FreezeSHA256:  hexSHA256(strings.Join(packages, "\n")),
+			Packages:      packages,
+			Git:           gitState(ctx, gitDir),
+		},
+		bootstrap: filepath.Join(dir, "jumpboot_seed.py"),
+		seedLog:   filepath.Join(dir, "seeded.json"),
+	}
+	if err := os.WriteFile(run.bootstrap, []byte(seedBootstrap), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write seed bootstrap: %w", err)
+	}
+	return run, nil
+}
+
+// environ adds the seed variables of the run to a command environment
+func (r *reproducibleRun) environ(environ []string) []string {
+	seed := strconv.FormatInt(r.manifest.Seed, 10)
+	return append(environ, "PYTHONHASHSEED="+seed, seedEnv+"="+seed, seedLogEnv+"="+r.seedLog)
+}
+
+// finish completes the manifest with the run's outcome and writes it to the
+// workspace, returning its workspace path
+func (r *reproducibleRun) finish(env *ManagedEnvironment, output string, resultJSON []byte, artifacts []Artifact) (string, error) {
+	manifest := r.manifest
+	manifest.DurationSeconds = time.Since(manifest.StartedAt).Seconds()
+	manifest.OutputSHA256 = hexSHA256(output)
+	if len(resultJSON) > 0 {
+		manifest.ResultSHA256 = hexSHA256(string(resultJSON))
+	}
+	if data, err := os.ReadFile(r.seedLog); err == nil {
+		var seeded []string
+		json.Unmarshal(data, &seeded)
+		for _, name := range seeded {
+			manifest.Seeds[name] = manifest.Seed
+		}
+	}
+	for _, artifact := range artifacts {
+		if sum, err := fileSHA256(filepath.Join(env.WorkspaceDir, filepath.FromSlash(artifact.Path))); err == nil {
+			if manifest.Artifacts == nil {
+				manifest.Artifacts = make(map[string]string)
+			}
+			manifest.Artifacts[artifact.Path] = sum
+		}
+	}
+
+	// Names sort by start time, like artifacts directories
+	name := manifest.StartedAt.Format("20060102-150405") + "-" + manifest.ID[:8] + ".json"
+	path := filepath.Join(env.WorkspaceDir, runManifestDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create run manifest directory: %w", err)
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write run manifest: %w", err)
+	}
+	return workspaceRelative(env.WorkspaceDir, path), nil
+}
+
+// gitState returns the checkout dir belongs to, or nil if it is not in a git
+// repository or git is not installed
+func gitState(ctx context.Context, dir string) *RunGitState {
+	if dir == "" {
+		return nil
+	}
+	commit, err := commandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return nil
+	}
+	state := &RunGitState{Commit: strings.TrimSpace(string(commit))}
+	if branch, err := commandContext(ctx, "git", "-C", dir, "symbolic-ref", "--short", "-q", "HEAD").Output(); err == nil {
+		state.Branch = strings.TrimSpace(string(branch))
+	}
+	if status, err := commandContext(ctx, "git", "-C", dir, "status", "--porcelain", "--untracked-files=no").Output(); err == nil {
+		state.Dirty = len(strings.TrimSpace(string(status))) > 0
+	}
+	return state
+}
@@ -0,0 +1,441 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Requirement is a parsed package requirement such as "numpy>=1.24,<2"
+type Requirement struct {
+	Name       string
+	Specifiers []VersionSpecifier
+	Original   string
+}
+
+// VersionSpecifier is a single version constraint such as ">=1.24"
+type VersionSpecifier struct {
+	Op      string
+	Version string
+}
+
+// EnvironmentMatch describes an environment that satisfies a set of requirements
+type EnvironmentMatch struct {
+	EnvironmentInfo
+	Server    string            `json:"server,omitempty"`
+	Satisfied map[string]string `json:"satisfied"` // requirement name -> installed version
+}
+
+// specifierOps lists the supported operators, longest first so prefixes match correctly
+var specifierOps = []string{"===", "~=", "==", "!=", ">=", "<=", ">", "<"}
+
+// ParseRequirement parses a pip-style requirement string.
+// Extras ("pkg[extra]") and environment markers ("; python_version...") are ignored.
+func ParseRequirement(s string) (Requirement, error) {
+	req := Requirement{Original: s}
+
+	s = trimSpace(s)
+	if idx := strings.Index(s, ";"); idx >= 0 {
+		s = trimSpace(s[:idx])
+	}
+	if s == "" {
+		return req, fmt.Errorf("empty requirement")
+	}
+
+	// Split the name from the specifiers
+	nameEnd := len(s)
+	for i, r := range s {
+		if r == '[' || r == '=' || r == '<' || r == '>' || r == '!' || r == '~' || r == ' ' {
+			nameEnd = i
+			break
+		}
+	}
+	req.Name = NormalizePackageName(s[:nameEnd])
+	if req.Name == "" {
+		return req, fmt.Errorf("invalid requirement: %s", req.Original)
+	}
+
+	rest := trimSpace(s[nameEnd:])
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return req, fmt.Errorf("invalid extras in requirement: %s", req.Original)
+		}
+		rest = trimSpace(rest[end+1:])
+	}
+
+	if rest == "" {
+		return req, nil
+	}
+
+	for _, part := range strings.Split(rest, ",") {
+		part = trimSpace(part)
+		if part == "" {
+			continue
+		}
+		matched := false
+		for _, op := range specifierOps {
+			if strings.HasPrefix(part, op) {
+				req.Specifiers = append(req.Specifiers, VersionSpecifier{
+					Op:      op,
+					Version: trimSpace(part[len(op):]),
+				})
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return req, fmt.Errorf("invalid version specifier %q in requirement: %s", part, req.Original)
+		}
+	}
+
+	return req, nil
+}
+
+// SatisfiedBy reports whether the given installed version satisfies the requirement
+func (r Requirement) SatisfiedBy(version string) bool {
+	for _, spec := range r.Specifiers {
+		if !spec.matches(version) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s VersionSpecifier) matches(version string) bool {
+	// Like pip, a local label (1.0+cpu) only counts if the specifier has one
+	if s.Op != "===" && !strings.Contains(s.Version, "+") {
+		version, _, _ = strings.Cut(version, "+")
+	}
+	switch s.Op {
+	case "===":
+		return version == s.Version
+	case "==":
+		if strings.HasSuffix(s.Version, ".*") {
+			prefix := strings.TrimSuffix(s.Version, "*")
+			return strings.HasPrefix(version+".", prefix)
+		}
+		return CompareVersions(version, s.Version) == 0
+	case "!=":
+		if strings.HasSuffix(s.Version, ".*") {
+			prefix := strings.TrimSuffix(s.Version, "*")
+			return !strings.HasPrefix(version+".", prefix)
+		}
+		return CompareVersions(version, s.Version) != 0
+	case ">=":
+		return CompareVersions(version, s.Version) >= 0
+	case "<=":
+		return CompareVersions(version, s.Version) <= 0
+	case ">":
+		return CompareVersions(version, s.Version) > 0
+	case "<":
+		return CompareVersions(version, s.Version) < 0
+	case "~=":
+		// Compatible release: ~=1.4.2 means >=1.4.2, ==1.4.*
+		if CompareVersions(version, s.Version) < 0 {
+			return false
+		}
+		parts := strings.Split(s.Version, ".")
+		if len(parts) < 2 {
+			return true
+		}
+		prefix := strings.Join(parts[:len(parts)-1], ".") + "."
+		return strings.HasPrefix(version+".", prefix)
+	}
+	return false
+}
+
+// pep440Pattern matches a PEP 440 version in any of its accepted spellings
+var pep440Pattern = regexp.MustCompile(`(?i)^\s*v?(?:([0-9]+)!)?([0-9]+(?:\.[0-9]+)*)` +
+	`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?([0-9]+)?)?` +
+	`(?:-([0-9]+)|[-_.]?(post|rev|r)[-_.]?([0-9]+)?)?` +
+	`(?:[-_.]?(dev)[-_.]?([0-9]+)?)?` +
+	`(?:\+([a-z0-9]+(?:[-_.][a-z0-9]+)*))?\s*$`)
+
+// pep440Version is a parsed PEP 440 version. Numbers are kept as digit strings so
+// that date-like components of any length compare correctly.
+type pep440Version struct {
+	epoch   string
+	release []string
+	pre     int // 0 for none, else 1 for a, 2 for b, 3 for rc
+	preN    string
+	post    bool
+	postN   string
+	dev     bool
+	devN    string
+	local   []string
+}
+
+// parsePEP440 parses a version, reporting false if it is not PEP 440
+func parsePEP440(v string) (pep440Version, bool) {
+	m := pep440Pattern.FindStringSubmatch(v)
+	if m == nil {
+		return pep440Version{}, false
+	}
+	pv := pep440Version{epoch: m[1], release: strings.Split(m[2], ".")}
+	switch strings.ToLower(m[3]) {
+	case "a", "alpha":
+		pv.pre = 1
+	case "b", "beta":
+		pv.pre = 2
+	case "c", "rc", "pre", "preview":
+		pv.pre = 3
+	}
+	pv.preN = m[4]
+	if m[5] != "" || m[6] != "" {
+		pv.post, pv.postN = true, m[5]+m[7]
+	}
+	pv.dev, pv.devN = m[8] != "", m[9]
+	if m[10] != "" {
+		pv.local = strings.FieldsFunc(strings.ToLower(m[10]), func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	}
+	return pv, true
+}
+
+// preKey ranks the pre-release phase: a dev release of the final version (1.0.dev1)
+// comes before all its pre-releases, and the final release after them
+func (v pep440Version) preKey() int {
+	switch {
+	case v.pre != 0:
+		return v.pre
+	case v.dev && !v.post:
+		return -1
+	default:
+		return 4
+	}
+}
+
+// comparePEP440 orders versions as PEP 440 does: by epoch, release, then
+// dev < a < b < rc < final < post, with a version's .devN before the version itself,
+// then by local label
+func comparePEP440(a, b pep440Version) int {
+	if c := compareNumbers(a.epoch, b.epoch); c != 0 {
+		return c
+	}
+	for i := 0; i < len(a.release) || i < len(b.release); i++ {
+		var ra, rb string
+		if i < len(a.release) {
+			ra = a.release[i]
+		}
+		if i < len(b.release) {
+			rb = b.release[i]
+		}
+		if c := compareNumbers(ra, rb); c != 0 {
+			return c
+		}
+	}
+	if c := compareInts(a.preKey(), b.preKey()); c != 0 {
+		return c
+	}
+	if c := compareNumbers(a.preN, b.preN); c != 0 {
+		return c
+	}
+	if a.post != b.post {
+		return boolOrder(a.post)
+	}
+	if c := compareNumbers(a.postN, b.postN); c != 0 {
+		return c
+	}
+	if a.dev != b.dev {
+		// A .devN comes before the same version without it
+		return boolOrder(b.dev)
+	}
+	if c := compareNumbers(a.devN, b.devN); c != 0 {
+		return c
+	}
+	return compareLocal(a.local, b.local)
+}
+
+// compareLocal orders local labels: none first, then segment by segment with
+// numbers after strings, and a longer label after its prefix
+func compareLocal(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, nb := leadingDigits(a[i]) == a[i], leadingDigits(b[i]) == b[i]
+		switch {
+		case na && nb:
+			if c := compareNumbers(a[i], b[i]); c != 0 {
+				return c
+			}
+		case na != nb:
+			return boolOrder(na)
+		case a[i] != b[i]:
+			return strings.Compare(a[i], b[i])
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+// compareNumbers compares non-negative decimal digit strings of any length; an
+// empty string is 0
+func compareNumbers(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if c := compareInts(len(a), len(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// boolOrder returns 1 if first is true and -1 otherwise, for fields that differ
+func boolOrder(first bool) int {
+	if first {
+		return 1
+	}
+	return -1
+}
+
+// CompareVersions compares two version strings in PEP 440 order, so that
+// 1.0.dev1 < 1.0a1 < 1.0b1 < 1.0rc1 < 1.0 < 1.0.post1 and 1.0 == 1.0.0. Versions
+// that are not PEP 440 are compared dotted part by part, numerically where possible.
+// Returns -1 if a < b, 0 if equal, and 1 if a > b.
+func CompareVersions(a, b string) int {
+	va, okA := parsePEP440(a)
+	vb, okB := parsePEP440(b)
+	if okA && okB {
+		return comparePEP440(va, vb)
+	}
+
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var sa, sb string
+		if i < len(pa) {
+			sa = pa[i]
+		}
+		if i < len(pb) {
+			sb = pb[i]
+		}
+		if c := compareVersionPart(sa, sb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareVersionPart compares a single version component, treating missing parts as 0
+func compareVersionPart(a, b string) int {
+	na, errA := strconv.Atoi(leadingDigits(a))
+	nb, errB := strconv.Atoi(leadingDigits(b))
+	if a == "" {
+		na, errA = 0, nil
+	}
+	if b == "" {
+		nb, errB = 0, nil
+	}
+	if errA == nil && errB == nil && na != nb {
+		if na < nb {
+			return -1
+		}
+		return 1
+	}
+	// Same numeric value (or non-numeric): fall back to comparing the suffix,
+	// where a pre-release suffix ("rc1") sorts before the plain release
+	sufA := strings.TrimPrefix(a, leadingDigits(a))
+	sufB := strings.TrimPrefix(b, leadingDigits(b))
+	switch {
+	case sufA == sufB:
+		return 0
+	case sufA == "":
+		return 1
+	case sufB == "":
+		return -1
+	case sufA < sufB:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func leadingDigits(s string) string {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
+
+// NormalizePackageName normalizes a package name per PEP 503
+func NormalizePackageName(name string) string {
+	name = strings.ToLower(trimSpace(name))
+	var b strings.Builder
+	lastSep := false
+	for _, r := range name {
+		if r == '-' || r == '_' || r == '.' {
+			if !lastSep {
+				b.WriteRune('-')
+			}
+			lastSep = true
+			continue
+		}
+		lastSep = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// FindEnvironments returns the managed environments that satisfy all requirements
+// and match the Python version prefix (e.g., "3.11" matches "3.11.9")
+func (m *Manager) FindEnvironments(ctx context.Context, requirements []string, pythonVersion string) ([]EnvironmentMatch, error) {
+	reqs := make([]Requirement, 0, len(requirements))
+	for _, r := range requirements {
+		req, err := ParseRequirement(r)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+
+	matches := []EnvironmentMatch{}
+	for _, info := range m.ListEnvironments(ctx) {
+		if pythonVersion != "" && info.PythonVersion != pythonVersion &&
+			!strings.HasPrefix(info.PythonVersion, pythonVersion+".") {
+			continue
+		}
+
+		satisfied := make(map[string]string)
+		if len(reqs) > 0 {
+			packages, err := m.ListPackages(info.ID)
+			if err != nil {
+				continue
+			}
+
+			installed := make(map[string]string, len(packages))
+			for _, pkg := range packages {
+				// Direct references ("name @ url") carry no version
+				name, _, _ := strings.Cut(pkg.Name, " @ ")
+				installed[NormalizePackageName(name)] = pkg.Version
+			}
+
+			ok := true
+			for _, req := range reqs {
+				version, found := installed[req.Name]
+				if !found || !req.SatisfiedBy(version) {
+					ok = false
+					break
+				}
+				satisfied[req.Name] = version
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		matches = append(matches, EnvironmentMatch{
+			EnvironmentInfo: info,
+			Satisfied:       satisfied,
+		})
+	}
+
+	return matches, nil
+}
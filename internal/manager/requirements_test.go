@@ -0,0 +1,112 @@
+package manager
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		// Release segments compare numerically, with missing parts as 0
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.2", "1.10", -1},
+		{"2", "1.99.99", 1},
+		{"1.0.1", "1.0", 1},
+		{"20240101", "9999", 1},
+		{"1.01", "1.1", 0},
+		{"123456789012345678901234567890", "123456789012345678901234567891", -1},
+
+		// dev < a < b < rc < final < post
+		{"1.0.dev1", "1.0a1", -1},
+		{"1.0a1", "1.0b1", -1},
+		{"1.0b1", "1.0rc1", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0", "1.0.post1", -1},
+		{"1.0.post1", "1.0", 1},
+		{"1.0.dev1", "1.0", -1},
+		{"1.0.dev1", "1.0rc1", -1},
+		{"1.0rc2", "1.0rc10", -1},
+		{"1.0.post1", "1.0.1", -1},
+		{"1.0.post1", "1.0.post2", -1},
+		{"0.9", "1.0.dev0", -1},
+
+		// A version's .devN comes just before it
+		{"1.0a1.dev1", "1.0a1", -1},
+		{"1.0a1.dev1", "1.0.dev2", 1},
+		{"1.0.post1.dev1", "1.0.post1", -1},
+		{"1.0.post1.dev1", "1.0", 1},
+		{"1.0.dev1", "1.0.dev2", -1},
+
+		// Alternative spellings
+		{"1.0alpha1", "1.0a1", 0},
+		{"1.0-beta.2", "1.0b2", 0},
+		{"1.0c1", "1.0rc1", 0},
+		{"1.0pre1", "1.0rc1", 0},
+		{"1.0-1", "1.0.post1", 0},
+		{"1.0.rev1", "1.0.post1", 0},
+		{"1.0post", "1.0.post0", 0},
+		{"1.0.dev", "1.0.dev0", 0},
+		{"v1.0", "1.0", 0},
+		{"1.0RC1", "1.0rc1", 0},
+
+		// Epochs outrank releases
+		{"1!1.0", "2.0", 1},
+		{"0!2.0", "2.0", 0},
+
+		// Local labels sort after the public version, numbers after strings
+		{"1.0+local", "1.0", 1},
+		{"1.0+1", "1.0+abc", 1},
+		{"1.0+abc.1", "1.0+abc", 1},
+		{"1.0+cu118", "1.0+cu121", -1},
+		{"1.0+local", "1.0.post1", -1},
+
+		// Versions that are not PEP 440 still compare part by part
+		{"1.0.x", "1.0.y", -1},
+		{"2.0-custom", "10.0-custom", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestRequirementSatisfiedBy(t *testing.T) {
+	tests := []struct {
+		req     string
+		version string
+		want    bool
+	}{
+		{"numpy>=1.24,<2", "1.26.4", true},
+		{"numpy>=1.24,<2", "2.0.0", false},
+		{"numpy>=1.24,<2", "1.23.5", false},
+		{"pkg>=1.0", "1.0.post1", true},
+		{"pkg<=1.0", "1.0.post1", false},
+		{"pkg>=1.0", "1.0rc1", false},
+		{"pkg>=1.0", "1.0.dev1", false},
+		{"pkg==1.0", "1.0.0", true},
+		{"pkg==1.0.*", "1.0.5", true},
+		{"pkg==1.0.*", "1.1", false},
+		{"pkg!=1.0", "1.0", false},
+		{"pkg~=1.4.2", "1.4.9", true},
+		{"pkg~=1.4.2", "1.5", false},
+		{"pkg~=1.4.2", "1.4.1", false},
+		{"torch==2.1.0", "2.1.0+cu118", true},
+		{"torch==2.1.0+cu118", "2.1.0+cu121", false},
+		{"torch<=2.1.0", "2.1.0+cu118", true},
+		{"torch===2.1.0", "2.1.0+cu118", false},
+	}
+	for _, tt := range tests {
+		r, err := ParseRequirement(tt.req)
+		if err != nil {
+			t.Fatalf("ParseRequirement(%q): %v", tt.req, err)
+		}
+		if got := r.SatisfiedBy(tt.version); got != tt.want {
+			t.Errorf("%q satisfied by %q = %v, want %v", tt.req, tt.version, got, tt.want)
+		}
+	}
+}
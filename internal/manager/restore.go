@@ -0,0 +1,171 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment specification formats accepted by RestoreEnvironmentFromSpec
+const (
+	SpecAuto           = "auto"
+	SpecJumpboot       = "jumpboot"        // JSON produced by freeze_environment
+	SpecRequirements   = "requirements"    // pip requirements.txt, optionally with --hash options
+	SpecEnvironmentYML = "environment_yml" // conda environment.yml
+)
+
+// RestoreOptions configures RestoreEnvironmentFromSpec
+type RestoreOptions struct {
+	// Format of the specification (SpecAuto or empty detects it)
+	Format string
+	// PythonVersion for specifications that do not pin one (default DefaultPythonVersion)
+	PythonVersion string
+}
+
+// condaEnvironment is the subset of environment.yml used for restoring
+type condaEnvironment struct {
+	Name         string   `yaml:"name"`
+	Channels     []string `yaml:"channels"`
+	Dependencies []any    `yaml:"dependencies"`
+}
+
+// condaPython matches a conda python dependency and captures its major.minor version
+var condaPython = regexp.MustCompile(`^python\s*[=<>!~]*\s*(\d+\.\d+)`)
+
+// DetectSpecFormat guesses the format of an environment specification
+func DetectSpecFormat(spec string) string {
+	trimmed := strings.TrimSpace(spec)
+	if strings.HasPrefix(trimmed, "{") {
+		return SpecJumpboot
+	}
+	for _, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "dependencies:") || strings.HasPrefix(line, "channels:") {
+			return SpecEnvironmentYML
+		}
+	}
+	return SpecRequirements
+}
+
+// RestoreEnvironmentFromSpec creates an environment from a jumpboot frozen JSON, a pip
+// requirements file or a conda environment.yml. Packages are installed before the
+// environment becomes visible; if installation fails the environment is removed.
+func (m *Manager) RestoreEnvironmentFromSpec(ctx context.Context, name, spec string, opts RestoreOptions) (*EnvironmentInfo, error) {
+	format := opts.Format
+	if format == "" || format == SpecAuto {
+		format = DetectSpecFormat(spec)
+	}
+
+	var info *EnvironmentInfo
+	var err error
+	switch format {
+	case SpecJumpboot:
+		info, err = m.RestoreEnvironment(ctx, name, spec)
+	case SpecRequirements:
+		info, err = m.restoreFromRequirements(ctx, name, spec, opts.PythonVersion)
+	case SpecEnvironmentYML:
+		info, err = m.restoreFromEnvironmentYML(ctx, name, spec, opts.PythonVersion)
+	default:
+		return nil, fmt.Errorf("unknown specification format: %s (use %s, %s or %s)",
+			format, SpecJumpboot, SpecRequirements, SpecEnvironmentYML)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.SpecFormat = format
+	return info, nil
+}
+
+// restoreFromRequirements creates an environment and pip-installs a requirements file.
+// pip switches to hash-checking mode by itself when any requirement carries --hash.
+func (m *Manager) restoreFromRequirements(ctx context.Context, name, requirements, pythonVersion string) (*EnvironmentInfo, error) {
+	return m.CreateEnvironment(ctx, name, pythonVersion, CreateOptions{
+		setup: func(ctx context.Context, env *ManagedEnvironment) error {
+			return pipInstallRequirements(ctx, env, requirements)
+		},
+	})
+}
+
+// restoreFromEnvironmentYML creates an environment for the Python version pinned in an
+// environment.yml, installs its conda dependencies with micromamba and its pip section
+// with pip
+func (m *Manager) restoreFromEnvironmentYML(ctx context.Context, name, spec, pythonVersion string) (*EnvironmentInfo, error) {
+	var condaEnv condaEnvironment
+	if err := yaml.Unmarshal([]byte(spec), &condaEnv); err != nil {
+		return nil, fmt.Errorf("invalid environment.yml: %w", err)
+	}
+
+	var condaDeps, pipDeps []string
+	for _, dep := range condaEnv.Dependencies {
+		switch d := dep.(type) {
+		case string:
+			if match := condaPython.FindStringSubmatch(d); match != nil {
+				pythonVersion = match[1]
+				continue
+			}
+			if d == "python" || d == "pip" {
+				continue
+			}
+			condaDeps = append(condaDeps, d)
+		case map[string]any:
+			pipSection, ok := d["pip"].([]any)
+			if !ok {
+				return nil, fmt.Errorf("unsupported dependency in environment.yml: %v", d)
+			}
+			for _, p := range pipSection {
+				s, ok := p.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid pip dependency in environment.yml: %v", p)
+				}
+				pipDeps = append(pipDeps, s)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported dependency in environment.yml: %v", d)
+		}
+	}
+
+	channels := condaEnv.Channels
+	if len(channels) == 0 {
+		channels = []string{"conda-forge"}
+	}
+
+	return m.CreateEnvironment(ctx, name, pythonVersion, CreateOptions{
+		setup: func(ctx context.Context, env *ManagedEnvironment) error {
+			if len(condaDeps) > 0 {
+				args := []string{"install", "--no-rc", "--prefix", env.Env.EnvPath, "-y"}
+				for _, ch := range channels {
+					args = append(args, "-c", ch)
+				}
+				args = append(args, condaDeps...)
+				if output, err := runCommand(ctx, commandContext(ctx, env.Env.MicromambaPath, args...)); err != nil {
+					return fmt.Errorf("failed to install conda dependencies: %w\nOutput: %s", err, output)
+				}
+			}
+			if len(pipDeps) > 0 {
+				return pipInstallRequirements(ctx, env, strings.Join(pipDeps, "\n"))
+			}
+			return nil
+		},
+	})
+}
+
+// pipInstallRequirements installs requirements text into an environment. The file is
+// written to the environment's root directory so relative references resolve there.
+func pipInstallRequirements(ctx context.Context, env *ManagedEnvironment, requirements string) error {
+	path := filepath.Join(env.RootDir, "requirements.restore.txt")
+	if err := os.WriteFile(path, []byte(requirements), 0644); err != nil {
+		return fmt.Errorf("failed to write requirements: %w", err)
+	}
+	defer os.Remove(path)
+
+	output, err := runPython(ctx, env, "-m", "pip", "install", "--no-warn-script-location", "-r", path)
+	if err != nil {
+		return fmt.Errorf("failed to install from requirements: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
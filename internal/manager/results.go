@@ -0,0 +1,321 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Defaults of the result spool
+const (
+	DefaultSpoolThreshold = 64 << 10  // results larger than this are spooled
+	DefaultSpoolTTL       = time.Hour // how long a spooled result can be fetched
+)
+
+// Page sizes of fetch_result
+const (
+	DefaultResultPageBytes = 32 << 10
+	MaxResultPageBytes     = 1 << 20
+)
+
+// MetaResultPage is the result _meta key fetch_result sets on its pages, so that no
+// server spools them again, however the call was proxied and named
+const MetaResultPage = "jumpboot/result_page"
+
+// maxSpoolBytes bounds the disk space of spooled results; the oldest are dropped first
+const maxSpoolBytes = 512 << 20
+
+// spoolDir is the directory under the base directory that holds spooled results
+const spoolDir = "results"
+
+// spoolPreviewBytes bounds the head and tail of a long string kept in a spooled result
+const spoolPreviewBytes = 2048
+
+// spooledResult is a large tool result kept on disk for fetch_result
+type spooledResult struct {
+	id      string
+	owner   string // MCP session that received it
+	path    string
+	size    int
+	created time.Time
+}
+
+// SpoolInfo tells the client that a result was shortened and how to fetch the rest
+type SpoolInfo struct {
+	ResultID  string         `json:"result_id"`
+	Size      int            `json:"size"`             // bytes of the full result
+	Fields    map[string]int `json:"fields,omitempty"` // shortened strings and their full sizes, by path
+	ExpiresAt time.Time      `json:"expires_at"`
+	Hint      string         `json:"hint"`
+}
+
+// ResultPage is a range of a spooled result
+type ResultPage struct {
+	ResultID   string `json:"result_id"`
+	Field      string `json:"field,omitempty"`
+	Offset     int    `json:"offset"`
+	Total      int    `json:"total"` // bytes of the result or field
+	Content    string `json:"content"`
+	NextOffset int    `json:"next_offset,omitempty"` // offset of the next page, unset at the end
+	Done       bool   `json:"done"`
+}
+
+// SetResultSpool makes tool results larger than threshold bytes be spooled to disk
+// for ttl and replaced by a summary (threshold 0 disables spooling). Results left over
+// from an earlier run are removed.
+func (m *Manager) SetResultSpool(threshold int, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultSpoolTTL
+	}
+	m.spoolMu.Lock()
+	defer m.spoolMu.Unlock()
+	m.spoolThreshold, m.spoolTTL = threshold, ttl
+	m.spooled = make(map[string]*spooledResult)
+	os.RemoveAll(filepath.Join(m.baseDir, spoolDir))
+}
+
+// ResultSpoolThreshold returns the size above which results are spooled (0 = never)
+func (m *Manager) ResultSpoolThreshold() int {
+	m.spoolMu.Lock()
+	defer m.spoolMu.Unlock()
+	return m.spoolThreshold
+}
+
+// SpoolResult stores a tool result that exceeds the spool threshold and returns the
+// text to send instead. A JSON result keeps its structure with long strings cut to
+// their head and tail, plus a "spooled" field naming the result and the cut fields;
+// other results, or JSON still too large after cutting, are replaced by a preview.
+// Results within the threshold are returned unchanged.
+func (m *Manager) SpoolResult(ctx context.Context, text string) (string, error) {
+	m.spoolMu.Lock()
+	threshold, ttl := m.spoolThreshold, m.spoolTTL
+	m.spoolMu.Unlock()
+	if threshold <= 0 || len(text) <= threshold {
+		return text, nil
+	}
+
+	id := uuid.New().String()
+	dir := filepath.Join(m.baseDir, spoolDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to spool result: %w", err)
+	}
+	path := filepath.Join(dir, id+".txt")
+	if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+		return "", fmt.Errorf("failed to spool result: %w", err)
+	}
+	result := &spooledResult{id: id, owner: ownerFor(ctx), path: path, size: len(text), created: time.Now()}
+	m.spoolMu.Lock()
+	m.spooled[id] = result
+	m.pruneSpool()
+	m.spoolMu.Unlock()
+
+	info := SpoolInfo{
+		ResultID:  id,
+		Size:      len(text),
+		Fields:    make(map[string]int),
+		ExpiresAt: result.created.Add(ttl).UTC(),
+	}
+	preview := min(spoolPreviewBytes, threshold/4)
+
+	var doc map[string]any
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if decoder.Decode(&doc) == nil {
+		doc = shortenStrings(doc, "", preview, info.Fields).(map[string]any)
+		info.Hint = "Long strings were cut to their head and tail. Call fetch_result with this result_id and a field from fields (or no field for the whole result) to page through the full content"
+		doc["spooled"] = info
+		if data, err := json.Marshal(doc); err == nil && len(data) <= threshold {
+			return string(data), nil
+		}
+	}
+
+	success := true
+	if s, ok := doc["success"].(bool); ok {
+		success = s
+	}
+	info.Fields = nil
+	info.Hint = "The result was too large to return. Call fetch_result with this result_id to page through it"
+	data, err := json.Marshal(map[string]any{
+		"success": success,
+		"spooled": info,
+		"preview": cutMiddle(text, preview, len(text)),
+	})
+	return string(data), err
+}
+
+// shortenStrings replaces the strings in v longer than twice preview by their head and
+// tail, recording the path and full size of each in fields
+func shortenStrings(v any, path string, preview int, fields map[string]int) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = shortenStrings(value, joinFieldPath(path, key), preview, fields)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = shortenStrings(value, joinFieldPath(path, strconv.Itoa(i)), preview, fields)
+		}
+	case string:
+		if len(v) > 2*preview {
+			fields[path] = len(v)
+			return cutMiddle(v, preview, len(v))
+		}
+	}
+	return v
+}
+
+// joinFieldPath appends a key or index to a dotted field path
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// cutMiddle returns the first and last n bytes of s with a note of what was left out
+func cutMiddle(s string, n, total int) string {
+	head := s[:runeBoundary(s, n)]
+	tail := s[runeBoundary(s, len(s)-n):]
+	return fmt.Sprintf("%s\n... [%d of %d bytes omitted; fetch_result returns them] ...\n%s", head, total-len(head)-len(tail), total, tail)
+}
+
+// runeBoundary moves i back to the start of the UTF-8 character it falls in
+func runeBoundary(s string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(s) {
+		return len(s)
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// FetchResult returns length bytes (default DefaultResultPageBytes, at most
+// MaxResultPageBytes) of a spooled result from offset. With field, a
+// dotted path from the result's "spooled" fields such as data.stdout, the range is
+// taken from that string instead of the whole result. Offsets are moved back to the
+// start of a UTF-8 character.
+func (m *Manager) FetchResult(ctx context.Context, id, field string, offset, length int) (*ResultPage, error) {
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("offset and length cannot be negative")
+	}
+	if length == 0 {
+		length = DefaultResultPageBytes
+	}
+	length = min(length, MaxResultPageBytes)
+	m.spoolMu.Lock()
+	m.pruneSpool()
+	result, ok := m.spooled[id]
+	m.spoolMu.Unlock()
+	m.mu.RLock()
+	ok = ok && m.canAccess(ctx, result.owner)
+	m.mu.RUnlock()
+	if !ok {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("result not found or expired: %s", id))
+	}
+
+	data, err := os.ReadFile(result.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result: %w", err)
+	}
+	content := string(data)
+	if field != "" {
+		if content, err = resultField(data, field); err != nil {
+			return nil, err
+		}
+	}
+
+	page := &ResultPage{ResultID: id, Field: field, Total: len(content)}
+	start := runeBoundary(content, offset)
+	end := runeBoundary(content, start+length)
+	if end == start && start < len(content) {
+		// Always return at least one character
+		_, size := utf8.DecodeRuneInString(content[start:])
+		end = start + size
+	}
+	page.Offset, page.Content = start, content[start:end]
+	if end < len(content) {
+		page.NextOffset = end
+	} else {
+		page.Done = true
+	}
+	return page, nil
+}
+
+// resultField returns the string at a dotted path of a JSON result
+func resultField(data []byte, field string) (string, error) {
+	var v any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return "", fmt.Errorf("the result is not JSON, so it has no field %s; fetch it without field", field)
+	}
+	for _, key := range strings.Split(field, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", WithErrorCode(CodeNotFound, fmt.Errorf("field %s not found", field))
+			}
+			v = node[i]
+		default:
+			v = nil
+		}
+		if v == nil {
+			return "", WithErrorCode(CodeNotFound, fmt.Errorf("field %s not found", field))
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s is not a string; fetch it without field", field)
+	}
+	return s, nil
+}
+
+// pruneSpool removes expired results and, while the spool is over maxSpoolBytes, the
+// oldest ones. Callers must hold m.spoolMu.
+func (m *Manager) pruneSpool() {
+	total := 0
+	var oldest *spooledResult
+	for id, result := range m.spooled {
+		if time.Since(result.created) > m.spoolTTL {
+			os.Remove(result.path)
+			delete(m.spooled, id)
+			continue
+		}
+		total += result.size
+	}
+	for total > maxSpoolBytes && len(m.spooled) > 1 {
+		oldest = nil
+		for _, result := range m.spooled {
+			if oldest == nil || result.created.Before(oldest.created) {
+				oldest = result
+			}
+		}
+		os.Remove(oldest.path)
+		delete(m.spooled, oldest.id)
+		total -= oldest.size
+	}
+}
+
+// clearSpool removes every spooled result
+func (m *Manager) clearSpool() {
+	m.spoolMu.Lock()
+	defer m.spoolMu.Unlock()
+	m.spooled = make(map[string]*spooledResult)
+	os.RemoveAll(filepath.Join(m.baseDir, spoolDir))
+}
@@ -0,0 +1,281 @@
+package manager
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Defaults and bounds of RunMap
+const (
+	DefaultMapFunction    = "main"
+	DefaultMapOutputLines = 50
+	MaxMapInputs          = 10000
+	MaxMapWorkers         = 64
+)
+
+// Map item outcomes
+const (
+	MapSucceeded = "succeeded"
+	MapFailed    = "failed" // raised, or never ran because the map was stopped
+)
+
+// mapDriver loads a function from a Python file and calls it with each input in a
+// pool of worker processes, appending one JSON line per finished item to the results
+// file. Every worker loads the file itself, so the function need not be picklable.
+// Results that are not JSON are converted with tolist() (numpy) or str(). Workers
+// exit when the driver is killed.
+// Arguments: function file, function name, workers, inputs file, results file.
+const mapDriver = `
+import concurrent.futures, importlib.util, json, os, sys, threading, time, traceback
+
+_fn = None
+
+def _load(path, name):
+    spec = importlib.util.spec_from_file_location('jumpboot_map_module', path)
+    module = importlib.util.module_from_spec(spec)
+    sys.modules[spec.name] = module
+    spec.loader.exec_module(module)
+    fn = getattr(module, name, None)
+    if not callable(fn):
+        raise AttributeError('%s has no function %s' % (path, name))
+    return fn
+
+def _watch_parent(parent):
+    while os.getppid() == parent:
+        time.sleep(1)
+    os._exit(1)
+
+def _init(path, name, parent):
+    global _fn
+    threading.Thread(target=_watch_parent, args=(parent,), daemon=True).start()
+    sys.path.insert(0, os.path.dirname(path))
+    _fn = _load(path, name)
+
+def _jsonable(value):
+    if hasattr(value, 'tolist'):
+        return value.tolist()
+    return str(value)
+
+def _call(item):
+    start = time.monotonic()
+    try:
+        value = json.dumps(_fn(item), default=_jsonable)
+        return {'status': 'succeeded', 'result': json.loads(value), 'seconds': time.monotonic() - start}
+    except BaseException as e:
+        return {'status': 'failed', 'error': '%s: %s' % (type(e).__name__, e),
+                'traceback': ''.join(traceback.format_exception(type(e), e, e.__traceback__.tb_next)),
+                'seconds': time.monotonic() - start}
+
+if __name__ == '__main__':
+    path, name, workers, inputs_path, results_path = os.path.abspath(sys.argv[1]), sys.argv[2], int(sys.argv[3]), sys.argv[4], sys.argv[5]
+    sys.path.insert(0, os.path.dirname(path))
+    _load(path, name)  # fail fast, before starting workers
+    with open(inputs_path) as f:
+        inputs = json.load(f)
+    with open(results_path, 'w') as out, concurrent.futures.ProcessPoolExecutor(
+            max_workers=workers, initializer=_init, initargs=(path, name, os.getpid())) as pool:
+        futures = {pool.submit(_call, item): i for i, item in enumerate(inputs)}
+        for future in concurrent.futures.as_completed(futures):
+            try:
+                line = future.result()
+            except BaseException as e:
+                line = {'status': 'failed', 'error': 'worker process died: %s: %s' % (type(e).__name__, e)}
+            line['index'] = futures[future]
+            out.write(json.dumps(line) + '\n')
+            out.flush()
+`
+
+// MapOptions configures RunMap
+type MapOptions struct {
+	Function    string        // function called with each input (default DefaultMapFunction)
+	MaxWorkers  int           // worker processes, 0 = one per CPU; never more than the inputs
+	Timeout     time.Duration // for the whole map, 0 = no limit
+	OutputLines int           // trailing output lines kept (default DefaultMapOutputLines)
+}
+
+// MapItem is the outcome of the function for one input
+type MapItem struct {
+	Index           int             `json:"index"`
+	Status          string          `json:"status"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Traceback       string          `json:"traceback,omitempty"`
+	DurationSeconds float64         `json:"duration_seconds"`
+}
+
+// MapResult is the outcome of RunMap, with the items in input order
+type MapResult struct {
+	Function        string    `json:"function"`
+	Workers         int       `json:"workers"`
+	Succeeded       int       `json:"succeeded"`
+	Failed          int       `json:"failed"`
+	TimedOut        bool      `json:"timed_out,omitempty"`
+	Items           []MapItem `json:"items"`
+	Output          string    `json:"output"` // printed by the function file and its calls
+	OutputTruncated bool      `json:"output_truncated,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// RunMap calls a function defined in a workspace Python file once per input, in
+// parallel worker processes of the environment, and returns each call's JSON result
+// or error. A call that raises fails its item, not the map. When the timeout expires
+// the items not yet finished are reported as failed; cancelling ctx fails the map.
+func (m *Manager) RunMap(ctx context.Context, envID, scriptPath string, inputs []json.RawMessage, opts MapOptions) (*MapResult, error) {
+	if len(inputs) == 0 {
+		return nil, WithErrorCode(CodeInvalidArgument, errors.New("at least one input is required"))
+	}
+	if len(inputs) > MaxMapInputs {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("at most %d inputs are allowed", MaxMapInputs))
+	}
+	if opts.Function == "" {
+		opts.Function = DefaultMapFunction
+	}
+	if opts.OutputLines <= 0 {
+		opts.OutputLines = DefaultMapOutputLines
+	}
+	workers := opts.MaxWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, MaxMapWorkers, len(inputs))
+
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	script, err := safeJoinPath(env.WorkspaceDir, scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(script); err != nil {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("script not found: %s", scriptPath))
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// The driver, the inputs and the results live in a temporary directory
+	tmpDir, err := os.MkdirTemp("", "run-map-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	driver := filepath.Join(tmpDir, "map.py")
+	if err := os.WriteFile(driver, []byte(mapDriver), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write map driver: %w", err)
+	}
+	inputsPath := filepath.Join(tmpDir, "inputs.json")
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid inputs: %w", err))
+	}
+	if err := os.WriteFile(inputsPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write inputs: %w", err)
+	}
+	resultsPath := filepath.Join(tmpDir, "results.jsonl")
+
+	runCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	cmd := commandContext(runCtx, env.Env.PythonPath, driver, script, opts.Function, strconv.Itoa(workers), inputsPath, resultsPath)
+	cmd.Dir = env.WorkspaceDir
+	cmd.Env = env.appendVars(os.Environ())
+	output, err := m.runIsolated(runCtx, env, cmd, sandboxMount{path: tmpDir, writable: true})
+	timedOut := errors.Is(err, ErrCancelled) && ctx.Err() == nil
+	if err != nil && !timedOut {
+		if errors.Is(err, ErrCancelled) {
+			return nil, err
+		}
+		return nil, WithErrorCode(CodeExecFailed, fmt.Errorf("map failed: %w\nOutput: %s", err, output))
+	}
+
+	result := &MapResult{
+		Function:        opts.Function,
+		Workers:         workers,
+		TimedOut:        timedOut,
+		Items:           make([]MapItem, len(inputs)),
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	result.Output, result.OutputTruncated = tailLines(output, opts.OutputLines)
+	finished, err := readMapResults(resultsPath, result.Items)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Items {
+		item := &result.Items[i]
+		item.Index = i
+		if !finished[i] {
+			item.Status = MapFailed
+			item.Error = "not finished: the map stopped"
+			if timedOut {
+				item.Error = fmt.Sprintf("not finished: timed out after %s", opts.Timeout)
+			}
+		}
+		if item.Status == MapSucceeded {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// readMapResults fills items from the lines the map driver wrote and reports which
+// items finished. A line cut short by a killed driver is ignored.
+func readMapResults(path string, items []MapItem) ([]bool, error) {
+	finished := make([]bool, len(items))
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return finished, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map results: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 256<<20)
+	for scanner.Scan() {
+		var line struct {
+			Index     int             `json:"index"`
+			Status    string          `json:"status"`
+			Result    json.RawMessage `json:"result"`
+			Error     string          `json:"error"`
+			Traceback string          `json:"traceback"`
+			Seconds   float64         `json:"seconds"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil || line.Index < 0 || line.Index >= len(items) {
+			continue
+		}
+		items[line.Index] = MapItem{
+			Status:          line.Status,
+			Result:          line.Result,
+			Error:           line.Error,
+			Traceback:       line.Traceback,
+			DurationSeconds: line.Seconds,
+		}
+		finished[line.Index] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read map results: %w", err)
+	}
+	return finished, nil
+}
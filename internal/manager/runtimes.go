@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RuntimeNodeJS provisions Node.js, with npm and npx, next to Python
+const RuntimeNodeJS = "nodejs"
+
+// runtimeVersionTimeout bounds a runtime's --version call
+const runtimeVersionTimeout = 10 * time.Second
+
+// runtimeCommands lists, per runtime an environment can provision from conda-forge,
+// the executables whose versions EnvironmentInfo reports
+var runtimeCommands = map[string][]string{
+	RuntimeNodeJS: {"node", "npm"},
+}
+
+// parseRuntimes validates runtime requests such as "nodejs" or "nodejs=20" and
+// returns them as conda package specs
+func parseRuntimes(runtimes []string) ([]string, error) {
+	specs := make([]string, 0, len(runtimes))
+	for _, r := range runtimes {
+		r = trimSpace(r)
+		name, version, _ := strings.Cut(r, "=")
+		if _, ok := runtimeCommands[name]; !ok {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("unknown runtime %q (supported: %s)",
+				name, strings.Join(slices.Sorted(maps.Keys(runtimeCommands)), ", ")))
+		}
+		if version = trimSpace(version); version != "" && strings.ContainsAny(version, " \t;|&") {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid version for runtime %s: %q", name, version))
+		}
+		specs = append(specs, r)
+	}
+	return specs, nil
+}
+
+// micromambaFor returns the micromamba that installs conda packages into env. Venvs
+// have none of their own and use the one of the base they were created from.
+func (m *Manager) micromambaFor(env *ManagedEnvironment) (string, error) {
+	if env.Env.MicromambaPath != "" {
+		return env.Env.MicromambaPath, nil
+	}
+	if env.AdoptedPath != "" {
+		return "", fmt.Errorf("environment %s is adopted; conda packages cannot be installed into it", env.ID)
+	}
+	base, err := m.getOrCreateBase(env.PythonVer)
+	if err != nil {
+		return "", err
+	}
+	return base.MicromambaPath, nil
+}
+
+// installRuntimes installs runtime conda specs from conda-forge into env's prefix,
+// which puts their executables in the environment's bin directory
+func (m *Manager) installRuntimes(ctx context.Context, env *ManagedEnvironment, specs []string) error {
+	micromamba, err := m.micromambaFor(env)
+	if err != nil {
+		return err
+	}
+	args := append([]string{"install", "--no-rc", "-c", "conda-forge", "--prefix", env.Env.EnvPath, "-y"}, specs...)
+	if output, err := runCommand(ctx, commandContext(ctx, micromamba, args...)); err != nil {
+		return fmt.Errorf("failed to install runtimes %s: %w\nOutput: %s", strings.Join(specs, ", "), err, output)
+	}
+	return nil
+}
+
+// detectRuntimes returns the versions of the runtime executables found in env's bin
+// directories, e.g. {"node": "20.11.1", "npm": "10.2.4"}, or nil if there are none
+func detectRuntimes(ctx context.Context, env *ManagedEnvironment) map[string]string {
+	var versions map[string]string
+	for _, commands := range runtimeCommands {
+		for _, command := range commands {
+			var path string
+			for _, dir := range envBinDirs(env) {
+				if p, err := exec.LookPath(filepath.Join(dir, command)); err == nil {
+					path = p
+					break
+				}
+			}
+			if path == "" {
+				continue
+			}
+			// npm is a node script, so the environment's bin directory must be on PATH
+			vctx, cancel := context.WithTimeout(ctx, runtimeVersionTimeout)
+			cmd := commandContext(vctx, path, "--version")
+			cmd.Env = append(os.Environ(), pathVar(env))
+			output, err := cmd.Output()
+			cancel()
+			if err != nil {
+				continue
+			}
+			if versions == nil {
+				versions = make(map[string]string)
+			}
+			versions[command] = strings.TrimPrefix(trimSpace(string(output)), "v")
+		}
+	}
+	return versions
+}
+
+// refreshRuntimes records the runtime versions found in env
+func (env *ManagedEnvironment) refreshRuntimes(ctx context.Context) {
+	versions := detectRuntimes(ctx, env)
+	env.configMu.Lock()
+	env.runtimes = versions
+	env.configMu.Unlock()
+}
+
+// runtimesOf returns a copy of the runtime versions recorded for the environment
+func (env *ManagedEnvironment) runtimesOf() map[string]string {
+	env.configMu.RLock()
+	defer env.configMu.RUnlock()
+	return maps.Clone(env.runtimes)
+}
@@ -0,0 +1,258 @@
+package manager
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptySHA256 is the SHA-256 of an empty payload
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Client is a minimal S3 client: ListObjectsV2, GetObject and PutObject signed with
+// Signature Version 4. Requests are anonymous without an access key.
+type s3Client struct {
+	endpoint     string // S3-compatible service, addressed path-style ("" = AWS)
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// objectURL returns the URL of a bucket or object. AWS buckets are addressed
+// virtual-hosted style, except names with dots, which break TLS host matching.
+func (c *s3Client) objectURL(bucket, key string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: "https", RawQuery: canonicalQuery(query)}
+	objectPath := "/" + key
+	switch {
+	case c.endpoint != "":
+		base, _ := url.Parse(c.endpoint)
+		u.Scheme, u.Host = base.Scheme, base.Host
+		objectPath = strings.TrimSuffix(base.Path, "/") + "/" + bucket + objectPath
+	case strings.Contains(bucket, "."):
+		u.Host = "s3." + c.region + ".amazonaws.com"
+		objectPath = "/" + bucket + objectPath
+	default:
+		u.Host = bucket + ".s3." + c.region + ".amazonaws.com"
+	}
+	u.Path, u.RawPath = objectPath, s3Escape(objectPath, false)
+	return u
+}
+
+// do sends a signed request and returns the response if its status is 2xx
+func (c *s3Client) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("User-Agent", objectStoreAgent)
+	c.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 request failed: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	message := resp.Status
+	if xml.Unmarshal(data, &s3Err) == nil && s3Err.Code != "" {
+		message = fmt.Sprintf("%s: %s", s3Err.Code, s3Err.Message)
+	}
+	err = fmt.Errorf("S3 %s %s failed: %s", method, u.Path, message)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, WithErrorCode(CodeNotFound, err)
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return nil, WithErrorCode(CodePermissionDenied, err)
+	}
+	return nil, err
+}
+
+// sign adds Signature Version 4 headers to the request
+func (c *s3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+	if c.accessKey == "" {
+		return
+	}
+
+	// Host, Range and the x-amz- headers are signed
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	date := amzDate[:8]
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{date, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.accessKey, scope, signedHeaders, signature))
+}
+
+// list returns the objects below prefix, paging through ListObjectsV2
+func (c *s3Client) list(ctx context.Context, bucket, prefix string, limit int) ([]objectInfo, error) {
+	var objects []objectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, "", query), nil, 0, emptySHA256)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key  string `xml:"Key"`
+				Size int64  `xml:"Size"`
+				ETag string `xml:"ETag"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 listing: %w", err)
+		}
+		for _, obj := range page.Contents {
+			// The ETag is the MD5 of the content, except for multipart uploads
+			etag := strings.Trim(obj.ETag, `"`)
+			if len(etag) != 32 {
+				etag = ""
+			}
+			objects = append(objects, objectInfo{Key: obj.Key, Size: obj.Size, MD5: etag})
+		}
+		if len(objects) > limit {
+			return nil, WithErrorCode(CodeQuotaExceeded, fmt.Errorf("more than %d objects below %s; narrow the prefix", limit, prefix))
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// get writes an object's content to w
+func (c *s3Client) get(ctx context.Context, bucket, key string, w io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, key, nil), nil, 0, emptySHA256)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// put uploads a file as an object in one request (at most 5 GB)
+func (c *s3Client) put(ctx context.Context, bucket, key, file string, size int64) error {
+	payloadHash, err := fileSHA256(file)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	resp, err := c.do(ctx, http.MethodPut, c.objectURL(bucket, key, nil), f, size, payloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// canonicalQuery encodes query parameters sorted by name, as Signature Version 4
+// requires
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything but unreserved characters and, in paths, '/'
+func s3Escape(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !encodeSlash:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
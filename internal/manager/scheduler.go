@@ -0,0 +1,326 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits of the run history kept per schedule
+const (
+	ScheduleHistoryLen     = 20
+	ScheduleOutputMaxChars = 16 << 10
+)
+
+// Schedule run states
+const (
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+	ScheduleRunSkipped   = "skipped" // the previous run was still going
+)
+
+// ScheduleOptions configures CreateSchedule. Exactly one of Cron and Interval is set.
+type ScheduleOptions struct {
+	Name       string
+	ScriptPath string        // script relative to the workspace
+	Args       []string      // command-line arguments for the script
+	Cron       string        // five-field cron expression or @-macro, in server local time
+	Interval   time.Duration // fixed interval between runs
+	Timeout    time.Duration // per-run time limit (0 = none)
+}
+
+// ScheduleRun records one execution of a schedule
+type ScheduleRun struct {
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Status          string    `json:"status"`
+	Output          string    `json:"output,omitempty"` // tail of the script output
+	Error           string    `json:"error,omitempty"`
+}
+
+// Schedule runs a workspace script repeatedly in an environment
+type Schedule struct {
+	ID        string
+	EnvID     string
+	Owner     string
+	CreatedAt time.Time
+	opts      ScheduleOptions
+	cron      *cronSchedule
+
+	mu       sync.Mutex // protects the fields below
+	next     time.Time
+	running  bool
+	runs     int
+	failures int
+	skipped  int
+	history  []ScheduleRun // oldest first, at most ScheduleHistoryLen
+
+	ctx    context.Context // cancelled when the schedule is deleted
+	cancel context.CancelFunc
+}
+
+// ScheduleInfo is the serializable state of a schedule
+type ScheduleInfo struct {
+	ID              string        `json:"id"`
+	Name            string        `json:"name,omitempty"`
+	EnvID           string        `json:"env_id"`
+	ScriptPath      string        `json:"script_path"`
+	Args            []string      `json:"args,omitempty"`
+	Cron            string        `json:"cron,omitempty"`
+	IntervalSeconds float64       `json:"interval_seconds,omitempty"`
+	TimeoutSeconds  float64       `json:"timeout_seconds,omitempty"`
+	Owner           string        `json:"owner,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	NextRun         *time.Time    `json:"next_run,omitempty"`
+	Running         bool          `json:"running"`
+	Runs            int           `json:"runs"`
+	Failures        int           `json:"failures"`
+	Skipped         int           `json:"skipped"`
+	LastRun         *ScheduleRun  `json:"last_run,omitempty"`
+	History         []ScheduleRun `json:"history,omitempty"`
+}
+
+// info returns the serializable state of the schedule, with the run history if
+// withHistory is set
+func (s *Schedule) info(withHistory bool) *ScheduleInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := &ScheduleInfo{
+		ID:              s.ID,
+		Name:            s.opts.Name,
+		EnvID:           s.EnvID,
+		ScriptPath:      s.opts.ScriptPath,
+		Args:            s.opts.Args,
+		Cron:            s.opts.Cron,
+		IntervalSeconds: s.opts.Interval.Seconds(),
+		TimeoutSeconds:  s.opts.Timeout.Seconds(),
+		Owner:           s.Owner,
+		CreatedAt:       s.CreatedAt,
+		Running:         s.running,
+		Runs:            s.runs,
+		Failures:        s.failures,
+		Skipped:         s.skipped,
+	}
+	if !s.next.IsZero() {
+		next := s.next
+		info.NextRun = &next
+	}
+	if n := len(s.history); n > 0 {
+		last := s.history[n-1]
+		info.LastRun = &last
+	}
+	if withHistory {
+		info.History = append([]ScheduleRun(nil), s.history...)
+	}
+	return info
+}
+
+// nextAfter returns the time of the first run after t, or the zero time if the cron
+// expression never matches again
+func (s *Schedule) nextAfter(t time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.next(t)
+	}
+	return t.Add(s.opts.Interval)
+}
+
+// record appends a finished run to the history
+func (s *Schedule) record(run ScheduleRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.history = append(s.history, run)
+	if len(s.history) > ScheduleHistoryLen {
+		s.history = s.history[len(s.history)-ScheduleHistoryLen:]
+	}
+	switch run.Status {
+	case ScheduleRunSkipped:
+		s.skipped++
+	case ScheduleRunFailed:
+		s.runs++
+		s.failures++
+	default:
+		s.runs++
+	}
+}
+
+// CreateSchedule starts running a workspace script of an environment on a cron
+// expression or at a fixed interval. A run that is due while the previous one is still
+// going is skipped. Schedules live until deleted or until their environment is destroyed.
+func (m *Manager) CreateSchedule(ctx context.Context, envID string, opts ScheduleOptions) (*ScheduleInfo, error) {
+	if (opts.Cron == "") == (opts.Interval <= 0) {
+		return nil, fmt.Errorf("set exactly one of cron and interval_seconds")
+	}
+	if opts.ScriptPath == "" {
+		return nil, fmt.Errorf("script_path is required")
+	}
+
+	s := &Schedule{
+		ID:        uuid.New().String(),
+		EnvID:     envID,
+		Owner:     ownerFor(ctx),
+		CreatedAt: time.Now(),
+		opts:      opts,
+	}
+	if opts.Cron != "" {
+		cron, err := parseCron(opts.Cron)
+		if err != nil {
+			return nil, err
+		}
+		s.cron = cron
+	}
+	s.next = s.nextAfter(s.CreatedAt)
+	if s.next.IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", opts.Cron)
+	}
+
+	m.mu.Lock()
+	env, ok := m.environments[envID]
+	if !ok || !m.canAccess(ctx, env.Owner) {
+		m.mu.Unlock()
+		return nil, notFound("environment", envID)
+	}
+	if env.WorkspaceDir == "" {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if _, err := safeJoinPath(env.WorkspaceDir, opts.ScriptPath); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	// Runs are not tied to the request that created the schedule
+	s.ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	m.schedules[s.ID] = s
+	m.mu.Unlock()
+
+	go m.runSchedule(s)
+	return s.info(false), nil
+}
+
+// runSchedule fires the schedule at each due time until it is deleted
+func (m *Manager) runSchedule(s *Schedule) {
+	for {
+		s.mu.Lock()
+		next := s.next
+		s.mu.Unlock()
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.mu.Lock()
+		busy := s.running
+		s.running = !busy
+		s.next = s.nextAfter(time.Now())
+		s.mu.Unlock()
+
+		if busy {
+			s.record(ScheduleRun{StartedAt: time.Now(), Status: ScheduleRunSkipped})
+			continue
+		}
+		go m.executeSchedule(s)
+	}
+}
+
+// executeSchedule runs the script once and records the outcome
+func (m *Manager) executeSchedule(s *Schedule) {
+	ctx := s.ctx
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	run := ScheduleRun{StartedAt: time.Now(), Status: ScheduleRunSucceeded}
+	output, err := m.RunWorkspaceScript(ctx, s.EnvID, s.opts.ScriptPath, s.opts.Args)
+	run.DurationSeconds = time.Since(run.StartedAt).Seconds()
+	run.Output = tailString(output, ScheduleOutputMaxChars)
+	if err != nil {
+		run.Status = ScheduleRunFailed
+		if errors.Is(err, ErrCancelled) && s.ctx.Err() == nil {
+			err = fmt.Errorf("timed out after %s", s.opts.Timeout)
+		}
+		run.Error = tailString(err.Error(), ScheduleOutputMaxChars)
+	}
+
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+	if s.ctx.Err() == nil {
+		s.record(run)
+	}
+}
+
+// tailString returns the last max bytes of s
+func tailString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[len(s)-max:]
+}
+
+// ListSchedules returns the schedules visible to the caller in ctx, optionally only
+// those of one environment, oldest first
+func (m *Manager) ListSchedules(ctx context.Context, envID string) []*ScheduleInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*ScheduleInfo, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		if !m.canAccess(ctx, s.Owner) || (envID != "" && s.EnvID != envID) {
+			continue
+		}
+		result = append(result, s.info(false))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result
+}
+
+// GetSchedule returns a schedule with its run history
+func (m *Manager) GetSchedule(ctx context.Context, id string) (*ScheduleInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.schedules[id]
+	if !ok || !m.canAccess(ctx, s.Owner) {
+		return nil, notFound("schedule", id)
+	}
+	return s.info(true), nil
+}
+
+// DeleteSchedule stops a schedule, cancelling its run if one is in progress
+func (m *Manager) DeleteSchedule(ctx context.Context, id string) (*ScheduleInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.schedules[id]
+	if !ok || !m.canAccess(ctx, s.Owner) {
+		return nil, notFound("schedule", id)
+	}
+	s.cancel()
+	delete(m.schedules, id)
+	return s.info(true), nil
+}
+
+// deleteEnvironmentSchedules stops the schedules of an environment. Callers must hold m.mu.
+func (m *Manager) deleteEnvironmentSchedules(envID string) {
+	for id, s := range m.schedules {
+		if s.EnvID == envID {
+			s.cancel()
+			delete(m.schedules, id)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minSecretLength is the shortest secret value accepted; shorter values would redact
+// ordinary output
+const minSecretLength = 4
+
+// Secret sources
+const (
+	SecretFromConfig = "config" // the -secrets file
+	SecretFromTool   = "tool"   // set_secret
+)
+
+// SecretSpec is an entry of the -secrets file. Values are best read from the server's
+// environment with value_env.
+type SecretSpec struct {
+	Name     string `json:"name"`
+	Value    string `json:"value,omitempty"`
+	ValueEnv string `json:"value_env,omitempty"`
+}
+
+// SecretInfo describes a registered secret, as listed to clients (no value)
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type secret struct {
+	SecretInfo
+	value string
+}
+
+// secretStore holds the server's secrets and redacts their values from output
+type secretStore struct {
+	mu       sync.RWMutex
+	secrets  map[string]*secret
+	replacer *strings.Replacer // nil when there are no secrets
+}
+
+func newSecretStore() *secretStore {
+	return &secretStore{secrets: make(map[string]*secret)}
+}
+
+// value returns the value of a secret
+func (s *secretStore) value(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sec, ok := s.secrets[name]
+	if !ok {
+		return "", false
+	}
+	return sec.value, true
+}
+
+// set adds or replaces a secret
+func (s *secretStore) set(name, value, source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[name] = &secret{
+		SecretInfo: SecretInfo{Name: name, Source: source, UpdatedAt: time.Now()},
+		value:      value,
+	}
+	s.rebuild()
+}
+
+// remove deletes a secret and reports whether it existed
+func (s *secretStore) remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.secrets[name]; !ok {
+		return false
+	}
+	delete(s.secrets, name)
+	s.rebuild()
+	return true
+}
+
+// rebuild recreates the replacer after a change. Longer values come first so a secret
+// containing another is redacted as a whole. Values are also matched in their JSON
+// string form, since most output reaches clients inside JSON. Callers must hold s.mu.
+func (s *secretStore) rebuild() {
+	secrets := make([]*secret, 0, len(s.secrets))
+	for _, sec := range s.secrets {
+		secrets = append(secrets, sec)
+	}
+	slices.SortFunc(secrets, func(a, b *secret) int {
+		if n := len(b.value) - len(a.value); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var pairs []string
+	for _, sec := range secrets {
+		mask := "[REDACTED:" + sec.Name + "]"
+		pairs = append(pairs, sec.value, mask)
+		if quoted, _ := json.Marshal(sec.value); string(quoted[1:len(quoted)-1]) != sec.value {
+			pairs = append(pairs, string(quoted[1:len(quoted)-1]), mask)
+		}
+	}
+	s.replacer = nil
+	if len(pairs) > 0 {
+		s.replacer = strings.NewReplacer(pairs...)
+	}
+}
+
+// redact replaces every secret value in text with [REDACTED:<name>]
+func (s *secretStore) redact(text string) string {
+	s.mu.RLock()
+	replacer := s.replacer
+	s.mu.RUnlock()
+	if replacer == nil {
+		return text
+	}
+	return replacer.Replace(text)
+}
+
+// validateSecret checks a secret's name and value
+func validateSecret(name, value string) error {
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) {
+		return fmt.Errorf("invalid secret name %q (use letters, digits, '-', '_' and '.')", name)
+	}
+	if len(value) < minSecretLength {
+		return fmt.Errorf("secret %s: value must be at least %d characters to be redacted reliably", name, minSecretLength)
+	}
+	return nil
+}
+
+// LoadSecrets reads a JSON array of SecretSpec and returns the values by name
+func LoadSecrets(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	var specs []SecretSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		if _, ok := values[spec.Name]; ok {
+			return nil, fmt.Errorf("%s: duplicate secret %s", path, spec.Name)
+		}
+		value := spec.Value
+		if spec.ValueEnv != "" {
+			if value = os.Getenv(spec.ValueEnv); value == "" {
+				return nil, fmt.Errorf("%s: secret %s: environment variable %s is not set", path, spec.Name, spec.ValueEnv)
+			}
+		}
+		if err := validateSecret(spec.Name, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		values[spec.Name] = value
+	}
+	return values, nil
+}
+
+// AddSecrets registers secrets from the server configuration
+func (m *Manager) AddSecrets(values map[string]string) {
+	for name, value := range values {
+		m.secrets.set(name, value, SecretFromConfig)
+	}
+}
+
+// SetSecret adds or replaces a secret. Requires the admin token. Environments that
+// reference the secret get the new value in processes started afterwards.
+func (m *Manager) SetSecret(ctx context.Context, name, value string) (*SecretInfo, error) {
+	if err := m.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := validateSecret(name, value); err != nil {
+		return nil, err
+	}
+	m.secrets.set(name, value, SecretFromTool)
+
+	m.secrets.mu.RLock()
+	defer m.secrets.mu.RUnlock()
+	info := m.secrets.secrets[name].SecretInfo
+	return &info, nil
+}
+
+// DeleteSecret removes a secret. Requires the admin token. Environments keep their
+// references, which are left out of processes until the secret is set again.
+func (m *Manager) DeleteSecret(ctx context.Context, name string) error {
+	if err := m.checkAdmin(ctx); err != nil {
+		return err
+	}
+	if !m.secrets.remove(name) {
+		return notFound("secret", name)
+	}
+	return nil
+}
+
+// Secrets lists the registered secrets without their values
+func (m *Manager) Secrets() []SecretInfo {
+	m.secrets.mu.RLock()
+	defer m.secrets.mu.RUnlock()
+	infos := make([]SecretInfo, 0, len(m.secrets.secrets))
+	for _, sec := range m.secrets.secrets {
+		infos = append(infos, sec.SecretInfo)
+	}
+	slices.SortFunc(infos, func(a, b SecretInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+// RedactSecrets replaces the values of all registered secrets in text with
+// [REDACTED:<name>]
+func (m *Manager) RedactSecrets(text string) string {
+	return m.secrets.redact(text)
+}
+
+// checkSecretRefs validates variable-to-secret references
+func (m *Manager) checkSecretRefs(refs map[string]string) error {
+	for key, name := range refs {
+		if err := validateVarName(key); err != nil {
+			return err
+		}
+		if _, ok := m.secrets.value(name); !ok {
+			return WithErrorCode(CodeNotFound, fmt.Errorf("secret not found: %s (referenced by %s)", name, key))
+		}
+	}
+	return nil
+}
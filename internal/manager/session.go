@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"context"
+)
+
+// Caller identifies the MCP session on whose behalf an operation runs
+type Caller struct {
+	SessionID string
+	Admin     bool   // admins can see and manage resources owned by any session
+	Principal string // name of the role token used (empty without roles)
+	Role      string // role of that token
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context carrying the given caller
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller stored in ctx (the zero Caller if none)
+func CallerFromContext(ctx context.Context) Caller {
+	if ctx == nil {
+		return Caller{}
+	}
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+// SetSessionIsolation enables or disables session scoping. When enabled, environments,
+// REPLs and processes are only visible to the session that created them (and admins).
+func (m *Manager) SetSessionIsolation(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionIsolation = enabled
+}
+
+// SessionIsolation reports whether session scoping is enabled
+func (m *Manager) SessionIsolation() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sessionIsolation
+}
+
+// ownerFor returns the owner to record for a resource created by the caller in ctx
+func ownerFor(ctx context.Context) string {
+	return CallerFromContext(ctx).SessionID
+}
+
+// EnvironmentCreator returns the principal whose role token created an environment
+// (empty when it was created without roles)
+func (m *Manager) EnvironmentCreator(envID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	env, ok := m.environments[envID]
+	if !ok {
+		return "", notFound("environment", envID)
+	}
+	return env.CreatedBy, nil
+}
+
+// Kinds of resources whose environment ResourceEnvironment finds
+const (
+	ResourceREPL     = "REPL session"
+	ResourceProcess  = "process"
+	ResourceJob      = "job"
+	ResourceTerminal = "terminal"
+	ResourceDebug    = "debug session"
+)
+
+// ResourceEnvironment returns the environment a REPL session, process, job, terminal
+// or debug session belongs to. ok is false if there is no such resource or, for jobs,
+// the job runs against no environment.
+func (m *Manager) ResourceEnvironment(kind, id string) (envID string, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	switch kind {
+	case ResourceREPL:
+		if repl, found := m.replSessions[id]; found {
+			envID = repl.EnvID
+		}
+	case ResourceProcess:
+		if proc, found := m.spawnedProcesses[id]; found {
+			envID = proc.EnvID
+		}
+	case ResourceJob:
+		if job, found := m.jobs[id]; found {
+			envID = job.EnvID
+		}
+	case ResourceTerminal:
+		if term, found := m.terminals[id]; found {
+			envID = term.EnvID
+		}
+	case ResourceDebug:
+		if session, found := m.debugSessions[id]; found {
+			envID = session.EnvID
+		}
+	}
+	return envID, envID != ""
+}
+
+// canAccess reports whether the caller in ctx may use a resource with the given owner.
+// Callers must hold m.mu.
+func (m *Manager) canAccess(ctx context.Context, owner string) bool {
+	if !m.sessionIsolation || owner == "" {
+		return true
+	}
+	caller := CallerFromContext(ctx)
+	return caller.Admin || caller.SessionID == owner
+}
+
+// CheckEnvironmentAccess returns an error if the caller in ctx may not use the environment.
+// Inaccessible environments are reported as not found so their existence is not leaked.
+func (m *Manager) CheckEnvironmentAccess(ctx context.Context, envID string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	env, ok := m.environments[envID]
+	if !ok || !m.canAccess(ctx, env.Owner) {
+		return notFound("environment", envID)
+	}
+	return nil
+}
+
+// CheckREPLAccess returns an error if the caller in ctx may not use the REPL session
+func (m *Manager) CheckREPLAccess(ctx context.Context, id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	repl, ok := m.replSessions[id]
+	if !ok || !m.canAccess(ctx, repl.Owner) {
+		return notFound("REPL session", id)
+	}
+	return nil
+}
+
+// CheckProcessAccess returns an error if the caller in ctx may not use the process
+func (m *Manager) CheckProcessAccess(ctx context.Context, id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	proc, ok := m.spawnedProcesses[id]
+	if !ok || !m.canAccess(ctx, proc.Owner) {
+		return notFound("process", id)
+	}
+	return nil
+}
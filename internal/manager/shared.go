@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+// sharedWorkspaceDirName is the directory under the base directory holding shared workspaces
+const sharedWorkspaceDirName = "shared"
+
+// sharedWorkspaceNamePattern matches valid shared workspace names
+var sharedWorkspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// SharedWorkspace is a named workspace that several environments can attach to, for
+// example to run the same checkout under two Python versions. It lives outside every
+// environment and is removed when the last environment detaches, unless Persistent.
+type SharedWorkspace struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Attached   []string  `json:"attached"` // IDs of the environments using it
+	Persistent bool      `json:"persistent,omitempty"`
+	Owner      string    `json:"owner,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Removed    bool      `json:"removed,omitempty"` // deleted by the detach that returned it
+}
+
+// info returns a copy of the shared workspace safe to return to callers. Callers must
+// hold m.mu.
+func (s *SharedWorkspace) info() *SharedWorkspace {
+	info := *s
+	info.Attached = slices.Clone(s.Attached)
+	return &info
+}
+
+// AttachWorkspace makes the shared workspace name the workspace of an environment,
+// creating it if it does not exist. The environment's own workspace, if any, is kept
+// and comes back on detach. persistent, if set, changes whether the shared workspace
+// is kept when the last environment detaches; new ones are not.
+func (m *Manager) AttachWorkspace(ctx context.Context, envID, name string, persistent *bool) (*SharedWorkspace, error) {
+	if !sharedWorkspaceNamePattern.MatchString(name) {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid shared workspace name %q: use letters, digits, '.', '_' and '-'", name))
+	}
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if env.sharedWorkspace != "" && env.sharedWorkspace != name {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("environment %s is attached to shared workspace %s; detach it first", envID, env.sharedWorkspace))
+	}
+
+	shared, ok := m.sharedWorkspaces[name]
+	if ok && !m.canAccess(ctx, shared.Owner) {
+		return nil, notFound("shared workspace", name)
+	}
+	if !ok {
+		// A directory left by an earlier server is picked up again
+		dir := filepath.Join(m.baseDir, sharedWorkspaceDirName, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create shared workspace: %w", err)
+		}
+		shared = &SharedWorkspace{
+			Name:      name,
+			Path:      dir,
+			Owner:     ownerFor(ctx),
+			CreatedAt: time.Now(),
+		}
+		m.sharedWorkspaces[name] = shared
+	}
+	if persistent != nil {
+		shared.Persistent = *persistent
+	}
+
+	if env.sharedWorkspace != name {
+		shared.Attached = append(shared.Attached, envID)
+		env.sharedWorkspace = name
+		env.WorkspaceDir = shared.Path
+	}
+	return shared.info(), nil
+}
+
+// DetachWorkspace detaches an environment from its shared workspace and gives it back
+// its own workspace, if it had one. The shared workspace is deleted when no
+// environment is left attached, unless it is persistent.
+func (m *Manager) DetachWorkspace(envID string) (*SharedWorkspace, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if env.sharedWorkspace == "" {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("environment %s is not attached to a shared workspace", envID))
+	}
+	// The environment's mounts go with it
+	if err := m.removeMounts(env, env.WorkspaceDir); err != nil {
+		return nil, err
+	}
+	env.WorkspaceDir = ""
+	if own := filepath.Join(env.RootDir, "workspace"); isDir(own) {
+		env.WorkspaceDir = own
+	}
+	return m.releaseSharedWorkspace(env)
+}
+
+// releaseSharedWorkspace drops an environment's reference to its shared workspace,
+// deleting the workspace if it was the last one and it is not persistent. Callers
+// must hold m.mu.
+func (m *Manager) releaseSharedWorkspace(env *ManagedEnvironment) (*SharedWorkspace, error) {
+	shared, ok := m.sharedWorkspaces[env.sharedWorkspace]
+	env.sharedWorkspace = ""
+	if !ok {
+		return nil, nil
+	}
+	shared.Attached = slices.DeleteFunc(shared.Attached, func(id string) bool { return id == env.ID })
+	if len(shared.Attached) > 0 || shared.Persistent {
+		return shared.info(), nil
+	}
+
+	delete(m.sharedWorkspaces, shared.Name)
+	shared.Removed = true
+	if err := os.RemoveAll(shared.Path); err != nil {
+		return nil, fmt.Errorf("failed to remove shared workspace: %w", err)
+	}
+	return shared.info(), nil
+}
+
+// ListSharedWorkspaces returns the shared workspaces visible to the caller in ctx,
+// sorted by name
+func (m *Manager) ListSharedWorkspaces(ctx context.Context) []*SharedWorkspace {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*SharedWorkspace, 0, len(m.sharedWorkspaces))
+	for _, shared := range m.sharedWorkspaces {
+		if m.canAccess(ctx, shared.Owner) {
+			result = append(result, shared.info())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,423 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxEnvironmentSnapshots is how many snapshots an environment keeps; taking another
+// removes the oldest
+const MaxEnvironmentSnapshots = 20
+
+// snapshotDirName is the per-environment snapshot directory (a sibling of the
+// workspace). Each snapshot has a subdirectory; the wheels they pin share wheelsDirName.
+const snapshotDirName = ".snapshots"
+
+// Files of a snapshot
+const (
+	snapshotMetaFile      = "snapshot.json"
+	snapshotFreezeFile    = "freeze.json"      // freeze_environment JSON, conda packages included
+	snapshotRequireFile   = "requirements.txt" // pip freeze, what rollback installs
+	snapshotWorkspaceFile = "workspace.tar.gz"
+	wheelsDirName         = "wheels"
+)
+
+// EnvironmentSnapshot describes the recorded state of an environment
+type EnvironmentSnapshot struct {
+	ID             string    `json:"id"`
+	EnvID          string    `json:"env_id"`
+	Name           string    `json:"name,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	Packages       int       `json:"packages"`
+	WheelsCached   bool      `json:"wheels_cached"`            // every package can be reinstalled offline
+	WheelsWarning  string    `json:"wheels_warning,omitempty"` // why some were not cached
+	Workspace      bool      `json:"workspace"`
+	WorkspaceFiles int       `json:"workspace_files,omitempty"`
+	WorkspaceBytes int64     `json:"workspace_bytes,omitempty"`
+}
+
+// SnapshotOptions configures SnapshotEnvironment
+type SnapshotOptions struct {
+	Name             string // label shown with the snapshot
+	IncludeWorkspace bool   // archive the workspace too
+	SkipWheels       bool   // do not download wheels; rollback then needs the package index
+}
+
+// RollbackOptions configures RollbackEnvironment
+type RollbackOptions struct {
+	SkipWorkspace bool // keep the current workspace even if the snapshot has one
+}
+
+// RollbackResult describes what RollbackEnvironment changed
+type RollbackResult struct {
+	Snapshot          *EnvironmentSnapshot `json:"snapshot"`
+	Installed         []string             `json:"installed"` // pins reinstalled because they differed
+	Removed           []string             `json:"removed"`   // packages installed after the snapshot
+	Offline           bool                 `json:"offline"`   // installed from cached wheels only
+	WorkspaceRestored bool                 `json:"workspace_restored"`
+	WorkspaceTrash    *TrashEntry          `json:"workspace_trash,omitempty"` // the replaced workspace
+	DurationSeconds   float64              `json:"duration_seconds"`
+}
+
+// snapshotsDir returns the snapshot directory of an environment
+func snapshotsDir(env *ManagedEnvironment) string {
+	return filepath.Join(env.RootDir, snapshotDirName)
+}
+
+// pipFreeze returns the environment's pip freeze lines by normalized package name.
+// Editable installs are left out: they point at workspace sources, not versions.
+func pipFreeze(ctx context.Context, env *ManagedEnvironment) (map[string]string, error) {
+	output, err := commandContext(ctx, env.Env.PythonPath, "-m", "pip", "freeze", "--exclude-editable").Output()
+	if err != nil {
+		if err := checkCancelled(ctx); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	pins := make(map[string]string)
+	for _, line := range splitLines(string(output)) {
+		line = trimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, _, _ := strings.Cut(line, " @ ")
+		if req, err := ParseRequirement(name); err == nil {
+			name = req.Name
+		}
+		pins[NormalizePackageName(name)] = line
+	}
+	return pins, nil
+}
+
+// sortedPins returns the lines of pins in package name order
+func sortedPins(pins map[string]string) []string {
+	names := make([]string, 0, len(pins))
+	for name := range pins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = pins[name]
+	}
+	return lines
+}
+
+// SnapshotEnvironment records the environment's installed packages, and optionally
+// its workspace, so RollbackEnvironment can return to them. The wheels of the pinned
+// packages are downloaded into the environment's wheel cache, which makes the
+// rollback fast and independent of the package index; packages that cannot be
+// downloaded are reported in WheelsWarning and rolled back from the index.
+func (m *Manager) SnapshotEnvironment(ctx context.Context, envID string, opts SnapshotOptions) (*EnvironmentSnapshot, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &EnvironmentSnapshot{
+		ID:        uuid.New().String(),
+		EnvID:     envID,
+		Name:      opts.Name,
+		CreatedAt: time.Now().UTC(),
+	}
+	dir := filepath.Join(snapshotsDir(env), snap.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	if err := m.snapshotPackages(ctx, env, dir, snap, opts.SkipWheels); err != nil {
+		return nil, err
+	}
+	if opts.IncludeWorkspace {
+		// ArchiveWorkspace takes the environment lock itself
+		data, info, err := m.ArchiveWorkspace(ctx, envID)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, snapshotWorkspaceFile), data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write workspace archive: %w", err)
+		}
+		snap.Workspace = true
+		snap.WorkspaceFiles = info.Files
+		snap.WorkspaceBytes = info.Bytes
+	}
+
+	meta, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotMetaFile), meta, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	ok = true
+
+	snapshots, err := readSnapshots(env)
+	if err == nil && len(snapshots) > MaxEnvironmentSnapshots {
+		for _, old := range snapshots[:len(snapshots)-MaxEnvironmentSnapshots] {
+			os.RemoveAll(filepath.Join(snapshotsDir(env), old.ID))
+		}
+	}
+	return snap, nil
+}
+
+// snapshotPackages writes the package state of a snapshot to dir and caches the
+// wheels, holding the environment lock shared so no install runs meanwhile
+func (m *Manager) snapshotPackages(ctx context.Context, env *ManagedEnvironment, dir string, snap *EnvironmentSnapshot, skipWheels bool) error {
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	frozen, err := m.FreezeEnvironment(env.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotFreezeFile), []byte(frozen), 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	pins, err := pipFreeze(ctx, env)
+	if err != nil {
+		return err
+	}
+	requirements := filepath.Join(dir, snapshotRequireFile)
+	lines := sortedPins(pins)
+	if err := os.WriteFile(requirements, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	snap.Packages = len(lines)
+
+	switch {
+	case skipWheels:
+		snap.WheelsWarning = "wheels not cached (skip_wheels)"
+	case len(lines) == 0:
+		snap.WheelsCached = true
+	default:
+		// pip reuses the files already in the cache, so only new pins are downloaded
+		output, err := runPython(ctx, env, "-m", "pip", "download", "--no-deps", "--disable-pip-version-check",
+			"-d", filepath.Join(snapshotsDir(env), wheelsDirName), "-r", requirements)
+		if err != nil {
+			if err := checkCancelled(ctx); err != nil {
+				return err
+			}
+			snap.WheelsWarning = "some wheels could not be cached: " + lastLine(output)
+		} else {
+			snap.WheelsCached = true
+		}
+	}
+	return nil
+}
+
+// readSnapshots returns the snapshots of an environment, oldest first
+func readSnapshots(env *ManagedEnvironment) ([]*EnvironmentSnapshot, error) {
+	entries, err := os.ReadDir(snapshotsDir(env))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	var snapshots []*EnvironmentSnapshot
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == wheelsDirName {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(snapshotsDir(env), e.Name(), snapshotMetaFile))
+		if err != nil {
+			continue // not finished, or removed meanwhile
+		}
+		var snap EnvironmentSnapshot
+		if json.Unmarshal(data, &snap) == nil {
+			snapshots = append(snapshots, &snap)
+		}
+	}
+	slices.SortFunc(snapshots, func(a, b *EnvironmentSnapshot) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// ListSnapshots returns the snapshots of an environment, oldest first
+func (m *Manager) ListSnapshots(envID string) ([]*EnvironmentSnapshot, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := readSnapshots(env)
+	if err != nil {
+		return nil, err
+	}
+	if snapshots == nil {
+		snapshots = []*EnvironmentSnapshot{}
+	}
+	return snapshots, nil
+}
+
+// RollbackEnvironment returns an environment to a snapshot ("" = the latest): packages
+// installed since are uninstalled and those whose version changed are reinstalled at
+// the snapshot's pin, from the cached wheels when possible. If the snapshot includes
+// the workspace, the current workspace is moved to the trash and replaced. Conda
+// packages and editable installs are left as they are.
+func (m *Manager) RollbackEnvironment(ctx context.Context, envID, snapshotID string, opts RollbackOptions) (*RollbackResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := readSnapshots(env)
+	if err != nil {
+		return nil, err
+	}
+	var snap *EnvironmentSnapshot
+	switch {
+	case len(snapshots) == 0:
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("environment %s has no snapshots; take one with environment_snapshot", envID))
+	case snapshotID == "":
+		snap = snapshots[len(snapshots)-1]
+	default:
+		i := slices.IndexFunc(snapshots, func(s *EnvironmentSnapshot) bool { return s.ID == snapshotID })
+		if i < 0 {
+			return nil, notFound("snapshot", snapshotID)
+		}
+		snap = snapshots[i]
+	}
+	dir := filepath.Join(snapshotsDir(env), snap.ID)
+
+	start := time.Now()
+	result := &RollbackResult{Snapshot: snap}
+	if err := m.rollbackPackages(ctx, env, dir, result); err != nil {
+		return nil, err
+	}
+
+	if snap.Workspace && !opts.SkipWorkspace {
+		data, err := os.ReadFile(filepath.Join(dir, snapshotWorkspaceFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read workspace archive: %w", err)
+		}
+		if result.WorkspaceTrash, err = m.replaceWorkspace(envID, env); err != nil {
+			return nil, err
+		}
+		// ExtractWorkspace creates the workspace again and takes the lock itself
+		if _, err := m.ExtractWorkspace(ctx, envID, data); err != nil {
+			return nil, err
+		}
+		result.WorkspaceRestored = true
+	}
+	result.DurationSeconds = time.Since(start).Seconds()
+	return result, nil
+}
+
+// rollbackPackages makes the environment's pip packages match the snapshot in dir
+func (m *Manager) rollbackPackages(ctx context.Context, env *ManagedEnvironment, dir string, result *RollbackResult) error {
+	unlock, err := m.lockEnvironment(ctx, env, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(filepath.Join(dir, snapshotRequireFile))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	want := make(map[string]string)
+	for _, line := range splitLines(string(data)) {
+		if line = trimSpace(line); line == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(line, " @ ")
+		if req, err := ParseRequirement(name); err == nil {
+			name = req.Name
+		}
+		want[NormalizePackageName(name)] = line
+	}
+	have, err := pipFreeze(ctx, env)
+	if err != nil {
+		return err
+	}
+
+	result.Installed, result.Removed = []string{}, []string{}
+	changed := make(map[string]string)
+	for name, line := range want {
+		if have[name] != line {
+			changed[name] = line
+		}
+	}
+	result.Installed = sortedPins(changed)
+	for name := range have {
+		if _, ok := want[name]; !ok {
+			result.Removed = append(result.Removed, name)
+		}
+	}
+	slices.Sort(result.Removed)
+
+	if len(result.Removed) > 0 {
+		args := append([]string{"-m", "pip", "uninstall", "-y"}, result.Removed...)
+		if output, err := runPython(ctx, env, args...); err != nil {
+			return fmt.Errorf("failed to uninstall packages: %w\nOutput: %s", err, output)
+		}
+	}
+	if len(result.Installed) == 0 {
+		result.Offline = true
+		return nil
+	}
+
+	// The snapshot pins every package, so dependencies need no resolving
+	requirements := filepath.Join(dir, "rollback.txt")
+	if err := os.WriteFile(requirements, []byte(strings.Join(result.Installed, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write requirements: %w", err)
+	}
+	defer os.Remove(requirements)
+	install := []string{"-m", "pip", "install", "--no-deps", "--no-warn-script-location", "--disable-pip-version-check",
+		"--find-links", filepath.Join(snapshotsDir(env), wheelsDirName), "-r", requirements}
+
+	if _, err := runPython(ctx, env, append(install, "--no-index")...); err == nil {
+		result.Offline = true
+		return nil
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+	if output, err := runPython(ctx, env, install...); err != nil {
+		return fmt.Errorf("failed to reinstall packages: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
+
+// replaceWorkspace moves the workspace to the trash so a snapshot's workspace can be
+// extracted in its place. Shared workspaces and workspaces with mounts are refused.
+func (m *Manager) replaceWorkspace(envID string, env *ManagedEnvironment) (*TrashEntry, error) {
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	workspaceDir, shared := env.WorkspaceDir, env.sharedWorkspace
+	m.mu.RUnlock()
+	if shared != "" {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("environment %s uses shared workspace %s, which a rollback does not replace; pass skip_workspace", envID, shared))
+	}
+	if workspaceDir == "" {
+		return nil, nil
+	}
+	// Without a trash retention the workspace is deleted and entry is nil
+	entry, err := m.moveToTrash(env, workspaceDir, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace workspace: %w", err)
+	}
+	m.mu.Lock()
+	env.WorkspaceDir = ""
+	m.mu.Unlock()
+	return entry, nil
+}
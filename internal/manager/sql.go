@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sqlMarker prefixes the JSON printed by sqlScript
+const sqlMarker = "__JUMPBOOT_SQL__"
+
+// Defaults of ExecuteSQL
+const (
+	DefaultSQLDatabase = "scratch.duckdb" // database file in the workspace
+	DefaultSQLMaxRows  = 100
+	MaxSQLMaxRows      = 10000
+	sqlCellChars       = 1000 // longer values are cut
+)
+
+// sqlScript runs the SQL read from stdin against a DuckDB database and prints the
+// columns and rows of the last statement's result.
+// Arguments: database, max rows, read only ("1"/"0"), max cell characters.
+const sqlScript = `
+import json, sys
+import duckdb
+db, max_rows, read_only, max_chars = sys.argv[1], int(sys.argv[2]), sys.argv[3] == '1', int(sys.argv[4])
+sql = sys.stdin.read()
+
+def cell(v):
+    if v is None or isinstance(v, (bool, int, float)):
+        return v if not (isinstance(v, float) and v != v) else None
+    v = v if isinstance(v, str) else str(v)
+    return v[:max_chars] + '...' if len(v) > max_chars else v
+
+con = duckdb.connect(db, read_only=read_only)
+try:
+    cur = con.execute(sql)
+    result = {'columns': [], 'rows': []}
+    if cur.description is not None:
+        result['columns'] = [{'name': d[0], 'type': str(d[1])} for d in cur.description]
+        rows = cur.fetchmany(max_rows + 1)
+        result['truncated'] = len(rows) > max_rows
+        result['rows'] = [[cell(v) for v in row] for row in rows[:max_rows]]
+finally:
+    con.close()
+print(%q + json.dumps(result))
+`
+
+// SQLOptions configures ExecuteSQL
+type SQLOptions struct {
+	Database string // database file in the workspace ("" = DefaultSQLDatabase)
+	MaxRows  int    // rows returned (0 = DefaultSQLMaxRows, at most MaxSQLMaxRows)
+	ReadOnly bool   // open the database read-only
+}
+
+// SQLColumn is a column of a SQL result
+type SQLColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// SQLResult is the result of the last statement run by ExecuteSQL
+type SQLResult struct {
+	Database  string      `json:"database"`
+	Installed bool        `json:"installed,omitempty"` // duckdb was installed on demand
+	Columns   []SQLColumn `json:"columns"`
+	Rows      [][]any     `json:"rows"`
+	RowCount  int         `json:"row_count"`           // rows returned
+	Truncated bool        `json:"truncated,omitempty"` // the result had more than MaxRows rows
+}
+
+// ExecuteSQL runs SQL against a DuckDB database file in the environment's workspace,
+// installing duckdb into the environment if needed. Several statements separated by
+// semicolons may be given; the result is that of the last one. The query runs in the
+// workspace with the environment's sandbox and network policy, so relative paths such
+// as SELECT * FROM 'data/sales.parquet' name workspace files.
+func (m *Manager) ExecuteSQL(ctx context.Context, envID, sql string, opts SQLOptions) (*SQLResult, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.ReadOnly {
+		if err := env.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if opts.Database == "" {
+		opts.Database = DefaultSQLDatabase
+	}
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = DefaultSQLMaxRows
+	}
+	opts.MaxRows = min(opts.MaxRows, MaxSQLMaxRows)
+	database, err := safeJoinPath(env.WorkspaceDir, opts.Database)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(database); err != nil && opts.ReadOnly {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("database not found: %s", opts.Database))
+	}
+
+	result := &SQLResult{Database: workspaceRelative(env.WorkspaceDir, database)}
+	if _, err := runPython(ctx, env, "-c", "import duckdb"); err != nil {
+		if errors.Is(err, ErrCancelled) {
+			return nil, err
+		}
+		if err := m.InstallPackages(ctx, envID, []string{"duckdb"}, false); err != nil {
+			return nil, fmt.Errorf("failed to install duckdb: %w", err)
+		}
+		result.Installed = true
+	}
+
+	unlock, err := m.lockEnvironment(ctx, env, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	readOnly := "0"
+	if opts.ReadOnly {
+		readOnly = "1"
+	}
+	cmd := commandContext(ctx, env.Env.PythonPath, "-c", fmt.Sprintf(sqlScript, sqlMarker), database,
+		strconv.Itoa(opts.MaxRows), readOnly, strconv.Itoa(sqlCellChars))
+	cmd.Dir = env.WorkspaceDir
+	cmd.Env = env.appendVars(os.Environ())
+	cmd.Stdin = strings.NewReader(sql)
+	output, err := m.runIsolated(ctx, env, cmd)
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, WithErrorCode(CodeExecFailed, fmt.Errorf("SQL failed: %s", lastLine(output)))
+	}
+
+	if err := decodeMarkedJSON(output, sqlMarker, result); err != nil {
+		return nil, fmt.Errorf("SQL failed: %w", err)
+	}
+	result.RowCount = len(result.Rows)
+	return result, nil
+}
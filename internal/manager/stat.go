@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FileStat is the metadata of a workspace path
+type FileStat struct {
+	Path     string    `json:"path"`
+	IsDir    bool      `json:"is_dir"`
+	Size     int64     `json:"size"`
+	Mode     string    `json:"mode"` // e.g. -rw-r--r--
+	ModTime  time.Time `json:"mtime"`
+	Symlink  string    `json:"symlink,omitempty"` // target, if path is a symbolic link
+	Entries  *int      `json:"entries,omitempty"` // directories only
+	MIMEType string    `json:"mime_type,omitempty"`
+	Binary   bool      `json:"binary,omitempty"`
+	Lines    *int      `json:"lines,omitempty"` // text files only
+	SHA256   string    `json:"sha256,omitempty"`
+}
+
+// StatWorkspaceFile returns the size, modification time, mode and, for files, the MIME
+// type, sha256 and (for text) line count of a workspace path, reading the file once.
+// A file is text if its first 8 KB have no NUL byte. Symbolic links are described,
+// with their target, but not followed.
+func (m *Manager) StatWorkspaceFile(envID, path string) (*FileStat, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	file, err := safeJoinPath(env.WorkspaceDir, path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(file)
+	if err != nil {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("file not found: %s", path))
+	}
+
+	stat := &FileStat{
+		Path:    workspaceRelative(env.WorkspaceDir, file),
+		IsDir:   info.IsDir(),
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime().UTC(),
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		stat.Symlink, _ = os.Readlink(file)
+		return stat, nil
+	case info.IsDir():
+		entries, err := os.ReadDir(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+		stat.Entries = new(int)
+		*stat.Entries = len(entries)
+		return stat, nil
+	case !info.Mode().IsRegular():
+		return stat, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	lines, binary, last := 0, false, byte('\n')
+	buf := make([]byte, 64<<10)
+	for read := int64(0); ; {
+		n, err := f.Read(buf)
+		chunk := buf[:n]
+		if read < 8<<10 && bytes.IndexByte(chunk[:min(n, int(8<<10-read))], 0) >= 0 {
+			binary = true
+		}
+		hash.Write(chunk)
+		lines += bytes.Count(chunk, []byte{'\n'})
+		if n > 0 {
+			last = chunk[n-1]
+		}
+		read += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+	stat.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	stat.MIMEType = detectMIMEType(file)
+	stat.Binary = binary
+	if !binary {
+		if last != '\n' {
+			lines++ // a last line without a newline
+		}
+		stat.Lines = &lines
+	}
+	return stat, nil
+}
@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ServerStatus describes the server for server_status and the HTTP health probes
+type ServerStatus struct {
+	Version          string                  `json:"version,omitempty"`
+	Ready            bool                    `json:"ready"`
+	NotReadyReason   string                  `json:"not_ready_reason,omitempty"`
+	StartedAt        time.Time               `json:"started_at"`
+	UptimeSeconds    int64                   `json:"uptime_seconds"`
+	Environments     int                     `json:"environments"`
+	REPLs            int                     `json:"repls"`
+	Processes        int                     `json:"processes"` // running spawned processes
+	Terminals        int                     `json:"terminals"`
+	DebugSessions    int                     `json:"debug_sessions"`
+	Jobs             int                     `json:"jobs"` // running or queued background jobs
+	Schedules        int                     `json:"schedules"`
+	Capacity         *CapacityInfo           `json:"capacity"`
+	BaseEnvironments []BaseEnvironmentStatus `json:"base_environments"`
+	WarmPool         map[string]int          `json:"warm_pool,omitempty"` // ready pre-warmed venvs by Python version
+	Drain            *DrainInfo              `json:"drain,omitempty"`     // set while the server drains
+}
+
+// BaseEnvironmentStatus describes a cached base interpreter that new environments
+// are created from
+type BaseEnvironmentStatus struct {
+	PythonVersion string `json:"python_version"`
+	Path          string `json:"path"`
+	Loaded        bool   `json:"loaded"` // in use since the server started; otherwise only on disk
+}
+
+// SetVersion sets the server version reported by Status
+func (m *Manager) SetVersion(version string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.version = version
+}
+
+// Status reports the server's version, uptime, resource counts, capacity and base
+// environment cache. The server is ready unless it is draining, shutting down or its
+// base directory is unusable.
+func (m *Manager) Status() *ServerStatus {
+	m.mu.RLock()
+	status := &ServerStatus{
+		Version:       m.version,
+		StartedAt:     m.startedAt,
+		Environments:  len(m.environments),
+		REPLs:         len(m.replSessions),
+		Terminals:     len(m.terminals),
+		DebugSessions: len(m.debugSessions),
+		Schedules:     len(m.schedules),
+	}
+	status.Capacity, _ = m.capacity()
+	for _, proc := range m.spawnedProcesses {
+		proc.outputMu.RLock()
+		if !proc.exited {
+			status.Processes++
+		}
+		proc.outputMu.RUnlock()
+	}
+	status.Jobs = m.runningJobs()
+	loaded := make(map[string]string, len(m.baseEnvironments))
+	for version, env := range m.baseEnvironments {
+		loaded[version] = env.EnvPath
+	}
+	shutdown := m.shutdown
+	m.mu.RUnlock()
+
+	status.UptimeSeconds = int64(time.Since(status.StartedAt).Seconds())
+	status.BaseEnvironments = m.baseEnvironmentStatus(loaded)
+	status.WarmPool = m.WarmPool()
+	status.Drain = m.DrainStatus()
+
+	status.Ready = true
+	switch {
+	case shutdown:
+		status.Ready, status.NotReadyReason = false, "shutting down"
+	case status.Drain != nil:
+		status.Ready, status.NotReadyReason = false, "draining"
+	case !isDir(m.baseDir):
+		status.Ready, status.NotReadyReason = false, "base directory "+m.baseDir+" is missing"
+	}
+	return status
+}
+
+// baseEnvironmentStatus lists the base environments in use, whose paths loaded holds
+// by Python version, and those that earlier runs left on disk
+func (m *Manager) baseEnvironmentStatus(loaded map[string]string) []BaseEnvironmentStatus {
+	bases := []BaseEnvironmentStatus{}
+	for version, path := range loaded {
+		bases = append(bases, BaseEnvironmentStatus{PythonVersion: version, Path: path, Loaded: true})
+	}
+	entries, _ := os.ReadDir(filepath.Join(m.baseDir, "bases"))
+	for _, entry := range entries {
+		version, ok := strings.CutPrefix(entry.Name(), "base_")
+		if !ok || !entry.IsDir() {
+			continue
+		}
+		if _, inUse := loaded[version]; !inUse {
+			bases = append(bases, BaseEnvironmentStatus{PythonVersion: version, Path: filepath.Join(m.baseDir, "bases", entry.Name())})
+		}
+	}
+	slices.SortFunc(bases, func(a, b BaseEnvironmentStatus) int { return strings.Compare(a.PythonVersion, b.PythonVersion) })
+	return bases
+}
@@ -0,0 +1,518 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Terminal defaults
+const (
+	terminalCols       = 120
+	terminalRows       = 40
+	terminalBufferSize = 1 << 20                // output kept for unread data
+	terminalIdleWait   = 250 * time.Millisecond // output is considered settled after this quiet period
+	terminalStartWait  = 3 * time.Second        // how long to wait for the shell's first prompt
+	terminalTerm       = "dumb"                 // TERM unless the caller asks for another
+	maxTerminalSize    = 1000                   // columns or rows
+)
+
+var (
+	// terminalEscape matches the escape sequences terminal programs write: CSI (colours,
+	// cursor movement, modes), OSC (window titles, ended by BEL or ST), character set
+	// selection and the remaining two-byte sequences
+	terminalEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[()*+].|\x1b[@-Z\\^_a-z0-9=>]`)
+	// partialEscape matches an escape sequence cut off at the end of the output
+	partialEscape = regexp.MustCompile(`^\x1b(?:\[[0-?]*[ -/]*|\][^\x07\x1b]*\x1b?|[()*+])?$`)
+	// terminalControl matches the control characters left after escapes are removed,
+	// except tab, newline and carriage return
+	terminalControl = regexp.MustCompile(`[\x00-\x08\x0b\x0c\x0e-\x1f\x7f]`)
+)
+
+// ManagedTerminal is a persistent interactive shell in an environment's workspace
+type ManagedTerminal struct {
+	ID        string
+	Name      string
+	EnvID     string
+	Shell     string
+	Owner     string
+	PTY       bool // false when the shell is connected through plain pipes
+	Term      string
+	StartTime time.Time
+
+	cmd  *exec.Cmd
+	tree *processTree // the shell and the processes started from it
+	tty  io.ReadWriteCloser
+	done chan struct{}
+
+	mu         sync.Mutex // protects the fields below
+	buf        []byte     // buffered output, starting at absolute offset base
+	base       int64
+	readPos    int64     // absolute offset of the first unread byte
+	lastOutput time.Time // when output was last received
+	lastInput  time.Time // when input was last sent
+	exited     bool
+	exitCode   int
+	cols, rows int
+	changed    chan struct{} // closed and replaced whenever output arrives or the shell exits
+}
+
+// TerminalOptions configure a new terminal. Zero fields take the defaults.
+type TerminalOptions struct {
+	Name  string
+	Shell string // default: $SHELL, /bin/bash or /bin/sh
+	Term  string // TERM for the shell, default "dumb"; e.g. "xterm-256color" for full-screen programs
+	Cols  int    // default 120
+	Rows  int    // default 40
+}
+
+// TerminalInfo is the serializable info about a terminal session
+type TerminalInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	EnvID     string    `json:"env_id"`
+	Shell     string    `json:"shell"`
+	PTY       bool      `json:"pty"`
+	Term      string    `json:"term"`
+	Cols      int       `json:"cols"`
+	Rows      int       `json:"rows"`
+	StartTime time.Time `json:"start_time"`
+	Running   bool      `json:"running"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+}
+
+// TerminalOutput is output read from a terminal
+type TerminalOutput struct {
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated,omitempty"` // unread output was dropped from the buffer
+	Running   bool   `json:"running"`
+	ExitCode  *int   `json:"exit_code,omitempty"`
+}
+
+// info returns the serializable info about the terminal
+func (t *ManagedTerminal) info() *TerminalInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info := &TerminalInfo{
+		ID:        t.ID,
+		Name:      t.Name,
+		EnvID:     t.EnvID,
+		Shell:     t.Shell,
+		PTY:       t.PTY,
+		Term:      t.Term,
+		Cols:      t.cols,
+		Rows:      t.rows,
+		StartTime: t.StartTime,
+		Running:   !t.exited,
+		Owner:     t.Owner,
+	}
+	if t.exited {
+		code := t.exitCode
+		info.ExitCode = &code
+	}
+	return info
+}
+
+// notify wakes readers waiting for output. Callers must hold t.mu.
+func (t *ManagedTerminal) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// captureOutput appends the shell's output to the buffer until the terminal closes
+func (t *ManagedTerminal) captureOutput() {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := t.tty.Read(chunk)
+		if n > 0 {
+			t.mu.Lock()
+			t.buf = append(t.buf, chunk[:n]...)
+			if over := len(t.buf) - terminalBufferSize; over > 0 {
+				t.buf = t.buf[over:]
+				t.base += int64(over)
+			}
+			t.lastOutput = time.Now()
+			t.notify()
+			t.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// waitSettled waits up to timeout for unread output produced after the last input to
+// arrive and stop changing, or for the shell to exit
+func (t *ManagedTerminal) waitSettled(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		t.mu.Lock()
+		pending := t.base+int64(len(t.buf)) > t.readPos
+		responded := t.lastOutput.After(t.lastInput)
+		settled := pending && responded && time.Since(t.lastOutput) >= terminalIdleWait
+		exited := t.exited
+		changed := t.changed
+		t.mu.Unlock()
+
+		remaining := time.Until(deadline)
+		if settled || exited || remaining <= 0 {
+			return nil
+		}
+
+		wait := remaining
+		if pending && responded && wait > terminalIdleWait {
+			wait = terminalIdleWait
+		}
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return checkCancelled(ctx)
+		}
+	}
+}
+
+// CreateTerminal starts a shell rooted in the environment's workspace with the
+// environment activated (its bin directory first on PATH, VIRTUAL_ENV set)
+func (m *Manager) CreateTerminal(ctx context.Context, envID string, opts TerminalOptions) (*TerminalInfo, error) {
+	if opts.Cols == 0 {
+		opts.Cols = terminalCols
+	}
+	if opts.Rows == 0 {
+		opts.Rows = terminalRows
+	}
+	if err := checkTerminalSize(opts.Cols, opts.Rows); err != nil {
+		return nil, err
+	}
+	if opts.Term == "" {
+		opts.Term = terminalTerm
+	}
+	if strings.ContainsAny(opts.Term, "= \t\n\x00") {
+		return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid term: %q", opts.Term))
+	}
+
+	m.mu.RLock()
+	env, ok := m.environments[envID]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, notFound("environment", envID)
+	}
+	if env.isolated() {
+		return nil, ErrNotIsolated
+	}
+	if env.networkPolicy().Mode != NetworkAllow {
+		return nil, ErrNetworkRestricted
+	}
+
+	// The terminal starts in the workspace, so make sure it exists
+	workspace, err := m.CreateWorkspace(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	shell := opts.Shell
+	if shell == "" {
+		shell = defaultShell()
+	}
+	if err := m.checkCommand(shell); err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	name := opts.Name
+	if name == "" {
+		name = filepath.Base(shell)
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Dir = workspace.Path
+	cmd.Env = append(env.appendVars(os.Environ()),
+		pathVar(env),
+		"VIRTUAL_ENV="+env.Env.EnvPath,
+		"JUMPBOOT_ENV_ID="+envID,
+		"TERM="+opts.Term,
+		"PS1=$ ",
+	)
+
+	tty, isPTY, err := startWithPTY(cmd, opts.Cols, opts.Rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start terminal: %w", err)
+	}
+
+	managed := &ManagedTerminal{
+		ID:        id,
+		Name:      name,
+		EnvID:     envID,
+		Shell:     shell,
+		Owner:     ownerFor(ctx),
+		PTY:       isPTY,
+		Term:      opts.Term,
+		StartTime: time.Now(),
+		cmd:       cmd,
+		tree:      newProcessTree(cmd.Process),
+		tty:       tty,
+		done:      make(chan struct{}),
+		cols:      opts.Cols,
+		rows:      opts.Rows,
+		changed:   make(chan struct{}),
+	}
+
+	go managed.captureOutput()
+
+	// Monitor the shell in background
+	go func() {
+		err := cmd.Wait()
+		managed.tree.release()
+		managed.mu.Lock()
+		managed.exited = true
+		managed.exitCode = 0
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				managed.exitCode = exitErr.ExitCode()
+			} else {
+				managed.exitCode = -1
+			}
+		}
+		managed.notify()
+		managed.mu.Unlock()
+		close(managed.done)
+	}()
+
+	// Wait for the prompt: shells may discard input typed before they are ready
+	managed.waitSettled(ctx, terminalStartWait)
+
+	m.mu.Lock()
+	m.terminals[id] = managed
+	m.mu.Unlock()
+
+	return managed.info(), nil
+}
+
+// defaultShell returns the shell used for new terminals
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		if shell := os.Getenv("COMSPEC"); shell != "" {
+			return shell
+		}
+		return "cmd.exe"
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	for _, shell := range []string{"/bin/bash", "/bin/sh"} {
+		if _, err := os.Stat(shell); err == nil {
+			return shell
+		}
+	}
+	return "sh"
+}
+
+// getTerminal returns a terminal by ID
+func (m *Manager) getTerminal(id string) (*ManagedTerminal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	term, ok := m.terminals[id]
+	if !ok {
+		return nil, notFound("terminal", id)
+	}
+	return term, nil
+}
+
+// CheckTerminalAccess returns an error if the caller in ctx may not use the terminal
+func (m *Manager) CheckTerminalAccess(ctx context.Context, id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	term, ok := m.terminals[id]
+	if !ok || !m.canAccess(ctx, term.Owner) {
+		return notFound("terminal", id)
+	}
+	return nil
+}
+
+// SendTerminal writes input to the terminal's shell
+func (m *Manager) SendTerminal(id, input string) error {
+	term, err := m.getTerminal(id)
+	if err != nil {
+		return err
+	}
+
+	term.mu.Lock()
+	exited := term.exited
+	term.mu.Unlock()
+	if exited {
+		return fmt.Errorf("terminal has exited: %s", id)
+	}
+
+	term.mu.Lock()
+	term.lastInput = time.Now()
+	term.mu.Unlock()
+
+	if _, err := io.WriteString(term.tty, input); err != nil {
+		return fmt.Errorf("failed to write to terminal: %w", err)
+	}
+	return nil
+}
+
+// ReadTerminal returns output received since the previous read. It waits up to timeout
+// for output to arrive and settle (no new output for a short period), so the result of
+// a command sent just before is usually complete. Unless raw is set, the output is
+// converted to plain text by plainTerminalText.
+func (m *Manager) ReadTerminal(ctx context.Context, id string, timeout time.Duration, raw bool) (*TerminalOutput, error) {
+	term, err := m.getTerminal(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := term.waitSettled(ctx, timeout); err != nil {
+		return nil, err
+	}
+
+	term.mu.Lock()
+	defer term.mu.Unlock()
+
+	out := &TerminalOutput{Running: !term.exited}
+	if term.readPos < term.base {
+		out.Truncated = true
+		term.readPos = term.base
+	}
+	unread := term.buf[term.readPos-term.base:]
+	if raw {
+		out.Output = string(unread)
+		term.readPos += int64(len(unread))
+	} else {
+		text, n := plainTerminalText(unread, !term.exited)
+		out.Output = text
+		term.readPos += int64(n)
+	}
+	if term.exited {
+		code := term.exitCode
+		out.ExitCode = &code
+	}
+	return out, nil
+}
+
+// plainTerminalText removes escape sequences and control characters from terminal
+// output and applies carriage returns, so a progress bar redrawn in place reads as its
+// last state. It returns the text and the number of bytes of b consumed: while more
+// output can follow, an escape sequence or carriage return cut off at the end is left
+// for the next read.
+func plainTerminalText(b []byte, more bool) (string, int) {
+	n := len(b)
+	if more {
+		if i := bytes.LastIndexByte(b, 0x1b); i >= 0 && partialEscape.Match(b[i:]) {
+			n = i
+		}
+		if n > 0 && b[n-1] == '\r' {
+			n--
+		}
+	}
+	text := terminalEscape.ReplaceAllString(string(b[:n]), "")
+	text = terminalControl.ReplaceAllString(text, "")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if j := strings.LastIndexByte(line, '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n"), n
+}
+
+// checkTerminalSize validates a terminal's columns and rows
+func checkTerminalSize(cols, rows int) error {
+	if cols < 1 || cols > maxTerminalSize || rows < 1 || rows > maxTerminalSize {
+		return WithErrorCode(CodeInvalidArgument, fmt.Errorf("cols and rows must be between 1 and %d", maxTerminalSize))
+	}
+	return nil
+}
+
+// ResizeTerminal changes the window size of the terminal's PTY. The programs running
+// in it receive SIGWINCH and redraw for the new size.
+func (m *Manager) ResizeTerminal(id string, cols, rows int) (*TerminalInfo, error) {
+	if err := checkTerminalSize(cols, rows); err != nil {
+		return nil, err
+	}
+	term, err := m.getTerminal(id)
+	if err != nil {
+		return nil, err
+	}
+	if !term.PTY {
+		return nil, WithErrorCode(CodeInvalidArgument, errors.New("terminal has no PTY to resize; PTYs are only available on Linux"))
+	}
+
+	term.mu.Lock()
+	exited := term.exited
+	term.mu.Unlock()
+	if exited {
+		return nil, fmt.Errorf("terminal has exited: %s", id)
+	}
+
+	if err := resizePTY(term.tty, cols, rows); err != nil {
+		return nil, fmt.Errorf("failed to resize terminal: %w", err)
+	}
+	term.mu.Lock()
+	term.cols, term.rows = cols, rows
+	term.mu.Unlock()
+	return term.info(), nil
+}
+
+// ListTerminals returns all terminal sessions visible to the caller in ctx
+func (m *Manager) ListTerminals(ctx context.Context) []TerminalInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]TerminalInfo, 0, len(m.terminals))
+	for _, term := range m.terminals {
+		if !m.canAccess(ctx, term.Owner) {
+			continue
+		}
+		result = append(result, *term.info())
+	}
+	return result
+}
+
+// CloseTerminal kills the terminal's shell and removes the session
+func (m *Manager) CloseTerminal(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	term, ok := m.terminals[id]
+	if !ok {
+		return notFound("terminal", id)
+	}
+
+	term.close()
+	delete(m.terminals, id)
+	return nil
+}
+
+// close kills the shell and the processes started from it and releases the terminal
+func (t *ManagedTerminal) close() {
+	t.mu.Lock()
+	exited := t.exited
+	t.mu.Unlock()
+
+	if !exited && t.cmd.Process != nil {
+		t.tree.kill()
+		<-t.done
+	}
+	t.tty.Close()
+}
@@ -0,0 +1,209 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTrashRetention is how long deleted workspace content is kept by default
+const DefaultTrashRetention = 24 * time.Hour
+
+// trashDirName is the per-environment trash directory (a sibling of the workspace)
+const trashDirName = ".trash"
+
+// trashMetaFile holds the metadata of a trash entry
+const trashMetaFile = "entry.json"
+
+// TrashEntry describes deleted workspace content that can still be restored
+type TrashEntry struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"original_path"` // relative to the workspace ("" for the whole workspace)
+	IsDir        bool      `json:"is_dir"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// SetTrashRetention configures how long deleted workspace content is kept.
+// Zero disables the trash and deletes immediately.
+func (m *Manager) SetTrashRetention(retention time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.trashRetention = retention
+}
+
+// trashDir returns the trash directory of an environment
+func trashDir(env *ManagedEnvironment) string {
+	return filepath.Join(env.RootDir, trashDirName)
+}
+
+// moveToTrash moves a workspace path into the environment's trash.
+// relPath is the path relative to the workspace ("" for the workspace itself).
+func (m *Manager) moveToTrash(env *ManagedEnvironment, fullPath, relPath string) (*TrashEntry, error) {
+	m.mu.RLock()
+	retention := m.trashRetention
+	m.mu.RUnlock()
+
+	if err := m.checkNoMounts(env, fullPath); err != nil {
+		return nil, err
+	}
+	if retention <= 0 {
+		return nil, os.RemoveAll(fullPath)
+	}
+
+	m.purgeTrash(env)
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entry := &TrashEntry{
+		ID:           uuid.New().String(),
+		OriginalPath: relPath,
+		IsDir:        info.IsDir(),
+		DeletedAt:    now,
+		ExpiresAt:    now.Add(retention),
+	}
+
+	entryDir := filepath.Join(trashDir(env), entry.ID)
+	if err := os.MkdirAll(entryDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trash entry: %w", err)
+	}
+
+	if err := os.Rename(fullPath, filepath.Join(entryDir, "content")); err != nil {
+		os.RemoveAll(entryDir)
+		return nil, fmt.Errorf("failed to move to trash: %w", err)
+	}
+
+	data, _ := json.Marshal(entry)
+	if err := os.WriteFile(filepath.Join(entryDir, trashMetaFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write trash metadata: %w", err)
+	}
+
+	return entry, nil
+}
+
+// readTrash returns all trash entries of an environment, newest first
+func readTrash(env *ManagedEnvironment) []TrashEntry {
+	dirEntries, err := os.ReadDir(trashDir(env))
+	if err != nil {
+		return nil
+	}
+
+	var entries []TrashEntry
+	for _, d := range dirEntries {
+		data, err := os.ReadFile(filepath.Join(trashDir(env), d.Name(), trashMetaFile))
+		if err != nil {
+			continue
+		}
+		var entry TrashEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries
+}
+
+// purgeTrash permanently removes expired trash entries
+func (m *Manager) purgeTrash(env *ManagedEnvironment) {
+	now := time.Now()
+	for _, entry := range readTrash(env) {
+		if now.After(entry.ExpiresAt) {
+			os.RemoveAll(filepath.Join(trashDir(env), entry.ID))
+		}
+	}
+}
+
+// ListTrash returns the restorable trash entries of an environment
+func (m *Manager) ListTrash(envID string) ([]TrashEntry, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.purgeTrash(env)
+	entries := readTrash(env)
+	if entries == nil {
+		entries = []TrashEntry{}
+	}
+	return entries, nil
+}
+
+// RestoreTrash moves a trash entry back to its original location in the workspace.
+// A destroyed workspace is recreated when it is restored.
+func (m *Manager) RestoreTrash(envID, trashID string) (*TrashEntry, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	m.purgeTrash(env)
+
+	var entry *TrashEntry
+	for _, e := range readTrash(env) {
+		if e.ID == trashID {
+			entry = &e
+			break
+		}
+	}
+	if entry == nil {
+		return nil, notFound("trash entry", trashID)
+	}
+
+	workspaceDir := filepath.Join(env.RootDir, "workspace")
+	target := workspaceDir
+	if entry.OriginalPath != "" {
+		// Files go back to the workspace in use, which may be a shared one
+		m.mu.RLock()
+		current := env.WorkspaceDir
+		m.mu.RUnlock()
+		if current == "" {
+			return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+		}
+
+		target, err = safeJoinPath(current, entry.OriginalPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("restore target already exists: %s", entry.OriginalPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	entryDir := filepath.Join(trashDir(env), entry.ID)
+	if err := os.Rename(filepath.Join(entryDir, "content"), target); err != nil {
+		return nil, fmt.Errorf("failed to restore from trash: %w", err)
+	}
+	os.RemoveAll(entryDir)
+
+	if entry.OriginalPath == "" {
+		// An environment attached to a shared workspace gets its own back on detach
+		m.mu.Lock()
+		if env.sharedWorkspace == "" {
+			env.WorkspaceDir = workspaceDir
+		}
+		m.mu.Unlock()
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,226 @@
+package manager
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Limits of ListWorkspaceTree
+const (
+	DefaultTreeDepth   = 10
+	DefaultTreeEntries = 1000
+	MaxTreeEntries     = 10000
+)
+
+// treeIgnoredDirs are skipped by ListWorkspaceTree unless IncludeIgnored is set
+var treeIgnoredDirs = []string{".git", "node_modules", "__pycache__", ".venv", ".mypy_cache", ".pytest_cache", ".ruff_cache", ".ipynb_checkpoints"}
+
+// TreeOptions configures ListWorkspaceTree
+type TreeOptions struct {
+	MaxDepth       int      // directory levels below the listed one (0 = DefaultTreeDepth)
+	Include        []string // globs files must match; directories are always walked
+	Exclude        []string // globs of files and directories to leave out
+	IncludeIgnored bool     // walk treeIgnoredDirs too
+	MaxEntries     int      // entries, or files with Include (0 = DefaultTreeEntries, at most MaxTreeEntries)
+	Tree           bool     // return an indented text tree instead of entries
+}
+
+// WorkspaceTree is a recursive listing of a workspace directory
+type WorkspaceTree struct {
+	Path      string     `json:"path"`
+	Entries   []FileInfo `json:"entries,omitempty"`
+	Tree      string     `json:"tree,omitempty"`
+	Files     int        `json:"files"`
+	Dirs      int        `json:"dirs"`
+	Truncated bool       `json:"truncated,omitempty"` // MaxEntries was reached
+	Ignored   []string   `json:"ignored,omitempty"`   // skipped directories, relative to the workspace
+}
+
+// ListWorkspaceTree lists the workspace or a subdirectory recursively. Globs without a
+// slash match base names; globs with one match paths relative to the listed directory,
+// where ** matches any number of directories. With Include, only matching files and
+// the directories leading to them are listed. Entries are sorted by path.
+func (m *Manager) ListWorkspaceTree(envID, subpath string, opts TreeOptions) (*WorkspaceTree, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	for _, pattern := range slices.Concat(opts.Include, opts.Exclude) {
+		if !validGlob(pattern) {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid glob %q", pattern))
+		}
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultTreeDepth
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultTreeEntries
+	}
+	opts.MaxEntries = min(opts.MaxEntries, MaxTreeEntries)
+
+	root := env.WorkspaceDir
+	if subpath != "" {
+		if root, err = safeJoinPath(env.WorkspaceDir, subpath); err != nil {
+			return nil, err
+		}
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, WithErrorCode(CodeNotFound, fmt.Errorf("directory not found: %s", subpath))
+	}
+	// WalkDir does not follow a symbolic link, such as a mount, given as its root
+	walkRoot := root
+	if info, err := os.Lstat(root); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		walkRoot, _ = filepath.EvalSymlinks(root)
+	}
+
+	tree := &WorkspaceTree{Path: subpath}
+	var entries []FileInfo
+	listed := 0 // entries counted against MaxEntries
+	err = filepath.WalkDir(walkRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == walkRoot {
+			return nil
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(p, walkRoot+string(filepath.Separator)))
+		p = filepath.Join(root, rel)
+		depth := strings.Count(rel, "/") + 1
+		if d.IsDir() {
+			switch {
+			case !opts.IncludeIgnored && slices.Contains(treeIgnoredDirs, d.Name()):
+				tree.Ignored = append(tree.Ignored, workspaceRelative(env.WorkspaceDir, p))
+				return filepath.SkipDir
+			case matchAnyGlob(opts.Exclude, rel):
+				return filepath.SkipDir
+			}
+		} else if matchAnyGlob(opts.Exclude, rel) || (len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel)) {
+			return nil
+		}
+		if listed == opts.MaxEntries {
+			tree.Truncated = true
+			return filepath.SkipAll
+		}
+		if !d.IsDir() || len(opts.Include) == 0 {
+			listed++
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, FileInfo{
+			Name:  d.Name(),
+			Path:  workspaceRelative(env.WorkspaceDir, p),
+			IsDir: d.IsDir(),
+			Size:  info.Size(),
+		})
+		if d.IsDir() && depth >= opts.MaxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace: %w", err)
+	}
+	if len(opts.Include) > 0 {
+		entries = pruneEmptyDirs(entries)
+	}
+
+	for _, e := range entries {
+		if e.IsDir {
+			tree.Dirs++
+		} else {
+			tree.Files++
+		}
+	}
+	if opts.Tree {
+		tree.Tree = formatTree(entries, subpath)
+	} else {
+		tree.Entries = entries
+	}
+	return tree, nil
+}
+
+// validGlob reports whether pattern is a usable include or exclude glob
+func validGlob(pattern string) bool {
+	_, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), "")
+	return err == nil && pattern != ""
+}
+
+// matchAnyGlob reports whether rel, a slash-separated relative path, matches a glob
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+		} else if matchGlobPath(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobPath matches path segments against glob segments, where ** matches any
+// number of segments
+func matchGlobPath(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlobPath(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && matchGlobPath(pattern[1:], segments[1:])
+}
+
+// pruneEmptyDirs drops the directories of a sorted listing that contain no listed file
+func pruneEmptyDirs(entries []FileInfo) []FileInfo {
+	keep := make(map[string]bool)
+	for _, e := range entries {
+		if !e.IsDir {
+			for dir := path.Dir(filepath.ToSlash(e.Path)); dir != "." && dir != "/"; dir = path.Dir(dir) {
+				keep[dir] = true
+			}
+		}
+	}
+	return slices.DeleteFunc(entries, func(e FileInfo) bool {
+		return e.IsDir && !keep[filepath.ToSlash(e.Path)]
+	})
+}
+
+// formatTree renders a sorted listing as an indented tree, directories ending in /
+func formatTree(entries []FileInfo, subpath string) string {
+	var sb strings.Builder
+	root := "."
+	if subpath != "" {
+		root = filepath.ToSlash(filepath.Clean(subpath))
+	}
+	sb.WriteString(root + "/\n")
+	for _, e := range entries {
+		rel := filepath.ToSlash(e.Path)
+		if root != "." {
+			rel = strings.TrimPrefix(rel, root+"/")
+		}
+		sb.WriteString(strings.Repeat("  ", strings.Count(rel, "/")+1))
+		sb.WriteString(e.Name)
+		if e.IsDir {
+			sb.WriteString("/")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
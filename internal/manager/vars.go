@@ -0,0 +1,171 @@
+package manager
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// validateVarName rejects names that cannot be set in a process environment and the
+// variables the server itself sets for processes
+func validateVarName(name string) error {
+	switch {
+	case name == "" || strings.ContainsAny(name, "=\x00"):
+		return fmt.Errorf("invalid variable name %q", name)
+	case name == "PATH" || name == "VIRTUAL_ENV" || strings.HasPrefix(name, "JUMPBOOT_"):
+		return fmt.Errorf("variable %s is set by the server and cannot be overridden", name)
+	}
+	return nil
+}
+
+// setConfig replaces the environment's variables, secret references and entrypoints
+func (env *ManagedEnvironment) setConfig(vars, secretRefs map[string]string, entrypoints map[string]Entrypoint) {
+	env.configMu.Lock()
+	defer env.configMu.Unlock()
+	env.vars = maps.Clone(vars)
+	env.secretRefs = maps.Clone(secretRefs)
+	env.entrypoints = maps.Clone(entrypoints)
+}
+
+// resolvedVars returns the environment's variables with the current values of the
+// secrets it references. References to deleted secrets are left out. Callers must hold
+// env.configMu.
+func (env *ManagedEnvironment) resolvedVars() map[string]string {
+	resolved := maps.Clone(env.vars)
+	for key, name := range env.secretRefs {
+		if env.secrets == nil {
+			break
+		}
+		if value, ok := env.secrets.value(name); ok {
+			if resolved == nil {
+				resolved = make(map[string]string)
+			}
+			resolved[key] = value
+		}
+	}
+	return resolved
+}
+
+// appendVars adds the environment's variables to a command environment
+func (env *ManagedEnvironment) appendVars(environ []string) []string {
+	env.configMu.RLock()
+	defer env.configMu.RUnlock()
+	vars := env.resolvedVars()
+	for _, key := range slices.Sorted(maps.Keys(vars)) {
+		environ = append(environ, key+"="+vars[key])
+	}
+	return environ
+}
+
+// varsWith returns the environment's variables merged with extra, or nil if both are
+// empty; extra wins on conflicts
+func (env *ManagedEnvironment) varsWith(extra map[string]string) map[string]string {
+	env.configMu.RLock()
+	defer env.configMu.RUnlock()
+	merged := env.resolvedVars()
+	if len(merged) == 0 {
+		return extra
+	}
+	maps.Copy(merged, extra)
+	return merged
+}
+
+// VarsUpdate describes a change of an environment's variables
+type VarsUpdate struct {
+	Vars    map[string]string // variables to set
+	Secrets map[string]string // variables to set from server secrets, by secret name
+	Unset   []string          // variables to remove
+	Replace bool              // remove all existing variables first
+}
+
+// EnvironmentVarsInfo lists an environment's variables. Secret values are never
+// included.
+type EnvironmentVarsInfo struct {
+	EnvID          string            `json:"env_id"`
+	Vars           map[string]string `json:"vars"`
+	Secrets        map[string]string `json:"secrets"`                   // variable -> secret name
+	MissingSecrets []string          `json:"missing_secrets,omitempty"` // referenced secrets that were deleted
+}
+
+// SetEnvironmentVars updates the variables added to every process of an environment.
+// A variable is either a plain value or a secret reference; setting one form replaces
+// the other. Processes already running, including REPL interpreters, keep their
+// variables.
+func (m *Manager) SetEnvironmentVars(envID string, update VarsUpdate) (*EnvironmentVarsInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	for key := range update.Vars {
+		if err := validateVarName(key); err != nil {
+			return nil, err
+		}
+		if _, ok := update.Secrets[key]; ok {
+			return nil, fmt.Errorf("variable %s is given both a value and a secret", key)
+		}
+	}
+	if err := m.checkSecretRefs(update.Secrets); err != nil {
+		return nil, err
+	}
+
+	env.configMu.Lock()
+	if update.Replace {
+		env.vars, env.secretRefs = nil, nil
+	}
+	for key, value := range update.Vars {
+		env.vars = setVar(env.vars, key, value)
+		delete(env.secretRefs, key)
+	}
+	for key, name := range update.Secrets {
+		env.secretRefs = setVar(env.secretRefs, key, name)
+		delete(env.vars, key)
+	}
+	for _, key := range update.Unset {
+		delete(env.vars, key)
+		delete(env.secretRefs, key)
+	}
+	env.configMu.Unlock()
+
+	return m.EnvironmentVars(envID)
+}
+
+// EnvironmentVars returns the variables added to every process of an environment
+func (m *Manager) EnvironmentVars(envID string) (*EnvironmentVarsInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	env.configMu.RLock()
+	defer env.configMu.RUnlock()
+
+	info := &EnvironmentVarsInfo{
+		EnvID:   envID,
+		Vars:    maps.Clone(env.vars),
+		Secrets: maps.Clone(env.secretRefs),
+	}
+	if info.Vars == nil {
+		info.Vars = map[string]string{}
+	}
+	if info.Secrets == nil {
+		info.Secrets = map[string]string{}
+	}
+	for _, key := range slices.Sorted(maps.Keys(env.secretRefs)) {
+		if _, ok := m.secrets.value(env.secretRefs[key]); !ok {
+			info.MissingSecrets = append(info.MissingSecrets, env.secretRefs[key])
+		}
+	}
+	return info, nil
+}
+
+// setVar sets a key of a possibly nil map
+func setVar(vars map[string]string, key, value string) map[string]string {
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	vars[key] = value
+	return vars
+}
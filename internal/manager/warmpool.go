@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/richinsley/jumpboot"
+)
+
+// warmDir is the directory under the base directory that holds pre-warmed venvs
+const warmDir = "warm"
+
+// warmEnv is a venv created ahead of time, waiting to become an environment
+type warmEnv struct {
+	id  string // ID of the environment it becomes
+	dir string
+	env *jumpboot.PythonEnvironment
+}
+
+// SetWarmPool keeps size ready-to-assign venvs for each of the Python versions
+// (default DefaultPythonVersion), created in the background. CreateEnvironment hands
+// one out instead of creating a venv and replenishes the pool afterwards. Size 0
+// disables the pool. Venvs left over from an earlier run are removed.
+func (m *Manager) SetWarmPool(size int, versions []string) {
+	if len(versions) == 0 {
+		versions = []string{DefaultPythonVersion}
+	}
+	m.mu.Lock()
+	m.warmSize = size
+	m.warmVersions = versions
+	m.mu.Unlock()
+
+	os.RemoveAll(filepath.Join(m.baseDir, warmDir))
+	if size <= 0 {
+		return
+	}
+	for _, version := range versions {
+		go m.fillWarmPool(version)
+	}
+}
+
+// WarmPool returns the number of ready venvs by Python version, or nil when the pool
+// is disabled
+func (m *Manager) WarmPool() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.warmSize <= 0 {
+		return nil
+	}
+	ready := make(map[string]int, len(m.warmVersions))
+	for _, version := range m.warmVersions {
+		ready[version] = len(m.warmPool[version])
+	}
+	return ready
+}
+
+// takeWarm removes a ready venv of the Python version from the pool and starts
+// replenishing it. It returns nil when none is ready.
+func (m *Manager) takeWarm(version string) *warmEnv {
+	m.mu.Lock()
+	pool := m.warmPool[version]
+	if len(pool) == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	warm := pool[0]
+	m.warmPool[version] = pool[1:]
+	m.mu.Unlock()
+
+	go m.fillWarmPool(version)
+	return warm
+}
+
+// fillWarmPool creates venvs of the Python version until its pool is full, one at a
+// time. Only one fill runs per version; it stops early when the server shuts down or
+// drains, or when free disk space falls below the minimum for new environments.
+func (m *Manager) fillWarmPool(version string) {
+	m.mu.Lock()
+	if m.warmFilling[version] {
+		m.mu.Unlock()
+		return
+	}
+	m.warmFilling[version] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.warmFilling, version)
+		m.mu.Unlock()
+	}()
+
+	for {
+		m.mu.RLock()
+		full := len(m.warmPool[version]) >= m.warmSize
+		stopped := m.shutdown || m.draining.Load()
+		_, reason := m.capacity()
+		m.mu.RUnlock()
+		if full || stopped || reason == CapacityDiskSpace {
+			return
+		}
+
+		warm, err := m.createWarmEnv(version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to pre-warm a Python %s environment: %v\n", version, err)
+			return
+		}
+		m.mu.Lock()
+		if m.shutdown {
+			m.mu.Unlock()
+			os.RemoveAll(warm.dir)
+			return
+		}
+		m.warmPool[version] = append(m.warmPool[version], warm)
+		m.mu.Unlock()
+	}
+}
+
+// createWarmEnv creates a venv of the Python version for the pool
+func (m *Manager) createWarmEnv(version string) (*warmEnv, error) {
+	baseEnv, err := m.getOrCreateBase(version)
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.New().String()
+	dir := filepath.Join(m.baseDir, warmDir, id)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, err
+	}
+	env, err := jumpboot.CreateVenvEnvironment(baseEnv, dir, jumpboot.VenvOptions{}, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create venv: %w", err)
+	}
+	return &warmEnv{id: id, dir: dir, env: env}, nil
+}
+
+// clearWarmPool removes the venvs waiting in the pool. Callers must hold m.mu.
+func (m *Manager) clearWarmPool() {
+	for version, pool := range m.warmPool {
+		for _, warm := range pool {
+			os.RemoveAll(warm.dir)
+		}
+		delete(m.warmPool, version)
+	}
+}
@@ -0,0 +1,386 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits of workspace watches
+const (
+	DefaultWatchInterval = time.Second
+	MinWatchInterval     = 200 * time.Millisecond
+	MaxWatchWait         = 60 * time.Second // longest workspace_changes long poll
+	watchBufferLen       = 1000             // events kept per watch; older ones are dropped
+	maxWatches           = 64               // watches on the server
+	maxWatchedFiles      = 50000            // paths one watch keeps track of
+)
+
+// Kinds of workspace changes
+const (
+	WatchCreated  = "created"
+	WatchModified = "modified"
+	WatchDeleted  = "deleted"
+)
+
+// WatchEvent is a change found by a workspace watch
+type WatchEvent struct {
+	Seq   int64     `json:"seq"`
+	Type  string    `json:"type"`
+	Path  string    `json:"path"` // relative to the workspace
+	IsDir bool      `json:"is_dir,omitempty"`
+	Size  int64     `json:"size,omitempty"`
+	Time  time.Time `json:"time"` // when the change was seen
+}
+
+// WatchOptions configures WatchWorkspace
+type WatchOptions struct {
+	Paths    []string                                         // files and directories to watch ("" or none = the workspace)
+	Include  []string                                         // globs files must match, as in ListWorkspaceTree
+	Exclude  []string                                         // globs of files and directories to ignore
+	Interval time.Duration                                    // how often the paths are scanned (0 = DefaultWatchInterval)
+	Notify   func(watchID, envID string, events []WatchEvent) // called with each batch of changes, if set
+}
+
+// watchedFile is what a scan remembers of a path
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// Watch scans workspace paths for changes made by processes, jobs or tools
+type Watch struct {
+	ID        string
+	EnvID     string
+	Owner     string
+	CreatedAt time.Time
+	opts      WatchOptions
+	workspace string
+	roots     []string // absolute paths scanned
+
+	mu        sync.Mutex // protects the fields below
+	files     map[string]watchedFile
+	truncated bool // maxWatchedFiles was reached
+	seq       int64
+	events    []WatchEvent  // the latest, at most watchBufferLen
+	changed   chan struct{} // closed when events are added
+
+	ctx    context.Context // cancelled when the watch is removed
+	cancel context.CancelFunc
+}
+
+// WatchInfo is the serializable state of a watch
+type WatchInfo struct {
+	ID              string    `json:"watch_id"`
+	EnvID           string    `json:"env_id"`
+	Paths           []string  `json:"paths"`
+	Include         []string  `json:"include,omitempty"`
+	Exclude         []string  `json:"exclude,omitempty"`
+	IntervalSeconds float64   `json:"interval_seconds"`
+	Files           int       `json:"files"`               // paths being tracked
+	Truncated       bool      `json:"truncated,omitempty"` // more paths than a watch tracks
+	LastSeq         int64     `json:"last_seq"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// WatchChanges is a page of a watch's events
+type WatchChanges struct {
+	WatchID string       `json:"watch_id"`
+	Events  []WatchEvent `json:"events"`
+	Next    int64        `json:"next_since"`       // pass as since to get the following events
+	Missed  int64        `json:"missed,omitempty"` // events after since that were dropped from the buffer
+}
+
+// info returns the serializable state of the watch
+func (w *Watch) info() *WatchInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info := &WatchInfo{
+		ID:              w.ID,
+		EnvID:           w.EnvID,
+		Include:         w.opts.Include,
+		Exclude:         w.opts.Exclude,
+		IntervalSeconds: w.opts.Interval.Seconds(),
+		Files:           len(w.files),
+		Truncated:       w.truncated,
+		LastSeq:         w.seq,
+		CreatedAt:       w.CreatedAt,
+	}
+	for _, root := range w.roots {
+		info.Paths = append(info.Paths, workspaceRelative(w.workspace, root))
+	}
+	return info
+}
+
+// WatchWorkspace starts scanning workspace paths for created, modified and deleted
+// files. Changes are kept for WorkspaceChanges and passed to opts.Notify. Scanning is
+// done by polling, so it works on every platform and inside sandboxes, and sees
+// changes within one interval. Directories of treeIgnoredDirs are skipped. Watches
+// live until removed or until their environment is destroyed.
+func (m *Manager) WatchWorkspace(ctx context.Context, envID string, opts WatchOptions) (*WatchInfo, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWatchInterval
+	}
+	opts.Interval = max(opts.Interval, MinWatchInterval)
+	for _, pattern := range slices.Concat(opts.Include, opts.Exclude) {
+		if !validGlob(pattern) {
+			return nil, WithErrorCode(CodeInvalidArgument, fmt.Errorf("invalid glob %q", pattern))
+		}
+	}
+
+	m.mu.Lock()
+	env, ok := m.environments[envID]
+	if !ok || !m.canAccess(ctx, env.Owner) {
+		m.mu.Unlock()
+		return nil, notFound("environment", envID)
+	}
+	workspace := env.WorkspaceDir
+	count := len(m.watches)
+	m.mu.Unlock()
+	if workspace == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if count >= maxWatches {
+		return nil, WithErrorCode(CodeQuotaExceeded, fmt.Errorf("too many workspace watches (%d); remove some with workspace_unwatch", maxWatches))
+	}
+
+	w := &Watch{
+		ID:        uuid.New().String(),
+		EnvID:     envID,
+		Owner:     ownerFor(ctx),
+		CreatedAt: time.Now(),
+		opts:      opts,
+		workspace: workspace,
+		changed:   make(chan struct{}),
+	}
+	if len(opts.Paths) == 0 {
+		w.roots = []string{workspace}
+	}
+	for _, p := range opts.Paths {
+		if p == "" || p == "." {
+			w.roots = append(w.roots, workspace)
+			continue
+		}
+		root, err := safeJoinPath(workspace, p)
+		if err != nil {
+			return nil, err
+		}
+		w.roots = append(w.roots, root)
+	}
+	w.files, w.truncated = w.scan()
+
+	// The watch outlives the request that created it
+	w.ctx, w.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	m.mu.Lock()
+	m.watches[w.ID] = w
+	m.mu.Unlock()
+
+	go w.run()
+	return w.info(), nil
+}
+
+// run scans the watched paths every interval until the watch is removed
+func (w *Watch) run() {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files, truncated := w.scan()
+		w.mu.Lock()
+		events := diffWatchedFiles(w.files, files)
+		w.files, w.truncated = files, truncated
+		now := time.Now()
+		for i := range events {
+			w.seq++
+			events[i].Seq, events[i].Time = w.seq, now
+		}
+		if len(events) > 0 {
+			w.events = append(w.events, events...)
+			if len(w.events) > watchBufferLen {
+				w.events = slices.Clone(w.events[len(w.events)-watchBufferLen:])
+			}
+			close(w.changed)
+			w.changed = make(chan struct{})
+		}
+		w.mu.Unlock()
+
+		if len(events) > 0 && w.opts.Notify != nil {
+			w.opts.Notify(w.ID, w.EnvID, events)
+		}
+	}
+}
+
+// scan records the size and modification time of every watched path, by path
+// relative to the workspace
+func (w *Watch) scan() (map[string]watchedFile, bool) {
+	files := make(map[string]watchedFile)
+	truncated := false
+	for _, root := range w.roots {
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // deleted while scanning, or not created yet
+			}
+			if len(files) >= maxWatchedFiles {
+				truncated = true
+				return filepath.SkipAll
+			}
+			rel := workspaceRelative(w.workspace, p)
+			if d.IsDir() {
+				switch {
+				case p == root:
+					return nil
+				case slices.Contains(treeIgnoredDirs, d.Name()), matchAnyGlob(w.opts.Exclude, rel):
+					return filepath.SkipDir
+				}
+			} else if matchAnyGlob(w.opts.Exclude, rel) || (len(w.opts.Include) > 0 && !matchAnyGlob(w.opts.Include, rel)) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files[rel] = watchedFile{size: info.Size(), modTime: info.ModTime(), isDir: d.IsDir()}
+			return nil
+		})
+	}
+	return files, truncated
+}
+
+// diffWatchedFiles returns the changes between two scans, sorted by path. Directories
+// are reported when created or deleted, not when their content changes.
+func diffWatchedFiles(before, after map[string]watchedFile) []WatchEvent {
+	var events []WatchEvent
+	for p, now := range after {
+		was, ok := before[p]
+		switch {
+		case !ok || was.isDir != now.isDir:
+			if ok {
+				events = append(events, WatchEvent{Type: WatchDeleted, Path: p, IsDir: was.isDir})
+			}
+			events = append(events, WatchEvent{Type: WatchCreated, Path: p, IsDir: now.isDir, Size: fileSize(now)})
+		case !now.isDir && (was.size != now.size || !was.modTime.Equal(now.modTime)):
+			events = append(events, WatchEvent{Type: WatchModified, Path: p, Size: now.size})
+		}
+	}
+	for p, was := range before {
+		if _, ok := after[p]; !ok {
+			events = append(events, WatchEvent{Type: WatchDeleted, Path: p, IsDir: was.isDir})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	return events
+}
+
+// fileSize returns the size of a file, or 0 for a directory
+func fileSize(f watchedFile) int64 {
+	if f.isDir {
+		return 0
+	}
+	return f.size
+}
+
+// WorkspaceChanges returns the events of a watch after since (0 = all kept), at most
+// watchBufferLen. With wait, it blocks until there is an event or wait passes.
+func (m *Manager) WorkspaceChanges(ctx context.Context, watchID string, since int64, wait time.Duration) (*WatchChanges, error) {
+	m.mu.RLock()
+	w, ok := m.watches[watchID]
+	ok = ok && m.canAccess(ctx, w.Owner)
+	m.mu.RUnlock()
+	if !ok {
+		return nil, notFound("watch", watchID)
+	}
+	wait = min(wait, MaxWatchWait)
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		w.mu.Lock()
+		result := &WatchChanges{WatchID: w.ID, Events: []WatchEvent{}, Next: max(since, 0)}
+		for _, e := range w.events {
+			if e.Seq > since {
+				result.Events = append(result.Events, e)
+			}
+		}
+		if len(w.events) > 0 && w.events[0].Seq > since+1 {
+			result.Missed = w.events[0].Seq - since - 1
+		}
+		if n := len(result.Events); n > 0 {
+			result.Next = result.Events[n-1].Seq
+		} else if since > w.seq {
+			result.Next = w.seq
+		}
+		changed := w.changed
+		w.mu.Unlock()
+
+		if len(result.Events) > 0 || timeout == nil {
+			return result, nil
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			return result, nil
+		case <-w.ctx.Done():
+			return result, nil
+		case <-ctx.Done():
+			return nil, ErrCancelled
+		}
+	}
+}
+
+// ListWatches returns the watches visible to the caller in ctx, optionally only those
+// of one environment, oldest first
+func (m *Manager) ListWatches(ctx context.Context, envID string) []*WatchInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*WatchInfo, 0, len(m.watches))
+	for _, w := range m.watches {
+		if !m.canAccess(ctx, w.Owner) || (envID != "" && w.EnvID != envID) {
+			continue
+		}
+		result = append(result, w.info())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result
+}
+
+// Unwatch stops a watch and discards its events
+func (m *Manager) Unwatch(ctx context.Context, watchID string) (*WatchInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.watches[watchID]
+	if !ok || !m.canAccess(ctx, w.Owner) {
+		return nil, notFound("watch", watchID)
+	}
+	w.cancel()
+	delete(m.watches, watchID)
+	return w.info(), nil
+}
+
+// deleteEnvironmentWatches stops the watches of an environment. Callers must hold m.mu.
+func (m *Manager) deleteEnvironmentWatches(envID string) {
+	for id, w := range m.watches {
+		if w.EnvID == envID {
+			w.cancel()
+			delete(m.watches, id)
+		}
+	}
+}
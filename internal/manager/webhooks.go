@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook events
+const (
+	EventJobFinished   = "job.finished"
+	EventProcessExited = "process.exited"
+)
+
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 3
+	webhookTailSize = 20 // output lines included in process.exited payloads
+)
+
+// Webhook is a callback URL notified when a job finishes or a process exits.
+// When Secret is set, deliveries carry an HMAC-SHA256 signature of the body in
+// the X-Jumpboot-Signature header ("sha256=<hex>").
+type Webhook struct {
+	URL    string
+	Secret string
+}
+
+// WebhookEvent is the JSON body posted to a webhook
+type WebhookEvent struct {
+	Event      string       `json:"event"`
+	Timestamp  time.Time    `json:"timestamp"`
+	Job        *JobInfo     `json:"job,omitempty"`
+	Process    *ProcessInfo `json:"process,omitempty"`
+	OutputTail []string     `json:"output_tail,omitempty"`
+}
+
+// SetWebhookPolicy restricts webhook URLs to the given prefixes (empty = any http(s) URL)
+// and sets the secret used to sign deliveries that do not provide their own.
+func (m *Manager) SetWebhookPolicy(allow []string, secret string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhookAllow = allow
+	m.webhookSecret = secret
+}
+
+// checkWebhook validates a webhook against the server policy and fills in the
+// default secret. A nil webhook is valid.
+func (m *Manager) checkWebhook(hook *Webhook) (*Webhook, error) {
+	if hook == nil {
+		return nil, nil
+	}
+
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %s", hook.URL)
+	}
+
+	m.mu.RLock()
+	allow, secret := m.webhookAllow, m.webhookSecret
+	m.mu.RUnlock()
+
+	if len(allow) > 0 {
+		allowed := false
+		for _, prefix := range allow {
+			if strings.HasPrefix(hook.URL, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("webhook URL not allowed by server policy: %s", hook.URL)
+		}
+	}
+
+	checked := *hook
+	if checked.Secret == "" {
+		checked.Secret = secret
+	}
+	return &checked, nil
+}
+
+// fireWebhook delivers event to hook in the background, retrying failed deliveries.
+// Secret values are redacted from the body.
+func (m *Manager) fireWebhook(hook *Webhook, event WebhookEvent) {
+	if hook == nil {
+		return
+	}
+
+	event.Timestamp = time.Now()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	body := []byte(m.RedactSecrets(string(data)))
+
+	go func() {
+		deliveryID := uuid.New().String()
+		backoff := time.Second
+		for attempt := 1; ; attempt++ {
+			err := postWebhook(hook, event.Event, deliveryID, body)
+			if err == nil {
+				return
+			}
+			if attempt == webhookAttempts {
+				fmt.Fprintf(os.Stderr, "Webhook %s for %s failed: %v\n", hook.URL, event.Event, err)
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+// postWebhook makes a single delivery attempt
+func postWebhook(hook *Webhook, event, deliveryID string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "jumpboot-mcp")
+	req.Header.Set("X-Jumpboot-Event", event)
+	req.Header.Set("X-Jumpboot-Delivery", deliveryID)
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Jumpboot-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
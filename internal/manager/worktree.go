@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitWorktreeInfo describes a git worktree added to the workspace
+type GitWorktreeInfo struct {
+	RepoDir      string `json:"repo_dir"`
+	Branch       string `json:"branch,omitempty"` // empty for a detached checkout
+	Commit       string `json:"commit"`
+	WorktreePath string `json:"worktree_path"`
+	DirName      string `json:"dir_name"`
+}
+
+// GitWorktreeAdd checks out another branch of a repository already cloned into the
+// workspace as a separate directory that shares the clone's object store.
+// ref is the branch, tag or commit to check out; a remote branch name such as "fix"
+// creates a local tracking branch. If newBranch is set, that branch is created at ref
+// (default HEAD) and checked out. dirName defaults to "<repo>-<branch>".
+func (m *Manager) GitWorktreeAdd(ctx context.Context, envID, repoDir, ref, newBranch, dirName string) (*GitWorktreeInfo, error) {
+	env, err := m.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.checkWritable(); err != nil {
+		return nil, err
+	}
+	if env.WorkspaceDir == "" {
+		return nil, fmt.Errorf("no workspace created for environment: %s", envID)
+	}
+	if ref == "" && newBranch == "" {
+		return nil, fmt.Errorf("ref or new_branch is required")
+	}
+
+	repoPath, err := safeJoinPath(env.WorkspaceDir, repoDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		return nil, fmt.Errorf("not a git repository: %s", repoDir)
+	}
+
+	if dirName == "" {
+		branch := newBranch
+		if branch == "" {
+			branch = ref
+		}
+		dirName = filepath.Base(repoPath) + "-" + strings.NewReplacer("/", "-", "\\", "-", ":", "-").Replace(branch)
+	}
+	worktreePath, err := safeJoinPath(env.WorkspaceDir, dirName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(worktreePath); err == nil {
+		return nil, fmt.Errorf("directory already exists: %s", dirName)
+	}
+
+	args := []string{"-C", repoPath, "worktree", "add"}
+	if newBranch != "" {
+		args = append(args, "-b", newBranch)
+	}
+	args = append(args, "--", worktreePath)
+	if ref != "" {
+		args = append(args, ref)
+	}
+	output, err := runCommand(ctx, commandContext(ctx, "git", args...))
+	if err != nil {
+		// Don't leave a partial checkout or a dangling worktree entry behind
+		os.RemoveAll(worktreePath)
+		runCommand(ctx, commandContext(ctx, "git", "-C", repoPath, "worktree", "prune"))
+		return nil, fmt.Errorf("git worktree add failed: %w\nOutput: %s", err, output)
+	}
+
+	info := &GitWorktreeInfo{
+		RepoDir:      filepath.ToSlash(repoDir),
+		WorktreePath: worktreePath,
+		DirName:      dirName,
+	}
+	if out, err := runCommand(ctx, commandContext(ctx, "git", "-C", worktreePath, "rev-parse", "HEAD")); err == nil {
+		info.Commit = strings.TrimSpace(out)
+	}
+	if out, err := runCommand(ctx, commandContext(ctx, "git", "-C", worktreePath, "symbolic-ref", "--short", "-q", "HEAD")); err == nil {
+		info.Branch = strings.TrimSpace(out)
+	}
+	return info, nil
+}
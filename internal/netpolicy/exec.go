@@ -0,0 +1,112 @@
+package netpolicy
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// noProxy keeps loopback traffic inside the namespace off the proxy
+const noProxy = "localhost,127.0.0.1,::1"
+
+// RunExec implements the net-exec subcommand. It brings up the namespace's loopback
+// interface and, with -proxy-socket, forwards a loopback port to the server's proxy
+// and sets the proxy variables. It then runs the command, relaying signals, and
+// returns its exit status.
+func RunExec(args []string) int {
+	fs := flag.NewFlagSet(ExecCommand, flag.ContinueOnError)
+	socket := fs.String("proxy-socket", "", "Unix socket of the server's filtering proxy (empty = no network at all)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	command := fs.Args()
+	if len(command) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: no command given\n", ExecCommand)
+		return 2
+	}
+
+	// Capabilities are per thread, and the command inherits the starting thread's
+	runtime.LockOSThread()
+	if err := loopbackUp(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to bring up the loopback interface: %v\n", ExecCommand, err)
+		return 1
+	}
+	dropAmbientCaps()
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = childAttr()
+	cmd.Env = os.Environ()
+	if *socket != "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to listen for the proxy: %v\n", ExecCommand, err)
+			return 1
+		}
+		go forwardConnections(listener, *socket)
+		url := "http://" + listener.Addr().String()
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY"} {
+			cmd.Env = append(cmd.Env, name+"="+url, strings.ToLower(name)+"="+url)
+		}
+		cmd.Env = append(cmd.Env, "NO_PROXY="+noProxy, "no_proxy="+noProxy)
+	}
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", ExecCommand, err)
+		return 127
+	}
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	cmd.Wait()
+	if code := cmd.ProcessState.ExitCode(); code >= 0 {
+		return code
+	}
+	// Killed by a signal
+	return 128 + signalNumber(cmd.ProcessState)
+}
+
+// forwardConnections connects every client of listener to the unix socket
+func forwardConnections(listener net.Listener, socket string) {
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer client.Close()
+			upstream, err := net.Dial("unix", socket)
+			if err != nil {
+				return
+			}
+			defer upstream.Close()
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				io.Copy(upstream, client)
+				closeWrite(upstream)
+			}()
+			go func() {
+				defer wg.Done()
+				io.Copy(client, upstream)
+				closeWrite(client)
+			}()
+			wg.Wait()
+		}()
+	}
+}
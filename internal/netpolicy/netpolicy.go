@@ -0,0 +1,48 @@
+// Package netpolicy restricts the network access of processes started in an
+// environment. Restricted processes run in their own network namespace, which only
+// has a loopback interface. For an allowlist, the exec helper started in the namespace
+// (jumpboot-mcp net-exec) forwards a loopback port to a filtering HTTP proxy the
+// server listens for on a unix socket, and points the standard proxy variables at it.
+package netpolicy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ExecCommand is the jumpboot-mcp subcommand that runs a process inside a restricted
+// network namespace
+const ExecCommand = "net-exec"
+
+// ValidatePattern checks an allowlist entry: a host name, an IP address, or *.domain
+// for every subdomain of domain
+func ValidatePattern(pattern string) error {
+	host := strings.TrimPrefix(pattern, "*.")
+	switch {
+	case host == "":
+		return fmt.Errorf("empty host in network allowlist")
+	case net.ParseIP(host) != nil && host == pattern:
+		return nil
+	case strings.ContainsAny(host, "*/:@ \t"):
+		return fmt.Errorf("invalid host %q in network allowlist (use a host name, an IP address or *.domain)", pattern)
+	}
+	return nil
+}
+
+// Allowed reports whether host matches one of the allowlist patterns. Names are
+// compared without case; *.domain matches subdomains of domain but not domain itself.
+func Allowed(patterns []string, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package netpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds connecting to an allowed host
+const dialTimeout = 30 * time.Second
+
+// hopHeaders are removed from proxied plain HTTP requests and responses
+var hopHeaders = []string{"Connection", "Proxy-Connection", "Proxy-Authorization", "Proxy-Authenticate", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// Proxy is an HTTP proxy on a unix socket that only reaches allowed hosts. It handles
+// CONNECT tunnels (HTTPS and anything else tunnelled) and plain HTTP requests.
+type Proxy struct {
+	server    *http.Server
+	allow     func(host string) bool
+	onDeny    func(host string)
+	transport *http.Transport
+	closeOnce sync.Once
+}
+
+// Listen starts a proxy on the unix socket path. allow is asked for every request's
+// host; onDeny, if set, is told about refused ones.
+func Listen(path string, allow func(host string) bool, onDeny func(host string)) (*Proxy, error) {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the network proxy: %w", err)
+	}
+	p := &Proxy{
+		allow:  allow,
+		onDeny: onDeny,
+		transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: dialTimeout}).DialContext,
+			TLSHandshakeTimeout: dialTimeout,
+		},
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: dialTimeout}
+	go p.server.Serve(listener)
+	return p, nil
+}
+
+// Close stops the proxy. Open tunnels end when either side closes them.
+func (p *Proxy) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		err = p.server.Close()
+		p.transport.CloseIdleConnections()
+	})
+	return err
+}
+
+// ServeHTTP handles one proxy request
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Hostname()
+	if r.Method == http.MethodConnect {
+		host, _, _ = net.SplitHostPort(r.Host)
+	}
+	if host == "" || !p.allow(host) {
+		if p.onDeny != nil && host != "" {
+			p.onDeny(host)
+		}
+		http.Error(w, fmt.Sprintf("jumpboot-mcp: %s is not in the environment's network allowlist", host), http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+// tunnel connects to the CONNECT target and copies bytes both ways
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, dialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunnelling is not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	// Bytes the client sent after the CONNECT request are already buffered
+	if n := buffered.Reader.Buffered(); n > 0 {
+		data, _ := buffered.Reader.Peek(n)
+		upstream.Write(data)
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(upstream, client)
+		closeWrite(upstream)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(client, upstream)
+		closeWrite(client)
+	}()
+	wg.Wait()
+	client.Close()
+	upstream.Close()
+}
+
+// forward sends a plain HTTP request on and copies the response back
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.IsAbs() {
+		http.Error(w, "jumpboot-mcp: proxy requests need an absolute URL", http.StatusBadRequest)
+		return
+	}
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, context.Canceled) {
+			status = http.StatusRequestTimeout
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// closeWrite half-closes a connection, if it supports it
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		c.CloseWrite()
+	}
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package netpolicy
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// capNetAdmin is CAP_NET_ADMIN, which net-exec needs to bring up the loopback interface
+const capNetAdmin = 12
+
+// prctl options for the ambient capability set
+const (
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+)
+
+// Supported reports whether processes can be confined to a network namespace here
+const Supported = true
+
+// NamespaceAttr returns the attributes that start net-exec in new user and network
+// namespaces. The user keeps its IDs and gets CAP_NET_ADMIN inside the namespace only.
+func NamespaceAttr() *syscall.SysProcAttr {
+	uid, gid := os.Getuid(), os.Getgid()
+	return &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		AmbientCaps: []uintptr{capNetAdmin},
+	}
+}
+
+// childAttr makes the command die with net-exec, which is what the server kills
+func childAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+}
+
+// dropAmbientCaps keeps CAP_NET_ADMIN from reaching the command. Kernels without
+// ambient capabilities never granted it.
+func dropAmbientCaps() {
+	syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0)
+}
+
+// ifreqFlags is the part of struct ifreq used by SIOCGIFFLAGS and SIOCSIFFLAGS
+type ifreqFlags struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// loopbackUp brings up the lo interface, unless it already is (as in a sandbox)
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var req ifreqFlags
+	copy(req.name[:], "lo")
+	if err := ioctl(fd, syscall.SIOCGIFFLAGS, &req); err != nil {
+		return err
+	}
+	if req.flags&syscall.IFF_UP != 0 {
+		return nil
+	}
+	req.flags |= syscall.IFF_UP
+	return ioctl(fd, syscall.SIOCSIFFLAGS, &req)
+}
+
+func ioctl(fd int, request uintptr, req *ifreqFlags) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(req))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// signalNumber returns the signal that ended a process
+func signalNumber(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return int(status.Signal())
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package netpolicy
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Supported reports whether processes can be confined to a network namespace here
+const Supported = false
+
+var errUnsupported = errors.New("network namespaces are only available on Linux")
+
+// NamespaceAttr returns nil: there are no network namespaces on this platform
+func NamespaceAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func childAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func dropAmbientCaps() {}
+
+func loopbackUp() error {
+	return errUnsupported
+}
+
+func signalNumber(state *os.ProcessState) int {
+	return 0
+}
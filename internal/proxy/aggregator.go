@@ -2,8 +2,10 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -17,21 +19,79 @@ type toolSource struct {
 	originalName string
 }
 
+// ToolsChangedFunc is called when a remote's tool list changes after a reconnect, with
+// the prefixed tools that are gone and the current definitions of its tools
+type ToolsChangedFunc func(removed []string, current []tools.ToolDef)
+
 // ToolAggregator aggregates tools from multiple remote MCP servers
 type ToolAggregator struct {
-	remotes     map[string]*RemoteClient // instance name -> client
-	toolMapping map[string]toolSource    // prefixed tool name -> source
-	mu          sync.RWMutex
+	remotes        map[string]*RemoteClient      // instance name -> client
+	failed         map[string]tools.FailedRemote // instance name -> remote that could not be added
+	toolMapping    map[string]toolSource         // prefixed tool name -> source
+	names          map[toolKey]string            // remote tool -> prefixed tool name
+	naming         ToolNaming                    // how prefixed tool names are built
+	aliases        map[string]string             // instance name -> prefix
+	reserved       map[string]bool               // names of tools served by this process
+	descMaxLen     int                           // max description length for proxied tools (0 = unlimited)
+	trustHints     bool                          // keep the read-only and destructive hints of remotes
+	healthInterval time.Duration                 // interval of remote health pings (0 = disabled)
+	maxHops        int                           // proxies a client may reach a tool through
+	onToolsChanged ToolsChangedFunc
+	mu             sync.RWMutex
 }
 
 // NewToolAggregator creates a new tool aggregator
 func NewToolAggregator() *ToolAggregator {
 	return &ToolAggregator{
-		remotes:     make(map[string]*RemoteClient),
-		toolMapping: make(map[string]toolSource),
+		remotes:        make(map[string]*RemoteClient),
+		failed:         make(map[string]tools.FailedRemote),
+		toolMapping:    make(map[string]toolSource),
+		names:          make(map[toolKey]string),
+		naming:         NamingColon,
+		healthInterval: DefaultHealthInterval,
+		maxHops:        discovery.DefaultMaxHops,
 	}
 }
 
+// SetMaxHops sets how many proxies a client may reach a tool through, counting this
+// one, for remotes added afterwards. With the default of 1, tools that a remote itself
+// proxies are never re-exported.
+func (a *ToolAggregator) SetMaxHops(maxHops int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.maxHops = max(maxHops, 1)
+}
+
+// SetToolNaming sets how the tools of remotes added afterwards are named. aliases map
+// instance names to shorter prefixes, and reserved lists the names of tools served by
+// this process, which proxied tools never take.
+func (a *ToolAggregator) SetToolNaming(naming ToolNaming, aliases map[string]string, reserved []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.naming = naming
+	a.aliases = aliases
+	a.reserved = make(map[string]bool, len(reserved))
+	for _, name := range reserved {
+		a.reserved[name] = true
+	}
+}
+
+// SetHealthInterval sets how often remotes added afterwards are pinged (0 disables
+// health checks and reconnection)
+func (a *ToolAggregator) SetHealthInterval(interval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.healthInterval = interval
+}
+
+// OnToolsChanged registers a function called when a remote comes back with its tool
+// list re-fetched, so that registered proxy tools can be updated
+func (a *ToolAggregator) OnToolsChanged(fn ToolsChangedFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onToolsChanged = fn
+}
+
 // AddRemote connects to a remote service and adds its tools
 func (a *ToolAggregator) AddRemote(ctx context.Context, info discovery.ServiceInfo) error {
 	a.mu.Lock()
@@ -44,25 +104,82 @@ func (a *ToolAggregator) AddRemote(ctx context.Context, info discovery.ServiceIn
 
 	// Create and connect to the remote
 	remote := NewRemoteClient(info)
+	remote.maxHops = a.maxHops
 	if err := remote.Connect(ctx); err != nil {
+		a.failed[info.InstanceName] = tools.FailedRemote{Info: info, Error: err.Error()}
 		return err
 	}
 
 	// Add to remotes map
+	delete(a.failed, info.InstanceName)
 	a.remotes[info.InstanceName] = remote
 
 	// Add tool mappings
 	for _, tool := range remote.Tools() {
-		prefixedName := fmt.Sprintf("%s:%s", info.InstanceName, tool.Name)
+		prefixedName := a.assignName(info.InstanceName, tool.Name)
 		a.toolMapping[prefixedName] = toolSource{
 			remote:       remote,
 			originalName: tool.Name,
 		}
 	}
 
+	if a.healthInterval > 0 {
+		remote.StartHealthCheck(a.healthInterval, a.remoteReconnected)
+	}
 	return nil
 }
 
+// remoteReconnected rebuilds the tool mappings of a remote from its re-fetched tool
+// list and reports the change
+func (a *ToolAggregator) remoteReconnected(remote *RemoteClient) {
+	a.mu.Lock()
+	instanceName := remote.Info.InstanceName
+	if a.remotes[instanceName] != remote {
+		a.mu.Unlock()
+		return
+	}
+
+	current := make(map[string]bool)
+	var defs []tools.ToolDef
+	for _, tool := range remote.Tools() {
+		def := a.prefixedTool(a.assignName(instanceName, tool.Name), instanceName, remote, tool)
+		current[def.Tool.Name] = true
+		defs = append(defs, def)
+		a.toolMapping[def.Tool.Name] = toolSource{remote: remote, originalName: tool.Name}
+	}
+	var removed []string
+	for name, source := range a.toolMapping {
+		if source.remote == remote && !current[name] {
+			removed = append(removed, name)
+			delete(a.toolMapping, name)
+			delete(a.names, toolKey{instanceName, source.originalName})
+		}
+	}
+	onToolsChanged := a.onToolsChanged
+	a.mu.Unlock()
+
+	if onToolsChanged != nil {
+		onToolsChanged(removed, defs)
+	}
+}
+
+// SetDescriptionLimit limits the description length of proxied tools (0 = unlimited)
+func (a *ToolAggregator) SetDescriptionLimit(maxLen int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.descMaxLen = maxLen
+}
+
+// SetTrustAnnotations makes proxied tools keep the read-only, destructive and
+// idempotent hints their remote reports. Otherwise they are marked as destructive,
+// since roles, draining and auditing rely on these hints and a remote must not decide
+// them.
+func (a *ToolAggregator) SetTrustAnnotations(trust bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.trustHints = trust
+}
+
 // RemoveRemote disconnects from a remote service
 func (a *ToolAggregator) RemoveRemote(instanceName string) error {
 	a.mu.Lock()
@@ -77,6 +194,7 @@ func (a *ToolAggregator) RemoveRemote(instanceName string) error {
 	for name, source := range a.toolMapping {
 		if source.remote == remote {
 			delete(a.toolMapping, name)
+			delete(a.names, toolKey{instanceName, source.originalName})
 		}
 	}
 
@@ -94,33 +212,62 @@ func (a *ToolAggregator) GetAllTools() []tools.ToolDef {
 
 	for instanceName, remote := range a.remotes {
 		for _, tool := range remote.Tools() {
-			prefixedName := fmt.Sprintf("%s:%s", instanceName, tool.Name)
-
-			// Create enhanced description with note
-			description := tool.Description
-			if remote.Info.Note != "" {
-				description = fmt.Sprintf("[%s] %s", remote.Info.Note, description)
+			if name, ok := a.names[toolKey{instanceName, tool.Name}]; ok {
+				result = append(result, a.prefixedTool(name, instanceName, remote, tool))
 			}
+		}
+	}
 
-			// Create a new tool with prefixed name
-			prefixedTool := mcp.NewTool(prefixedName,
-				mcp.WithDescription(description),
-			)
+	return result
+}
 
-			// Copy the input schema
-			prefixedTool.InputSchema = tool.InputSchema
+// prefixedTool returns the proxy definition of a remote tool, exposed as
+// prefixedName. Callers must hold a.mu.
+func (a *ToolAggregator) prefixedTool(prefixedName, instanceName string, remote *RemoteClient, tool mcp.Tool) tools.ToolDef {
 
-			// Create handler that proxies to the remote
-			handler := a.createProxyHandler(prefixedName)
+	// Create enhanced description with note
+	description := tools.SummarizeDescription(tool.Description, a.descMaxLen)
+	if remote.Info.Note != "" {
+		description = fmt.Sprintf("[%s] %s", remote.Info.Note, description)
+	}
 
-			result = append(result, tools.ToolDef{
-				Tool:    prefixedTool,
-				Handler: handler,
-			})
-		}
+	// Create a new tool with prefixed name
+	prefixedTool := mcp.NewTool(prefixedName,
+		mcp.WithDescription(description),
+	)
+
+	// Copy the input schema and the remote's annotations; the call leaves this host
+	prefixedTool.InputSchema = tool.InputSchema
+	prefixedTool.Annotations = tool.Annotations
+	openWorld := true
+	prefixedTool.Annotations.OpenWorldHint = &openWorld
+	if !a.trustHints {
+		readOnly, destructive, idempotent := false, true, false
+		prefixedTool.Annotations.ReadOnlyHint = &readOnly
+		prefixedTool.Annotations.DestructiveHint = &destructive
+		prefixedTool.Annotations.IdempotentHint = &idempotent
 	}
 
-	return result
+	// Record the hop, so that an aggregator of this server can tell the tool is proxied
+	meta := map[string]any{}
+	origin := instanceName
+	if tool.Meta != nil {
+		for k, v := range tool.Meta.AdditionalFields {
+			meta[k] = v
+		}
+		if inner, ok := tool.Meta.AdditionalFields[discovery.MetaOrigin].(string); ok && inner != "" {
+			origin += "/" + inner
+		}
+	}
+	meta[discovery.MetaHops] = ToolHops(tool) + 1
+	meta[discovery.MetaOrigin] = origin
+	prefixedTool.Meta = mcp.NewMetaFromMap(meta)
+
+	// Create handler that proxies to the remote
+	return tools.ToolDef{
+		Tool:    prefixedTool,
+		Handler: a.createProxyHandler(prefixedName),
+	}
 }
 
 // createProxyHandler creates a handler function that proxies calls to the remote server
@@ -135,7 +282,7 @@ func (a *ToolAggregator) createProxyHandler(prefixedName string) server.ToolHand
 		}
 
 		if !source.remote.IsConnected() {
-			return mcp.NewToolResultError(fmt.Sprintf("server disconnected for tool %s", prefixedName)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("server disconnected for tool %s (%s)", prefixedName, source.remote.Status().State)), nil
 		}
 
 		// Call the remote tool with the original name
@@ -152,6 +299,148 @@ func (a *ToolAggregator) createProxyHandler(prefixedName string) server.ToolHand
 	}
 }
 
+// GetMetaTools returns generic tools that reach every remote tool through a single
+// entry point, instead of registering one prefixed tool per remote tool. This keeps
+// the tools/list payload small for large federations.
+func (a *ToolAggregator) GetMetaTools() []tools.ToolDef {
+	return []tools.ToolDef{
+		{
+			Tool: mcp.NewTool("list_remote_tools",
+				mcp.WithDescription("List the tools (with input schemas) offered by a remote jumpboot-mcp server. Use with call_remote_tool."),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("server", mcp.Required(), mcp.Description("Remote server instance name (see list_servers)")),
+			),
+			Handler: a.listRemoteToolsHandler,
+		},
+		{
+			Tool: mcp.NewTool("call_remote_tool",
+				mcp.WithDescription("Call a tool on a remote jumpboot-mcp server"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("server", mcp.Required(), mcp.Description("Remote server instance name (see list_servers)")),
+				mcp.WithString("tool", mcp.Required(), mcp.Description("Name of the tool on the remote server (e.g., 'run_code')")),
+				mcp.WithObject("args", mcp.Description("Arguments for the remote tool")),
+			),
+			Handler: a.callRemoteToolHandler,
+		},
+	}
+}
+
+func (a *ToolAggregator) listRemoteToolsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	instanceName := request.GetString("server", "")
+
+	a.mu.RLock()
+	remote, exists := a.remotes[instanceName]
+	a.mu.RUnlock()
+
+	if !exists {
+		return mcp.NewToolResultError(fmt.Sprintf("server %s not found", instanceName)), nil
+	}
+
+	result := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"server": instanceName,
+			"tools":  remote.Tools(),
+		},
+		"error": nil,
+	}
+
+	jsonBytes, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(jsonBytes)), nil
+}
+
+func (a *ToolAggregator) callRemoteToolHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	instanceName := request.GetString("server", "")
+	toolName := request.GetString("tool", "")
+	if instanceName == "" || toolName == "" {
+		return mcp.NewToolResultError("server and tool are required"), nil
+	}
+
+	var args map[string]any
+	if raw, ok := request.GetArguments()["args"]; ok && raw != nil {
+		args, ok = raw.(map[string]any)
+		if !ok {
+			return mcp.NewToolResultError("invalid arguments type"), nil
+		}
+	}
+
+	result, err := a.CallRemoteTool(ctx, instanceName, toolName, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return result, nil
+}
+
+// CallRemoteTool invokes a tool by its original (unprefixed) name on a connected remote
+func (a *ToolAggregator) CallRemoteTool(ctx context.Context, instanceName, toolName string, args map[string]any) (*mcp.CallToolResult, error) {
+	a.mu.RLock()
+	remote, exists := a.remotes[instanceName]
+	a.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("server %s not found", instanceName)
+	}
+
+	if !remote.IsConnected() {
+		return nil, fmt.Errorf("server %s disconnected (%s)", instanceName, remote.Status().State)
+	}
+
+	result, err := remote.CallTool(ctx, toolName, args)
+	if err != nil {
+		return nil, fmt.Errorf("remote call failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// ToolCount returns the number of tools a remote offers
+func (a *ToolAggregator) ToolCount(instanceName string) int {
+	a.mu.RLock()
+	remote, exists := a.remotes[instanceName]
+	a.mu.RUnlock()
+
+	if !exists {
+		return 0
+	}
+	return len(remote.Tools())
+}
+
+// EstimateToolTokens returns the estimated token cost of the tool list of a remote
+func (a *ToolAggregator) EstimateToolTokens(instanceName string) int {
+	a.mu.RLock()
+	remote, exists := a.remotes[instanceName]
+	a.mu.RUnlock()
+
+	if !exists {
+		return 0
+	}
+	return tools.EstimateTokens(remote.Tools())
+}
+
+// RemoteTool returns a tool of a remote by its original (unprefixed) name
+func (a *ToolAggregator) RemoteTool(instanceName, toolName string) (mcp.Tool, bool) {
+	a.mu.RLock()
+	remote, exists := a.remotes[instanceName]
+	a.mu.RUnlock()
+
+	if !exists {
+		return mcp.Tool{}, false
+	}
+	for _, tool := range remote.Tools() {
+		if tool.Name == toolName {
+			return tool, true
+		}
+	}
+	return mcp.Tool{}, false
+}
+
 // Close closes all remote connections
 func (a *ToolAggregator) Close() error {
 	a.mu.Lock()
@@ -166,6 +455,7 @@ func (a *ToolAggregator) Close() error {
 
 	a.remotes = make(map[string]*RemoteClient)
 	a.toolMapping = make(map[string]toolSource)
+	a.names = make(map[toolKey]string)
 
 	return lastErr
 }
@@ -184,7 +474,43 @@ func (a *ToolAggregator) GetRemoteInfos() []discovery.ServiceInfo {
 
 	var infos []discovery.ServiceInfo
 	for _, remote := range a.remotes {
-		infos = append(infos, remote.Info)
+		infos = append(infos, remote.ServiceInfo())
 	}
 	return infos
 }
+
+// ToolSource returns the remote and original name of a proxied tool
+func (a *ToolAggregator) ToolSource(prefixedName string) (instanceName, toolName string, ok bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	source, ok := a.toolMapping[prefixedName]
+	if !ok {
+		return "", "", false
+	}
+	return source.remote.Info.InstanceName, source.originalName, true
+}
+
+// FailedRemotes returns the remotes that could not be connected
+func (a *ToolAggregator) FailedRemotes() []tools.FailedRemote {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var failed []tools.FailedRemote
+	for _, remote := range a.failed {
+		failed = append(failed, remote)
+	}
+	return failed
+}
+
+// RemoteStatus returns the connection health of a remote
+func (a *ToolAggregator) RemoteStatus(instanceName string) tools.RemoteStatus {
+	a.mu.RLock()
+	remote, exists := a.remotes[instanceName]
+	a.mu.RUnlock()
+
+	if !exists {
+		return tools.RemoteStatus{State: StateClosed}
+	}
+	return remote.Status()
+}
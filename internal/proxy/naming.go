@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToolNaming selects how proxied tool names are built from the remote's name
+type ToolNaming string
+
+// Tool naming strategies
+const (
+	NamingColon      ToolNaming = "colon"      // gpu-box:run_code
+	NamingUnderscore ToolNaming = "underscore" // gpu-box_run_code, valid for clients that restrict tool names to [A-Za-z0-9_-]
+	NamingNone       ToolNaming = "none"       // run_code; names taken by local or other remote tools fall back to underscore
+)
+
+// ParseToolNaming validates a -remote-prefix value
+func ParseToolNaming(value string) (ToolNaming, error) {
+	switch naming := ToolNaming(value); naming {
+	case NamingColon, NamingUnderscore, NamingNone:
+		return naming, nil
+	}
+	return "", fmt.Errorf("unknown tool naming %q (use colon, underscore or none)", value)
+}
+
+// ParseAliases parses "instance=alias" entries into a map. Aliases replace instance
+// names in tool prefixes and must be unique.
+func ParseAliases(entries []string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	used := make(map[string]string)
+	for _, entry := range entries {
+		instance, alias, ok := strings.Cut(entry, "=")
+		instance, alias = strings.TrimSpace(instance), strings.TrimSpace(alias)
+		if !ok || instance == "" || alias == "" {
+			return nil, fmt.Errorf("invalid alias %q (expected instance=alias)", entry)
+		}
+		if other, taken := used[alias]; taken && other != instance {
+			return nil, fmt.Errorf("alias %q is used for both %s and %s", alias, other, instance)
+		}
+		aliases[instance] = alias
+		used[alias] = instance
+	}
+	return aliases, nil
+}
+
+// toolKey identifies a tool of a remote by its original name
+type toolKey struct {
+	instance string
+	tool     string
+}
+
+// assignName returns the exposed name of a remote tool, choosing one on first use.
+// Callers must hold a.mu for writing.
+func (a *ToolAggregator) assignName(instanceName, toolName string) string {
+	key := toolKey{instanceName, toolName}
+	if name, ok := a.names[key]; ok {
+		return name
+	}
+
+	prefix := instanceName
+	if alias, ok := a.aliases[instanceName]; ok {
+		prefix = alias
+	}
+	var name string
+	switch a.naming {
+	case NamingUnderscore:
+		name = safeToolPrefix(prefix) + "_" + toolName
+	case NamingNone:
+		name = toolName
+	default:
+		name = prefix + ":" + toolName
+	}
+
+	// Without a prefix, names collide with local tools and with the same tool on other
+	// remotes: the first remote keeps the plain name, later ones get a prefix
+	if a.nameTaken(name) {
+		name = safeToolPrefix(prefix) + "_" + toolName
+	}
+	for base, n := name, 2; a.nameTaken(name); n++ {
+		name = base + "_" + strconv.Itoa(n)
+	}
+
+	a.names[key] = name
+	return name
+}
+
+// nameTaken reports whether a name is reserved or used by another remote tool
+func (a *ToolAggregator) nameTaken(name string) bool {
+	if a.reserved[name] {
+		return true
+	}
+	_, mapped := a.toolMapping[name]
+	return mapped
+}
+
+// safeToolPrefix replaces characters that are not valid in tool names
+func safeToolPrefix(prefix string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, prefix)
+}
@@ -2,37 +2,139 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/richinsley/jumpboot-mcp/internal/discovery"
+	"github.com/richinsley/jumpboot-mcp/internal/tools"
+)
+
+// Remote connection states
+const (
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateClosed       = "closed"
+)
+
+// Health check settings
+const (
+	DefaultHealthInterval = 30 * time.Second
+	healthPingTimeout     = 10 * time.Second
+	minReconnectBackoff   = time.Second
+	maxReconnectBackoff   = 5 * time.Minute
 )
 
 // RemoteClient wraps an MCP client connection to a remote jumpboot-mcp server
 type RemoteClient struct {
 	Info   discovery.ServiceInfo
 	client *client.Client
-	tools  []mcp.Tool
-	mu     sync.RWMutex
+	mu     sync.RWMutex // held for reading while the client is used, for writing while it is replaced
+
+	stateMu    sync.Mutex // protects the fields below and Info.Host
+	tools      []mcp.Tool
+	templates  []mcp.ResourceTemplate
+	state      string
+	lastPing   time.Time
+	lastError  string
+	reconnects int
+	nextRetry  time.Time
+
+	stopHealth context.CancelFunc
+	checkNow   chan struct{} // asks the health loop to ping right away
+
+	maxHops int // tools that already passed through this many proxies are dropped
 }
 
 // NewRemoteClient creates a new remote client for the given service
 func NewRemoteClient(info discovery.ServiceInfo) *RemoteClient {
 	return &RemoteClient{
-		Info: info,
+		Info:     info,
+		checkNow: make(chan struct{}, 1),
+		maxHops:  discovery.DefaultMaxHops,
+	}
+}
+
+// ToolHops returns the number of proxies a tool has passed through (0 for a tool
+// served by the server that runs it)
+func ToolHops(tool mcp.Tool) int {
+	if tool.Meta == nil {
+		return 0
+	}
+	// JSON numbers decode as float64
+	switch hops := tool.Meta.AdditionalFields[discovery.MetaHops].(type) {
+	case float64:
+		return int(hops)
+	case int:
+		return hops
 	}
+	return 0
 }
 
-// Connect establishes a connection to the remote MCP server
+// dialTimeout bounds the reachability check of each candidate address, so that an
+// address from an unusable network does not hold up the ones after it
+const dialTimeout = 2 * time.Second
+
+// Connect establishes a connection to the remote MCP server, trying its candidate
+// addresses in order. Host is set to the address that worked.
 func (r *RemoteClient) Connect(ctx context.Context) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.connectLocked(ctx)
+}
+
+// connectLocked tries the candidate addresses. Callers must hold r.mu.
+func (r *RemoteClient) connectLocked(ctx context.Context) error {
+	var errs []error
+	for _, host := range r.Info.Candidates() {
+		port := strconv.Itoa(r.Info.Port)
+		conn, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s unreachable: %w", host, err))
+			continue
+		}
+		conn.Close()
+
+		if err := r.connect(ctx, r.Info.URLFor(host)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.stateMu.Lock()
+		r.Info.Host = host
+		r.state = StateConnected
+		r.lastPing = time.Now()
+		r.lastError = ""
+		r.nextRetry = time.Time{}
+		r.stateMu.Unlock()
+		return nil
+	}
+	return errors.Join(errs...)
+}
 
-	// Create HTTP client
-	url := r.Info.URL()
-	c, err := client.NewStreamableHttpClient(url)
+// connect connects to the server at url. Callers must hold r.mu.
+func (r *RemoteClient) connect(ctx context.Context, url string) error {
+	// Create HTTP client, using the legacy SSE protocol if the server only speaks that
+	var c *client.Client
+	var err error
+	headers := map[string]string{discovery.FederationHeader: "1"}
+	if r.Info.Token != "" {
+		headers["Authorization"] = "Bearer " + r.Info.Token
+	}
+	// Each request carries the proxies it passed through, so the server can refuse loops
+	origin := func(ctx context.Context) map[string]string {
+		return map[string]string{discovery.OriginHeader: discovery.OutgoingOrigin(ctx)}
+	}
+	if r.Info.SSE {
+		c, err = client.NewSSEMCPClient(url, transport.WithHeaders(headers), transport.WithHeaderFunc(origin))
+	} else {
+		c, err = client.NewStreamableHttpClient(url, transport.WithHTTPHeaders(headers), transport.WithHTTPHeaderFunc(origin))
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create client for %s: %w", url, err)
 	}
@@ -46,7 +148,7 @@ func (r *RemoteClient) Connect(ctx context.Context) error {
 	initReq := mcp.InitializeRequest{}
 	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
 	initReq.Params.ClientInfo = mcp.Implementation{
-		Name:    "jumpboot-mcp-proxy",
+		Name:    discovery.FederationClientName,
 		Version: "1.0.0",
 	}
 
@@ -61,13 +163,19 @@ func (r *RemoteClient) Connect(ctx context.Context) error {
 	// Fetch tools from the remote server
 	if err := r.fetchTools(ctx); err != nil {
 		c.Close()
+		r.client = nil
 		return fmt.Errorf("failed to fetch tools from %s: %w", url, err)
 	}
 
+	// Older servers have no resources
+	r.fetchResourceTemplates(ctx)
+
 	return nil
 }
 
-// fetchTools retrieves the list of tools from the remote server
+// fetchTools retrieves the list of tools from the remote server. Tools the remote
+// itself proxies are dropped once another hop would exceed maxHops, so that mutually
+// federated servers do not re-export each other's tools.
 func (r *RemoteClient) fetchTools(ctx context.Context) error {
 	toolsReq := mcp.ListToolsRequest{}
 	result, err := r.client.ListTools(ctx, toolsReq)
@@ -75,17 +183,87 @@ func (r *RemoteClient) fetchTools(ctx context.Context) error {
 		return err
 	}
 
-	r.tools = result.Tools
+	tools := make([]mcp.Tool, 0, len(result.Tools))
+	for _, tool := range result.Tools {
+		if ToolHops(tool)+1 <= r.maxHops {
+			tools = append(tools, tool)
+		}
+	}
+
+	r.stateMu.Lock()
+	r.tools = tools
+	r.stateMu.Unlock()
 	return nil
 }
 
-// Tools returns the tools available from this remote server
-func (r *RemoteClient) Tools() []mcp.Tool {
+// fetchResourceTemplates retrieves the resource templates of the remote server
+func (r *RemoteClient) fetchResourceTemplates(ctx context.Context) {
+	var templates []mcp.ResourceTemplate
+	if result, err := r.client.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{}); err == nil {
+		templates = result.ResourceTemplates
+	}
+
+	r.stateMu.Lock()
+	r.templates = templates
+	r.stateMu.Unlock()
+}
+
+// ResourceTemplates returns the resource templates available from this remote server
+func (r *RemoteClient) ResourceTemplates() []mcp.ResourceTemplate {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.templates
+}
+
+// ReadResource reads a resource from the remote server
+func (r *RemoteClient) ReadResource(ctx context.Context, uri string) (*mcp.ReadResourceResult, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+
+	if r.client == nil {
+		return nil, fmt.Errorf("client not connected")
+	}
+
+	req := mcp.ReadResourceRequest{}
+	req.Params.URI = uri
+	return r.client.ReadResource(ctx, req)
+}
+
+// Tools returns the tools available from this remote server
+func (r *RemoteClient) Tools() []mcp.Tool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
 	return r.tools
 }
 
+// ServiceInfo returns the service info, with Host set to the address in use
+func (r *RemoteClient) ServiceInfo() discovery.ServiceInfo {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.Info
+}
+
+// Status returns the connection health of the remote
+func (r *RemoteClient) Status() tools.RemoteStatus {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+
+	status := tools.RemoteStatus{
+		State:      r.state,
+		LastError:  r.lastError,
+		Reconnects: r.reconnects,
+	}
+	if !r.lastPing.IsZero() {
+		lastPing := r.lastPing
+		status.LastPing = &lastPing
+	}
+	if !r.nextRetry.IsZero() {
+		nextRetry := r.nextRetry
+		status.NextRetry = &nextRetry
+	}
+	return status
+}
+
 // CallTool invokes a tool on the remote server
 func (r *RemoteClient) CallTool(ctx context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
 	r.mu.RLock()
@@ -99,11 +277,139 @@ func (r *RemoteClient) CallTool(ctx context.Context, name string, args map[strin
 	req.Params.Name = name
 	req.Params.Arguments = args
 
-	return r.client.CallTool(ctx, req)
+	result, err := r.client.CallTool(ctx, req)
+	if err != nil && ctx.Err() == nil {
+		// The server may be gone; check now rather than at the next interval
+		r.requestCheck()
+	}
+	return result, err
+}
+
+// requestCheck asks the health loop, if running, to ping the server right away
+func (r *RemoteClient) requestCheck() {
+	select {
+	case r.checkNow <- struct{}{}:
+	default:
+	}
+}
+
+// StartHealthCheck pings the server every interval. When a ping fails, the client
+// reconnects with exponential backoff, re-fetching the tool list, and calls
+// onReconnect once the connection is back.
+func (r *RemoteClient) StartHealthCheck(interval time.Duration, onReconnect func(*RemoteClient)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stateMu.Lock()
+	r.stopHealth = cancel
+	r.stateMu.Unlock()
+	go r.healthLoop(ctx, interval, onReconnect)
+}
+
+// healthLoop runs until ctx is cancelled by Close
+func (r *RemoteClient) healthLoop(ctx context.Context, interval time.Duration, onReconnect func(*RemoteClient)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.checkNow:
+		}
+
+		err := r.ping(ctx)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+
+		r.setState(StateReconnecting, err, time.Time{})
+		for backoff := minReconnectBackoff; ; backoff = min(backoff*2, maxReconnectBackoff) {
+			err := r.reconnect(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				if onReconnect != nil {
+					onReconnect(r)
+				}
+				break
+			}
+			r.setState(StateReconnecting, err, time.Now().Add(backoff))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+		}
+
+		// Drain a check requested by calls that failed while reconnecting
+		select {
+		case <-r.checkNow:
+		default:
+		}
+	}
+}
+
+// ping checks that the server still answers on the current session
+func (r *RemoteClient) ping(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.client == nil {
+		return fmt.Errorf("client not connected")
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+	if err := r.client.Ping(ctx); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+
+	r.stateMu.Lock()
+	r.lastPing = time.Now()
+	r.stateMu.Unlock()
+	return nil
 }
 
-// Close closes the connection to the remote server
+// reconnect replaces the client with a new connection
+func (r *RemoteClient) reconnect(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.client != nil {
+		r.client.Close()
+		r.client = nil
+	}
+	if err := r.connectLocked(ctx); err != nil {
+		return err
+	}
+
+	r.stateMu.Lock()
+	r.reconnects++
+	r.stateMu.Unlock()
+	return nil
+}
+
+// setState records a connection state with the error that caused it
+func (r *RemoteClient) setState(state string, err error, nextRetry time.Time) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+
+	r.state = state
+	r.nextRetry = nextRetry
+	if err != nil {
+		r.lastError = err.Error()
+	}
+}
+
+// Close stops health checking and closes the connection to the remote server
 func (r *RemoteClient) Close() error {
+	r.stateMu.Lock()
+	if r.stopHealth != nil {
+		r.stopHealth()
+	}
+	r.state = StateClosed
+	r.stateMu.Unlock()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -117,7 +423,7 @@ func (r *RemoteClient) Close() error {
 
 // IsConnected returns true if the client is connected
 func (r *RemoteClient) IsConnected() bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return r.client != nil
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.state == StateConnected
 }
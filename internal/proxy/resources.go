@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/tools"
+)
+
+// URI prefixes of jumpboot resources. A remote resource jumpboot://workspace/... of
+// the server gpu-box is exposed as jumpboot://remote/gpu-box/workspace/...
+const (
+	resourceScheme  = "jumpboot://"
+	RemoteURIPrefix = resourceScheme + "remote/"
+)
+
+// remoteURI rewrites the URI of a remote's resource to its proxied form
+func remoteURI(instanceName, uri string) string {
+	rest, ok := strings.CutPrefix(uri, resourceScheme)
+	if !ok {
+		return uri
+	}
+	return RemoteURIPrefix + url.PathEscape(instanceName) + "/" + rest
+}
+
+// originalURI rewrites a proxied URI back to the remote's own URI
+func originalURI(instanceName, uri string) (string, bool) {
+	rest, ok := strings.CutPrefix(uri, RemoteURIPrefix+url.PathEscape(instanceName)+"/")
+	if !ok {
+		return "", false
+	}
+	return resourceScheme + rest, true
+}
+
+// GetResourceTemplates returns the jumpboot:// resource templates of all remotes,
+// rewritten under jumpboot://remote/{instance}/
+func (a *ToolAggregator) GetResourceTemplates() []tools.ResourceTemplateDef {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var result []tools.ResourceTemplateDef
+	for instanceName, remote := range a.remotes {
+		for _, template := range remote.ResourceTemplates() {
+			if template.URITemplate == nil || !strings.HasPrefix(template.URITemplate.Raw(), resourceScheme) {
+				continue
+			}
+			description := template.Description
+			if remote.Info.Note != "" {
+				description = fmt.Sprintf("[%s] %s", remote.Info.Note, description)
+			}
+			proxied := mcp.NewResourceTemplate(remoteURI(instanceName, template.URITemplate.Raw()), instanceName+":"+template.Name,
+				mcp.WithTemplateDescription(description),
+			)
+			proxied.MIMEType = template.MIMEType
+			result = append(result, tools.ResourceTemplateDef{
+				Template: proxied,
+				Handler:  a.createResourceHandler(instanceName),
+			})
+		}
+	}
+	return result
+}
+
+// createResourceHandler creates a handler that reads resources from a remote server
+func (a *ToolAggregator) createResourceHandler(instanceName string) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		a.mu.RLock()
+		remote, exists := a.remotes[instanceName]
+		a.mu.RUnlock()
+
+		if !exists {
+			return nil, fmt.Errorf("server %s not found", instanceName)
+		}
+		if !remote.IsConnected() {
+			return nil, fmt.Errorf("server %s disconnected (%s)", instanceName, remote.Status().State)
+		}
+		uri, ok := originalURI(instanceName, request.Params.URI)
+		if !ok {
+			return nil, fmt.Errorf("resource %s does not belong to server %s", request.Params.URI, instanceName)
+		}
+
+		result, err := remote.ReadResource(ctx, uri)
+		if err != nil {
+			return nil, fmt.Errorf("remote read failed: %w", err)
+		}
+
+		// Point the contents at the proxied URIs
+		contents := make([]mcp.ResourceContents, 0, len(result.Contents))
+		for _, content := range result.Contents {
+			switch c := content.(type) {
+			case mcp.TextResourceContents:
+				c.URI = remoteURI(instanceName, c.URI)
+				content = c
+			case mcp.BlobResourceContents:
+				c.URI = remoteURI(instanceName, c.URI)
+				content = c
+			}
+			contents = append(contents, content)
+		}
+		return contents, nil
+	}
+}
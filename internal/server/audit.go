@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+	"github.com/richinsley/jumpboot-mcp/internal/tools"
+)
+
+// auditMiddleware records every call of a tool not annotated read-only in the
+// Manager's audit log, with the caller, summarized arguments and the outcome
+func auditMiddleware(mgr *manager.Manager, adminToken string, roles *RolePolicy, lookup tools.ToolLookup) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			tool, ok := lookup(request.Params.Name)
+			if !ok || (tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint) {
+				return next(ctx, request)
+			}
+
+			_, caller := withCaller(ctx, adminToken, roles)
+			start := time.Now()
+			result, err := next(ctx, request)
+
+			entry := manager.AuditEntry{
+				Time:       start.UTC(),
+				Session:    caller.SessionID,
+				Admin:      caller.Admin,
+				Principal:  caller.Principal,
+				Role:       caller.Role,
+				Tool:       request.Params.Name,
+				Args:       request.GetArguments(),
+				Outcome:    manager.AuditOK,
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				entry.Outcome, entry.Error = manager.AuditError, err.Error()
+			} else if failed, msg := resultError(result); failed {
+				entry.Outcome, entry.Error = manager.AuditError, msg
+			}
+			mgr.RecordAudit(entry)
+			return result, err
+		}
+	}
+}
+
+// resultError reports whether a tool result is an error response and its message
+func resultError(result *mcp.CallToolResult) (bool, string) {
+	if result == nil || len(result.Content) == 0 {
+		return false, ""
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		return result.IsError, ""
+	}
+	var resp manager.Response
+	if err := json.Unmarshal([]byte(text.Text), &resp); err != nil {
+		return result.IsError, ""
+	}
+	return result.IsError || !resp.Success, resp.Error
+}
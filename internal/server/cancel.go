@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// requestIDMetaKey carries the JSON-RPC request ID from the BeforeCallTool hook to the
+// tool middleware, which otherwise has no access to it
+const requestIDMetaKey = "jumpboot-mcp/request-id"
+
+// cancelTracker maps in-flight tool calls to their cancel functions so that
+// notifications/cancelled from the client aborts the call server-side
+type cancelTracker struct {
+	mu       sync.Mutex
+	inFlight map[string]context.CancelFunc // keyed by session ID and request ID
+}
+
+func newCancelTracker() *cancelTracker {
+	return &cancelTracker{inFlight: make(map[string]context.CancelFunc)}
+}
+
+// requestIDString returns the canonical form of a JSON-RPC request ID. The hooks see
+// an mcp.RequestId while notifications/cancelled carries the decoded JSON value (a
+// float64 for numbers), and both must give the same string.
+func requestIDString(id any) string {
+	switch v := id.(type) {
+	case mcp.RequestId:
+		return v.String()
+	case *mcp.RequestId:
+		return v.String()
+	default:
+		return mcp.NewRequestId(id).String()
+	}
+}
+
+// requestKey identifies a request within its MCP session
+func requestKey(ctx context.Context, id string) string {
+	sessionID := ""
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		sessionID = session.SessionID()
+	}
+	return sessionID + "/" + id
+}
+
+// tagRequest records the request ID in the request's metadata (BeforeCallTool hook)
+func (t *cancelTracker) tagRequest(ctx context.Context, id any, request *mcp.CallToolRequest) {
+	if request.Params.Meta == nil {
+		request.Params.Meta = &mcp.Meta{}
+	}
+	if request.Params.Meta.AdditionalFields == nil {
+		request.Params.Meta.AdditionalFields = make(map[string]any)
+	}
+	request.Params.Meta.AdditionalFields[requestIDMetaKey] = requestIDString(id)
+}
+
+// middleware gives each tool call a context that is cancelled by a matching
+// notifications/cancelled
+func (t *cancelTracker) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta == nil {
+			return next(ctx, request)
+		}
+		id, ok := request.Params.Meta.AdditionalFields[requestIDMetaKey].(string)
+		if !ok {
+			return next(ctx, request)
+		}
+		delete(request.Params.Meta.AdditionalFields, requestIDMetaKey)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		key := requestKey(ctx, id)
+		t.mu.Lock()
+		t.inFlight[key] = cancel
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			delete(t.inFlight, key)
+			t.mu.Unlock()
+		}()
+
+		return next(ctx, request)
+	}
+}
+
+// handleCancelled cancels the in-flight call named by a notifications/cancelled message
+func (t *cancelTracker) handleCancelled(ctx context.Context, notification mcp.JSONRPCNotification) {
+	id, ok := notification.Params.AdditionalFields["requestId"]
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	cancel, ok := t.inFlight[requestKey(ctx, requestIDString(id))]
+	t.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+	"github.com/richinsley/jumpboot-mcp/internal/tools"
+)
+
+// testSession is a minimal MCP client session
+type testSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func newTestSession(id string) *testSession {
+	return &testSession{id: id, notifications: make(chan mcp.JSONRPCNotification, 16)}
+}
+
+func (s *testSession) Initialize()                                         {}
+func (s *testSession) Initialized() bool                                   { return true }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *testSession) SessionID() string                                   { return s.id }
+
+func TestNotificationCancelsInFlightCall(t *testing.T) {
+	mgr, err := manager.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{}, 1)
+	blockTool := tools.ToolDef{
+		Tool: mcp.NewTool("block_until_cancelled"),
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			started <- struct{}{}
+			select {
+			case <-ctx.Done():
+				return mcp.NewToolResultText("cancelled"), nil
+			case <-time.After(5 * time.Second):
+				return mcp.NewToolResultText("not cancelled"), nil
+			}
+		},
+	}
+	s := NewWithOptions(mgr, []tools.ToolDef{blockTool}, Options{})
+
+	for _, id := range []string{`7`, `"call-7"`} {
+		t.Run(id, func(t *testing.T) {
+			session := newTestSession("session-1")
+			ctx := s.WithContext(context.Background(), session)
+
+			done := make(chan mcp.JSONRPCMessage, 1)
+			go func() {
+				done <- s.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":`+id+
+					`,"method":"tools/call","params":{"name":"block_until_cancelled","arguments":{}}}`))
+			}()
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("tool call never started")
+			}
+
+			// A notification from another session or for another request is ignored
+			other := s.WithContext(context.Background(), newTestSession("session-2"))
+			s.HandleMessage(other, json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":`+id+`}}`))
+			s.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":8}}`))
+			select {
+			case <-done:
+				t.Fatal("call ended on a notification for another call")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			s.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":`+id+`,"reason":"user abort"}}`))
+			select {
+			case msg := <-done:
+				data, _ := json.Marshal(msg)
+				var resp struct {
+					Result mcp.CallToolResult `json:"result"`
+				}
+				if err := json.Unmarshal(data, &resp); err != nil {
+					t.Fatal(err)
+				}
+				if len(resp.Result.Content) == 0 {
+					t.Fatalf("unexpected response %s", data)
+				}
+				if text, _ := resp.Result.Content[0].(mcp.TextContent); text.Text != "cancelled" {
+					t.Errorf("tool saw %q, want its context cancelled", text.Text)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("notifications/cancelled did not cancel the call")
+			}
+		})
+	}
+}
+
+func TestCancelTrackerMatchesRequestIDForms(t *testing.T) {
+	// The hook may see the ID as an mcp.RequestId or as the decoded JSON value, while
+	// the notification always carries the decoded value
+	tests := []struct {
+		name         string
+		hookID       any
+		notification any
+	}{
+		{"RequestId int64", mcp.NewRequestId(int64(5)), float64(5)},
+		{"RequestId pointer", func() any { id := mcp.NewRequestId(int64(5)); return &id }(), float64(5)},
+		{"float64", float64(5), float64(5)},
+		{"RequestId string", mcp.NewRequestId("abc"), "abc"},
+		{"string", "abc", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newCancelTracker()
+			ctx := context.Background()
+			var request mcp.CallToolRequest
+			tracker.tagRequest(ctx, tt.hookID, &request)
+
+			cancelled := make(chan bool, 1)
+			handler := tracker.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				var notification mcp.JSONRPCNotification
+				notification.Params.AdditionalFields = map[string]any{"requestId": tt.notification}
+				tracker.handleCancelled(ctx, notification)
+				cancelled <- ctx.Err() != nil
+				return nil, nil
+			})
+			handler(ctx, request)
+			if !<-cancelled {
+				t.Errorf("hook ID %#v and notification ID %#v did not match", tt.hookID, tt.notification)
+			}
+		})
+	}
+}
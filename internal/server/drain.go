@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+	"github.com/richinsley/jumpboot-mcp/internal/tools"
+)
+
+// drainMiddleware refuses new tool calls while the server drains for shutdown and
+// counts the calls in flight, which the drain waits for. Read-only tools keep
+// answering, so clients can still collect job results and watch server_status.
+func drainMiddleware(mgr *manager.Manager, lookup tools.ToolLookup) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if tool, ok := lookup(request.Params.Name); ok && tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint {
+				return next(ctx, request)
+			}
+			done, err := mgr.BeginToolCall()
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			defer done()
+			return next(ctx, request)
+		}
+	}
+}
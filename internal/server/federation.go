@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/discovery"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+type federatedKey struct{}
+
+// ExportFilter decides which local tools federated sessions (other jumpboot-mcp
+// instances proxying this server) may list and call. A nil filter exports every tool.
+type ExportFilter struct {
+	allow []string // glob patterns; empty allows every tool not denied
+	deny  []string // glob patterns, checked first
+}
+
+// ParseExportFilter builds a filter from tool names or glob patterns. Entries starting
+// with "!" deny matching tools. Without allow entries, every tool not denied is exported.
+// No entries at all return a nil filter.
+func ParseExportFilter(entries []string) (*ExportFilter, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	f := &ExportFilter{}
+	for _, entry := range entries {
+		pattern, deny := strings.CutPrefix(entry, "!")
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("invalid tool pattern %q", entry)
+		}
+		if deny {
+			f.deny = append(f.deny, pattern)
+		} else {
+			f.allow = append(f.allow, pattern)
+		}
+	}
+	return f, nil
+}
+
+// Allows reports whether a tool is exported
+func (f *ExportFilter) Allows(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, pattern := range f.deny {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, pattern := range f.allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// withFederationHeader marks the context of a request sent by a federation proxy and
+// records the proxies it passed through
+func withFederationHeader(ctx context.Context, r *http.Request) context.Context {
+	if r.Header.Get(discovery.FederationHeader) != "" {
+		ctx = context.WithValue(ctx, federatedKey{}, true)
+	}
+	if chain := discovery.ParseOrigin(r.Header.Get(discovery.OriginHeader)); len(chain) > 0 {
+		ctx = discovery.WithOrigin(ctx, chain)
+	}
+	return ctx
+}
+
+// checkOrigin refuses requests that this process forwarded itself, or that passed
+// through more than maxHops proxies
+func checkOrigin(ctx context.Context, maxHops int) error {
+	chain := discovery.OriginFromContext(ctx)
+	if slices.Contains(chain, discovery.InstanceID) {
+		return manager.WithErrorCode(manager.CodeUnsupported, fmt.Errorf("federation loop: the request was forwarded by this server"))
+	}
+	if maxHops <= 0 {
+		maxHops = discovery.DefaultMaxHops
+	}
+	if len(chain) > maxHops {
+		return manager.WithErrorCode(manager.CodeUnsupported, fmt.Errorf("request passed through %d federation proxies (limit %d)", len(chain), maxHops))
+	}
+	return nil
+}
+
+// isFederated reports whether the request in ctx comes from a federation proxy, known
+// by its MCP client name or, in stateless mode, by the header it sends
+func isFederated(ctx context.Context) bool {
+	if federated, _ := ctx.Value(federatedKey{}).(bool); federated {
+		return true
+	}
+	if session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo); ok {
+		return session.GetClientInfo().Name == discovery.FederationClientName
+	}
+	return false
+}
+
+// exportToolFilter hides tools that are not exported from the tool list of federated
+// sessions, and all tools from requests that loop or exceed the hop limit
+func exportToolFilter(f *ExportFilter, maxHops int) server.ToolFilterFunc {
+	return func(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+		if checkOrigin(ctx, maxHops) != nil {
+			return []mcp.Tool{}
+		}
+		if !isFederated(ctx) {
+			return tools
+		}
+		exported := make([]mcp.Tool, 0, len(tools))
+		for _, tool := range tools {
+			if f.Allows(tool.Name) {
+				exported = append(exported, tool)
+			}
+		}
+		return exported
+	}
+}
+
+// exportMiddleware rejects calls from federated sessions to tools that are not
+// exported, and calls that loop or exceed the hop limit
+func exportMiddleware(f *ExportFilter, maxHops int) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if err := checkOrigin(ctx, maxHops); err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			if isFederated(ctx) && !f.Allows(request.Params.Name) {
+				err := manager.WithErrorCode(manager.CodePermissionDenied, fmt.Errorf("tool %s is not exported to federated servers", request.Params.Name))
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// originResourceMiddleware refuses resource reads that loop or exceed the hop limit
+func originResourceMiddleware(maxHops int) server.ResourceHandlerMiddleware {
+	return func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+		return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			if err := checkOrigin(ctx, maxHops); err != nil {
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
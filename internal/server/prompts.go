@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// PromptDef pairs a prompt with its handler
+type PromptDef struct {
+	Prompt  mcp.Prompt
+	Handler server.PromptHandlerFunc
+}
+
+// Prompts returns the built-in prompt templates for common workflows
+func Prompts() []PromptDef {
+	return []PromptDef{
+		{
+			Prompt: mcp.NewPrompt("data_science_setup",
+				mcp.WithPromptDescription("Set up a data-science environment with a persistent REPL"),
+				mcp.WithArgument("python_version", mcp.ArgumentDescription("Python version (e.g., '3.11'). Default: '3.11'")),
+				mcp.WithArgument("extra_packages", mcp.ArgumentDescription("Comma-separated packages to install in addition to the standard stack")),
+			),
+			Handler: dataScienceSetupPrompt,
+		},
+		{
+			Prompt: mcp.NewPrompt("clone_and_test",
+				mcp.WithPromptDescription("Clone a git repository into a fresh environment, install its dependencies and run its tests"),
+				mcp.WithArgument("repo_url", mcp.RequiredArgument(), mcp.ArgumentDescription("Git repository URL")),
+				mcp.WithArgument("python_version", mcp.ArgumentDescription("Python version (e.g., '3.11'). Default: '3.11'")),
+				mcp.WithArgument("test_command", mcp.ArgumentDescription("Test entry point to run, as a module invocation. Default: 'pytest'")),
+			),
+			Handler: cloneAndTestPrompt,
+		},
+		{
+			Prompt: mcp.NewPrompt("debug_script",
+				mcp.WithPromptDescription("Diagnose and fix a failing Python script in a workspace"),
+				mcp.WithArgument("script", mcp.RequiredArgument(), mcp.ArgumentDescription("Workspace path of the failing script")),
+				mcp.WithArgument("env_id", mcp.ArgumentDescription("Environment the script runs in (omit to pick one with list_environments)")),
+				mcp.WithArgument("error", mcp.ArgumentDescription("Error message or traceback observed, if known")),
+			),
+			Handler: debugScriptPrompt,
+		},
+	}
+}
+
+// promptArg returns a prompt argument or its default
+func promptArg(request mcp.GetPromptRequest, name, defaultValue string) string {
+	if v := strings.TrimSpace(request.Params.Arguments[name]); v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+// promptResult builds a single user-message prompt result
+func promptResult(description, text string) *mcp.GetPromptResult {
+	return mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+	})
+}
+
+func dataScienceSetupPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	pythonVersion := promptArg(request, "python_version", "3.11")
+
+	packages := []string{"numpy", "pandas", "matplotlib", "scipy", "scikit-learn"}
+	if extra := promptArg(request, "extra_packages", ""); extra != "" {
+		for _, pkg := range strings.Split(extra, ",") {
+			if pkg = strings.TrimSpace(pkg); pkg != "" {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	pkgList := strings.Join(packages, ", ")
+	text := fmt.Sprintf(`Set up a data-science environment using the jumpboot tools:
+
+1. Call find_environment with packages %s and python_version "%s" to check whether a suitable environment already exists. If one does, reuse its env_id, call workspace_create and repl_create (session_name "analysis") for it, and skip to step 4.
+2. Call create_environment with a descriptive name, python_version "%s" and with_repl true. Note the returned id and repl.id; the workspace is created too, so data files and notebooks have a home.
+3. Call install_packages with that env_id and packages %s.
+4. Use repl_execute with the REPL session_id to import the packages and print their versions to confirm the setup.
+
+Report the env_id, the REPL session_id and the installed versions when done.`,
+		pkgList, pythonVersion, pythonVersion, pkgList)
+
+	return promptResult("Data-science environment setup", text), nil
+}
+
+func cloneAndTestPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	repoURL := promptArg(request, "repo_url", "")
+	if repoURL == "" {
+		return nil, fmt.Errorf("repo_url is required")
+	}
+	pythonVersion := promptArg(request, "python_version", "3.11")
+	testCommand := promptArg(request, "test_command", "pytest")
+
+	text := fmt.Sprintf(`Clone and test %s using the jumpboot tools:
+
+1. Call create_environment with python_version "%s" and a name derived from the repository. Note the env_id.
+2. Call workspace_create for the env_id, then workspace_git_clone with repo_url "%s".
+3. Use workspace_list_files on the cloned directory to find the dependency files (requirements*.txt, pyproject.toml, setup.py).
+4. Install dependencies: use install_requirements for requirements files, or install_packages for what pyproject.toml/setup.py declare. Make sure "%s" itself is installed.
+5. Write a small runner with workspace_write_file that runs [sys.executable, "-m", "%s"] via subprocess with cwd set to the cloned directory and prints its output, then execute it with workspace_run_script.
+6. Summarize the results: number of tests passed/failed and the first failure's traceback, if any.`,
+		repoURL, pythonVersion, repoURL, testCommand, testCommand)
+
+	return promptResult("Clone a repository and run its tests", text), nil
+}
+
+func debugScriptPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	script := promptArg(request, "script", "")
+	if script == "" {
+		return nil, fmt.Errorf("script is required")
+	}
+
+	envStep := "Call list_environments and pick the environment whose workspace contains the script."
+	if envID := promptArg(request, "env_id", ""); envID != "" {
+		envStep = fmt.Sprintf("Use environment %s.", envID)
+	}
+
+	errorNote := "Run it first to reproduce the failure."
+	if errText := promptArg(request, "error", ""); errText != "" {
+		errorNote = fmt.Sprintf("The reported error is:\n\n%s\n\nStill run it once to confirm the failure reproduces.", errText)
+	}
+
+	text := fmt.Sprintf(`Debug the failing script %s using the jumpboot tools:
+
+1. %s
+2. Read the script with workspace_read_file.
+3. %s Use workspace_run_script and capture the full traceback.
+4. Form a hypothesis. Verify it with small experiments in a REPL (repl_create, repl_execute) rather than by guessing, e.g. inspect inputs, types and package versions (list_packages).
+5. Fix the script with workspace_write_file. If a dependency is missing or incompatible, use install_packages.
+6. Re-run the script to confirm the fix, then explain the root cause and the change you made.`,
+		script, envStep, errorNote)
+
+	return promptResult("Debug a failing script", text), nil
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+	"github.com/richinsley/jumpboot-mcp/internal/tools"
+)
+
+// Roles, from least to most privileged
+const (
+	RoleReader = "reader" // read-only tools
+	RoleRunner = "runner" // everything except destructive calls on others' environments
+	RoleAdmin  = "admin"  // everything, including admin tools
+)
+
+// errNoRole is returned for calls without a recognized role token
+var errNoRole = manager.WithErrorCode(manager.CodePermissionDenied, errors.New("a bearer token with a role is required"))
+
+// RoleSpec is an entry of the -roles file. Tokens are best read from the server's
+// environment with token_env.
+type RoleSpec struct {
+	Name     string `json:"name"` // principal recorded for the token's calls
+	Role     string `json:"role"`
+	Token    string `json:"token,omitempty"`
+	TokenEnv string `json:"token_env,omitempty"`
+}
+
+// roleGrant is a token's principal and role
+type roleGrant struct {
+	name  string
+	role  string
+	token string
+}
+
+// RolePolicy maps bearer tokens to roles. A nil policy disables role checks.
+type RolePolicy struct {
+	grants []roleGrant
+}
+
+// LoadRolePolicy reads a JSON array of RoleSpec
+func LoadRolePolicy(path string) (*RolePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roles file: %w", err)
+	}
+	var specs []RoleSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse roles file %s: %w", path, err)
+	}
+
+	p := &RolePolicy{}
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("%s: every role token needs a name", path)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("%s: duplicate name %s", path, spec.Name)
+		}
+		switch spec.Role {
+		case RoleReader, RoleRunner, RoleAdmin:
+		default:
+			return nil, fmt.Errorf("%s: %s: unknown role %q (use %s, %s or %s)", path, spec.Name, spec.Role, RoleReader, RoleRunner, RoleAdmin)
+		}
+		token := spec.Token
+		if spec.TokenEnv != "" {
+			if token = os.Getenv(spec.TokenEnv); token == "" {
+				return nil, fmt.Errorf("%s: %s: environment variable %s is not set", path, spec.Name, spec.TokenEnv)
+			}
+		}
+		if token == "" {
+			return nil, fmt.Errorf("%s: %s has no token", path, spec.Name)
+		}
+		if tokens[token] {
+			return nil, fmt.Errorf("%s: %s reuses another entry's token", path, spec.Name)
+		}
+		names[spec.Name], tokens[token] = true, true
+		p.grants = append(p.grants, roleGrant{name: spec.Name, role: spec.Role, token: token})
+	}
+	return p, nil
+}
+
+// grant returns the principal and role of a token. Every grant is compared so the
+// time taken does not reveal which one matched.
+func (p *RolePolicy) grant(token string) (roleGrant, bool) {
+	var found roleGrant
+	ok := false
+	for _, g := range p.grants {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) == 1 {
+			found, ok = g, true
+		}
+	}
+	return found, ok
+}
+
+// rbacMiddleware refuses calls the caller's role does not allow: callers without a
+// role token get nothing, readers only read-only tools, and runners no destructive or
+// configuration calls on environments another principal created, whichever argument
+// leads to them. Admin tools check the admin role in the Manager.
+func rbacMiddleware(mgr *manager.Manager, adminToken string, roles *RolePolicy, lookup tools.ToolLookup) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		if roles == nil {
+			return next
+		}
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			_, caller := withCaller(ctx, adminToken, roles)
+			if err := checkRole(mgr, caller, request, lookup); err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// configTools change how an environment behaves for everyone using it without
+// destroying anything, so runners may only call them on their own environments
+var configTools = map[string]bool{
+	"environment_set_network": true,
+	"environment_set_vars":    true,
+	"lock_environment":        true,
+	"workspace_attach":        true,
+	"workspace_mount":         true,
+}
+
+// environmentOwners finds the environments a call refers to and who created them
+type environmentOwners interface {
+	EnvironmentCreator(envID string) (string, error)
+	ResourceEnvironment(kind, id string) (envID string, ok bool)
+}
+
+// checkRole applies the role rules to a tool call
+func checkRole(mgr environmentOwners, caller manager.Caller, request mcp.CallToolRequest, lookup tools.ToolLookup) error {
+	name := request.Params.Name
+	tool, ok := lookup(name)
+	if !ok {
+		return manager.WithErrorCode(manager.CodeNotFound, fmt.Errorf("tool not found: %s", name))
+	}
+	readOnly := tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint
+	destructive := tool.Annotations.DestructiveHint == nil || *tool.Annotations.DestructiveHint
+
+	switch caller.Role {
+	case RoleAdmin:
+		return nil
+	case RoleReader:
+		if !readOnly {
+			return manager.WithErrorCode(manager.CodePermissionDenied, fmt.Errorf("role %s may only call read-only tools, not %s", RoleReader, name))
+		}
+		return nil
+	case RoleRunner:
+		if readOnly || (!destructive && !configTools[name]) {
+			return nil
+		}
+		args := request.GetArguments()
+		// The ownership of an environment on another server cannot be checked here
+		if destroy, _ := args["destroy_source"].(bool); destroy {
+			if server, _ := args["source_server"].(string); server != "" {
+				return manager.WithErrorCode(manager.CodePermissionDenied, fmt.Errorf("role %s may not destroy the source environment on another server", RoleRunner))
+			}
+		}
+		for _, envID := range referencedEnvironments(mgr, args) {
+			creator, err := mgr.EnvironmentCreator(envID)
+			if err != nil {
+				// Let the tool report unknown environments
+				continue
+			}
+			if creator != caller.Principal {
+				return manager.WithErrorCode(manager.CodePermissionDenied, fmt.Errorf("role %s may not call %s on environment %s created by another principal", RoleRunner, name, envID))
+			}
+		}
+		return nil
+	}
+	return errNoRole
+}
+
+// envArgs are the tool arguments naming an environment
+var envArgs = []string{"env_id", "source_env_id", "workdir_env_id"}
+
+// resourceArgs are the tool arguments naming a resource that belongs to an environment
+var resourceArgs = map[string]string{
+	"session_id":  manager.ResourceREPL,
+	"process_id":  manager.ResourceProcess,
+	"job_id":      manager.ResourceJob,
+	"terminal_id": manager.ResourceTerminal,
+	"debug_id":    manager.ResourceDebug,
+}
+
+// referencedEnvironments returns the environments a call's arguments name, directly or
+// through the REPL sessions, processes, jobs, terminals and debug sessions they name
+func referencedEnvironments(mgr environmentOwners, args map[string]any) []string {
+	var envIDs []string
+	for _, arg := range envArgs {
+		if id, _ := args[arg].(string); id != "" {
+			envIDs = append(envIDs, id)
+		}
+	}
+	if ids, ok := args["env_ids"].([]any); ok {
+		for _, v := range ids {
+			if id, _ := v.(string); id != "" {
+				envIDs = append(envIDs, id)
+			}
+		}
+	}
+	for arg, kind := range resourceArgs {
+		if id, _ := args[arg].(string); id != "" {
+			if envID, ok := mgr.ResourceEnvironment(kind, id); ok {
+				envIDs = append(envIDs, envID)
+			}
+		}
+	}
+	return envIDs
+}
+
+// rbacResourceMiddleware refuses resource reads from callers without a role token
+func rbacResourceMiddleware(adminToken string, roles *RolePolicy) server.ResourceHandlerMiddleware {
+	return func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+		if roles == nil {
+			return next
+		}
+		return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			if _, caller := withCaller(ctx, adminToken, roles); caller.Role == "" {
+				return nil, errNoRole
+			}
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// testOwners maps environment IDs to their creators
+type testOwners map[string]string
+
+func (o testOwners) EnvironmentCreator(envID string) (string, error) {
+	creator, ok := o[envID]
+	if !ok {
+		return "", errors.New("environment not found")
+	}
+	return creator, nil
+}
+
+func (o testOwners) ResourceEnvironment(kind, id string) (string, bool) {
+	return "", false
+}
+
+func TestRunnerRestrictedOnForeignEnvironments(t *testing.T) {
+	mgr, err := manager.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs := make(map[string]mcp.Tool)
+	for _, td := range localTools(mgr, Options{}, nil, nil) {
+		defs[td.Tool.Name] = td.Tool
+	}
+	lookup := func(name string) (mcp.Tool, bool) {
+		tool, ok := defs[name]
+		return tool, ok
+	}
+	owners := testOwners{"mine": "alice", "theirs": "bob"}
+	runner := manager.Caller{Principal: "alice", Role: RoleRunner}
+	admin := manager.Caller{Principal: "carol", Role: RoleAdmin}
+
+	tests := []struct {
+		tool    string
+		allowed bool // on bob's environment
+	}{
+		{"environment_set_network", false},
+		{"environment_set_vars", false},
+		{"lock_environment", false},
+		{"unlock_environment", false},
+		{"workspace_mount", false},
+		{"workspace_attach", false},
+		{"workspace_sync_remote", false},
+		{"destroy_environment", false},
+		{"run_code", true},
+		{"workspace_read_file", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			if _, ok := defs[tt.tool]; !ok {
+				t.Fatalf("no tool %s", tt.tool)
+			}
+			call := func(caller manager.Caller, envID string) error {
+				var request mcp.CallToolRequest
+				request.Params.Name = tt.tool
+				request.Params.Arguments = map[string]any{"env_id": envID}
+				return checkRole(owners, caller, request, lookup)
+			}
+			if err := call(runner, "mine"); err != nil {
+				t.Errorf("runner refused on their own environment: %v", err)
+			}
+			err := call(runner, "theirs")
+			if tt.allowed && err != nil {
+				t.Errorf("runner refused on another's environment: %v", err)
+			}
+			if !tt.allowed && err == nil {
+				t.Error("runner allowed on another's environment")
+			}
+			if err := call(admin, "theirs"); err != nil {
+				t.Errorf("admin refused: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// redactMiddleware replaces the values of the server's secrets in tool results, so
+// code printing a secret injected into its environment does not reveal it to the client
+func redactMiddleware(mgr *manager.Manager) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			result, err := next(ctx, request)
+			if err != nil {
+				return nil, errors.New(mgr.RedactSecrets(err.Error()))
+			}
+			if result != nil {
+				for i, content := range result.Content {
+					result.Content[i] = redactContent(mgr, content)
+				}
+			}
+			return result, nil
+		}
+	}
+}
+
+// redactResourceMiddleware replaces the values of the server's secrets in resource
+// contents, such as workspace files written by a process
+func redactResourceMiddleware(mgr *manager.Manager) server.ResourceHandlerMiddleware {
+	return func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+		return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			contents, err := next(ctx, request)
+			if err != nil {
+				return nil, errors.New(mgr.RedactSecrets(err.Error()))
+			}
+			for i, c := range contents {
+				contents[i] = redactResource(mgr, c)
+			}
+			return contents, nil
+		}
+	}
+}
+
+// redactContent redacts text content and embedded text resources
+func redactContent(mgr *manager.Manager, content mcp.Content) mcp.Content {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		c.Text = mgr.RedactSecrets(c.Text)
+		return c
+	case *mcp.TextContent:
+		c.Text = mgr.RedactSecrets(c.Text)
+	case mcp.EmbeddedResource:
+		c.Resource = redactResource(mgr, c.Resource)
+		return c
+	case *mcp.EmbeddedResource:
+		c.Resource = redactResource(mgr, c.Resource)
+	}
+	return content
+}
+
+// redactResource redacts text resource contents; binary contents are returned as is
+func redactResource(mgr *manager.Manager, contents mcp.ResourceContents) mcp.ResourceContents {
+	switch c := contents.(type) {
+	case mcp.TextResourceContents:
+		c.Text = mgr.RedactSecrets(c.Text)
+		return c
+	case *mcp.TextResourceContents:
+		c.Text = mgr.RedactSecrets(c.Text)
+	}
+	return contents
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+	"github.com/richinsley/jumpboot-mcp/internal/tools"
+)
+
+// WorkspaceURIPrefix starts the URI of every workspace file resource
+const WorkspaceURIPrefix = manager.WorkspaceURIPrefix
+
+// ResourceTemplates returns the resource templates served by this process
+func ResourceTemplates(mgr *manager.Manager) []tools.ResourceTemplateDef {
+	return []tools.ResourceTemplateDef{
+		{
+			Template: mcp.NewResourceTemplate(WorkspaceURIPrefix+"{env_id}/{+path}", "workspace_file",
+				mcp.WithTemplateDescription("A file in an environment's workspace. A path ending in / (or empty) returns the directory listing as JSON."),
+			),
+			Handler: workspaceResourceHandler(mgr),
+		},
+	}
+}
+
+// templateArg returns a variable matched from a resource URI
+func templateArg(request mcp.ReadResourceRequest, name string) string {
+	switch v := request.Params.Arguments[name].(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return ""
+}
+
+func workspaceResourceHandler(mgr *manager.Manager) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		envID := templateArg(request, "env_id")
+		filename, err := url.PathUnescape(templateArg(request, "path"))
+		if envID == "" || err != nil {
+			return nil, fmt.Errorf("invalid workspace resource URI: %s", request.Params.URI)
+		}
+		if err := mgr.CheckEnvironmentAccess(ctx, envID); err != nil {
+			return nil, err
+		}
+
+		if filename == "" || strings.HasSuffix(filename, "/") {
+			files, err := mgr.ListWorkspaceFiles(envID, strings.TrimSuffix(filename, "/"))
+			if err != nil {
+				return nil, err
+			}
+			data, _ := json.Marshal(files)
+			return []mcp.ResourceContents{mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(data),
+			}}, nil
+		}
+
+		content, err := mgr.ReadWorkspaceFile(envID, filename)
+		if err != nil {
+			return nil, err
+		}
+		mimeType := mime.TypeByExtension(path.Ext(filename))
+		if !utf8.ValidString(content) {
+			if mimeType == "" {
+				mimeType = "application/octet-stream"
+			}
+			return []mcp.ResourceContents{mcp.BlobResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: mimeType,
+				Blob:     base64.StdEncoding.EncodeToString([]byte(content)),
+			}}, nil
+		}
+		if mimeType == "" {
+			mimeType = "text/plain"
+		}
+		return []mcp.ResourceContents{mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: mimeType,
+			Text:     content,
+		}}, nil
+	}
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/richinsley/jumpboot-mcp/internal/manager"
@@ -12,6 +14,31 @@ const (
 	ServerVersion = "1.0.0"
 )
 
+// Options configures optional server behavior
+type Options struct {
+	// DescriptionMaxLen limits the length of local tool descriptions (0 = unlimited)
+	DescriptionMaxLen int
+
+	// Remotes gives local tools access to federated servers (nil when not federating)
+	Remotes tools.RemoteServerProvider
+
+	// AdminToken grants access to resources of all sessions when presented as a bearer token
+	AdminToken string
+
+	// Roles maps bearer tokens to reader, runner and admin roles (nil = no role checks)
+	Roles *RolePolicy
+
+	// FederationExport limits the local tools federated sessions can list and call (nil = all)
+	FederationExport *ExportFilter
+
+	// RemoteResources are resource templates proxied from remote servers
+	RemoteResources []tools.ResourceTemplateDef
+
+	// FederationMaxHops is the number of proxies a request may pass through before
+	// reaching this server (0 = discovery.DefaultMaxHops)
+	FederationMaxHops int
+}
+
 // New creates and configures a new MCP server with all tools
 func New(mgr *manager.Manager) *server.MCPServer {
 	return NewWithExtraTools(mgr, nil)
@@ -19,59 +46,160 @@ func New(mgr *manager.Manager) *server.MCPServer {
 
 // NewWithExtraTools creates a new MCP server with local tools plus additional tools (e.g., proxied remote tools)
 func NewWithExtraTools(mgr *manager.Manager, extraTools []tools.ToolDef) *server.MCPServer {
-	s := server.NewMCPServer(
+	return NewWithOptions(mgr, extraTools, Options{})
+}
+
+// NewWithOptions creates a new MCP server with local tools, additional tools and the given options
+func NewWithOptions(mgr *manager.Manager, extraTools []tools.ToolDef, opts Options) *server.MCPServer {
+	mgr.SetVersion(ServerVersion)
+
+	// Abort in-flight tool calls when the client sends notifications/cancelled
+	cancels := newCancelTracker()
+	hooks := &server.Hooks{}
+	hooks.AddBeforeCallTool(cancels.tagRequest)
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		mgr.SessionDisconnected(session.SessionID())
+	})
+
+	// The audit middleware looks tools up on the server created below
+	var s *server.MCPServer
+	lookup := func(name string) (mcp.Tool, bool) { return serverToolLookup(s)(name) }
+
+	s = server.NewMCPServer(
 		ServerName,
 		ServerVersion,
 		server.WithToolCapabilities(true),
+		server.WithPromptCapabilities(false),
+		server.WithResourceCapabilities(false, false),
+		server.WithHooks(hooks),
+		// The first middleware is outermost: refuse unexported tools before anything runs
+		server.WithToolHandlerMiddleware(exportMiddleware(opts.FederationExport, opts.FederationMaxHops)),
+		server.WithToolHandlerMiddleware(drainMiddleware(mgr, lookup)),
+		server.WithToolHandlerMiddleware(auditMiddleware(mgr, opts.AdminToken, opts.Roles, lookup)),
+		server.WithToolHandlerMiddleware(rbacMiddleware(mgr, opts.AdminToken, opts.Roles, lookup)),
+		server.WithToolHandlerMiddleware(spoolMiddleware(mgr, opts.AdminToken, opts.Roles)),
+		server.WithToolHandlerMiddleware(redactMiddleware(mgr)),
+		server.WithToolHandlerMiddleware(cancels.middleware),
+		server.WithToolHandlerMiddleware(callerMiddleware(mgr, opts.AdminToken, opts.Roles)),
+		server.WithResourceHandlerMiddleware(originResourceMiddleware(opts.FederationMaxHops)),
+		server.WithResourceHandlerMiddleware(redactResourceMiddleware(mgr)),
+		server.WithResourceHandlerMiddleware(rbacResourceMiddleware(opts.AdminToken, opts.Roles)),
+		server.WithResourceHandlerMiddleware(callerResourceMiddleware(mgr, opts.AdminToken, opts.Roles)),
+		server.WithToolFilter(exportToolFilter(opts.FederationExport, opts.FederationMaxHops)),
 	)
+	s.AddNotificationHandler("notifications/cancelled", cancels.handleCancelled)
 
 	// Register all local tools
 	// If we have remote tools, prefix local tool descriptions with "[local]"
 	hasRemoteTools := len(extraTools) > 0
-	registerToolsWithPrefix(s, mgr, hasRemoteTools)
+	registerToolsWithPrefix(s, mgr, hasRemoteTools, opts, serverToolLookup(s), serverToolNames(s))
 
 	// Register extra tools (e.g., proxied remote tools)
 	for _, td := range extraTools {
 		s.AddTool(td.Tool, td.Handler)
 	}
 
+	// Register federation tools whenever remotes are configured or discovered, even if
+	// none could be connected, so clients can see why
+	for _, td := range tools.RegisterFederationTools(opts.Remotes) {
+		s.AddTool(td.Tool, td.Handler)
+	}
+
+	// Register workspace file resources and those of remote servers
+	for _, rd := range append(ResourceTemplates(mgr), opts.RemoteResources...) {
+		s.AddResourceTemplate(rd.Template, rd.Handler)
+	}
+
+	// Register built-in workflow prompts
+	for _, pd := range Prompts() {
+		s.AddPrompt(pd.Prompt, pd.Handler)
+	}
+
 	return s
 }
 
-func registerToolsWithPrefix(s *server.MCPServer, mgr *manager.Manager, addLocalPrefix bool) {
+func registerToolsWithPrefix(s *server.MCPServer, mgr *manager.Manager, addLocalPrefix bool, opts Options, lookup tools.ToolLookup, names tools.ToolNames) {
 	// Collect all tool definitions
-	allTools := []tools.ToolDef{}
-	allTools = append(allTools, tools.RegisterEnvironmentTools(mgr)...)
-	allTools = append(allTools, tools.RegisterPackageTools(mgr)...)
-	allTools = append(allTools, tools.RegisterExecutionTools(mgr)...)
-	allTools = append(allTools, tools.RegisterREPLTools(mgr)...)
-	allTools = append(allTools, tools.RegisterWorkspaceTools(mgr)...)
-	allTools = append(allTools, tools.RegisterProcessTools(mgr)...)
+	allTools := localTools(mgr, opts, lookup, names)
 
 	// Register each tool with the server
 	for _, td := range allTools {
+		tool := td.Tool
+		tool.Description = tools.SummarizeDescription(tool.Description, opts.DescriptionMaxLen)
 		if addLocalPrefix {
-			// Create a copy with "[local]" prefix in description
-			localTool := mcp.NewTool(td.Tool.Name,
-				mcp.WithDescription("[local] "+td.Tool.Description),
-			)
-			localTool.InputSchema = td.Tool.InputSchema
-			s.AddTool(localTool, td.Handler)
-		} else {
-			s.AddTool(td.Tool, td.Handler)
+			// Add "[local]" prefix in description
+			tool.Description = "[local] " + tool.Description
 		}
+		s.AddTool(tool, td.Handler)
 	}
 }
 
-// ServerTools returns all registered tools for inspection
-func ServerTools(mgr *manager.Manager) []mcp.Tool {
+// serverToolLookup finds a tool registered with s, including proxied remote tools
+func serverToolLookup(s *server.MCPServer) tools.ToolLookup {
+	return func(name string) (mcp.Tool, bool) {
+		st := s.GetTool(name)
+		if st == nil {
+			return mcp.Tool{}, false
+		}
+		return st.Tool, true
+	}
+}
+
+// serverToolNames lists the tools registered with s
+func serverToolNames(s *server.MCPServer) tools.ToolNames {
+	return func() []string {
+		registered := s.ListTools()
+		names := make([]string, 0, len(registered))
+		for name := range registered {
+			names = append(names, name)
+		}
+		return names
+	}
+}
+
+// LocalToolNames returns the names of the tools served by this process, which proxied
+// remote tools must not take
+func LocalToolNames(mgr *manager.Manager, opts Options) []string {
+	var names []string
+	for _, td := range localTools(mgr, opts, nil, nil) {
+		names = append(names, td.Tool.Name)
+	}
+	return names
+}
+
+// localTools collects the definitions of all tools served by this process. lookup and
+// names let validate_call see every tool registered with the server.
+func localTools(mgr *manager.Manager, opts Options, lookup tools.ToolLookup, names tools.ToolNames) []tools.ToolDef {
 	allTools := []tools.ToolDef{}
-	allTools = append(allTools, tools.RegisterEnvironmentTools(mgr)...)
+	allTools = append(allTools, tools.RegisterEnvironmentTools(mgr, opts.Remotes)...)
+	allTools = append(allTools, tools.RegisterEnvironmentSearchTools(mgr, opts.Remotes)...)
+	allTools = append(allTools, tools.RegisterMigrationTools(mgr, opts.Remotes)...)
+	allTools = append(allTools, tools.RegisterDistributedTools(mgr, opts.Remotes)...)
+	allTools = append(allTools, tools.RegisterManifestTools(mgr, opts.Remotes)...)
 	allTools = append(allTools, tools.RegisterPackageTools(mgr)...)
 	allTools = append(allTools, tools.RegisterExecutionTools(mgr)...)
+	allTools = append(allTools, tools.RegisterLintTools(mgr)...)
 	allTools = append(allTools, tools.RegisterREPLTools(mgr)...)
 	allTools = append(allTools, tools.RegisterWorkspaceTools(mgr)...)
 	allTools = append(allTools, tools.RegisterProcessTools(mgr)...)
+	allTools = append(allTools, tools.RegisterTerminalTools(mgr)...)
+	allTools = append(allTools, tools.RegisterDebugTools(mgr)...)
+	allTools = append(allTools, tools.RegisterProfileTools(mgr)...)
+	allTools = append(allTools, tools.RegisterJobTools(mgr)...)
+	allTools = append(allTools, tools.RegisterResultTools(mgr)...)
+	allTools = append(allTools, tools.RegisterScheduleTools(mgr)...)
+	allTools = append(allTools, tools.RegisterExperimentTools(mgr)...)
+	allTools = append(allTools, tools.RegisterSessionTools(mgr)...)
+	allTools = append(allTools, tools.RegisterSecretTools(mgr)...)
+	allTools = append(allTools, tools.RegisterAuditTools(mgr)...)
+	allTools = append(allTools, tools.RegisterValidateTools(lookup, names, opts.Remotes)...)
+	return allTools
+}
+
+// ServerTools returns all registered tools for inspection
+func ServerTools(mgr *manager.Manager) []mcp.Tool {
+	// validate_call is only listed here, never called
+	allTools := localTools(mgr, Options{}, nil, nil)
 
 	result := make([]mcp.Tool, len(allTools))
 	for i, td := range allTools {
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+type authTokenKey struct{}
+
+// HTTPContextFunc copies the bearer token of an HTTP request into the context
+// so tool middleware can authenticate the caller, and marks requests from federation
+// proxies
+func HTTPContextFunc(ctx context.Context, r *http.Request) context.Context {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		ctx = context.WithValue(ctx, authTokenKey{}, strings.TrimSpace(token))
+	}
+	return withFederationHeader(ctx, r)
+}
+
+// AdminOnly wraps an HTTP handler so that only requests with the admin bearer token,
+// or with roles an admin role token, reach it
+func AdminOnly(next http.Handler, adminToken string, roles *RolePolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, caller := withCaller(HTTPContextFunc(r.Context(), r), adminToken, roles); !caller.Admin {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "admin bearer token required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authTokenFromContext returns the bearer token stored by HTTPContextFunc
+func authTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(authTokenKey{}).(string)
+	return token
+}
+
+// withCaller stores the calling MCP session, and with roles the token's principal and
+// role, in the context for the Manager. The admin token counts as the admin role.
+func withCaller(ctx context.Context, adminToken string, roles *RolePolicy) (context.Context, manager.Caller) {
+	caller := manager.Caller{}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		caller.SessionID = session.SessionID()
+	}
+	token := authTokenFromContext(ctx)
+	if adminToken != "" {
+		caller.Admin = subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+	}
+	if roles != nil {
+		if caller.Admin {
+			caller.Principal, caller.Role = RoleAdmin, RoleAdmin
+		} else if g, ok := roles.grant(token); ok {
+			caller.Principal, caller.Role = g.name, g.role
+			caller.Admin = g.role == RoleAdmin
+		}
+	}
+	return manager.WithCaller(ctx, caller), caller
+}
+
+// callerResourceMiddleware identifies the calling MCP session for resource reads. The
+// handlers check access to the environments they read from.
+func callerResourceMiddleware(mgr *manager.Manager, adminToken string, roles *RolePolicy) server.ResourceHandlerMiddleware {
+	return func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+		return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			ctx, caller := withCaller(ctx, adminToken, roles)
+			mgr.TouchSession(caller.SessionID)
+			return next(ctx, request)
+		}
+	}
+}
+
+// callerMiddleware identifies the calling MCP session, stores it in the context for the
+// Manager and applies the calls-per-minute limits. With session isolation it rejects
+// calls referencing environments, REPLs, processes, jobs, terminals or debug sessions
+// the caller does not own.
+func callerMiddleware(mgr *manager.Manager, adminToken string, roles *RolePolicy) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			ctx, caller := withCaller(ctx, adminToken, roles)
+
+			// Record activity before and after the call so a long call does not look idle
+			mgr.TouchSession(caller.SessionID)
+			defer mgr.TouchSession(caller.SessionID)
+
+			if err := mgr.AllowCall(ctx); err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+
+			// Check access to resources referenced by the arguments
+			args := request.GetArguments()
+			if id, ok := args["env_id"].(string); ok && id != "" {
+				if err := mgr.CheckEnvironmentAccess(ctx, id); err != nil {
+					return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+				}
+			}
+			if id, ok := args["session_id"].(string); ok && id != "" {
+				if err := mgr.CheckREPLAccess(ctx, id); err != nil {
+					return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+				}
+			}
+			if id, ok := args["process_id"].(string); ok && id != "" {
+				if err := mgr.CheckProcessAccess(ctx, id); err != nil {
+					return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+				}
+			}
+			if id, ok := args["job_id"].(string); ok && id != "" {
+				if err := mgr.CheckJobAccess(ctx, id); err != nil {
+					return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+				}
+			}
+			if id, ok := args["terminal_id"].(string); ok && id != "" {
+				if err := mgr.CheckTerminalAccess(ctx, id); err != nil {
+					return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+				}
+			}
+			if id, ok := args["debug_id"].(string); ok && id != "" {
+				if err := mgr.CheckDebugAccess(ctx, id); err != nil {
+					return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+				}
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
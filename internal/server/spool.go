@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// spoolMiddleware replaces text results larger than the Manager's spool threshold by a
+// summary with a result_id that fetch_result pages through. It runs outside the
+// redaction middleware, so spooled results never hold secret values.
+func spoolMiddleware(mgr *manager.Manager, adminToken string, roles *RolePolicy) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			result, err := next(ctx, request)
+			if err != nil || result == nil || isResultPage(result) {
+				return result, err
+			}
+			callerCtx, _ := withCaller(ctx, adminToken, roles)
+			for i, content := range result.Content {
+				text, ok := content.(mcp.TextContent)
+				if !ok {
+					continue
+				}
+				spooled, err := mgr.SpoolResult(callerCtx, text.Text)
+				if err != nil {
+					return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+				}
+				text.Text = spooled
+				result.Content[i] = text
+			}
+			return result, nil
+		}
+	}
+}
+
+// isResultPage reports whether a result is a page of a spooled result, from this or a
+// federated server, which is never spooled again
+func isResultPage(result *mcp.CallToolResult) bool {
+	if result.Meta == nil {
+		return false
+	}
+	page, _ := result.Meta.AdditionalFields[manager.MetaResultPage].(bool)
+	return page
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/discovery"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+	"github.com/richinsley/jumpboot-mcp/internal/proxy"
+)
+
+// callTool sends a tools/call through s and returns the text of its result
+func callTool(t *testing.T, s *server.MCPServer, name string, args map[string]any) string {
+	t.Helper()
+	params, err := json.Marshal(map[string]any{"name": name, "arguments": args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := s.WithContext(context.Background(), newTestSession("session-1"))
+	msg := s.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":`+string(params)+`}`))
+	data, _ := json.Marshal(msg)
+	var resp struct {
+		Result mcp.CallToolResult `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Result.Content) == 0 {
+		t.Fatalf("unexpected response %s", data)
+	}
+	text, _ := resp.Result.Content[0].(mcp.TextContent)
+	return text.Text
+}
+
+func TestProxiedFetchResultIsNotSpooledAgain(t *testing.T) {
+	// The remote holds a spooled result
+	remoteMgr, err := manager.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteMgr.SetResultSpool(1024, 0)
+	summary, err := remoteMgr.SpoolResult(context.Background(), strings.Repeat("x", 16<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spooled struct {
+		Spooled manager.SpoolInfo `json:"spooled"`
+	}
+	if err := json.Unmarshal([]byte(summary), &spooled); err != nil || spooled.Spooled.ResultID == "" {
+		t.Fatalf("no result_id in %s: %v", summary, err)
+	}
+
+	remote := httptest.NewServer(server.NewStreamableHTTPServer(NewWithOptions(remoteMgr, nil, Options{})))
+	defer remote.Close()
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(remote.URL, "http://"))
+	portNum, _ := strconv.Atoi(port)
+
+	// The front server spools at the same threshold and proxies the remote's tools
+	// without a prefix separator it could recognize
+	frontMgr, err := manager.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	frontMgr.SetResultSpool(1024, 0)
+	aggregator := proxy.NewToolAggregator()
+	defer aggregator.Close()
+	aggregator.SetHealthInterval(0)
+	aggregator.SetToolNaming(proxy.NamingUnderscore, nil, LocalToolNames(frontMgr, Options{}))
+	info := discovery.ServiceInfo{InstanceName: "gpu-box", Host: host, Port: portNum, Endpoint: "/mcp", Static: true}
+	if err := aggregator.AddRemote(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	front := NewWithOptions(frontMgr, append(aggregator.GetAllTools(), aggregator.GetMetaTools()...), Options{})
+
+	fetchArgs := map[string]any{"result_id": spooled.Spooled.ResultID, "length": 4096}
+	calls := map[string]func() string{
+		"gpu-box_fetch_result": func() string { return callTool(t, front, "gpu-box_fetch_result", fetchArgs) },
+		"call_remote_tool": func() string {
+			return callTool(t, front, "call_remote_tool", map[string]any{"server": "gpu-box", "tool": "fetch_result", "args": fetchArgs})
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			text := call()
+			var resp struct {
+				Success bool               `json:"success"`
+				Data    manager.ResultPage `json:"data"`
+				Spooled *manager.SpoolInfo `json:"spooled"`
+			}
+			if err := json.Unmarshal([]byte(text), &resp); err != nil {
+				t.Fatalf("unexpected result %s: %v", text, err)
+			}
+			if resp.Spooled != nil {
+				t.Fatalf("the page was spooled again: %s", text)
+			}
+			if !resp.Success || len(resp.Data.Content) != 4096 {
+				t.Errorf("got %d bytes of content, want 4096: %.200s", len(resp.Data.Content), text)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/net/websocket"
+)
+
+// wsNotificationBuffer is the number of notifications queued per WebSocket session
+const wsNotificationBuffer = 100
+
+// wsSession is the MCP session of one WebSocket connection
+type wsSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+
+	mu                 sync.Mutex // protects the client fields
+	clientInfo         mcp.Implementation
+	clientCapabilities mcp.ClientCapabilities
+}
+
+func (s *wsSession) SessionID() string { return s.id }
+
+func (s *wsSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *wsSession) Initialize() { s.initialized.Store(true) }
+
+func (s *wsSession) Initialized() bool { return s.initialized.Load() }
+
+func (s *wsSession) GetClientInfo() mcp.Implementation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clientInfo
+}
+
+func (s *wsSession) SetClientInfo(clientInfo mcp.Implementation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientInfo = clientInfo
+}
+
+func (s *wsSession) GetClientCapabilities() mcp.ClientCapabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clientCapabilities
+}
+
+func (s *wsSession) SetClientCapabilities(clientCapabilities mcp.ClientCapabilities) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientCapabilities = clientCapabilities
+}
+
+// WithWebSocket returns a handler that serves MCP over WebSocket for upgrade requests
+// and passes every other request to next, so one endpoint speaks both WebSocket and
+// streamable HTTP. Each connection is its own MCP session; every text message is one
+// JSON-RPC message. contextFunc, if set, adds values from the upgrade request (such as
+// the bearer token) to the session's context.
+func WithWebSocket(s *server.MCPServer, next http.Handler, contextFunc server.HTTPContextFunc) http.Handler {
+	ws := websocket.Server{
+		// Accept any Origin, like the streamable HTTP endpoint
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			serveWebSocket(s, conn, contextFunc)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveWebSocket runs one MCP session until the connection closes
+func serveWebSocket(s *server.MCPServer, conn *websocket.Conn, contextFunc server.HTTPContextFunc) {
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(conn.Request().Context())
+	defer cancel()
+	if contextFunc != nil {
+		ctx = contextFunc(ctx, conn.Request())
+	}
+
+	session := &wsSession{
+		id:            uuid.New().String(),
+		notifications: make(chan mcp.JSONRPCNotification, wsNotificationBuffer),
+	}
+	if err := s.RegisterSession(ctx, session); err != nil {
+		log.Printf("websocket: failed to register session: %v", err)
+		return
+	}
+	defer s.UnregisterSession(ctx, session.id)
+	ctx = s.WithContext(ctx, session)
+
+	var writeMu sync.Mutex
+	send := func(message any) {
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("websocket: failed to encode message: %v", err)
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := websocket.Message.Send(conn, string(data)); err != nil {
+			cancel()
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case notification := <-session.notifications:
+				send(notification)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var calls sync.WaitGroup
+	defer calls.Wait()
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			return
+		}
+
+		message := json.RawMessage(data)
+		var base struct {
+			Method string `json:"method"`
+		}
+		if err := json.Unmarshal(message, &base); err != nil {
+			send(mcp.NewJSONRPCError(mcp.NewRequestId(nil), mcp.PARSE_ERROR, "Parse error", nil))
+			continue
+		}
+
+		// Tool calls may run for a long time; handle them concurrently so that other
+		// requests and cancellation notifications are not held up
+		if base.Method == string(mcp.MethodToolsCall) {
+			calls.Add(1)
+			go func() {
+				defer calls.Done()
+				if response := s.HandleMessage(ctx, message); response != nil {
+					send(response)
+				}
+			}()
+			continue
+		}
+		if response := s.HandleMessage(ctx, message); response != nil {
+			send(response)
+		}
+	}
+}
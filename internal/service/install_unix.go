@@ -0,0 +1,105 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Generate returns the service definition for the current platform
+func Generate(cfg Config) (Plan, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return SystemdPlan(cfg, home), nil
+	case "darwin":
+		return LaunchdPlan(cfg, home), nil
+	default:
+		return Plan{}, fmt.Errorf("service installation is not supported on %s", runtime.GOOS)
+	}
+}
+
+// Install writes the service definition and, if start is set, enables and starts the
+// service. Progress is written to log.
+func Install(cfg Config, start bool, log io.Writer) error {
+	plan, err := Generate(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(plan.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(plan.Path), err)
+	}
+	if err := os.WriteFile(plan.Path, []byte(plan.Content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", plan.Path, err)
+	}
+	fmt.Fprintf(log, "Wrote %s\n", plan.Path)
+
+	if !start {
+		fmt.Fprintln(log, "To start the service, run:")
+		for _, args := range plan.Start {
+			fmt.Fprintf(log, "  %s\n", strings.Join(args, " "))
+		}
+		return nil
+	}
+	return runCommands(plan.Start, log, false)
+}
+
+// Uninstall stops and disables the service and removes its definition
+func Uninstall(cfg Config, log io.Writer) error {
+	plan, err := Generate(cfg)
+	if err != nil {
+		return err
+	}
+
+	// The service may already be stopped; removal proceeds regardless
+	runCommands(plan.Stop, log, true)
+
+	if err := os.Remove(plan.Path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", plan.Path, err)
+	}
+	fmt.Fprintf(log, "Removed %s\n", plan.Path)
+
+	return runCommands(plan.Reload, log, false)
+}
+
+// runCommands runs each command in order, stopping at the first failure unless
+// ignoreErrors is set
+func runCommands(commands [][]string, log io.Writer, ignoreErrors bool) error {
+	for _, args := range commands {
+		fmt.Fprintf(log, "Running: %s\n", strings.Join(args, " "))
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = log
+		cmd.Stderr = log
+		if err := cmd.Run(); err != nil && !ignoreErrors {
+			return fmt.Errorf("%s failed: %w", strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
+
+// Print writes the service definition for the current platform without installing it
+func Print(cfg Config, w io.Writer) error {
+	plan, err := Generate(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# %s\n%s", plan.Path, plan.Content)
+	return nil
+}
+
+// RunAsService reports whether the process was started by the Windows Service Control
+// Manager. It is always false on this platform.
+func RunAsService(name string, stop chan<- os.Signal) (bool, error) {
+	return false, nil
+}
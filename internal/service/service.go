@@ -0,0 +1,174 @@
+// Package service installs jumpboot-mcp as an always-on system service
+// (systemd on Linux, launchd on macOS, the Service Control Manager on Windows).
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultName is the default service name
+const DefaultName = "jumpboot-mcp"
+
+// Config describes the service to install
+type Config struct {
+	Name        string   // service name (systemd unit, launchd label suffix, Windows service name)
+	Description string   // human-readable description
+	Executable  string   // absolute path of the jumpboot-mcp binary
+	Args        []string // server flags passed to the binary
+	User        string   // account the service runs as (system scope only; empty = root/LocalSystem)
+	UserScope   bool     // install for the current user instead of system-wide (Linux/macOS)
+}
+
+// Plan is a generated service definition and the commands that manage it
+type Plan struct {
+	Path    string     // where the definition is written
+	Content string     // the unit file or property list
+	Start   [][]string // commands run after writing Path
+	Stop    [][]string // commands run before removing Path
+	Reload  [][]string // commands run after removing Path
+}
+
+// NewConfig returns a Config for the running binary with the given server flags.
+// HTTP transport is added when no transport is given, since stdio cannot run as a service.
+func NewConfig(name string, args []string) (Config, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to locate executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	hasTransport := false
+	for _, arg := range args {
+		if arg == "-transport" || arg == "--transport" ||
+			strings.HasPrefix(arg, "-transport=") || strings.HasPrefix(arg, "--transport=") {
+			hasTransport = true
+		}
+	}
+	if !hasTransport {
+		args = append([]string{"-transport", "http"}, args...)
+	}
+
+	return Config{
+		Name:        name,
+		Description: "jumpboot-mcp Python environment MCP server",
+		Executable:  exe,
+		Args:        args,
+	}, nil
+}
+
+// SystemdPlan generates a systemd unit for the service
+func SystemdPlan(cfg Config, home string) Plan {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", cfg.Description)
+	fmt.Fprintf(&b, "Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=network-online.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdCommandLine(append([]string{cfg.Executable}, cfg.Args...)))
+	if cfg.User != "" && !cfg.UserScope {
+		fmt.Fprintf(&b, "User=%s\n", cfg.User)
+	}
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=5\n")
+	fmt.Fprintf(&b, "KillSignal=SIGTERM\n\n")
+	fmt.Fprintf(&b, "[Install]\n")
+
+	plan := Plan{Content: b.String()}
+	unit := cfg.Name + ".service"
+	if cfg.UserScope {
+		plan.Content += "WantedBy=default.target\n"
+		plan.Path = filepath.Join(home, ".config", "systemd", "user", unit)
+		plan.Start = [][]string{
+			{"systemctl", "--user", "daemon-reload"},
+			{"systemctl", "--user", "enable", "--now", unit},
+			{"loginctl", "enable-linger"}, // keep the user's services running after logout
+		}
+		plan.Stop = [][]string{{"systemctl", "--user", "disable", "--now", unit}}
+		plan.Reload = [][]string{{"systemctl", "--user", "daemon-reload"}}
+	} else {
+		plan.Content += "WantedBy=multi-user.target\n"
+		plan.Path = filepath.Join("/etc/systemd/system", unit)
+		plan.Start = [][]string{
+			{"systemctl", "daemon-reload"},
+			{"systemctl", "enable", "--now", unit},
+		}
+		plan.Stop = [][]string{{"systemctl", "disable", "--now", unit}}
+		plan.Reload = [][]string{{"systemctl", "daemon-reload"}}
+	}
+	return plan
+}
+
+// systemdCommandLine quotes arguments for an ExecStart line
+func systemdCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\$%;") {
+			arg = strings.ReplaceAll(arg, `\`, `\\`)
+			arg = strings.ReplaceAll(arg, `"`, `\"`)
+			arg = strings.ReplaceAll(arg, `%`, `%%`)
+			arg = strings.ReplaceAll(arg, `$`, `$$`)
+			arg = `"` + arg + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// LaunchdLabel returns the launchd label for a service name
+func LaunchdLabel(name string) string {
+	return "com.richinsley." + name
+}
+
+// LaunchdPlan generates a launchd property list for the service
+func LaunchdPlan(cfg Config, home string) Plan {
+	label := LaunchdLabel(cfg.Name)
+
+	var plan Plan
+	var logDir, domain string
+	if cfg.UserScope {
+		plan.Path = filepath.Join(home, "Library", "LaunchAgents", label+".plist")
+		logDir = filepath.Join(home, "Library", "Logs")
+		domain = fmt.Sprintf("gui/%d", os.Getuid())
+	} else {
+		plan.Path = filepath.Join("/Library/LaunchDaemons", label+".plist")
+		logDir = "/Library/Logs"
+		domain = "system"
+	}
+	logPath := filepath.Join(logDir, cfg.Name+".log")
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+	plistString(&b, "Label", label)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{cfg.Executable}, cfg.Args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", html.EscapeString(arg))
+	}
+	b.WriteString("\t</array>\n")
+	if cfg.User != "" && !cfg.UserScope {
+		plistString(&b, "UserName", cfg.User)
+	}
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	plistString(&b, "StandardOutPath", logPath)
+	plistString(&b, "StandardErrorPath", logPath)
+	b.WriteString("</dict>\n</plist>\n")
+
+	plan.Content = b.String()
+	plan.Start = [][]string{{"launchctl", "bootstrap", domain, plan.Path}}
+	plan.Stop = [][]string{{"launchctl", "bootout", domain + "/" + label}}
+	return plan
+}
+
+func plistString(b *bytes.Buffer, key, value string) {
+	fmt.Fprintf(b, "\t<key>%s</key>\n\t<string>%s</string>\n", key, html.EscapeString(value))
+}
@@ -0,0 +1,129 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Install registers the service with the Service Control Manager (automatic start,
+// restart on failure) and, if start is set, starts it. Progress is written to log.
+func Install(cfg Config, start bool, log io.Writer) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(cfg.Name, cfg.Executable, mgr.Config{
+		DisplayName:      cfg.Name,
+		Description:      cfg.Description,
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: cfg.User,
+	}, cfg.Args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service %s: %w", cfg.Name, err)
+	}
+	defer s.Close()
+
+	recovery := []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 5 * time.Second}}
+	if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		fmt.Fprintf(log, "Warning: failed to set recovery actions: %v\n", err)
+	}
+	fmt.Fprintf(log, "Created service %s\n", cfg.Name)
+
+	if !start {
+		fmt.Fprintf(log, "To start the service, run:\n  sc.exe start %s\n", cfg.Name)
+		return nil
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service %s: %w", cfg.Name, err)
+	}
+	fmt.Fprintf(log, "Started service %s\n", cfg.Name)
+	return nil
+}
+
+// Uninstall stops the service and removes it from the Service Control Manager
+func Uninstall(cfg Config, log io.Writer) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(cfg.Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", cfg.Name, err)
+	}
+	defer s.Close()
+
+	// The service may already be stopped; removal proceeds regardless
+	s.Control(svc.Stop)
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service %s: %w", cfg.Name, err)
+	}
+	fmt.Fprintf(log, "Removed service %s\n", cfg.Name)
+	return nil
+}
+
+// Print writes the equivalent sc.exe command without installing the service
+func Print(cfg Config, w io.Writer) error {
+	binPath := syscall.EscapeArg(cfg.Executable)
+	for _, arg := range cfg.Args {
+		binPath += " " + syscall.EscapeArg(arg)
+	}
+	fmt.Fprintf(w, "sc.exe create %s start= auto binPath= %s\n", cfg.Name, syscall.EscapeArg(binPath))
+	if cfg.User != "" {
+		fmt.Fprintf(w, "sc.exe config %s obj= %s\n", cfg.Name, cfg.User)
+	}
+	fmt.Fprintf(w, "sc.exe failure %s reset= 86400 actions= restart/5000\n", cfg.Name)
+	fmt.Fprintf(w, "sc.exe description %s %s\n", cfg.Name, syscall.EscapeArg(cfg.Description))
+	return nil
+}
+
+// RunAsService reports whether the process was started by the Service Control Manager.
+// If so, it answers the SCM in the background and sends SIGTERM on stop when the
+// service is stopped, so the normal shutdown path runs.
+func RunAsService(name string, stop chan<- os.Signal) (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false, err
+	}
+
+	go func() {
+		if err := svc.Run(name, &handler{stop: stop}); err != nil {
+			fmt.Fprintf(os.Stderr, "Service error: %v\n", err)
+		}
+	}()
+	return true, nil
+}
+
+// handler implements svc.Handler by forwarding stop requests as a signal
+type handler struct {
+	stop chan<- os.Signal
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			h.stop <- syscall.SIGTERM
+			return false, 0
+		}
+	}
+	return false, 0
+}
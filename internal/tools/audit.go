@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterAuditTools registers the admin tool searching the audit log
+func RegisterAuditTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("audit_query",
+				mcp.WithDescription("Admin: search the audit log of mutating tool calls (who called what, with which arguments, and whether it failed). Returns the most recent matches, oldest first"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("tool", mcp.Description("Only calls of this tool")),
+				mcp.WithString("session", mcp.Description("Only calls from this MCP session")),
+				mcp.WithString("env_id_filter", mcp.Description("Only calls with this env_id argument")),
+				mcp.WithString("outcome", mcp.Description("Only successful or failed calls"), mcp.Enum(manager.AuditOK, manager.AuditError)),
+				mcp.WithString("since", mcp.Description("Only calls at or after this time: RFC 3339 (2025-01-31T12:00:00Z) or a duration ago (30m, 24h)")),
+				mcp.WithString("until", mcp.Description("Only calls at or before this time, in the same formats as since")),
+				mcp.WithString("contains", mcp.Description("Only entries whose recorded JSON contains this text")),
+				mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Most recent matches to return. Default: %d, max: %d", manager.DefaultAuditQueryLimit, manager.MaxAuditQueryLimit))),
+			),
+			Handler: auditQueryHandler(mgr),
+		},
+	}
+}
+
+func auditQueryHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		q := manager.AuditQuery{
+			Tool:     request.GetString("tool", ""),
+			Session:  request.GetString("session", ""),
+			EnvID:    request.GetString("env_id_filter", ""),
+			Outcome:  request.GetString("outcome", ""),
+			Contains: request.GetString("contains", ""),
+			Limit:    request.GetInt("limit", 0),
+		}
+		var err error
+		if q.Since, err = parseAuditTime(request.GetString("since", "")); err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		if q.Until, err = parseAuditTime(request.GetString("until", "")); err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		result, err := mgr.QueryAudit(ctx, q)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+// parseAuditTime parses an RFC 3339 time or a duration before now ("" = zero time)
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or a duration such as 24h", s)
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterDebugTools registers the debugger tools with the server
+func RegisterDebugTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("debug_start",
+				mcp.WithDescription("Run a workspace script under the Python debugger (bdb, like pdb) and return where it stopped: at a breakpoint, on its first line with stop_on_entry, or on an uncaught exception, whose frames can then be inspected. Continue with debug_command. Output is returned with each step"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("filename", mcp.Required(), mcp.Description("Script to debug, relative to the workspace")),
+				mcp.WithArray("args",
+					mcp.Description("Command-line arguments for the script"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithArray("breakpoints",
+					mcp.Description("Breakpoints to set before the script starts, as [{\"line\": 12}, {\"file\": \"lib/model.py\", \"line\": 40, \"condition\": \"loss > 10\"}]. file defaults to the script and is relative to the workspace; temporary breakpoints are removed when first hit"),
+					mcp.Items(map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"file":      map[string]interface{}{"type": "string"},
+							"line":      map[string]interface{}{"type": "integer"},
+							"condition": map[string]interface{}{"type": "string"},
+							"temporary": map[string]interface{}{"type": "boolean"},
+						},
+						"required": []string{"line"},
+					}),
+				),
+				mcp.WithBoolean("stop_on_entry", mcp.Description("Pause on the script's first line. Default: false")),
+				mcp.WithString("name", mcp.Description("Name for the session. Default: the script's file name")),
+				mcp.WithNumber("timeout_seconds", mcp.Description("Max seconds to wait for the script to stop. If it is still running, the state is 'running' and debug_command wait collects the stop. Default: 30")),
+			),
+			Handler: debugStartHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("debug_command",
+				mcp.WithDescription("Control a paused script: continue, step (into calls), next (over calls), return (run until the current function returns), quit; break/clear/breakpoints to manage breakpoints; stack, variables, eval and source to inspect a frame; wait to wait for a running script to stop. Resuming commands return the new stop and the output printed meanwhile"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("debug_id", mcp.Required(), mcp.Description("Debug session ID")),
+				mcp.WithString("command", mcp.Required(),
+					mcp.Description("Debugger command"),
+					mcp.Enum(manager.DebugContinue, manager.DebugStep, manager.DebugNext, manager.DebugReturn, manager.DebugQuit,
+						manager.DebugBreak, manager.DebugClear, manager.DebugBreakpoints,
+						manager.DebugStack, manager.DebugVariables, manager.DebugEval, manager.DebugSource, manager.DebugWait),
+				),
+				mcp.WithString("file", mcp.Description("break/clear: file relative to the workspace. Default: the debugged script")),
+				mcp.WithNumber("line", mcp.Description("break/clear: line number. clear without a line removes every breakpoint")),
+				mcp.WithString("condition", mcp.Description("break: Python expression; the breakpoint only stops when it is true")),
+				mcp.WithBoolean("temporary", mcp.Description("break: remove the breakpoint when it is first hit")),
+				mcp.WithNumber("frame", mcp.Description("variables/eval/source: stack frame, 0 = innermost (see stack). Default: 0")),
+				mcp.WithString("scope", mcp.Description("variables: 'locals' or 'globals'. Default: locals")),
+				mcp.WithString("expression", mcp.Description("eval: expression to evaluate, or a statement to execute, in the frame; assignments change the paused program")),
+				mcp.WithNumber("context", mcp.Description("source: lines shown before and after the current line. Default: 5")),
+				mcp.WithNumber("timeout_seconds", mcp.Description("Max seconds a resuming command or wait waits for the script to stop. Default: 30")),
+			),
+			Handler: debugCommandHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("debug_list",
+				mcp.WithDescription("List debug sessions with where each script is paused"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: debugListHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("debug_stop",
+				mcp.WithDescription("Kill a debugged script and everything it started, and remove the session"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("debug_id", mcp.Required(), mcp.Description("Debug session ID")),
+			),
+			Handler: debugStopHandler(mgr),
+		},
+	}
+}
+
+// debugTimeoutArg returns the timeout_seconds argument of the debug tools
+func debugTimeoutArg(request mcp.CallToolRequest) time.Duration {
+	if seconds := request.GetFloat("timeout_seconds", 0); seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return manager.DefaultDebugTimeout
+}
+
+func debugStartHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+		filename := request.GetString("filename", "")
+		if filename == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		var breakpoints []manager.DebugBreakpoint
+		if raw, ok := request.GetArguments()["breakpoints"]; ok {
+			data, _ := json.Marshal(raw)
+			if err := json.Unmarshal(data, &breakpoints); err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument,
+					errors.New("breakpoints must be a list of {file, line, condition, temporary} objects")))), nil
+			}
+		}
+
+		result, err := mgr.StartDebug(ctx, envID, filename, manager.DebugOptions{
+			Name:        request.GetString("name", ""),
+			Args:        stringArrayArg(request, "args"),
+			Breakpoints: breakpoints,
+			StopOnEntry: request.GetBool("stop_on_entry", false),
+		}, debugTimeoutArg(request))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func debugCommandHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		debugID := request.GetString("debug_id", "")
+		if debugID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingDebugID)), nil
+		}
+		command := request.GetString("command", "")
+		if command == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.DebugCommand(ctx, debugID, manager.DebugRequest{
+			Command:    command,
+			File:       request.GetString("file", ""),
+			Line:       request.GetInt("line", 0),
+			Condition:  request.GetString("condition", ""),
+			Temporary:  request.GetBool("temporary", false),
+			Frame:      request.GetInt("frame", 0),
+			Scope:      request.GetString("scope", ""),
+			Expression: request.GetString("expression", ""),
+			Context:    request.GetInt("context", 0),
+		}, debugTimeoutArg(request))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func debugListHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sessions := mgr.ListDebugSessions(ctx)
+		return mcp.NewToolResultText(manager.SuccessResponse(sessions)), nil
+	}
+}
+
+func debugStopHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		debugID := request.GetString("debug_id", "")
+		if debugID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingDebugID)), nil
+		}
+
+		if err := mgr.StopDebug(debugID); err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]string{
+			"message":  "Debug session stopped",
+			"debug_id": debugID,
+		})), nil
+	}
+}
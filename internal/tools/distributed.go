@@ -0,0 +1,363 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// Shard outcomes of run_distributed
+const (
+	ShardCompleted = "completed" // run_map returned, whatever its items' status
+	ShardFailed    = "failed"    // no target could run the shard
+)
+
+// RegisterDistributedTools registers tools that spread work over federated servers.
+// It returns nil when no federation is configured.
+func RegisterDistributedTools(mgr *manager.Manager, remotes RemoteServerProvider) []ToolDef {
+	if remotes == nil {
+		return nil
+	}
+
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("run_distributed",
+				mcp.WithDescription("Shard a list of inputs across environments on this and connected remote servers, run each shard there with run_map, and gather the per-item results in input order with each shard's server and status. Targets take the next shard as they finish one, so faster servers take more; a shard whose server fails is retried on another target. Each target should run the same environment, e.g. one restored from the same freeze_environment spec or moved with migrate_environment"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithArray("targets",
+					mcp.Required(),
+					mcp.Description("Environments to run shards in, as [{\"server\": \"gpu-box\", \"env_id\": \"...\"}]. Omit server for this server (see list_servers)"),
+					mcp.Items(map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"server": map[string]interface{}{"type": "string"},
+							"env_id": map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"env_id"},
+					}),
+				),
+				mcp.WithString("script_path", mcp.Required(), mcp.Description("Python file defining the function, relative to each target's workspace")),
+				mcp.WithArray("inputs", mcp.Required(), mcp.Description("JSON values, one function call each")),
+				mcp.WithString("function", mcp.Description("Name of the function to call. Default: 'main'")),
+				mcp.WithString("code", mcp.Description("Source of the function file, written to script_path in every target's workspace before running. Default: use the file already there")),
+				mcp.WithNumber("shard_size", mcp.Description("Inputs per shard. Smaller shards balance uneven servers better. Default: the inputs split evenly, one shard per target")),
+				mcp.WithNumber("retries", mcp.Description("Times a shard is retried on another target after its server failed. Default: 1")),
+				mcp.WithNumber("max_workers", mcp.Description("Worker processes per target, as for run_map. Default: one per CPU")),
+				mcp.WithNumber("timeout_seconds", mcp.Description("Time limit per shard; unfinished items are reported as failed. Default: none")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: runDistributedHandler(mgr, remotes),
+		},
+	}
+}
+
+// DistributedShard is the outcome of one shard of run_distributed
+type DistributedShard struct {
+	Index           int     `json:"index"`
+	Start           int     `json:"start"` // first input of the shard
+	End             int     `json:"end"`   // one past its last input
+	Server          string  `json:"server,omitempty"`
+	EnvID           string  `json:"env_id,omitempty"`
+	Status          string  `json:"status"`
+	Attempts        int     `json:"attempts"`
+	Error           string  `json:"error,omitempty"`
+	Succeeded       int     `json:"succeeded"`
+	Failed          int     `json:"failed"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// DistributedTarget is what one target did during run_distributed
+type DistributedTarget struct {
+	Server string `json:"server,omitempty"`
+	EnvID  string `json:"env_id"`
+	Shards int    `json:"shards"`          // completed here
+	Error  string `json:"error,omitempty"` // why it stopped taking shards
+}
+
+// DistributedResult is the outcome of run_distributed, with items in input order
+type DistributedResult struct {
+	Succeeded       int                 `json:"succeeded"`
+	Failed          int                 `json:"failed"`
+	Items           []manager.MapItem   `json:"items"`
+	Shards          []DistributedShard  `json:"shards"`
+	Targets         []DistributedTarget `json:"targets"`
+	DurationSeconds float64             `json:"duration_seconds"`
+}
+
+// mapEndpoint is a server run_distributed can run shards on
+type mapEndpoint interface {
+	writeFile(ctx context.Context, envID, path, content string) error
+	runMap(ctx context.Context, envID, scriptPath string, inputs []json.RawMessage, opts manager.MapOptions) (*manager.MapResult, error)
+}
+
+func (l localEndpoint) writeFile(ctx context.Context, envID, path, content string) error {
+	_, err := l.mgr.WriteWorkspaceFile(envID, path, content)
+	return err
+}
+
+func (l localEndpoint) runMap(ctx context.Context, envID, scriptPath string, inputs []json.RawMessage, opts manager.MapOptions) (*manager.MapResult, error) {
+	return l.mgr.RunMap(ctx, envID, scriptPath, inputs, opts)
+}
+
+func (r remoteEndpoint) writeFile(ctx context.Context, envID, path, content string) error {
+	args := map[string]any{"env_id": envID, "filename": path, "content": content}
+	return callRemote(ctx, r.remotes, r.server, "workspace_write_file", args, nil)
+}
+
+func (r remoteEndpoint) runMap(ctx context.Context, envID, scriptPath string, inputs []json.RawMessage, opts manager.MapOptions) (*manager.MapResult, error) {
+	args := map[string]any{"env_id": envID, "script_path": scriptPath, "inputs": inputs, "function": opts.Function}
+	if opts.MaxWorkers > 0 {
+		args["max_workers"] = opts.MaxWorkers
+	}
+	if opts.Timeout > 0 {
+		args["timeout_seconds"] = opts.Timeout.Seconds()
+	}
+	var result manager.MapResult
+	if err := callRemote(ctx, r.remotes, r.server, "run_map", args, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// distributedTarget is a target environment and the endpoint of its server
+type distributedTarget struct {
+	DistributedTarget
+	endpoint mapEndpoint
+}
+
+// distributedSpec is the work of one run_distributed call
+type distributedSpec struct {
+	scriptPath string
+	code       string
+	inputs     []json.RawMessage
+	opts       manager.MapOptions
+	shardSize  int
+	retries    int
+}
+
+func runDistributedHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rawTargets, _ := request.GetArguments()["targets"].([]interface{})
+		rawInputs, _ := request.GetArguments()["inputs"].([]interface{})
+		spec := distributedSpec{
+			scriptPath: request.GetString("script_path", ""),
+			code:       request.GetString("code", ""),
+			opts: manager.MapOptions{
+				Function:   request.GetString("function", manager.DefaultMapFunction),
+				MaxWorkers: request.GetInt("max_workers", 0),
+				Timeout:    time.Duration(request.GetFloat("timeout_seconds", 0) * float64(time.Second)),
+			},
+			shardSize: request.GetInt("shard_size", 0),
+			retries:   request.GetInt("retries", 1),
+		}
+		if spec.scriptPath == "" || len(rawTargets) == 0 || len(rawInputs) == 0 {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+		if len(rawInputs) > manager.MaxMapInputs*len(rawTargets) {
+			return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument,
+				fmt.Errorf("at most %d inputs per target are allowed", manager.MaxMapInputs)))), nil
+		}
+		spec.inputs = make([]json.RawMessage, len(rawInputs))
+		for i, input := range rawInputs {
+			data, err := json.Marshal(input)
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument, err))), nil
+			}
+			spec.inputs[i] = data
+		}
+
+		targets, err := distributedTargets(ctx, mgr, remotes, rawTargets)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return runDistributed(ctx, targets, spec)
+		}), nil
+	}
+}
+
+// distributedTargets resolves the targets argument of run_distributed
+func distributedTargets(ctx context.Context, mgr *manager.Manager, remotes RemoteServerProvider, raw []interface{}) ([]*distributedTarget, error) {
+	seen := make(map[DistributedTarget]bool)
+	targets := make([]*distributedTarget, 0, len(raw))
+	for _, item := range raw {
+		fields, _ := item.(map[string]interface{})
+		serverName, _ := fields["server"].(string)
+		envID, _ := fields["env_id"].(string)
+		if envID == "" {
+			return nil, manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("every target needs an env_id"))
+		}
+		target := DistributedTarget{Server: serverName, EnvID: envID}
+		if seen[target] {
+			return nil, manager.WithErrorCode(manager.CodeInvalidArgument, fmt.Errorf("target %s listed twice", targetName(target)))
+		}
+		seen[target] = true
+
+		// The tool middleware only checks a single env_id argument
+		if serverName == "" {
+			if err := mgr.CheckEnvironmentAccess(ctx, envID); err != nil {
+				return nil, err
+			}
+		}
+		endpoint, err := migrationEndpoint(mgr, remotes, serverName)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, &distributedTarget{DistributedTarget: target, endpoint: endpoint.(mapEndpoint)})
+	}
+	return targets, nil
+}
+
+// targetName names a target in errors
+func targetName(target DistributedTarget) string {
+	if target.Server == "" {
+		return target.EnvID
+	}
+	return target.Server + "/" + target.EnvID
+}
+
+// runDistributed runs the shards of spec on the targets. Each target takes the next
+// queued shard when it finishes one. A target whose call fails stops taking shards,
+// and its shard is queued again for the others while it has retries left.
+func runDistributed(ctx context.Context, targets []*distributedTarget, spec distributedSpec) (*DistributedResult, error) {
+	start := time.Now()
+	ready := targets
+	if spec.code != "" {
+		// A target the file cannot be written to takes no shards
+		ready = nil
+		for _, target := range targets {
+			if err := target.endpoint.writeFile(ctx, target.EnvID, spec.scriptPath, spec.code); err != nil {
+				target.Error = fmt.Sprintf("failed to write %s: %s", spec.scriptPath, err)
+				continue
+			}
+			ready = append(ready, target)
+		}
+		if len(ready) == 0 {
+			return nil, fmt.Errorf("failed to write %s to any target: %s", spec.scriptPath, targets[0].Error)
+		}
+	}
+
+	size := spec.shardSize
+	if size <= 0 {
+		size = (len(spec.inputs) + len(ready) - 1) / len(ready)
+	}
+	size = min(size, manager.MaxMapInputs)
+	result := &DistributedResult{Items: make([]manager.MapItem, len(spec.inputs))}
+	for begin := 0; begin < len(spec.inputs); begin += size {
+		result.Shards = append(result.Shards, DistributedShard{
+			Index: len(result.Shards),
+			Start: begin,
+			End:   min(begin+size, len(spec.inputs)),
+		})
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		alive = len(ready)
+		queue = make(chan int, len(result.Shards)) // a shard is queued at most once at a time
+	)
+	wg.Add(len(result.Shards))
+	for i := range result.Shards {
+		queue <- i
+	}
+	// finish records a shard's outcome; callers hold mu
+	finish := func(i int, target *distributedTarget, mapped *manager.MapResult, err error) {
+		shard := &result.Shards[i]
+		if err != nil {
+			shard.Status, shard.Error, shard.Failed = ShardFailed, err.Error(), shard.End-shard.Start
+			for j := shard.Start; j < shard.End; j++ {
+				result.Items[j] = manager.MapItem{Index: j, Status: manager.MapFailed, Error: fmt.Sprintf("shard %d failed: %s", i, err)}
+			}
+		} else {
+			shard.Status = ShardCompleted
+			target.Shards++
+			for _, item := range mapped.Items {
+				if item.Index < 0 || shard.Start+item.Index >= shard.End {
+					continue
+				}
+				item.Index += shard.Start
+				result.Items[item.Index] = item
+			}
+			for j := shard.Start; j < shard.End; j++ {
+				if result.Items[j].Status == "" {
+					result.Items[j] = manager.MapItem{Index: j, Status: manager.MapFailed, Error: "missing from the run_map result"}
+				}
+				if result.Items[j].Status == manager.MapSucceeded {
+					shard.Succeeded++
+				} else {
+					shard.Failed++
+				}
+			}
+		}
+		wg.Done()
+	}
+
+	for _, target := range ready {
+		go func() {
+			for i := range queue {
+				shard := &result.Shards[i]
+				mu.Lock()
+				shard.Attempts++
+				shard.Server, shard.EnvID = target.Server, target.EnvID
+				mu.Unlock()
+
+				began := time.Now()
+				mapped, err := target.endpoint.runMap(ctx, target.EnvID, spec.scriptPath, spec.inputs[shard.Start:shard.End], spec.opts)
+
+				mu.Lock()
+				shard.DurationSeconds += time.Since(began).Seconds()
+				if err == nil {
+					finish(i, target, mapped, nil)
+					mu.Unlock()
+					continue
+				}
+				// The target is considered down; another one may take the shard
+				target.Error = err.Error()
+				alive--
+				if alive > 0 && shard.Attempts <= spec.retries && ctx.Err() == nil {
+					queue <- i
+				} else {
+					finish(i, target, nil, err)
+				}
+				if alive == 0 {
+					// Nobody is left to take the queued shards
+					for len(queue) > 0 {
+						finish(<-queue, target, nil, errors.New("no target left to run the shard"))
+					}
+				}
+				mu.Unlock()
+				return
+			}
+		}()
+	}
+	wg.Wait()
+	close(queue)
+
+	for _, item := range result.Items {
+		if item.Status == manager.MapSucceeded {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	for _, target := range targets {
+		result.Targets = append(result.Targets, target.DistributedTarget)
+	}
+	result.DurationSeconds = time.Since(start).Seconds()
+	return result, nil
+}
@@ -2,32 +2,69 @@ package tools
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/richinsley/jumpboot-mcp/internal/manager"
 )
 
-// RegisterEnvironmentTools registers environment management tools with the server
-func RegisterEnvironmentTools(mgr *manager.Manager) []ToolDef {
+// remoteCapacityTimeout bounds how long a capacity query to a federated server may take
+const remoteCapacityTimeout = 3 * time.Second
+
+// RegisterEnvironmentTools registers environment management tools with the server.
+// remotes may be nil when no federation is configured.
+func RegisterEnvironmentTools(mgr *manager.Manager, remotes RemoteServerProvider) []ToolDef {
 	return []ToolDef{
 		{
 			Tool: mcp.NewTool("create_environment",
 				mcp.WithDescription("Create a new Python environment. Creates a venv from a cached micromamba base (independent of system Python). First call for a Python version creates the base (slower), subsequent calls are fast."),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 				mcp.WithString("name", mcp.Required(), mcp.Description("Name for the environment")),
-				mcp.WithString("python_version", mcp.Description("Python version (e.g., '3.11'). Default: '3.11'")),
+				mcp.WithString("python_version", mcp.Description("Python version (e.g., '3.11'). Default: '3.11', or the template's version")),
+				mcp.WithString("template", mcp.Description("ID of an environment whose installed packages are cloned into the new one instead of reinstalled. Files are shared copy-on-write on btrfs, xfs and APFS, hard-linked on other filesystems, so heavy stacks like torch clone in seconds without taking more disk. The workspace is not copied")),
+				mcp.WithObject("labels", mcp.Description("Labels to organize environments, as {\"key\": \"value\"} (e.g. {\"experiment\": \"lr-sweep\", \"owner\": \"alice\"}). Filter with list_environments label_selector")),
+				mcp.WithString("post_create", mcp.Description("Python code to run inside the new environment after creation (e.g., configure pip, install an internal SDK). Creation fails if it fails")),
+				mcp.WithArray("runtimes",
+					mcp.Description("Runtimes to provision from conda-forge next to Python, optionally with a version: 'nodejs' or 'nodejs=20'. Their executables (node, npm, npx) are on the PATH of run_command, spawn_process and terminals, and their versions are reported as runtimes"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("with_repl", mcp.Description("Also create the workspace and a REPL session named 'default', returning workspace_dir and repl.id, so code can run without further setup calls. Not available with isolation or a network policy. Default: false")),
+				mcp.WithString("isolation", mcp.Description("Run the environment's code, scripts, commands and spawned processes in a sandbox that only sees the environment's directory: 'bubblewrap' (Linux), 'podman' or 'docker'. REPLs and terminals are unavailable in sandboxed environments; package installs still run on the host. Default: 'none'"),
+					mcp.Enum(manager.IsolationNone, manager.IsolationBubblewrap, manager.IsolationPodman, manager.IsolationDocker)),
+				networkOption,
+				networkAllowOption,
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
 			),
-			Handler: createEnvironmentHandler(mgr),
+			Handler: createEnvironmentHandler(mgr, remotes),
 		},
 		{
 			Tool: mcp.NewTool("list_environments",
-				mcp.WithDescription("List all managed Python environments"),
+				mcp.WithDescription("List all managed Python environments with their labels"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("label_selector", mcp.Description("Only list environments whose labels match, as comma-separated requirements that must all hold: key=value, key!=value, key in (a,b), key (label set) or !key (label not set). Example: 'experiment=lr-sweep,!archived'")),
 			),
 			Handler: listEnvironmentsHandler(mgr),
 		},
 		{
 			Tool: mcp.NewTool("destroy_environment",
 				mcp.WithDescription("Delete a Python environment"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID to destroy")),
 			),
 			Handler: destroyEnvironmentHandler(mgr),
@@ -35,38 +72,302 @@ func RegisterEnvironmentTools(mgr *manager.Manager) []ToolDef {
 		{
 			Tool: mcp.NewTool("freeze_environment",
 				mcp.WithDescription("Export an environment to JSON for later restoration"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID to freeze")),
 			),
 			Handler: freezeEnvironmentHandler(mgr),
 		},
 		{
 			Tool: mcp.NewTool("restore_environment",
-				mcp.WithDescription("Recreate an environment from frozen JSON"),
+				mcp.WithDescription("Recreate an environment from frozen JSON, a pip requirements.txt (hashes supported) or a conda environment.yml. The format is detected automatically"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 				mcp.WithString("name", mcp.Required(), mcp.Description("Name for the restored environment")),
-				mcp.WithString("frozen_json", mcp.Required(), mcp.Description("Frozen environment JSON")),
+				mcp.WithString("spec", mcp.Description("Environment specification: frozen JSON, requirements.txt or environment.yml content")),
+				mcp.WithString("frozen_json", mcp.Description("Frozen environment JSON (alias of spec, kept for compatibility)")),
+				mcp.WithString("format", mcp.Description("Specification format: 'auto', 'jumpboot', 'requirements' or 'environment_yml'. Default: 'auto'")),
+				mcp.WithString("python_version", mcp.Description("Python version for a requirements.txt, or an environment.yml that does not pin python. Default: '3.11'")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: restoreEnvironmentHandler(mgr, remotes),
+		},
+		{
+			Tool: mcp.NewTool("environment_snapshot",
+				mcp.WithDescription("Record an environment's installed packages, and optionally its workspace, so environment_rollback can return to them after an upgrade or install breaks something. The pinned packages' wheels are cached on the server, so the rollback is quick and works without the package index. Returns the snapshot and the environment's earlier snapshots"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("name", mcp.Description("Label for the snapshot, e.g. 'before torch upgrade'")),
+				mcp.WithBoolean("include_workspace", mcp.Description("Archive the workspace too, so the rollback restores its files. Default: false")),
+				mcp.WithBoolean("skip_wheels", mcp.Description("Do not cache wheels; the rollback then downloads from the package index. Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: environmentSnapshotHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("environment_rollback",
+				mcp.WithDescription("Return an environment to a snapshot taken with environment_snapshot: packages installed since are uninstalled and changed versions are reinstalled from the cached wheels. If the snapshot includes the workspace, the current workspace is moved to the trash and replaced. Conda packages and editable installs are left as they are"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("snapshot_id", mcp.Description("Snapshot to return to. Default: the latest")),
+				mcp.WithBoolean("skip_workspace", mcp.Description("Keep the current workspace even if the snapshot includes one. Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: environmentRollbackHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("adopt_environment",
+				mcp.WithDescription("Register an existing Python installation or venv on the server's host, such as a project's .venv, as an environment without copying it. destroy_environment later removes only the server's workspace and bookkeeping for it, never the adopted installation. Packages are installed into it with pip"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("path", mcp.Required(), mcp.Description("Python interpreter, or the directory of a venv or installation (its bin/python3 is used), outside the server's base directory")),
+				mcp.WithString("name", mcp.Description("Name for the environment. Default: the venv's project directory or the installation's directory name")),
+			),
+			Handler: adoptEnvironmentHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("create_environment_from_yml",
+				mcp.WithDescription("Create an environment from a conda environment.yml: the python dependency selects the version, conda dependencies are installed with micromamba from the file's channels and the pip section with pip"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("environment_yml", mcp.Required(), mcp.Description("Content of the environment.yml")),
+				mcp.WithString("name", mcp.Description("Name for the environment. Default: the file's name field")),
+				mcp.WithString("python_version", mcp.Description("Python version if the file does not pin python. Default: '3.11'")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: createEnvironmentFromYMLHandler(mgr, remotes),
+		},
+		{
+			Tool: mcp.NewTool("export_environment_yml",
+				mcp.WithDescription("Export an environment as a conda environment.yml: Python version, conda packages and a pip section with pinned versions"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithBoolean("write", mcp.Description("Also write the file to the workspace. Default: false")),
+				mcp.WithString("path", mcp.Description("Workspace file to write. Default: 'environment.yml'")),
+			),
+			Handler: exportEnvironmentYMLHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("export_image",
+				mcp.WithDescription("Export an environment as a container image: generates a Dockerfile that reproduces its Python version, packages, variables and workspace, optionally writes the build context to the workspace, and builds the image with buildah, docker or podman when a tag is given. Secret variables are not baked in; they are listed as runtime_secrets"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithBoolean("include_workspace", mcp.Description("Copy the workspace to /workspace in the image, without .git and caches. Default: true")),
+				mcp.WithString("entrypoint", mcp.Description("Manifest entrypoint to run as the image's command")),
+				mcp.WithArray("cmd", mcp.Description("Image command, e.g. [\"python\", \"app.py\"], run in /workspace. Instead of entrypoint"), mcp.Items(map[string]interface{}{"type": "string"})),
+				mcp.WithString("base_image", mcp.Description("Base image providing micromamba. Default: '"+manager.DefaultImageBase+"'")),
+				mcp.WithString("output", mcp.Description("Workspace directory to write the build context to (Dockerfile, environment.yml, workspace/), ready for 'docker build <output>'. An earlier export there is replaced")),
+				mcp.WithString("tag", mcp.Description("Build the image with this tag, e.g. 'myapp:latest'. Without it only the Dockerfile is generated")),
+				mcp.WithString("builder", mcp.Description("Image builder. Default: the first of buildah, docker and podman that is installed"), mcp.Enum("buildah", "docker", "podman")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: exportImageHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("environment_set_vars",
+				mcp.WithDescription("Set environment variables that are added to every run_code, run_script, command, REPL, spawned process and terminal started in the environment afterwards, so endpoints and configuration need not be repeated per call"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithObject("vars", mcp.Description("Variables to set, as {\"NAME\": \"value\"}")),
+				mcp.WithObject("secrets", mcp.Description("Variables to set from server secrets (see list_secrets), as {\"NAME\": \"secret_name\"}. The value is injected when a process starts and redacted from all output")),
+				mcp.WithArray("unset",
+					mcp.Description("Names of variables to remove"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("replace", mcp.Description("Remove all existing variables first. Default: false")),
+			),
+			Handler: environmentSetVarsHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("environment_get_vars",
+				mcp.WithDescription("Show the environment variables stored on an environment and the secrets referenced by name (values are never shown)"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+			),
+			Handler: environmentGetVarsHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("environment_set_labels",
+				mcp.WithDescription("Set or remove key=value labels on an environment, so agents managing many environments can group them (by experiment, task or stage) and find them with list_environments label_selector. Labels can be changed on locked environments"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithObject("labels", mcp.Description("Labels to set, as {\"key\": \"value\"}. Keys use letters, digits and . _ / -; values also : @ +")),
+				mcp.WithArray("unset",
+					mcp.Description("Keys of labels to remove"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("replace", mcp.Description("Remove all existing labels first. Default: false")),
+			),
+			Handler: environmentSetLabelsHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("environment_set_network",
+				mcp.WithDescription("Change what code, scripts, commands and processes started in the environment from now on may reach: everything, nothing but loopback, or HTTP(S) to an allowlist of hosts through the server's filtering proxy. Lets untrusted generated code run without a way to exfiltrate data"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				networkOption,
+				networkAllowOption,
+			),
+			Handler: environmentSetNetworkHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("environment_get_network",
+				mcp.WithDescription("Show an environment's network policy and the hosts its allowlist recently refused"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+			),
+			Handler: environmentGetNetworkHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("lock_environment",
+				mcp.WithDescription("Mark a prepared environment read-only: package installs, manifest and variable changes, workspace writes and destroying it are refused until unlock_environment, while code, scripts and processes still run. Protects reference environments from accidental changes"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("reason", mcp.Description("Why the environment is locked, shown in the errors of refused changes")),
+			),
+			Handler: lockEnvironmentHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("unlock_environment",
+				mcp.WithDescription("Make a read-only environment writable again. Only unlock an environment someone deliberately locked when the user asked for changes to it"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+			),
+			Handler: unlockEnvironmentHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("server_capacity",
+				mcp.WithDescription("Report whether this server has room for new environments: environment count and limit, free disk space and required minimum"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: serverCapacityHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("server_status",
+				mcp.WithDescription("Report this server's health: version, uptime, readiness, counts of environments, REPLs, running processes, terminals, jobs and schedules, free disk space, cached base environments and connected remote servers"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 			),
-			Handler: restoreEnvironmentHandler(mgr),
+			Handler: serverStatusHandler(mgr, remotes),
+		},
+		{
+			Tool: mcp.NewTool("server_drain",
+				mcp.WithDescription("Admin: drain this server for shutdown. New tool calls are refused (read-only tools still answer), in-flight calls and background jobs get a grace period, REPL sessions are checkpointed if the server enables it, then the server exits. Progress is reported by server_status"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithNumber("grace_seconds", mcp.Description("How long in-flight calls and jobs may take before the server exits anyway. Default: the server's -drain-grace")),
+			),
+			Handler: serverDrainHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("gpu_info",
+				mcp.WithDescription("Report the GPUs of this server (NVIDIA via nvidia-smi, AMD via rocm-smi, Apple via Metal): models, driver/CUDA/ROCm versions, memory and current utilization"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: gpuInfoHandler(mgr),
 		},
 	}
 }
 
-func createEnvironmentHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+func createEnvironmentHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		name := request.GetString("name", "")
-		pythonVersion := request.GetString("python_version", "3.11")
-
-		info, err := mgr.CreateEnvironment(name, pythonVersion)
-		if err != nil {
-			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		pythonVersion := request.GetString("python_version", "")
+		opts := manager.CreateOptions{
+			PostCreate: request.GetString("post_create", ""),
+			Isolation:  request.GetString("isolation", ""),
+			Network:    networkPolicyArg(request),
+			Template:   request.GetString("template", ""),
+			Labels:     stringMapArg(request, "labels"),
+			WithREPL:   request.GetBool("with_repl", false),
+			Runtimes:   stringArrayArg(request, "runtimes"),
 		}
 
-		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			info, err := mgr.CreateEnvironment(ctx, name, pythonVersion, opts)
+			if err != nil {
+				return nil, withRemoteCapacity(ctx, remotes, err)
+			}
+			return info, discardIfCancelled(ctx, mgr, info.ID)
+		}), nil
 	}
 }
 
 func listEnvironmentsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		envs := mgr.ListEnvironments()
+		selector, err := manager.ParseLabelSelector(request.GetString("label_selector", ""))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		envs := slices.DeleteFunc(mgr.ListEnvironments(ctx), func(env manager.EnvironmentInfo) bool {
+			return !selector.Matches(env.Labels)
+		})
 		return mcp.NewToolResultText(manager.SuccessResponse(envs)), nil
 	}
 }
@@ -109,16 +410,38 @@ func freezeEnvironmentHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	}
 }
 
-func restoreEnvironmentHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+func restoreEnvironmentHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		name := request.GetString("name", "")
-		frozenJSON := request.GetString("frozen_json", "")
+		spec := request.GetString("spec", request.GetString("frozen_json", ""))
+
+		if name == "" || spec == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		opts := manager.RestoreOptions{
+			Format:        request.GetString("format", manager.SpecAuto),
+			PythonVersion: request.GetString("python_version", ""),
+		}
 
-		if name == "" || frozenJSON == "" {
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			info, err := mgr.RestoreEnvironmentFromSpec(ctx, name, spec, opts)
+			if err != nil {
+				return nil, withRemoteCapacity(ctx, remotes, err)
+			}
+			return info, discardIfCancelled(ctx, mgr, info.ID)
+		}), nil
+	}
+}
+
+func adoptEnvironmentHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := request.GetString("path", "")
+		if path == "" {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
 		}
 
-		info, err := mgr.RestoreEnvironment(name, frozenJSON)
+		info, err := mgr.AdoptEnvironment(ctx, request.GetString("name", ""), path)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
@@ -126,3 +449,446 @@ func restoreEnvironmentHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
 	}
 }
+
+func environmentSnapshotHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+		opts := manager.SnapshotOptions{
+			Name:             request.GetString("name", ""),
+			IncludeWorkspace: request.GetBool("include_workspace", false),
+			SkipWheels:       request.GetBool("skip_wheels", false),
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			snap, err := mgr.SnapshotEnvironment(ctx, envID, opts)
+			if err != nil {
+				return nil, err
+			}
+			snapshots, err := mgr.ListSnapshots(envID)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{
+				"snapshot":  snap,
+				"snapshots": snapshots,
+			}, nil
+		}), nil
+	}
+}
+
+func environmentRollbackHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+		snapshotID := request.GetString("snapshot_id", "")
+		opts := manager.RollbackOptions{SkipWorkspace: request.GetBool("skip_workspace", false)}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.RollbackEnvironment(ctx, envID, snapshotID, opts)
+		}), nil
+	}
+}
+
+func createEnvironmentFromYMLHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		spec := request.GetString("environment_yml", "")
+		if spec == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+		name := request.GetString("name", "")
+		pythonVersion := request.GetString("python_version", "")
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			info, err := mgr.CreateEnvironmentFromYML(ctx, name, spec, pythonVersion)
+			if err != nil {
+				return nil, withRemoteCapacity(ctx, remotes, err)
+			}
+			return info, discardIfCancelled(ctx, mgr, info.ID)
+		}), nil
+	}
+}
+
+func exportEnvironmentYMLHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		content, err := mgr.ExportEnvironmentYML(ctx, envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		result := map[string]interface{}{
+			"env_id":          envID,
+			"environment_yml": content,
+		}
+		if request.GetBool("write", false) {
+			path := request.GetString("path", manager.DefaultEnvironmentYMLFile)
+			if _, err := mgr.WriteWorkspaceFile(envID, path, content); err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			result["path"] = path
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func exportImageHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+		opts := manager.ImageExportOptions{
+			BaseImage:        request.GetString("base_image", ""),
+			IncludeWorkspace: request.GetBool("include_workspace", true),
+			Entrypoint:       request.GetString("entrypoint", ""),
+			Cmd:              stringArrayArg(request, "cmd"),
+			Output:           request.GetString("output", ""),
+			Tag:              request.GetString("tag", ""),
+			Builder:          request.GetString("builder", ""),
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.ExportImage(ctx, envID, opts)
+		}), nil
+	}
+}
+
+func environmentSetVarsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		update := manager.VarsUpdate{
+			Vars:    stringMapArg(request, "vars"),
+			Secrets: stringMapArg(request, "secrets"),
+			Unset:   stringArrayArg(request, "unset"),
+			Replace: request.GetBool("replace", false),
+		}
+		if len(update.Vars) == 0 && len(update.Secrets) == 0 && len(update.Unset) == 0 && !update.Replace {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.SetEnvironmentVars(envID, update)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func environmentSetLabelsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		update := manager.LabelsUpdate{
+			Labels:  stringMapArg(request, "labels"),
+			Unset:   stringArrayArg(request, "unset"),
+			Replace: request.GetBool("replace", false),
+		}
+		if len(update.Labels) == 0 && len(update.Unset) == 0 && !update.Replace {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.SetEnvironmentLabels(envID, update)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func environmentGetVarsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		vars, err := mgr.EnvironmentVars(envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(vars)), nil
+	}
+}
+
+func environmentSetNetworkHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+		policy := networkPolicyArg(request)
+		if policy.Mode == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errors.New("network is required"))), nil
+		}
+
+		info, err := mgr.SetNetworkPolicy(envID, policy)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func environmentGetNetworkHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		info, err := mgr.NetworkPolicy(envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func lockEnvironmentHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		info, err := mgr.LockEnvironment(ctx, envID, request.GetString("reason", ""))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func unlockEnvironmentHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		info, err := mgr.UnlockEnvironment(envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+// networkOption and networkAllowOption select an environment's network policy
+var (
+	networkOption = mcp.WithString("network",
+		mcp.Description("What the environment's processes may reach: 'allow' (everything), 'deny' (loopback only) or 'allowlist' (HTTP(S) to network_allow hosts through the server's proxy; Linux). REPLs and terminals are unavailable while restricted. Default at creation: 'deny' for isolated environments, 'allow' otherwise"),
+		mcp.Enum(manager.NetworkAllow, manager.NetworkDeny, manager.NetworkAllowlist))
+	networkAllowOption = mcp.WithArray("network_allow",
+		mcp.Description("Hosts the allowlist policy lets through: names (pypi.org), IP addresses or *.domain for subdomains (*.pythonhosted.org)"),
+		mcp.Items(map[string]interface{}{"type": "string"}))
+)
+
+// networkPolicyArg reads the network and network_allow arguments. Hosts without a
+// mode imply the allowlist.
+func networkPolicyArg(request mcp.CallToolRequest) manager.NetworkPolicy {
+	policy := manager.NetworkPolicy{
+		Mode:  request.GetString("network", ""),
+		Hosts: stringArrayArg(request, "network_allow"),
+	}
+	if policy.Mode == "" && len(policy.Hosts) > 0 {
+		policy.Mode = manager.NetworkAllowlist
+	}
+	return policy
+}
+
+// discardIfCancelled destroys an environment created by a job that was cancelled
+// while the creation was in progress. If that fails the environment remains, and the
+// job reports it as created_env_id.
+func discardIfCancelled(ctx context.Context, mgr *manager.Manager, envID string) error {
+	if err := ctx.Err(); err != nil {
+		if destroyErr := mgr.DestroyEnvironment(envID); destroyErr != nil {
+			return fmt.Errorf("%w; environment %s was already created and could not be destroyed: %v", err, envID, destroyErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func serverCapacityHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(manager.SuccessResponse(mgr.Capacity())), nil
+	}
+}
+
+// remoteServerStatus is a federated server as reported by server_status
+type remoteServerStatus struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	State     string `json:"state"`
+	ToolCount int    `json:"tool_count"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+func serverStatusHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result := struct {
+			*manager.ServerStatus
+			Remotes []remoteServerStatus `json:"remotes,omitempty"`
+		}{ServerStatus: mgr.Status()}
+
+		if remotes != nil {
+			for _, info := range remotes.GetRemoteInfos() {
+				status := remotes.RemoteStatus(info.InstanceName)
+				result.Remotes = append(result.Remotes, remoteServerStatus{
+					Name:      info.InstanceName,
+					URL:       info.URL(),
+					State:     status.State,
+					ToolCount: remotes.ToolCount(info.InstanceName),
+					LastError: status.LastError,
+				})
+			}
+			for _, failed := range remotes.FailedRemotes() {
+				result.Remotes = append(result.Remotes, remoteServerStatus{
+					Name:      failed.Info.InstanceName,
+					URL:       failed.Info.URL(),
+					State:     "failed",
+					LastError: failed.Error,
+				})
+			}
+		}
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func serverDrainHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		grace := time.Duration(-1)
+		if seconds := request.GetFloat("grace_seconds", -1); seconds >= 0 {
+			grace = time.Duration(seconds * float64(time.Second))
+		}
+
+		drain, err := mgr.RequestDrain(ctx, grace)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		return mcp.NewToolResultText(manager.SuccessResponse(drain)), nil
+	}
+}
+
+func gpuInfoHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(manager.SuccessResponse(mgr.GPUInfo(ctx))), nil
+	}
+}
+
+// withRemoteCapacity adds the federated servers that still have room to a capacity
+// error, so the client can create the environment there instead
+func withRemoteCapacity(ctx context.Context, remotes RemoteServerProvider, err error) error {
+	var capErr *manager.CapacityError
+	if remotes == nil || !errors.As(err, &capErr) {
+		return err
+	}
+
+	for _, info := range remotes.GetRemoteInfos() {
+		callCtx, cancel := context.WithTimeout(ctx, remoteCapacityTimeout)
+		var capacity manager.CapacityInfo
+		// Servers without server_capacity are skipped
+		callErr := callRemote(callCtx, remotes, info.InstanceName, "server_capacity", map[string]any{}, &capacity)
+		cancel()
+		if callErr == nil && capacity.Available {
+			capErr.Servers = append(capErr.Servers, manager.ServerCapacity{
+				Server:       info.InstanceName,
+				CapacityInfo: capacity,
+			})
+		}
+	}
+	return err
+}
+
+// RegisterEnvironmentSearchTools registers tools that search existing environments.
+// remotes may be nil when no federation is configured.
+func RegisterEnvironmentSearchTools(mgr *manager.Manager, remotes RemoteServerProvider) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("find_environment",
+				mcp.WithDescription("Find existing environments that already satisfy a set of package requirements and Python version, so they can be reused instead of building a new one"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithArray("packages",
+					mcp.Description("Required packages with optional version specifiers (e.g., ['numpy>=1.24', 'pandas'])"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("python_version", mcp.Description("Required Python version prefix (e.g., '3.11')")),
+				mcp.WithBoolean("include_remote", mcp.Description("Also search connected remote servers. Default: false")),
+			),
+			Handler: findEnvironmentHandler(mgr, remotes),
+		},
+	}
+}
+
+func findEnvironmentHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		packages := stringArrayArg(request, "packages")
+		pythonVersion := request.GetString("python_version", "")
+		includeRemote := request.GetBool("include_remote", false)
+
+		matches, err := mgr.FindEnvironments(ctx, packages, pythonVersion)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		// Ask each remote server for its own matches
+		remoteErrors := map[string]string{}
+		if includeRemote && remotes != nil {
+			args := map[string]any{
+				"packages":       packages,
+				"python_version": pythonVersion,
+			}
+			for _, info := range remotes.GetRemoteInfos() {
+				var remoteMatches struct {
+					Matches []manager.EnvironmentMatch `json:"matches"`
+				}
+				if err := callRemote(ctx, remotes, info.InstanceName, "find_environment", args, &remoteMatches); err != nil {
+					remoteErrors[info.InstanceName] = err.Error()
+					continue
+				}
+				for _, match := range remoteMatches.Matches {
+					match.Server = info.InstanceName
+					matches = append(matches, match)
+				}
+			}
+		}
+
+		result := map[string]interface{}{
+			"matches": matches,
+			"count":   len(matches),
+		}
+		if len(remoteErrors) > 0 {
+			result["remote_errors"] = remoteErrors
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
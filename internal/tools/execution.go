@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -14,28 +15,182 @@ func RegisterExecutionTools(mgr *manager.Manager) []ToolDef {
 	return []ToolDef{
 		{
 			Tool: mcp.NewTool("run_code",
-				mcp.WithDescription("Execute a Python code snippet in an environment"),
+				mcp.WithDescription("Execute a Python code snippet in an environment. input_json is sent on stdin and its file path is in $JUMPBOOT_INPUT; JSON the code writes to the file named by $JUMPBOOT_RESULT is returned parsed as result_json, separate from the printed output. Files saved in the directory named by $JUMPBOOT_ARTIFACTS (e.g. plt.savefig(os.path.join(os.environ['JUMPBOOT_ARTIFACTS'], 'plot.png'))) are listed as artifacts with their workspace path, size, MIME type and resource URI"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("code", mcp.Required(), mcp.Description("Python code to execute")),
-				mcp.WithString("input_json", mcp.Description("Optional JSON input data")),
+				mcp.WithString("input_json", mcp.Description("Optional JSON input, readable with json.load(sys.stdin) or from the file named by $JUMPBOOT_INPUT")),
+				cacheOption,
+				reproducibleOption,
+				seedOption,
 			),
 			Handler: runCodeHandler(mgr),
 		},
 		{
 			Tool: mcp.NewTool("run_script",
-				mcp.WithDescription("Execute a Python script file in an environment"),
+				mcp.WithDescription("Execute a Python script file in an environment. Files the script saves in the directory named by $JUMPBOOT_ARTIFACTS are listed as artifacts with their workspace path, size, MIME type and resource URI"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("script_path", mcp.Required(), mcp.Description("Path to Python script")),
 				mcp.WithArray("args",
 					mcp.Description("Command-line arguments for the script"),
 					mcp.Items(map[string]interface{}{"type": "string"}),
 				),
+				gpuDevicesOption,
+				cacheOption,
+				reproducibleOption,
+				seedOption,
 			),
 			Handler: runScriptHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("run_command",
+				mcp.WithDescription("Run an executable (e.g., pytest, make, npm) in the environment's workspace with the environment's bin directory first on PATH, and wait for it to finish"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("command", mcp.Required(), mcp.Description("Executable name (looked up in the environment first) or path relative to the workspace")),
+				mcp.WithArray("args",
+					mcp.Description("Command-line arguments"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+			),
+			Handler: runCommandHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("run_matrix",
+				mcp.WithDescription("Run the same Python code or command (e.g. pytest) in several environments in parallel, such as different Python versions or dependency sets, and return a pass/fail matrix with each environment's exit code and output tail"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithArray("env_ids",
+					mcp.Required(),
+					mcp.Description("Environments to run in"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("code", mcp.Description("Python code to run with each environment's interpreter")),
+				mcp.WithString("command", mcp.Description("Executable to run instead of code, looked up in each environment first (e.g. 'pytest')")),
+				mcp.WithArray("args",
+					mcp.Description("Command-line arguments for command"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("workdir_env_id", mcp.Description("Run every environment in this environment's workspace, e.g. to test one checkout against several interpreters. Default: each environment's own workspace")),
+				mcp.WithNumber("timeout_seconds", mcp.Description("Per-environment time limit. Default: none")),
+				mcp.WithNumber("max_parallel", mcp.Description("Maximum environments running at once. Default: all")),
+				mcp.WithNumber("output_lines", mcp.Description("Trailing output lines kept per environment. Default: 50")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: runMatrixHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("run_map",
+				mcp.WithDescription("Call a Python function once per input in parallel worker processes of an environment and return each call's result or error, in input order. The function is defined in a workspace file and takes one JSON-decoded input, e.g. def main(item): return len(item['text']). A call that raises fails only its item, reported with its traceback. Results must be JSON-serializable; other values are converted with tolist() or str(). For embarrassingly parallel work such as processing files, scoring samples or API calls"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("script_path", mcp.Required(), mcp.Description("Python file defining the function, relative to the workspace. Module-level code runs once in every worker")),
+				mcp.WithArray("inputs", mcp.Required(), mcp.Description("JSON values, one call each (at most 10000), e.g. [\"a.csv\", \"b.csv\"] or [{\"id\": 1}, {\"id\": 2}]")),
+				mcp.WithString("function", mcp.Description("Name of the function to call. Default: 'main'")),
+				mcp.WithNumber("max_workers", mcp.Description("Worker processes. Default: one per CPU, at most 64 and never more than the inputs")),
+				mcp.WithNumber("timeout_seconds", mcp.Description("Time limit for the whole map; unfinished items are reported as failed. Default: none")),
+				mcp.WithNumber("output_lines", mcp.Description("Trailing lines of printed output kept. Default: 50")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: runMapHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("run_notebook",
+				mcp.WithDescription("Execute a Jupyter notebook (.ipynb) from the workspace with papermill, injecting parameters after the cell tagged 'parameters', and save the executed notebook with its outputs back into the workspace. Returns each code cell's status (completed, failed or skipped), output tail and error. A failing cell stops the run and is reported in the result, not as a tool error. Installs papermill and ipykernel into the environment on first use"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("Notebook relative to the workspace")),
+				mcp.WithObject("parameters", mcp.Description("Values for the notebook's parameters, as {\"name\": value}")),
+				mcp.WithString("output_path", mcp.Description("Workspace file for the executed notebook; pass path to overwrite the input. Default: '<name>.executed.ipynb' next to the input")),
+				mcp.WithString("kernel", mcp.Description("Jupyter kernel name. Default: 'python3', the environment's interpreter")),
+				mcp.WithNumber("cell_timeout_seconds", mcp.Description("Time limit per cell. Default: none")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: runNotebookHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("sql_execute",
+				mcp.WithDescription("Run SQL against the environment's DuckDB database, a file in the workspace that persists between calls. DuckDB queries workspace files directly, e.g. SELECT * FROM 'data/sales.parquet' or read_csv('logs/*.csv'), and tables created here can be read from Python with duckdb.connect. Several statements separated by semicolons return the last one's result. Installs duckdb into the environment on first use"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("sql", mcp.Required(), mcp.Description("SQL to run")),
+				mcp.WithString("database", mcp.Description("Database file relative to the workspace, created if missing. Default: 'scratch.duckdb'")),
+				mcp.WithNumber("max_rows", mcp.Description("Rows returned. Default: 100, at most 10000")),
+				mcp.WithBoolean("read_only", mcp.Description("Open the database read-only, e.g. while a Python process holds it or the environment is locked. Default: false")),
+			),
+			Handler: sqlExecuteHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("exec_cache",
+				mcp.WithDescription("List the run_code and run_script results memoized with cache=true, most recently used first, with their size and hit counts and the server's hit and miss totals. With clear, removes the listed entries instead, e.g. after workspace files the code reads have changed"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Description("Only this environment's entries. Default: all visible environments")),
+				mcp.WithString("key", mcp.Description("Only the entry with this cache_key")),
+				mcp.WithBoolean("clear", mcp.Description("Remove the selected entries. Default: false")),
+			),
+			Handler: execCacheHandler(mgr),
+		},
 	}
 }
 
+// cacheOption is the "cache" parameter of run_code and run_script
+var cacheOption = mcp.WithBoolean("cache",
+	mcp.Description("Return the memoized result of an identical earlier run, marked cached: true, instead of running again. Runs are identical when the code or script content, input, arguments, variables and installed packages match; workspace files are not compared. Successful runs without artifacts are memoized. Default: false"))
+
+// Reproducible-run parameters of run_code and run_script
+var (
+	reproducibleOption = mcp.WithBoolean("reproducible",
+		mcp.Description("Seed the run and record it: sets PYTHONHASHSEED, seeds random, and seeds numpy, torch and tensorflow when the code imports them, then writes a run manifest to runs/ in the workspace with the seed, pip freeze and its hash, the workspace's git commit, and hashes of the code, inputs, output and artifacts. Cannot be combined with cache. Default: false"))
+	seedOption = mcp.WithNumber("seed",
+		mcp.Description("Seed of a reproducible run, e.g. from an earlier manifest to repeat it (0 to 4294967295). Default: random, returned as seed"))
+)
+
+// runOptionsArg returns the cache and reproducible-run arguments
+func runOptionsArg(request mcp.CallToolRequest) manager.RunOptions {
+	opts := manager.RunOptions{
+		Cache:        request.GetBool("cache", false),
+		Reproducible: request.GetBool("reproducible", false),
+	}
+	if _, ok := request.GetArguments()["seed"]; ok {
+		seed := int64(request.GetFloat("seed", 0))
+		opts.Seed = &seed
+	}
+	return opts
+}
+
 func runCodeHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		envID := request.GetString("env_id", "")
@@ -50,14 +205,12 @@ func runCodeHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 
 		inputJSON := request.GetString("input_json", "")
 
-		output, err := mgr.RunCode(envID, code, inputJSON)
+		result, err := mgr.RunCode(ctx, envID, code, inputJSON, runOptionsArg(request))
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
 
-		return mcp.NewToolResultText(manager.SuccessResponse(map[string]string{
-			"output": output,
-		})), nil
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
 	}
 }
 
@@ -92,13 +245,152 @@ func runScriptHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			}
 		}
 
-		output, err := mgr.RunScript(envID, scriptPath, args)
+		result, err := mgr.RunScript(ctx, envID, scriptPath, args, gpuDevicesArg(request), runOptionsArg(request))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func runCommandHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		command := request.GetString("command", "")
+		if command == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.RunCommand(ctx, envID, command, stringArrayArg(request, "args"))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func runNotebookHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		opts := manager.NotebookOptions{
+			OutputPath:  request.GetString("output_path", ""),
+			Kernel:      request.GetString("kernel", ""),
+			CellTimeout: time.Duration(request.GetFloat("cell_timeout_seconds", 0) * float64(time.Second)),
+		}
+		if params, ok := request.GetArguments()["parameters"].(map[string]any); ok {
+			opts.Parameters = params
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.RunNotebook(ctx, envID, path, opts)
+		}), nil
+	}
+}
+
+func sqlExecuteHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		sql := request.GetString("sql", "")
+		if sql == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.ExecuteSQL(ctx, envID, sql, manager.SQLOptions{
+			Database: request.GetString("database", ""),
+			MaxRows:  request.GetInt("max_rows", 0),
+			ReadOnly: request.GetBool("read_only", false),
+		})
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func runMatrixHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envIDs := stringArrayArg(request, "env_ids")
+		if len(envIDs) == 0 {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		spec := manager.MatrixSpec{
+			Code:         request.GetString("code", ""),
+			Command:      request.GetString("command", ""),
+			Args:         stringArrayArg(request, "args"),
+			WorkdirEnvID: request.GetString("workdir_env_id", ""),
+			Timeout:      time.Duration(request.GetFloat("timeout_seconds", 0) * float64(time.Second)),
+			MaxParallel:  request.GetInt("max_parallel", 0),
+			OutputLines:  request.GetInt("output_lines", 0),
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.RunMatrix(ctx, envIDs, spec)
+		}), nil
+	}
+}
+
+func runMapHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		scriptPath := request.GetString("script_path", "")
+		rawInputs, ok := request.GetArguments()["inputs"].([]interface{})
+		if scriptPath == "" || !ok {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+		inputs := make([]json.RawMessage, len(rawInputs))
+		for i, input := range rawInputs {
+			data, err := json.Marshal(input)
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument, err))), nil
+			}
+			inputs[i] = data
+		}
+
+		opts := manager.MapOptions{
+			Function:    request.GetString("function", ""),
+			MaxWorkers:  request.GetInt("max_workers", 0),
+			Timeout:     time.Duration(request.GetFloat("timeout_seconds", 0) * float64(time.Second)),
+			OutputLines: request.GetInt("output_lines", 0),
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.RunMap(ctx, envID, scriptPath, inputs, opts)
+		}), nil
+	}
+}
+
+func execCacheHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		info, err := mgr.ExecCache(ctx, request.GetString("env_id", ""), request.GetString("key", ""), request.GetBool("clear", false))
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
 
-		return mcp.NewToolResultText(manager.SuccessResponse(map[string]string{
-			"output": output,
-		})), nil
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
 	}
 }
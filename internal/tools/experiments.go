@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterExperimentTools registers the experiment tracking tools with the server
+func RegisterExperimentTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("experiment_log",
+				mcp.WithDescription("Record a run of an experiment, such as one point of a hyperparameter sweep, with its params, metrics and artifacts in the server's experiment store. Pass run_id to add metrics or artifacts to a recorded run, e.g. when a training run finishes. Runs outlive environments and server restarts"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("experiment", mcp.Description("Experiment name, e.g. 'resnet-lr-sweep'. Required for new runs")),
+				mcp.WithString("run_id", mcp.Description("Update this run instead of recording a new one: params and metrics are merged, artifacts added")),
+				mcp.WithString("name", mcp.Description("Optional run label")),
+				mcp.WithString("env_id", mcp.Description("Environment whose workspace holds the artifacts and manifest")),
+				mcp.WithString("status", mcp.Description("running, completed or failed. Default for new runs: completed")),
+				mcp.WithObject("params", mcp.Description("Parameters of the run, as {\"name\": value}, e.g. {\"lr\": 0.01, \"optimizer\": \"adam\"}")),
+				mcp.WithObject("metrics", mcp.Description("Numeric results of the run, as {\"name\": number}, e.g. {\"val_loss\": 0.31}")),
+				mcp.WithArray("artifacts",
+					mcp.Description("Workspace paths (checked to exist) or URIs of files the run produced"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("manifest", mcp.Description("Workspace path of the run manifest of a reproducible run_code/run_script")),
+				mcp.WithString("notes", mcp.Description("Free-form notes")),
+			),
+			Handler: experimentLogHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("experiment_query",
+				mcp.WithDescription("Compare the recorded runs of an experiment: filter by params and status, sort by a metric, and get the params that differ between the returned runs plus each metric's min, max and mean with the runs that reached them. Without experiment or run_ids, lists the experiments"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("experiment", mcp.Description("Experiment name")),
+				mcp.WithArray("run_ids",
+					mcp.Description("Only these runs, e.g. to compare runs of different experiments"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithObject("params", mcp.Description("Only runs with these param values, as {\"name\": value}")),
+				mcp.WithString("status", mcp.Description("Only runs with this status")),
+				mcp.WithString("sort_by", mcp.Description("Metric to sort by; runs without it come last. Default: newest first")),
+				mcp.WithString("order", mcp.Description("desc (default, highest first) or asc")),
+				mcp.WithNumber("limit", mcp.Description("Runs or experiments returned. Default: 20, at most 1000")),
+			),
+			Handler: experimentQueryHandler(mgr),
+		},
+	}
+}
+
+func experimentLogHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		entry := manager.ExperimentLog{
+			RunID:      request.GetString("run_id", ""),
+			Experiment: request.GetString("experiment", ""),
+			Name:       request.GetString("name", ""),
+			EnvID:      request.GetString("env_id", ""),
+			Status:     request.GetString("status", ""),
+			Artifacts:  stringArrayArg(request, "artifacts"),
+			Manifest:   request.GetString("manifest", ""),
+			Notes:      request.GetString("notes", ""),
+		}
+		if entry.RunID == "" && entry.Experiment == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("experiment or run_id is required")))), nil
+		}
+		entry.Params, _ = request.GetArguments()["params"].(map[string]interface{})
+		if raw, ok := request.GetArguments()["metrics"].(map[string]interface{}); ok {
+			entry.Metrics = make(map[string]float64, len(raw))
+			for key, value := range raw {
+				number, ok := value.(float64)
+				if !ok {
+					return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument, fmt.Errorf("metric %s must be a number", key)))), nil
+				}
+				entry.Metrics[key] = number
+			}
+		}
+
+		run, err := mgr.LogExperiment(ctx, entry)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(run)), nil
+	}
+}
+
+func experimentQueryHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		order := request.GetString("order", "desc")
+		if order != "asc" && order != "desc" {
+			return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("order must be asc or desc")))), nil
+		}
+		query := manager.ExperimentQuery{
+			Experiment: request.GetString("experiment", ""),
+			RunIDs:     stringArrayArg(request, "run_ids"),
+			Status:     request.GetString("status", ""),
+			SortBy:     request.GetString("sort_by", ""),
+			Ascending:  order == "asc",
+			Limit:      request.GetInt("limit", 0),
+		}
+		query.Params, _ = request.GetArguments()["params"].(map[string]interface{})
+
+		result, err := mgr.QueryExperiments(ctx, query)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
@@ -3,14 +3,75 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/richinsley/jumpboot-mcp/internal/discovery"
 )
 
+// RemoteStatus is the connection health of a federated server
+type RemoteStatus struct {
+	State      string     `json:"state"`                // connected, reconnecting or closed
+	LastPing   *time.Time `json:"last_ping,omitempty"`  // last successful health check
+	LastError  string     `json:"last_error,omitempty"` // why the connection is down
+	Reconnects int        `json:"reconnects,omitempty"`
+	NextRetry  *time.Time `json:"next_retry,omitempty"` // while reconnecting
+}
+
+// FailedRemote is a remote server the proxy could not connect to at startup
+type FailedRemote struct {
+	Info  discovery.ServiceInfo
+	Error string
+}
+
 // RemoteServerProvider is implemented by the aggregator to provide remote server info
 type RemoteServerProvider interface {
 	GetRemoteInfos() []discovery.ServiceInfo
+	FailedRemotes() []FailedRemote
+	RemoteStatus(instanceName string) RemoteStatus
+	ToolCount(instanceName string) int
+	EstimateToolTokens(instanceName string) int
+	RemoteTool(instanceName, toolName string) (mcp.Tool, bool)
+	ToolSource(prefixedName string) (instanceName, toolName string, ok bool)
+	CallRemoteTool(ctx context.Context, instanceName, toolName string, args map[string]any) (*mcp.CallToolResult, error)
+}
+
+// remoteResponse is the standard response envelope returned by remote jumpboot-mcp tools
+type remoteResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// callRemote invokes a tool on a remote server and decodes its standard response data into out
+func callRemote(ctx context.Context, provider RemoteServerProvider, instanceName, toolName string, args map[string]any, out any) error {
+	result, err := provider.CallRemoteTool(ctx, instanceName, toolName, args)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	for _, content := range result.Content {
+		if tc, ok := content.(mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	if result.IsError {
+		return fmt.Errorf("%s: %s", instanceName, text)
+	}
+
+	var resp remoteResponse
+	if err := json.Unmarshal([]byte(text), &resp); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", instanceName, err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s: %s", instanceName, resp.Error)
+	}
+	if out == nil || len(resp.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, out)
 }
 
 // RegisterFederationTools registers tools for managing federated servers
@@ -22,31 +83,76 @@ func RegisterFederationTools(provider RemoteServerProvider) []ToolDef {
 	return []ToolDef{
 		{
 			Tool: mcp.NewTool("list_servers",
-				mcp.WithDescription("List all discovered remote jumpboot-mcp servers"),
+				mcp.WithDescription("List all discovered and configured remote jumpboot-mcp servers with their connection status, health and tool counts"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
 			Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 				infos := provider.GetRemoteInfos()
 
 				type serverInfo struct {
-					InstanceName string `json:"instance_name"`
-					URL          string `json:"url"`
-					Note         string `json:"note,omitempty"`
+					InstanceName    string       `json:"instance_name"`
+					URL             string       `json:"url"`
+					Addresses       []string     `json:"addresses,omitempty"` // other candidate addresses
+					Note            string       `json:"note,omitempty"`
+					GPU             string       `json:"gpu,omitempty"`
+					WebSocket       bool         `json:"websocket,omitempty"`
+					SSE             bool         `json:"sse,omitempty"`
+					Static          bool         `json:"static,omitempty"` // configured, not discovered
+					Status          RemoteStatus `json:"status"`
+					ToolCount       int          `json:"tool_count"`
+					EstimatedTokens int          `json:"estimated_tokens"`
 				}
 
+				totalTokens, totalTools, connected := 0, 0, 0
 				servers := make([]serverInfo, len(infos))
 				for i, info := range infos {
 					servers[i] = serverInfo{
-						InstanceName: info.InstanceName,
-						URL:          info.URL(),
-						Note:         info.Note,
+						InstanceName:    info.InstanceName,
+						URL:             info.URL(),
+						Addresses:       info.Candidates()[1:],
+						Note:            info.Note,
+						GPU:             info.GPU,
+						WebSocket:       info.WebSocket,
+						SSE:             info.SSE,
+						Static:          info.Static,
+						Status:          provider.RemoteStatus(info.InstanceName),
+						ToolCount:       provider.ToolCount(info.InstanceName),
+						EstimatedTokens: provider.EstimateToolTokens(info.InstanceName),
+					}
+					totalTokens += servers[i].EstimatedTokens
+					totalTools += servers[i].ToolCount
+					if servers[i].Status.State == "connected" {
+						connected++
 					}
 				}
 
+				// Servers that never connected have no tools, but are listed so the
+				// client can see why
+				for _, failed := range provider.FailedRemotes() {
+					servers = append(servers, serverInfo{
+						InstanceName: failed.Info.InstanceName,
+						URL:          failed.Info.URL(),
+						Addresses:    failed.Info.Candidates()[1:],
+						Note:         failed.Info.Note,
+						GPU:          failed.Info.GPU,
+						WebSocket:    failed.Info.WebSocket,
+						SSE:          failed.Info.SSE,
+						Static:       failed.Info.Static,
+						Status:       RemoteStatus{State: "failed", LastError: failed.Error},
+					})
+				}
+
 				result := map[string]any{
 					"success": true,
 					"data": map[string]any{
-						"servers": servers,
-						"count":   len(servers),
+						"servers":                servers,
+						"count":                  len(servers),
+						"connected":              connected,
+						"total_tools":            totalTools,
+						"total_estimated_tokens": totalTokens,
 					},
 					"error": nil,
 				}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// asyncOption is the "async" parameter shared by tools that can run as background jobs
+var asyncOption = mcp.WithBoolean("async",
+	mcp.Description("Run in the background and return a job_id immediately (poll with job_status/job_result). Default: false"))
+
+// priorityOption is the queue priority of tools that can run as background jobs
+var priorityOption = mcp.WithNumber("priority",
+	mcp.Description("Priority of the background job in the server's job queue, from -100 to 100; higher starts first when the server limits running jobs. Requires async. Default: 0"))
+
+// webhookURLOption and webhookSecretOption are the parameters of tools that can
+// notify a webhook when their background work finishes
+var (
+	webhookURLOption = mcp.WithString("webhook_url",
+		mcp.Description("URL that receives a signed JSON POST when the background work finishes"))
+	webhookSecretOption = mcp.WithString("webhook_secret",
+		mcp.Description("Secret used to sign the webhook body (HMAC-SHA256 in the X-Jumpboot-Signature header). Default: the server's secret"))
+)
+
+// webhookArg returns the webhook configured by the request, or nil
+func webhookArg(request mcp.CallToolRequest) *manager.Webhook {
+	webhookURL := request.GetString("webhook_url", "")
+	if webhookURL == "" {
+		return nil
+	}
+	return &manager.Webhook{
+		URL:    webhookURL,
+		Secret: request.GetString("webhook_secret", ""),
+	}
+}
+
+// runMaybeAsync runs op synchronously, or as a background job when the request sets
+// async. Background jobs go through the job queue, against the request's env_id.
+func runMaybeAsync(ctx context.Context, mgr *manager.Manager, request mcp.CallToolRequest,
+	op func(ctx context.Context) (any, error)) *mcp.CallToolResult {
+	hook := webhookArg(request)
+	_, hasPriority := request.GetArguments()["priority"]
+	if request.GetBool("async", false) {
+		opts := manager.JobOptions{
+			EnvID:    request.GetString("env_id", ""),
+			Priority: request.GetInt("priority", 0),
+		}
+		job, err := mgr.SubmitJob(ctx, request.Params.Name, opts, hook, op)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err))
+		}
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"job_id": job.ID,
+			"job":    job,
+		}))
+	}
+	if hook != nil {
+		return mcp.NewToolResultText(manager.ErrorResponse(errWebhookNeedsAsync))
+	}
+	if hasPriority {
+		return mcp.NewToolResultText(manager.ErrorResponse(errPriorityNeedsAsync))
+	}
+
+	result, err := op(ctx)
+	if err != nil {
+		return mcp.NewToolResultText(manager.ErrorResponse(err))
+	}
+	return mcp.NewToolResultText(manager.SuccessResponse(result))
+}
+
+// RegisterJobTools registers background job tools with the server
+func RegisterJobTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("job_status",
+				mcp.WithDescription("Get the status of a background job started with async=true. A job waiting in the server's job queue is 'queued', with its queue_position (1 = starts next) and the limit it waits for"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("job_id", mcp.Required(), mcp.Description("Job ID")),
+			),
+			Handler: jobStatusHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("job_result",
+				mcp.WithDescription("Get the result of a finished background job, optionally waiting for it to finish"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("job_id", mcp.Required(), mcp.Description("Job ID")),
+				mcp.WithNumber("wait_seconds", mcp.Description("Seconds to wait for the job to finish. Default: 0 (return immediately)")),
+			),
+			Handler: jobResultHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("job_cancel",
+				mcp.WithDescription("Cancel a queued or running background job. A running job is 'cancelling' until its work stops, then 'cancelled' (or 'succeeded' if it completed first); job_status then reports created_env_id if it left an environment behind"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("job_id", mcp.Required(), mcp.Description("Job ID to cancel")),
+			),
+			Handler: jobCancelHandler(mgr),
+		},
+	}
+}
+
+func jobStatusHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jobID := request.GetString("job_id", "")
+		if jobID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingJobID)), nil
+		}
+
+		info, err := mgr.GetJobStatus(ctx, jobID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func jobResultHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jobID := request.GetString("job_id", "")
+		if jobID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingJobID)), nil
+		}
+
+		wait := time.Duration(request.GetFloat("wait_seconds", 0) * float64(time.Second))
+
+		info, result, err := mgr.GetJobResult(ctx, jobID, wait)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"job":    info,
+			"result": result,
+		})), nil
+	}
+}
+
+func jobCancelHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jobID := request.GetString("job_id", "")
+		if jobID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingJobID)), nil
+		}
+
+		info, err := mgr.CancelJob(ctx, jobID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterLintTools registers static analysis tools with the server
+func RegisterLintTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("lint_code",
+				mcp.WithDescription("Lint workspace Python files with ruff and return structured diagnostics (file, line, column, severity, rule code). Installs ruff into the environment on first use"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithArray("paths",
+					mcp.Description("Files or directories relative to the workspace. Default: the whole workspace"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("fix", mcp.Description("Apply safe fixes in place and report only the remaining diagnostics. Default: false")),
+			),
+			Handler: lintCodeHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("type_check",
+				mcp.WithDescription("Type-check workspace Python files with mypy against the environment's installed packages and return structured diagnostics. Installs mypy into the environment on first use"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithArray("paths",
+					mcp.Description("Files or directories relative to the workspace. Default: the whole workspace"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("strict", mcp.Description("Enable mypy --strict. Default: false")),
+			),
+			Handler: typeCheckHandler(mgr),
+		},
+	}
+}
+
+func lintCodeHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		result, err := mgr.LintWorkspace(ctx, envID, stringArrayArg(request, "paths"), request.GetBool("fix", false))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func typeCheckHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		result, err := mgr.TypeCheckWorkspace(ctx, envID, stringArrayArg(request, "paths"), request.GetBool("strict", false))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterManifestTools registers tools for declarative environment manifests
+// (jumpboot.yaml). remotes may be nil when no federation is configured.
+func RegisterManifestTools(mgr *manager.Manager, remotes RemoteServerProvider) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("apply_manifest",
+				mcp.WithDescription("Reconcile an environment with a manifest (jumpboot.yaml: python, packages, env, entrypoints): install missing or outdated packages and replace the environment variables and entrypoints. Without env_id, create a new environment from the manifest"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Description("Environment to reconcile. Omit to create a new environment")),
+				mcp.WithString("manifest", mcp.Description("Manifest YAML content. Default: read from the workspace file given by path")),
+				mcp.WithString("path", mcp.Description("Workspace file holding the manifest, when manifest is omitted. Default: 'jumpboot.yaml'")),
+				mcp.WithString("name", mcp.Description("Name of a new environment. Default: the manifest's name")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: applyManifestHandler(mgr, remotes),
+		},
+		{
+			Tool: mcp.NewTool("export_manifest",
+				mcp.WithDescription("Generate a manifest (jumpboot.yaml) from an environment's current state: Python version, pinned packages, environment variables and entrypoints"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithBoolean("write", mcp.Description("Also write the manifest to the workspace. Default: false")),
+				mcp.WithString("path", mcp.Description("Workspace file to write. Default: 'jumpboot.yaml'")),
+			),
+			Handler: exportManifestHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("run_entrypoint",
+				mcp.WithDescription("Run a named entrypoint declared by the environment's manifest"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("name", mcp.Required(), mcp.Description("Entrypoint name")),
+				mcp.WithArray("args",
+					mcp.Description("Arguments appended to the entrypoint's own arguments"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+			),
+			Handler: runEntrypointHandler(mgr),
+		},
+	}
+}
+
+func applyManifestHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		content := request.GetString("manifest", "")
+		if envID == "" && content == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		var mf *manager.Manifest
+		var err error
+		if content != "" {
+			mf, err = manager.ParseManifest(content)
+		} else {
+			mf, err = mgr.ReadManifest(envID, request.GetString("path", manager.DefaultManifestFile))
+		}
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		name := request.GetString("name", "")
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			if envID != "" {
+				return mgr.ApplyManifest(ctx, envID, mf)
+			}
+			result, err := mgr.CreateEnvironmentFromManifest(ctx, name, mf)
+			if err != nil {
+				return nil, withRemoteCapacity(ctx, remotes, err)
+			}
+			return result, discardIfCancelled(ctx, mgr, result.EnvID)
+		}), nil
+	}
+}
+
+func exportManifestHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		mf, err := mgr.ExportManifest(envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		content, err := mf.Marshal()
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		result := map[string]interface{}{
+			"env_id":   envID,
+			"manifest": content,
+		}
+		if request.GetBool("write", false) {
+			path := request.GetString("path", manager.DefaultManifestFile)
+			if _, err := mgr.WriteWorkspaceFile(envID, path, content); err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			result["path"] = path
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func runEntrypointHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+		name := request.GetString("name", "")
+		if name == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		output, err := mgr.RunEntrypoint(ctx, envID, name, stringArrayArg(request, "args"))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]string{
+			"output": output,
+		})), nil
+	}
+}
@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterMigrationTools registers tools that move environments between federated
+// servers. It returns nil when no federation is configured.
+func RegisterMigrationTools(mgr *manager.Manager, remotes RemoteServerProvider) []ToolDef {
+	if remotes == nil {
+		return nil
+	}
+
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("migrate_environment",
+				mcp.WithDescription("Move an environment and its workspace to another server in one operation: freeze it, restore it on the target, and copy the workspace files. Works local to remote, remote to local and between remotes"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("source_env_id", mcp.Required(), mcp.Description("ID of the environment to migrate, on the source server")),
+				mcp.WithString("source_server", mcp.Description("Remote server holding the environment (see list_servers). Omit for this server")),
+				mcp.WithString("target_server", mcp.Description("Remote server to migrate to. Omit for this server")),
+				mcp.WithString("name", mcp.Description("Name of the migrated environment. Default: the source environment's name")),
+				mcp.WithBoolean("include_workspace", mcp.Description("Copy the workspace files. Default: true")),
+				mcp.WithBoolean("destroy_source", mcp.Description("Destroy the source environment once the migration succeeded. Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: migrateEnvironmentHandler(mgr, remotes),
+		},
+	}
+}
+
+// MigrationResult reports a completed migration
+type MigrationResult struct {
+	SourceServer    string                        `json:"source_server,omitempty"`
+	SourceEnvID     string                        `json:"source_env_id"`
+	TargetServer    string                        `json:"target_server,omitempty"`
+	Environment     manager.EnvironmentInfo       `json:"environment"`
+	Workspace       *manager.WorkspaceArchiveInfo `json:"workspace,omitempty"`
+	SourceDestroyed bool                          `json:"source_destroyed,omitempty"`
+}
+
+// envEndpoint is one side of a migration: this server or a remote
+type envEndpoint interface {
+	info(ctx context.Context, envID string) (manager.EnvironmentInfo, error)
+	freeze(ctx context.Context, envID string) (string, error)
+	exportWorkspace(ctx context.Context, envID string) ([]byte, error)
+	restore(ctx context.Context, name, spec string) (manager.EnvironmentInfo, error)
+	importWorkspace(ctx context.Context, envID string, archive []byte) (*manager.WorkspaceArchiveInfo, error)
+	destroy(ctx context.Context, envID string) error
+}
+
+// localEndpoint migrates from or to this server
+type localEndpoint struct {
+	mgr *manager.Manager
+}
+
+// info only finds environments the caller may access
+func (l localEndpoint) info(ctx context.Context, envID string) (manager.EnvironmentInfo, error) {
+	for _, env := range l.mgr.ListEnvironments(ctx) {
+		if env.ID == envID {
+			return env, nil
+		}
+	}
+	return manager.EnvironmentInfo{}, manager.WithErrorCode(manager.CodeNotFound, fmt.Errorf("environment not found: %s", envID))
+}
+
+func (l localEndpoint) freeze(ctx context.Context, envID string) (string, error) {
+	return l.mgr.FreezeEnvironment(envID)
+}
+
+func (l localEndpoint) exportWorkspace(ctx context.Context, envID string) ([]byte, error) {
+	data, _, err := l.mgr.ArchiveWorkspace(ctx, envID)
+	return data, err
+}
+
+func (l localEndpoint) restore(ctx context.Context, name, spec string) (manager.EnvironmentInfo, error) {
+	info, err := l.mgr.RestoreEnvironmentFromSpec(ctx, name, spec, manager.RestoreOptions{Format: manager.SpecJumpboot})
+	if err != nil {
+		return manager.EnvironmentInfo{}, err
+	}
+	return *info, nil
+}
+
+func (l localEndpoint) importWorkspace(ctx context.Context, envID string, archive []byte) (*manager.WorkspaceArchiveInfo, error) {
+	return l.mgr.ExtractWorkspace(ctx, envID, archive)
+}
+
+func (l localEndpoint) destroy(ctx context.Context, envID string) error {
+	return l.mgr.DestroyEnvironment(envID)
+}
+
+// remoteEndpoint migrates from or to a federated server, through its tools
+type remoteEndpoint struct {
+	remotes RemoteServerProvider
+	server  string
+}
+
+func (r remoteEndpoint) info(ctx context.Context, envID string) (manager.EnvironmentInfo, error) {
+	var envs []manager.EnvironmentInfo
+	if err := callRemote(ctx, r.remotes, r.server, "list_environments", map[string]any{}, &envs); err != nil {
+		return manager.EnvironmentInfo{}, err
+	}
+	for _, env := range envs {
+		if env.ID == envID {
+			return env, nil
+		}
+	}
+	return manager.EnvironmentInfo{}, manager.WithErrorCode(manager.CodeNotFound, fmt.Errorf("%s: environment not found: %s", r.server, envID))
+}
+
+func (r remoteEndpoint) freeze(ctx context.Context, envID string) (string, error) {
+	var frozen struct {
+		FrozenJSON string `json:"frozen_json"`
+	}
+	err := callRemote(ctx, r.remotes, r.server, "freeze_environment", map[string]any{"env_id": envID}, &frozen)
+	return frozen.FrozenJSON, err
+}
+
+func (r remoteEndpoint) exportWorkspace(ctx context.Context, envID string) ([]byte, error) {
+	var exported struct {
+		Archive string `json:"archive"`
+	}
+	if err := callRemote(ctx, r.remotes, r.server, "workspace_export", map[string]any{"env_id": envID}, &exported); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(exported.Archive)
+}
+
+func (r remoteEndpoint) restore(ctx context.Context, name, spec string) (manager.EnvironmentInfo, error) {
+	var info manager.EnvironmentInfo
+	args := map[string]any{"name": name, "spec": spec, "format": manager.SpecJumpboot}
+	err := callRemote(ctx, r.remotes, r.server, "restore_environment", args, &info)
+	return info, err
+}
+
+func (r remoteEndpoint) importWorkspace(ctx context.Context, envID string, archive []byte) (*manager.WorkspaceArchiveInfo, error) {
+	var info manager.WorkspaceArchiveInfo
+	args := map[string]any{"env_id": envID, "archive": base64.StdEncoding.EncodeToString(archive)}
+	if err := callRemote(ctx, r.remotes, r.server, "workspace_import", args, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (r remoteEndpoint) destroy(ctx context.Context, envID string) error {
+	return callRemote(ctx, r.remotes, r.server, "destroy_environment", map[string]any{"env_id": envID}, nil)
+}
+
+// migrationEndpoint returns the endpoint for a server name ("" = this server)
+func migrationEndpoint(mgr *manager.Manager, remotes RemoteServerProvider, name string) (envEndpoint, error) {
+	if name == "" {
+		return localEndpoint{mgr: mgr}, nil
+	}
+	for _, info := range remotes.GetRemoteInfos() {
+		if info.InstanceName == name {
+			return remoteEndpoint{remotes: remotes, server: name}, nil
+		}
+	}
+	return nil, manager.WithErrorCode(manager.CodeNotFound, fmt.Errorf("server %s not found", name))
+}
+
+func migrateEnvironmentHandler(mgr *manager.Manager, remotes RemoteServerProvider) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("source_env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+		sourceServer := request.GetString("source_server", "")
+		targetServer := request.GetString("target_server", "")
+		if sourceServer == targetServer {
+			return mcp.NewToolResultText(manager.ErrorResponse(fmt.Errorf("source and target server are the same"))), nil
+		}
+		source, err := migrationEndpoint(mgr, remotes, sourceServer)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		target, err := migrationEndpoint(mgr, remotes, targetServer)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		name := request.GetString("name", "")
+		includeWorkspace := request.GetBool("include_workspace", true)
+		destroySource := request.GetBool("destroy_source", false)
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			info, err := source.info(ctx, envID)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = info.Name
+			}
+
+			// Read everything from the source before creating anything on the target
+			spec, err := source.freeze(ctx, envID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to freeze source environment: %w", err)
+			}
+			var archive []byte
+			if includeWorkspace && info.WorkspaceDir != "" {
+				if archive, err = source.exportWorkspace(ctx, envID); err != nil {
+					return nil, fmt.Errorf("failed to export workspace: %w", err)
+				}
+			}
+
+			restored, err := target.restore(ctx, name, spec)
+			if err != nil {
+				return nil, fmt.Errorf("failed to restore on target: %w", err)
+			}
+			result := &MigrationResult{
+				SourceServer: sourceServer,
+				SourceEnvID:  envID,
+				TargetServer: targetServer,
+				Environment:  restored,
+			}
+			if archive != nil {
+				if result.Workspace, err = target.importWorkspace(ctx, restored.ID, archive); err != nil {
+					// Do not leave a half-migrated environment behind
+					target.destroy(context.WithoutCancel(ctx), restored.ID)
+					return nil, fmt.Errorf("failed to import workspace: %w", err)
+				}
+			}
+
+			if destroySource {
+				if err := source.destroy(ctx, envID); err != nil {
+					return nil, fmt.Errorf("migrated to %s, but failed to destroy the source: %w", restored.ID, err)
+				}
+				result.SourceDestroyed = true
+			}
+			return result, nil
+		}), nil
+	}
+}
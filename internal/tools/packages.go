@@ -15,32 +15,215 @@ func RegisterPackageTools(mgr *manager.Manager) []ToolDef {
 		{
 			Tool: mcp.NewTool("install_packages",
 				mcp.WithDescription("Install Python packages in an environment"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithArray("packages",
 					mcp.Required(),
-					mcp.Description("List of packages to install"),
+					mcp.Description("Packages to install. With pip, entries may also be workspace files or directories: './dist/mypkg-1.0-py3-none-any.whl', 'dist/mypkg-1.0.tar.gz', '-e ./repo' or './repo[dev]'"),
 					mcp.Items(map[string]interface{}{"type": "string"}),
 				),
 				mcp.WithBoolean("use_conda", mcp.Description("Use conda instead of pip. Default: false")),
+				mcp.WithString("index", mcp.Description("Name of a private pip index or conda channel configured on the server (see list_package_indexes). Default: PyPI or conda-forge")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
 			),
 			Handler: installPackagesHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("check_install",
+				mcp.WithDescription("Check what installing pip packages would do without installing anything: runs the pip resolver with --dry-run and reports the packages that would be installed, upgraded or downgraded, and the requirements of already installed packages the install would break. Call it before an install that might disturb a working environment"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithArray("packages",
+					mcp.Required(),
+					mcp.Description("Packages as for install_packages with pip, e.g. 'torch==2.5.0' or './dist/mypkg-1.0-py3-none-any.whl'"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("index", mcp.Description("Name of a private pip index configured on the server (see list_package_indexes). Default: PyPI")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: checkInstallHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("install_requirements",
 				mcp.WithDescription("Install packages from a requirements.txt file in the workspace using the environment's pip"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("requirements_path", mcp.Required(), mcp.Description("Path to requirements.txt relative to workspace (e.g., 'repo/requirements.txt')")),
 				mcp.WithBoolean("upgrade", mcp.Description("Upgrade packages if already installed. Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
 			),
 			Handler: installRequirementsHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("project_install",
+				mcp.WithDescription("Install a Python project from the workspace (e.g. a cloned repo) with the backend it uses: uv sync for uv projects, poetry install for Poetry projects, pip install -e . otherwise"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Description("Project directory relative to the workspace (e.g., 'repo'). Default: the workspace root")),
+				mcp.WithString("backend", mcp.Description("'auto', 'pip', 'poetry' or 'uv'. Default: 'auto' (detected from uv.lock, poetry.lock and pyproject.toml)")),
+				mcp.WithArray("extras",
+					mcp.Description("Optional dependency sets (extras) to install"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithArray("groups",
+					mcp.Description("Dependency groups to install (pip needs 25.1 or later)"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("editable", mcp.Description("pip only: install in development mode (-e). Default: true")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: projectInstallHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("build_package",
+				mcp.WithDescription("Build wheels and/or sdists of a workspace project with python -m build, returning the paths (and optionally base64 content) of the artifacts"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Description("Project directory relative to the workspace. Default: the workspace root")),
+				mcp.WithString("outdir", mcp.Description("Output directory relative to the workspace. Default: '<path>/dist'")),
+				mcp.WithBoolean("sdist", mcp.Description("Build an sdist. Without sdist and wheel, both are built")),
+				mcp.WithBoolean("wheel", mcp.Description("Build a wheel. Without sdist and wheel, both are built")),
+				mcp.WithBoolean("no_isolation", mcp.Description("Use the environment's packages instead of an isolated build environment (works offline). Default: false")),
+				mcp.WithBoolean("include_content", mcp.Description("Return the artifacts base64-encoded (up to 50 MB in total). Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: buildPackageHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("list_package_indexes",
+				mcp.WithDescription("List the private pip indexes and conda channels configured on the server, by the names install_packages accepts as index. Credentials are never shown"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: listPackageIndexesHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("list_packages",
 				mcp.WithDescription("List installed packages in an environment"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 			),
 			Handler: listPackagesHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("freeze_requirements",
+				mcp.WithDescription("Write the environment's installed packages, pinned to their versions, to a requirements.txt in the workspace. Development and tooling packages (pip, setuptools, ruff, pytest, ipykernel, ...) are left out unless include_tooling is set"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Description("Workspace file to write (overwritten). Default: 'requirements.txt'")),
+				mcp.WithArray("exclude",
+					mcp.Description("Additional package names to leave out"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("include_tooling", mcp.Description("Keep development and tooling packages. Default: false")),
+			),
+			Handler: freezeRequirementsHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("package_docs",
+				mcp.WithDescription("Show the docstring, signature and public members of a module, class or function as installed in an environment (e.g. 'requests', 'pandas.DataFrame.merge'). Use it to check the actual API of the installed version"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("target", mcp.Required(), mcp.Description("Dotted import path of a module or object (e.g. 'numpy.linalg.norm')")),
+				mcp.WithNumber("max_members", mcp.Description("Maximum members listed for modules and classes. Default: 100")),
+				mcp.WithBoolean("include_private", mcp.Description("Also list private members and names missing from __all__. Default: false")),
+			),
+			Handler: packageDocsHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("package_info",
+				mcp.WithDescription("Look a package up on PyPI before installing it: summary, license, links, the dependencies and Python requirement of a release, and the available versions. With env_id it also shows the installed version and whether the release supports the environment's Python. With a private index only the versions are available"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("name", mcp.Required(), mcp.Description("Package name, e.g. 'requests'")),
+				mcp.WithString("version", mcp.Description("Release to describe. Default: the latest")),
+				mcp.WithString("env_id", mcp.Description("Environment to compare against")),
+				mcp.WithString("index", mcp.Description("Name of a private pip index configured on the server (see list_package_indexes), queried with pip from env_id. Default: PyPI")),
+			),
+			Handler: packageInfoHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("package_search",
+				mcp.WithDescription("Search PyPI for packages by keyword, returning names, latest versions and summaries in PyPI's relevance order. Follow up with package_info for details"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("query", mcp.Required(), mcp.Description("Search terms, e.g. 'pdf table extraction'")),
+				mcp.WithNumber("limit", mcp.Description("Maximum results (max 100). Default: 20")),
+			),
+			Handler: packageSearchHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("model_download",
+				mcp.WithDescription("Download a Hugging Face model (or dataset) snapshot with huggingface_hub into the server's shared model cache (HF_HOME), installing huggingface_hub on first use. Every environment sees the same cache, so weights are stored once; load them from the returned local_path or by repo ID"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment whose interpreter runs the download")),
+				mcp.WithString("repo_id", mcp.Required(), mcp.Description("Repository ID (e.g. 'meta-llama/Llama-3.1-8B')")),
+				mcp.WithString("revision", mcp.Description("Branch, tag or commit. Default: the repository's default branch")),
+				mcp.WithString("repo_type", mcp.Description("Repository type. Default: model"), mcp.Enum("model", "dataset", "space")),
+				mcp.WithArray("allow_patterns",
+					mcp.Description("Only download files matching these globs (e.g. ['*.safetensors', '*.json'])"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithArray("ignore_patterns",
+					mcp.Description("Skip files matching these globs (e.g. ['*.bin'])"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("token", mcp.Description("Hugging Face token for gated or private repositories. Default: HF_TOKEN of the server")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: modelDownloadHandler(mgr),
+		},
 	}
 }
 
@@ -52,6 +235,7 @@ func installPackagesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 		}
 
 		useConda := request.GetBool("use_conda", false)
+		index := request.GetString("index", "")
 
 		// Extract packages array from arguments
 		args := request.GetArguments()
@@ -80,15 +264,33 @@ func installPackagesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
 		}
 
-		err := mgr.InstallPackages(envID, packages, useConda)
-		if err != nil {
-			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			if err := mgr.InstallPackagesFromIndex(ctx, envID, packages, useConda, index); err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"message":  "Packages installed successfully",
+				"packages": packages,
+			}, nil
+		}), nil
+	}
+}
+
+func checkInstallHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
 		}
+		packages := stringArrayArg(request, "packages")
+		if len(packages) == 0 {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+		index := request.GetString("index", "")
 
-		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
-			"message":  "Packages installed successfully",
-			"packages": packages,
-		})), nil
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.CheckInstall(ctx, envID, packages, index)
+		}), nil
 	}
 }
 
@@ -106,15 +308,84 @@ func installRequirementsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 
 		upgrade := request.GetBool("upgrade", false)
 
-		err := mgr.InstallRequirements(envID, requirementsPath, upgrade)
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			if err := mgr.InstallRequirements(ctx, envID, requirementsPath, upgrade); err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"message":           "Requirements installed successfully",
+				"requirements_path": requirementsPath,
+			}, nil
+		}), nil
+	}
+}
+
+func projectInstallHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		opts := manager.ProjectInstallOptions{
+			Path:     request.GetString("path", ""),
+			Backend:  request.GetString("backend", manager.ProjectBackendAuto),
+			Extras:   stringArrayArg(request, "extras"),
+			Groups:   stringArrayArg(request, "groups"),
+			Editable: request.GetBool("editable", true),
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.ProjectInstall(ctx, envID, opts)
+		}), nil
+	}
+}
+
+func buildPackageHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		opts := manager.BuildOptions{
+			Path:           request.GetString("path", ""),
+			OutDir:         request.GetString("outdir", ""),
+			Sdist:          request.GetBool("sdist", false),
+			Wheel:          request.GetBool("wheel", false),
+			NoIsolation:    request.GetBool("no_isolation", false),
+			IncludeContent: request.GetBool("include_content", false),
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.BuildPackage(ctx, envID, opts)
+		}), nil
+	}
+}
+
+func freezeRequirementsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		result, err := mgr.FreezeRequirements(ctx, envID, manager.FreezeRequirementsOptions{
+			Path:           request.GetString("path", ""),
+			Exclude:        stringArrayArg(request, "exclude"),
+			IncludeTooling: request.GetBool("include_tooling", false),
+		})
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
 
-		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
-			"message":           "Requirements installed successfully",
-			"requirements_path": requirementsPath,
-		})), nil
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func listPackageIndexesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(manager.SuccessResponse(mgr.PackageIndexes())), nil
 	}
 }
 
@@ -133,3 +404,90 @@ func listPackagesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 		return mcp.NewToolResultText(manager.SuccessResponse(packages)), nil
 	}
 }
+
+func packageDocsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		target := request.GetString("target", "")
+		if target == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		docs, err := mgr.PackageDocs(ctx, envID, target, manager.DocsOptions{
+			MaxMembers:     request.GetInt("max_members", 0),
+			IncludePrivate: request.GetBool("include_private", false),
+		})
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(docs)), nil
+	}
+}
+
+func modelDownloadHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		repoID := request.GetString("repo_id", "")
+		if repoID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		opts := manager.ModelDownloadOptions{
+			Revision:       request.GetString("revision", ""),
+			RepoType:       request.GetString("repo_type", ""),
+			AllowPatterns:  stringArrayArg(request, "allow_patterns"),
+			IgnorePatterns: stringArrayArg(request, "ignore_patterns"),
+			Token:          request.GetString("token", ""),
+		}
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.DownloadModel(ctx, envID, repoID, opts)
+		}), nil
+	}
+}
+
+func packageInfoHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.GetString("name", "")
+		if name == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		details, err := mgr.PackageInfo(ctx, name, manager.PackageInfoOptions{
+			Version: request.GetString("version", ""),
+			EnvID:   request.GetString("env_id", ""),
+			Index:   request.GetString("index", ""),
+		})
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		return mcp.NewToolResultText(manager.SuccessResponse(details)), nil
+	}
+}
+
+func packageSearchHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query := request.GetString("query", "")
+		if query == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		hits, err := mgr.SearchPackages(ctx, query, request.GetInt("limit", 0))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]any{
+			"query":   query,
+			"results": hits,
+		})), nil
+	}
+}
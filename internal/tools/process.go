@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -16,6 +18,10 @@ func RegisterProcessTools(mgr *manager.Manager) []ToolDef {
 		{
 			Tool: mcp.NewTool("spawn_process",
 				mcp.WithDescription("Spawn a Python script that runs in the background. Use for long-running tasks like GUI apps, servers, or games."),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("script_path", mcp.Required(), mcp.Description("Path to the Python script (relative to workspace)")),
 				mcp.WithString("name", mcp.Description("Name for the process (defaults to script filename)")),
@@ -24,26 +30,87 @@ func RegisterProcessTools(mgr *manager.Manager) []ToolDef {
 					mcp.Items(map[string]interface{}{"type": "string"}),
 				),
 				mcp.WithBoolean("capture_output", mcp.Description("Capture stdout/stderr for later retrieval. Set false for GUI apps. Default: true")),
+				logFileOption,
+				outputMaxLinesOption,
+				outputMaxBytesOption,
+				spillOutputOption,
+				mcp.WithNumber("metrics_port", mcp.Description("Local port on which the process exposes Prometheus metrics. The server scrapes it and re-exports the samples on its own /metrics endpoint (HTTP mode)")),
+				mcp.WithString("metrics_path", mcp.Description("HTTP path of the process metrics endpoint. Default: '/metrics'")),
+				gpuDevicesOption,
+				webhookURLOption,
+				webhookSecretOption,
 			),
 			Handler: spawnProcessHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("spawn_command",
+				mcp.WithDescription("Spawn an executable (e.g., uvicorn, npm) that runs in the background in the environment's workspace, with the environment's bin directory first on PATH. Manage it with the process tools"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("command", mcp.Required(), mcp.Description("Executable name (looked up in the environment first) or path relative to the workspace")),
+				mcp.WithString("name", mcp.Description("Name for the process (defaults to the command name)")),
+				mcp.WithArray("args",
+					mcp.Description("Command-line arguments"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("capture_output", mcp.Description("Capture stdout/stderr for later retrieval. Default: true")),
+				logFileOption,
+				outputMaxLinesOption,
+				outputMaxBytesOption,
+				spillOutputOption,
+				mcp.WithNumber("metrics_port", mcp.Description("Local port on which the process exposes Prometheus metrics (HTTP mode)")),
+				mcp.WithString("metrics_path", mcp.Description("HTTP path of the process metrics endpoint. Default: '/metrics'")),
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: spawnCommandHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("list_processes",
 				mcp.WithDescription("List all spawned processes"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 			),
 			Handler: listProcessesHandler(mgr),
 		},
 		{
 			Tool: mcp.NewTool("process_output",
 				mcp.WithDescription("Get stdout/stderr output from a spawned process"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("process_id", mcp.Required(), mcp.Description("Process ID")),
 				mcp.WithNumber("tail_lines", mcp.Description("Number of lines to return from the end. Default: all lines")),
+				mcp.WithNumber("offset", mcp.Description("Return lines starting at this line number (0 = first line the process wrote) instead of the tail. Use next_offset from the previous call to follow output")),
+				mcp.WithNumber("limit", mcp.Description("Maximum lines returned with offset. Default: all remaining")),
 			),
 			Handler: processOutputHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("process_logs",
+				mcp.WithDescription("Tail the log file of a process started with log_file=true. Works whether or not capture_output is enabled, e.g. to see why a GUI app failed"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("process_id", mcp.Required(), mcp.Description("Process ID")),
+				mcp.WithNumber("lines", mcp.Description("Number of lines to return from the end. Default: 100")),
+			),
+			Handler: processLogsHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("kill_process",
 				mcp.WithDescription("Terminate a spawned process"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("process_id", mcp.Required(), mcp.Description("Process ID to kill")),
 			),
 			Handler: killProcessHandler(mgr),
@@ -85,7 +152,104 @@ func spawnProcessHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			}
 		}
 
-		info, err := mgr.SpawnProcess(envID, scriptPath, name, args, captureOutput)
+		opts := manager.SpawnOptions{
+			Name:           name,
+			Args:           args,
+			CaptureOutput:  captureOutput,
+			MetricsURL:     metricsURLArg(request),
+			GPUDevices:     gpuDevicesArg(request),
+			LogToFile:      request.GetBool("log_file", false),
+			OutputMaxLines: request.GetInt("output_max_lines", 0),
+			OutputMaxBytes: request.GetInt("output_max_bytes", 0),
+			SpillOutput:    request.GetBool("spill_output", false),
+			Webhook:        webhookArg(request),
+		}
+
+		info, err := mgr.SpawnProcess(ctx, envID, scriptPath, opts)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+// logFileOption is the "log_file" parameter of the spawn tools
+var logFileOption = mcp.WithBoolean("log_file",
+	mcp.Description("Also write stdout/stderr to a rotating log file under the workspace's logs/ directory, readable with process_logs even without capture_output. Default: false"))
+
+// Output buffer parameters of the spawn tools
+var (
+	outputMaxLinesOption = mcp.WithNumber("output_max_lines",
+		mcp.Description("Captured lines kept in memory; older lines are dropped. Default: the server's -process-output-lines (1000)"))
+	outputMaxBytesOption = mcp.WithNumber("output_max_bytes",
+		mcp.Description("Captured bytes kept in memory; older lines are dropped. Default: the server's -process-output-kb (1 MB)"))
+	spillOutputOption = mcp.WithBoolean("spill_output",
+		mcp.Description("Also write all captured output to a file in the environment directory, so process_output can return lines dropped from memory and output survives a server restart. Default: false"))
+)
+
+// gpuDevicesOption is the "gpu_devices" parameter of tools that start a Python process
+var gpuDevicesOption = mcp.WithArray("gpu_devices",
+	mcp.Description("GPUs the process may use, as indices or UUIDs from gpu_info (e.g., ['0', '1']). Sets CUDA_VISIBLE_DEVICES and ROCR_VISIBLE_DEVICES. Default: all GPUs"),
+	mcp.Items(map[string]interface{}{"type": "string"}),
+)
+
+// gpuDevicesArg returns the gpu_devices argument, accepting numeric indices as well
+func gpuDevicesArg(request mcp.CallToolRequest) []string {
+	raw, ok := request.GetArguments()["gpu_devices"].([]interface{})
+	if !ok {
+		return stringArrayArg(request, "gpu_devices")
+	}
+	devices := make([]string, 0, len(raw))
+	for _, d := range raw {
+		switch v := d.(type) {
+		case string:
+			devices = append(devices, v)
+		case float64:
+			devices = append(devices, strconv.Itoa(int(v)))
+		}
+	}
+	return devices
+}
+
+// metricsURLArg builds the scrape URL from the metrics_port and metrics_path arguments
+func metricsURLArg(request mcp.CallToolRequest) string {
+	port := request.GetInt("metrics_port", 0)
+	if port <= 0 {
+		return ""
+	}
+	metricsPath := request.GetString("metrics_path", "/metrics")
+	if !strings.HasPrefix(metricsPath, "/") {
+		metricsPath = "/" + metricsPath
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d%s", port, metricsPath)
+}
+
+func spawnCommandHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		command := request.GetString("command", "")
+		if command == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		opts := manager.SpawnOptions{
+			Name:           request.GetString("name", ""),
+			Args:           stringArrayArg(request, "args"),
+			CaptureOutput:  request.GetBool("capture_output", true),
+			MetricsURL:     metricsURLArg(request),
+			LogToFile:      request.GetBool("log_file", false),
+			OutputMaxLines: request.GetInt("output_max_lines", 0),
+			OutputMaxBytes: request.GetInt("output_max_bytes", 0),
+			SpillOutput:    request.GetBool("spill_output", false),
+			Webhook:        webhookArg(request),
+		}
+
+		info, err := mgr.SpawnCommand(ctx, envID, command, opts)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
@@ -96,7 +260,7 @@ func spawnProcessHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 
 func listProcessesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		processes := mgr.ListProcesses()
+		processes := mgr.ListProcesses(ctx)
 		return mcp.NewToolResultText(manager.SuccessResponse(processes)), nil
 	}
 }
@@ -108,9 +272,19 @@ func processOutputHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
 		}
 
+		args := request.GetArguments()
+		_, hasOffset := args["offset"]
+		_, hasLimit := args["limit"]
+		if hasOffset || hasLimit {
+			output, err := mgr.GetProcessOutputRange(processID, request.GetInt("offset", 0), request.GetInt("limit", 0))
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return mcp.NewToolResultText(manager.SuccessResponse(output)), nil
+		}
+
 		// Extract tail_lines from arguments
 		tailLines := 0
-		args := request.GetArguments()
 		if v, ok := args["tail_lines"]; ok {
 			switch n := v.(type) {
 			case float64:
@@ -137,6 +311,22 @@ func processOutputHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	}
 }
 
+func processLogsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		processID := request.GetString("process_id", "")
+		if processID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		logs, err := mgr.GetProcessLogs(processID, request.GetInt("lines", 0))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(logs)), nil
+	}
+}
+
 func killProcessHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		processID := request.GetString("process_id", "")
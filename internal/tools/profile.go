@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterProfileTools registers the profiling tools with the server
+func RegisterProfileTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("profile_script",
+				mcp.WithDescription("Profile Python code and return the functions taking the most time (file, line, calls, self and total seconds and percentages). With filename, runs a workspace script under cProfile to completion and returns its output too; with process_id, samples a running spawned process with py-spy (installed on first use) for duration_seconds. Optionally saves a flamegraph SVG in the workspace's artifacts"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Description("Environment ID. Required with filename")),
+				mcp.WithString("filename", mcp.Description("Script to run under cProfile, relative to the workspace")),
+				mcp.WithArray("args",
+					mcp.Description("Command-line arguments for the script"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("process_id", mcp.Description("Spawned process to sample with py-spy instead of running a script")),
+				mcp.WithNumber("duration_seconds", mcp.Description("process_id: seconds to sample, less if the process exits. Default: 10, max 600")),
+				mcp.WithNumber("top", mcp.Description("Number of functions to return. Default: 20, max 500")),
+				mcp.WithString("sort",
+					mcp.Description("'self' ranks by time in the function itself, 'total' by time including the functions it calls. Default: self"),
+					mcp.Enum(manager.ProfileSortSelf, manager.ProfileSortTotal),
+				),
+				mcp.WithBoolean("flamegraph", mcp.Description("Save a flamegraph SVG with the execution's artifacts. For cProfile the call tree is approximated from the call graph. Default: false")),
+			),
+			Handler: profileScriptHandler(mgr),
+		},
+	}
+}
+
+func profileScriptHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		filename := request.GetString("filename", "")
+		processID := request.GetString("process_id", "")
+		if (filename == "") == (processID == "") {
+			return mcp.NewToolResultText(manager.ErrorResponse(manager.WithErrorCode(manager.CodeInvalidArgument,
+				errors.New("either filename or process_id is required, not both")))), nil
+		}
+
+		opts := manager.ProfileOptions{
+			Args:       stringArrayArg(request, "args"),
+			Duration:   time.Duration(request.GetFloat("duration_seconds", 0) * float64(time.Second)),
+			Top:        request.GetInt("top", 0),
+			Sort:       request.GetString("sort", ""),
+			Flamegraph: request.GetBool("flamegraph", false),
+		}
+		var result *manager.ProfileResult
+		var err error
+		if processID != "" {
+			result, err = mgr.ProfileProcess(ctx, processID, opts)
+		} else {
+			envID := request.GetString("env_id", "")
+			if envID == "" {
+				return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+			}
+			result, err = mgr.ProfileScript(ctx, envID, filename, opts)
+		}
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+	"path"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -14,32 +16,103 @@ func RegisterREPLTools(mgr *manager.Manager) []ToolDef {
 		{
 			Tool: mcp.NewTool("repl_create",
 				mcp.WithDescription("Create a persistent REPL session for an environment"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("session_name", mcp.Required(), mcp.Description("Name for the REPL session")),
+				gpuDevicesOption,
 			),
 			Handler: replCreateHandler(mgr),
 		},
 		{
 			Tool: mcp.NewTool("repl_execute",
-				mcp.WithDescription("Execute code in a REPL session (state is preserved between calls)"),
-				mcp.WithString("session_id", mcp.Required(), mcp.Description("REPL session ID")),
+				mcp.WithDescription("Execute code in a REPL session (state is preserved between calls). Address the session by session_id, or by env_id and session_name"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("session_id", mcp.Description("REPL session ID")),
+				mcp.WithString("env_id", mcp.Description("Environment ID (with session_name, instead of session_id)")),
+				mcp.WithString("session_name", mcp.Description("REPL session name (with env_id, instead of session_id)")),
 				mcp.WithString("code", mcp.Required(), mcp.Description("Python code to execute")),
+				mcp.WithString("output_format", mcp.Description("'text' returns the combined output; 'structured' returns stdout, stderr, the repr of a trailing expression and any exception separately. Default: text")),
 			),
 			Handler: replExecuteHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("repl_install",
+				mcp.WithDescription("Install packages into a REPL session's environment and make them importable in the running session without a restart (like %pip install). Reports already-imported modules that need a reload. Address the session by session_id, or by env_id and session_name"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("session_id", mcp.Description("REPL session ID")),
+				mcp.WithString("env_id", mcp.Description("Environment ID (with session_name, instead of session_id)")),
+				mcp.WithString("session_name", mcp.Description("REPL session name (with env_id, instead of session_id)")),
+				mcp.WithArray("packages",
+					mcp.Required(),
+					mcp.Description("List of packages to install"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithBoolean("use_conda", mcp.Description("Use conda instead of pip. Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: replInstallHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("repl_list",
 				mcp.WithDescription("List all active REPL sessions"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 			),
 			Handler: replListHandler(mgr),
 		},
 		{
 			Tool: mcp.NewTool("repl_destroy",
 				mcp.WithDescription("Close and destroy a REPL session"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("session_id", mcp.Required(), mcp.Description("REPL session ID to destroy")),
 			),
 			Handler: replDestroyHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("repl_checkpoint",
+				mcp.WithDescription("Save a REPL session's variables and imported modules to a workspace file (pickle, or dill when installed), so the analysis survives a crash or restart. Address the session by session_id, or by env_id and session_name"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("session_id", mcp.Description("REPL session ID")),
+				mcp.WithString("env_id", mcp.Description("Environment ID (with session_name, instead of session_id)")),
+				mcp.WithString("session_name", mcp.Description("REPL session name (with env_id, instead of session_id)")),
+				mcp.WithString("path", mcp.Description("Checkpoint file relative to the workspace. Default: 'checkpoints/<session_name>.pkl'")),
+			),
+			Handler: replCheckpointHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("repl_restore",
+				mcp.WithDescription("Load a checkpoint saved by repl_checkpoint into a new REPL session, or into an existing one"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID whose workspace holds the checkpoint")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("Checkpoint file relative to the workspace")),
+				mcp.WithString("session_id", mcp.Description("Existing REPL session to load into. Default: create a new session")),
+				mcp.WithString("session_name", mcp.Description("Name for the new session. Default: the checkpoint file name")),
+			),
+			Handler: replRestoreHandler(mgr),
+		},
 	}
 }
 
@@ -55,7 +128,7 @@ func replCreateHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
 		}
 
-		info, err := mgr.CreateREPL(envID, sessionName)
+		info, err := mgr.CreateREPL(ctx, envID, sessionName, gpuDevicesArg(request))
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
@@ -64,11 +137,26 @@ func replCreateHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	}
 }
 
+// replSessionArg returns the session addressed by session_id, or by env_id and session_name
+func replSessionArg(ctx context.Context, mgr *manager.Manager, request mcp.CallToolRequest) (string, error) {
+	sessionID := request.GetString("session_id", "")
+	if sessionID != "" {
+		return sessionID, nil
+	}
+
+	envID := request.GetString("env_id", "")
+	sessionName := request.GetString("session_name", "")
+	if envID == "" || sessionName == "" {
+		return "", errMissingSessionID
+	}
+	return mgr.FindREPL(ctx, envID, sessionName)
+}
+
 func replExecuteHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		sessionID := request.GetString("session_id", "")
-		if sessionID == "" {
-			return mcp.NewToolResultText(manager.ErrorResponse(errMissingSessionID)), nil
+		sessionID, err := replSessionArg(ctx, mgr, request)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
 
 		code := request.GetString("code", "")
@@ -76,7 +164,19 @@ func replExecuteHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingCode)), nil
 		}
 
-		output, err := mgr.ExecuteREPL(sessionID, code)
+		switch request.GetString("output_format", "text") {
+		case "text":
+		case "structured":
+			result, err := mgr.ExecuteREPLStructured(ctx, sessionID, code)
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+		default:
+			return mcp.NewToolResultText(manager.ErrorResponse(errInvalidOutputFormat)), nil
+		}
+
+		output, err := mgr.ExecuteREPL(ctx, sessionID, code)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
@@ -87,9 +187,29 @@ func replExecuteHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	}
 }
 
+func replInstallHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sessionID, err := replSessionArg(ctx, mgr, request)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		packages := stringArrayArg(request, "packages")
+		if len(packages) == 0 {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		useConda := request.GetBool("use_conda", false)
+
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.InstallREPLPackages(ctx, sessionID, packages, useConda)
+		}), nil
+	}
+}
+
 func replListHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		sessions := mgr.ListREPLs()
+		sessions := mgr.ListREPLs(ctx)
 		return mcp.NewToolResultText(manager.SuccessResponse(sessions)), nil
 	}
 }
@@ -112,3 +232,45 @@ func replDestroyHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 		})), nil
 	}
 }
+
+func replCheckpointHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sessionID, err := replSessionArg(ctx, mgr, request)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		info, err := mgr.CheckpointREPL(ctx, sessionID, request.GetString("path", ""))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func replRestoreHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		checkpoint := request.GetString("path", "")
+		if checkpoint == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		sessionName := request.GetString("session_name", "")
+		if sessionName == "" {
+			sessionName = strings.TrimSuffix(path.Base(checkpoint), path.Ext(checkpoint))
+		}
+
+		info, err := mgr.RestoreREPL(ctx, envID, checkpoint, request.GetString("session_id", ""), sessionName)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
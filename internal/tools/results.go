@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterResultTools registers the tool paging through spooled large results
+func RegisterResultTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("fetch_result",
+				mcp.WithDescription("Page through a large tool result that was spooled on the server. Results over the server's size limit come back with long strings cut to their head and tail and a \"spooled\" object holding a result_id and the cut fields; fetch the full content here instead of rerunning the call"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("result_id", mcp.Required(), mcp.Description("result_id from the spooled object")),
+				mcp.WithString("field", mcp.Description("Dotted path of a cut string, as listed in spooled.fields (e.g. 'data.stdout'). Default: the whole result as JSON text")),
+				mcp.WithNumber("offset", mcp.Description("Byte offset to start at; use next_offset of the previous page. Default: 0")),
+				mcp.WithNumber("length", mcp.Description(fmt.Sprintf("Bytes to return. Default: %d, max: %d", manager.DefaultResultPageBytes, manager.MaxResultPageBytes))),
+			),
+			Handler: fetchResultHandler(mgr),
+		},
+	}
+}
+
+func fetchResultHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		resultID := request.GetString("result_id", "")
+		if resultID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingResultID)), nil
+		}
+
+		page, err := mgr.FetchResult(ctx, resultID, request.GetString("field", ""), request.GetInt("offset", 0), request.GetInt("length", 0))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		result := mcp.NewToolResultText(manager.SuccessResponse(page))
+		result.Meta = mcp.NewMetaFromMap(map[string]any{manager.MetaResultPage: true})
+		return result, nil
+	}
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterScheduleTools registers recurring script execution tools with the server
+func RegisterScheduleTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("schedule_create",
+				mcp.WithDescription("Run a workspace script repeatedly in an environment, on a cron expression or at a fixed interval (e.g. monitoring or data refresh). Each run's status and output tail is kept; see schedule_list"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("script_path", mcp.Required(), mcp.Description("Script path relative to the workspace")),
+				mcp.WithArray("args",
+					mcp.Description("Command-line arguments for the script"),
+					mcp.Items(map[string]interface{}{"type": "string"}),
+				),
+				mcp.WithString("cron", mcp.Description("Five-field cron expression in server local time (e.g. '*/15 * * * *', '0 6 * * mon-fri') or @hourly/@daily/@weekly/@monthly")),
+				mcp.WithNumber("interval_seconds", mcp.Description("Fixed interval between runs, instead of cron")),
+				mcp.WithNumber("timeout_seconds", mcp.Description("Per-run time limit. Default: none")),
+				mcp.WithString("name", mcp.Description("Optional label")),
+			),
+			Handler: scheduleCreateHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("schedule_list",
+				mcp.WithDescription("List schedules with their next run and last run output, or show one schedule with its run history"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("schedule_id", mcp.Description("Show only this schedule, including its run history")),
+				mcp.WithString("env_id", mcp.Description("Only list schedules of this environment")),
+			),
+			Handler: scheduleListHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("schedule_delete",
+				mcp.WithDescription("Delete a schedule, cancelling its run if one is in progress"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("schedule_id", mcp.Required(), mcp.Description("Schedule ID")),
+			),
+			Handler: scheduleDeleteHandler(mgr),
+		},
+	}
+}
+
+func scheduleCreateHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		scriptPath := request.GetString("script_path", "")
+		if scriptPath == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		info, err := mgr.CreateSchedule(ctx, envID, manager.ScheduleOptions{
+			Name:       request.GetString("name", ""),
+			ScriptPath: scriptPath,
+			Args:       stringArrayArg(request, "args"),
+			Cron:       request.GetString("cron", ""),
+			Interval:   time.Duration(request.GetFloat("interval_seconds", 0) * float64(time.Second)),
+			Timeout:    time.Duration(request.GetFloat("timeout_seconds", 0) * float64(time.Second)),
+		})
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func scheduleListHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if scheduleID := request.GetString("schedule_id", ""); scheduleID != "" {
+			info, err := mgr.GetSchedule(ctx, scheduleID)
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+		}
+
+		schedules := mgr.ListSchedules(ctx, request.GetString("env_id", ""))
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"schedules": schedules,
+			"count":     len(schedules),
+		})), nil
+	}
+}
+
+func scheduleDeleteHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		scheduleID := request.GetString("schedule_id", "")
+		if scheduleID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingScheduleID)), nil
+		}
+
+		info, err := mgr.DeleteSchedule(ctx, scheduleID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterSecretTools registers tools for the server's named secrets
+func RegisterSecretTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("list_secrets",
+				mcp.WithDescription("List the names of the server's secrets, which environment_set_vars and manifests can reference. Values are never returned"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: listSecretsHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("set_secret",
+				mcp.WithDescription("Admin: add or replace a named secret. Environments referencing it get the new value in processes started afterwards, and the value is redacted from all output"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("name", mcp.Required(), mcp.Description("Secret name (letters, digits, '-', '_' and '.')")),
+				mcp.WithString("value", mcp.Required(), mcp.Description("Secret value (at least 4 characters)")),
+			),
+			Handler: setSecretHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("delete_secret",
+				mcp.WithDescription("Admin: delete a named secret. Environments keep their references but no longer receive the variable"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("name", mcp.Required(), mcp.Description("Secret name")),
+			),
+			Handler: deleteSecretHandler(mgr),
+		},
+	}
+}
+
+func listSecretsHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"secrets": mgr.Secrets(),
+		})), nil
+	}
+}
+
+func setSecretHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.GetString("name", "")
+		value := request.GetString("value", "")
+		if name == "" || value == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		info, err := mgr.SetSecret(ctx, name, value)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func deleteSecretHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.GetString("name", "")
+		if name == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		if err := mgr.DeleteSecret(ctx, name); err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"deleted": name,
+		})), nil
+	}
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterSessionTools registers admin tools for resources of vanished MCP sessions
+func RegisterSessionTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("list_orphaned_resources",
+				mcp.WithDescription("Admin: list MCP sessions that disconnected or went idle while still owning environments, REPLs, processes, terminals or running jobs, with when each will be reaped"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: listOrphanedResourcesHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("claim_orphaned_resources",
+				mcp.WithDescription("Admin: transfer all resources of a dead MCP session to another session (default: the caller's), so they are not reaped"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("owner", mcp.Required(), mcp.Description("MCP session ID of the dead session, as reported by list_orphaned_resources")),
+				mcp.WithString("target_session", mcp.Description("MCP session ID that receives the resources. Default: the calling session")),
+			),
+			Handler: claimOrphanedResourcesHandler(mgr),
+		},
+	}
+}
+
+func listOrphanedResourcesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		orphans, err := mgr.OrphanedSessions(ctx)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(orphans)), nil
+	}
+}
+
+func claimOrphanedResourcesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		owner := request.GetString("owner", "")
+		if owner == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		claimed, err := mgr.ClaimOrphanedSession(ctx, owner, request.GetString("target_session", ""))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(claimed)), nil
+	}
+}
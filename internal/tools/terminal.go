@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// RegisterTerminalTools registers interactive terminal tools with the server
+func RegisterTerminalTools(mgr *manager.Manager) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("terminal_create",
+				mcp.WithDescription("Start a persistent interactive shell (PTY) in the environment's workspace with the environment activated. Use for make, interactive installers and multi-step shell work"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("name", mcp.Description("Name for the terminal")),
+				mcp.WithString("shell", mcp.Description("Shell to run. Default: $SHELL, /bin/bash or /bin/sh")),
+				mcp.WithString("term", mcp.Description("TERM for the shell. Default: dumb. Use xterm-256color for full-screen programs and read them with raw=true")),
+				mcp.WithNumber("cols", mcp.Description("Terminal width in columns. Default: 120")),
+				mcp.WithNumber("rows", mcp.Description("Terminal height in rows. Default: 40")),
+			),
+			Handler: terminalCreateHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("terminal_send",
+				mcp.WithDescription("Send input to a terminal. Control characters can be sent as escapes (e.g., \\u0003 for Ctrl-C, \\u0004 for Ctrl-D)"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("terminal_id", mcp.Required(), mcp.Description("Terminal ID")),
+				mcp.WithString("input", mcp.Required(), mcp.Description("Text to send")),
+				mcp.WithBoolean("enter", mcp.Description("Append a newline to run the input as a command. Default: true")),
+			),
+			Handler: terminalSendHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("terminal_read",
+				mcp.WithDescription("Read terminal output produced since the previous read, waiting for output to settle. Output is plain text unless raw is set"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("terminal_id", mcp.Required(), mcp.Description("Terminal ID")),
+				mcp.WithNumber("timeout_seconds", mcp.Description("Max seconds to wait for output. Default: 2")),
+				mcp.WithBoolean("raw", mcp.Description("Return the output as the terminal sent it, with ANSI escape sequences and carriage returns. Default: false (escapes and control characters stripped, carriage-return redraws collapsed)")),
+			),
+			Handler: terminalReadHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("terminal_resize",
+				mcp.WithDescription("Change a terminal's window size. Programs running in it get SIGWINCH and redraw. Only PTY terminals (Linux) can be resized"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("terminal_id", mcp.Required(), mcp.Description("Terminal ID")),
+				mcp.WithNumber("cols", mcp.Required(), mcp.Description("Width in columns")),
+				mcp.WithNumber("rows", mcp.Required(), mcp.Description("Height in rows")),
+			),
+			Handler: terminalResizeHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("terminal_list",
+				mcp.WithDescription("List terminal sessions"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: terminalListHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("terminal_close",
+				mcp.WithDescription("Close a terminal and kill everything running in it"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("terminal_id", mcp.Required(), mcp.Description("Terminal ID to close")),
+			),
+			Handler: terminalCloseHandler(mgr),
+		},
+	}
+}
+
+func terminalCreateHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		info, err := mgr.CreateTerminal(ctx, envID, manager.TerminalOptions{
+			Name:  request.GetString("name", ""),
+			Shell: request.GetString("shell", ""),
+			Term:  request.GetString("term", ""),
+			Cols:  request.GetInt("cols", 0),
+			Rows:  request.GetInt("rows", 0),
+		})
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func terminalSendHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		terminalID := request.GetString("terminal_id", "")
+		if terminalID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingTermID)), nil
+		}
+
+		input := request.GetString("input", "")
+		if request.GetBool("enter", true) {
+			input += "\n"
+		}
+		if input == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		if err := mgr.SendTerminal(terminalID, input); err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"terminal_id": terminalID,
+			"bytes_sent":  len(input),
+		})), nil
+	}
+}
+
+func terminalReadHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		terminalID := request.GetString("terminal_id", "")
+		if terminalID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingTermID)), nil
+		}
+
+		timeout := time.Duration(request.GetFloat("timeout_seconds", 2) * float64(time.Second))
+
+		output, err := mgr.ReadTerminal(ctx, terminalID, timeout, request.GetBool("raw", false))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(output)), nil
+	}
+}
+
+func terminalResizeHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		terminalID := request.GetString("terminal_id", "")
+		if terminalID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingTermID)), nil
+		}
+
+		info, err := mgr.ResizeTerminal(terminalID, request.GetInt("cols", 0), request.GetInt("rows", 0))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func terminalListHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		terminals := mgr.ListTerminals(ctx)
+		return mcp.NewToolResultText(manager.SuccessResponse(terminals)), nil
+	}
+}
+
+func terminalCloseHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		terminalID := request.GetString("terminal_id", "")
+		if terminalID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingTermID)), nil
+		}
+
+		if err := mgr.CloseTerminal(terminalID); err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]string{
+			"message":     "Terminal closed successfully",
+			"terminal_id": terminalID,
+		})), nil
+	}
+}
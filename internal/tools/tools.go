@@ -1,18 +1,30 @@
 package tools
 
 import (
+	"encoding/json"
 	"errors"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
 )
 
 // Common errors
 var (
-	errMissingEnvID    = errors.New("env_id is required")
-	errMissingParams   = errors.New("missing required parameters")
-	errMissingCode     = errors.New("code is required")
-	errMissingSessionID = errors.New("session_id is required")
+	errMissingEnvID        = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("env_id is required"))
+	errMissingParams       = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("missing required parameters"))
+	errMissingCode         = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("code is required"))
+	errMissingSessionID    = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("session_id is required"))
+	errMissingJobID        = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("job_id is required"))
+	errMissingTermID       = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("terminal_id is required"))
+	errMissingDebugID      = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("debug_id is required"))
+	errMissingScheduleID   = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("schedule_id is required"))
+	errMissingResultID     = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("result_id is required"))
+	errWebhookNeedsAsync   = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("webhook_url requires async=true"))
+	errPriorityNeedsAsync  = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("priority requires async=true"))
+	errInvalidOutputFormat = manager.WithErrorCode(manager.CodeInvalidArgument, errors.New("output_format must be text or structured"))
 )
 
 // ToolDef pairs a tool with its handler
@@ -20,3 +32,90 @@ type ToolDef struct {
 	Tool    mcp.Tool
 	Handler server.ToolHandlerFunc
 }
+
+// ResourceTemplateDef pairs a resource template with its handler
+type ResourceTemplateDef struct {
+	Template mcp.ResourceTemplate
+	Handler  server.ResourceTemplateHandlerFunc
+}
+
+// stringArrayArg extracts a string array argument, tolerating the different
+// shapes clients send ([]interface{}, []string, or other JSON-compatible values)
+func stringArrayArg(request mcp.CallToolRequest, key string) []string {
+	var result []string
+	raw, ok := request.GetArguments()[key]
+	if !ok {
+		return nil
+	}
+	switch v := raw.(type) {
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				result = append(result, s)
+			}
+		}
+	case []string:
+		result = v
+	default:
+		data, _ := json.Marshal(raw)
+		json.Unmarshal(data, &result)
+	}
+	return result
+}
+
+// stringMapArg extracts an object argument as a string map. Numbers and booleans are
+// accepted in their JSON form.
+func stringMapArg(request mcp.CallToolRequest, key string) map[string]string {
+	raw, ok := request.GetArguments()[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	result := make(map[string]string, len(raw))
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok {
+			data, _ := json.Marshal(v)
+			s = string(data)
+		}
+		result[k] = s
+	}
+	return result
+}
+
+// SummarizeDescription shortens a tool description to at most maxLen bytes. It
+// prefers cutting at the end of the first sentence and falls back to a hard
+// truncation with an ellipsis, never inside a UTF-8 character. A maxLen <= 0
+// disables summarization.
+func SummarizeDescription(desc string, maxLen int) string {
+	if maxLen <= 0 || len(desc) <= maxLen {
+		return desc
+	}
+
+	// Prefer the first sentence if it fits within the budget
+	if idx := strings.Index(desc, ". "); idx > 0 && idx+1 <= maxLen {
+		return desc[:idx+1]
+	}
+
+	if maxLen <= 3 {
+		return desc[:runeStart(desc, maxLen)]
+	}
+	return strings.TrimSpace(desc[:runeStart(desc, maxLen-3)]) + "..."
+}
+
+// runeStart moves i back to the start of the UTF-8 character it falls in
+func runeStart(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// EstimateTokens returns a rough token estimate for a set of tools as they would
+// appear in a tools/list response (approximately 4 bytes per token)
+func EstimateTokens(tools []mcp.Tool) int {
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return (len(data) + 3) / 4
+}
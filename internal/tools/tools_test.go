@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSummarizeDescription(t *testing.T) {
+	tests := []struct {
+		desc   string
+		maxLen int
+		want   string
+	}{
+		{"Short.", 0, "Short."},
+		{"Short.", 10, "Short."},
+		{"First sentence. Second sentence.", 20, "First sentence."},
+		{"No sentence break here at all", 12, "No senten..."},
+		{"abcdef", 3, "abc"},
+		// é is 2 bytes and ☕ 3: cuts back off to the start of the character
+		{"caféterias", 6, "caf..."},
+		{"café", 4, "c..."},
+		{"☕☕☕", 5, "..."},
+		{"☕☕☕", 7, "☕..."},
+		{"☕☕☕", 2, ""},
+		{"日本語の説明です", 10, "日本..."},
+	}
+	for _, tt := range tests {
+		got := SummarizeDescription(tt.desc, tt.maxLen)
+		if got != tt.want {
+			t.Errorf("SummarizeDescription(%q, %d) = %q, want %q", tt.desc, tt.maxLen, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("SummarizeDescription(%q, %d) is not valid UTF-8", tt.desc, tt.maxLen)
+		}
+		if tt.maxLen > 0 && len(got) > tt.maxLen {
+			t.Errorf("SummarizeDescription(%q, %d) is %d bytes", tt.desc, tt.maxLen, len(got))
+		}
+	}
+}
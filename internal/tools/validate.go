@@ -0,0 +1,378 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/richinsley/jumpboot-mcp/internal/manager"
+)
+
+// maxToolSuggestions bounds the similar tool names suggested for an unknown tool
+const maxToolSuggestions = 3
+
+// ToolLookup finds a registered tool by name
+type ToolLookup func(name string) (mcp.Tool, bool)
+
+// ToolNames lists the names of the registered tools
+type ToolNames func() []string
+
+// ValidationIssue is a problem found in the arguments of a proposed tool call
+type ValidationIssue struct {
+	Path    string `json:"path,omitempty"` // argument path, e.g. "args[2]"; empty for the call itself
+	Message string `json:"message"`
+}
+
+// CallValidation is the result of validate_call
+type CallValidation struct {
+	Tool        string            `json:"tool"`
+	Server      string            `json:"server,omitempty"` // remote server of a proxied tool
+	Valid       bool              `json:"valid"`
+	Errors      []ValidationIssue `json:"errors"`
+	Warnings    []ValidationIssue `json:"warnings,omitempty"`
+	Suggestions []string          `json:"suggestions,omitempty"` // similar names for an unknown tool
+}
+
+// RegisterValidateTools registers validate_call, which checks a proposed call against
+// the schema of any tool the server offers. lookup and names see every registered
+// tool, including prefixed remote tools; remotes (nil when not federating) resolves
+// "server:tool" names when remote tools are collapsed behind call_remote_tool.
+func RegisterValidateTools(lookup ToolLookup, names ToolNames, remotes RemoteServerProvider) []ToolDef {
+	return []ToolDef{
+		{
+			Tool: mcp.NewTool("validate_call",
+				mcp.WithDescription("Check a proposed tool call against the tool's input schema without executing it. Reports missing required arguments, wrong types, values outside enums or bounds, and unknown arguments. Works for proxied remote tools and call_remote_tool"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("tool", mcp.Required(), mcp.Description("Name of the tool to check (e.g., 'spawn_process' or 'gpu-server:run_code')")),
+				mcp.WithObject("arguments", mcp.Description("Arguments the call would be made with")),
+			),
+			Handler: validateCallHandler(lookup, names, remotes),
+		},
+	}
+}
+
+func validateCallHandler(lookup ToolLookup, names ToolNames, remotes RemoteServerProvider) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.GetString("tool", "")
+		if name == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+		args, ok := request.GetArguments()["arguments"].(map[string]any)
+		if !ok && request.GetArguments()["arguments"] != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(fmt.Errorf("arguments must be an object"))), nil
+		}
+
+		result := &CallValidation{Tool: name, Errors: []ValidationIssue{}}
+		tool, server, found := resolveTool(lookup, remotes, name)
+		if !found {
+			result.Errors = append(result.Errors, ValidationIssue{Message: "unknown tool: " + name})
+			result.Suggestions = similarNames(name, names())
+			return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+		}
+		result.Server = server
+
+		schema, err := inputSchema(tool)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+		var errs, warns []ValidationIssue
+		validateValue("", schema, map[string]any(args), &errs, &warns)
+
+		// Also check the arguments forwarded by call_remote_tool
+		if name == "call_remote_tool" && remotes != nil {
+			remoteServer, _ := args["server"].(string)
+			remoteName, _ := args["tool"].(string)
+			if remoteServer != "" && remoteName != "" {
+				if remoteTool, ok := remotes.RemoteTool(remoteServer, remoteName); ok {
+					remoteSchema, err := inputSchema(remoteTool)
+					if err != nil {
+						return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+					}
+					remoteArgs, _ := args["args"].(map[string]any)
+					validateValue("args", remoteSchema, remoteArgs, &errs, &warns)
+				} else {
+					errs = append(errs, ValidationIssue{Path: "tool", Message: fmt.Sprintf("server %s has no tool %s", remoteServer, remoteName)})
+				}
+			}
+		}
+
+		result.Errors = append(result.Errors, errs...)
+		result.Warnings = warns
+		result.Valid = len(result.Errors) == 0
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+// resolveTool finds a registered tool, or a remote tool addressed as "server:tool"
+func resolveTool(lookup ToolLookup, remotes RemoteServerProvider, name string) (mcp.Tool, string, bool) {
+	if tool, ok := lookup(name); ok {
+		var server string
+		if remotes != nil {
+			server, _, _ = remotes.ToolSource(name)
+		}
+		return tool, server, true
+	}
+	if remotes == nil {
+		return mcp.Tool{}, "", false
+	}
+	server, remoteName, ok := strings.Cut(name, ":")
+	if !ok {
+		return mcp.Tool{}, "", false
+	}
+	tool, ok := remotes.RemoteTool(server, remoteName)
+	return tool, server, ok
+}
+
+// inputSchema returns a tool's input schema as decoded JSON
+func inputSchema(tool mcp.Tool) (map[string]any, error) {
+	data, err := json.Marshal(tool)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema for tool %s: %w", tool.Name, err)
+	}
+	var decoded struct {
+		InputSchema map[string]any `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid schema for tool %s: %w", tool.Name, err)
+	}
+	return decoded.InputSchema, nil
+}
+
+// validateValue checks value against the JSON Schema keywords used by tool input
+// schemas. Arguments the schema does not declare are warnings, since tools ignore them,
+// unless additionalProperties is false.
+func validateValue(path string, schema map[string]any, value any, errs, warns *[]ValidationIssue) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationIssue{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonType(value)
+		matched := false
+		for _, t := range types {
+			if t == actual || (t == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("expected %s, got %s", strings.Join(types, " or "), actual)
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		matched := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			allowed := make([]string, len(enum))
+			for i, v := range enum {
+				allowed[i] = fmt.Sprintf("%q", fmt.Sprint(v))
+			}
+			fail("must be one of %s", strings.Join(allowed, ", "))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if n, ok := schemaNumber(schema, "minLength"); ok && float64(len([]rune(v))) < n {
+			fail("must be at least %g characters", n)
+		}
+		if n, ok := schemaNumber(schema, "maxLength"); ok && float64(len([]rune(v))) > n {
+			fail("must be at most %g characters", n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("must match pattern %s", pattern)
+			}
+		}
+	case float64:
+		if n, ok := schemaNumber(schema, "minimum"); ok && v < n {
+			fail("must be >= %g", n)
+		}
+		if n, ok := schemaNumber(schema, "maximum"); ok && v > n {
+			fail("must be <= %g", n)
+		}
+	case []any:
+		if n, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < n {
+			fail("must have at least %g items", n)
+		}
+		if n, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > n {
+			fail("must have at most %g items", n)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), items, item, errs, warns)
+			}
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, ValidationIssue{Path: joinPath(path, name), Message: "required argument is missing"})
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propSchema, declared := properties[key].(map[string]any)
+			if declared {
+				validateValue(joinPath(path, key), propSchema, v[key], errs, warns)
+				continue
+			}
+			if properties == nil {
+				continue
+			}
+			issue := ValidationIssue{Path: joinPath(path, key), Message: "unknown argument"}
+			if suggestions := similarNames(key, mapKeys(properties)); len(suggestions) > 0 {
+				issue.Message += fmt.Sprintf(" (did you mean %s?)", suggestions[0])
+			}
+			if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				*errs = append(*errs, issue)
+			} else {
+				*warns = append(*warns, issue)
+			}
+		}
+	}
+}
+
+// schemaTypes returns the allowed types of a "type" keyword (a string or a list)
+func schemaTypes(raw any) []string {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// schemaNumber returns a numeric schema keyword
+func schemaNumber(schema map[string]any, key string) (float64, bool) {
+	n, ok := schema[key].(float64)
+	return n, ok
+}
+
+// joinPath appends an object key to an argument path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// mapKeys returns the keys of m
+func mapKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// similarNames returns up to maxToolSuggestions candidates close to name: those that
+// contain it (or are contained in it) first, then those within a small edit distance
+func similarNames(name string, candidates []string) []string {
+	type scored struct {
+		name  string
+		score int
+	}
+	lower := strings.ToLower(name)
+	var matches []scored
+	for _, c := range candidates {
+		lc := strings.ToLower(c)
+		switch {
+		case strings.Contains(lc, lower) || strings.Contains(lower, lc):
+			matches = append(matches, scored{c, 0})
+		default:
+			if d := editDistance(lower, lc); d <= max(2, len(lower)/4) {
+				matches = append(matches, scored{c, d})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score < matches[j].score
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	result := make([]string, 0, maxToolSuggestions)
+	for _, m := range matches {
+		if len(result) == maxToolSuggestions {
+			break
+		}
+		result = append(result, m.name)
+	}
+	return result
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -2,7 +2,10 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -15,6 +18,10 @@ func RegisterWorkspaceTools(mgr *manager.Manager) []ToolDef {
 		{
 			Tool: mcp.NewTool("workspace_create",
 				mcp.WithDescription("Create a temp code folder (workspace) for an environment"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 			),
 			Handler: workspaceCreateHandler(mgr),
@@ -22,6 +29,10 @@ func RegisterWorkspaceTools(mgr *manager.Manager) []ToolDef {
 		{
 			Tool: mcp.NewTool("workspace_write_file",
 				mcp.WithDescription("Write a file to the workspace"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("filename", mcp.Required(), mcp.Description("Name of the file to write")),
 				mcp.WithString("content", mcp.Required(), mcp.Description("Content to write to the file")),
@@ -31,30 +42,166 @@ func RegisterWorkspaceTools(mgr *manager.Manager) []ToolDef {
 		{
 			Tool: mcp.NewTool("workspace_read_file",
 				mcp.WithDescription("Read a file from the workspace"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("filename", mcp.Required(), mcp.Description("Name of the file to read")),
+				mcp.WithNumber("start_line", mcp.Description("First line to read, from 1. With start_line or end_line, only those lines are returned, with the file's total_lines")),
+				mcp.WithNumber("end_line", mcp.Description("Last line to read (inclusive). Default: the end of the file")),
 			),
 			Handler: workspaceReadFileHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("workspace_edit_file",
+				mcp.WithDescription("Edit part of a workspace file without rewriting it: replace lines start_line to end_line with new_content, or replace old_string with new_string. old_string must occur exactly once unless replace_all is set; the error lists the lines of every occurrence"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("filename", mcp.Required(), mcp.Description("Name of the file to edit")),
+				mcp.WithNumber("start_line", mcp.Description("First line to replace, from 1 (line-range edit)")),
+				mcp.WithNumber("end_line", mcp.Description("Last line to replace (inclusive). start_line - 1 inserts new_content before start_line. Default: start_line")),
+				mcp.WithString("new_content", mcp.Description("Lines replacing the range; empty deletes it")),
+				mcp.WithString("old_string", mcp.Description("Exact text to replace (find-and-replace edit)")),
+				mcp.WithString("new_string", mcp.Description("Replacement for old_string")),
+				mcp.WithBoolean("replace_all", mcp.Description("Replace every occurrence of old_string. Default: false")),
+			),
+			Handler: workspaceEditFileHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("workspace_list_files",
-				mcp.WithDescription("List files in the workspace or a subdirectory"),
+				mcp.WithDescription("List files in the workspace or a subdirectory. With recursive, include/exclude globs or format 'tree', returns a filtered listing of the whole tree in one call"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("path", mcp.Description("Subdirectory path to list (e.g., 'repo/src'). Defaults to workspace root")),
+				mcp.WithBoolean("recursive", mcp.Description("List subdirectories too, skipping .git, node_modules, __pycache__, .venv and tool caches. Default: false")),
+				mcp.WithNumber("max_depth", mcp.Description("Directory levels to descend when recursive. Default: 10")),
+				mcp.WithArray("include", mcp.WithStringItems(), mcp.Description("Globs of files to list, e.g. '*.py' (base names) or 'src/**/*.ts' (paths); directories without matches are left out")),
+				mcp.WithArray("exclude", mcp.WithStringItems(), mcp.Description("Globs of files and directories to leave out")),
+				mcp.WithBoolean("include_ignored", mcp.Description("Also walk .git, node_modules and the other skipped directories. Default: false")),
+				mcp.WithNumber("max_entries", mcp.Description("Entries returned (files when include is set). Default: 1000, at most 10000")),
+				mcp.WithString("format", mcp.Description("'list' returns entries; 'tree' returns an indented text tree, which is much smaller. Default: 'list'"), mcp.Enum("list", "tree")),
 			),
 			Handler: workspaceListFilesHandler(mgr),
 		},
 		{
 			Tool: mcp.NewTool("workspace_delete_file",
-				mcp.WithDescription("Delete a file from the workspace"),
+				mcp.WithDescription("Delete a file from the workspace. The file is moved to the environment's trash and can be restored with workspace_restore_trash until the retention period expires"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("filename", mcp.Required(), mcp.Description("Name of the file to delete")),
 			),
 			Handler: workspaceDeleteFileHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("workspace_move",
+				mcp.WithDescription("Move or rename a file or directory within the workspace. Missing parent directories are created. An existing destination file is replaced only with overwrite, and moved to the trash"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("source", mcp.Required(), mcp.Description("File or directory to move, relative to the workspace")),
+				mcp.WithString("destination", mcp.Required(), mcp.Description("New path, relative to the workspace (not a directory to move into)")),
+				mcp.WithBoolean("overwrite", mcp.Description("Replace an existing destination file. Default: false")),
+			),
+			Handler: workspaceMoveHandler(mgr, false),
+		},
+		{
+			Tool: mcp.NewTool("workspace_copy",
+				mcp.WithDescription("Copy a file, or a directory recursively, within the workspace, keeping file modes. Symbolic links are copied as links. An existing destination file is replaced only with overwrite, and moved to the trash"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("source", mcp.Required(), mcp.Description("File or directory to copy, relative to the workspace")),
+				mcp.WithString("destination", mcp.Required(), mcp.Description("Path of the copy, relative to the workspace (not a directory to copy into)")),
+				mcp.WithBoolean("overwrite", mcp.Description("Replace an existing destination file. Default: false")),
+			),
+			Handler: workspaceMoveHandler(mgr, true),
+		},
+		{
+			Tool: mcp.NewTool("workspace_mkdir",
+				mcp.WithDescription("Create a directory in the workspace, with any missing parents. An existing directory is not an error"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("Directory to create, relative to the workspace")),
+			),
+			Handler: workspaceMkdirHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_stat",
+				mcp.WithDescription("Get the metadata of a workspace file or directory without reading it: size, mtime, mode, and for files the MIME type, line count (text files) and sha256. Use it to verify downloads or to see whether a file changed between steps"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("File or directory, relative to the workspace")),
+			),
+			Handler: workspaceStatHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_watch",
+				mcp.WithDescription("Watch workspace paths for files that processes, jobs or tools create, modify or delete. Changes are sent as notifications/jumpboot/workspace_changed and kept for workspace_changes, so agents can react to the outputs of long-running jobs. Paths are scanned every interval_seconds; .git, node_modules and caches are skipped"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithArray("paths", mcp.WithStringItems(), mcp.Description("Files or directories to watch, relative to the workspace. Default: the whole workspace")),
+				mcp.WithArray("include", mcp.WithStringItems(), mcp.Description("Globs of files to report, e.g. '*.csv' or 'out/**/*.png'")),
+				mcp.WithArray("exclude", mcp.WithStringItems(), mcp.Description("Globs of files and directories to ignore")),
+				mcp.WithNumber("interval_seconds", mcp.Description("How often the paths are scanned. Default: 1, at least 0.2")),
+				mcp.WithBoolean("notify", mcp.Description("Send a notification for each batch of changes. Default: true")),
+			),
+			Handler: workspaceWatchHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_changes",
+				mcp.WithDescription("Get the changes a workspace_watch has seen after since, optionally waiting for the next one. Without watch_id, lists the watches"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("watch_id", mcp.Description("Watch ID")),
+				mcp.WithNumber("since", mcp.Description("Return events after this sequence number: the next_since of the previous call. Default: 0, all kept events (the latest 1000)")),
+				mcp.WithNumber("wait_seconds", mcp.Description("If there are no events yet, wait this long for one. Default: 0, at most 60")),
+				mcp.WithString("env_id", mcp.Description("When listing watches, only those of this environment")),
+			),
+			Handler: workspaceChangesHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_unwatch",
+				mcp.WithDescription("Stop a workspace watch and discard its events"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("watch_id", mcp.Required(), mcp.Description("Watch ID")),
+			),
+			Handler: workspaceUnwatchHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("workspace_run_script",
 				mcp.WithDescription("Run a Python script from the workspace"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("filename", mcp.Required(), mcp.Description("Name of the script file to run")),
 				mcp.WithArray("args",
@@ -66,20 +213,391 @@ func RegisterWorkspaceTools(mgr *manager.Manager) []ToolDef {
 		},
 		{
 			Tool: mcp.NewTool("workspace_destroy",
-				mcp.WithDescription("Destroy the workspace (delete all files)"),
+				mcp.WithDescription("Destroy the workspace (delete all files). The workspace is moved to the environment's trash and can be restored with workspace_restore_trash until the retention period expires"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 			),
 			Handler: workspaceDestroyHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("workspace_attach",
+				mcp.WithDescription("Attach an environment to a named shared workspace, creating it if needed, so several environments (e.g. Python 3.10 and 3.12) work on the same files. The environment's own workspace is kept and comes back on workspace_detach. A shared workspace is deleted when its last environment detaches or is destroyed, unless created with persistent"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("name", mcp.Required(), mcp.Description("Shared workspace name (letters, digits, '.', '_' and '-')")),
+				mcp.WithBoolean("persistent", mcp.Description("Keep the shared workspace when no environment is attached. New shared workspaces are not persistent; set false to make an existing one temporary again")),
+			),
+			Handler: workspaceAttachHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_detach",
+				mcp.WithDescription("Detach an environment from its shared workspace and give it back its own workspace. Detaching the last environment deletes a shared workspace that is not persistent"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+			),
+			Handler: workspaceDetachHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_list_shared",
+				mcp.WithDescription("List shared workspaces with their paths and attached environments"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+			),
+			Handler: workspaceListSharedHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_mount",
+				mcp.WithDescription("Mount a host directory or file that the server operator allows (-mount-allow) into the workspace, so local datasets can be used without copying them. Without host_path, lists the workspace's mounts"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("host_path", mcp.Description("Absolute host path inside an allowed directory")),
+				mcp.WithString("path", mcp.Description("Where it appears in the workspace (default: its base name)")),
+				mcp.WithString("mode", mcp.Description("symlink (default) or bind (Linux, needs root or CAP_SYS_ADMIN)")),
+				mcp.WithBoolean("read_only", mcp.Description("Refuse writes through the mount (default false; always on for directories the operator allows read-only)")),
+			),
+			Handler: workspaceMountHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_unmount",
+				mcp.WithDescription("Remove a mount from the workspace. The host files are not touched"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("Mount path in the workspace")),
+			),
+			Handler: workspaceUnmountHandler(mgr),
+		},
 		{
 			Tool: mcp.NewTool("workspace_git_clone",
 				mcp.WithDescription("Clone a git repository into the workspace"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
 				mcp.WithString("repo_url", mcp.Required(), mcp.Description("Git repository URL (https or ssh)")),
 				mcp.WithString("dir_name", mcp.Description("Directory name for the clone (defaults to repo name)")),
 			),
 			Handler: workspaceGitCloneHandler(mgr),
 		},
+		{
+			Tool: mcp.NewTool("workspace_download",
+				mcp.WithDescription("Download a file from an http(s) URL into the workspace (datasets, model weights). Reports progress notifications, enforces a size limit, verifies an optional sha256, and resumes an interrupted download when called again with the same url and path"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("url", mcp.Required(), mcp.Description("http(s) URL to download")),
+				mcp.WithString("path", mcp.Description("Destination path relative to the workspace (defaults to the URL's file name)")),
+				mcp.WithString("sha256", mcp.Description("Expected sha256 hex digest; the download is discarded if it does not match")),
+				mcp.WithNumber("max_mb", mcp.Description("Fail if the file is larger than this many MB (capped by the server's -download-max-mb)")),
+				mcp.WithBoolean("overwrite", mcp.Description("Replace an existing file at path. Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: workspaceDownloadHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_sync_remote",
+				mcp.WithDescription("Sync files between an s3:// or gs:// prefix and the workspace with the server's configured credentials, so datasets and results never pass through the client. pull downloads objects, push uploads files; unchanged files (same size and MD5) are skipped and nothing is deleted"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("uri", mcp.Required(), mcp.Description("s3://bucket/prefix or gs://bucket/prefix; a prefix may also name a single object")),
+				mcp.WithString("direction", mcp.Required(), mcp.Enum(manager.SyncPull, manager.SyncPush), mcp.Description("pull (storage to workspace) or push (workspace to storage)")),
+				mcp.WithString("path", mcp.Description("Workspace directory to sync, or file to push (default: the workspace)")),
+				mcp.WithArray("include", mcp.WithStringItems(), mcp.Description("Globs of files to sync, relative to the prefix or path, e.g. '*.parquet' or 'train/**/*.jpg'")),
+				mcp.WithArray("exclude", mcp.WithStringItems(), mcp.Description("Globs of files to leave out")),
+				mcp.WithBoolean("dry_run", mcp.Description("Only report what would be transferred. Default: false")),
+				asyncOption,
+				priorityOption,
+				webhookURLOption,
+				webhookSecretOption,
+			),
+			Handler: workspaceSyncRemoteHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_git_worktree_add",
+				mcp.WithDescription("Check out another branch of a repository cloned into the workspace as a separate directory (git worktree), sharing the clone's history instead of re-cloning. Use it to compare branches side by side"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("repo_dir", mcp.Required(), mcp.Description("Directory of the existing clone, relative to the workspace")),
+				mcp.WithString("ref", mcp.Description("Branch, tag or commit to check out. A remote branch name creates a local tracking branch. Default with new_branch: HEAD")),
+				mcp.WithString("new_branch", mcp.Description("Create this branch at ref and check it out")),
+				mcp.WithString("dir_name", mcp.Description("Directory name for the worktree (defaults to '<repo>-<branch>')")),
+			),
+			Handler: workspaceGitWorktreeAddHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_list_trash",
+				mcp.WithDescription("List deleted workspace files and destroyed workspaces that can still be restored"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+			),
+			Handler: workspaceListTrashHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_restore_trash",
+				mcp.WithDescription("Restore a deleted file or destroyed workspace from the environment's trash to its original location"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("trash_id", mcp.Required(), mcp.Description("Trash entry ID (from workspace_list_trash or the delete result)")),
+			),
+			Handler: workspaceRestoreTrashHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_export",
+				mcp.WithDescription("Pack the whole workspace into a base64-encoded tar.gz archive (at most 100 MB compressed), e.g. to move it to another server with workspace_import"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+			),
+			Handler: workspaceExportHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_import",
+				mcp.WithDescription("Unpack a base64-encoded tar.gz archive from workspace_export into the workspace, creating it if needed. Existing files with the same paths are overwritten"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("archive", mcp.Required(), mcp.Description("Base64-encoded tar.gz archive")),
+			),
+			Handler: workspaceImportHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("notebook_to_script",
+				mcp.WithDescription("Convert a workspace notebook (.ipynb) to a text file with jupytext, e.g. to refactor or diff it as code. Outputs are dropped; cells become '# %%' blocks. Installs jupytext into the environment on first use. An existing output file is overwritten"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("Notebook relative to the workspace")),
+				mcp.WithString("output_path", mcp.Description("File to write. Default: path with .py (or .md for markdown)")),
+				mcp.WithString("format", mcp.Description("Text format: 'percent' (# %% cell markers), 'light' (cells separated by blank lines) or 'markdown'. Default: 'percent'"),
+					mcp.Enum(manager.NotebookFormatPercent, manager.NotebookFormatLight, manager.NotebookFormatMarkdown)),
+			),
+			Handler: convertNotebookHandler(mgr, false),
+		},
+		{
+			Tool: mcp.NewTool("script_to_notebook",
+				mcp.WithDescription("Convert a workspace script (.py in percent or light format, or .md) to a notebook (.ipynb) with jupytext. If the notebook exists, cells whose code is unchanged keep their outputs. Installs jupytext into the environment on first use"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("Script relative to the workspace")),
+				mcp.WithString("output_path", mcp.Description("Notebook to write. Default: path with .ipynb")),
+			),
+			Handler: convertNotebookHandler(mgr, true),
+		},
+		{
+			Tool: mcp.NewTool("workspace_preview_data",
+				mcp.WithDescription("Preview a CSV, TSV, Parquet, JSON or JSON Lines file in the workspace: column names and types, row count and the first rows, read with the environment's pandas and pyarrow (CSV and JSON also work without them). Nothing is installed"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("File relative to the workspace")),
+				mcp.WithString("format", mcp.Description("File format. Default: 'auto', detected from the extension (.gz and other compression suffixes are ignored)"),
+					mcp.Enum(manager.DataFormatAuto, manager.DataFormatCSV, manager.DataFormatTSV, manager.DataFormatParquet, manager.DataFormatJSON, manager.DataFormatJSONL)),
+				mcp.WithNumber("rows", mcp.Description("Rows to return. Default: 10, at most 100")),
+			),
+			Handler: workspacePreviewDataHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_diff",
+				mcp.WithDescription("Show a unified diff from a workspace file to new content, or to another workspace file. Use it to review an edit before writing it; the diff can be passed to workspace_apply_patch"),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("path", mcp.Required(), mcp.Description("File relative to the workspace (a missing file diffs as empty)")),
+				mcp.WithString("content", mcp.Description("New content to compare the file with. Give this or other_path")),
+				mcp.WithString("other_path", mcp.Description("Workspace file to compare the file with. Give this or content")),
+				mcp.WithNumber("context_lines", mcp.Description("Unchanged lines shown around each change. Default: 3")),
+			),
+			Handler: workspaceDiffHandler(mgr),
+		},
+		{
+			Tool: mcp.NewTool("workspace_apply_patch",
+				mcp.WithDescription("Apply a unified diff (from workspace_diff, git diff or diff -u) to workspace files, to edit large files without rewriting them. Hunks may have moved from the lines their headers name but must otherwise match exactly. Nothing is written unless every hunk applies; dry_run only checks. Files the patch deletes are moved to the trash"),
+				mcp.WithReadOnlyHintAnnotation(false),
+				mcp.WithDestructiveHintAnnotation(true),
+				mcp.WithIdempotentHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(false),
+				mcp.WithString("env_id", mcp.Required(), mcp.Description("Environment ID")),
+				mcp.WithString("patch", mcp.Required(), mcp.Description("Unified diff with ---/+++ file headers and @@ hunks; git's a/ and b/ prefixes are stripped")),
+				mcp.WithString("path", mcp.Description("File to patch instead of the one named in a single-file patch")),
+				mcp.WithBoolean("dry_run", mcp.Description("Check that the patch applies without changing any file. Default: false")),
+			),
+			Handler: workspaceApplyPatchHandler(mgr),
+		},
+	}
+}
+
+func workspaceDiffHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		path := request.GetString("path", "")
+		other := request.GetString("other_path", "")
+		_, hasContent := request.GetArguments()["content"]
+		if path == "" || hasContent == (other != "") {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		diff, err := mgr.DiffWorkspaceFile(envID, path, other, request.GetString("content", ""), request.GetInt("context_lines", -1))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(diff)), nil
+	}
+}
+
+func workspaceApplyPatchHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		patch := request.GetString("patch", "")
+		if patch == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.ApplyPatch(envID, patch, request.GetString("path", ""), request.GetBool("dry_run", false))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func workspacePreviewDataHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		preview, err := mgr.PreviewData(ctx, envID, path, request.GetString("format", ""), request.GetInt("rows", 0))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(preview)), nil
+	}
+}
+
+func convertNotebookHandler(mgr *manager.Manager, toNotebook bool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.ConvertNotebook(ctx, envID, path, request.GetString("output_path", ""), request.GetString("format", ""), toNotebook)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func workspaceExportHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		data, info, err := mgr.ArchiveWorkspace(ctx, envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"env_id":  envID,
+			"files":   info.Files,
+			"bytes":   info.Bytes,
+			"archive": base64.StdEncoding.EncodeToString(data),
+		})), nil
+	}
+}
+
+func workspaceImportHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		data, err := base64.StdEncoding.DecodeString(request.GetString("archive", ""))
+		if err != nil || len(data) == 0 {
+			return mcp.NewToolResultText(manager.ErrorResponse(fmt.Errorf("archive must be a base64-encoded tar.gz"))), nil
+		}
+
+		info, err := mgr.ExtractWorkspace(ctx, envID, data)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
 	}
 }
 
@@ -133,6 +651,15 @@ func workspaceReadFileHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
 		}
 
+		start, end := request.GetInt("start_line", 0), request.GetInt("end_line", 0)
+		if start != 0 || end != 0 {
+			lines, err := mgr.ReadWorkspaceLines(envID, filename, start, end)
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return mcp.NewToolResultText(manager.SuccessResponse(lines)), nil
+		}
+
 		content, err := mgr.ReadWorkspaceFile(envID, filename)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
@@ -145,6 +672,35 @@ func workspaceReadFileHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	}
 }
 
+func workspaceEditFileHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		filename := request.GetString("filename", "")
+		edit := manager.FileEdit{
+			StartLine:  request.GetInt("start_line", 0),
+			NewContent: request.GetString("new_content", ""),
+			OldString:  request.GetString("old_string", ""),
+			NewString:  request.GetString("new_string", ""),
+			ReplaceAll: request.GetBool("replace_all", false),
+		}
+		edit.EndLine = request.GetInt("end_line", edit.StartLine)
+		if filename == "" || (edit.OldString == "") == (edit.StartLine == 0) {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.EditWorkspaceFile(envID, filename, edit)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
 func workspaceListFilesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		envID := request.GetString("env_id", "")
@@ -153,6 +709,25 @@ func workspaceListFilesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 		}
 
 		subpath := request.GetString("path", "")
+		recursive := request.GetBool("recursive", false)
+		opts := manager.TreeOptions{
+			MaxDepth:       1,
+			Include:        stringArrayArg(request, "include"),
+			Exclude:        stringArrayArg(request, "exclude"),
+			IncludeIgnored: request.GetBool("include_ignored", false),
+			MaxEntries:     request.GetInt("max_entries", 0),
+			Tree:           request.GetString("format", "list") == "tree",
+		}
+		if recursive {
+			opts.MaxDepth = request.GetInt("max_depth", 0)
+		}
+		if recursive || opts.Tree || len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+			tree, err := mgr.ListWorkspaceTree(envID, subpath, opts)
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return mcp.NewToolResultText(manager.SuccessResponse(tree)), nil
+		}
 
 		files, err := mgr.ListWorkspaceFiles(envID, subpath)
 		if err != nil {
@@ -175,15 +750,175 @@ func workspaceDeleteFileHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
 		}
 
-		err := mgr.DeleteWorkspaceFile(envID, filename)
+		entry, err := mgr.DeleteWorkspaceFile(envID, filename)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
 
-		return mcp.NewToolResultText(manager.SuccessResponse(map[string]string{
+		result := map[string]string{
 			"message":  "File deleted successfully",
 			"filename": filename,
-		})), nil
+		}
+		if entry != nil {
+			result["trash_id"] = entry.ID
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+// workspaceMoveHandler handles workspace_move, or workspace_copy with copy
+func workspaceMoveHandler(mgr *manager.Manager, copy bool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		source := request.GetString("source", "")
+		destination := request.GetString("destination", "")
+		if source == "" || destination == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		move := mgr.MoveWorkspaceFile
+		if copy {
+			move = mgr.CopyWorkspaceFile
+		}
+		result, err := move(envID, source, destination, request.GetBool("overwrite", false))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func workspaceMkdirHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		result, err := mgr.MakeWorkspaceDir(envID, path)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func workspaceStatHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		stat, err := mgr.StatWorkspaceFile(envID, path)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(stat)), nil
+	}
+}
+
+func workspaceWatchHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		opts := manager.WatchOptions{
+			Paths:    stringArrayArg(request, "paths"),
+			Include:  stringArrayArg(request, "include"),
+			Exclude:  stringArrayArg(request, "exclude"),
+			Interval: time.Duration(request.GetFloat("interval_seconds", 0) * float64(time.Second)),
+		}
+		if request.GetBool("notify", true) {
+			opts.Notify = watchNotifier(ctx)
+		}
+
+		info, err := mgr.WatchWorkspace(ctx, envID, opts)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+// watchNotificationEvents bounds the events of one workspace_changed notification
+const watchNotificationEvents = 100
+
+// watchNotifier returns a callback that sends the changes of a watch to the session
+// in ctx, or nil if there is no session to notify
+func watchNotifier(ctx context.Context) func(watchID, envID string, events []manager.WatchEvent) {
+	srv := server.ServerFromContext(ctx)
+	session := server.ClientSessionFromContext(ctx)
+	if srv == nil || session == nil {
+		return nil
+	}
+	sessionID := session.SessionID()
+	return func(watchID, envID string, events []manager.WatchEvent) {
+		params := map[string]any{
+			"watch_id": watchID,
+			"env_id":   envID,
+			"events":   events,
+		}
+		if len(events) > watchNotificationEvents {
+			params["events"] = events[:watchNotificationEvents]
+			params["truncated"] = true
+		}
+		// Best effort: the client may have disconnected; workspace_changes has them all
+		srv.SendNotificationToSpecificClient(sessionID, "notifications/jumpboot/workspace_changed", params)
+	}
+}
+
+func workspaceChangesHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		watchID := request.GetString("watch_id", "")
+		if watchID == "" {
+			return mcp.NewToolResultText(manager.SuccessResponse(mgr.ListWatches(ctx, request.GetString("env_id", "")))), nil
+		}
+
+		wait := time.Duration(request.GetFloat("wait_seconds", 0) * float64(time.Second))
+		changes, err := mgr.WorkspaceChanges(ctx, watchID, int64(request.GetInt("since", 0)), wait)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(changes)), nil
+	}
+}
+
+func workspaceUnwatchHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		watchID := request.GetString("watch_id", "")
+		if watchID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		info, err := mgr.Unwatch(ctx, watchID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
 	}
 }
 
@@ -218,7 +953,7 @@ func workspaceRunScriptHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			}
 		}
 
-		output, err := mgr.RunWorkspaceScript(envID, filename, args)
+		output, err := mgr.RunWorkspaceScript(ctx, envID, filename, args)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
@@ -236,15 +971,115 @@ func workspaceDestroyHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
 		}
 
-		err := mgr.DestroyWorkspace(envID)
+		entry, err := mgr.DestroyWorkspace(envID)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
 
-		return mcp.NewToolResultText(manager.SuccessResponse(map[string]string{
+		result := map[string]string{
 			"message": "Workspace destroyed successfully",
 			"env_id":  envID,
-		})), nil
+		}
+		if entry != nil {
+			result["trash_id"] = entry.ID
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(result)), nil
+	}
+}
+
+func workspaceAttachHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		name := request.GetString("name", "")
+		if name == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		var persistent *bool
+		if _, ok := request.GetArguments()["persistent"]; ok {
+			p := request.GetBool("persistent", false)
+			persistent = &p
+		}
+
+		shared, err := mgr.AttachWorkspace(ctx, envID, name, persistent)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(shared)), nil
+	}
+}
+
+func workspaceDetachHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		shared, err := mgr.DetachWorkspace(envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(shared)), nil
+	}
+}
+
+func workspaceListSharedHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(manager.SuccessResponse(mgr.ListSharedWorkspaces(ctx))), nil
+	}
+}
+
+func workspaceMountHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		hostPath := request.GetString("host_path", "")
+		if hostPath == "" {
+			mounts, err := mgr.ListMounts(envID)
+			if err != nil {
+				return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+			}
+			return mcp.NewToolResultText(manager.SuccessResponse(mounts)), nil
+		}
+
+		mount, err := mgr.MountWorkspace(envID, hostPath, request.GetString("path", ""), request.GetString("mode", ""), request.GetBool("read_only", false))
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(mount)), nil
+	}
+}
+
+func workspaceUnmountHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		path := request.GetString("path", "")
+		if path == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		mount, err := mgr.UnmountWorkspace(envID, path)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(mount)), nil
 	}
 }
 
@@ -262,7 +1097,7 @@ func workspaceGitCloneHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 
 		dirName := request.GetString("dir_name", "")
 
-		info, err := mgr.GitCloneToWorkspace(envID, repoURL, dirName)
+		info, err := mgr.GitCloneToWorkspace(ctx, envID, repoURL, dirName)
 		if err != nil {
 			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
 		}
@@ -270,3 +1105,147 @@ func workspaceGitCloneHandler(mgr *manager.Manager) server.ToolHandlerFunc {
 		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
 	}
 }
+
+func workspaceGitWorktreeAddHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		repoDir := request.GetString("repo_dir", "")
+		ref := request.GetString("ref", "")
+		newBranch := request.GetString("new_branch", "")
+		if repoDir == "" || (ref == "" && newBranch == "") {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		dirName := request.GetString("dir_name", "")
+
+		info, err := mgr.GitWorktreeAdd(ctx, envID, repoDir, ref, newBranch, dirName)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(info)), nil
+	}
+}
+
+func workspaceListTrashHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		entries, err := mgr.ListTrash(envID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(entries)), nil
+	}
+}
+
+func workspaceRestoreTrashHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		trashID := request.GetString("trash_id", "")
+		if trashID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		entry, err := mgr.RestoreTrash(envID, trashID)
+		if err != nil {
+			return mcp.NewToolResultText(manager.ErrorResponse(err)), nil
+		}
+
+		return mcp.NewToolResultText(manager.SuccessResponse(map[string]interface{}{
+			"message": "Restored from trash successfully",
+			"entry":   entry,
+		})), nil
+	}
+}
+
+func workspaceDownloadHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		rawURL := request.GetString("url", "")
+		if rawURL == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		opts := manager.DownloadOptions{
+			Path:      request.GetString("path", ""),
+			SHA256:    request.GetString("sha256", ""),
+			MaxBytes:  int64(request.GetFloat("max_mb", 0) * (1 << 20)),
+			Overwrite: request.GetBool("overwrite", false),
+			Progress:  progressNotifier(ctx, request, "downloaded"),
+		}
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.DownloadToWorkspace(ctx, envID, rawURL, opts)
+		}), nil
+	}
+}
+
+func workspaceSyncRemoteHandler(mgr *manager.Manager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		envID := request.GetString("env_id", "")
+		if envID == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingEnvID)), nil
+		}
+
+		uri := request.GetString("uri", "")
+		direction := request.GetString("direction", "")
+		if uri == "" || direction == "" {
+			return mcp.NewToolResultText(manager.ErrorResponse(errMissingParams)), nil
+		}
+
+		opts := manager.SyncOptions{
+			Direction: direction,
+			Path:      request.GetString("path", ""),
+			Include:   stringArrayArg(request, "include"),
+			Exclude:   stringArrayArg(request, "exclude"),
+			DryRun:    request.GetBool("dry_run", false),
+			Progress:  progressNotifier(ctx, request, "transferred"),
+		}
+		return runMaybeAsync(ctx, mgr, request, func(ctx context.Context) (any, error) {
+			return mgr.SyncRemote(ctx, envID, uri, opts)
+		}), nil
+	}
+}
+
+// progressNotifier returns a callback that sends notifications/progress for the
+// request, or nil if the client did not ask for progress with a progressToken
+func progressNotifier(ctx context.Context, request mcp.CallToolRequest, verb string) func(done, total int64) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	token := request.Params.Meta.ProgressToken
+	return func(done, total int64) {
+		params := map[string]any{
+			"progressToken": token,
+			"progress":      done,
+		}
+		message := fmt.Sprintf("%s %d MB", verb, done>>20)
+		if total > 0 {
+			params["total"] = total
+			message = fmt.Sprintf("%s %d of %d MB", verb, done>>20, total>>20)
+		}
+		params["message"] = message
+		// Best effort: the client may be gone for an async download
+		srv.SendNotificationToClient(ctx, "notifications/progress", params)
+	}
+}
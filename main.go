@@ -2,29 +2,69 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/richinsley/jumpboot-mcp/internal/discovery"
 	"github.com/richinsley/jumpboot-mcp/internal/manager"
+	"github.com/richinsley/jumpboot-mcp/internal/netpolicy"
 	"github.com/richinsley/jumpboot-mcp/internal/proxy"
 	mcpserver "github.com/richinsley/jumpboot-mcp/internal/server"
+	"github.com/richinsley/jumpboot-mcp/internal/service"
 	"github.com/richinsley/jumpboot-mcp/internal/tools"
 )
 
 func main() {
+	// Helper that runs environment processes under a restricted network policy
+	if len(os.Args) > 1 && os.Args[1] == netpolicy.ExecCommand {
+		os.Exit(netpolicy.RunExec(os.Args[2:]))
+	}
+	// Service management subcommands
+	if len(os.Args) > 1 && (os.Args[1] == "install-service" || os.Args[1] == "uninstall-service") {
+		runServiceCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// Transport flags
-	transport := flag.String("transport", "stdio", "Transport type: stdio, http")
-	addr := flag.String("addr", ":8080", "HTTP server address (for http transport)")
-	endpoint := flag.String("endpoint", "/mcp", "HTTP endpoint path (for http transport)")
+	transport := flag.String("transport", "stdio", "Transport type: stdio, http, ws (streamable HTTP plus WebSocket upgrades on the same endpoint) or sse (legacy HTTP+SSE at <endpoint>/sse and <endpoint>/message)")
+	addr := flag.String("addr", ":8080", "HTTP server address (for http, ws and sse transports)")
+	endpoint := flag.String("endpoint", "/mcp", "HTTP endpoint path (for http, ws and sse transports)")
 	stateless := flag.Bool("stateless", false, "Run HTTP server in stateless mode")
 	certFile := flag.String("tls-cert", "", "TLS certificate file (enables HTTPS)")
 	keyFile := flag.String("tls-key", "", "TLS key file (enables HTTPS)")
+	health := flag.Bool("health", true, "Serve /healthz (liveness) and /readyz (readiness) with the server status as JSON in HTTP mode")
+	metricsPath := flag.String("metrics-path", "/metrics", "HTTP path for Prometheus metrics, including scraped process metrics; on the MCP listener it requires an admin bearer token (empty disables)")
+	metricsAddr := flag.String("metrics-addr", "", "Serve metrics without a token on this separate address (e.g. 127.0.0.1:9090) instead of the MCP listener")
+	sessionIsolation := flag.Bool("session-isolation", false, "Scope environments, REPLs and processes to the MCP session that created them (HTTP mode)")
+	adminToken := flag.String("admin-token", "", "Bearer token granting access to resources of all sessions")
+	var federationExport []string
+	flag.Var((*listFlag)(&federationExport), "federation-export", "Tools (names or globs) other jumpboot-mcp instances may list and call through federation; prefix with ! to deny, e.g. '!destroy_*' (repeatable or comma-separated; empty = all)")
+	federationMaxHops := flag.Int("federation-max-hops", discovery.DefaultMaxHops, "Max federation proxies between a client and a tool; proxied tools beyond it are not re-exported, and looping requests are refused")
+	sessionIdleTimeout := flag.Duration("session-idle-timeout", 0, "With -session-isolation, treat a session without tool calls for this long as gone (0 = only when it disconnects)")
+	sessionReapGrace := flag.Duration("session-reap-grace", 0, "With -session-isolation, destroy resources of a gone session after this long (0 = keep until an admin claims them)")
+	rolesFile := flag.String("roles", "", "JSON file mapping bearer tokens to roles reader, runner or admin ([{\"name\", \"role\", \"token_env\"}]); calls without a role token are refused (HTTP mode)")
+	sessionMaxEnvironments := flag.Int("session-max-environments", 0, "Max environments one MCP session may own (0 = unlimited)")
+	sessionMaxExecutions := flag.Int("session-max-executions", 0, "Max concurrent executions and installs of one MCP session (0 = unlimited)")
+	maxExecutions := flag.Int("max-executions", 0, "Max concurrent executions and installs on the server (0 = unlimited)")
+	jobMaxRunning := flag.Int("job-max-running", 0, "Max background jobs (async: true) running on the server; further jobs wait in the priority queue (0 = unlimited)")
+	jobMaxPerEnv := flag.Int("job-max-per-env", 0, "Max background jobs running against one environment; further jobs wait in the queue (0 = unlimited)")
+	jobMaxPerSession := flag.Int("job-max-per-session", 0, "Max background jobs running for one MCP session; further jobs wait in the queue (0 = unlimited)")
+	sessionCallsPerMinute := flag.Int("session-calls-per-minute", 0, "Max tool calls per minute of one MCP session (0 = unlimited)")
+	callsPerMinute := flag.Int("calls-per-minute", 0, "Max tool calls per minute on the server (0 = unlimited)")
+	auditLog := flag.String("audit-log", "", "Append-only JSONL file recording every mutating tool call (session, tool, arguments, outcome); searchable by admins with audit_query")
 
 	// mDNS flags
 	note := flag.String("note", "", "Human-readable server description (e.g., 'GPU server for ML')")
@@ -32,6 +72,55 @@ func main() {
 	mdnsAnnounce := flag.Bool("mdns-announce", true, "Enable mDNS service announcement (HTTP mode)")
 	mdnsDiscover := flag.Bool("mdns-discover", true, "Enable mDNS service discovery (stdio mode)")
 	discoverTimeout := flag.Duration("discover-timeout", 5*time.Second, "Discovery wait time at startup")
+	var mdnsIfaces discovery.InterfaceFilter
+	flag.Var((*listFlag)(&mdnsIfaces.Include), "mdns-interface", "Network interface (name or glob, e.g. br0 or bond*) to announce and browse on, replacing the physical-interface whitelist (repeatable or comma-separated)")
+	flag.Var((*listFlag)(&mdnsIfaces.Exclude), "mdns-exclude-interface", "Network interface (name or glob) never used for mDNS (repeatable or comma-separated)")
+	var remoteSpecs []string
+	flag.Func("remote", "Remote server to proxy in stdio mode: URL[;name=NAME][;note=TEXT][;token=TOKEN][;token_env=VAR] (repeatable)", func(value string) error {
+		remoteSpecs = append(remoteSpecs, value)
+		return nil
+	})
+	remotesFile := flag.String("remotes-file", "", "JSON file listing remote servers to proxy in stdio mode ([{\"url\", \"name\", \"note\", \"token\", \"token_env\"}])")
+
+	// Environment flags
+	envLockWait := flag.Duration("env-lock-wait", 0, "How long an operation waits for a busy environment (0 = fail immediately)")
+	warmPool := flag.Int("warm-pool", 0, "Pre-warmed venvs kept ready per Python version, so create_environment returns at once (0 = disabled)")
+	warmPoolVersions := flag.String("warm-pool-versions", manager.DefaultPythonVersion, "Comma-separated Python versions the warm pool is kept for")
+	cloneHardlinks := flag.Bool("clone-hardlinks", true, "Let create_environment with a template hard-link package files when the filesystem has no copy-on-write clones")
+	spoolThreshold := flag.Int("spool-threshold", manager.DefaultSpoolThreshold, "Tool results larger than this many bytes are stored on the server and returned shortened, with a result_id for fetch_result (0 = never)")
+	spoolTTL := flag.Duration("spool-ttl", manager.DefaultSpoolTTL, "How long spooled tool results can be fetched")
+	drainGrace := flag.Duration("drain-grace", manager.DefaultDrainGrace, "On SIGTERM or server_drain, how long in-flight tool calls and jobs may take before the server exits anyway")
+	drainCheckpoint := flag.Bool("drain-checkpoint-repls", false, "Checkpoint every idle REPL session into its workspace when draining, so it can be restored after a restart")
+	trashRetention := flag.Duration("trash-retention", manager.DefaultTrashRetention, "How long deleted workspace content stays restorable (0 = delete immediately)")
+	maxEnvironments := flag.Int("max-environments", 0, "Max environments on this server; creation beyond it fails with cleanup hints (0 = unlimited)")
+	minFreeDiskMB := flag.Int("min-free-disk-mb", 0, "Free disk space (MB) that must remain to create an environment (0 = no check)")
+	packageIndexes := flag.String("package-indexes", "", "JSON file of private pip indexes and conda channels install_packages may use by name ([{\"name\", \"type\", \"url\", \"extra\", \"username\", \"password_env\"}])")
+	objectStores := flag.String("object-stores", "", "JSON file of S3 and GCS accounts workspace_sync_remote may use ([{\"name\", \"type\" (s3/gs), \"buckets\", \"endpoint\", \"region\", \"access_key_id\", \"secret_access_key_env\", \"session_token_env\", \"credentials_file\", \"access_token_env\"}])")
+	secretsFile := flag.String("secrets", "", "JSON file of named secrets environments can reference; values are redacted from all output ([{\"name\", \"value_env\"}])")
+	postCreateHook := flag.String("post-create-hook", "", "Python script run inside every newly created or restored environment")
+	commandAllow := flag.String("command-allow", "", "Comma-separated executables (names or globs) run_command, spawn_command and terminals may start (empty = any)")
+	commandDeny := flag.String("command-deny", "", "Comma-separated executables (names or globs) that may never be started")
+	webhookAllow := flag.String("webhook-allow", "", "Comma-separated URL prefixes job and process webhooks may target (empty = any http(s) URL)")
+	webhookSecret := flag.String("webhook-secret", "", "Default secret for signing webhook deliveries")
+	downloadAllow := flag.String("download-allow", "", "Comma-separated URL prefixes workspace_download may fetch (empty = any http(s) URL)")
+	downloadMaxMB := flag.Int("download-max-mb", int(manager.DefaultDownloadMaxBytes>>20), "Largest file workspace_download may fetch, in MB")
+	mountAllow := flag.String("mount-allow", "", "Comma-separated host directories workspace_mount may mount into workspaces; append :ro to allow read-only mounts only (empty = none)")
+	sandboxImage := flag.String("sandbox-image", manager.DefaultSandboxImage, "Container image for environments created with podman or docker isolation")
+	modelCache := flag.String("model-cache", "", "Shared Hugging Face cache (HF_HOME) for all environments (default: $HF_HOME or ~/.jumpboot-mcp/models; 'off' leaves HF_HOME alone)")
+	processOutputLines := flag.Int("process-output-lines", manager.DefaultOutputMaxLines, "Captured output lines kept in memory per spawned process")
+	processOutputKB := flag.Int("process-output-kb", manager.DefaultOutputMaxBytes>>10, "Captured output kept in memory per spawned process, in KB")
+	maxREPLsPerEnv := flag.Int("max-repls-per-env", 0, "Max REPL sessions per environment; the least recently used idle session is evicted (0 = unlimited)")
+	maxREPLs := flag.Int("max-repls", 0, "Max REPL sessions across the server; the least recently used idle session is evicted (0 = unlimited)")
+
+	// Tool list budget flags
+	toolDescMax := flag.Int("tool-desc-max", 0, "Max length of local tool descriptions (0 = unlimited)")
+	remoteToolDescMax := flag.Int("remote-tool-desc-max", 0, "Max length of proxied remote tool descriptions (0 = unlimited)")
+	remoteTools := flag.String("remote-tools", "expand", "How remote tools are exposed: expand (one prefixed tool per remote tool) or collapse (call_remote_tool meta-tool)")
+	remotePrefix := flag.String("remote-prefix", string(proxy.NamingColon), "How proxied tool names are prefixed: colon (server:tool), underscore (server_tool) or none (tool, prefixed only on collisions)")
+	var remoteAliases []string
+	flag.Var((*listFlag)(&remoteAliases), "remote-alias", "Short prefix for a remote's tools, as instance=alias (repeatable or comma-separated)")
+	remoteTrustAnnotations := flag.Bool("remote-trust-annotations", false, "Keep the read-only and destructive hints remote servers report for their tools; otherwise proxied tools count as destructive for roles, draining and auditing")
+	remoteHealthInterval := flag.Duration("remote-health-interval", proxy.DefaultHealthInterval, "How often remote servers are pinged; unreachable ones are reconnected with backoff (0 = disabled)")
 
 	flag.Parse()
 
@@ -42,32 +131,245 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *remoteTools != "expand" && *remoteTools != "collapse" {
+		fmt.Fprintf(os.Stderr, "Unknown remote-tools mode: %s (use 'expand' or 'collapse')\n", *remoteTools)
+		os.Exit(1)
+	}
+
+	toolNaming, err := proxy.ParseToolNaming(*remotePrefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -remote-prefix: %v\n", err)
+		os.Exit(1)
+	}
+	aliases, err := proxy.ParseAliases(remoteAliases)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -remote-alias: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := mdnsIfaces.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid mDNS interface selection: %v\n", err)
+		os.Exit(1)
+	}
+	remotes, err := loadRemotes(remoteSpecs, *remotesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid remote server: %v\n", err)
+		os.Exit(1)
+	}
+	if *transport == "sse" && *stateless {
+		fmt.Fprintln(os.Stderr, "-transport sse requires stateful mode (remove -stateless)")
+		os.Exit(1)
+	}
+	if *sessionIsolation && *stateless {
+		fmt.Fprintln(os.Stderr, "-session-isolation requires stateful HTTP mode (remove -stateless)")
+		os.Exit(1)
+	}
+	mgr.SetSessionIsolation(*sessionIsolation)
+	if *sessionIsolation {
+		mgr.SetSessionReaping(*sessionIdleTimeout, *sessionReapGrace)
+	}
+	if *auditLog != "" {
+		audit, err := manager.OpenAuditLog(*auditLog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -audit-log: %v\n", err)
+			os.Exit(1)
+		}
+		mgr.SetAuditLog(audit)
+	}
+	mgr.SetRateLimits(manager.RateLimits{
+		SessionEnvironments:   max(*sessionMaxEnvironments, 0),
+		SessionExecutions:     max(*sessionMaxExecutions, 0),
+		Executions:            max(*maxExecutions, 0),
+		SessionCallsPerMinute: max(*sessionCallsPerMinute, 0),
+		CallsPerMinute:        max(*callsPerMinute, 0),
+	})
+	mgr.SetJobLimits(manager.JobLimits{
+		Running:        max(*jobMaxRunning, 0),
+		PerEnvironment: max(*jobMaxPerEnv, 0),
+		PerSession:     max(*jobMaxPerSession, 0),
+	})
+	mgr.SetLockWait(*envLockWait)
+	mgr.SetTrashRetention(*trashRetention)
+	mgr.SetDrainPolicy(*drainGrace, *drainCheckpoint)
+	mgr.SetResultSpool(*spoolThreshold, *spoolTTL)
+	mgr.SetWarmPool(*warmPool, splitList(*warmPoolVersions))
+	mgr.SetCloneHardlinks(*cloneHardlinks)
+	mgr.SetREPLLimits(*maxREPLsPerEnv, *maxREPLs)
+	mgr.SetCapacityLimits(*maxEnvironments, uint64(max(*minFreeDiskMB, 0))<<20)
+	mgr.SetWebhookPolicy(splitList(*webhookAllow), *webhookSecret)
+	mgr.SetOutputLimits(*processOutputLines, max(*processOutputKB, 0)<<10)
+	mgr.SetDownloadPolicy(splitList(*downloadAllow), int64(max(*downloadMaxMB, 0))<<20)
+	if err := mgr.SetMountPolicy(splitList(*mountAllow)); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -mount-allow: %v\n", err)
+		os.Exit(1)
+	}
+	mgr.SetSandboxImage(*sandboxImage)
+	if err := mgr.SetModelCache(resolveModelCache(*modelCache)); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -model-cache: %v\n", err)
+		os.Exit(1)
+	}
+	if err := mgr.SetCommandPolicy(splitList(*commandAllow), splitList(*commandDeny)); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid command policy: %v\n", err)
+		os.Exit(1)
+	}
+	if *packageIndexes != "" {
+		indexes, err := manager.LoadPackageIndexes(*packageIndexes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -package-indexes: %v\n", err)
+			os.Exit(1)
+		}
+		mgr.SetPackageIndexes(indexes)
+	}
+	if *objectStores != "" {
+		stores, err := manager.LoadObjectStores(*objectStores)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -object-stores: %v\n", err)
+			os.Exit(1)
+		}
+		mgr.SetObjectStores(stores)
+	}
+	if *secretsFile != "" {
+		secrets, err := manager.LoadSecrets(*secretsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -secrets: %v\n", err)
+			os.Exit(1)
+		}
+		mgr.AddSecrets(secrets)
+	}
+	if err := mgr.SetPostCreateHook(*postCreateHook); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -post-create-hook: %v\n", err)
+		os.Exit(1)
+	}
+
+	exportFilter, err := mcpserver.ParseExportFilter(federationExport)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -federation-export: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *federationMaxHops < 1 {
+		fmt.Fprintln(os.Stderr, "-federation-max-hops must be at least 1")
+		os.Exit(1)
+	}
+
+	var roles *mcpserver.RolePolicy
+	if *rolesFile != "" {
+		if *transport == "stdio" {
+			fmt.Fprintln(os.Stderr, "-roles requires an HTTP transport (stdio requests carry no bearer token)")
+			os.Exit(1)
+		}
+		if roles, err = mcpserver.LoadRolePolicy(*rolesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -roles: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	serverOpts := mcpserver.Options{
+		DescriptionMaxLen: *toolDescMax,
+		AdminToken:        *adminToken,
+		Roles:             roles,
+		FederationExport:  exportFilter,
+		FederationMaxHops: *federationMaxHops,
+	}
+
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Under the Windows Service Control Manager, a service stop arrives as SIGTERM
+	if _, err := service.RunAsService(service.DefaultName, sigChan); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to detect service mode: %v\n", err)
+	}
+
 	switch *transport {
 	case "stdio":
-		runStdioMode(mgr, sigChan, *mdnsDiscover, *discoverTimeout)
+		runStdioMode(mgr, sigChan, serverOpts, stdioConfig{
+			remotes:         remotes,
+			discover:        *mdnsDiscover,
+			discoverTimeout: *discoverTimeout,
+			mdnsIfaces:      mdnsIfaces,
+			remoteDescMax:   *remoteToolDescMax,
+			trustHints:      *remoteTrustAnnotations,
+			collapseRemote:  *remoteTools == "collapse",
+			healthInterval:  *remoteHealthInterval,
+			toolNaming:      toolNaming,
+			aliases:         aliases,
+		})
 
-	case "http":
-		runHTTPMode(mgr, sigChan, *addr, *endpoint, *stateless, *certFile, *keyFile,
-			*note, *instanceName, *mdnsAnnounce)
+	case "http", "ws", "sse":
+		runHTTPMode(mgr, sigChan, serverOpts, httpConfig{
+			addr:         *addr,
+			endpoint:     *endpoint,
+			stateless:    *stateless,
+			certFile:     *certFile,
+			keyFile:      *keyFile,
+			metricsPath:  *metricsPath,
+			metricsAddr:  *metricsAddr,
+			health:       *health,
+			note:         *note,
+			instanceName: *instanceName,
+			announce:     *mdnsAnnounce,
+			mdnsIfaces:   mdnsIfaces,
+			websocket:    *transport == "ws",
+			sse:          *transport == "sse",
+		})
 
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown transport: %s (use 'stdio' or 'http')\n", *transport)
+		fmt.Fprintf(os.Stderr, "Unknown transport: %s (use 'stdio', 'http', 'ws' or 'sse')\n", *transport)
 		os.Exit(1)
 	}
 }
 
-func runStdioMode(mgr *manager.Manager, sigChan chan os.Signal, discover bool, discoverTimeout time.Duration) {
+// stdioConfig holds the stdio mode federation settings
+type stdioConfig struct {
+	remotes         []discovery.ServiceInfo // configured with -remote and -remotes-file
+	discover        bool
+	discoverTimeout time.Duration
+	mdnsIfaces      discovery.InterfaceFilter
+	remoteDescMax   int
+	trustHints      bool // keep the remotes' tool annotations
+	collapseRemote  bool
+	healthInterval  time.Duration
+	toolNaming      proxy.ToolNaming
+	aliases         map[string]string // instance name -> tool prefix
+}
+
+func runStdioMode(mgr *manager.Manager, sigChan chan os.Signal, serverOpts mcpserver.Options, cfg stdioConfig) {
+	remotes, discover, discoverTimeout := cfg.remotes, cfg.discover, cfg.discoverTimeout
+	collapseRemote := cfg.collapseRemote
+
 	var aggregator *proxy.ToolAggregator
+	if discover || len(remotes) > 0 {
+		aggregator = proxy.NewToolAggregator()
+		aggregator.SetDescriptionLimit(cfg.remoteDescMax)
+		aggregator.SetTrustAnnotations(cfg.trustHints)
+		aggregator.SetHealthInterval(cfg.healthInterval)
+		aggregator.SetMaxHops(serverOpts.FederationMaxHops)
+
+		// Proxied tools must not shadow local, federation or meta tools
+		reserved := mcpserver.LocalToolNames(mgr, serverOpts)
+		for _, td := range append(tools.RegisterFederationTools(aggregator), aggregator.GetMetaTools()...) {
+			reserved = append(reserved, td.Tool.Name)
+		}
+		aggregator.SetToolNaming(cfg.toolNaming, cfg.aliases, reserved)
+	}
+
+	// Connect to the configured remote servers
+	configured := make(map[string]bool)
+	for _, svc := range remotes {
+		configured[svc.InstanceName] = true
+		fmt.Fprintf(os.Stderr, "Remote %s at %s\n", svc.InstanceName, svc.URL())
+		if err := aggregator.AddRemote(context.Background(), svc); err != nil {
+			fmt.Fprintf(os.Stderr, "    Warning: failed to connect: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "    Connected successfully\n")
+		}
+	}
 
 	// Discover remote services if enabled
 	if discover {
-		aggregator = proxy.NewToolAggregator()
 		ctx, cancel := context.WithTimeout(context.Background(), discoverTimeout)
-		services, err := discovery.Discover(ctx, discoverTimeout)
+		services, err := discovery.Discover(ctx, discoverTimeout, cfg.mdnsIfaces)
 		cancel()
 
 		if err != nil {
@@ -79,8 +381,16 @@ func runStdioMode(mgr *manager.Manager, sigChan chan os.Signal, discover bool, d
 				if svc.Note != "" {
 					fmt.Fprintf(os.Stderr, " (%s)", svc.Note)
 				}
+				if svc.GPU != "" {
+					fmt.Fprintf(os.Stderr, " [GPU: %s]", svc.GPU)
+				}
 				fmt.Fprintln(os.Stderr)
 
+				if configured[svc.InstanceName] {
+					fmt.Fprintf(os.Stderr, "    Skipped: a configured remote has the same name\n")
+					continue
+				}
+
 				// Connect to the remote service
 				if err := aggregator.AddRemote(context.Background(), svc); err != nil {
 					fmt.Fprintf(os.Stderr, "    Warning: failed to connect: %v\n", err)
@@ -93,20 +403,42 @@ func runStdioMode(mgr *manager.Manager, sigChan chan os.Signal, discover bool, d
 
 	// Create the MCP server with local tools + proxy tools + federation tools
 	var s *server.MCPServer
-	if aggregator != nil && aggregator.RemoteCount() > 0 {
-		proxyTools := aggregator.GetAllTools()
-		// Add federation tools (list_servers, etc.)
-		federationTools := tools.RegisterFederationTools(aggregator)
-		proxyTools = append(proxyTools, federationTools...)
-		fmt.Fprintf(os.Stderr, "Registered %d proxied tools from remote servers\n", len(proxyTools)-len(federationTools))
-		s = mcpserver.NewWithExtraTools(mgr, proxyTools)
+	if aggregator != nil {
+		var proxyTools []tools.ToolDef
+		if aggregator.RemoteCount() == 0 {
+			fmt.Fprintf(os.Stderr, "No remote servers connected\n")
+		} else if collapseRemote {
+			// Expose remotes through call_remote_tool instead of one tool per remote tool
+			proxyTools = aggregator.GetMetaTools()
+			fmt.Fprintf(os.Stderr, "Remote tools collapsed behind call_remote_tool (%d servers)\n", aggregator.RemoteCount())
+		} else {
+			proxyTools = aggregator.GetAllTools()
+			fmt.Fprintf(os.Stderr, "Registered %d proxied tools from remote servers\n", len(proxyTools))
+		}
+		// Setting Remotes also registers the federation tools (list_servers, etc.)
+		serverOpts.Remotes = aggregator
+		serverOpts.RemoteResources = aggregator.GetResourceTemplates()
+		s = mcpserver.NewWithOptions(mgr, proxyTools, serverOpts)
+		if !collapseRemote {
+			// A remote that comes back may offer different tools
+			aggregator.OnToolsChanged(func(removed []string, current []tools.ToolDef) {
+				if len(removed) > 0 {
+					s.DeleteTools(removed...)
+				}
+				serverTools := make([]server.ServerTool, len(current))
+				for i, td := range current {
+					serverTools[i] = server.ServerTool{Tool: td.Tool, Handler: td.Handler}
+				}
+				s.AddTools(serverTools...)
+			})
+		}
 	} else {
-		s = mcpserver.New(mgr)
+		s = mcpserver.NewWithOptions(mgr, nil, serverOpts)
 	}
 
-	// Handle shutdown
+	// Handle shutdown once the server has drained
 	go func() {
-		<-sigChan
+		waitForDrain(mgr, sigChan)
 		if aggregator != nil {
 			aggregator.Close()
 		}
@@ -114,22 +446,48 @@ func runStdioMode(mgr *manager.Manager, sigChan chan os.Signal, discover bool, d
 		os.Exit(0)
 	}()
 
-	if err := server.ServeStdio(s); err != nil {
+	// ServeStdio would stop reading on SIGTERM, before in-flight calls could answer
+	if err := server.NewStdioServer(s).Listen(context.Background(), os.Stdin, os.Stdout); err != nil {
 		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func runHTTPMode(mgr *manager.Manager, sigChan chan os.Signal, addr, endpoint string,
-	stateless bool, certFile, keyFile, note, instanceName string, announce bool) {
+// httpConfig holds the HTTP transport settings
+type httpConfig struct {
+	addr         string
+	endpoint     string
+	stateless    bool
+	certFile     string
+	keyFile      string
+	metricsPath  string
+	metricsAddr  string // serve metrics here instead of on the MCP listener
+	health       bool   // serve /healthz and /readyz
+	note         string
+	instanceName string
+	announce     bool
+	mdnsIfaces   discovery.InterfaceFilter
+	websocket    bool // also accept WebSocket connections on the endpoint
+	sse          bool // serve the legacy HTTP+SSE protocol instead of streamable HTTP
+}
+
+func runHTTPMode(mgr *manager.Manager, sigChan chan os.Signal, serverOpts mcpserver.Options, cfg httpConfig) {
+	addr, endpoint := cfg.addr, cfg.endpoint
+	stateless, certFile, keyFile := cfg.stateless, cfg.certFile, cfg.keyFile
+	note, instanceName, announce := cfg.note, cfg.instanceName, cfg.announce
 
 	// Create the MCP server
-	s := mcpserver.New(mgr)
+	s := mcpserver.NewWithOptions(mgr, nil, serverOpts)
+
+	// Serve the MCP endpoint alongside auxiliary endpoints (metrics) on one mux
+	mux := http.NewServeMux()
 
 	// Build HTTP server options
 	opts := []server.StreamableHTTPOption{
 		server.WithEndpointPath(endpoint),
 		server.WithHeartbeatInterval(30 * time.Second),
+		server.WithHTTPContextFunc(mcpserver.HTTPContextFunc),
+		server.WithStreamableHTTPServer(&http.Server{Handler: mux}),
 	}
 
 	if stateless {
@@ -142,7 +500,60 @@ func runHTTPMode(mgr *manager.Manager, sigChan chan os.Signal, addr, endpoint st
 	}
 
 	// Create the HTTP server
-	httpServer := server.NewStreamableHTTPServer(s, opts...)
+	var httpServer mcpHTTPServer
+	announcedEndpoint := endpoint
+	if cfg.sse {
+		sseServer, srv := newSSEServer(s, mux, endpoint)
+		httpServer = &sseTransport{sse: sseServer, srv: srv, certFile: certFile, keyFile: keyFile}
+		mux.Handle(sseServer.CompleteSsePath(), sseServer)
+		mux.Handle(sseServer.CompleteMessagePath(), sseServer)
+		announcedEndpoint = sseServer.CompleteSsePath()
+	} else {
+		streamable := server.NewStreamableHTTPServer(s, opts...)
+		httpServer = streamable
+		if cfg.websocket {
+			mux.Handle(endpoint, mcpserver.WithWebSocket(s, streamable, mcpserver.HTTPContextFunc))
+		} else {
+			mux.Handle(endpoint, streamable)
+		}
+	}
+
+	// Metrics reveal process names and scraped values, so on the MCP listener only
+	// admins may read them; a separate listener is for private interfaces
+	var metricsServer *http.Server
+	if cfg.metricsPath != "" {
+		metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			mgr.WriteMetrics(r.Context(), w)
+		})
+		if cfg.metricsAddr != "" {
+			listener, err := net.Listen("tcp", cfg.metricsAddr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to listen for metrics: %v\n", err)
+				os.Exit(1)
+			}
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle(cfg.metricsPath, metrics)
+			metricsServer = &http.Server{Handler: metricsMux}
+			go metricsServer.Serve(listener)
+			fmt.Fprintf(os.Stderr, "Serving metrics on http://%s%s\n", listener.Addr(), cfg.metricsPath)
+		} else {
+			if serverOpts.AdminToken == "" && serverOpts.Roles == nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s needs an admin bearer token; set -admin-token or -roles, or serve it with -metrics-addr\n", cfg.metricsPath)
+			}
+			mux.Handle(cfg.metricsPath, mcpserver.AdminOnly(metrics, serverOpts.AdminToken, serverOpts.Roles))
+		}
+	}
+	if cfg.health {
+		// Liveness only needs an answer; readiness fails while shutting down
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			writeStatus(w, mgr.Status(), true)
+		})
+		mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+			status := mgr.Status()
+			writeStatus(w, status, status.Ready)
+		})
+	}
 
 	// Start mDNS announcer if enabled
 	var announcer *discovery.Announcer
@@ -155,11 +566,14 @@ func runHTTPMode(mgr *manager.Manager, sigChan chan os.Signal, addr, endpoint st
 				InstanceName: instanceName,
 				Port:         port,
 				Note:         note,
-				Endpoint:     endpoint,
+				GPU:          mgr.GPUInfo(context.Background()).Summary,
+				Endpoint:     announcedEndpoint,
 				TLS:          useTLS,
+				WebSocket:    cfg.websocket,
+				SSE:          cfg.sse,
 			}
 
-			announcer = discovery.NewAnnouncer(info)
+			announcer = discovery.NewAnnouncer(info, cfg.mdnsIfaces)
 			if err := announcer.Start(); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to start mDNS announcer: %v\n", err)
 				announcer = nil
@@ -168,13 +582,16 @@ func runHTTPMode(mgr *manager.Manager, sigChan chan os.Signal, addr, endpoint st
 				if note != "" {
 					fmt.Fprintf(os.Stderr, "mDNS: note = %s\n", note)
 				}
+				if info.GPU != "" {
+					fmt.Fprintf(os.Stderr, "mDNS: gpu = %s\n", info.GPU)
+				}
 			}
 		}
 	}
 
-	// Handle graceful shutdown
+	// Handle graceful shutdown once the server has drained
 	go func() {
-		<-sigChan
+		waitForDrain(mgr, sigChan)
 		fmt.Fprintln(os.Stderr, "Shutting down...")
 
 		// Stop mDNS announcer
@@ -185,6 +602,9 @@ func runHTTPMode(mgr *manager.Manager, sigChan chan os.Signal, addr, endpoint st
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		httpServer.Shutdown(ctx)
+		if metricsServer != nil {
+			metricsServer.Shutdown(ctx)
+		}
 		mgr.Shutdown()
 		os.Exit(0)
 	}()
@@ -193,10 +613,210 @@ func runHTTPMode(mgr *manager.Manager, sigChan chan os.Signal, addr, endpoint st
 	if useTLS {
 		proto = "https"
 	}
-	fmt.Fprintf(os.Stderr, "Starting MCP server on %s://%s%s\n", proto, addr, endpoint)
+	fmt.Fprintf(os.Stderr, "Starting MCP server on %s://%s%s\n", proto, addr, announcedEndpoint)
+	if cfg.websocket {
+		fmt.Fprintf(os.Stderr, "Accepting WebSocket connections on ws%s://%s%s\n", strings.TrimPrefix(proto, "http"), addr, endpoint)
+	}
 
 	if err := httpServer.Start(addr); err != nil {
+		if errors.Is(err, http.ErrServerClosed) {
+			// The shutdown goroutine exits once the manager has shut down
+			select {}
+		}
 		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// mcpHTTPServer is the part of the streamable HTTP and SSE servers runHTTPMode uses
+type mcpHTTPServer interface {
+	Start(addr string) error
+	Shutdown(ctx context.Context) error
+}
+
+// waitForDrain returns once the server has drained, whether the drain was started by
+// a signal or by server_drain. A second signal ends the wait early.
+func waitForDrain(mgr *manager.Manager, sigChan chan os.Signal) {
+	for {
+		select {
+		case <-sigChan:
+			if mgr.DrainStatus() != nil {
+				fmt.Fprintln(os.Stderr, "Second signal, shutting down without waiting")
+				return
+			}
+			drain := mgr.Drain(-1)
+			fmt.Fprintf(os.Stderr, "Draining: waiting up to %gs for %d tool calls and %d jobs (signal again to exit now)\n",
+				drain.GraceSeconds, drain.InFlightCalls, drain.RunningJobs)
+		case <-mgr.Drained():
+			if drain := mgr.DrainStatus(); drain != nil && drain.TimedOut {
+				fmt.Fprintf(os.Stderr, "Drain grace period expired with %d tool calls and %d jobs still running\n", drain.InFlightCalls, drain.RunningJobs)
+			}
+			return
+		}
+	}
+}
+
+// writeStatus writes a server status as JSON, with 503 Service Unavailable unless ok
+func writeStatus(w http.ResponseWriter, status *manager.ServerStatus, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// newSSEServer creates the legacy HTTP+SSE server, serving <endpoint>/sse and
+// <endpoint>/message through mux
+func newSSEServer(s *server.MCPServer, mux *http.ServeMux, endpoint string) (*server.SSEServer, *http.Server) {
+	srv := &http.Server{Handler: mux}
+	sseServer := server.NewSSEServer(s,
+		server.WithStaticBasePath(endpoint),
+		// Clients resolve the relative message path against the URL they connected to,
+		// which keeps working behind proxies and with any bind address
+		server.WithUseFullURLForMessageEndpoint(false),
+		server.WithKeepAlive(true),
+		server.WithKeepAliveInterval(30*time.Second),
+		server.WithSSEContextFunc(mcpserver.HTTPContextFunc),
+		server.WithHTTPServer(srv),
+	)
+	return sseServer, srv
+}
+
+// sseTransport runs the SSE server on its own http.Server, which unlike the streamable
+// HTTP server has no TLS option
+type sseTransport struct {
+	sse               *server.SSEServer
+	srv               *http.Server
+	certFile, keyFile string
+}
+
+func (t *sseTransport) Start(addr string) error {
+	t.srv.Addr = addr
+	if t.certFile != "" && t.keyFile != "" {
+		return t.srv.ListenAndServeTLS(t.certFile, t.keyFile)
+	}
+	return t.srv.ListenAndServe()
+}
+
+// Shutdown closes the open event streams and stops the HTTP server
+func (t *sseTransport) Shutdown(ctx context.Context) error {
+	return t.sse.Shutdown(ctx)
+}
+
+// runServiceCommand installs or removes jumpboot-mcp as a system service. Arguments after
+// the subcommand's own flags (or after "--") are the server flags the service runs with.
+func runServiceCommand(command string, args []string) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	name := fs.String("name", service.DefaultName, "Service name")
+	runAs := fs.String("user", defaultServiceUser(), "Account the system service runs as (environments are stored in its home directory)")
+	userScope := fs.Bool("user-scope", false, "Install for the current user instead of system-wide (Linux/macOS)")
+	printOnly := fs.Bool("print", false, "Print the service definition instead of installing it")
+	noStart := fs.Bool("no-start", false, "Install without enabling and starting the service")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [options] [-- server flags]\n", os.Args[0], command)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg, err := service.NewConfig(*name, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	cfg.User = *runAs
+	cfg.UserScope = *userScope
+
+	switch {
+	case *printOnly:
+		err = service.Print(cfg, os.Stdout)
+	case command == "install-service":
+		err = service.Install(cfg, !*noStart, os.Stderr)
+	default:
+		err = service.Uninstall(cfg, os.Stderr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", command, err)
+		os.Exit(1)
+	}
+}
+
+// defaultServiceUser returns the account a system service should run as: the user who
+// invoked sudo, or the current user unless that is root
+func defaultServiceUser() string {
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return sudoUser
+	}
+	if u, err := user.Current(); err == nil && u.Uid != "0" && runtime.GOOS != "windows" {
+		return u.Username
+	}
+	return ""
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// loadRemotes parses the -remote flags and the -remotes-file, rejecting duplicate names
+func loadRemotes(specs []string, file string) ([]discovery.ServiceInfo, error) {
+	var remotes []discovery.ServiceInfo
+	for _, spec := range specs {
+		info, err := discovery.ParseRemote(spec)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, info)
+	}
+	if file != "" {
+		infos, err := discovery.LoadRemotes(file)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, infos...)
+	}
+
+	seen := make(map[string]bool)
+	for _, info := range remotes {
+		if seen[info.InstanceName] {
+			return nil, fmt.Errorf("duplicate remote name %q (set name= to tell them apart)", info.InstanceName)
+		}
+		seen[info.InstanceName] = true
+	}
+	return remotes, nil
+}
+
+// listFlag is a repeatable flag collecting values, each of which may itself be a
+// comma-separated list
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// resolveModelCache maps the -model-cache flag to a directory: "off" disables the
+// shared cache, and empty keeps an existing HF_HOME or uses ~/.jumpboot-mcp/models
+func resolveModelCache(value string) string {
+	switch value {
+	case "off":
+		return ""
+	case "":
+		if hfHome := os.Getenv("HF_HOME"); hfHome != "" {
+			return hfHome
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		return filepath.Join(home, ".jumpboot-mcp", "models")
+	}
+	return value
+}